@@ -0,0 +1,206 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tchannel
+
+import (
+	"time"
+
+	"github.com/uber-go/atomic"
+)
+
+// Directions of calls in an AccessLogEntry.
+const (
+	AccessLogInbound  = "inbound"
+	AccessLogOutbound = "outbound"
+	AccessLogRelay    = "relay"
+)
+
+// Response codes in an AccessLogEntry, in addition to the metrics key of a
+// system error, such as "timeout", or the failure reason of a relayed call.
+const (
+	AccessLogOK               = "ok"
+	AccessLogApplicationError = "application-error"
+)
+
+// AccessLogEntry describes a single call handled, made or relayed by a channel.
+type AccessLogEntry struct {
+	// Time is when the call started.
+	Time time.Time `json:"time"`
+
+	// Direction is one of AccessLogInbound, AccessLogOutbound or AccessLogRelay.
+	Direction string `json:"direction"`
+
+	// Caller is the name of the calling service.
+	Caller string `json:"caller"`
+
+	// Service and Method are the service and method called.
+	Service string `json:"service"`
+	Method  string `json:"method"`
+
+	// RemoteHostPort is the host:port of the peer that made an inbound or
+	// relayed call, or that an outbound call was sent to.
+	RemoteHostPort string `json:"remoteHostPort"`
+
+	// Latency is how long the call took, in nanoseconds when encoded as JSON.
+	Latency time.Duration `json:"latency"`
+
+	// BytesReceived and BytesSent are the frame payload bytes of the request
+	// and response. For outbound calls, the request is sent and the response
+	// is received.
+	BytesReceived uint64 `json:"bytesReceived"`
+	BytesSent     uint64 `json:"bytesSent"`
+
+	// ResponseCode is AccessLogOK, AccessLogApplicationError, the metrics key
+	// of a system error, or the failure reason of a relayed call.
+	ResponseCode string `json:"responseCode"`
+
+	// Attempt is the attempt number of an outbound call, starting at 1. It
+	// is zero for inbound and relayed calls.
+	Attempt int `json:"attempt,omitempty"`
+}
+
+// AccessLogSink receives an entry for each call once it completes. Log is
+// called synchronously from the goroutine that completed the call, so it
+// must be safe for concurrent use and should not block.
+type AccessLogSink interface {
+	Log(entry AccessLogEntry)
+}
+
+// accessLogCall collects the access log entry for a single call. A nil
+// *accessLogCall is valid, and ignores all updates. The entry is also used
+// to sample slow outbound calls.
+type accessLogCall struct {
+	sink      AccessLogSink
+	slowCalls *slowCallSampler
+	headers   transportHeaders
+	entry     AccessLogEntry
+
+	bytesReceived atomic.Uint64
+	bytesSent     atomic.Uint64
+	responseCode  atomic.String
+}
+
+// newAccessLogCall returns an accessLogCall if the channel has an access log,
+// or samples slow outbound calls, and nil otherwise.
+func (c *Connection) newAccessLogCall(entry AccessLogEntry) *accessLogCall {
+	var slowCalls *slowCallSampler
+	if entry.Direction == AccessLogOutbound {
+		slowCalls = c.slowCalls
+	}
+	if c.accessLog == nil && slowCalls == nil {
+		return nil
+	}
+	entry.RemoteHostPort = c.remotePeerInfo.HostPort
+	return &accessLogCall{sink: c.accessLog, slowCalls: slowCalls, entry: entry}
+}
+
+// setHeaders sets the transport headers recorded for slow calls.
+func (l *accessLogCall) setHeaders(headers transportHeaders) {
+	if l != nil {
+		l.headers = headers
+	}
+}
+
+func (l *accessLogCall) received(f *Frame) {
+	if l != nil {
+		l.bytesReceived.Add(uint64(f.payloadSize()))
+	}
+}
+
+func (l *accessLogCall) sent(f *Frame) {
+	if l != nil {
+		l.bytesSent.Add(uint64(f.payloadSize()))
+	}
+}
+
+func (l *accessLogCall) setResponseCode(code string) {
+	if l != nil {
+		l.responseCode.Store(code)
+	}
+}
+
+// finish logs the entry for the call, which completed at now.
+func (l *accessLogCall) finish(now time.Time) {
+	if l == nil {
+		return
+	}
+
+	entry := l.entry
+	entry.Latency = now.Sub(entry.Time)
+	entry.BytesReceived = l.bytesReceived.Load()
+	entry.BytesSent = l.bytesSent.Load()
+	entry.ResponseCode = l.responseCode.Load()
+	if l.sink != nil {
+		l.sink.Log(entry)
+	}
+	l.slowCalls.record(entry, l.headers)
+}
+
+// responseCode returns the access log response code for a call that
+// completed with the given system error and application error status.
+func accessLogResponseCode(sysErr error, applicationError bool) string {
+	switch {
+	case sysErr != nil:
+		return GetSystemErrorCode(sysErr).MetricsKey()
+	case applicationError:
+		return AccessLogApplicationError
+	default:
+		return AccessLogOK
+	}
+}
+
+// wrapAccessLog wraps a relayed call to record it in the access log, if the
+// channel has one.
+func (r *Relayer) wrapAccessLog(f lazyCallReq, call RelayCall) (RelayCall, *accessLogCall) {
+	log := r.conn.newAccessLogCall(AccessLogEntry{
+		Time:      r.conn.timeNow(),
+		Direction: AccessLogRelay,
+		Caller:    string(f.Caller()),
+		Service:   string(f.Service()),
+		Method:    string(f.Method()),
+	})
+	if log == nil {
+		return call, nil
+	}
+	return accessLogRelayCall{call, log, r.conn.timeNow}, log
+}
+
+// accessLogRelayCall records the outcome of a relayed call in the access log.
+type accessLogRelayCall struct {
+	RelayCall
+
+	log     *accessLogCall
+	timeNow func() time.Time
+}
+
+func (c accessLogRelayCall) Succeeded() {
+	c.log.setResponseCode(AccessLogOK)
+	c.RelayCall.Succeeded()
+}
+
+func (c accessLogRelayCall) Failed(reason string) {
+	c.log.setResponseCode(reason)
+	c.RelayCall.Failed(reason)
+}
+
+func (c accessLogRelayCall) End() {
+	c.log.finish(c.timeNow())
+	c.RelayCall.End()
+}
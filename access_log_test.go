@@ -0,0 +1,128 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tchannel_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type accessLogRecorder struct {
+	sync.Mutex
+	entries []AccessLogEntry
+}
+
+func (r *accessLogRecorder) Log(entry AccessLogEntry) {
+	r.Lock()
+	r.entries = append(r.entries, entry)
+	r.Unlock()
+}
+
+// waitFor waits for an entry for the given direction and method.
+func (r *accessLogRecorder) waitFor(t *testing.T, direction, method string) AccessLogEntry {
+	var found AccessLogEntry
+	require.True(t, testutils.WaitFor(time.Second, func() bool {
+		r.Lock()
+		defer r.Unlock()
+		for _, e := range r.entries {
+			if e.Direction == direction && e.Method == method {
+				found = e
+				return true
+			}
+		}
+		return false
+	}), "No %v access log entry for %v", direction, method)
+	return found
+}
+
+func TestAccessLog(t *testing.T) {
+	serverLog := &accessLogRecorder{}
+	opts := testutils.NewOpts().AddLogFilter("Couldn't find handler.", 1)
+	opts.AccessLog = serverLog
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		serverLog.Lock()
+		serverLog.entries = nil
+		serverLog.Unlock()
+
+		testutils.RegisterEcho(ts.Server(), nil)
+		testutils.RegisterFunc(ts.Server(), "appError", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+			return &raw.Res{IsErr: true}, nil
+		})
+
+		clientLog := &accessLogRecorder{}
+		clientOpts := testutils.NewOpts()
+		clientOpts.AccessLog = clientLog
+		client := ts.NewClient(clientOpts)
+
+		tests := []struct {
+			method   string
+			wantCode string
+		}{
+			{"echo", AccessLogOK},
+			{"appError", AccessLogApplicationError},
+			{"unknown", ErrCodeBadRequest.MetricsKey()},
+		}
+
+		for _, tt := range tests {
+			ctx, cancel := NewContext(testutils.Timeout(time.Second))
+			raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), tt.method, []byte("arg2"), []byte("arg3"))
+			cancel()
+
+			outbound := clientLog.waitFor(t, AccessLogOutbound, tt.method)
+			assert.Equal(t, client.ServiceName(), outbound.Caller, "%v: unexpected caller", tt.method)
+			assert.Equal(t, ts.ServiceName(), outbound.Service, "%v: unexpected service", tt.method)
+			assert.Equal(t, ts.HostPort(), outbound.RemoteHostPort, "%v: unexpected remote", tt.method)
+			assert.Equal(t, tt.wantCode, outbound.ResponseCode, "%v: unexpected response code", tt.method)
+			assert.Equal(t, 1, outbound.Attempt, "%v: unexpected attempt", tt.method)
+			assert.NotZero(t, outbound.Latency, "%v: missing latency", tt.method)
+			assert.NotZero(t, outbound.BytesSent, "%v: missing bytes sent", tt.method)
+
+			inbound := serverLog.waitFor(t, AccessLogInbound, tt.method)
+			assert.Equal(t, client.ServiceName(), inbound.Caller, "%v: unexpected caller", tt.method)
+			assert.Equal(t, tt.wantCode, inbound.ResponseCode, "%v: unexpected response code", tt.method)
+			assert.Equal(t, outbound.BytesSent, inbound.BytesReceived, "%v: request bytes mismatch", tt.method)
+			assert.Zero(t, inbound.Attempt, "%v: inbound calls have no attempt", tt.method)
+			if tt.wantCode != ErrCodeBadRequest.MetricsKey() {
+				// System errors are sent as error frames, which are not counted.
+				assert.Equal(t, outbound.BytesReceived, inbound.BytesSent, "%v: response bytes mismatch", tt.method)
+			}
+
+			if !ts.HasRelay() {
+				continue
+			}
+			relayed := serverLog.waitFor(t, AccessLogRelay, tt.method)
+			assert.Equal(t, client.ServiceName(), relayed.Caller, "%v: unexpected caller", tt.method)
+			assert.Equal(t, outbound.BytesSent, relayed.BytesReceived, "%v: relayed request bytes mismatch", tt.method)
+			if tt.wantCode == AccessLogOK {
+				assert.Equal(t, AccessLogOK, relayed.ResponseCode, "%v: unexpected relay response code", tt.method)
+				assert.Equal(t, outbound.BytesReceived, relayed.BytesSent, "%v: relayed response bytes mismatch", tt.method)
+			}
+		}
+	})
+}
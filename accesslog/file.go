@@ -0,0 +1,163 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+// Package accesslog contains sinks for the TChannel access log, which is
+// enabled by setting ChannelOptions.AccessLog.
+package accesslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/uber/tchannel-go"
+)
+
+// FileOptions configures a FileSink.
+type FileOptions struct {
+	// Path is the file that entries are appended to.
+	Path string
+
+	// MaxSize is the size in bytes after which the file is rotated. Zero
+	// disables rotation.
+	MaxSize int64
+
+	// MaxBackups is the number of rotated files that are kept, named Path.1
+	// for the most recent up to Path.MaxBackups. If zero, the file is
+	// truncated when it is rotated.
+	MaxBackups int
+
+	// OnError, if set, is called when an entry cannot be written.
+	OnError func(error)
+}
+
+// FileSink is a tchannel.AccessLogSink that writes each entry as a line of
+// JSON to a file, rotating the file once it reaches a maximum size.
+type FileSink struct {
+	sync.Mutex
+
+	opts FileOptions
+	file *os.File
+	size int64
+}
+
+var _ tchannel.AccessLogSink = (*FileSink)(nil)
+
+// NewFileSink returns a FileSink that appends to the file at opts.Path,
+// creating it if needed.
+func NewFileSink(opts FileOptions) (*FileSink, error) {
+	s := &FileSink{opts: opts}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.opts.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.file = f
+	s.size = info.Size()
+	return nil
+}
+
+// Log writes the entry to the file.
+func (s *FileSink) Log(entry tchannel.AccessLogEntry) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		s.failed(err)
+		return
+	}
+	line = append(line, '\n')
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.file == nil {
+		s.failed(fmt.Errorf("access log %v is closed", s.opts.Path))
+		return
+	}
+	if s.opts.MaxSize > 0 && s.size > 0 && s.size+int64(len(line)) > s.opts.MaxSize {
+		if err := s.rotate(); err != nil {
+			s.failed(err)
+			return
+		}
+	}
+
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	if err != nil {
+		s.failed(err)
+	}
+}
+
+// rotate renames the current file to be the most recent backup, removing the
+// oldest backup, and opens a new file. The sink must be locked.
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	s.file = nil
+
+	path := s.opts.Path
+	if s.opts.MaxBackups > 0 {
+		for i := s.opts.MaxBackups - 1; i > 0; i-- {
+			if err := os.Rename(backupPath(path, i), backupPath(path, i+1)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := os.Rename(path, backupPath(path, 1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(path); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+func backupPath(path string, n int) string {
+	return fmt.Sprintf("%v.%v", path, n)
+}
+
+func (s *FileSink) failed(err error) {
+	if s.opts.OnError != nil {
+		s.opts.OnError(err)
+	}
+}
+
+// Close closes the file. Entries logged after Close are dropped.
+func (s *FileSink) Close() error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package accesslog
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/uber/tchannel-go"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readEntries(t *testing.T, path string) []tchannel.AccessLogEntry {
+	f, err := os.Open(path)
+	require.NoError(t, err, "Open failed")
+	defer f.Close()
+
+	var entries []tchannel.AccessLogEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry tchannel.AccessLogEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry), "Invalid JSON line: %s", scanner.Text())
+		entries = append(entries, entry)
+	}
+	require.NoError(t, scanner.Err(), "Read failed")
+	return entries
+}
+
+func withTempDir(t *testing.T, f func(dir string)) {
+	dir, err := ioutil.TempDir("", "accesslog")
+	require.NoError(t, err, "TempDir failed")
+	defer os.RemoveAll(dir)
+	f(dir)
+}
+
+func TestFileSink(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		path := filepath.Join(dir, "access.log")
+		sink, err := NewFileSink(FileOptions{Path: path})
+		require.NoError(t, err, "NewFileSink failed")
+
+		entry := tchannel.AccessLogEntry{
+			Time:         time.Unix(1500000000, 0).UTC(),
+			Direction:    tchannel.AccessLogInbound,
+			Caller:       "caller",
+			Service:      "svc",
+			Method:       "echo",
+			Latency:      time.Millisecond,
+			BytesSent:    10,
+			ResponseCode: tchannel.AccessLogOK,
+		}
+		sink.Log(entry)
+		sink.Log(entry)
+		require.NoError(t, sink.Close(), "Close failed")
+
+		assert.Equal(t, []tchannel.AccessLogEntry{entry, entry}, readEntries(t, path), "Unexpected entries")
+
+		var logErr error
+		closed, err := NewFileSink(FileOptions{Path: path, OnError: func(err error) { logErr = err }})
+		require.NoError(t, err, "NewFileSink failed")
+		require.NoError(t, closed.Close(), "Close failed")
+		closed.Log(entry)
+		assert.Error(t, logErr, "Log after Close should fail")
+		assert.Len(t, readEntries(t, path), 2, "Entries should be appended to, and not logged after Close")
+	})
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		path := filepath.Join(dir, "access.log")
+		entry := tchannel.AccessLogEntry{Service: "svc", Method: "echo"}
+		line, err := json.Marshal(entry)
+		require.NoError(t, err, "Marshal failed")
+
+		// Each file holds two entries.
+		sink, err := NewFileSink(FileOptions{
+			Path:       path,
+			MaxSize:    int64(2 * (len(line) + 1)),
+			MaxBackups: 2,
+			OnError:    func(err error) { t.Errorf("Unexpected error: %v", err) },
+		})
+		require.NoError(t, err, "NewFileSink failed")
+		defer sink.Close()
+
+		for i := 0; i < 7; i++ {
+			sink.Log(entry)
+		}
+
+		assert.Len(t, readEntries(t, path), 1, "Unexpected entries in current file")
+		assert.Len(t, readEntries(t, path+".1"), 2, "Unexpected entries in first backup")
+		assert.Len(t, readEntries(t, path+".2"), 2, "Unexpected entries in second backup")
+		_, err = os.Stat(path + ".3")
+		assert.True(t, os.IsNotExist(err), "Only MaxBackups backups should be kept")
+	})
+}
+
+func TestNewFileSinkError(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		_, err := NewFileSink(FileOptions{Path: filepath.Join(dir, "missing", "access.log")})
+		assert.Error(t, err, "NewFileSink should fail if the directory does not exist")
+	})
+}
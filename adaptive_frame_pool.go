@@ -0,0 +1,247 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	_defaultSmallFramePayloadSize = 1024
+	_minSmallFramePayloadSize     = 64
+	_defaultFramePoolShrinkPeriod = 30 * time.Second
+)
+
+// A SizedFramePool is a FramePool that can return frames sized for their
+// payload, so that small frames, such as control frames, don't hold a full
+// size frame buffer. Connections read the header of each frame before
+// getting a frame from a SizedFramePool.
+type SizedFramePool interface {
+	FramePool
+
+	// GetSized returns a frame with a payload capacity of at least
+	// payloadSize.
+	GetSized(payloadSize int) *Frame
+}
+
+// AdaptiveFramePoolOptions configures an AdaptiveFramePool.
+type AdaptiveFramePoolOptions struct {
+	// SmallPayloadSize is the payload capacity of small frames. Frames with
+	// larger payloads use full size frames. If this is 0, the default of
+	// 1KB is used. Values below 64 bytes or above MaxFramePayloadSize are
+	// replaced with the default.
+	SmallPayloadSize int
+
+	// ShrinkPeriod is how often pooled frames that were not used since the
+	// last period are released, so that an idle pool does not keep the
+	// frames allocated for a burst of traffic. If this is 0, the default of
+	// 30 seconds is used.
+	ShrinkPeriod time.Duration
+
+	// StatsReporter reports the pool's hits, misses and pooled frames for
+	// each size class, if set.
+	StatsReporter StatsReporter
+}
+
+func (o AdaptiveFramePoolOptions) withDefaults() AdaptiveFramePoolOptions {
+	if o.SmallPayloadSize < _minSmallFramePayloadSize || o.SmallPayloadSize > MaxFramePayloadSize {
+		o.SmallPayloadSize = _defaultSmallFramePayloadSize
+	}
+	if o.ShrinkPeriod <= 0 {
+		o.ShrinkPeriod = _defaultFramePoolShrinkPeriod
+	}
+	return o
+}
+
+// FramePoolClassStats are the stats for a size class of an AdaptiveFramePool.
+type FramePoolClassStats struct {
+	// Hits is the number of frames returned from the pool.
+	Hits uint64 `json:"hits"`
+
+	// Misses is the number of frames allocated as the pool was empty.
+	Misses uint64 `json:"misses"`
+
+	// Shrunk is the number of pooled frames released as they were unused.
+	Shrunk uint64 `json:"shrunk"`
+
+	// Pooled is the number of frames currently in the pool.
+	Pooled int `json:"pooled"`
+}
+
+// FramePoolStats are the stats for an AdaptiveFramePool.
+type FramePoolStats struct {
+	Small FramePoolClassStats `json:"small"`
+	Full  FramePoolClassStats `json:"full"`
+}
+
+// AdaptiveFramePool is a SizedFramePool with a pool of small frames and a
+// pool of full size frames, which shrink when frames are unused.
+type AdaptiveFramePool struct {
+	small *frameClassPool
+	full  *frameClassPool
+}
+
+// NewAdaptiveFramePool returns an AdaptiveFramePool.
+func NewAdaptiveFramePool(opts AdaptiveFramePoolOptions) *AdaptiveFramePool {
+	opts = opts.withDefaults()
+	return &AdaptiveFramePool{
+		small: newFrameClassPool("small", opts.SmallPayloadSize, opts),
+		full:  newFrameClassPool("full", MaxFramePayloadSize, opts),
+	}
+}
+
+// Get returns a full size frame.
+func (p *AdaptiveFramePool) Get() *Frame {
+	return p.full.get()
+}
+
+// GetSized returns a small frame if the payload fits in one, and a full size
+// frame otherwise.
+func (p *AdaptiveFramePool) GetSized(payloadSize int) *Frame {
+	if payloadSize <= p.small.payloadCapacity {
+		return p.small.get()
+	}
+	return p.full.get()
+}
+
+// Release returns a frame to the pool for its size class. Frames that don't
+// belong to either size class are discarded.
+func (p *AdaptiveFramePool) Release(f *Frame) {
+	if f.large {
+		return
+	}
+	switch len(f.Payload) {
+	case p.small.payloadCapacity:
+		p.small.release(f)
+	case p.full.payloadCapacity:
+		p.full.release(f)
+	}
+}
+
+// Stats returns the pool's stats.
+func (p *AdaptiveFramePool) Stats() FramePoolStats {
+	return FramePoolStats{
+		Small: p.small.stats(),
+		Full:  p.full.stats(),
+	}
+}
+
+// frameClassPool is the pool for a single size class of an AdaptiveFramePool.
+type frameClassPool struct {
+	sync.Mutex
+
+	payloadCapacity int
+	shrinkPeriod    time.Duration
+	statsReporter   StatsReporter
+	statsTags       map[string]string
+
+	frames []*Frame
+
+	// minFrames is the fewest frames that were pooled since the last shrink,
+	// which is the number of frames that were not used.
+	minFrames   int
+	shrinkTimer *time.Timer
+	counters    FramePoolClassStats
+}
+
+func newFrameClassPool(class string, payloadCapacity int, opts AdaptiveFramePoolOptions) *frameClassPool {
+	return &frameClassPool{
+		payloadCapacity: payloadCapacity,
+		shrinkPeriod:    opts.ShrinkPeriod,
+		statsReporter:   opts.StatsReporter,
+		statsTags:       map[string]string{"size-class": class},
+	}
+}
+
+func (p *frameClassPool) get() *Frame {
+	p.Lock()
+	var f *Frame
+	if n := len(p.frames); n > 0 {
+		f = p.frames[n-1]
+		p.frames[n-1] = nil
+		p.frames = p.frames[:n-1]
+		if len(p.frames) < p.minFrames {
+			p.minFrames = len(p.frames)
+		}
+		p.counters.Hits++
+	} else {
+		p.counters.Misses++
+	}
+	p.Unlock()
+
+	if f != nil {
+		p.incCounter("frame-pool.hits")
+		return f
+	}
+	p.incCounter("frame-pool.misses")
+	return NewFrame(p.payloadCapacity)
+}
+
+func (p *frameClassPool) release(f *Frame) {
+	p.Lock()
+	p.frames = append(p.frames, f)
+	if p.shrinkTimer == nil {
+		p.minFrames = len(p.frames)
+		p.shrinkTimer = time.AfterFunc(p.shrinkPeriod, p.shrink)
+	}
+	p.Unlock()
+}
+
+// shrink releases the frames that were not used since the last shrink. It
+// runs periodically while there are frames in the pool.
+func (p *frameClassPool) shrink() {
+	p.Lock()
+	unused := p.minFrames
+	for i := 0; i < unused; i++ {
+		p.frames[i] = nil
+	}
+	p.frames = append(p.frames[:0], p.frames[unused:]...)
+	p.counters.Shrunk += uint64(unused)
+	p.minFrames = len(p.frames)
+	if len(p.frames) > 0 {
+		p.shrinkTimer.Reset(p.shrinkPeriod)
+	} else {
+		p.shrinkTimer = nil
+	}
+	pooled := len(p.frames)
+	p.Unlock()
+
+	if p.statsReporter != nil {
+		p.statsReporter.IncCounter("frame-pool.shrunk", p.statsTags, int64(unused))
+		p.statsReporter.UpdateGauge("frame-pool.pooled", p.statsTags, int64(pooled))
+	}
+}
+
+func (p *frameClassPool) incCounter(name string) {
+	if p.statsReporter != nil {
+		p.statsReporter.IncCounter(name, p.statsTags, 1)
+	}
+}
+
+func (p *frameClassPool) stats() FramePoolClassStats {
+	p.Lock()
+	defer p.Unlock()
+
+	stats := p.counters
+	stats.Pooled = len(p.frames)
+	return stats
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdaptiveFramePoolSizeClasses(t *testing.T) {
+	stats := newRecordingStatsReporter()
+	pool := NewAdaptiveFramePool(AdaptiveFramePoolOptions{
+		SmallPayloadSize: 128,
+		ShrinkPeriod:     time.Hour,
+		StatsReporter:    stats,
+	})
+
+	small := pool.GetSized(100)
+	assert.Len(t, small.Payload, 128, "small payloads should use small frames")
+	full := pool.GetSized(129)
+	assert.Len(t, full.Payload, MaxFramePayloadSize, "large payloads should use full frames")
+	assert.Len(t, pool.Get().Payload, MaxFramePayloadSize, "Get should return full frames")
+
+	pool.Release(small)
+	pool.Release(full)
+	pool.Release(NewFrame(500))
+
+	assert.True(t, small == pool.GetSized(0), "expected the released small frame to be reused")
+	assert.True(t, full == pool.Get(), "expected the released full frame to be reused")
+
+	assert.Equal(t, FramePoolStats{
+		Small: FramePoolClassStats{Hits: 1, Misses: 1},
+		Full:  FramePoolClassStats{Hits: 1, Misses: 2},
+	}, pool.Stats(), "unexpected pool stats")
+
+	stats.Expected.IncCounter("frame-pool.hits", map[string]string{"size-class": "small"}, 1)
+	stats.Expected.IncCounter("frame-pool.misses", map[string]string{"size-class": "small"}, 1)
+	stats.Expected.IncCounter("frame-pool.hits", map[string]string{"size-class": "full"}, 1)
+	stats.Expected.IncCounter("frame-pool.misses", map[string]string{"size-class": "full"}, 2)
+	stats.Validate(t)
+}
+
+func TestAdaptiveFramePoolShrink(t *testing.T) {
+	pool := NewAdaptiveFramePool(AdaptiveFramePoolOptions{ShrinkPeriod: 10 * time.Millisecond})
+
+	frames := []*Frame{pool.GetSized(0), pool.GetSized(0), pool.GetSized(0)}
+	for _, f := range frames {
+		pool.Release(f)
+	}
+	require.Equal(t, 3, pool.Stats().Small.Pooled, "expected released frames to be pooled")
+
+	// Frames in use while the pool shrinks are kept, and only unused frames
+	// are released.
+	inUse := pool.GetSized(0)
+	require.True(t, testutils.WaitFor(time.Second, func() bool {
+		return pool.Stats().Small.Shrunk == 2
+	}), "expected unused frames to be released, got %+v", pool.Stats())
+
+	pool.Release(inUse)
+	require.True(t, testutils.WaitFor(time.Second, func() bool {
+		return pool.Stats().Small.Pooled == 0
+	}), "expected the pool to shrink when idle, got %+v", pool.Stats())
+	assert.Equal(t, uint64(3), pool.Stats().Small.Shrunk, "unexpected number of shrunk frames")
+}
+
+func TestAdaptiveFramePoolCalls(t *testing.T) {
+	pool := NewAdaptiveFramePool(AdaptiveFramePoolOptions{})
+	opts := testutils.NewOpts().SetFramePool(pool)
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		testutils.RegisterEcho(ts.Server(), nil)
+		client := ts.NewClient(testutils.NewOpts().SetFramePool(pool))
+
+		for _, size := range []int{0, 100, 4096, 2 * MaxFramePayloadSize} {
+			arg3 := testutils.RandBytes(size)
+			ctx, cancel := NewContext(time.Second)
+			_, res, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "echo", []byte("arg2"), arg3)
+			cancel()
+			require.NoError(t, err, "call with %v byte arg3 failed", size)
+			assert.Equal(t, arg3, res, "unexpected arg3 with %v byte arg3", size)
+		}
+
+		ctx, cancel := NewContext(time.Second)
+		defer cancel()
+		require.NoError(t, client.Ping(ctx, ts.HostPort()), "ping failed")
+
+		stats := pool.Stats()
+		assert.NotZero(t, stats.Small.Hits+stats.Small.Misses, "expected small frames to be used")
+		assert.NotZero(t, stats.Full.Hits+stats.Full.Misses, "expected full frames to be used")
+	})
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+// ArgSizeLimits are the maximum sizes in bytes of arg2 and arg3 of a call, as
+// sent on the wire. A zero limit means the argument's size is not limited.
+type ArgSizeLimits struct {
+	Arg2 int
+	Arg3 int
+}
+
+// check returns an error if size is over the limit for the argument at
+// index arg, where arg1 is at index 0.
+func (l ArgSizeLimits) check(arg, size int) error {
+	switch {
+	case arg == 1 && l.Arg2 > 0 && size > l.Arg2:
+		return ErrArg2TooLarge
+	case arg == 2 && l.Arg3 > 0 && size > l.Arg3:
+		return ErrArg3TooLarge
+	}
+	return nil
+}
+
+// override returns the limits with any limits set in o replacing those in l.
+func (l ArgSizeLimits) override(o ArgSizeLimits) ArgSizeLimits {
+	if o.Arg2 > 0 {
+		l.Arg2 = o.Arg2
+	}
+	if o.Arg3 > 0 {
+		l.Arg3 = o.Arg3
+	}
+	return l
+}
+
+// WithMethodArgSizeLimits is a SubChannelOption that limits the size of the
+// arguments of inbound calls to the given method, overriding the channel's
+// InboundArgSizeLimits. Calls over the limit are rejected with a BadRequest
+// error.
+func WithMethodArgSizeLimits(method string, limits ArgSizeLimits) SubChannelOption {
+	return func(s *SubChannel) {
+		s.Lock()
+		defer s.Unlock()
+		if s.methodArgLimits == nil {
+			s.methodArgLimits = make(map[string]ArgSizeLimits)
+		}
+		s.methodArgLimits[method] = limits
+	}
+}
+
+// inboundArgSizeLimits returns the argument size limits for an inbound call.
+func (c *Connection) inboundArgSizeLimits(call *InboundCall) ArgSizeLimits {
+	limits := c.inboundArgLimits
+	if subCh, ok := c.subChannels.get(call.ServiceName()); ok {
+		subCh.RLock()
+		limits = limits.override(subCh.methodArgLimits[call.MethodString()])
+		subCh.RUnlock()
+	}
+	return limits
+}
+
+// rejectArgs rejects an inbound call with an argument over its size limit.
+// The error is sent as soon as the limit is exceeded, so the caller is not
+// left waiting on a handler that can no longer read the call.
+func (call *InboundCall) rejectArgs(err error) {
+	call.statsReporter.IncCounter("inbound.calls.rejected-args", call.commonStatsTags, 1)
+	if call.log.Enabled(LogLevelDebug) {
+		call.log.Debugf("Rejecting call to %s::%s: %v", call.ServiceName(), call.MethodString(), err)
+	}
+	call.Response().SendSystemError(err)
+	call.failed(err)
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func callWithArgs(ch *Channel, hostPort, service, method string, arg2, arg3 []byte) error {
+	ctx, cancel := NewContext(testutils.Timeout(time.Second))
+	defer cancel()
+
+	_, _, _, err := raw.Call(ctx, ch, hostPort, service, method, arg2, arg3)
+	return err
+}
+
+func TestInboundArgSizeLimits(t *testing.T) {
+	opts := testutils.NewOpts()
+	opts.InboundArgSizeLimits = ArgSizeLimits{Arg2: 100, Arg3: 1000}
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		sc := ts.Server().GetSubChannel("limits", WithMethodArgSizeLimits("large", ArgSizeLimits{Arg3: 1 << 20}))
+		testutils.RegisterEcho(sc, nil)
+		testutils.RegisterFunc(sc, "large", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+			return &raw.Res{}, nil
+		})
+		if ts.HasRelay() {
+			ts.RelayHost().Add("limits", ts.Server().PeerInfo().HostPort)
+		}
+
+		client := ts.NewClient(nil)
+		small := make([]byte, 100)
+		tests := []struct {
+			msg     string
+			method  string
+			arg2    []byte
+			arg3    []byte
+			wantErr error
+		}{
+			{"within limits", "echo", small, make([]byte, 1000), nil},
+			{"arg2 over limit", "echo", make([]byte, 101), nil, ErrArg2TooLarge},
+			{"arg3 over limit", "echo", small, make([]byte, 1001), ErrArg3TooLarge},
+			{"arg3 over limit across fragments", "echo", nil, make([]byte, 200000), ErrArg3TooLarge},
+			{"method limit overrides channel limit", "large", nil, make([]byte, 200000), nil},
+			{"method inherits channel arg2 limit", "large", make([]byte, 101), nil, ErrArg2TooLarge},
+		}
+
+		for _, tt := range tests {
+			err := callWithArgs(client, ts.HostPort(), "limits", tt.method, tt.arg2, tt.arg3)
+			if tt.wantErr == nil {
+				assert.NoError(t, err, "%v: call failed", tt.msg)
+			} else {
+				assert.Equal(t, tt.wantErr, err, "%v: unexpected error", tt.msg)
+			}
+		}
+
+		// Rejected calls should not affect the connection.
+		assert.NoError(t, callWithArgs(client, ts.HostPort(), "limits", "echo", small, small),
+			"Call after rejected calls failed")
+	})
+}
+
+func TestOutboundArgSizeLimits(t *testing.T) {
+	testutils.WithTestServer(t, nil, func(ts *testutils.TestServer) {
+		called := false
+		testutils.RegisterFunc(ts.Server(), "echo", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+			called = true
+			return &raw.Res{Arg2: args.Arg2, Arg3: args.Arg3}, nil
+		})
+
+		clientOpts := testutils.NewOpts()
+		clientOpts.OutboundArgSizeLimits = ArgSizeLimits{Arg2: 10, Arg3: 10}
+		client := ts.NewClient(clientOpts)
+
+		err := callWithArgs(client, ts.HostPort(), ts.ServiceName(), "echo", bytes.Repeat([]byte("a"), 11), nil)
+		assert.Equal(t, ErrArg2TooLarge, err, "Expected arg2 limit error")
+		err = callWithArgs(client, ts.HostPort(), ts.ServiceName(), "echo", nil, bytes.Repeat([]byte("a"), 11))
+		assert.Equal(t, ErrArg3TooLarge, err, "Expected arg3 limit error")
+		assert.False(t, called, "Calls over the limit should not reach the server")
+
+		assert.NoError(t, callWithArgs(client, ts.HostPort(), ts.ServiceName(), "echo", []byte("arg2"), []byte("arg3")),
+			"Calls within the limits should succeed")
+	})
+}
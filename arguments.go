@@ -91,6 +91,25 @@ func (r ArgReadHelper) Read(bs *[]byte) error {
 	})
 }
 
+// ReadBorrowed reads the argument without copying it out of the frames it was
+// received in, if the reader supports it. The returned ArgBuffer must be
+// released once the argument is no longer used, which returns the frames to
+// the frame pool. Readers that do not support borrowing are read into a
+// single buffer.
+func (r ArgReadHelper) ReadBorrowed() (*ArgBuffer, error) {
+	var buf *ArgBuffer
+	err := r.read(func() error {
+		var err error
+		buf, err = borrowArg(r.reader)
+		return err
+	})
+	if err != nil && buf != nil {
+		buf.Release()
+		buf = nil
+	}
+	return buf, err
+}
+
 // ReadJSON deserializes JSON from the underlying reader into data.
 func (r ArgReadHelper) ReadJSON(data interface{}) error {
 	return r.read(func() error {
@@ -109,6 +128,87 @@ func (r ArgReadHelper) ReadJSON(data interface{}) error {
 	})
 }
 
+// argBorrower is implemented by readers that can read an argument without copying it.
+type argBorrower interface {
+	borrowArg() (*ArgBuffer, error)
+}
+
+func borrowArg(reader io.Reader) (*ArgBuffer, error) {
+	if b, ok := reader.(argBorrower); ok {
+		return b.borrowArg()
+	}
+
+	bs, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	return &ArgBuffer{chunks: [][]byte{bs}, size: len(bs)}, nil
+}
+
+// ArgBuffer is an argument that references the frames it was received in,
+// rather than a copy of the argument. Arguments that span multiple frames are
+// made up of multiple chunks. The contents are only valid until Release is called.
+type ArgBuffer struct {
+	chunks    [][]byte
+	size      int
+	fragments []*readableFragment
+	joined    []byte
+}
+
+// Len returns the size of the argument in bytes.
+func (b *ArgBuffer) Len() int {
+	return b.size
+}
+
+// Chunks returns the chunks that make up the argument, which reference the
+// received frames. The chunks must not be modified or used after Release.
+func (b *ArgBuffer) Chunks() [][]byte {
+	return b.chunks
+}
+
+// Bytes returns the contents of the argument. If the argument was received in
+// a single chunk, the returned slice references the received frame and must
+// not be used after Release. Otherwise, the chunks are copied into a new slice.
+func (b *ArgBuffer) Bytes() []byte {
+	switch len(b.chunks) {
+	case 0:
+		return nil
+	case 1:
+		return b.chunks[0]
+	}
+
+	if b.joined == nil {
+		b.joined = make([]byte, 0, b.size)
+		for _, c := range b.chunks {
+			b.joined = append(b.joined, c...)
+		}
+	}
+	return b.joined
+}
+
+// WriteTo writes the contents of the argument to w without copying it.
+func (b *ArgBuffer) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for _, c := range b.chunks {
+		n, err := w.Write(c)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// Release releases the frames referenced by the argument. The contents of the
+// argument must not be used after Release. Release is idempotent.
+func (b *ArgBuffer) Release() {
+	fragments := b.fragments
+	b.chunks, b.fragments, b.joined, b.size = nil, nil, nil, 0
+	for _, f := range fragments {
+		f.release()
+	}
+}
+
 // ArgWriteHelper providers a simpler interface to writing arguments.
 type ArgWriteHelper struct {
 	writer io.WriteCloser
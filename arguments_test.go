@@ -83,6 +83,23 @@ func TestReadNotEmpty(t *testing.T) {
 	require.Error(t, reader.ReadJSON(&data), "Read should fail due to extra bytes")
 }
 
+func TestReadBorrowedFallback(t *testing.T) {
+	reader := newReader([]byte("borrowed"))
+	buf, err := NewArgReader(reader, nil).ReadBorrowed()
+	require.NoError(t, err, "ReadBorrowed failed")
+	assert.True(t, reader.closed, "Reader should be closed")
+	assert.Equal(t, "borrowed", string(buf.Bytes()), "Unexpected contents")
+	assert.Equal(t, 8, buf.Len(), "Unexpected length")
+
+	var out bytes.Buffer
+	_, err = buf.WriteTo(&out)
+	require.NoError(t, err, "WriteTo failed")
+	assert.Equal(t, "borrowed", out.String(), "Unexpected contents written")
+
+	buf.Release()
+	assert.Nil(t, buf.Bytes(), "Released buffer should be empty")
+}
+
 func BenchmarkArgReaderWriter(b *testing.B) {
 	obj := testObject{Name: "Foo", Value: 20756}
 	outObj := testObject{}
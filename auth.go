@@ -0,0 +1,148 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"context"
+	"crypto/x509"
+)
+
+// ErrAccessDenied is returned to callers that are not authorized to make a
+// call, see Authorizer.
+var ErrAccessDenied = NewSystemError(ErrCodeBadRequest, "access denied")
+
+// AuthInfo describes the caller of an inbound call that is being authorized.
+type AuthInfo struct {
+	// CallerName is the caller's service name, from the CallerName transport header.
+	CallerName string
+
+	// ServiceName and Method are the service and method being called.
+	ServiceName string
+	Method      string
+
+	// RemotePeer is the caller's peer information.
+	RemotePeer PeerInfo
+
+	// PeerCertificates are the certificates presented by the caller if the
+	// connection uses TLS, and nil otherwise.
+	PeerCertificates []*x509.Certificate
+
+	// Headers are the transport headers sent with the call.
+	Headers map[string]string
+}
+
+// Authorizer authorizes inbound calls before they are dispatched to a
+// handler. Calls relayed by the channel are not authorized.
+type Authorizer interface {
+	// Authorize returns nil if the call is allowed. Otherwise, the call is
+	// rejected with the returned error if it is a SystemError, and with
+	// ErrAccessDenied for any other error.
+	Authorize(ctx context.Context, info *AuthInfo) error
+}
+
+// AuthorizerFunc is an adapter to allow the use of ordinary functions as
+// an Authorizer.
+type AuthorizerFunc func(ctx context.Context, info *AuthInfo) error
+
+// Authorize calls f(ctx, info).
+func (f AuthorizerFunc) Authorize(ctx context.Context, info *AuthInfo) error {
+	return f(ctx, info)
+}
+
+// CallerAllowlist is an Authorizer that only allows calls from a list of
+// callers, identified by their CallerName.
+type CallerAllowlist struct {
+	callers map[string]struct{}
+	methods map[string]map[string]struct{}
+}
+
+// NewCallerAllowlist returns an Authorizer that allows the given callers to
+// call any method.
+func NewCallerAllowlist(callers ...string) *CallerAllowlist {
+	return &CallerAllowlist{
+		callers: toStringSet(callers),
+		methods: make(map[string]map[string]struct{}),
+	}
+}
+
+// AllowMethod allows the given callers to call the given method, in addition
+// to the callers allowed to call any method. It must not be called once the
+// allowlist is used by a channel.
+func (l *CallerAllowlist) AllowMethod(method string, callers ...string) *CallerAllowlist {
+	allowed, ok := l.methods[method]
+	if !ok {
+		allowed = make(map[string]struct{}, len(callers))
+		l.methods[method] = allowed
+	}
+	for _, caller := range callers {
+		allowed[caller] = struct{}{}
+	}
+	return l
+}
+
+// Authorize returns ErrAccessDenied unless the caller is allowed to call the method.
+func (l *CallerAllowlist) Authorize(_ context.Context, info *AuthInfo) error {
+	if _, ok := l.callers[info.CallerName]; ok {
+		return nil
+	}
+	if _, ok := l.methods[info.Method][info.CallerName]; ok {
+		return nil
+	}
+	return ErrAccessDenied
+}
+
+// authorize returns whether the call is allowed by the channel's Authorizer,
+// and rejects the call otherwise.
+func (c *Connection) authorize(ctx context.Context, call *InboundCall) bool {
+	if c.authorizer == nil {
+		return true
+	}
+
+	info := &AuthInfo{
+		CallerName:  call.CallerName(),
+		ServiceName: call.ServiceName(),
+		Method:      call.MethodString(),
+		RemotePeer:  call.RemotePeer(),
+		Headers:     make(map[string]string, len(call.headers)),
+	}
+	if state, ok := c.TLSConnectionState(); ok {
+		info.PeerCertificates = state.PeerCertificates
+	}
+	for k, v := range call.headers {
+		info.Headers[string(k)] = v
+	}
+
+	err := c.authorizer.Authorize(ctx, info)
+	if err == nil {
+		return true
+	}
+
+	call.statsReporter.IncCounter("inbound.calls.unauthorized", call.commonStatsTags, 1)
+	if call.log.Enabled(LogLevelDebug) {
+		call.log.Debugf("Rejecting unauthorized call to %s::%s from %s: %v",
+			call.ServiceName(), call.MethodString(), call.CallerName(), err)
+	}
+	if _, ok := err.(SystemError); !ok {
+		err = ErrAccessDenied
+	}
+	call.Response().SendSystemError(err)
+	return false
+}
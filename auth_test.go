@@ -0,0 +1,154 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallerAllowlist(t *testing.T) {
+	allowlist := NewCallerAllowlist("allowed").AllowMethod("public", "other")
+	opts := testutils.NewOpts()
+	opts.Authorizer = allowlist
+
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		for _, method := range []string{"echo", "public"} {
+			testutils.RegisterFunc(ts.Server(), method, func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+				return &raw.Res{Arg3: args.Arg3}, nil
+			})
+		}
+
+		tests := []struct {
+			caller  string
+			method  string
+			allowed bool
+		}{
+			{caller: "allowed", method: "echo", allowed: true},
+			{caller: "allowed", method: "public", allowed: true},
+			{caller: "other", method: "echo", allowed: false},
+			{caller: "other", method: "public", allowed: true},
+			{caller: "unknown", method: "public", allowed: false},
+		}
+
+		for _, tt := range tests {
+			client := ts.NewClient(testutils.NewOpts().SetServiceName(tt.caller))
+			ctx, cancel := NewContext(testutils.Timeout(time.Second))
+			_, arg3, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), tt.method, nil, []byte("hello"))
+			cancel()
+
+			if tt.allowed {
+				if assert.NoError(t, err, "%v calling %v should be allowed", tt.caller, tt.method) {
+					assert.Equal(t, "hello", string(arg3), "Unexpected response")
+				}
+				continue
+			}
+			assert.Equal(t, ErrCodeBadRequest, GetSystemErrorCode(err), "%v calling %v should be rejected", tt.caller, tt.method)
+			assert.Equal(t, ErrAccessDenied, err, "Unexpected error")
+		}
+	})
+}
+
+func TestAuthorizerInfo(t *testing.T) {
+	denied := NewSystemError(ErrCodeDeclined, "not yet")
+	infos := make(chan *AuthInfo, 2)
+	opts := testutils.NewOpts()
+	opts.Authorizer = AuthorizerFunc(func(ctx context.Context, info *AuthInfo) error {
+		infos <- info
+		switch info.Method {
+		case "declined":
+			return denied
+		case "denied":
+			return errors.New("unknown caller")
+		}
+		return nil
+	})
+
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		testutils.RegisterEcho(ts.Server(), nil)
+		client := ts.NewClient(nil)
+
+		ctx, cancel := NewContextBuilder(testutils.Timeout(time.Second)).
+			SetShardKey("shard").
+			Build()
+		defer cancel()
+
+		_, _, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "echo", nil, nil)
+		require.NoError(t, err, "Authorized call failed")
+		info := <-infos
+		assert.Equal(t, client.ServiceName(), info.CallerName, "Unexpected caller")
+		assert.Equal(t, ts.ServiceName(), info.ServiceName, "Unexpected service")
+		assert.Equal(t, "echo", info.Method, "Unexpected method")
+		assert.Equal(t, "shard", info.Headers[string(ShardKey)], "Missing transport header")
+		assert.Nil(t, info.PeerCertificates, "Unexpected certificates without TLS")
+
+		_, _, _, err = raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "declined", nil, nil)
+		assert.Equal(t, denied, err, "System errors should be returned to the caller")
+		<-infos
+
+		_, _, _, err = raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "denied", nil, nil)
+		assert.Equal(t, ErrAccessDenied, err, "Other errors should deny access")
+		<-infos
+	})
+}
+
+func TestAuthorizerPeerCertificates(t *testing.T) {
+	serverCert, serverPool := newTestCert(t, "server")
+	clientCert, clientPool := newTestCert(t, "client")
+
+	sopts := testutils.NewOpts()
+	sopts.TLSConfig = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientPool,
+	}
+	sopts.Authorizer = AuthorizerFunc(func(ctx context.Context, info *AuthInfo) error {
+		if len(info.PeerCertificates) == 0 || info.PeerCertificates[0].Subject.CommonName != "client" {
+			return ErrAccessDenied
+		}
+		return nil
+	})
+	server := testutils.NewServer(t, sopts)
+	defer server.Close()
+	testutils.RegisterEcho(server, nil)
+
+	copts := testutils.NewOpts()
+	copts.TLSConfig = &tls.Config{
+		Certificates: []tls.Certificate{clientCert},
+		RootCAs:      serverPool,
+		ServerName:   "127.0.0.1",
+	}
+	client := testutils.NewClient(t, copts)
+	defer client.Close()
+
+	testutils.AssertEcho(t, client, server.PeerInfo().HostPort, server.ServiceName())
+}
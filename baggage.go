@@ -0,0 +1,202 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// baggageHeaderPrefix is the prefix of the transport headers that carry
+// baggage items, followed by the item's key.
+const baggageHeaderPrefix = "$baggage$"
+
+const (
+	defaultMaxBaggageItems = 32
+	defaultMaxBaggageSize  = 4 * 1024
+
+	// maxBaggageItems keeps the baggage within the 255 transport headers a
+	// call can send, leaving room for the other headers.
+	maxBaggageItems = 200
+
+	// maxTransportHeaderSize is the maximum length of a transport header's
+	// key or value, which are sent with a single byte length.
+	maxTransportHeaderSize = 255
+)
+
+// BaggageOptions configures the baggage sent with outbound calls.
+//
+// Baggage items are set using ContextBuilder.SetBaggage, and are sent in
+// transport headers so relays forward them as-is. The baggage of an inbound
+// call is available from the handler's context using CurrentBaggage, and is
+// sent with any calls made using that context.
+type BaggageOptions struct {
+	// MaxItems is the maximum number of baggage items sent with a call.
+	// Defaults to 32, and is capped at 200.
+	MaxItems int
+
+	// MaxSize is the maximum total size in bytes of the keys and values
+	// of the baggage sent with a call. Defaults to 4KB.
+	MaxSize int
+
+	// Redact, if set, is called for every baggage item recorded by the
+	// channel, such as in the introspected slow calls, and returns the
+	// value to record in place of the item's value.
+	Redact func(key, value string) string
+}
+
+func (o BaggageOptions) withDefaults() BaggageOptions {
+	if o.MaxItems <= 0 {
+		o.MaxItems = defaultMaxBaggageItems
+	}
+	if o.MaxItems > maxBaggageItems {
+		o.MaxItems = maxBaggageItems
+	}
+	if o.MaxSize <= 0 {
+		o.MaxSize = defaultMaxBaggageSize
+	}
+	return o
+}
+
+// setHeaders adds the baggage to the transport headers of a call, in key
+// order, and returns the number of items that were dropped as they were over
+// the limits.
+func (o BaggageOptions) setHeaders(headers transportHeaders, baggage map[string]string) (dropped int) {
+	if len(baggage) == 0 {
+		return 0
+	}
+
+	keys := make([]string, 0, len(baggage))
+	for k := range baggage {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var items, size int
+	for _, k := range keys {
+		v := baggage[k]
+		itemSize := len(k) + len(v)
+		if items == o.MaxItems || size+itemSize > o.MaxSize ||
+			len(baggageHeaderPrefix)+len(k) > maxTransportHeaderSize || len(v) > maxTransportHeaderSize {
+			dropped++
+			continue
+		}
+		headers[TransportHeaderName(baggageHeaderPrefix+k)] = v
+		items++
+		size += itemSize
+	}
+	return dropped
+}
+
+// redact returns the headers with the values of any baggage items replaced
+// using the Redact option. The headers are only copied if needed.
+func (o BaggageOptions) redact(headers transportHeaders) transportHeaders {
+	if o.Redact == nil {
+		return headers
+	}
+
+	var redacted transportHeaders
+	for k, v := range headers {
+		key, ok := baggageKey(k)
+		if !ok {
+			continue
+		}
+		if redacted == nil {
+			redacted = make(transportHeaders, len(headers))
+			for hk, hv := range headers {
+				redacted[hk] = hv
+			}
+		}
+		redacted[k] = o.Redact(key, v)
+	}
+	if redacted == nil {
+		return headers
+	}
+	return redacted
+}
+
+// baggageKey returns the key of the baggage item carried in the given
+// transport header, if any.
+func baggageKey(header TransportHeaderName) (string, bool) {
+	if !strings.HasPrefix(string(header), baggageHeaderPrefix) {
+		return "", false
+	}
+	return string(header[len(baggageHeaderPrefix):]), true
+}
+
+// baggageFromHeaders returns the baggage items carried in the given transport
+// headers, or nil if there are none.
+func baggageFromHeaders(headers transportHeaders) map[string]string {
+	var baggage map[string]string
+	for k, v := range headers {
+		key, ok := baggageKey(k)
+		if !ok || key == "" {
+			continue
+		}
+		if baggage == nil {
+			baggage = make(map[string]string)
+		}
+		baggage[key] = v
+	}
+	return baggage
+}
+
+// mergeBaggage returns the parent's baggage with the given items added, where
+// items with an empty value remove the item from the baggage. The returned
+// map must not be modified.
+func mergeBaggage(parent, items map[string]string) map[string]string {
+	if len(items) == 0 {
+		return parent
+	}
+
+	merged := make(map[string]string, len(parent)+len(items))
+	for k, v := range parent {
+		merged[k] = v
+	}
+	for k, v := range items {
+		if v == "" || k == "" {
+			delete(merged, k)
+			continue
+		}
+		merged[k] = v
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
+// CurrentBaggage returns the baggage items set on the context, including the
+// baggage received with the current inbound call, or nil if there are none.
+// The returned map must not be modified.
+func CurrentBaggage(ctx context.Context) map[string]string {
+	if params := getTChannelParams(ctx); params != nil {
+		return params.baggage
+	}
+	return nil
+}
+
+// BaggageItem returns the value of the baggage item with the given key, or
+// an empty string if the context has no such item.
+func BaggageItem(ctx context.Context, key string) string {
+	return CurrentBaggage(ctx)[key]
+}
@@ -0,0 +1,188 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// formatBaggage returns the baggage of the context as sorted key=value pairs.
+func formatBaggage(ctx context.Context) string {
+	var items []string
+	for k, v := range CurrentBaggage(ctx) {
+		items = append(items, k+"="+v)
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+// registerBaggage registers a "baggage" handler that returns the baggage it
+// receives, and a "forward" handler that calls it using its own context.
+func registerBaggage(ts *testutils.TestServer) {
+	testutils.RegisterFunc(ts.Server(), "baggage", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		return &raw.Res{Arg3: []byte(formatBaggage(ctx))}, nil
+	})
+	testutils.RegisterFunc(ts.Server(), "forward", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		if len(args.Arg3) > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = NewContextBuilder(0).
+				SetParentContext(ctx).
+				SetBaggage(string(args.Arg3), "").
+				SetBaggage("hop", "2").
+				Build()
+			defer cancel()
+		}
+		_, arg3, _, err := raw.Call(ctx, ts.Server(), ts.HostPort(), ts.ServiceName(), "baggage", nil, nil)
+		return &raw.Res{Arg3: arg3}, err
+	})
+}
+
+func callBaggage(t *testing.T, client *Channel, ts *testutils.TestServer, ctx context.Context, method, arg3 string) string {
+	_, got, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), method, nil, []byte(arg3))
+	require.NoError(t, err, "%v call failed", method)
+	return string(got)
+}
+
+func TestBaggagePropagation(t *testing.T) {
+	testutils.WithTestServer(t, nil, func(ts *testutils.TestServer) {
+		registerBaggage(ts)
+		client := ts.NewClient(nil)
+
+		ctx, cancel := NewContextBuilder(testutils.Timeout(time.Second)).
+			SetBaggage("tenant", "t1").
+			SetBaggage("user", "u1").
+			Build()
+		defer cancel()
+		assert.Equal(t, "t1", BaggageItem(ctx, "tenant"), "Unexpected baggage item")
+		assert.Equal(t, "", BaggageItem(ctx, "unknown"), "Unknown baggage item should be empty")
+
+		assert.Equal(t, "tenant=t1,user=u1", callBaggage(t, client, ts, ctx, "baggage", ""),
+			"Baggage should be received by the server")
+		assert.Equal(t, "tenant=t1,user=u1", callBaggage(t, client, ts, ctx, "forward", ""),
+			"Baggage should be propagated by calls using the handler's context")
+		assert.Equal(t, "hop=2,tenant=t1", callBaggage(t, client, ts, ctx, "forward", "user"),
+			"Baggage should be merged with the parent context's baggage")
+
+		noBaggageCtx, cancel := NewContext(testutils.Timeout(time.Second))
+		defer cancel()
+		assert.Equal(t, "", callBaggage(t, client, ts, noBaggageCtx, "forward", ""),
+			"Unexpected baggage for a call without baggage")
+	})
+}
+
+func TestBaggageLimits(t *testing.T) {
+	testutils.WithTestServer(t, nil, func(ts *testutils.TestServer) {
+		registerBaggage(ts)
+
+		stats := newRecordingStatsReporter()
+		opts := testutils.NewOpts().SetStatsReporter(stats)
+		opts.Baggage = BaggageOptions{MaxItems: 2, MaxSize: 10}
+		client := ts.NewClient(opts)
+
+		tests := []struct {
+			msg     string
+			baggage map[string]string
+			want    string
+			dropped int
+		}{
+			{
+				msg:     "under the limits",
+				baggage: map[string]string{"a": "1", "b": "2"},
+				want:    "a=1,b=2",
+			},
+			{
+				msg:     "over the item limit",
+				baggage: map[string]string{"a": "1", "b": "2", "c": "3"},
+				want:    "a=1,b=2",
+				dropped: 1,
+			},
+			{
+				msg:     "over the size limit",
+				baggage: map[string]string{"a": "1234567890", "b": "2"},
+				want:    "b=2",
+				dropped: 1,
+			},
+			{
+				msg:     "value longer than a transport header",
+				baggage: map[string]string{"a": strings.Repeat("1", 256)},
+				dropped: 1,
+			},
+		}
+
+		for _, tt := range tests {
+			stats.Reset()
+			cb := NewContextBuilder(testutils.Timeout(time.Second))
+			cb.Baggage = tt.baggage
+			ctx, cancel := cb.Build()
+			assert.Equal(t, tt.want, callBaggage(t, client, ts, ctx, "baggage", ""), "%v: unexpected baggage", tt.msg)
+			cancel()
+
+			stats.Lock()
+			var dropped int64
+			for _, v := range stats.Values["outbound.baggage.dropped"] {
+				dropped += v.count
+			}
+			stats.Unlock()
+			assert.EqualValues(t, tt.dropped, dropped, "%v: unexpected dropped items", tt.msg)
+		}
+	})
+}
+
+func TestBaggageRedact(t *testing.T) {
+	testutils.WithTestServer(t, nil, func(ts *testutils.TestServer) {
+		registerBaggage(ts)
+
+		opts := testutils.NewOpts()
+		opts.OutboundStats.SlowCallThreshold = time.Nanosecond
+		opts.Baggage.Redact = func(key, value string) string {
+			if key == "token" {
+				return "redacted"
+			}
+			return value
+		}
+		client := ts.NewClient(opts)
+
+		ctx, cancel := NewContextBuilder(testutils.Timeout(time.Second)).
+			SetBaggage("token", "secret").
+			SetBaggage("tenant", "t1").
+			Build()
+		defer cancel()
+		assert.Equal(t, "tenant=t1,token=secret", callBaggage(t, client, ts, ctx, "baggage", ""),
+			"Redaction should not change the baggage sent")
+
+		calls := client.SlowCalls()
+		require.Len(t, calls, 1, "Expected the call to be sampled")
+		assert.Equal(t, "redacted", calls[0].Headers["$baggage$token"], "Baggage item should be redacted")
+		assert.Equal(t, "t1", calls[0].Headers["$baggage$tenant"], "Unexpected baggage item")
+	})
+}
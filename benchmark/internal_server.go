@@ -21,6 +21,7 @@
 package benchmark
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -31,7 +32,6 @@ import (
 	gen "github.com/uber/tchannel-go/thrift/gen-go/test"
 
 	"github.com/uber-go/atomic"
-	"golang.org/x/net/context"
 )
 
 // internalServer represents a benchmark server.
@@ -0,0 +1,91 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import "fmt"
+
+// CallError describes an outbound call that failed. Calls made using a
+// channel with ChannelOptions.DetailedErrors set return a *CallError which
+// wraps the error that the call failed with, so callers can use errors.Is,
+// errors.As or GetSystemErrorCode to check the error, rather than matching
+// error messages.
+type CallError struct {
+	// Service is the service that was called.
+	Service string
+
+	// Method is the method that was called.
+	Method string
+
+	// HostPort is the host:port of the peer the call was made to.
+	HostPort string
+
+	// Attempt is the attempt of the call when using RunWithRetry, starting
+	// at 1. It's 0 for calls made without RunWithRetry.
+	Attempt int
+
+	// Remote is set if the error was sent by the peer in an error frame,
+	// rather than caused locally, e.g. by a connection error or timeout.
+	// GetSystemErrorMessage returns the message sent by the peer.
+	Remote bool
+
+	// Err is the error the call failed with.
+	Err error
+}
+
+func (e *CallError) Error() string {
+	return fmt.Sprintf("%v (call to %v::%v on %v)", e.Err, e.Service, e.Method, e.HostPort)
+}
+
+// Unwrap returns the error the call failed with.
+func (e *CallError) Unwrap() error {
+	return e.Err
+}
+
+// callErrorInfo describes an outbound call, and is used to wrap the call's
+// errors in a CallError. A nil callErrorInfo leaves errors unwrapped.
+type callErrorInfo struct {
+	service      string
+	method       string
+	hostPort     string
+	requestState *RequestState
+}
+
+func (i *callErrorInfo) wrap(err error, remote bool) error {
+	if i == nil || err == nil {
+		return err
+	}
+	if _, ok := err.(*CallError); ok {
+		return err
+	}
+
+	var attempt int
+	if i.requestState != nil {
+		attempt = i.requestState.Attempt
+	}
+	return &CallError{
+		Service:  i.service,
+		Method:   i.method,
+		HostPort: i.hostPort,
+		Attempt:  attempt,
+		Remote:   remote,
+		Err:      err,
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetailedErrors(t *testing.T) {
+	opts := testutils.NewOpts().NoRelay()
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		testutils.RegisterFunc(ts.Server(), "busy", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+			return nil, ErrServerBusy
+		})
+		// Block until the test completes, so the client always times out.
+		release := make(chan struct{})
+		defer close(release)
+		ts.Register(HandlerFunc(func(ctx context.Context, call *InboundCall) {
+			<-release
+		}), "slow")
+
+		client := ts.NewClient(testutils.NewOpts().SetDetailedErrors())
+		sc := client.GetSubChannel(ts.ServiceName())
+		client.Peers().Add(ts.HostPort())
+
+		ctx, cancel := NewContext(time.Second)
+		defer cancel()
+
+		var attempts int
+		err := client.RunWithRetry(ctx, func(ctx context.Context, rs *RequestState) error {
+			attempts++
+			_, err := raw.CallV2(ctx, sc, raw.CArgs{
+				Method:      "busy",
+				CallOptions: &CallOptions{RequestState: rs},
+			})
+			return err
+		})
+		callErr, ok := err.(*CallError)
+		require.True(t, ok, "Expected CallError, got %v", err)
+		assert.Equal(t, &CallError{
+			Service:  ts.ServiceName(),
+			Method:   "busy",
+			HostPort: ts.HostPort(),
+			Attempt:  attempts,
+			Remote:   true,
+			Err:      ErrServerBusy,
+		}, callErr, "Unexpected CallError for error sent by the server")
+		assert.Equal(t, ErrCodeBusy, GetSystemErrorCode(err), "Unexpected error code")
+		assert.True(t, attempts > 1, "Busy calls should be retried")
+
+		slowCtx, cancel := NewContext(testutils.Timeout(50 * time.Millisecond))
+		defer cancel()
+		_, _, _, err = raw.Call(slowCtx, client, ts.HostPort(), ts.ServiceName(), "slow", nil, nil)
+		callErr, ok = err.(*CallError)
+		require.True(t, ok, "Expected CallError, got %v", err)
+		assert.False(t, callErr.Remote, "Timeouts should not be reported as remote errors")
+		assert.Equal(t, "slow", callErr.Method, "Unexpected method")
+		assert.Equal(t, ErrCodeTimeout, GetSystemErrorCode(err), "Unexpected error code")
+
+		closed := testutils.GetClosedHostPort(t)
+		_, _, _, err = raw.Call(ctx, client, closed, ts.ServiceName(), "echo", nil, nil)
+		callErr, ok = err.(*CallError)
+		require.True(t, ok, "Expected CallError, got %v", err)
+		assert.Equal(t, closed, callErr.HostPort, "Unexpected host:port")
+		assert.IsType(t, &net.OpError{}, callErr.Err, "Expected the connection error to be wrapped")
+	})
+}
+
+func TestDetailedErrorsDisabled(t *testing.T) {
+	opts := testutils.NewOpts().NoRelay()
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		testutils.RegisterFunc(ts.Server(), "busy", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+			return nil, ErrServerBusy
+		})
+
+		ctx, cancel := NewContext(time.Second)
+		defer cancel()
+
+		_, _, _, err := raw.Call(ctx, ts.NewClient(nil), ts.HostPort(), ts.ServiceName(), "busy", nil, nil)
+		assert.Equal(t, ErrServerBusy, err, "Errors should not be wrapped by default")
+	})
+}
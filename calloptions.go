@@ -20,6 +20,8 @@
 
 package tchannel
 
+import "github.com/uber/tchannel-go/tos"
+
 // Format is the arg scheme used for a specific call.
 type Format string
 
@@ -27,6 +29,7 @@ type Format string
 const (
 	HTTP   Format = "http"
 	JSON   Format = "json"
+	Proto  Format = "proto"
 	Raw    Format = "raw"
 	Thrift Format = "thrift"
 )
@@ -56,6 +59,30 @@ type CallOptions struct {
 	// to an instance of the intended service.
 	RoutingDelegate string
 
+	// Compression is the name of the compression used for arg3 of the call,
+	// which overrides the channel's compression. NoCompression disables
+	// compression for the call.
+	Compression string
+
+	// Priority is the priority of the call, sent in the "pr" header. Servers
+	// that queue calls admit higher priority calls first, and relays shed low
+	// priority calls to busy connections.
+	Priority CallPriority
+
+	// TosPriority is the ToS class of the connection used for the call. If it
+	// differs from the channel's ConnectionOptions.TosPriority, the call is
+	// sent on a separate connection marked with this class, so that traffic
+	// classes such as health checks and bulk transfers can use different
+	// DSCP markings. By default, calls use the channel's connections.
+	TosPriority tos.ToS
+
+	// IdempotencyKey is sent in the "idk" header so that servers which
+	// deduplicate calls only handle the request once. Calls made using
+	// RunWithRetry that may be retried use a generated key by default, which
+	// is new for each RunWithRetry. The key of an inbound call is not copied
+	// by (*InboundCall).CallOptions.
+	IdempotencyKey string
+
 	// callerName can only be used when forwarding a request. It can only be set internally,
 	// e.g. by calling (*InboundCall).CallOptions() when forwarding a request
 	callerName string
@@ -65,6 +92,9 @@ var defaultCallOptions = &CallOptions{}
 
 func (c *CallOptions) setHeaders(headers transportHeaders) {
 	headers[ArgScheme] = Raw.String()
+	if key := c.RequestState.IdempotencyKey(); key != "" {
+		headers[IdempotencyKey] = key
+	}
 	c.overrideHeaders(headers)
 }
 
@@ -82,6 +112,12 @@ func (c *CallOptions) overrideHeaders(headers transportHeaders) {
 	if c.RoutingDelegate != "" {
 		headers[RoutingDelegate] = c.RoutingDelegate
 	}
+	if c.Priority != PriorityNormal {
+		headers[Priority] = c.Priority.String()
+	}
+	if c.IdempotencyKey != "" {
+		headers[IdempotencyKey] = c.IdempotencyKey
+	}
 	if c.callerName != "" {
 		headers[CallerName] = c.callerName
 	}
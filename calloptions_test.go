@@ -31,6 +31,7 @@ func TestSetHeaders(t *testing.T) {
 		format          Format
 		routingDelegate string
 		routingKey      string
+		priority        CallPriority
 		expectedHeaders transportHeaders
 	}{
 		{
@@ -58,6 +59,14 @@ func TestSetHeaders(t *testing.T) {
 				RoutingKey: "canary",
 			},
 		},
+		{
+			format:   Thrift,
+			priority: PriorityHigh,
+			expectedHeaders: transportHeaders{
+				ArgScheme: Thrift.String(),
+				Priority:  "high",
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -65,6 +74,7 @@ func TestSetHeaders(t *testing.T) {
 			Format:          tt.format,
 			RoutingDelegate: tt.routingDelegate,
 			RoutingKey:      tt.routingKey,
+			Priority:        tt.priority,
 		}
 		headers := make(transportHeaders)
 		callOpts.setHeaders(headers)
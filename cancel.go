@@ -0,0 +1,172 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// callControlCancel and callControlClaim are advertised in
+// InitParamCallControl by peers that handle cancel and claim frames.
+const (
+	callControlCancel = "cancel"
+	callControlClaim  = "claim"
+)
+
+// advertisedCallControl is the list of call control frames that channels
+// handle, which is sent to peers in InitParamCallControl.
+const advertisedCallControl = callControlCancel + "," + callControlClaim
+
+// advertisedCallControl returns the call control frames to advertise to peers.
+func (ch *Channel) advertisedCallControl() string {
+	if ch.callControlDisabled.Load() {
+		return ""
+	}
+	return advertisedCallControl
+}
+
+// callControlSupport is the set of call control frames that a peer advertised
+// support for. Peers that do not advertise support, such as older versions of
+// TChannel, treat the frames as unexpected, so they are not sent.
+type callControlSupport struct {
+	cancel bool
+	claim  bool
+}
+
+// parseCallControl parses the call control frames advertised by a peer.
+func parseCallControl(p initParams) callControlSupport {
+	var supported callControlSupport
+	for _, name := range strings.Split(p[InitParamCallControl], ",") {
+		switch name {
+		case callControlCancel:
+			supported.cancel = true
+		case callControlClaim:
+			supported.claim = true
+		}
+	}
+	return supported
+}
+
+// supportsCallControl returns whether the peer handles the given call control
+// frame type.
+func (c *Connection) supportsCallControl(t messageType) bool {
+	switch t {
+	case messageTypeCancel:
+		return c.remoteCallControl.cancel
+	case messageTypeClaim:
+		return c.remoteCallControl.claim
+	default:
+		return false
+	}
+}
+
+// cancelOnFailure returns a callback for the call's writer and reader that
+// sends a cancel frame to the peer if the call fails because the caller's
+// context was cancelled. This lets the peer stop working on a call whose
+// response will never be read, such as the losing attempt of a hedged call.
+// Cancel frames are only sent to peers that advertised support for them.
+func (c *Connection) cancelOnFailure(call *OutboundCall) func(error) {
+	return func(err error) {
+		if err != ErrRequestCancelled || !c.supportsCallControl(messageTypeCancel) || call.abandoned.Swap(true) {
+			return
+		}
+
+		c.sendCallControl(&cancelMessage{
+			id:         call.callReq.id,
+			TimeToLive: call.remainingTTL(),
+			Tracing:    call.callReq.Tracing,
+			Why:        GetSystemErrorMessage(err),
+		}, "outbound.calls.cancels")
+	}
+}
+
+// remainingTTL returns the time left before the call's deadline.
+func (call *OutboundCall) remainingTTL() time.Duration {
+	ttl := call.callReq.TimeToLive
+	if deadline, ok := call.mex.ctx.Deadline(); ok {
+		ttl = deadline.Sub(call.conn.timeNow())
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+	return ttl
+}
+
+// sendCallControl sends a cancel or claim frame for an outbound call, and
+// increments the given counter if the frame was sent.
+func (c *Connection) sendCallControl(msg message, counter string) error {
+	frame, err := newMessageFrame(c.opts.FramePool, msg)
+	if err != nil {
+		return err
+	}
+
+	// Hold the state rlock to ensure that sendCh is not closed while we send.
+	return c.withStateRLock(func() error {
+		if c.state == connectionClosed {
+			c.opts.FramePool.Release(frame)
+			return fmt.Errorf("failed to send %v frame, connection state %v", msg.messageType(), c.state)
+		}
+
+		select {
+		case c.sendCh <- frame:
+			c.statsReporter.IncCounter(counter, c.commonStatsTags, 1)
+			return nil
+		default:
+		}
+		c.opts.FramePool.Release(frame)
+		c.log.WithFields(
+			LogField{"remotePeer", c.remotePeerInfo},
+			LogField{"id", msg.ID()},
+			LogField{"messageType", msg.messageType()},
+		).Info("Dropping call control frame as the send buffer is full.")
+		return ErrSendBufferFull
+	})
+}
+
+// handleCancel handles a cancel frame from the peer by cancelling the context
+// of the inbound call it refers to, if the call is still active.
+func (c *Connection) handleCancel(frame *Frame) {
+	var msg cancelMessage
+	if err := frame.read(&msg); err != nil {
+		c.log.WithFields(
+			LogField{"remotePeer", c.remotePeerInfo},
+			ErrField(err),
+		).Warn("Unable to read cancel frame.")
+		return
+	}
+
+	cancel := c.inbound.cancelFunc(frame.Header.ID)
+	if cancel == nil {
+		// The call may have already completed or timed out.
+		if c.log.Enabled(LogLevelDebug) {
+			c.log.Debugf("Ignoring cancel for unknown inbound call %v", frame.Header.ID)
+		}
+		return
+	}
+
+	c.statsReporter.IncCounter("inbound.calls.cancels", c.commonStatsTags, 1)
+	cancel()
+	if c.log.Enabled(LogLevelDebug) {
+		c.log.Debugf("Cancelled inbound call %v: %v", frame.Header.ID, msg.Why)
+	}
+}
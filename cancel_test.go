@@ -0,0 +1,222 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/relay/relaytest"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// registerCancelHandler registers a handler that blocks until its context is
+// done, and reports the context's error.
+func registerCancelHandler(r Registrar, method string, started chan<- struct{}) <-chan error {
+	handlerErr := make(chan error, 1)
+	testutils.RegisterFunc(r, method, func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		handlerErr <- ctx.Err()
+		return &raw.Res{}, nil
+	})
+	return handlerErr
+}
+
+func TestCancelPropagatesToServer(t *testing.T) {
+	opts := testutils.NewOpts().DisableLogVerification() // handler responds after cancel
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		started := make(chan struct{}, 1)
+		handlerErr := registerCancelHandler(ts.Server(), "block", started)
+		client := ts.NewClient(nil)
+
+		ctx, cancel := NewContext(time.Minute)
+		go func() {
+			<-started
+			cancel()
+		}()
+		_, _, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "block", nil, nil)
+		assert.Equal(t, ErrRequestCancelled, err, "Expected call to be cancelled")
+
+		select {
+		case err := <-handlerErr:
+			assert.Equal(t, context.Canceled, err, "Expected handler's context to be cancelled")
+		case <-time.After(testutils.Timeout(time.Second)):
+			t.Fatal("Handler's context was not cancelled")
+		}
+	})
+}
+
+func TestCancelRelayStats(t *testing.T) {
+	opts := testutils.NewOpts().
+		SetRelayOnly().
+		DisableLogVerification() // handler responds after cancel
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		started := make(chan struct{}, 1)
+		handlerErr := registerCancelHandler(ts.Server(), "block", started)
+		client := ts.NewClient(nil)
+
+		ctx, cancel := NewContext(time.Minute)
+		go func() {
+			<-started
+			cancel()
+		}()
+		_, _, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "block", nil, nil)
+		assert.Equal(t, ErrRequestCancelled, err, "Expected call to be cancelled")
+		require.Equal(t, context.Canceled, <-handlerErr, "Expected relay to forward the cancel")
+
+		calls := relaytest.NewMockStats()
+		calls.Add(client.PeerInfo().ServiceName, ts.ServiceName(), "block").
+			Failed("cancelled").End()
+		ts.AssertRelayStats(calls)
+	})
+}
+
+func TestCancelNotSentToOlderPeers(t *testing.T) {
+	opts := testutils.NewOpts().DisableLogVerification() // handler responds after cancel
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		// The server acts as an older peer that does not handle cancel
+		// frames, so neither the client nor the relay should send them.
+		ts.Server().DisableCallControl()
+
+		started := make(chan struct{}, 1)
+		release := make(chan struct{})
+		handlerErr := make(chan error, 1)
+		testutils.RegisterFunc(ts.Server(), "block", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+			started <- struct{}{}
+			select {
+			case <-ctx.Done():
+				handlerErr <- ctx.Err()
+			case <-release:
+				handlerErr <- nil
+			}
+			return &raw.Res{}, nil
+		})
+		client := ts.NewClient(nil)
+
+		ctx, cancel := NewContext(time.Minute)
+		go func() {
+			<-started
+			cancel()
+		}()
+		_, _, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "block", nil, nil)
+		assert.Equal(t, ErrRequestCancelled, err, "Expected call to be cancelled")
+
+		select {
+		case err := <-handlerErr:
+			t.Fatalf("Handler's context should not be cancelled, got %v", err)
+		case <-time.After(testutils.Timeout(50 * time.Millisecond)):
+		}
+		close(release)
+		assert.NoError(t, <-handlerErr, "Handler should complete normally")
+	})
+}
+
+// relayItemCount returns the number of live relay items across all of the
+// relay's connections.
+func relayItemCount(relay *Channel) int {
+	var n int
+	for _, peerState := range relay.IntrospectState(nil).RootPeers {
+		for _, connState := range peerState.InboundConnections {
+			n += connState.Relayer.Count
+		}
+		for _, connState := range peerState.OutboundConnections {
+			n += connState.Relayer.Count
+		}
+	}
+	return n
+}
+
+func TestCancelRelayRemovesItems(t *testing.T) {
+	tests := []struct {
+		msg             string
+		disableDestCtrl bool
+	}{
+		{msg: "destination supports cancel"},
+		{msg: "destination does not support cancel", disableDestCtrl: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			opts := testutils.NewOpts().SetRelayOnly()
+			if !tt.disableDestCtrl {
+				opts.DisableLogVerification() // handler responds after cancel
+			}
+			testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+				if tt.disableDestCtrl {
+					ts.Server().DisableCallControl()
+				}
+
+				started := make(chan struct{}, 1)
+				release := make(chan struct{})
+				handlerDone := make(chan struct{})
+				testutils.RegisterFunc(ts.Server(), "block", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+					defer close(handlerDone)
+					started <- struct{}{}
+					<-release
+					return &raw.Res{}, nil
+				})
+				client := ts.NewClient(nil)
+
+				ctx, cancel := NewContext(time.Minute)
+				go func() {
+					<-started
+					cancel()
+				}()
+				_, _, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "block", nil, nil)
+				assert.Equal(t, ErrRequestCancelled, err, "Expected call to be cancelled")
+
+				// Both sides of the relayed call should be done while the
+				// handler is still running.
+				assert.True(t, testutils.WaitFor(time.Second, func() bool {
+					return relayItemCount(ts.Relay()) == 0
+				}), "Relay items remain after cancel")
+
+				// Any late response is dropped by the relay.
+				close(release)
+				<-handlerDone
+				assert.Equal(t, 0, relayItemCount(ts.Relay()), "Relay items remain after late response")
+			})
+		})
+	}
+}
+
+func TestCancelAfterCompletionIgnored(t *testing.T) {
+	testutils.WithTestServer(t, nil, func(ts *testutils.TestServer) {
+		testutils.RegisterEcho(ts.Server(), nil)
+		client := ts.NewClient(nil)
+
+		ctx, cancel := NewContext(testutils.Timeout(time.Second))
+		_, _, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "echo", nil, nil)
+		require.NoError(t, err, "Call failed")
+		cancel()
+
+		// Cancelling the context after the call completes should not affect
+		// the connection or later calls.
+		assert.NoError(t, callService(client, ts.HostPort(), ts.ServiceName(), "echo"), "Call failed")
+	})
+}
@@ -0,0 +1,592 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/uber/tchannel-go/trand"
+	"github.com/uber/tchannel-go/typed"
+
+	"github.com/uber-go/atomic"
+)
+
+const (
+	defaultMaxCapturedCalls  = 100
+	defaultMaxCapturedFrames = 32
+)
+
+// captureMagic starts every capture written by WriteCapture, and includes the
+// version of the format.
+var captureMagic = [8]byte{'t', 'c', 'h', 'c', 'a', 'p', 0, 1}
+
+var (
+	errInvalidCapture   = errors.New("invalid capture: unknown format")
+	errCaptureTruncated = errors.New("captured call is truncated")
+)
+
+// CaptureOptions configures which calls are captured by Channel.StartCapture.
+type CaptureOptions struct {
+	// SampleRate is the fraction of matching calls that are captured. If
+	// this is 0, all matching calls are captured.
+	SampleRate float64
+
+	// Methods restricts capturing to calls for the given methods. If empty,
+	// calls for all methods are captured.
+	Methods []string
+
+	// Callers restricts capturing to calls made by the given services. If
+	// empty, calls from all callers are captured.
+	Callers []string
+
+	// MaxCalls is the number of the most recent captured calls that are
+	// kept. Defaults to 100.
+	MaxCalls int
+
+	// MaxFramesPerCall is the maximum number of frames captured for each
+	// call. Later frames are dropped, and the call is marked as truncated.
+	// Defaults to 32.
+	MaxFramesPerCall int
+}
+
+// CapturedFrame is a frame sent or received for a captured call.
+type CapturedFrame struct {
+	Time time.Time `json:"time"`
+
+	// Sent is set for frames sent by the channel, and unset for received
+	// frames.
+	Sent bool `json:"sent"`
+
+	// Large is set for frames on connections that negotiated large frames.
+	Large bool `json:"large,omitempty"`
+
+	Header  FrameHeader `json:"header"`
+	Payload []byte      `json:"payload"`
+}
+
+// CapturedCall is a call captured by Channel.StartCapture, with all the
+// frames sent and received for the call.
+type CapturedCall struct {
+	// Time is when the first frame of the call was captured.
+	Time time.Time `json:"time"`
+
+	// Direction is AccessLogInbound or AccessLogOutbound.
+	Direction string `json:"direction"`
+
+	ConnectionID uint32 `json:"connectionID"`
+	RemotePeer   string `json:"remotePeer"`
+	Caller       string `json:"caller"`
+	Service      string `json:"service"`
+	Method       string `json:"method"`
+
+	// Truncated is set if frames were dropped due to MaxFramesPerCall.
+	Truncated bool `json:"truncated,omitempty"`
+
+	Frames []CapturedFrame `json:"frames"`
+}
+
+// frame returns a copy of the captured frame that can be parsed.
+func (f CapturedFrame) frame() *Frame {
+	frame := NewFrame(len(f.Payload))
+	frame.Header = f.Header
+	frame.large = f.Large
+	copy(frame.Payload, f.Payload)
+	return frame
+}
+
+// RequestArgs decodes the arguments sent with the call from its captured
+// frames. Arguments are not decompressed.
+func (c CapturedCall) RequestArgs() ([][]byte, error) {
+	return c.decodeArgs(true /* request */)
+}
+
+// ResponseArgs decodes the arguments of the call's response from its
+// captured frames. Arguments are not decompressed. If the call failed with
+// an error frame, the error is returned.
+func (c CapturedCall) ResponseArgs() ([][]byte, error) {
+	return c.decodeArgs(false /* request */)
+}
+
+func (c CapturedCall) decodeArgs(request bool) ([][]byte, error) {
+	var args [][]byte
+	for _, f := range c.Frames {
+		var msg message
+		switch msgType := f.Header.messageType; {
+		case request && msgType == messageTypeCallReq:
+			msg = &callReq{}
+		case request && msgType == messageTypeCallReqContinue:
+			msg = &callReqContinue{}
+		case !request && msgType == messageTypeCallRes:
+			msg = &callRes{}
+		case !request && msgType == messageTypeCallResContinue:
+			msg = &callResContinue{}
+		case !request && msgType == messageTypeError:
+			errMsg := &errorMessage{}
+			if err := f.frame().read(errMsg); err != nil {
+				return nil, err
+			}
+			return nil, errMsg.AsSystemError()
+		default:
+			continue
+		}
+
+		_, chunks, err := fragmentChunks(f.frame(), msg)
+		if err != nil {
+			return nil, err
+		}
+		// The first chunk in a fragment continues the previous fragment's
+		// last argument, while every other chunk starts a new argument.
+		for i, chunk := range chunks {
+			if i == 0 && len(args) > 0 {
+				args[len(args)-1] = append(args[len(args)-1], chunk...)
+			} else {
+				args = append(args, append([]byte(nil), chunk...))
+			}
+		}
+	}
+
+	if c.Truncated {
+		return args, errCaptureTruncated
+	}
+	return args, nil
+}
+
+// fragmentChunks returns the flags and argument chunks of a call frame.
+func fragmentChunks(frame *Frame, msg message) (flags byte, chunks [][]byte, _ error) {
+	fragment, err := parseInboundFragment(DisabledFramePool, frame, msg)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	contents := fragment.contents
+	for contents.BytesRemaining() > 0 && contents.Err() == nil {
+		var chunkSize int
+		if fragment.large {
+			chunkSize = int(contents.ReadUint32())
+		} else {
+			chunkSize = int(contents.ReadUint16())
+		}
+		if chunkSize > contents.BytesRemaining() {
+			return 0, nil, errChunkExceedsFragmentSize
+		}
+		chunks = append(chunks, contents.ReadBytes(chunkSize))
+	}
+	return fragment.flags, chunks, contents.Err()
+}
+
+// captureKey identifies a call on a connection. Inbound and outbound calls
+// on a connection may use the same message ID.
+type captureKey struct {
+	connID   uint32
+	outbound bool
+	msgID    uint32
+}
+
+type capturedCallEntry struct {
+	key  captureKey
+	call *CapturedCall
+}
+
+// frameCapture records the frames of sampled calls. It is disabled until
+// started, and only checks whether it's enabled for each frame while disabled.
+type frameCapture struct {
+	enabled atomic.Bool
+	rng     *rand.Rand
+
+	sync.Mutex
+	opts    CaptureOptions
+	methods map[string]struct{}
+	callers map[string]struct{}
+	calls   []capturedCallEntry
+	next    int
+	// pending are the captured calls that have not completed.
+	pending map[captureKey]*CapturedCall
+}
+
+func newFrameCapture() *frameCapture {
+	return &frameCapture{rng: trand.NewSeeded()}
+}
+
+func (fc *frameCapture) start(opts CaptureOptions) {
+	if opts.MaxCalls <= 0 {
+		opts.MaxCalls = defaultMaxCapturedCalls
+	}
+	if opts.MaxFramesPerCall <= 0 {
+		opts.MaxFramesPerCall = defaultMaxCapturedFrames
+	}
+
+	fc.Lock()
+	defer fc.Unlock()
+	fc.opts = opts
+	fc.methods = nil
+	if len(opts.Methods) > 0 {
+		fc.methods = toStringSet(opts.Methods)
+	}
+	fc.callers = nil
+	if len(opts.Callers) > 0 {
+		fc.callers = toStringSet(opts.Callers)
+	}
+	fc.calls = make([]capturedCallEntry, 0, opts.MaxCalls)
+	fc.next = 0
+	fc.pending = make(map[captureKey]*CapturedCall)
+	fc.enabled.Store(true)
+}
+
+func (fc *frameCapture) stop() {
+	fc.Lock()
+	defer fc.Unlock()
+	fc.enabled.Store(false)
+	fc.pending = nil
+}
+
+// shouldCapture returns whether a new call should be captured. It must be
+// called with the lock held.
+func (fc *frameCapture) shouldCapture(caller, method string) bool {
+	if fc.methods != nil {
+		if _, ok := fc.methods[method]; !ok {
+			return false
+		}
+	}
+	if fc.callers != nil {
+		if _, ok := fc.callers[caller]; !ok {
+			return false
+		}
+	}
+	if rate := fc.opts.SampleRate; rate > 0 && rate < 1 {
+		return fc.rng.Float64() < rate
+	}
+	return true
+}
+
+// add adds a new captured call, replacing the oldest call if the buffer is
+// full. It must be called with the lock held.
+func (fc *frameCapture) add(key captureKey, call *CapturedCall) {
+	fc.pending[key] = call
+	entry := capturedCallEntry{key, call}
+	if len(fc.calls) < cap(fc.calls) {
+		fc.calls = append(fc.calls, entry)
+		return
+	}
+
+	evicted := fc.calls[fc.next]
+	if fc.pending[evicted.key] == evicted.call {
+		delete(fc.pending, evicted.key)
+	}
+	fc.calls[fc.next] = entry
+	fc.next = (fc.next + 1) % len(fc.calls)
+}
+
+// list returns copies of the captured calls, oldest first.
+func (fc *frameCapture) list() []CapturedCall {
+	fc.Lock()
+	defer fc.Unlock()
+
+	calls := make([]CapturedCall, 0, len(fc.calls))
+	for _, entries := range [][]capturedCallEntry{fc.calls[fc.next:], fc.calls[:fc.next]} {
+		for _, entry := range entries {
+			call := *entry.call
+			call.Frames = append([]CapturedFrame(nil), call.Frames...)
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// captureFrame records a frame sent or received by the connection, if it
+// belongs to a captured call, or starts a new captured call.
+func (c *Connection) captureFrame(f *Frame, sent bool) {
+	fc := c.capture
+	if !fc.enabled.Load() {
+		return
+	}
+
+	// Requests are sent by the caller, and responses by the callee.
+	var outbound, final bool
+	switch f.messageType() {
+	case messageTypeCallReq, messageTypeCallReqContinue, messageTypeCancel:
+		outbound = sent
+	case messageTypeCallRes, messageTypeCallResContinue:
+		outbound = !sent
+		final = f.payloadSize() == 0 || f.Payload[0]&hasMoreFragmentsFlag == 0
+	case messageTypeError:
+		outbound = !sent
+		final = true
+	default:
+		return
+	}
+
+	key := captureKey{connID: c.connID, outbound: outbound, msgID: f.Header.ID}
+	captured := CapturedFrame{
+		Time:    c.timeNow(),
+		Sent:    sent,
+		Large:   f.large,
+		Header:  f.Header,
+		Payload: append([]byte(nil), f.SizedPayload()...),
+	}
+
+	var req callReq
+	var method string
+	if f.messageType() == messageTypeCallReq {
+		_, chunks, err := fragmentChunks(f, &req)
+		if err != nil || len(chunks) == 0 {
+			return
+		}
+		method = string(chunks[0])
+	}
+
+	fc.Lock()
+	defer fc.Unlock()
+	if !fc.enabled.Load() {
+		return
+	}
+
+	call, ok := fc.pending[key]
+	if !ok {
+		caller := req.Headers[CallerName]
+		if f.messageType() != messageTypeCallReq || !fc.shouldCapture(caller, method) {
+			return
+		}
+
+		direction := AccessLogInbound
+		if outbound {
+			direction = AccessLogOutbound
+		}
+		call = &CapturedCall{
+			Time:         captured.Time,
+			Direction:    direction,
+			ConnectionID: c.connID,
+			RemotePeer:   c.remotePeerInfo.HostPort,
+			Caller:       caller,
+			Service:      req.Service,
+			Method:       method,
+		}
+		fc.add(key, call)
+	}
+
+	if len(call.Frames) < fc.opts.MaxFramesPerCall {
+		call.Frames = append(call.Frames, captured)
+	} else {
+		call.Truncated = true
+	}
+	if final {
+		delete(fc.pending, key)
+	}
+}
+
+// StartCapture starts capturing the frames of calls sent and received by the
+// channel that match the given options, for debugging. Any previously
+// captured calls are discarded. Like introspection, capturing may slow down
+// the channel.
+func (ch *Channel) StartCapture(opts CaptureOptions) {
+	ch.capture.start(opts)
+}
+
+// StopCapture stops capturing new frames. The captured calls are kept until
+// capturing is started again.
+func (ch *Channel) StopCapture() {
+	ch.capture.stop()
+}
+
+// CapturedCalls returns the most recent captured calls, oldest first.
+func (ch *Channel) CapturedCalls() []CapturedCall {
+	return ch.capture.list()
+}
+
+// WriteCapture writes the captured calls to w, in a binary format that can
+// be read using ReadCapture.
+func (ch *Channel) WriteCapture(w io.Writer) error {
+	return writeCapture(w, ch.CapturedCalls())
+}
+
+// writeCapture writes the magic header, followed by each call as
+// time:8 outbound:1 truncated:1 connID:4 (remotePeer~2 caller~2 service~2 method~2) nf:2
+// and its nf frames, each as time:8 sent:1 large:1 header:16 payload, where
+// the payload size is determined by the frame header.
+func writeCapture(w io.Writer, calls []CapturedCall) error {
+	cw := &captureWriter{w: bufio.NewWriter(w)}
+	cw.write(captureMagic[:])
+	for _, call := range calls {
+		cw.writeUint64(uint64(call.Time.UnixNano()))
+		cw.writeBool(call.Direction == AccessLogOutbound)
+		cw.writeBool(call.Truncated)
+		cw.writeUint32(call.ConnectionID)
+		for _, s := range []string{call.RemotePeer, call.Caller, call.Service, call.Method} {
+			cw.writeUint16(uint16(len(s)))
+			cw.write([]byte(s))
+		}
+		cw.writeUint16(uint16(len(call.Frames)))
+		for _, f := range call.Frames {
+			cw.writeUint64(uint64(f.Time.UnixNano()))
+			cw.writeBool(f.Sent)
+			cw.writeBool(f.Large)
+
+			var header [FrameHeaderSize]byte
+			f.Header.write(typed.NewWriteBuffer(header[:]))
+			cw.write(header[:])
+			cw.write(f.Payload)
+		}
+	}
+	if cw.err != nil {
+		return cw.err
+	}
+	return cw.w.Flush()
+}
+
+// ReadCapture reads the calls written by Channel.WriteCapture.
+func ReadCapture(r io.Reader) ([]CapturedCall, error) {
+	cr := &captureReader{r: bufio.NewReader(r)}
+	if magic := cr.read(len(captureMagic)); cr.err != nil || string(magic) != string(captureMagic[:]) {
+		return nil, errInvalidCapture
+	}
+
+	var calls []CapturedCall
+	for {
+		if _, err := cr.r.Peek(1); err == io.EOF {
+			return calls, nil
+		}
+
+		var call CapturedCall
+		call.Time = time.Unix(0, int64(cr.readUint64()))
+		call.Direction = AccessLogInbound
+		if cr.readBool() {
+			call.Direction = AccessLogOutbound
+		}
+		call.Truncated = cr.readBool()
+		call.ConnectionID = cr.readUint32()
+		for _, s := range []*string{&call.RemotePeer, &call.Caller, &call.Service, &call.Method} {
+			*s = string(cr.read(int(cr.readUint16())))
+		}
+
+		numFrames := int(cr.readUint16())
+		for i := 0; i < numFrames && cr.err == nil; i++ {
+			var f CapturedFrame
+			f.Time = time.Unix(0, int64(cr.readUint64()))
+			f.Sent = cr.readBool()
+			f.Large = cr.readBool()
+			if err := f.Header.read(typed.NewReadBuffer(cr.read(FrameHeaderSize))); err != nil && cr.err == nil {
+				cr.err = err
+			}
+
+			frame := &Frame{Header: f.Header, large: f.Large}
+			payloadSize := frame.payloadSize()
+			if payloadSize < 0 && cr.err == nil {
+				cr.err = fmt.Errorf("invalid captured frame size %v", frame.frameSize())
+			}
+			f.Payload = cr.read(payloadSize)
+			call.Frames = append(call.Frames, f)
+		}
+
+		if cr.err != nil {
+			return nil, cr.err
+		}
+		calls = append(calls, call)
+	}
+}
+
+// captureWriter writes the capture format, keeping the first error.
+type captureWriter struct {
+	w   *bufio.Writer
+	err error
+	buf [8]byte
+}
+
+func (w *captureWriter) write(b []byte) {
+	if w.err == nil {
+		_, w.err = w.w.Write(b)
+	}
+}
+
+func (w *captureWriter) writeBool(v bool) {
+	w.buf[0] = 0
+	if v {
+		w.buf[0] = 1
+	}
+	w.write(w.buf[:1])
+}
+
+func (w *captureWriter) writeUint16(v uint16) {
+	binary.BigEndian.PutUint16(w.buf[:], v)
+	w.write(w.buf[:2])
+}
+
+func (w *captureWriter) writeUint32(v uint32) {
+	binary.BigEndian.PutUint32(w.buf[:], v)
+	w.write(w.buf[:4])
+}
+
+func (w *captureWriter) writeUint64(v uint64) {
+	binary.BigEndian.PutUint64(w.buf[:], v)
+	w.write(w.buf[:8])
+}
+
+// captureReader reads the capture format, keeping the first error.
+type captureReader struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (r *captureReader) read(n int) []byte {
+	if r.err != nil || n < 0 {
+		return nil
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		r.err = err
+		return nil
+	}
+	return b
+}
+
+func (r *captureReader) readBool() bool {
+	b := r.read(1)
+	return len(b) == 1 && b[0] == 1
+}
+
+func (r *captureReader) readUint16() uint16 {
+	if b := r.read(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *captureReader) readUint32() uint32 {
+	if b := r.read(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *captureReader) readUint64() uint64 {
+	if b := r.read(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapture(t *testing.T) {
+	testutils.WithTestServer(t, nil, func(ts *testutils.TestServer) {
+		testutils.RegisterEcho(ts.Server(), nil)
+		testutils.RegisterFunc(ts.Server(), "fail", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+			return nil, ErrServerBusy
+		})
+		testutils.RegisterFunc(ts.Server(), "other", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+			return &raw.Res{}, nil
+		})
+
+		ts.Server().StartCapture(CaptureOptions{Methods: []string{"echo", "fail"}})
+		client := ts.NewClient(nil)
+		client.StartCapture(CaptureOptions{})
+
+		ctx, cancel := NewContext(testutils.Timeout(time.Second))
+		defer cancel()
+
+		arg3 := testutils.RandBytes(100000)
+		_, _, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "echo", []byte("arg2"), arg3)
+		require.NoError(t, err, "echo failed")
+		_, _, _, err = raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "fail", nil, nil)
+		require.Error(t, err, "fail should fail")
+
+		otherArg2 := testutils.RandBytes(10)
+		_, _, _, err = raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "other", otherArg2, nil)
+		require.NoError(t, err, "other failed")
+
+		serverCalls := ts.Server().CapturedCalls()
+		require.Len(t, serverCalls, 2, "Server should only capture the given methods")
+		echo := serverCalls[0]
+		assert.Equal(t, AccessLogInbound, echo.Direction, "Unexpected direction")
+		assert.Equal(t, client.ServiceName(), echo.Caller, "Unexpected caller")
+		assert.Equal(t, ts.ServiceName(), echo.Service, "Unexpected service")
+		assert.Equal(t, "echo", echo.Method, "Unexpected method")
+		assert.True(t, len(echo.Frames) > 2, "Large args should be captured in multiple frames")
+
+		reqArgs, err := echo.RequestArgs()
+		require.NoError(t, err, "RequestArgs failed")
+		assert.Equal(t, [][]byte{[]byte("echo"), []byte("arg2"), arg3}, reqArgs, "Unexpected request args")
+		resArgs, err := echo.ResponseArgs()
+		require.NoError(t, err, "ResponseArgs failed")
+		require.Len(t, resArgs, 3, "Unexpected number of response args")
+		assert.Equal(t, [][]byte{[]byte("arg2"), arg3}, resArgs[1:], "Unexpected response args")
+
+		_, err = serverCalls[1].ResponseArgs()
+		assert.Equal(t, ErrCodeBusy, GetSystemErrorCode(err), "Error frame should be decoded")
+
+		clientCalls := client.CapturedCalls()
+		require.Len(t, clientCalls, 3, "Client should capture all calls")
+		assert.Equal(t, AccessLogOutbound, clientCalls[0].Direction, "Unexpected direction")
+		assert.Equal(t, "other", clientCalls[2].Method, "Unexpected method")
+		reqArgs, err = clientCalls[2].RequestArgs()
+		require.NoError(t, err, "RequestArgs failed")
+		assert.Equal(t, otherArg2, reqArgs[1], "Unexpected arg2")
+
+		// Stopping keeps the captured calls, while restarting discards them.
+		client.StopCapture()
+		testutils.AssertEcho(t, client, ts.HostPort(), ts.ServiceName())
+		assert.Len(t, client.CapturedCalls(), 3, "Calls should not be captured after StopCapture")
+
+		client.StartCapture(CaptureOptions{Callers: []string{"other"}})
+		testutils.AssertEcho(t, client, ts.HostPort(), ts.ServiceName())
+		assert.Empty(t, client.CapturedCalls(), "Calls from other callers should not be captured")
+	})
+}
+
+func TestCaptureLimits(t *testing.T) {
+	testutils.WithTestServer(t, nil, func(ts *testutils.TestServer) {
+		testutils.RegisterEcho(ts.Server(), nil)
+		client := ts.NewClient(nil)
+		client.StartCapture(CaptureOptions{MaxCalls: 2, MaxFramesPerCall: 2})
+
+		ctx, cancel := NewContext(testutils.Timeout(time.Second))
+		defer cancel()
+
+		for i := 0; i < 3; i++ {
+			arg2 := []byte{byte(i)}
+			_, _, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "echo", arg2, nil)
+			require.NoError(t, err, "echo failed")
+		}
+
+		calls := client.CapturedCalls()
+		require.Len(t, calls, 2, "Only the most recent calls should be kept")
+		for i, call := range calls {
+			args, err := call.RequestArgs()
+			require.NoError(t, err, "RequestArgs failed")
+			assert.Equal(t, []byte{byte(i + 1)}, args[1], "Unexpected call order")
+		}
+
+		_, _, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "echo", nil, testutils.RandBytes(200000))
+		require.NoError(t, err, "echo failed")
+		calls = client.CapturedCalls()
+		truncated := calls[len(calls)-1]
+		assert.True(t, truncated.Truncated, "Call with more frames than the limit should be truncated")
+		assert.Len(t, truncated.Frames, 2, "Unexpected number of frames")
+		_, err = truncated.RequestArgs()
+		assert.Error(t, err, "Decoding truncated calls should fail")
+	})
+}
+
+func TestCaptureFile(t *testing.T) {
+	opts := testutils.NewOpts().NoRelay()
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		testutils.RegisterEcho(ts.Server(), nil)
+		client := ts.NewClient(nil)
+		client.StartCapture(CaptureOptions{})
+		testutils.AssertEcho(t, client, ts.HostPort(), ts.ServiceName())
+		testutils.AssertEcho(t, client, ts.HostPort(), ts.ServiceName())
+
+		want := client.CapturedCalls()
+		require.Len(t, want, 2, "Unexpected number of captured calls")
+
+		rec := httptest.NewRecorder()
+		client.IntrospectionHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/tchannel/capture", nil))
+		assert.Equal(t, "application/octet-stream", rec.Header().Get("Content-Type"), "unexpected content type")
+
+		got, err := ReadCapture(rec.Body)
+		require.NoError(t, err, "ReadCapture failed")
+		require.Len(t, got, len(want), "Unexpected number of calls read")
+		for i := range want {
+			assert.True(t, want[i].Time.Equal(got[i].Time), "Unexpected call time")
+			require.Len(t, got[i].Frames, len(want[i].Frames), "Unexpected number of frames")
+			for j := range want[i].Frames {
+				assert.True(t, want[i].Frames[j].Time.Equal(got[i].Frames[j].Time), "Unexpected frame time")
+				want[i].Frames[j].Time = got[i].Frames[j].Time
+			}
+			want[i].Time = got[i].Time
+		}
+		assert.Equal(t, want, got, "Calls read should match the captured calls")
+
+		_, err = ReadCapture(bytes.NewReader([]byte("not a capture")))
+		assert.Error(t, err, "ReadCapture should fail for invalid input")
+
+		var buf bytes.Buffer
+		require.NoError(t, client.WriteCapture(&buf), "WriteCapture failed")
+		_, err = ReadCapture(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+		assert.Error(t, err, "ReadCapture should fail for truncated input")
+
+		state := client.IntrospectState(&IntrospectionOptions{IncludeCapture: true})
+		assert.Len(t, state.Capture, 2, "Introspection should include the captured calls")
+	})
+}
@@ -21,9 +21,12 @@
 package tchannel
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -35,7 +38,6 @@ import (
 
 	"github.com/opentracing/opentracing-go"
 	"github.com/uber-go/atomic"
-	"golang.org/x/net/context"
 )
 
 var (
@@ -49,6 +51,10 @@ var (
 
 const ephemeralHostPort = "0.0.0.0:0"
 
+// Dialer creates an outbound connection to the given address, with the same
+// semantics as net.Dialer's DialContext.
+type Dialer func(ctx context.Context, network, address string) (net.Conn, error)
+
 // ChannelOptions are used to control parameters on a create a TChannel
 type ChannelOptions struct {
 	// Default Connection options
@@ -62,6 +68,120 @@ type ChannelOptions struct {
 	// a connection to a peer.
 	OnPeerStatusChanged func(*Peer)
 
+	// OnPeerAvailable is an optional callback that receives a notification
+	// when a peer goes from having no active connections to having one.
+	// Adding further connections to an available peer does not trigger it.
+	OnPeerAvailable func(*Peer)
+
+	// OnPeerUnavailable is an optional callback that receives a notification
+	// when a peer loses its last active connection.
+	OnPeerUnavailable func(*Peer)
+
+	// PeerSelectionStrategy is the strategy used to select peers from the
+	// channel's PeerList, which is shared by all non-isolated subchannels.
+	// If not set, NewPreferIncomingStrategy is used. Isolated subchannels use
+	// NewLeastPendingStrategy unless WithPeerSelectionStrategy is passed.
+	PeerSelectionStrategy ScoreCalculator
+
+	// CircuitBreaker configures the per-peer circuit breaker, which stops
+	// selecting peers that are failing calls. It is disabled by default.
+	CircuitBreaker CircuitBreakerOptions
+
+	// Reconnect configures background reconnects to peers whose connections
+	// fail, with exponential backoff. It is disabled by default.
+	Reconnect ReconnectOptions
+
+	// PeerRateLimit configures a rate limiter for outbound calls to each
+	// peer. Calls over the rate are delayed or rejected before they are sent.
+	// It is disabled by default. See WithRateLimit to limit the rate of calls
+	// made using a SubChannel.
+	PeerRateLimit RateLimitOptions
+
+	// RetryBudget limits the number of retries made by RunWithRetry across
+	// all calls on the channel. It is disabled by default.
+	RetryBudget RetryBudgetOptions
+
+	// DetailedErrors wraps the errors returned by outbound calls in a
+	// *CallError, which describes the call that failed. Errors should then be
+	// checked using errors.Is or GetSystemErrorCode, rather than comparing
+	// them to ErrTimeout and other errors directly. It is disabled by default.
+	DetailedErrors bool
+
+	// PeerCacheFile is a file used to persist the channel's peer list across
+	// restarts. Peers in the file are added to the peer list when the channel
+	// is created, and the peer list is saved to the file when the channel is
+	// closed. By default, the peer list is not persisted.
+	PeerCacheFile string
+
+	// WarmUp configures connecting to peers loaded from PeerCacheFile when
+	// the channel is created. It is disabled by default.
+	WarmUp WarmUpOptions
+
+	// Compression is the name of the compression used for arg3 of outbound
+	// calls, and responses to calls that used compression. Calls are only
+	// compressed if the peer advertised support for the compression when
+	// connecting, and it can be overridden per call using CallOptions.
+	// By default, calls are not compressed.
+	Compression string
+
+	// Compressors are the compressions supported by the channel, in addition
+	// to gzip, which is always supported.
+	Compressors []Compressor
+
+	// ConnectionPool configures how many connections are used for outbound
+	// calls to each peer, and when idle connections are closed. By default,
+	// a single connection is used and idle connections are kept open.
+	ConnectionPool ConnectionPoolOptions
+
+	// MaxFrameSize is the largest frame size to negotiate with peers, up to
+	// MaxLargeFrameSize. Larger frames are only used with peers that also
+	// advertise support for them, and other peers use 64KB frames.
+	// Relays always use 64KB frames. By default, 64KB frames are used.
+	MaxFrameSize int
+
+	// ConnectionStatsInterval is how often the bytes and frames sent and
+	// received on each connection, and the occupancy of its send buffer, are
+	// reported to the StatsReporter, aggregated by peer. Zero disables
+	// reporting, though the values are always available using IntrospectState.
+	ConnectionStatsInterval time.Duration
+
+	// MaxIdleTime is the duration after which a connection, inbound or
+	// outbound, that has had no calls is gracefully closed. Unlike
+	// ConnectionPool.IdleTimeout, it applies to every connection and does not
+	// keep a minimum number of connections open. Zero disables it.
+	MaxIdleTime time.Duration
+
+	// MaxConnectionAge is the duration after which a connection is gracefully
+	// closed, so that long-lived connections pick up DNS and load balancer
+	// changes. Calls in-flight on the connection complete first, and outbound
+	// connections are only closed once a replacement has connected. The age
+	// of each connection is reduced by a random jitter of up to 10% so that
+	// connections created together are not closed together. Zero disables it.
+	MaxConnectionAge time.Duration
+
+	// FaultInjector, if set, injects faults into inbound calls and the frames
+	// written by the channel. It is intended for testing only.
+	FaultInjector *FaultInjector
+
+	// AccessLog, if set, receives an entry for every call handled, made or
+	// relayed by the channel. See the accesslog package for a sink that
+	// writes JSON lines to a file.
+	AccessLog AccessLogSink
+
+	// LoadReporter, if set, is called for every response sent by the channel,
+	// and the returned load, where 0 is idle and 1 is saturated, is sent to
+	// the caller in the Load transport header. Callers can use
+	// NewLeastLoadedStrategy to prefer peers reporting less load.
+	LoadReporter func() float64
+
+	// InboundInterceptors wrap the handling of every inbound call, in order,
+	// regardless of the encoding, including calls to a custom Handler.
+	InboundInterceptors []InboundInterceptor
+
+	// OutboundInterceptors wrap the start of every outbound call, in order,
+	// regardless of the encoding.
+	OutboundInterceptors []OutboundInterceptor
+
 	// The logger to use for this channel
 	Logger Logger
 
@@ -77,9 +197,52 @@ type ChannelOptions struct {
 	// clamped to this value). Passing zero uses the default of 2m.
 	RelayMaxTimeout time.Duration
 
+	// RelayAdaptiveTimeouts reduces the timeouts of relayed calls based on the
+	// recent response latencies of their destination.
+	RelayAdaptiveTimeouts RelayAdaptiveTimeoutOptions
+
+	// RelayRateLimiter limits the rate of relayed calls for each pair of
+	// caller and callee services. Calls over the limit are rejected with a
+	// Busy error. The limits can be changed while the channel is running.
+	RelayRateLimiter *RelayRateLimiter
+
+	// RelayInterceptors inspect and modify relayed calls, in order, and may
+	// fail them before they are forwarded.
+	RelayInterceptors []RelayInterceptor
+
+	// RelayShadower copies a percentage of the relayed calls to each service
+	// to a shadow destination, discarding the shadow's responses. The shadows
+	// can be changed while the channel is running.
+	RelayShadower *RelayShadower
+
+	// RelayEdgeStats tracks the calls relayed from each caller to each
+	// service, and samples a percentage of them as traces.
+	RelayEdgeStats *RelayEdgeStats
+
 	// The reporter to use for reporting stats for this channel.
 	StatsReporter StatsReporter
 
+	// DrainTimeout is the maximum time Close waits for in-flight calls to
+	// complete. Once it expires, the remaining calls fail and all connections
+	// are closed. Zero means Close waits for all calls to complete.
+	DrainTimeout time.Duration
+
+	// DialTimeout is the maximum time to spend establishing a new outbound
+	// connection (TCP dial and the init handshake), regardless of the
+	// deadline on the call's context. Zero means the context deadline is
+	// the only limit.
+	DialTimeout time.Duration
+
+	// OutboundStats configures additional stats for outbound calls, such as
+	// tagging by peer and sampling slow calls.
+	OutboundStats OutboundStatsOptions
+
+	// Dialer is used to create all outbound network connections, which
+	// allows connecting through a proxy or binding to a specific source
+	// address. The dialer must respect the context's deadline and
+	// cancellation. By default, a net.Dialer is used.
+	Dialer Dialer
+
 	// TimeNow is a variable for overriding time.Now in unit tests.
 	// Note: This is not a stable part of the API and may change.
 	TimeNow func() time.Time
@@ -91,6 +254,87 @@ type ChannelOptions struct {
 	// Handler is an alternate handler for all inbound requests, overriding the
 	// default handler that delegates to a subchannel.
 	Handler Handler
+
+	// HTTP2Handler serves inbound connections that start with the HTTP/2
+	// client preface, allowing HTTP/2 cleartext (with prior knowledge) and
+	// TChannel to share the channel's listener. This is used by the grpc
+	// package to serve gRPC calls. If the handler has a SetChannel(*Channel)
+	// method, it is called once the channel is created. HTTP/2 connections
+	// are closed when the channel is closed.
+	HTTP2Handler http.Handler
+
+	// HTTPHandler serves inbound connections that start with an HTTP/1.x
+	// request, allowing HTTP endpoints such as health checks, pprof and
+	// metrics to share the channel's listener with TChannel. HTTP connections
+	// are closed when the channel is closed.
+	HTTPHandler http.Handler
+
+	// MaxConcurrentCalls is the maximum number of inbound calls the channel
+	// handles concurrently. Calls over the limit are queued up to
+	// MaxQueuedCalls, and otherwise rejected with a Busy error. Zero means
+	// there is no limit. Limits for a single service or method can be set
+	// using WithMaxConcurrentCalls and WithMethodMaxConcurrentCalls when
+	// getting a SubChannel.
+	MaxConcurrentCalls int
+
+	// MaxQueuedCalls is the maximum number of inbound calls that wait for
+	// one of the MaxConcurrentCalls to complete. Queued calls are admitted
+	// by their CallPriority, with high, normal and low priority calls
+	// admitted in the ratio 4:2:1. When the queue is full, a queued call with
+	// a lower priority is shed to make room, and otherwise the new call is
+	// rejected with a Busy error. Zero disables queueing.
+	MaxQueuedCalls int
+
+	// InboundArgSizeLimits limits the size of arg2 and arg3 of inbound
+	// calls. Calls over the limit are rejected with ErrArg2TooLarge or
+	// ErrArg3TooLarge as soon as the oversized fragment is received, before
+	// the handler reads it. Limits for a method can be set using
+	// WithMethodArgSizeLimits when getting a SubChannel. Relayed calls are
+	// not limited.
+	InboundArgSizeLimits ArgSizeLimits
+
+	// OutboundArgSizeLimits limits the size of arg2 and arg3 of outbound
+	// calls. Writing an argument over the limit fails the call with
+	// ErrArg2TooLarge or ErrArg3TooLarge.
+	OutboundArgSizeLimits ArgSizeLimits
+
+	// EnforceDeadlines sends callers a timeout error as soon as an inbound
+	// call's deadline expires, rather than waiting for the handler to
+	// return. The handler's context is cancelled, and any response it writes
+	// later is dropped. Maximum timeouts for a method can be set using
+	// WithMethodMaxTimeout when getting a SubChannel.
+	EnforceDeadlines bool
+
+	// TLSConfig enables TLS for all connections if set. Inbound connections
+	// are served using this config, and outbound connections use it as the
+	// client config, unless OutboundTLSConfig returns a config for the peer.
+	// Outbound configs must set ServerName or InsecureSkipVerify.
+	// The TLS handshake runs before the TChannel init handshake, so a listener
+	// passed to Serve should not already be a TLS listener.
+	TLSConfig *tls.Config
+
+	// OutboundTLSConfig optionally returns the TLS client config to use for a
+	// new outbound connection to the given host:port, e.g. to set a per-peer
+	// ServerName. If it returns nil, TLSConfig is used.
+	OutboundTLSConfig func(hostPort string) *tls.Config
+
+	// Baggage configures the limits and redaction of the baggage sent with
+	// outbound calls. See BaggageOptions for details.
+	Baggage BaggageOptions
+
+	// Authorizer, if set, authorizes every inbound call before it is
+	// dispatched to a handler, and unauthorized calls are rejected.
+	// See NewCallerAllowlist for an Authorizer that allows a list of callers.
+	Authorizer Authorizer
+
+	// ConnectionObserver, if set, is notified when connections are
+	// established, complete the init handshake, change state while closing,
+	// fail a health check, and are closed.
+	ConnectionObserver ConnectionObserver
+
+	// Idempotency, if set, deduplicates inbound calls that are sent with an
+	// idempotency key, such as retried calls, so they are only handled once.
+	Idempotency *IdempotencyOptions
 }
 
 // ChannelState is the state of a channel.
@@ -125,15 +369,41 @@ const (
 type Channel struct {
 	channelConnectionCommon
 
-	chID                uint32
-	createdStack        string
-	commonStatsTags     map[string]string
-	connectionOptions   ConnectionOptions
-	peers               *PeerList
-	relayHost           RelayHost
-	relayMaxTimeout     time.Duration
-	handler             Handler
-	onPeerStatusChanged func(*Peer)
+	chID                  uint32
+	createdStack          string
+	commonStatsTags       map[string]string
+	connectionOptions     ConnectionOptions
+	peers                 *PeerList
+	relayHost             RelayHost
+	relayMaxTimeout       time.Duration
+	relayAdaptiveTimeouts RelayAdaptiveTimeoutOptions
+	peerCacheFile         string
+	warmUp                WarmUpOptions
+	relayRateLimiter      *RelayRateLimiter
+	relayInterceptors     []RelayInterceptor
+	relayShadower         *RelayShadower
+	relayEdgeStats        *RelayEdgeStats
+	dialTimeout           time.Duration
+	dialer                Dialer
+	drainTimeout          time.Duration
+	circuitBreaker        CircuitBreakerOptions
+	reconnect             ReconnectOptions
+	peerRateLimit         RateLimitOptions
+	retryBudget           *retryBudget
+	connectionPool        ConnectionPoolOptions
+	maxFrameSize          int
+	connStatsInterval     time.Duration
+	maxIdleTime           time.Duration
+	maxConnectionAge      time.Duration
+	tlsConfig             *tls.Config
+	outboundTLSConfig     func(hostPort string) *tls.Config
+	handler               Handler
+	http2Handler          http.Handler
+	httpHandler           http.Handler
+	health                healthHandler
+
+	inboundInterceptors  []InboundInterceptor
+	outboundInterceptors []OutboundInterceptor
 
 	// mutable contains all the members of Channel which are mutable.
 	mutable struct {
@@ -142,6 +412,17 @@ type Channel struct {
 		peerInfo     LocalPeerInfo // May be ephemeral if this is a client only channel
 		l            net.Listener  // May be nil if this is a client only channel
 		conns        map[uint32]*Connection
+		http2Conns   map[net.Conn]struct{}
+		httpServer   *http.Server  // Set once an HTTP/1.x connection is accepted.
+		httpListener *httpListener // Returns HTTP/1.x connections to httpServer.
+		drainTimer   *time.Timer   // Set once Close is called if drainTimeout is set.
+		sweepTimer   *time.Timer   // Set if idle or aged connections are closed.
+		statsTimer   *time.Timer   // Set if ConnectionStatsInterval is set.
+		onRebind     []func(LocalPeerInfo)
+		onClose      []func()
+
+		// extraListeners are the listeners added using AddListener.
+		extraListeners []*extraListener
 	}
 }
 
@@ -154,6 +435,63 @@ type channelConnectionCommon struct {
 	tracer        opentracing.Tracer
 	subChannels   *subChannelMap
 	timeNow       func() time.Time
+
+	// inboundQueue limits the number of concurrent inbound calls across
+	// all connections, queueing calls over the limit by priority.
+	inboundQueue *callQueue
+
+	// enforceDeadlines is whether callers are sent a timeout error as soon
+	// as an inbound call's deadline expires.
+	enforceDeadlines bool
+
+	// compressors are the compressions supported by the channel.
+	compressors *compressors
+
+	// faults injects faults for testing, if set.
+	faults *FaultInjector
+
+	// accessLog receives an entry for every call, if set.
+	accessLog AccessLogSink
+
+	// loadReporter returns the load to report on responses, if set.
+	loadReporter func() float64
+
+	// outboundPeerTag is whether outbound call stats are tagged by peer.
+	outboundPeerTag bool
+
+	// detailedErrors is whether errors returned by outbound calls are
+	// wrapped in a CallError.
+	detailedErrors bool
+
+	// connObserver is notified of connection events, if set.
+	connObserver ConnectionObserver
+
+	// authorizer authorizes inbound calls, if set.
+	authorizer Authorizer
+
+	// dedup deduplicates inbound calls with an idempotency key, if set.
+	dedup *deduplicator
+
+	// baggage limits and redacts the baggage sent with outbound calls.
+	baggage BaggageOptions
+
+	// slowCalls samples slow outbound calls, if set.
+	slowCalls *slowCallSampler
+
+	// capture records the frames of sampled calls while capturing is started.
+	capture *frameCapture
+
+	// inboundArgLimits and outboundArgLimits limit the argument sizes of
+	// inbound and outbound calls.
+	inboundArgLimits  ArgSizeLimits
+	outboundArgLimits ArgSizeLimits
+
+	// draining is set while the channel rejects new inbound calls.
+	draining *atomic.Bool
+
+	// callControlDisabled stops the channel from advertising support for
+	// call control frames, which is used by tests to act as an older peer.
+	callControlDisabled *atomic.Bool
 }
 
 // _nextChID is used to allocate unique IDs to every channel for debugging purposes.
@@ -217,13 +555,74 @@ func NewChannel(serviceName string, opts *ChannelOptions) (*Channel, error) {
 			subChannels:   &subChannelMap{},
 			timeNow:       timeNow,
 			tracer:        opts.Tracer,
+
+			inboundQueue:     newCallQueue(opts.MaxConcurrentCalls, opts.MaxQueuedCalls),
+			enforceDeadlines: opts.EnforceDeadlines,
+			// Relays forward arg3 as-is, so they do not advertise compression
+			// as the relayed peer may not support it.
+			compressors: newCompressors(opts.Compressors, opts.Compression, opts.RelayHost == nil),
+			faults:      opts.FaultInjector,
+			accessLog:   opts.AccessLog,
+
+			loadReporter: opts.LoadReporter,
+
+			outboundPeerTag: opts.OutboundStats.PeerTag,
+			detailedErrors:  opts.DetailedErrors,
+			slowCalls:       newSlowCallSampler(opts.OutboundStats),
+			capture:         newFrameCapture(),
+			baggage:         opts.Baggage.withDefaults(),
+			authorizer:      opts.Authorizer,
+			dedup:           newDeduplicator(opts.Idempotency, timeNow),
+			connObserver:    opts.ConnectionObserver,
+
+			inboundArgLimits:  opts.InboundArgSizeLimits,
+			outboundArgLimits: opts.OutboundArgSizeLimits,
+
+			draining:            atomic.NewBool(false),
+			callControlDisabled: atomic.NewBool(false),
 		},
-		chID:              chID,
-		connectionOptions: opts.DefaultConnectionOptions.withDefaults(),
-		relayHost:         opts.RelayHost,
-		relayMaxTimeout:   validateRelayMaxTimeout(opts.RelayMaxTimeout, logger),
+		chID:                  chID,
+		connectionOptions:     opts.DefaultConnectionOptions.withDefaults(),
+		relayHost:             opts.RelayHost,
+		relayMaxTimeout:       validateRelayMaxTimeout(opts.RelayMaxTimeout, logger),
+		relayAdaptiveTimeouts: opts.RelayAdaptiveTimeouts,
+		peerCacheFile:         opts.PeerCacheFile,
+		warmUp:                opts.WarmUp,
+		relayRateLimiter:      opts.RelayRateLimiter,
+		relayInterceptors:     opts.RelayInterceptors,
+		relayShadower:         opts.RelayShadower,
+		relayEdgeStats:        opts.RelayEdgeStats,
+		dialTimeout:           opts.DialTimeout,
+		dialer:                opts.Dialer,
+		drainTimeout:          opts.DrainTimeout,
+		circuitBreaker:        opts.CircuitBreaker,
+		reconnect:             opts.Reconnect,
+		peerRateLimit:         opts.PeerRateLimit,
+		retryBudget:           newRetryBudget(opts.RetryBudget, timeNow),
+		connectionPool:        opts.ConnectionPool,
+		connStatsInterval:     opts.ConnectionStatsInterval,
+		maxIdleTime:           opts.MaxIdleTime,
+		maxConnectionAge:      opts.MaxConnectionAge,
+		tlsConfig:             opts.TLSConfig,
+		outboundTLSConfig:     opts.OutboundTLSConfig,
+		http2Handler:          opts.HTTP2Handler,
+		httpHandler:           opts.HTTPHandler,
+
+		inboundInterceptors:  opts.InboundInterceptors,
+		outboundInterceptors: opts.OutboundInterceptors,
+	}
+	if opts.RelayHost == nil {
+		// Relays forward frames as-is, so they only use standard frames.
+		ch.maxFrameSize = maxFrameSize(opts.MaxFrameSize)
+	}
+	ch.peers = newRootPeerList(ch, peerStatusEvents{
+		OnStatusChanged: opts.OnPeerStatusChanged,
+		OnAvailable:     opts.OnPeerAvailable,
+		OnUnavailable:   opts.OnPeerUnavailable,
+	}, ch.initPeer).newChild()
+	if opts.PeerSelectionStrategy != nil {
+		ch.peers.SetStrategy(opts.PeerSelectionStrategy)
 	}
-	ch.peers = newRootPeerList(ch, opts.OnPeerStatusChanged).newChild()
 
 	if opts.Handler != nil {
 		ch.handler = opts.Handler
@@ -255,10 +654,18 @@ func NewChannel(serviceName string, opts *ChannelOptions) (*Channel, error) {
 	}
 
 	registerNewChannel(ch)
+	ch.startConnectionSweep()
+	ch.startConnectionStats()
 
 	if opts.RelayHost != nil {
 		opts.RelayHost.SetChannel(ch)
 	}
+	if setter, ok := opts.HTTP2Handler.(channelSetter); ok {
+		setter.SetChannel(ch)
+	}
+	if ch.loadPeerCache() > 0 && ch.warmUp.Connections > 0 {
+		ch.startWarmUp()
+	}
 	return ch, nil
 }
 
@@ -285,7 +692,7 @@ func (ch *Channel) Serve(l net.Listener) error {
 	}
 	mutable.state = ChannelListening
 
-	mutable.peerInfo.HostPort = l.Addr().String()
+	mutable.peerInfo.HostPort = addrHostPort(l.Addr())
 	mutable.peerInfo.IsEphemeral = false
 	ch.log = ch.log.WithFields(LogField{"hostPort", mutable.peerInfo.HostPort})
 
@@ -293,12 +700,14 @@ func (ch *Channel) Serve(l net.Listener) error {
 	ch.log.WithFields(
 		LogField{"hostPort", peerInfo.HostPort},
 	).Info("Channel is listening.")
-	go ch.serve()
+	go ch.serve(mutable.l, "" /* hostPort */)
 	return nil
 }
 
 // ListenAndServe listens on the given address and serves incoming requests.
 // The port may be 0, in which case the channel will use an OS assigned port
+// The address may also be a unix domain socket, such as unix:///path/to/sock,
+// or unix://@name for an abstract socket on Linux.
 // This method does not block as the handling of connections is done in a goroutine.
 func (ch *Channel) ListenAndServe(hostPort string) error {
 	mutable := &ch.mutable
@@ -309,7 +718,7 @@ func (ch *Channel) ListenAndServe(hostPort string) error {
 		return errAlreadyListening
 	}
 
-	l, err := net.Listen("tcp", hostPort)
+	l, err := net.Listen(networkAddress(hostPort))
 	if err != nil {
 		mutable.RUnlock()
 		return err
@@ -359,6 +768,15 @@ func (ch *Channel) Register(h Handler, methodName string) {
 	ch.GetSubChannel(ch.PeerInfo().ServiceName).Register(h, methodName)
 }
 
+// Unregister removes the handler registered for the given method on the
+// channel's service, and returns whether there was a handler registered.
+func (ch *Channel) Unregister(methodName string) bool {
+	if _, ok := ch.handler.(channelHandler); !ok {
+		panic("can't unregister handler when channel configured with alternate root handler")
+	}
+	return ch.GetSubChannel(ch.PeerInfo().ServiceName).Unregister(methodName)
+}
+
 // PeerInfo returns the current peer info for the channel
 func (ch *Channel) PeerInfo() LocalPeerInfo {
 	ch.mutable.RLock()
@@ -415,12 +833,13 @@ func (ch *Channel) BeginCall(ctx context.Context, hostPort, serviceName, methodN
 }
 
 // serve runs the listener to accept and manage new incoming connections, blocking
-// until the channel is closed.
-func (ch *Channel) serve() {
+// until the channel is closed. If hostPort is set, it is sent to peers instead
+// of the channel's host:port.
+func (ch *Channel) serve(l net.Listener, hostPort string) {
 	acceptBackoff := 0 * time.Millisecond
 
 	for {
-		netConn, err := ch.mutable.l.Accept()
+		netConn, err := l.Accept()
 		if err != nil {
 			// Backoff from new accepts if this is a temporary error
 			if ne, ok := err.(net.Error); ok && ne.Temporary() {
@@ -439,8 +858,9 @@ func (ch *Channel) serve() {
 				time.Sleep(acceptBackoff)
 				continue
 			} else {
-				// Only log an error if this didn't happen due to a Close.
-				if ch.State() >= ChannelStartClose {
+				// Only log an error if this didn't happen due to a Close
+				// or the listener being replaced by Rebind.
+				if ch.State() >= ChannelStartClose || !ch.isListener(l) {
 					return
 				}
 				ch.log.WithFields(ErrField(err)).Fatal("Unrecoverable accept error, closing server.")
@@ -458,8 +878,22 @@ func (ch *Channel) serve() {
 				OnCloseStateChange: ch.connectionCloseStateChange,
 				OnExchangeUpdated:  ch.exchangeUpdated,
 			}
-			if _, err := ch.inboundHandshake(context.Background(), netConn, events); err != nil {
-				netConn.Close()
+			conn := ch.tlsServer(netConn)
+			if ch.http2Handler != nil || ch.httpHandler != nil {
+				var protocol connProtocol
+				conn, protocol = sniffProtocol(conn)
+				switch {
+				case protocol == protocolHTTP2 && ch.http2Handler != nil:
+					ch.serveHTTP2(conn)
+					return
+				case protocol == protocolHTTP && ch.httpHandler != nil:
+					ch.serveHTTP(conn)
+					return
+				}
+			}
+			ch.observeNetConn(ConnectionConnected, inbound, conn, nil)
+			if _, err := ch.inboundHandshake(context.Background(), conn, hostPort, events); err != nil {
+				conn.Close()
 			}
 		}()
 	}
@@ -473,7 +907,11 @@ func (ch *Channel) Ping(ctx context.Context, hostPort string) error {
 		return err
 	}
 
-	return conn.ping(ctx)
+	if err := conn.ping(ctx); err != nil {
+		conn.observe(ConnectionEvent{Type: ConnectionHealthCheckFailed, Err: err})
+		return err
+	}
+	return nil
 }
 
 // Logger returns the logger for this channel.
@@ -503,6 +941,11 @@ func (ch *Channel) ServiceName() string {
 
 // Connect creates a new outbound connection to hostPort.
 func (ch *Channel) Connect(ctx context.Context, hostPort string) (*Connection, error) {
+	return ch.connect(ctx, hostPort, ch.connectionOptions)
+}
+
+// connect creates a new outbound connection to hostPort using opts.
+func (ch *Channel) connect(ctx context.Context, hostPort string, opts ConnectionOptions) (*Connection, error) {
 	switch state := ch.State(); state {
 	case ChannelClient, ChannelListening:
 		break
@@ -519,6 +962,14 @@ func (ch *Channel) Connect(ctx context.Context, hostPort string) (*Connection, e
 		defer cancel()
 	}
 
+	// The channel's dial timeout caps connection establishment, so a dead
+	// peer is detected quickly even when the call has a long deadline.
+	if ch.dialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ch.dialTimeout)
+		defer cancel()
+	}
+
 	events := connectionEvents{
 		OnActive:           ch.outboundConnectionActive,
 		OnCloseStateChange: ch.connectionCloseStateChange,
@@ -530,7 +981,7 @@ func (ch *Channel) Connect(ctx context.Context, hostPort string) (*Connection, e
 	}
 
 	timeout := getTimeout(ctx)
-	tcpConn, err := dialContext(ctx, hostPort)
+	tcpConn, err := dialContext(ctx, ch.dialer, hostPort)
 	if err != nil {
 		if ne, ok := err.(net.Error); ok && ne.Timeout() {
 			ch.log.WithFields(
@@ -552,7 +1003,8 @@ func (ch *Channel) Connect(ctx context.Context, hostPort string) (*Connection, e
 		return nil, err
 	}
 
-	conn, err := ch.outboundHandshake(ctx, tcpConn, hostPort, events)
+	ch.observeNetConn(ConnectionConnected, outbound, tcpConn, nil)
+	conn, err := ch.outboundHandshake(ctx, ch.tlsClient(tcpConn, hostPort), hostPort, opts, events)
 	if conn != nil {
 		// It's possible that the connection we just created responds with a host:port
 		// that is not what we tried to connect to. E.g., we may have connected to
@@ -722,6 +1174,12 @@ func (ch *Channel) connectionCloseStateChange(c *Connection) {
 }
 
 func (ch *Channel) onClosed() {
+	ch.mutable.Lock()
+	if ch.mutable.drainTimer != nil {
+		ch.mutable.drainTimer.Stop()
+	}
+	ch.mutable.Unlock()
+
 	removeClosedChannel(ch)
 	ch.log.Infof("Channel closed.")
 }
@@ -740,18 +1198,51 @@ func (ch *Channel) State() ChannelState {
 	return state
 }
 
+// OnClose registers f to be called when Close is first called, before the
+// channel starts closing connections, so f can make calls to other services,
+// such as to remove the channel from service discovery.
+func (ch *Channel) OnClose(f func()) {
+	ch.mutable.Lock()
+	ch.mutable.onClose = append(ch.mutable.onClose, f)
+	ch.mutable.Unlock()
+}
+
+func (ch *Channel) callOnClose() {
+	ch.mutable.Lock()
+	onClose := ch.mutable.onClose
+	ch.mutable.onClose = nil
+	ch.mutable.Unlock()
+
+	for _, f := range onClose {
+		f()
+	}
+}
+
 // Close starts a graceful Close for the channel. This does not happen immediately:
 // 1. This call closes the Listener and starts closing connections.
 // 2. When all incoming connections are drained, the connection blocks new outgoing calls.
 // 3. When all connections are drained, the channel's state is updated to Closed.
+// New incoming calls are rejected with ErrChannelClosed, which callers can retry
+// on other peers. If DrainTimeout is set and connections are not drained in time,
+// the remaining calls fail and the connections are closed.
 func (ch *Channel) Close() {
 	ch.Logger().Info("Channel.Close called.")
+	ch.callOnClose()
+	ch.savePeerCache()
+
 	var connections []*Connection
 	var channelClosed bool
 	ch.mutable.Lock()
 
-	if ch.mutable.l != nil {
-		ch.mutable.l.Close()
+	ch.closeListenersLocked()
+	ch.closeHTTP2ConnsLocked()
+	ch.closeHTTPLocked()
+
+	if ch.mutable.sweepTimer != nil {
+		ch.mutable.sweepTimer.Stop()
+	}
+	if ch.mutable.statsTimer != nil {
+		ch.mutable.statsTimer.Stop()
 	}
 
 	ch.mutable.state = ChannelStartClose
@@ -762,8 +1253,12 @@ func (ch *Channel) Close() {
 	for _, c := range ch.mutable.conns {
 		connections = append(connections, c)
 	}
+	if !channelClosed && ch.drainTimeout > 0 && ch.mutable.drainTimer == nil {
+		ch.mutable.drainTimer = time.AfterFunc(ch.drainTimeout, ch.drainTimedOut)
+	}
 	ch.mutable.Unlock()
 
+	ch.closePeerReconnects()
 	for _, c := range connections {
 		c.close(LogField{"reason", "channel closing"})
 	}
@@ -773,6 +1268,42 @@ func (ch *Channel) Close() {
 	}
 }
 
+// drainTimedOut is called if the channel's connections were not drained within
+// the drain timeout after Close was called.
+func (ch *Channel) drainTimedOut() {
+	var connections []*Connection
+	ch.mutable.RLock()
+	for _, c := range ch.mutable.conns {
+		connections = append(connections, c)
+	}
+	ch.mutable.RUnlock()
+
+	if len(connections) == 0 {
+		return
+	}
+
+	ch.log.WithFields(
+		LogField{"drainTimeout", ch.drainTimeout},
+		LogField{"numConnections", len(connections)},
+	).Warn("Channel was not drained before the drain timeout, closing connections.")
+	for _, c := range connections {
+		c.abort(errDrainTimeout)
+	}
+}
+
+// initPeer sets up the per-peer circuit breaker, rate limiter, connection
+// pool and error wrapping for a new peer.
+func (ch *Channel) initPeer(p *Peer) {
+	p.circuit = ch.newPeerCircuitBreaker(p.HostPort())
+	p.reconnect = newReconnector(ch.reconnect, p, ch.log)
+	p.rateLimiter = ch.newPeerRateLimiter(p.HostPort())
+	p.pool = newConnPool(ch.connectionPool)
+	p.detailedErrors = ch.detailedErrors
+	if len(ch.outboundInterceptors) > 0 {
+		p.interceptedBeginCall = chainOutboundInterceptors(p.beginCall, ch.outboundInterceptors)
+	}
+}
+
 // RelayHost returns the channel's RelayHost, if any.
 func (ch *Channel) RelayHost() RelayHost {
 	return ch.relayHost
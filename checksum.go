@@ -21,6 +21,8 @@
 package tchannel
 
 import (
+	"encoding/binary"
+	"fmt"
 	"hash"
 	"hash/crc32"
 	"sync"
@@ -41,7 +43,8 @@ const (
 	// ChecksumTypeFarmhash indicates the message checksum is calculated using Farmhash
 	ChecksumTypeFarmhash ChecksumType = 2
 
-	// ChecksumTypeCrc32C indicates the message checksum is calculated using crc32c
+	// ChecksumTypeCrc32C indicates the message checksum is calculated using crc32c,
+	// which uses hardware acceleration where it is available.
 	ChecksumTypeCrc32C ChecksumType = 3
 
 	checksumCount = 4
@@ -59,13 +62,32 @@ func init() {
 	ChecksumTypeCrc32C.pool().New = func() interface{} {
 		return newHashChecksum(ChecksumTypeCrc32C, crc32.New(crc32CastagnoliTable))
 	}
-
-	// TODO: Implement farm hash.
 	ChecksumTypeFarmhash.pool().New = func() interface{} {
-		return nullChecksum{}
+		return &farmhashChecksum{}
+	}
+}
+
+// String returns the name of the checksum type.
+func (t ChecksumType) String() string {
+	switch t {
+	case ChecksumTypeNone:
+		return "none"
+	case ChecksumTypeCrc32:
+		return "crc32"
+	case ChecksumTypeFarmhash:
+		return "farmhash"
+	case ChecksumTypeCrc32C:
+		return "crc32c"
+	default:
+		return fmt.Sprintf("ChecksumType(%d)", byte(t))
 	}
 }
 
+// valid returns whether the checksum type is supported.
+func (t ChecksumType) valid() bool {
+	return t < checksumCount
+}
+
 // ChecksumSize returns the size in bytes of the checksum calculation
 func (t ChecksumType) ChecksumSize() int {
 	switch t {
@@ -173,3 +195,66 @@ func (h *hashChecksum) Release() { h.TypeCode().Release(h) }
 
 // Reset resets the checksum state to the default 0 value.
 func (h *hashChecksum) Reset() { h.hash.Reset() }
+
+// Farmhash Checksum, which chains the 32-bit Farmhash of each chunk of data,
+// using the previous checksum as the seed.
+type farmhashChecksum struct {
+	sum      uint32
+	sumCache [4]byte
+}
+
+// TypeCode returns the type of the checksum
+func (f *farmhashChecksum) TypeCode() ChecksumType { return ChecksumTypeFarmhash }
+
+// Size returns the size of the checksum data
+func (f *farmhashChecksum) Size() int { return 4 }
+
+// Add adds a byte slice to the checksum calculation
+func (f *farmhashChecksum) Add(b []byte) []byte {
+	f.sum = farmHash32WithSeed(b, f.sum)
+	return f.Sum()
+}
+
+// Sum returns the current value of the checksum calculation
+func (f *farmhashChecksum) Sum() []byte {
+	binary.BigEndian.PutUint32(f.sumCache[:], f.sum)
+	return f.sumCache[:]
+}
+
+// Release puts a Checksum back in the pool.
+func (f *farmhashChecksum) Release() { f.TypeCode().Release(f) }
+
+// Reset resets the checksum state to the default 0 value.
+func (f *farmhashChecksum) Reset() { f.sum = 0 }
+
+// verifyChecksums sets up a reader to verify checksums using the connection's
+// checksum options, calling onError if a checksum is rejected.
+func (c *Connection) verifyChecksums(r *fragmentingReader, onError func(error, ChecksumType)) {
+	r.strictChecksums = c.opts.StrictChecksums
+	r.requiredChecksum = c.opts.ChecksumType
+	r.onChecksumError = onError
+}
+
+// checksumFailed logs and counts a message that was rejected due to its
+// checksum.
+func (c *Connection) checksumFailed(err error, checksumType ChecksumType) {
+	counter := "checksum.rejected"
+	if err == errMismatchedChecksums {
+		counter = "checksum.mismatches"
+	}
+	tags := cloneTags(c.commonStatsTags)
+	tags["checksum-type"] = checksumType.String()
+	c.statsReporter.IncCounter(counter, tags, 1)
+
+	c.log.WithFields(
+		LogField{"remotePeer", c.remotePeerInfo},
+		LogField{"checksumType", checksumType.String()},
+		ErrField(err),
+	).Warn("Rejected message due to its checksum.")
+}
+
+// rejectChecksum rejects an inbound call whose checksum was rejected.
+func (call *InboundCall) rejectChecksum(err error, checksumType ChecksumType) {
+	call.conn.checksumFailed(err, checksumType)
+	call.Response().SendSystemError(err)
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func checksumOpts(checksumType ChecksumType) *testutils.ChannelOpts {
+	opts := testutils.NewOpts()
+	opts.DefaultConnectionOptions.ChecksumType = checksumType
+	opts.DefaultConnectionOptions.DisableChecksums = checksumType == ChecksumTypeNone
+	return opts
+}
+
+func TestChecksumTypes(t *testing.T) {
+	for _, checksumType := range []ChecksumType{ChecksumTypeNone, ChecksumTypeCrc32, ChecksumTypeFarmhash, ChecksumTypeCrc32C} {
+		t.Run(checksumType.String(), func(t *testing.T) {
+			opts := checksumOpts(checksumType)
+			testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+				testutils.RegisterEcho(ts.Server(), nil)
+				client := ts.NewClient(checksumOpts(checksumType))
+
+				arg3 := testutils.RandBytes(3 * MaxFramePayloadSize)
+				ctx, cancel := NewContext(testutils.Timeout(time.Second))
+				defer cancel()
+				_, res, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "echo", []byte("arg2"), arg3)
+				require.NoError(t, err, "call failed")
+				assert.Equal(t, arg3, res, "unexpected response")
+			})
+		})
+	}
+}
+
+func TestFarmhashChecksumGolden(t *testing.T) {
+	// Expected values are farmhashmk::Hash32WithSeed from the reference
+	// farmhash implementation, which is what other TChannel languages use.
+	// Lengths cover each of the 0-4, 5-12, 13-24 and >24 byte branches.
+	data := []byte("The quick brown fox jumps over the lazy dog. \xff\xfe\x80\x7f tchannel farmhash 0123456789 abcdefghijklmnopqrstuvwxyz")
+	tests := []struct {
+		msg      string
+		seeded   bool
+		input    []byte
+		expected uint32
+	}{
+		{"empty", false, data[:0], 0xdc56d17a},
+		{"len 1", false, data[:1], 0xee554ba3},
+		{"len 3", false, data[:3], 0x07e51322},
+		{"len 4", false, data[:4], 0x0ea5d26a},
+		{"len 4 high bytes", false, data[45:49], 0xf1793ffa},
+		{"len 5", false, data[:5], 0xd42d0854},
+		{"len 8", false, data[:8], 0x189b8630},
+		{"len 12", false, data[:12], 0xb36c96b8},
+		{"len 13", false, data[:13], 0x1d26ad46},
+		{"len 17", false, data[:17], 0x873b350b},
+		{"len 24", false, data[:24], 0xca601003},
+		{"len 25", false, data[:25], 0xe29d1dab},
+		{"len 44", false, data[:44], 0x170ef443},
+		{"len 45", false, data[:45], 0x79d135b3},
+		{"len 100", false, data[:100], 0xb412626a},
+		{"seeded empty", true, data[:0], 0x85ed07cc},
+		{"seeded len 1", true, data[:1], 0x38017e6a},
+		{"seeded len 3", true, data[:3], 0x88eaa093},
+		{"seeded len 4", true, data[:4], 0xdb59f859},
+		{"seeded len 4 high bytes", true, data[45:49], 0x217d9f1f},
+		{"seeded len 5", true, data[:5], 0x4ac5935a},
+		{"seeded len 8", true, data[:8], 0x97f1c7db},
+		{"seeded len 12", true, data[:12], 0x3df3cfe2},
+		{"seeded len 13", true, data[:13], 0x13d5b6c4},
+		{"seeded len 17", true, data[:17], 0xf91f6dd2},
+		{"seeded len 24", true, data[:24], 0xb4ab5c59},
+		{"seeded len 25", true, data[:25], 0x3fda2868},
+		{"seeded len 44", true, data[:44], 0x1d50e4f3},
+		{"seeded len 45", true, data[:45], 0x88de8e95},
+		{"seeded len 100", true, data[:100], 0x125c3d50},
+	}
+
+	for _, tt := range tests {
+		checksum := ChecksumTypeFarmhash.New()
+		if tt.seeded {
+			// The checksum chains chunks, so the first chunk's hash (0x88dd74c2)
+			// is used as the seed for the second chunk.
+			checksum.Add([]byte("tchannel"))
+		}
+		got := binary.BigEndian.Uint32(checksum.Add(tt.input))
+		assert.Equal(t, tt.expected, got, "%v: unexpected checksum, got %#08x", tt.msg, got)
+		checksum.Release()
+	}
+}
+
+func TestStrictChecksums(t *testing.T) {
+	stats := newRecordingStatsReporter()
+	opts := checksumOpts(ChecksumTypeCrc32C).
+		SetStatsReporter(stats).
+		AddLogFilter("Rejected message due to its checksum.", 1).
+		AddLogFilter("Couldn't read method.", 1)
+	opts.DefaultConnectionOptions.StrictChecksums = true
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		testutils.RegisterEcho(ts.Server(), nil)
+		stats.Reset()
+
+		call := func(client *Channel) error {
+			ctx, cancel := NewContext(testutils.Timeout(time.Second))
+			defer cancel()
+			_, _, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "echo", nil, nil)
+			return err
+		}
+
+		err := call(ts.NewClient(checksumOpts(ChecksumTypeCrc32)))
+		assert.Equal(t, ErrCodeBadRequest, GetSystemErrorCode(err), "expected calls with other checksums to be rejected, got %v", err)
+		assert.NoError(t, call(ts.NewClient(checksumOpts(ChecksumTypeCrc32C))), "calls with the required checksum should succeed")
+
+		var rejected int64
+		stats.Lock()
+		for _, v := range stats.Values["checksum.rejected"] {
+			rejected += v.count
+		}
+		stats.Unlock()
+		assert.EqualValues(t, 1, rejected, "unexpected number of rejected calls")
+	})
+}
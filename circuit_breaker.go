@@ -0,0 +1,208 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"sync"
+	"time"
+
+	"github.com/uber-go/atomic"
+)
+
+const defaultCircuitOpenDuration = 10 * time.Second
+
+// CircuitBreakerOptions configures the per-peer circuit breaker, which stops
+// selecting a peer from peer lists after consecutive failed calls to it.
+type CircuitBreakerOptions struct {
+	// ConsecutiveFailures is the number of consecutive failed calls to a peer
+	// after which the peer's circuit is opened. Calls fail if they time out,
+	// the peer cannot be connected to, or the peer returns a system error
+	// other than a bad request. Application errors and cancelled calls are
+	// not failures. Zero disables the circuit breaker.
+	ConsecutiveFailures int
+
+	// OpenDuration is how long a peer's circuit stays open before the peer
+	// can be selected again for a single probe call. If the probe succeeds,
+	// the circuit is closed, otherwise it is opened again. If this is 0,
+	// the default of 10 seconds is used.
+	OpenDuration time.Duration
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+func (s circuitState) String() string {
+	switch s {
+	case circuitClosed:
+		return "closed"
+	case circuitOpen:
+		return "open"
+	case circuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// circuitBreaker tracks the results of calls to a single peer. A nil
+// circuitBreaker is disabled, and allows all calls.
+type circuitBreaker struct {
+	sync.Mutex
+
+	opts          CircuitBreakerOptions
+	timeNow       func() time.Time
+	onStateChange func(from, to circuitState)
+
+	state    circuitState
+	failures int
+	// changedAt is when the circuit was last opened, or when the current
+	// probe was started if the circuit is half-open.
+	changedAt time.Time
+}
+
+func newCircuitBreaker(opts CircuitBreakerOptions, timeNow func() time.Time, onStateChange func(from, to circuitState)) *circuitBreaker {
+	if opts.ConsecutiveFailures <= 0 {
+		return nil
+	}
+	if opts.OpenDuration <= 0 {
+		opts.OpenDuration = defaultCircuitOpenDuration
+	}
+	return &circuitBreaker{
+		opts:          opts,
+		timeNow:       timeNow,
+		onStateChange: onStateChange,
+	}
+}
+
+// allowSelection returns whether the peer can be selected. If the circuit
+// has been open for long enough, it becomes half-open and the caller is
+// allowed to make a single probe call.
+func (cb *circuitBreaker) allowSelection() bool {
+	if cb == nil {
+		return true
+	}
+
+	cb.Lock()
+	defer cb.Unlock()
+
+	if cb.state == circuitClosed {
+		return true
+	}
+
+	// If a probe does not report a result (e.g. the selected peer is never
+	// called), another probe is allowed after OpenDuration.
+	now := cb.timeNow()
+	if now.Sub(cb.changedAt) < cb.opts.OpenDuration {
+		return false
+	}
+
+	cb.changedAt = now
+	cb.setStateLocked(circuitHalfOpen)
+	return true
+}
+
+// recordResult records the result of a call to the peer.
+func (cb *circuitBreaker) recordResult(err error) {
+	if cb == nil {
+		return
+	}
+
+	cb.Lock()
+	defer cb.Unlock()
+
+	if err == nil {
+		cb.failures = 0
+		cb.setStateLocked(circuitClosed)
+		return
+	}
+
+	if !isCircuitFailure(err) {
+		if cb.state == circuitHalfOpen {
+			// The probe was inconclusive, so allow another probe.
+			cb.changedAt = time.Time{}
+		}
+		return
+	}
+
+	cb.failures++
+	if cb.state == circuitHalfOpen || cb.failures >= cb.opts.ConsecutiveFailures {
+		cb.changedAt = cb.timeNow()
+		cb.setStateLocked(circuitOpen)
+	}
+}
+
+// callRecorder returns a function that records only the first result it is
+// passed, so a call that fails in multiple places is only recorded once.
+func (cb *circuitBreaker) callRecorder() func(error) {
+	if cb == nil {
+		return nil
+	}
+
+	var recorded atomic.Bool
+	return func(err error) {
+		if recorded.Swap(true) {
+			return
+		}
+		cb.recordResult(err)
+	}
+}
+
+func (cb *circuitBreaker) setStateLocked(state circuitState) {
+	if cb.state == state {
+		return
+	}
+
+	prev := cb.state
+	cb.state = state
+	if cb.onStateChange != nil {
+		cb.onStateChange(prev, state)
+	}
+}
+
+// isCircuitFailure returns whether a call error indicates a problem with the peer.
+func isCircuitFailure(err error) bool {
+	switch getErrCode(err) {
+	case ErrCodeBadRequest, ErrCodeCancelled:
+		return false
+	}
+	return true
+}
+
+// newPeerCircuitBreaker returns the circuit breaker for a new peer, which
+// reports state changes using the channel's logger and stats reporter.
+func (ch *Channel) newPeerCircuitBreaker(hostPort string) *circuitBreaker {
+	return newCircuitBreaker(ch.circuitBreaker, ch.timeNow, func(from, to circuitState) {
+		ch.log.WithFields(
+			LogField{"hostPort", hostPort},
+			LogField{"from", from.String()},
+			LogField{"to", to.String()},
+		).Info("Peer circuit breaker state changed.")
+
+		tags := ch.StatsTags()
+		tags["peer"] = hostPort
+		tags["state"] = to.String()
+		ch.statsReporter.IncCounter("peer.circuit-breaker.state-change", tags, 1)
+	})
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/atomic"
+)
+
+// newFlakyServer returns a server whose "flaky" method returns res while
+// healthy is false, and succeeds otherwise.
+func newFlakyServer(t *testing.T, healthy *atomic.Bool, res *raw.Res) *Channel {
+	server := testutils.NewServer(t, nil)
+	testutils.RegisterFunc(server, "flaky", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		if healthy.Load() {
+			return &raw.Res{}, nil
+		}
+		return res, nil
+	})
+	return server
+}
+
+func TestCircuitBreakerEjectsFailingPeer(t *testing.T) {
+	openDuration := testutils.Timeout(200 * time.Millisecond)
+
+	var badHealthy, goodHealthy atomic.Bool
+	goodHealthy.Store(true)
+	bad := newFlakyServer(t, &badHealthy, &raw.Res{SystemErr: ErrServerBusy})
+	defer bad.Close()
+	good := newFlakyServer(t, &goodHealthy, nil)
+	defer good.Close()
+
+	stats := newRecordingStatsReporter()
+	opts := testutils.NewOpts().SetStatsReporter(stats)
+	opts.CircuitBreaker = CircuitBreakerOptions{
+		ConsecutiveFailures: 3,
+		OpenDuration:        openDuration,
+	}
+	client := testutils.NewClient(t, opts)
+	defer client.Close()
+
+	sc := client.GetSubChannel(bad.ServiceName())
+	call := func() error {
+		ctx, cancel := NewContext(testutils.Timeout(time.Second))
+		defer cancel()
+		_, _, _, err := raw.CallSC(ctx, sc, "flaky", nil, nil)
+		return err
+	}
+
+	sc.Peers().Add(bad.PeerInfo().HostPort)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, ErrServerBusy, call(), "Expected call %v to fail with busy", i)
+	}
+	assert.Equal(t, ErrCircuitOpen, call(), "Failing peer should not be selected")
+
+	sc.Peers().Add(good.PeerInfo().HostPort)
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, call(), "Calls should only be made to the healthy peer")
+	}
+	require.NoError(t, sc.Peers().Remove(good.PeerInfo().HostPort), "Remove failed")
+
+	// After the open duration, a single failed probe opens the circuit again.
+	time.Sleep(openDuration)
+	assert.Equal(t, ErrServerBusy, call(), "Expected probe to be made to failing peer")
+	assert.Equal(t, ErrCircuitOpen, call(), "Circuit should open after a failed probe")
+
+	// A successful probe closes the circuit.
+	badHealthy.Store(true)
+	time.Sleep(openDuration)
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, call(), "Calls should succeed once the circuit is closed")
+	}
+
+	var stateChanges int64
+	stats.Lock()
+	for _, v := range stats.Values["peer.circuit-breaker.state-change"] {
+		stateChanges += v.count
+	}
+	stats.Unlock()
+	// open, half-open, open, half-open, closed
+	assert.EqualValues(t, 5, stateChanges, "Unexpected number of state changes")
+}
+
+func TestCircuitBreakerIgnoresNonPeerErrors(t *testing.T) {
+	var healthy atomic.Bool
+	server := newFlakyServer(t, &healthy, &raw.Res{IsErr: true})
+	defer server.Close()
+
+	opts := testutils.NewOpts()
+	opts.CircuitBreaker = CircuitBreakerOptions{ConsecutiveFailures: 1}
+	client := testutils.NewClient(t, opts)
+	defer client.Close()
+
+	sc := client.GetSubChannel(server.ServiceName())
+	sc.Peers().Add(server.PeerInfo().HostPort)
+
+	for i := 0; i < 3; i++ {
+		ctx, cancel := NewContext(testutils.Timeout(time.Second))
+		_, _, res, err := raw.CallSC(ctx, sc, "flaky", nil, nil)
+		cancel()
+		require.NoError(t, err, "Application errors should not open the circuit")
+		assert.True(t, res.ApplicationError(), "Expected application error")
+	}
+
+	ctx, cancel := NewContext(testutils.Timeout(time.Second))
+	cancel()
+	_, _, _, err := raw.CallSC(ctx, sc, "flaky", nil, nil)
+	assert.Error(t, err, "Call with cancelled context should fail")
+
+	_, err = sc.Peers().Get(nil)
+	assert.NoError(t, err, "Peer should still be selectable")
+}
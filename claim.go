@@ -0,0 +1,107 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+// addCall adds an attempt's call, which is removed once the call completes.
+// Once any call's peer starts responding, the other calls are claimed.
+func (h *hedgedPeers) addCall(call *OutboundCall) {
+	if h == nil {
+		return
+	}
+
+	h.Lock()
+	h.calls[call] = struct{}{}
+	h.Unlock()
+
+	remove := func(error) { h.removeCall(call) }
+	call.onFailed = chainCallback(call.onFailed, remove)
+	call.response.onFailed = chainCallback(call.response.onFailed, remove)
+	call.response.onDone = chainCallback(call.response.onDone, remove)
+	call.response.onStarted = func() { h.claim(call) }
+}
+
+func (h *hedgedPeers) removeCall(call *OutboundCall) {
+	h.Lock()
+	delete(h.calls, call)
+	h.Unlock()
+}
+
+// claim sends claim frames for all in-flight calls other than winner, since
+// their responses will not be used.
+func (h *hedgedPeers) claim(winner *OutboundCall) {
+	h.Lock()
+	if h.claimed {
+		h.Unlock()
+		return
+	}
+	h.claimed = true
+	var losers []*OutboundCall
+	for call := range h.calls {
+		if call != winner {
+			losers = append(losers, call)
+		}
+	}
+	h.Unlock()
+
+	for _, call := range losers {
+		call.claim()
+	}
+}
+
+// claim tells the peer that another attempt of the call has been claimed, if
+// the peer advertised support for claim frames. Otherwise, the call is left to
+// be cancelled once the hedged request completes.
+func (call *OutboundCall) claim() {
+	if !call.conn.supportsCallControl(messageTypeClaim) || call.abandoned.Swap(true) {
+		return
+	}
+
+	call.conn.sendCallControl(&claimMessage{
+		id:         call.callReq.id,
+		TimeToLive: call.remainingTTL(),
+		Tracing:    call.callReq.Tracing,
+	}, "outbound.calls.claims")
+}
+
+// handleClaim handles a claim frame from the peer by cancelling the context of
+// the inbound call it refers to, as another peer is handling the call.
+func (c *Connection) handleClaim(frame *Frame) {
+	var msg claimMessage
+	if err := frame.read(&msg); err != nil {
+		c.log.WithFields(
+			LogField{"remotePeer", c.remotePeerInfo},
+			ErrField(err),
+		).Warn("Unable to read claim frame.")
+		return
+	}
+
+	cancel := c.inbound.cancelFunc(frame.Header.ID)
+	if cancel == nil {
+		// The call may have already completed or timed out.
+		if c.log.Enabled(LogLevelDebug) {
+			c.log.Debugf("Ignoring claim for unknown inbound call %v", frame.Header.ID)
+		}
+		return
+	}
+
+	c.statsReporter.IncCounter("inbound.calls.claimed", c.commonStatsTags, 1)
+	cancel()
+}
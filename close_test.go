@@ -21,6 +21,7 @@
 package tchannel_test
 
 import (
+	"context"
 	"math/rand"
 	"sync"
 	"testing"
@@ -34,7 +35,6 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/uber-go/atomic"
-	"golang.org/x/net/context"
 )
 
 type channelState struct {
@@ -104,6 +104,43 @@ func TestCloseAfterTimeout(t *testing.T) {
 	})
 }
 
+func TestCloseDrainTimeout(t *testing.T) {
+	opts := testutils.NewOpts().
+		NoRelay().
+		SetDrainTimeout(testutils.Timeout(50*time.Millisecond)).
+		AddLogFilter("Channel was not drained before the drain timeout", 1)
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		gotCall := make(chan struct{})
+		ts.Register(HandlerFunc(func(ctx context.Context, call *InboundCall) {
+			close(gotCall)
+			<-ctx.Done()
+		}), "block")
+
+		clientCh := ts.NewClient(testutils.NewOpts().DisableLogVerification())
+		callErr := make(chan error, 1)
+		go func() {
+			ctx, cancel := NewContext(testutils.Timeout(time.Second))
+			defer cancel()
+			_, _, _, err := raw.Call(ctx, clientCh, ts.HostPort(), ts.ServiceName(), "block", nil, nil)
+			callErr <- err
+		}()
+
+		<-gotCall
+		ts.Server().Close()
+		assert.Equal(t, ChannelStartClose, ts.Server().State(), "Channel should wait for the in-flight call")
+
+		// Once the drain timeout expires, the connection is closed even though
+		// the call has not completed.
+		assertStateChangesTo(t, ts.Server(), ChannelClosed)
+		select {
+		case err := <-callErr:
+			assert.Error(t, err, "Call should fail when the server closes the connection")
+		case <-time.After(testutils.Timeout(time.Second)):
+			t.Fatalf("Call did not fail after the drain timeout")
+		}
+	})
+}
+
 func TestRaceExchangesWithClose(t *testing.T) {
 	var wg sync.WaitGroup
 
@@ -0,0 +1,232 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/thrift"
+
+	"github.com/samuel/go-thrift/parser"
+)
+
+// codec encodes the arguments and decodes the response of a call.
+type codec interface {
+	format() tchannel.Format
+	encode(headers, body []byte) (arg2, arg3 []byte, err error)
+	decode(arg2, arg3 []byte) (headers, body interface{}, err error)
+}
+
+// callResult is the output of a call.
+type callResult struct {
+	OK      bool        `json:"ok"`
+	Headers interface{} `json:"headers"`
+	Body    interface{} `json:"body"`
+}
+
+func runCall(args []string, out io.Writer) error {
+	var pf peerFlags
+	fs := newFlagSet("call", &pf)
+	service := fs.String("service", "", "The service to call")
+	method := fs.String("method", "", "The method to call, of the form Service::method for thrift")
+	encoding := fs.String("encoding", "raw", "The encoding of the call: raw, json or thrift")
+	thriftFile := fs.String("thrift", "", "The Thrift IDL file for the method, required for the thrift encoding")
+	headers := fs.String("headers", "", "The headers (arg2), as a JSON object of strings for json and thrift")
+	body := fs.String("body", "", "The body (arg3), as JSON for json and thrift. Use - to read it from stdin")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := pf.validate(); err != nil {
+		return err
+	}
+	if *service == "" || *method == "" {
+		return errors.New("-service and -method are required")
+	}
+
+	c, err := newCodec(*encoding, *thriftFile, *method)
+	if err != nil {
+		return err
+	}
+
+	arg3 := []byte(*body)
+	if *body == "-" {
+		if arg3, err = ioutil.ReadAll(os.Stdin); err != nil {
+			return err
+		}
+	}
+	arg2, arg3, err := c.encode([]byte(*headers), arg3)
+	if err != nil {
+		return err
+	}
+
+	ch, err := newClient()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	ctx, cancel := pf.context()
+	defer cancel()
+
+	call, err := ch.BeginCall(ctx, pf.peer, *service, *method, &tchannel.CallOptions{Format: c.format()})
+	if err != nil {
+		return err
+	}
+	resArg2, resArg3, res, err := raw.WriteArgs(call, arg2, arg3)
+	if err != nil {
+		return err
+	}
+
+	result := callResult{OK: !res.ApplicationError()}
+	if result.Headers, result.Body, err = c.decode(resArg2, resArg3); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	return writeJSON(out, result)
+}
+
+func newCodec(encoding, thriftFile, method string) (codec, error) {
+	switch encoding {
+	case "raw":
+		return rawCodec{}, nil
+	case "json":
+		return jsonCodec{}, nil
+	case "thrift":
+		if thriftFile == "" {
+			return nil, errors.New("-thrift is required for the thrift encoding")
+		}
+		idl, err := parseIDL(thriftFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %v: %v", thriftFile, err)
+		}
+		file, m, err := idl.method(method)
+		if err != nil {
+			return nil, err
+		}
+		return thriftCodec{idl, file, m}, nil
+	}
+	return nil, fmt.Errorf("unknown encoding %q", encoding)
+}
+
+type rawCodec struct{}
+
+func (rawCodec) format() tchannel.Format { return tchannel.Raw }
+
+func (rawCodec) encode(headers, body []byte) ([]byte, []byte, error) {
+	return headers, body, nil
+}
+
+func (rawCodec) decode(arg2, arg3 []byte) (interface{}, interface{}, error) {
+	return string(arg2), string(arg3), nil
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) format() tchannel.Format { return tchannel.JSON }
+
+func (jsonCodec) encode(headers, body []byte) ([]byte, []byte, error) {
+	if len(headers) == 0 {
+		headers = []byte("{}")
+	}
+	if len(body) == 0 {
+		body = []byte("{}")
+	}
+	if _, err := parseHeaders(headers); err != nil {
+		return nil, nil, err
+	}
+	if !json.Valid(body) {
+		return nil, nil, errors.New("body is not valid JSON")
+	}
+	return headers, body, nil
+}
+
+func (jsonCodec) decode(arg2, arg3 []byte) (interface{}, interface{}, error) {
+	headers, err := parseHeaders(arg2)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !json.Valid(arg3) {
+		return headers, string(arg3), nil
+	}
+	return headers, json.RawMessage(arg3), nil
+}
+
+type thriftCodec struct {
+	idl    *idl
+	file   *parser.Thrift
+	method *parser.Method
+}
+
+func (thriftCodec) format() tchannel.Format { return tchannel.Thrift }
+
+func (c thriftCodec) encode(headers, body []byte) ([]byte, []byte, error) {
+	h, err := parseHeaders(headers)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var arg2 bytes.Buffer
+	if err := thrift.WriteHeaders(&arg2, h); err != nil {
+		return nil, nil, err
+	}
+	arg3, err := c.idl.encodeArgs(c.file, c.method, body)
+	return arg2.Bytes(), arg3, err
+}
+
+func (c thriftCodec) decode(arg2, arg3 []byte) (interface{}, interface{}, error) {
+	headers, err := thrift.ReadHeaders(bytes.NewReader(arg2))
+	if err != nil {
+		return nil, nil, err
+	}
+	if headers == nil {
+		headers = make(map[string]string)
+	}
+	result, err := c.idl.decodeResult(c.file, c.method, arg3)
+	return headers, result, err
+}
+
+// parseHeaders parses headers from a JSON object of strings.
+func parseHeaders(headers []byte) (map[string]string, error) {
+	h := make(map[string]string)
+	if len(bytes.TrimSpace(headers)) == 0 {
+		return h, nil
+	}
+	if err := json.Unmarshal(headers, &h); err != nil {
+		return nil, fmt.Errorf("headers must be a JSON object of strings: %v", err)
+	}
+	return h, nil
+}
+
+func writeJSON(out io.Writer, v interface{}) error {
+	encoded, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s\n", encoded)
+	return err
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	tjson "github.com/uber/tchannel-go/json"
+	"github.com/uber/tchannel-go/testutils"
+	"github.com/uber/tchannel-go/thrift"
+	gen "github.com/uber/tchannel-go/thrift/gen-go/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type simpleHandler struct{}
+
+func (simpleHandler) Call(ctx thrift.Context, arg *gen.Data) (*gen.Data, error) {
+	return &gen.Data{B1: !arg.B1, S2: arg.S2 + "!", I3: arg.I3 + 1}, nil
+}
+
+func (simpleHandler) Simple(ctx thrift.Context) error {
+	return &gen.SimpleErr{Message: "simple failed"}
+}
+
+func (simpleHandler) SimpleFuture(ctx thrift.Context) error {
+	return nil
+}
+
+func runCallJSON(t *testing.T, args ...string) callResult {
+	var out bytes.Buffer
+	require.NoError(t, runCall(args, &out), "call failed")
+
+	var result callResult
+	require.NoError(t, json.Unmarshal(out.Bytes(), &result), "Failed to parse output: %s", out.Bytes())
+	return result
+}
+
+func TestCall(t *testing.T) {
+	testutils.WithTestServer(t, nil, func(ts *testutils.TestServer) {
+		testutils.RegisterEcho(ts.Server(), nil)
+		require.NoError(t, tjson.Register(ts.Server(), tjson.Handlers{
+			"add": func(ctx tjson.Context, req map[string]int) (map[string]int, error) {
+				ctx.SetResponseHeaders(ctx.Headers())
+				return map[string]int{"sum": req["a"] + req["b"]}, nil
+			},
+		}, nil), "Failed to register json handler")
+		thrift.NewServer(ts.Server()).Register(gen.NewTChanSimpleServiceServer(simpleHandler{}))
+
+		tests := []struct {
+			msg  string
+			args []string
+			want callResult
+		}{
+			{
+				msg:  "raw",
+				args: []string{"-method", "echo", "-headers", "h", "-body", "hello"},
+				want: callResult{OK: true, Headers: "h", Body: "hello"},
+			},
+			{
+				msg:  "json",
+				args: []string{"-encoding", "json", "-method", "add", "-headers", `{"k":"v"}`, "-body", `{"a":1,"b":2}`},
+				want: callResult{
+					OK:      true,
+					Headers: map[string]interface{}{"k": "v"},
+					Body:    map[string]interface{}{"sum": float64(3)},
+				},
+			},
+			{
+				msg: "thrift",
+				args: []string{
+					"-encoding", "thrift", "-thrift", "../../thrift/test.thrift", "-method", "SimpleService::Call",
+					"-body", `{"arg": {"b1": true, "s2": "hi", "i3": 41}}`,
+				},
+				want: callResult{
+					OK:      true,
+					Headers: map[string]interface{}{},
+					Body: map[string]interface{}{
+						"success": map[string]interface{}{"b1": false, "s2": "hi!", "i3": float64(42)},
+					},
+				},
+			},
+			{
+				msg: "thrift exception",
+				args: []string{
+					"-encoding", "thrift", "-thrift", "../../thrift/test.thrift", "-method", "SimpleService::Simple",
+				},
+				want: callResult{
+					OK:      false,
+					Headers: map[string]interface{}{},
+					Body: map[string]interface{}{
+						"simpleErr": map[string]interface{}{"message": "simple failed"},
+					},
+				},
+			},
+		}
+
+		for _, tt := range tests {
+			args := append([]string{"-peer", ts.HostPort(), "-service", ts.ServiceName()}, tt.args...)
+			got := runCallJSON(t, args...)
+			assert.Equal(t, tt.want, got, "%v: unexpected result", tt.msg)
+		}
+	})
+}
+
+func TestCallErrors(t *testing.T) {
+	tests := []struct {
+		msg     string
+		args    []string
+		wantErr string
+	}{
+		{
+			msg:     "missing peer",
+			args:    []string{"-service", "svc", "-method", "m"},
+			wantErr: "-peer is required",
+		},
+		{
+			msg:     "missing method",
+			args:    []string{"-peer", "127.0.0.1:1", "-service", "svc"},
+			wantErr: "-service and -method are required",
+		},
+		{
+			msg:     "unknown encoding",
+			args:    []string{"-peer", "127.0.0.1:1", "-service", "svc", "-method", "m", "-encoding", "xml"},
+			wantErr: `unknown encoding "xml"`,
+		},
+		{
+			msg:     "thrift without IDL",
+			args:    []string{"-peer", "127.0.0.1:1", "-service", "svc", "-method", "m", "-encoding", "thrift"},
+			wantErr: "-thrift is required for the thrift encoding",
+		},
+		{
+			msg: "unknown thrift method",
+			args: []string{"-peer", "127.0.0.1:1", "-service", "svc", "-encoding", "thrift",
+				"-thrift", "../../thrift/test.thrift", "-method", "SimpleService::Unknown"},
+			wantErr: `unknown method "Unknown" in thrift service "SimpleService"`,
+		},
+		{
+			msg:     "invalid json body",
+			args:    []string{"-peer", "127.0.0.1:1", "-service", "svc", "-method", "m", "-encoding", "json", "-body", "{"},
+			wantErr: "body is not valid JSON",
+		},
+	}
+
+	for _, tt := range tests {
+		err := runCall(tt.args, &bytes.Buffer{})
+		if assert.Error(t, err, "%v: expected error", tt.msg) {
+			assert.Equal(t, tt.wantErr, err.Error(), "%v: unexpected error", tt.msg)
+		}
+	}
+}
+
+func TestPing(t *testing.T) {
+	testutils.WithTestServer(t, nil, func(ts *testutils.TestServer) {
+		var out bytes.Buffer
+		require.NoError(t, runPing([]string{"-peer", ts.HostPort()}, &out), "ping failed")
+		assert.Contains(t, out.String(), "Ping to "+ts.HostPort(), "Unexpected output")
+	})
+}
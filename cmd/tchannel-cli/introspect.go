@@ -0,0 +1,171 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+)
+
+// introspectService is the service that the introspection endpoints are
+// registered on, in addition to the channel's own service.
+const introspectService = "tchannel"
+
+func runIntrospect(args []string, out io.Writer) error {
+	var (
+		pf   peerFlags
+		opts tchannel.IntrospectionOptions
+	)
+	fs := newFlagSet("introspect", &pf)
+	fs.BoolVar(&opts.IncludeExchanges, "exchanges", false, "Include the message exchanges of each connection")
+	fs.BoolVar(&opts.IncludeEmptyPeers, "emptyPeers", false, "Include peers without connections")
+	fs.BoolVar(&opts.IncludeTombstones, "tombstones", false, "Include tombstones of relayed calls")
+	fs.BoolVar(&opts.IncludeOtherChannels, "otherChannels", false, "Include other channels in the process")
+	fs.BoolVar(&opts.IncludeSlowCalls, "slowCalls", false, "Include sampled slow outbound calls")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := pf.validate(); err != nil {
+		return err
+	}
+
+	ch, err := newClient()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	state, err := introspect(ch, pf, &opts)
+	if err != nil {
+		return err
+	}
+	return writeJSON(out, json.RawMessage(state))
+}
+
+// introspect returns the JSON introspection state of the peer.
+func introspect(ch *tchannel.Channel, pf peerFlags, opts *tchannel.IntrospectionOptions) ([]byte, error) {
+	arg3, err := json.Marshal(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := pf.context()
+	defer cancel()
+
+	_, state, _, err := raw.Call(ctx, ch, pf.peer, introspectService, "_gometa_introspect", nil, arg3)
+	return state, err
+}
+
+func runRelayStats(args []string, out io.Writer) error {
+	var pf peerFlags
+	fs := newFlagSet("relay-stats", &pf)
+	interval := fs.Duration("interval", time.Second, "How often to print stats")
+	count := fs.Int("count", 0, "The number of times to print stats, or 0 to print them until interrupted")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := pf.validate(); err != nil {
+		return err
+	}
+
+	ch, err := newClient()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	last := make(map[uint32]tchannel.ConnectionStatsRuntimeState)
+	for i := 0; *count == 0 || i < *count; i++ {
+		if i > 0 {
+			time.Sleep(*interval)
+		}
+
+		encoded, err := introspect(ch, pf, &tchannel.IntrospectionOptions{})
+		if err != nil {
+			return err
+		}
+		var state introspectState
+		if err := json.Unmarshal(encoded, &state); err != nil {
+			return fmt.Errorf("failed to parse introspection state: %v", err)
+		}
+		if err := writeRelayStats(out, &state, last); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// introspectState is the subset of tchannel.RuntimeState used by relay-stats.
+type introspectState struct {
+	LocalPeer struct {
+		HostPort string `json:"hostPort"`
+	} `json:"localPeer"`
+	RootPeers map[string]struct {
+		InboundConnections  []connState `json:"inboundConnections"`
+		OutboundConnections []connState `json:"outboundConnections"`
+	} `json:"rootPeers"`
+}
+
+// connState is the subset of tchannel.ConnectionRuntimeState used by relay-stats.
+type connState struct {
+	ID             uint32                               `json:"id"`
+	RemoteHostPort string                               `json:"remoteHostPort"`
+	Relayer        tchannel.RelayerRuntimeState         `json:"relayer"`
+	Stats          tchannel.ConnectionStatsRuntimeState `json:"stats"`
+}
+
+type byConnID []connState
+
+func (c byConnID) Len() int           { return len(c) }
+func (c byConnID) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+func (c byConnID) Less(i, j int) bool { return c[i].ID < c[j].ID }
+
+// writeRelayStats writes the relayed calls and traffic of each connection,
+// with the traffic since the stats in last, which are then updated.
+func writeRelayStats(out io.Writer, state *introspectState, last map[uint32]tchannel.ConnectionStatsRuntimeState) error {
+	var conns []connState
+	for _, peer := range state.RootPeers {
+		conns = append(conns, peer.InboundConnections...)
+		conns = append(conns, peer.OutboundConnections...)
+	}
+	sort.Sort(byConnID(conns))
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "%v\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintln(w, "CONN\tREMOTE\tRELAYING\tINBOUND\tOUTBOUND\tFRAMES SENT\tFRAMES RECVD\tBYTES SENT\tBYTES RECVD")
+	for _, c := range conns {
+		prev := last[c.ID]
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t+%v\t+%v\t+%v\t+%v\n",
+			c.ID, c.RemoteHostPort, c.Relayer.Count, c.Relayer.InboundItems.Count, c.Relayer.OutboundItems.Count,
+			c.Stats.FramesSent-prev.FramesSent, c.Stats.FramesRecvd-prev.FramesRecvd,
+			c.Stats.BytesSent-prev.BytesSent, c.Stats.BytesRecvd-prev.BytesRecvd)
+		last[c.ID] = c.Stats
+	}
+	fmt.Fprintln(w)
+	return w.Flush()
+}
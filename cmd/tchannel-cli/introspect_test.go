@@ -0,0 +1,59 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntrospect(t *testing.T) {
+	opts := testutils.NewOpts().SetRelayLocal(introspectService)
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		var out bytes.Buffer
+		require.NoError(t, runIntrospect([]string{"-peer", ts.HostPort()}, &out), "introspect failed")
+
+		var state introspectState
+		require.NoError(t, json.Unmarshal(out.Bytes(), &state), "Failed to parse output")
+		assert.Equal(t, ts.HostPort(), state.LocalPeer.HostPort, "Unexpected host:port")
+	})
+}
+
+func TestRelayStats(t *testing.T) {
+	opts := testutils.NewOpts().SetRelayOnly().SetRelayLocal(introspectService)
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		testutils.RegisterEcho(ts.Server(), nil)
+		client := ts.NewClient(nil)
+		testutils.AssertEcho(t, client, ts.HostPort(), ts.ServiceName())
+
+		var out bytes.Buffer
+		args := []string{"-peer", ts.HostPort(), "-count", "2", "-interval", "0"}
+		require.NoError(t, runRelayStats(args, &out), "relay-stats failed")
+		assert.Contains(t, out.String(), "RELAYING", "Missing header")
+		assert.Contains(t, out.String(), ts.Server().PeerInfo().HostPort, "Missing the relayed server's connection")
+	})
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// tchannel-cli makes ad hoc calls to TChannel services, and inspects the
+// state of running channels.
+//
+// Usage:
+//
+//	tchannel-cli call -peer host:port -service svc -method method [-encoding raw|json|thrift] [-thrift file.thrift] [-headers ...] [-body ...]
+//	tchannel-cli ping -peer host:port
+//	tchannel-cli introspect -peer host:port
+//	tchannel-cli relay-stats -peer host:port [-interval 1s]
+//
+// Thrift calls use the IDL to encode the JSON body as the method's arguments,
+// and to decode the result as JSON, so generated code is not needed.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/uber/tchannel-go"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string, out io.Writer) error
+}
+
+var commands = []command{
+	{"call", "Make a raw, json or thrift call", runCall},
+	{"ping", "Ping a peer", runPing},
+	{"introspect", "Dump the introspection state of a remote channel", runIntrospect},
+	{"relay-stats", "Periodically print the relay stats of a remote channel", runRelayStats},
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		for _, cmd := range commands {
+			if cmd.name == os.Args[1] {
+				if err := cmd.run(os.Args[2:], os.Stdout); err != nil {
+					if err != flag.ErrHelp {
+						fmt.Fprintln(os.Stderr, err)
+					}
+					os.Exit(1)
+				}
+				return
+			}
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Usage: %v <command> [flags]\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12v %v\n", cmd.name, cmd.usage)
+	}
+	os.Exit(2)
+}
+
+// peerFlags are the flags used by all commands to connect to a peer.
+type peerFlags struct {
+	peer    string
+	timeout time.Duration
+}
+
+func newFlagSet(name string, pf *peerFlags) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&pf.peer, "peer", "", "The host:port of the peer")
+	fs.DurationVar(&pf.timeout, "timeout", time.Second, "The timeout for each call")
+	return fs
+}
+
+func (pf *peerFlags) validate() error {
+	if pf.peer == "" {
+		return errors.New("-peer is required")
+	}
+	return nil
+}
+
+func (pf *peerFlags) context() (context.Context, context.CancelFunc) {
+	return tchannel.NewContext(pf.timeout)
+}
+
+func newClient() (*tchannel.Channel, error) {
+	return tchannel.NewChannel("tchannel-cli", nil)
+}
+
+func runPing(args []string, out io.Writer) error {
+	var pf peerFlags
+	if err := newFlagSet("ping", &pf).Parse(args); err != nil {
+		return err
+	}
+	if err := pf.validate(); err != nil {
+		return err
+	}
+
+	ch, err := newClient()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	ctx, cancel := pf.context()
+	defer cancel()
+
+	started := time.Now()
+	if err := ch.Ping(ctx, pf.peer); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Ping to %v took %v\n", pf.peer, time.Since(started))
+	return nil
+}
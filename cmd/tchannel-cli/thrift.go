@@ -0,0 +1,458 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/samuel/go-thrift/parser"
+	"go.uber.org/thriftrw/protocol"
+	"go.uber.org/thriftrw/wire"
+)
+
+// idl is a parsed Thrift file and its includes, used to encode the arguments
+// and decode the results of calls without generated code.
+type idl struct {
+	files map[string]*parser.Thrift
+	main  *parser.Thrift
+}
+
+// resolvedType is a Thrift type with typedefs and identifiers resolved.
+type resolvedType struct {
+	// file is the file that the type was declared in, used to resolve
+	// any types that it contains.
+	file *parser.Thrift
+
+	// name is the base or container type name if the type isn't a struct or enum.
+	name  string
+	typ   *parser.Type
+	strct *parser.Struct
+	enum  *parser.Enum
+}
+
+func parseIDL(filename string) (*idl, error) {
+	files, main, err := (&parser.Parser{}).ParseFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &idl{files: files, main: files[main]}, nil
+}
+
+// method returns the method for a "Service::method" name, searching any
+// services that the service extends.
+func (i *idl) method(name string) (*parser.Thrift, *parser.Method, error) {
+	parts := strings.Split(name, "::")
+	if len(parts) != 2 {
+		return nil, nil, fmt.Errorf("thrift method %q must be of the form Service::method", name)
+	}
+
+	file, svcName := i.main, parts[0]
+	for {
+		file, svcName = i.lookupFile(file, svcName)
+		svc, ok := file.Services[svcName]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown thrift service %q", svcName)
+		}
+		if m, ok := svc.Methods[parts[1]]; ok {
+			return file, m, nil
+		}
+		if svc.Extends == "" {
+			return nil, nil, fmt.Errorf("unknown method %q in thrift service %q", parts[1], parts[0])
+		}
+		svcName = svc.Extends
+	}
+}
+
+// lookupFile returns the file and unqualified name for an identifier, which
+// may refer to an included file.
+func (i *idl) lookupFile(file *parser.Thrift, name string) (*parser.Thrift, string) {
+	if idx := strings.Index(name, "."); idx > 0 {
+		if included, ok := i.files[file.Includes[name[:idx]]]; ok {
+			return included, name[idx+1:]
+		}
+	}
+	return file, name
+}
+
+func (i *idl) resolve(file *parser.Thrift, t *parser.Type) (resolvedType, error) {
+	for {
+		switch t.Name {
+		case "bool", "byte", "i8", "i16", "i32", "i64", "double", "string", "binary", "list", "set", "map":
+			return resolvedType{file: file, name: t.Name, typ: t}, nil
+		}
+
+		f, name := i.lookupFile(file, t.Name)
+		if td, ok := f.Typedefs[name]; ok {
+			file, t = f, td.Type
+			continue
+		}
+		if e, ok := f.Enums[name]; ok {
+			return resolvedType{file: f, enum: e}, nil
+		}
+		for _, structs := range []map[string]*parser.Struct{f.Structs, f.Exceptions, f.Unions} {
+			if s, ok := structs[name]; ok {
+				return resolvedType{file: f, strct: s}, nil
+			}
+		}
+		return resolvedType{}, fmt.Errorf("unknown thrift type %q", t.Name)
+	}
+}
+
+func wireType(rt resolvedType) wire.Type {
+	switch {
+	case rt.strct != nil:
+		return wire.TStruct
+	case rt.enum != nil:
+		return wire.TI32
+	}
+	switch rt.name {
+	case "bool":
+		return wire.TBool
+	case "byte", "i8":
+		return wire.TI8
+	case "i16":
+		return wire.TI16
+	case "i32":
+		return wire.TI32
+	case "i64":
+		return wire.TI64
+	case "double":
+		return wire.TDouble
+	case "string", "binary":
+		return wire.TBinary
+	case "list":
+		return wire.TList
+	case "set":
+		return wire.TSet
+	default:
+		return wire.TMap
+	}
+}
+
+// encodeArgs encodes the JSON arguments for a method as the method's
+// arguments struct.
+func (i *idl) encodeArgs(file *parser.Thrift, m *parser.Method, args []byte) ([]byte, error) {
+	var v interface{} = map[string]interface{}{}
+	if len(bytes.TrimSpace(args)) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(args))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return nil, fmt.Errorf("failed to parse arguments as JSON: %v", err)
+		}
+	}
+
+	s, err := i.encodeStruct(file, m.Arguments, v)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := protocol.Binary.Encode(wire.NewValueStruct(s), &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeResult decodes the method's result struct, returning the success
+// value or exception by field name.
+func (i *idl) decodeResult(file *parser.Thrift, m *parser.Method, result []byte) (interface{}, error) {
+	fields := append([]*parser.Field(nil), m.Exceptions...)
+	if m.ReturnType != nil && m.ReturnType.Name != "void" {
+		fields = append(fields, &parser.Field{ID: 0, Name: "success", Type: m.ReturnType})
+	}
+
+	v, err := protocol.Binary.Decode(bytes.NewReader(result), wire.TStruct)
+	if err != nil {
+		return nil, err
+	}
+	return i.decodeStruct(file, fields, v.GetStruct())
+}
+
+func (i *idl) encodeStruct(file *parser.Thrift, fields []*parser.Field, v interface{}) (wire.Struct, error) {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return wire.Struct{}, fmt.Errorf("expected a JSON object, got %v", v)
+	}
+
+	var s wire.Struct
+	for _, f := range fields {
+		fv, ok := obj[f.Name]
+		if !ok {
+			continue
+		}
+		rt, err := i.resolve(file, f.Type)
+		if err != nil {
+			return wire.Struct{}, err
+		}
+		value, err := i.encodeValue(rt, fv)
+		if err != nil {
+			return wire.Struct{}, fmt.Errorf("field %v: %v", f.Name, err)
+		}
+		s.Fields = append(s.Fields, wire.Field{ID: int16(f.ID), Value: value})
+		delete(obj, f.Name)
+	}
+	for name := range obj {
+		return wire.Struct{}, fmt.Errorf("unknown field %q", name)
+	}
+	return s, nil
+}
+
+func (i *idl) encodeValue(rt resolvedType, v interface{}) (wire.Value, error) {
+	switch {
+	case rt.strct != nil:
+		s, err := i.encodeStruct(rt.file, rt.strct.Fields, v)
+		return wire.NewValueStruct(s), err
+	case rt.enum != nil:
+		if name, ok := v.(string); ok {
+			ev, ok := rt.enum.Values[name]
+			if !ok {
+				return wire.Value{}, fmt.Errorf("unknown %v value %q", rt.enum.Name, name)
+			}
+			return wire.NewValueI32(int32(ev.Value)), nil
+		}
+		n, err := jsonInt(v, 32)
+		return wire.NewValueI32(int32(n)), err
+	}
+
+	switch rt.name {
+	case "bool":
+		b, ok := v.(bool)
+		if !ok {
+			return wire.Value{}, fmt.Errorf("expected a bool, got %v", v)
+		}
+		return wire.NewValueBool(b), nil
+	case "byte", "i8":
+		n, err := jsonInt(v, 8)
+		return wire.NewValueI8(int8(n)), err
+	case "i16":
+		n, err := jsonInt(v, 16)
+		return wire.NewValueI16(int16(n)), err
+	case "i32":
+		n, err := jsonInt(v, 32)
+		return wire.NewValueI32(int32(n)), err
+	case "i64":
+		n, err := jsonInt(v, 64)
+		return wire.NewValueI64(n), err
+	case "double":
+		num, ok := v.(json.Number)
+		if !ok {
+			return wire.Value{}, fmt.Errorf("expected a number, got %v", v)
+		}
+		f, err := num.Float64()
+		return wire.NewValueDouble(f), err
+	case "string", "binary":
+		s, ok := v.(string)
+		if !ok {
+			return wire.Value{}, fmt.Errorf("expected a string, got %v", v)
+		}
+		return wire.NewValueBinary([]byte(s)), nil
+	case "list", "set":
+		return i.encodeList(rt, v)
+	default:
+		return i.encodeMap(rt, v)
+	}
+}
+
+func (i *idl) encodeList(rt resolvedType, v interface{}) (wire.Value, error) {
+	items, ok := v.([]interface{})
+	if !ok {
+		return wire.Value{}, fmt.Errorf("expected a JSON array, got %v", v)
+	}
+	elem, err := i.resolve(rt.file, rt.typ.ValueType)
+	if err != nil {
+		return wire.Value{}, err
+	}
+
+	values := make([]wire.Value, len(items))
+	for idx, item := range items {
+		if values[idx], err = i.encodeValue(elem, item); err != nil {
+			return wire.Value{}, err
+		}
+	}
+	list := wire.ValueListFromSlice(wireType(elem), values)
+	if rt.name == "set" {
+		return wire.NewValueSet(list), nil
+	}
+	return wire.NewValueList(list), nil
+}
+
+// encodeMap encodes a JSON object as a map. Keys that are not strings are
+// parsed from the object's keys as JSON.
+func (i *idl) encodeMap(rt resolvedType, v interface{}) (wire.Value, error) {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return wire.Value{}, fmt.Errorf("expected a JSON object, got %v", v)
+	}
+	keyType, err := i.resolve(rt.file, rt.typ.KeyType)
+	if err != nil {
+		return wire.Value{}, err
+	}
+	valueType, err := i.resolve(rt.file, rt.typ.ValueType)
+	if err != nil {
+		return wire.Value{}, err
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	items := make([]wire.MapItem, 0, len(obj))
+	for _, k := range keys {
+		var key interface{} = k
+		if keyType.name != "string" && keyType.name != "binary" && keyType.enum == nil {
+			dec := json.NewDecoder(strings.NewReader(k))
+			dec.UseNumber()
+			if err := dec.Decode(&key); err != nil {
+				return wire.Value{}, fmt.Errorf("invalid map key %q: %v", k, err)
+			}
+		}
+
+		var item wire.MapItem
+		if item.Key, err = i.encodeValue(keyType, key); err != nil {
+			return wire.Value{}, err
+		}
+		if item.Value, err = i.encodeValue(valueType, obj[k]); err != nil {
+			return wire.Value{}, err
+		}
+		items = append(items, item)
+	}
+	return wire.NewValueMap(wire.MapItemListFromSlice(wireType(keyType), wireType(valueType), items)), nil
+}
+
+func (i *idl) decodeStruct(file *parser.Thrift, fields []*parser.Field, s wire.Struct) (map[string]interface{}, error) {
+	byID := make(map[int16]*parser.Field, len(fields))
+	for _, f := range fields {
+		byID[int16(f.ID)] = f
+	}
+
+	obj := make(map[string]interface{}, len(s.Fields))
+	for _, sf := range s.Fields {
+		f, ok := byID[sf.ID]
+		if !ok {
+			// Unknown fields are skipped, as they may be from a newer IDL.
+			continue
+		}
+		rt, err := i.resolve(file, f.Type)
+		if err != nil {
+			return nil, err
+		}
+		if obj[f.Name], err = i.decodeValue(rt, sf.Value); err != nil {
+			return nil, fmt.Errorf("field %v: %v", f.Name, err)
+		}
+	}
+	return obj, nil
+}
+
+func (i *idl) decodeValue(rt resolvedType, v wire.Value) (interface{}, error) {
+	if want := wireType(rt); v.Type() != want {
+		return nil, fmt.Errorf("expected %v, got %v", want, v.Type())
+	}
+
+	switch {
+	case rt.strct != nil:
+		return i.decodeStruct(rt.file, rt.strct.Fields, v.GetStruct())
+	case rt.enum != nil:
+		n := int(v.GetI32())
+		for name, ev := range rt.enum.Values {
+			if ev.Value == n {
+				return name, nil
+			}
+		}
+		return n, nil
+	}
+
+	switch rt.name {
+	case "string", "binary":
+		return v.GetString(), nil
+	case "list", "set":
+		elem, err := i.resolve(rt.file, rt.typ.ValueType)
+		if err != nil {
+			return nil, err
+		}
+		list := v.GetList()
+		if rt.name == "set" {
+			list = v.GetSet()
+		}
+		items := make([]interface{}, 0, list.Size())
+		err = list.ForEach(func(item wire.Value) error {
+			decoded, err := i.decodeValue(elem, item)
+			items = append(items, decoded)
+			return err
+		})
+		return items, err
+	case "map":
+		return i.decodeMap(rt, v.GetMap())
+	default:
+		return v.Get(), nil
+	}
+}
+
+// decodeMap decodes a map as a JSON object, with keys that are not strings
+// encoded as JSON.
+func (i *idl) decodeMap(rt resolvedType, m wire.MapItemList) (interface{}, error) {
+	keyType, err := i.resolve(rt.file, rt.typ.KeyType)
+	if err != nil {
+		return nil, err
+	}
+	valueType, err := i.resolve(rt.file, rt.typ.ValueType)
+	if err != nil {
+		return nil, err
+	}
+
+	obj := make(map[string]interface{}, m.Size())
+	err = m.ForEach(func(item wire.MapItem) error {
+		key, err := i.decodeValue(keyType, item.Key)
+		if err != nil {
+			return err
+		}
+		value, err := i.decodeValue(valueType, item.Value)
+		if err != nil {
+			return err
+		}
+
+		keyStr, ok := key.(string)
+		if !ok {
+			encoded, err := json.Marshal(key)
+			if err != nil {
+				return err
+			}
+			keyStr = string(encoded)
+		}
+		obj[keyStr] = value
+		return nil
+	})
+	return obj, err
+}
+
+func jsonInt(v interface{}, bits int) (int64, error) {
+	num, ok := v.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("expected a number, got %v", v)
+	}
+	return strconv.ParseInt(string(num), 10, bits)
+}
@@ -0,0 +1,209 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// NoCompression can be set as the compression for a call to disable the
+// channel's default compression.
+const NoCompression = "none"
+
+// Compressor compresses and decompresses call payloads (arg3). Gzip is
+// always supported, other codecs (e.g. snappy or zstd) can be added using
+// ChannelOptions.Compressors.
+type Compressor interface {
+	// Name is the name of the compression, which is advertised to peers when
+	// connecting, and sent in the transport headers of compressed calls.
+	Name() string
+
+	// Compress returns a writer that compresses data written to it into w.
+	// Flush is called on the writer if it implements it, and Close is called
+	// once all data has been written.
+	Compress(w io.Writer) io.WriteCloser
+
+	// Decompress returns a reader that decompresses data read from r.
+	Decompress(r io.Reader) (io.ReadCloser, error)
+}
+
+// GzipCompressor is a Compressor that uses gzip.
+var GzipCompressor Compressor = gzipCompressor{}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Name() string { return "gzip" }
+
+func (gzipCompressor) Compress(w io.Writer) io.WriteCloser {
+	return gzip.NewWriter(w)
+}
+
+func (gzipCompressor) Decompress(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// compressors are the codecs supported by a channel.
+type compressors struct {
+	byName map[string]Compressor
+
+	// advertised is the list of supported compressions sent to peers.
+	advertised string
+
+	// outbound is the compression used by default for outbound calls.
+	outbound string
+}
+
+func newCompressors(supported []Compressor, outbound string, advertise bool) *compressors {
+	c := &compressors{
+		byName:   map[string]Compressor{GzipCompressor.Name(): GzipCompressor},
+		outbound: outbound,
+	}
+	for _, compressor := range supported {
+		c.byName[compressor.Name()] = compressor
+	}
+
+	if advertise {
+		names := make([]string, 0, len(c.byName))
+		for name := range c.byName {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		c.advertised = strings.Join(names, ",")
+	}
+	return c
+}
+
+// parseCompressions parses the compressions advertised by a peer.
+func parseCompressions(p initParams) map[string]struct{} {
+	advertised := p[InitParamCompression]
+	if advertised == "" {
+		return nil
+	}
+
+	names := make(map[string]struct{})
+	for _, name := range strings.Split(advertised, ",") {
+		names[name] = struct{}{}
+	}
+	return names
+}
+
+// outboundCompressor returns the compressor to use for an outbound call with
+// the given compression, which is only used if the peer supports it.
+func (c *Connection) outboundCompressor(compression string) Compressor {
+	if compression == "" {
+		compression = c.compressors.outbound
+	}
+	if compression == "" || compression == NoCompression {
+		return nil
+	}
+	if _, ok := c.remoteCompressions[compression]; !ok {
+		return nil
+	}
+	return c.compressors.byName[compression]
+}
+
+// forHeaders returns the compressor for an argument received with the given
+// transport headers, or nil if the argument is not compressed.
+func (c *compressors) forHeaders(headers transportHeaders) (Compressor, error) {
+	compression := headers[Compression]
+	if compression == "" {
+		return nil, nil
+	}
+
+	compressor, ok := c.byName[compression]
+	if !ok {
+		return nil, NewSystemError(ErrCodeBadRequest, "unsupported compression %q", compression)
+	}
+	return compressor, nil
+}
+
+// compressingWriter compresses data written to an ArgWriter.
+type compressingWriter struct {
+	ArgWriter
+	cw io.WriteCloser
+}
+
+func newCompressingWriter(compressor Compressor, w ArgWriter) ArgWriter {
+	if compressor == nil {
+		return w
+	}
+	return &compressingWriter{ArgWriter: w, cw: compressor.Compress(w)}
+}
+
+func (w *compressingWriter) Write(b []byte) (int, error) {
+	return w.cw.Write(b)
+}
+
+func (w *compressingWriter) Flush() error {
+	if f, ok := w.cw.(interface {
+		Flush() error
+	}); ok {
+		if err := f.Flush(); err != nil {
+			return err
+		}
+	}
+	return w.ArgWriter.Flush()
+}
+
+func (w *compressingWriter) Close() error {
+	if err := w.cw.Close(); err != nil {
+		return err
+	}
+	return w.ArgWriter.Close()
+}
+
+// decompressingReader decompresses data read from an ArgReader.
+type decompressingReader struct {
+	ArgReader
+	dr io.ReadCloser
+}
+
+func newDecompressingReader(compressor Compressor, r ArgReader) (ArgReader, error) {
+	if compressor == nil {
+		return r, nil
+	}
+
+	dr, err := compressor.Decompress(r)
+	if err != nil {
+		return nil, NewWrappedSystemError(ErrCodeBadRequest, err)
+	}
+	return &decompressingReader{ArgReader: r, dr: dr}, nil
+}
+
+func (r *decompressingReader) Read(b []byte) (int, error) {
+	return r.dr.Read(b)
+}
+
+func (r *decompressingReader) Close() error {
+	if err := r.dr.Close(); err != nil {
+		return err
+	}
+
+	// The decompressor may stop reading before the end of the argument.
+	if _, err := io.Copy(ioutil.Discard, r.ArgReader); err != nil {
+		return err
+	}
+	return r.ArgReader.Close()
+}
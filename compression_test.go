@@ -0,0 +1,158 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type flateCompressor struct{}
+
+func (flateCompressor) Name() string { return "flate" }
+
+func (flateCompressor) Compress(w io.Writer) io.WriteCloser {
+	fw, _ := flate.NewWriter(w, flate.BestSpeed)
+	return fw
+}
+
+func (flateCompressor) Decompress(r io.Reader) (io.ReadCloser, error) {
+	return flate.NewReader(r), nil
+}
+
+// registerCompressionEcho registers a handler that echoes arg3, and returns
+// a channel with the compression used by each call.
+func registerCompressionEcho(t *testing.T, ch *Channel) <-chan string {
+	compressions := make(chan string, 10)
+	ch.Register(HandlerFunc(func(ctx context.Context, call *InboundCall) {
+		args, err := raw.ReadArgs(call)
+		require.NoError(t, err, "Failed to read args")
+		compressions <- call.Compression()
+		require.NoError(t, NewArgWriter(call.Response().Arg2Writer()).Write(args.Arg2), "Failed to write arg2")
+		require.NoError(t, NewArgWriter(call.Response().Arg3Writer()).Write(args.Arg3), "Failed to write arg3")
+	}), "echo")
+	return compressions
+}
+
+func callWithCompression(t *testing.T, client *Channel, hostPort, serviceName, compression string, arg3 []byte) {
+	ctx, cancel := NewContext(testutils.Timeout(time.Second))
+	defer cancel()
+
+	call, err := client.BeginCall(ctx, hostPort, serviceName, "echo", &CallOptions{Compression: compression})
+	require.NoError(t, err, "BeginCall failed")
+	resArg2, resArg3, _, err := raw.WriteArgs(call, []byte("arg2"), arg3)
+	require.NoError(t, err, "Call failed")
+	assert.Equal(t, []byte("arg2"), resArg2, "Unexpected arg2")
+	assert.True(t, bytes.Equal(arg3, resArg3), "Unexpected arg3")
+}
+
+func TestCompression(t *testing.T) {
+	// arg3 is larger than a frame before compression.
+	arg3 := bytes.Repeat([]byte("compressible payload "), 10000)
+
+	tests := []struct {
+		msg               string
+		clientCompression string
+		clientCompressors []Compressor
+		serverCompressors []Compressor
+		callCompression   string
+		want              string
+	}{
+		{
+			msg:               "channel default",
+			clientCompression: "gzip",
+			want:              "gzip",
+		},
+		{
+			msg:             "per-call compression",
+			callCompression: "gzip",
+			want:            "gzip",
+		},
+		{
+			msg:               "disabled for the call",
+			clientCompression: "gzip",
+			callCompression:   NoCompression,
+			want:              "",
+		},
+		{
+			msg:               "custom compressor",
+			clientCompression: "flate",
+			clientCompressors: []Compressor{flateCompressor{}},
+			serverCompressors: []Compressor{flateCompressor{}},
+			want:              "flate",
+		},
+		{
+			msg:               "not supported by the server",
+			clientCompression: "flate",
+			clientCompressors: []Compressor{flateCompressor{}},
+			want:              "",
+		},
+		{
+			msg:  "no compression",
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		opts := testutils.NewOpts().NoRelay()
+		opts.Compressors = tt.serverCompressors
+		testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+			compressions := registerCompressionEcho(t, ts.Server())
+
+			clientOpts := testutils.NewOpts()
+			clientOpts.Compression = tt.clientCompression
+			clientOpts.Compressors = tt.clientCompressors
+			client := ts.NewClient(clientOpts)
+
+			callWithCompression(t, client, ts.HostPort(), ts.ServiceName(), tt.callCompression, arg3)
+			assert.Equal(t, tt.want, <-compressions, "%v: unexpected compression", tt.msg)
+		})
+	}
+}
+
+func TestCompressionRelay(t *testing.T) {
+	testutils.WithTestServer(t, nil, func(ts *testutils.TestServer) {
+		compressions := registerCompressionEcho(t, ts.Server())
+
+		clientOpts := testutils.NewOpts()
+		clientOpts.Compression = "gzip"
+		client := ts.NewClient(clientOpts)
+
+		callWithCompression(t, client, ts.HostPort(), ts.ServiceName(), "", []byte("payload"))
+		want := "gzip"
+		if ts.HasRelay() {
+			// Relays do not advertise compression as the relayed peer may not support it.
+			want = ""
+		}
+		assert.Equal(t, want, <-compressions, "Unexpected compression")
+	})
+}
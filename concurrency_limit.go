@@ -0,0 +1,124 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"context"
+
+	"github.com/uber-go/atomic"
+)
+
+// errChannelCallLimit is returned to callers when the channel is already
+// handling MaxConcurrentCalls calls.
+var errChannelCallLimit = NewSystemError(ErrCodeBusy, "channel has too many concurrent calls")
+
+// concurrencyLimiter limits the number of concurrent inbound calls. A nil
+// limiter allows all calls.
+type concurrencyLimiter struct {
+	max      int64
+	inflight atomic.Int64
+}
+
+// newConcurrencyLimiter returns a limiter that allows max concurrent calls,
+// or nil if max is not positive.
+func newConcurrencyLimiter(max int) *concurrencyLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &concurrencyLimiter{max: int64(max)}
+}
+
+// acquire reserves capacity for a call, returning false if the limit has been
+// reached. Every successful acquire must be followed by a release.
+func (l *concurrencyLimiter) acquire() bool {
+	if l == nil {
+		return true
+	}
+	if l.inflight.Inc() > l.max {
+		l.inflight.Dec()
+		return false
+	}
+	return true
+}
+
+func (l *concurrencyLimiter) release() {
+	if l != nil {
+		l.inflight.Dec()
+	}
+}
+
+// WithMaxConcurrentCalls is a SubChannelOption that limits the number of
+// concurrent inbound calls handled by the subchannel. Calls over the limit
+// are rejected with a Busy error rather than queued.
+func WithMaxConcurrentCalls(max int) SubChannelOption {
+	return func(s *SubChannel) {
+		s.Lock()
+		s.inboundLimiter = newConcurrencyLimiter(max)
+		s.Unlock()
+	}
+}
+
+// WithMethodMaxConcurrentCalls is a SubChannelOption that limits the number
+// of concurrent inbound calls to the given method. Calls over the limit are
+// rejected with a Busy error rather than queued.
+func WithMethodMaxConcurrentCalls(method string, max int) SubChannelOption {
+	return func(s *SubChannel) {
+		s.Lock()
+		defer s.Unlock()
+		if s.methodLimiters == nil {
+			s.methodLimiters = make(map[string]*concurrencyLimiter)
+		}
+		s.methodLimiters[method] = newConcurrencyLimiter(max)
+	}
+}
+
+// limitHandler enforces the subchannel and method concurrency limits before
+// passing the call to the subchannel's handler.
+func (c *SubChannel) limitHandler(ctx context.Context, call *InboundCall) {
+	c.RLock()
+	subChLimiter := c.inboundLimiter
+	methodLimiter := c.methodLimiters[string(call.Method())]
+	handler := c.handler
+	c.RUnlock()
+
+	if !subChLimiter.acquire() {
+		call.shed(NewSystemError(ErrCodeBusy, "service %q has too many concurrent calls", c.serviceName))
+		return
+	}
+	defer subChLimiter.release()
+
+	if !methodLimiter.acquire() {
+		call.shed(NewSystemError(ErrCodeBusy, "method %q of service %q has too many concurrent calls", call.MethodString(), c.serviceName))
+		return
+	}
+	defer methodLimiter.release()
+
+	handler.Handle(ctx, call)
+}
+
+// shed rejects an inbound call that was not admitted due to a concurrency limit.
+func (call *InboundCall) shed(err error) {
+	call.statsReporter.IncCounter("inbound.calls.shed", call.commonStatsTags, 1)
+	if call.log.Enabled(LogLevelDebug) {
+		call.log.Debugf("Shedding call to %s::%s: %v", call.ServiceName(), call.MethodString(), err)
+	}
+	call.Response().SendSystemError(err)
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// registerBlockingHandler registers a handler for method that blocks until
+// the returned channel is closed. Each call is signalled on started.
+func registerBlockingHandler(r Registrar, method string, started chan<- struct{}) chan struct{} {
+	unblock := make(chan struct{})
+	testutils.RegisterFunc(r, method, func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		started <- struct{}{}
+		<-unblock
+		return &raw.Res{}, nil
+	})
+	return unblock
+}
+
+func callService(ch *Channel, hostPort, service, method string) error {
+	ctx, cancel := NewContext(testutils.Timeout(time.Second))
+	defer cancel()
+
+	_, _, _, err := raw.Call(ctx, ch, hostPort, service, method, nil, nil)
+	return err
+}
+
+func TestMaxConcurrentCalls(t *testing.T) {
+	opts := testutils.NewOpts()
+	opts.MaxConcurrentCalls = 1
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		started := make(chan struct{}, 1)
+		unblock := registerBlockingHandler(ts.Server(), "block", started)
+		testutils.RegisterEcho(ts.Server(), nil)
+
+		client := ts.NewClient(nil)
+		blockedErr := make(chan error, 1)
+		go func() {
+			blockedErr <- callService(client, ts.HostPort(), ts.ServiceName(), "block")
+		}()
+		<-started
+
+		err := callService(client, ts.HostPort(), ts.ServiceName(), "echo")
+		assert.Equal(t, ErrCodeBusy, GetSystemErrorCode(err), "Expected call over the limit to be shed, got %v", err)
+
+		close(unblock)
+		require.NoError(t, <-blockedErr, "Blocked call failed")
+		assert.NoError(t, callService(client, ts.HostPort(), ts.ServiceName(), "echo"),
+			"Call should succeed once the in-flight call completes")
+	})
+}
+
+func TestMaxQueuedCalls(t *testing.T) {
+	opts := testutils.NewOpts()
+	opts.MaxConcurrentCalls = 1
+	opts.MaxQueuedCalls = 1
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		started := make(chan struct{}, 1)
+		unblock := registerBlockingHandler(ts.Server(), "block", started)
+		testutils.RegisterEcho(ts.Server(), nil)
+
+		client := ts.NewClient(nil)
+		blockedErr := make(chan error, 1)
+		go func() {
+			blockedErr <- callService(client, ts.HostPort(), ts.ServiceName(), "block")
+		}()
+		<-started
+
+		callWithPriority := func(p CallPriority) error {
+			ctx, cancel := NewContextBuilder(testutils.Timeout(time.Second)).SetPriority(p).Build()
+			defer cancel()
+			_, _, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "echo", nil, nil)
+			return err
+		}
+
+		queuedErr := make(chan error, 1)
+		go func() { queuedErr <- callWithPriority(PriorityLow) }()
+
+		// Wait for the low priority call to be queued, and then queue a high
+		// priority call which sheds it.
+		var err error
+		select {
+		case err = <-queuedErr:
+			t.Fatalf("Queued call completed early: %v", err)
+		case <-time.After(testutils.Timeout(50 * time.Millisecond)):
+		}
+		highErr := make(chan error, 1)
+		go func() { highErr <- callWithPriority(PriorityHigh) }()
+
+		err = <-queuedErr
+		assert.Equal(t, ErrCodeBusy, GetSystemErrorCode(err), "Expected low priority call to be shed, got %v", err)
+
+		close(unblock)
+		require.NoError(t, <-blockedErr, "Blocked call failed")
+		assert.NoError(t, <-highErr, "Queued high priority call should succeed")
+	})
+}
+
+func TestSubChannelMaxConcurrentCalls(t *testing.T) {
+	tests := []struct {
+		msg              string
+		opt              SubChannelOption
+		otherMethodLimit bool
+	}{
+		{
+			msg:              "service limit",
+			opt:              WithMaxConcurrentCalls(1),
+			otherMethodLimit: true,
+		},
+		{
+			msg:              "method limit",
+			opt:              WithMethodMaxConcurrentCalls("block", 1),
+			otherMethodLimit: false,
+		},
+	}
+
+	for _, tt := range tests {
+		opts := testutils.NewOpts().NoRelay()
+		testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+			const service = "limited"
+			subCh := ts.Server().GetSubChannel(service, tt.opt)
+			started := make(chan struct{}, 2)
+			unblock := registerBlockingHandler(subCh, "block", started)
+			testutils.RegisterEcho(subCh, nil)
+
+			client := ts.NewClient(nil)
+			blockedErr := make(chan error, 1)
+			go func() {
+				blockedErr <- callService(client, ts.HostPort(), service, "block")
+			}()
+			<-started
+
+			err := callService(client, ts.HostPort(), service, "block")
+			assert.Equal(t, ErrCodeBusy, GetSystemErrorCode(err), "%v: expected call over the limit to be shed, got %v", tt.msg, err)
+
+			err = callService(client, ts.HostPort(), service, "echo")
+			if tt.otherMethodLimit {
+				assert.Equal(t, ErrCodeBusy, GetSystemErrorCode(err), "%v: expected other method to be shed, got %v", tt.msg, err)
+			} else {
+				assert.NoError(t, err, "%v: other methods should not be limited", tt.msg)
+			}
+
+			// Calls to other services are not affected by the subchannel's limits.
+			testutils.RegisterEcho(ts.Server(), nil)
+			assert.NoError(t, callService(client, ts.HostPort(), ts.ServiceName(), "echo"),
+				"%v: other services should not be limited", tt.msg)
+
+			close(unblock)
+			require.NoError(t, <-blockedErr, "%v: blocked call failed", tt.msg)
+		})
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"fmt"
+	"net"
+)
+
+// ConnectionEventType is the type of a ConnectionEvent.
+type ConnectionEventType int
+
+const (
+	// ConnectionConnected is sent once a network connection is established,
+	// before the init handshake.
+	ConnectionConnected ConnectionEventType = iota + 1
+
+	// ConnectionHandshakeCompleted is sent once the init handshake completes,
+	// and the connection is active.
+	ConnectionHandshakeCompleted
+
+	// ConnectionHandshakeFailed is sent if the init handshake fails, and the
+	// network connection is closed.
+	ConnectionHandshakeFailed
+
+	// ConnectionStateChanged is sent when an active connection changes state
+	// while it's closing.
+	ConnectionStateChanged
+
+	// ConnectionHealthCheckFailed is sent when a ping sent on the connection,
+	// such as a health check using Channel.Ping, fails.
+	ConnectionHealthCheckFailed
+
+	// ConnectionClosed is sent once the connection is closed.
+	ConnectionClosed
+)
+
+func (t ConnectionEventType) String() string {
+	switch t {
+	case ConnectionConnected:
+		return "connected"
+	case ConnectionHandshakeCompleted:
+		return "handshakeCompleted"
+	case ConnectionHandshakeFailed:
+		return "handshakeFailed"
+	case ConnectionStateChanged:
+		return "stateChanged"
+	case ConnectionHealthCheckFailed:
+		return "healthCheckFailed"
+	case ConnectionClosed:
+		return "closed"
+	default:
+		return fmt.Sprintf("ConnectionEventType(%v)", int(t))
+	}
+}
+
+// ConnectionEvent describes a change to a connection of a channel.
+type ConnectionEvent struct {
+	Type ConnectionEventType
+
+	// Direction is "inbound" or "outbound".
+	Direction string
+
+	// ConnectionID is the ID of the connection, which is only set once the
+	// init handshake has completed.
+	ConnectionID uint32
+
+	// LocalAddr and RemoteAddr are the addresses of the network connection.
+	LocalAddr  string
+	RemoteAddr string
+
+	// RemotePeer is the remote peer's information, which is only set once
+	// the init handshake has completed.
+	RemotePeer PeerInfo
+
+	// State is the connection's state once the event has occurred, and is
+	// only set once the init handshake has completed.
+	State string
+
+	// Reason is the reason the connection was closed, for ConnectionClosed
+	// and ConnectionStateChanged events.
+	Reason string
+
+	// Err is the error that caused the event, if any, such as the error that
+	// failed the handshake or health check, or that closed the connection.
+	Err error
+}
+
+// ConnectionObserver is notified of the events of every connection of a
+// channel. ObserveConnection is called synchronously when the event occurs,
+// so it must not block.
+type ConnectionObserver interface {
+	ObserveConnection(event ConnectionEvent)
+}
+
+// ConnectionObserverFunc is an adapter to allow the use of ordinary functions
+// as a ConnectionObserver.
+type ConnectionObserverFunc func(event ConnectionEvent)
+
+// ObserveConnection calls f(event).
+func (f ConnectionObserverFunc) ObserveConnection(event ConnectionEvent) {
+	f(event)
+}
+
+// observeNetConn notifies the channel's ConnectionObserver, if any, of an
+// event for a network connection that has not completed the init handshake.
+func (ch *Channel) observeNetConn(t ConnectionEventType, connDir connectionDirection, c net.Conn, err error) {
+	if ch.connObserver == nil {
+		return
+	}
+	ch.connObserver.ObserveConnection(ConnectionEvent{
+		Type:       t,
+		Direction:  connDir.String(),
+		LocalAddr:  c.LocalAddr().String(),
+		RemoteAddr: c.RemoteAddr().String(),
+		Err:        err,
+	})
+}
+
+// observe notifies the channel's ConnectionObserver, if any, of an event,
+// adding the connection's information to it.
+func (c *Connection) observe(event ConnectionEvent) {
+	if c.connObserver == nil {
+		return
+	}
+
+	connDir := inbound
+	if c.outboundHP != "" {
+		connDir = outbound
+	}
+	event.Direction = connDir.String()
+	event.ConnectionID = c.connID
+	event.LocalAddr = c.conn.LocalAddr().String()
+	event.RemoteAddr = c.conn.RemoteAddr().String()
+	event.RemotePeer = c.remotePeerInfo
+
+	c.stateMut.RLock()
+	event.State = c.state.String()
+	if event.Type == ConnectionStateChanged || event.Type == ConnectionClosed {
+		event.Reason = c.closeReason
+		event.Err = c.closeErr
+	}
+	c.stateMut.RUnlock()
+
+	c.connObserver.ObserveConnection(event)
+}
+
+// setCloseErr records the error that caused the connection to close, if no
+// error has been recorded yet.
+func (c *Connection) setCloseErr(err error) {
+	c.stateMut.Lock()
+	if c.closeErr == nil {
+		c.closeErr = err
+	}
+	c.stateMut.Unlock()
+}
+
+// closeReasonField returns the "reason" field of the fields passed to close.
+func closeReasonField(fields []LogField) string {
+	for _, f := range fields {
+		if reason, ok := f.Value.(string); ok && f.Key == "reason" {
+			return reason
+		}
+	}
+	return ""
+}
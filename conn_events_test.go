@@ -0,0 +1,141 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// connEventRecorder records the connection events of a channel.
+type connEventRecorder struct {
+	sync.Mutex
+	events []ConnectionEvent
+}
+
+func (r *connEventRecorder) ObserveConnection(event ConnectionEvent) {
+	r.Lock()
+	defer r.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *connEventRecorder) get() []ConnectionEvent {
+	r.Lock()
+	defer r.Unlock()
+	return append([]ConnectionEvent(nil), r.events...)
+}
+
+func (r *connEventRecorder) types() []ConnectionEventType {
+	var types []ConnectionEventType
+	for _, e := range r.get() {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+// waitForClosed waits till the recorder has a ConnectionClosed event.
+func (r *connEventRecorder) waitForClosed(t *testing.T) {
+	require.True(t, testutils.WaitFor(time.Second, func() bool {
+		events := r.get()
+		return len(events) > 0 && events[len(events)-1].Type == ConnectionClosed
+	}), "Connection was not closed, got events %v", r.types())
+}
+
+func TestConnectionObserver(t *testing.T) {
+	serverEvents := &connEventRecorder{}
+	sopts := testutils.NewOpts()
+	sopts.ConnectionObserver = serverEvents
+	server := testutils.NewServer(t, sopts)
+	defer server.Close()
+	testutils.RegisterEcho(server, nil)
+
+	clientEvents := &connEventRecorder{}
+	copts := testutils.NewOpts()
+	copts.ConnectionObserver = clientEvents
+	client := testutils.NewClient(t, copts)
+
+	testutils.AssertEcho(t, client, server.PeerInfo().HostPort, server.ServiceName())
+	client.Close()
+	clientEvents.waitForClosed(t)
+	serverEvents.waitForClosed(t)
+
+	wantTypes := []ConnectionEventType{
+		ConnectionConnected,
+		ConnectionHandshakeCompleted,
+		ConnectionStateChanged,
+		ConnectionStateChanged,
+		ConnectionStateChanged,
+		ConnectionClosed,
+	}
+	assert.Equal(t, wantTypes, clientEvents.types(), "Unexpected client events")
+	assert.Equal(t, wantTypes, serverEvents.types(), "Unexpected server events")
+
+	events := clientEvents.get()
+	assert.Equal(t, "outbound", events[0].Direction, "Unexpected direction")
+	assert.Equal(t, server.PeerInfo().HostPort, events[0].RemoteAddr, "Unexpected remote address")
+	assert.Zero(t, events[0].ConnectionID, "Connection ID should not be set before the handshake")
+	assert.Equal(t, server.PeerInfo().HostPort, events[1].RemotePeer.HostPort, "Unexpected remote peer")
+	assert.NotZero(t, events[1].ConnectionID, "Missing connection ID")
+	assert.Equal(t, "connectionActive", events[1].State, "Unexpected state")
+	assert.Equal(t, "connectionStartClose", events[2].State, "Unexpected state")
+	assert.Equal(t, "channel closing", events[2].Reason, "Unexpected close reason")
+	assert.Equal(t, "connectionClosed", events[5].State, "Unexpected state")
+	assert.Equal(t, "channel closing", events[5].Reason, "Unexpected close reason")
+	assert.NoError(t, events[5].Err, "Unexpected close error")
+
+	events = serverEvents.get()
+	assert.Equal(t, "inbound", events[0].Direction, "Unexpected direction")
+	assert.Equal(t, client.PeerInfo().ProcessName, events[1].RemotePeer.ProcessName, "Unexpected remote peer")
+	assert.Equal(t, "network connection EOF", events[5].Reason, "Unexpected close reason")
+	assert.Error(t, events[5].Err, "Server should report the network error")
+}
+
+func TestConnectionObserverHandshakeFailed(t *testing.T) {
+	events := &connEventRecorder{}
+	opts := testutils.NewOpts().AddLogFilter("Failed during connection handshake.", 1)
+	opts.ConnectionObserver = events
+	server := testutils.NewServer(t, opts)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.PeerInfo().HostPort)
+	require.NoError(t, err, "Dial failed")
+	_, err = conn.Write([]byte("not a tchannel init request"))
+	require.NoError(t, err, "Write failed")
+	// The server waits for the rest of the frame until the connection is closed.
+	require.NoError(t, conn.Close(), "Close failed")
+
+	require.True(t, testutils.WaitFor(time.Second, func() bool {
+		return len(events.get()) == 2
+	}), "Missing handshake failure, got events %v", events.types())
+	got := events.get()
+	assert.Equal(t, []ConnectionEventType{ConnectionConnected, ConnectionHandshakeFailed}, events.types(), "Unexpected events")
+	assert.Equal(t, conn.LocalAddr().String(), got[1].RemoteAddr, "Unexpected remote address")
+	assert.Error(t, got[1].Err, "Missing handshake error")
+}
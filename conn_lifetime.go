@@ -0,0 +1,147 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tchannel
+
+import (
+	"context"
+	"time"
+
+	"github.com/uber/tchannel-go/trand"
+)
+
+var connAgeRng = trand.NewSeeded()
+
+// connectionExpiry returns when a connection created at now exceeds the
+// MaxConnectionAge, or the zero time if connections have no maximum age.
+func (ch *Channel) connectionExpiry(now time.Time) time.Time {
+	if ch.maxConnectionAge <= 0 {
+		return time.Time{}
+	}
+	jitter := time.Duration(connAgeRng.Int63n(int64(ch.maxConnectionAge/10) + 1))
+	return now.Add(ch.maxConnectionAge - jitter)
+}
+
+// expired returns whether the connection has exceeded the MaxConnectionAge.
+func (c *Connection) expired(now time.Time) bool {
+	return !c.expiresAt.IsZero() && !now.Before(c.expiresAt)
+}
+
+// startConnectionSweep periodically closes idle connections and connections
+// that have exceeded the MaxConnectionAge, if either is configured. The sweep
+// stops once the channel is closed.
+func (ch *Channel) startConnectionSweep() {
+	if ch.connectionSweepInterval() <= 0 {
+		return
+	}
+
+	ch.mutable.Lock()
+	ch.mutable.sweepTimer = time.AfterFunc(ch.connectionSweepInterval(), ch.sweepConnections)
+	ch.mutable.Unlock()
+}
+
+// connectionSweepInterval returns the shortest interval needed by the
+// configured limits, or 0 if there are none.
+func (ch *Channel) connectionSweepInterval() time.Duration {
+	var interval time.Duration
+	for _, d := range []time.Duration{
+		ch.connectionPool.IdleTimeout / 2,
+		ch.maxIdleTime / 2,
+		ch.maxConnectionAge / 10,
+	} {
+		if d > 0 && (interval == 0 || d < interval) {
+			interval = d
+		}
+	}
+	return interval
+}
+
+func (ch *Channel) sweepConnections() {
+	now := time.Now()
+	for _, peer := range ch.RootPeers().Copy() {
+		peer.pool.closeIdle(peer, now)
+		ch.closeExpired(peer, now)
+	}
+
+	ch.mutable.Lock()
+	if ch.mutable.state == ChannelClient || ch.mutable.state == ChannelListening {
+		ch.mutable.sweepTimer.Reset(ch.connectionSweepInterval())
+	}
+	ch.mutable.Unlock()
+}
+
+// closeExpired gracefully closes the peer's connections that have exceeded
+// the MaxIdleTime or MaxConnectionAge.
+func (ch *Channel) closeExpired(peer *Peer, now time.Time) {
+	if ch.maxIdleTime <= 0 && ch.maxConnectionAge <= 0 {
+		return
+	}
+
+	var idle, expired []*Connection
+	peer.RLock()
+	for _, conns := range [][]*Connection{peer.inboundConnections, peer.outboundConnections} {
+		for _, c := range conns {
+			switch {
+			case !c.IsActive():
+			case ch.maxIdleTime > 0 && c.idleSince(now) >= ch.maxIdleTime:
+				idle = append(idle, c)
+			case c.expired(now):
+				expired = append(expired, c)
+			}
+		}
+	}
+	peer.RUnlock()
+
+	for _, c := range idle {
+		c.close(LogField{"reason", "max idle time"})
+	}
+	for _, c := range expired {
+		ch.replaceExpired(peer, c)
+	}
+}
+
+// replaceExpired closes a connection that has exceeded the MaxConnectionAge.
+// Inbound connections are closed immediately, and the remote peer will
+// reconnect when it next makes a call. Outbound connections are replaced
+// using replaceOutbound.
+func (ch *Channel) replaceExpired(peer *Peer, c *Connection) {
+	if c.outboundHP == "" {
+		c.close(LogField{"reason", "max connection age"})
+		return
+	}
+	go ch.replaceOutbound(peer, c, "max connection age")
+}
+
+// replaceOutbound closes an outbound connection once a new connection to the
+// peer has been created, so calls are not delayed by connecting. If
+// connecting fails, the connection is kept and may be replaced later.
+func (ch *Channel) replaceOutbound(peer *Peer, c *Connection, reason string) {
+	if c.replacing.Swap(true) {
+		return
+	}
+	defer c.replacing.Store(false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultConnectTimeout)
+	defer cancel()
+	if _, err := peer.Connect(ctx); err != nil {
+		c.log.WithFields(ErrField(err), LogField{"reason", reason}).Warn("Failed to replace outbound connection.")
+		return
+	}
+	c.close(LogField{"reason", reason})
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tchannel_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxIdleTime(t *testing.T) {
+	opts := testutils.NewOpts().NoRelay()
+	opts.MaxIdleTime = 20 * time.Millisecond
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		testutils.RegisterEcho(ts.Server(), nil)
+		client := ts.NewClient(nil)
+
+		ctx, cancel := NewContext(testutils.Timeout(time.Second))
+		defer cancel()
+		_, _, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "echo", nil, nil)
+		require.NoError(t, err, "Call failed")
+
+		peer, ok := client.RootPeers().Get(ts.HostPort())
+		require.True(t, ok, "Peer not found")
+		assert.True(t, testutils.WaitFor(time.Second, func() bool {
+			return numOutbound(peer) == 0
+		}), "Idle inbound connection was not closed by the server")
+
+		// Calls reconnect once the idle connection is closed.
+		_, _, _, err = raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "echo", nil, nil)
+		assert.NoError(t, err, "Call after idle close failed")
+	})
+}
+
+func TestMaxConnectionAge(t *testing.T) {
+	testutils.WithTestServer(t, nil, func(ts *testutils.TestServer) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		testutils.RegisterEcho(ts.Server(), nil)
+		testutils.RegisterFunc(ts.Server(), "block", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+			close(started)
+			<-release
+			return &raw.Res{}, nil
+		})
+
+		clientOpts := testutils.NewOpts()
+		clientOpts.MaxConnectionAge = 50 * time.Millisecond
+		client := ts.NewClient(clientOpts)
+
+		ctx, cancel := NewContext(testutils.Timeout(time.Second))
+		defer cancel()
+
+		callErr := make(chan error, 1)
+		go func() {
+			_, _, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "block", nil, nil)
+			callErr <- err
+		}()
+		<-started
+
+		peer, ok := client.RootPeers().Get(ts.HostPort())
+		require.True(t, ok, "Peer not found")
+		oldConn, err := peer.GetConnection(ctx)
+		require.NoError(t, err, "GetConnection failed")
+
+		// The expired connection is replaced, and closes once its call completes.
+		require.True(t, testutils.WaitFor(time.Second, func() bool {
+			conn, err := peer.GetConnection(ctx)
+			return err == nil && conn != oldConn && !oldConn.IsActive()
+		}), "Expired connection was not replaced")
+		_, _, _, err = raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "echo", nil, nil)
+		assert.NoError(t, err, "Call on the replacement connection failed")
+
+		close(release)
+		assert.NoError(t, <-callErr, "In-flight call on the expired connection failed")
+	})
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"context"
+	"time"
+
+	"github.com/uber-go/atomic"
+)
+
+// ConnectionPoolOptions configures the pool of connections used to make
+// outbound calls to each peer.
+type ConnectionPoolOptions struct {
+	// MaxConnectionsPerPeer is the maximum number of connections to a peer,
+	// including inbound connections from the peer. When every connection to
+	// the peer has calls in-flight, a new outbound connection is created in
+	// the background until this limit is reached. Calls are sent on the
+	// connection with the fewest in-flight calls. Zero or one disables the
+	// pool, and calls use a single connection unless the peer connects to us.
+	MaxConnectionsPerPeer int
+
+	// MinIdleConnections is the number of connections to a peer that are kept
+	// open when idle connections are closed.
+	MinIdleConnections int
+
+	// IdleTimeout is the duration after which an outbound connection without
+	// any calls is closed. Zero disables closing idle connections.
+	IdleTimeout time.Duration
+}
+
+func (o ConnectionPoolOptions) enabled() bool {
+	return o.MaxConnectionsPerPeer > 1 || o.IdleTimeout > 0
+}
+
+// connPool manages the connections to a single peer.
+type connPool struct {
+	opts ConnectionPoolOptions
+
+	// connecting is set while a new connection is being created to grow the pool.
+	connecting atomic.Bool
+}
+
+func newConnPool(opts ConnectionPoolOptions) *connPool {
+	if !opts.enabled() {
+		return nil
+	}
+	return &connPool{opts: opts}
+}
+
+// shouldGrow returns whether a new connection should be created given that
+// the least loaded connection to the peer is conn, and there are numConns
+// connections to the peer.
+func (p *connPool) shouldGrow(conn *Connection, numConns int) bool {
+	return numConns < p.opts.MaxConnectionsPerPeer && conn.outbound.count() > 0
+}
+
+// grow creates a new outbound connection to the peer in the background, unless
+// another connection is already being created.
+func (p *connPool) grow(peer *Peer) {
+	if p.connecting.Swap(true) {
+		return
+	}
+
+	go func() {
+		defer p.connecting.Store(false)
+
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultConnectTimeout)
+		defer cancel()
+		// Errors are logged by Connect, and calls continue to use the
+		// existing connections.
+		peer.Connect(ctx)
+	}()
+}
+
+// idleConnectionsLocked returns the outbound connections to the peer that have had
+// no calls since the idle timeout, leaving at least MinIdleConnections open.
+// The peer must be read-locked.
+func (p *connPool) idleConnectionsLocked(peer *Peer, now time.Time) []*Connection {
+	var (
+		idle      []*Connection
+		numActive int
+	)
+	for _, c := range peer.inboundConnections {
+		if c.IsActive() {
+			numActive++
+		}
+	}
+	for _, c := range peer.outboundConnections {
+		if !c.IsActive() {
+			continue
+		}
+		numActive++
+		if c.idleSince(now) >= p.opts.IdleTimeout {
+			idle = append(idle, c)
+		}
+	}
+
+	if canClose := numActive - p.opts.MinIdleConnections; len(idle) > canClose {
+		if canClose < 0 {
+			canClose = 0
+		}
+		idle = idle[:canClose]
+	}
+	return idle
+}
+
+// closeIdle closes the peer's idle outbound connections.
+func (p *connPool) closeIdle(peer *Peer, now time.Time) {
+	if p == nil || p.opts.IdleTimeout <= 0 {
+		return
+	}
+
+	peer.RLock()
+	idle := p.idleConnectionsLocked(peer, now)
+	peer.RUnlock()
+
+	for _, c := range idle {
+		c.close(LogField{"reason", "idle timeout"})
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func numOutbound(p *Peer) int {
+	_, outbound := p.NumConnections()
+	return outbound
+}
+
+func TestConnectionPoolGrows(t *testing.T) {
+	testutils.WithTestServer(t, nil, func(ts *testutils.TestServer) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		testutils.RegisterFunc(ts.Server(), "block", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+			started <- struct{}{}
+			<-release
+			return &raw.Res{}, nil
+		})
+
+		clientOpts := testutils.NewOpts()
+		clientOpts.ConnectionPool = ConnectionPoolOptions{MaxConnectionsPerPeer: 3}
+		client := ts.NewClient(clientOpts)
+
+		var wg sync.WaitGroup
+		startCall := func() {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ctx, cancel := NewContext(testutils.Timeout(time.Second))
+				defer cancel()
+				_, _, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "block", nil, nil)
+				assert.NoError(t, err, "Call failed")
+			}()
+			<-started
+		}
+
+		startCall()
+		peer, ok := client.RootPeers().Get(ts.HostPort())
+		require.True(t, ok, "Peer not found")
+		require.Equal(t, 1, numOutbound(peer), "Expected a single connection for the first call")
+
+		// A connection is added whenever every connection is busy, until the limit.
+		for i, want := range []int{2, 2, 3, 3, 3} {
+			startCall()
+			require.True(t, testutils.WaitFor(time.Second, func() bool {
+				return numOutbound(peer) == want
+			}), "Call %v: expected %v connections, got %v", i, want, numOutbound(peer))
+		}
+
+		// Calls are sent on the least loaded connection.
+		state := client.IntrospectState(nil)
+		conns := state.RootPeers[ts.HostPort()].OutboundConnections
+		require.Len(t, conns, 3, "Unexpected number of connections")
+		for _, c := range conns {
+			assert.Equal(t, 2, c.OutboundExchange.Count, "Calls were not balanced across connections")
+		}
+
+		close(release)
+		wg.Wait()
+	})
+}
+
+func TestConnectionPoolIdleTimeout(t *testing.T) {
+	testutils.WithTestServer(t, nil, func(ts *testutils.TestServer) {
+		testutils.RegisterEcho(ts.Server(), nil)
+
+		newClient := func(minIdle int) (*Channel, *Peer) {
+			clientOpts := testutils.NewOpts()
+			clientOpts.ConnectionPool = ConnectionPoolOptions{
+				MinIdleConnections: minIdle,
+				IdleTimeout:        20 * time.Millisecond,
+			}
+			client := ts.NewClient(clientOpts)
+
+			ctx, cancel := NewContext(testutils.Timeout(time.Second))
+			defer cancel()
+			_, _, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "echo", nil, nil)
+			require.NoError(t, err, "Call failed")
+
+			peer, ok := client.RootPeers().Get(ts.HostPort())
+			require.True(t, ok, "Peer not found")
+			return client, peer
+		}
+
+		_, idlePeer := newClient(0)
+		assert.True(t, testutils.WaitFor(time.Second, func() bool {
+			return numOutbound(idlePeer) == 0
+		}), "Idle connection was not closed")
+
+		_, keptPeer := newClient(1)
+		time.Sleep(testutils.Timeout(100 * time.Millisecond))
+		assert.Equal(t, 1, numOutbound(keptPeer), "Connection below MinIdleConnections should not be closed")
+	})
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"time"
+
+	"github.com/uber-go/atomic"
+)
+
+// connectionStats counts the frames and bytes sent and received on a connection.
+type connectionStats struct {
+	framesSent  atomic.Uint64
+	framesRecvd atomic.Uint64
+	bytesSent   atomic.Uint64
+	bytesRecvd  atomic.Uint64
+
+	// reported is the state when stats were last reported. It is only
+	// accessed by the channel's stats timer.
+	reported ConnectionStatsRuntimeState
+}
+
+func (s *connectionStats) sent(f *Frame) {
+	s.framesSent.Inc()
+	s.bytesSent.Add(uint64(f.frameSize()))
+}
+
+func (s *connectionStats) recvd(f *Frame) {
+	s.framesRecvd.Inc()
+	s.bytesRecvd.Add(uint64(f.frameSize()))
+}
+
+func (c *Connection) introspectStats() ConnectionStatsRuntimeState {
+	return ConnectionStatsRuntimeState{
+		FramesSent:     c.stats.framesSent.Load(),
+		FramesRecvd:    c.stats.framesRecvd.Load(),
+		BytesSent:      c.stats.bytesSent.Load(),
+		BytesRecvd:     c.stats.bytesRecvd.Load(),
+		SendBufferUsed: len(c.sendCh),
+		SendBufferSize: cap(c.sendCh),
+	}
+}
+
+// peerStats aggregates the traffic on all connections to a single peer
+// since stats were last reported.
+type peerStats struct {
+	ConnectionStatsRuntimeState
+}
+
+func (p *peerStats) add(c *Connection) {
+	cur := c.introspectStats()
+	last := c.stats.reported
+	c.stats.reported = cur
+
+	p.FramesSent += cur.FramesSent - last.FramesSent
+	p.FramesRecvd += cur.FramesRecvd - last.FramesRecvd
+	p.BytesSent += cur.BytesSent - last.BytesSent
+	p.BytesRecvd += cur.BytesRecvd - last.BytesRecvd
+	if cur.SendBufferUsed > p.SendBufferUsed {
+		p.SendBufferUsed = cur.SendBufferUsed
+	}
+}
+
+// startConnectionStats periodically reports connection stats if
+// ConnectionStatsInterval is set. Reporting stops once the channel is closed.
+func (ch *Channel) startConnectionStats() {
+	if ch.connStatsInterval <= 0 {
+		return
+	}
+
+	ch.mutable.Lock()
+	ch.mutable.statsTimer = time.AfterFunc(ch.connStatsInterval, ch.reportConnectionStats)
+	ch.mutable.Unlock()
+}
+
+// reportConnectionStats reports the traffic on every connection since the last
+// report, aggregated by the remote peer. The send buffer gauge is the occupancy
+// of the fullest connection to the peer, since a single saturated connection
+// delays all calls sent on it.
+func (ch *Channel) reportConnectionStats() {
+	ch.mutable.RLock()
+	conns := make([]*Connection, 0, len(ch.mutable.conns))
+	for _, c := range ch.mutable.conns {
+		conns = append(conns, c)
+	}
+	ch.mutable.RUnlock()
+
+	peers := make(map[string]*peerStats)
+	for _, c := range conns {
+		hostPort := c.RemotePeerInfo().HostPort
+		p, ok := peers[hostPort]
+		if !ok {
+			p = &peerStats{}
+			peers[hostPort] = p
+		}
+		p.add(c)
+	}
+
+	for hostPort, p := range peers {
+		tags := make(map[string]string, len(ch.commonStatsTags)+1)
+		for k, v := range ch.commonStatsTags {
+			tags[k] = v
+		}
+		tags["peer"] = hostPort
+
+		ch.statsReporter.IncCounter("connection.frames.sent", tags, int64(p.FramesSent))
+		ch.statsReporter.IncCounter("connection.frames.recvd", tags, int64(p.FramesRecvd))
+		ch.statsReporter.IncCounter("connection.bytes.sent", tags, int64(p.BytesSent))
+		ch.statsReporter.IncCounter("connection.bytes.recvd", tags, int64(p.BytesRecvd))
+		ch.statsReporter.UpdateGauge("connection.send-buffer", tags, int64(p.SendBufferUsed))
+	}
+
+	ch.mutable.Lock()
+	if ch.mutable.state == ChannelClient || ch.mutable.state == ChannelListening {
+		ch.mutable.statsTimer.Reset(ch.connStatsInterval)
+	}
+	ch.mutable.Unlock()
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// peerStatsReporter records connection stats by the peer tag.
+type peerStatsReporter struct {
+	sync.Mutex
+	counters map[string]int64
+	gauges   map[string]int64
+}
+
+func newPeerStatsReporter() *peerStatsReporter {
+	return &peerStatsReporter{
+		counters: make(map[string]int64),
+		gauges:   make(map[string]int64),
+	}
+}
+
+func (r *peerStatsReporter) IncCounter(name string, tags map[string]string, value int64) {
+	r.Lock()
+	defer r.Unlock()
+	if peer, ok := tags["peer"]; ok {
+		r.counters[name+" "+peer] += value
+	}
+}
+
+func (r *peerStatsReporter) UpdateGauge(name string, tags map[string]string, value int64) {
+	r.Lock()
+	defer r.Unlock()
+	if peer, ok := tags["peer"]; ok {
+		r.gauges[name+" "+peer] = value
+	}
+}
+
+func (r *peerStatsReporter) RecordTimer(name string, tags map[string]string, d time.Duration) {}
+
+func (r *peerStatsReporter) counter(name, peer string) int64 {
+	r.Lock()
+	defer r.Unlock()
+	return r.counters[name+" "+peer]
+}
+
+func outboundConnStats(t *testing.T, ch *Channel, hostPort string) ConnectionStatsRuntimeState {
+	conns := ch.IntrospectState(nil).RootPeers[hostPort].OutboundConnections
+	require.Len(t, conns, 1, "Expected a single outbound connection")
+	return conns[0].Stats
+}
+
+func TestConnectionStatsIntrospection(t *testing.T) {
+	opts := testutils.NewOpts().NoRelay()
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		testutils.RegisterEcho(ts.Server(), nil)
+		client := ts.NewClient(nil)
+		testutils.AssertEcho(t, client, ts.HostPort(), ts.ServiceName())
+
+		stats := outboundConnStats(t, client, ts.HostPort())
+		// The init handshake is not counted, so only the call request is sent
+		// and the call response received.
+		assert.Equal(t, uint64(1), stats.FramesSent, "Unexpected frames sent")
+		assert.Equal(t, uint64(1), stats.FramesRecvd, "Unexpected frames received")
+		assert.True(t, stats.BytesSent > 0, "Expected bytes to be sent")
+		assert.True(t, stats.BytesRecvd > 0, "Expected bytes to be received")
+		assert.Equal(t, 0, stats.SendBufferUsed, "Send buffer should be empty")
+		assert.True(t, stats.SendBufferSize > 0, "Expected the send buffer size")
+
+		// The server received everything the client sent.
+		var serverStats ConnectionStatsRuntimeState
+		assert.True(t, testutils.WaitFor(time.Second, func() bool {
+			for _, peer := range ts.Server().IntrospectState(nil).RootPeers {
+				for _, conn := range peer.InboundConnections {
+					serverStats = conn.Stats
+				}
+			}
+			return serverStats.BytesRecvd == stats.BytesSent
+		}), "Server received %v bytes, client sent %v", serverStats.BytesRecvd, stats.BytesSent)
+	})
+}
+
+func TestConnectionStatsReported(t *testing.T) {
+	testutils.WithTestServer(t, nil, func(ts *testutils.TestServer) {
+		testutils.RegisterEcho(ts.Server(), nil)
+		reporter := newPeerStatsReporter()
+		opts := testutils.NewOpts()
+		opts.StatsReporter = reporter
+		opts.ConnectionStatsInterval = 10 * time.Millisecond
+		client := ts.NewClient(opts)
+
+		testutils.AssertEcho(t, client, ts.HostPort(), ts.ServiceName())
+		stats := outboundConnStats(t, client, ts.HostPort())
+		require.True(t, testutils.WaitFor(time.Second, func() bool {
+			return reporter.counter("connection.bytes.sent", ts.HostPort()) == int64(stats.BytesSent)
+		}), "Bytes sent were not reported")
+
+		// Only the traffic since the last report is added to counters.
+		ctx, cancel := NewContext(testutils.Timeout(time.Second))
+		defer cancel()
+		_, _, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "echo", nil, nil)
+		require.NoError(t, err, "Call failed")
+		stats = outboundConnStats(t, client, ts.HostPort())
+		assert.True(t, testutils.WaitFor(time.Second, func() bool {
+			return reporter.counter("connection.frames.sent", ts.HostPort()) == int64(stats.FramesSent) &&
+				reporter.counter("connection.frames.recvd", ts.HostPort()) == int64(stats.FramesRecvd) &&
+				reporter.counter("connection.bytes.recvd", ts.HostPort()) == int64(stats.BytesRecvd)
+		}), "Connection stats were not reported")
+
+		reporter.Lock()
+		_, ok := reporter.gauges["connection.send-buffer "+ts.HostPort()]
+		reporter.Unlock()
+		assert.True(t, ok, "Send buffer gauge was not reported")
+	})
+}
@@ -21,6 +21,7 @@
 package tchannel
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -30,9 +31,9 @@ import (
 	"time"
 
 	"github.com/uber/tchannel-go/tos"
+	"github.com/uber/tchannel-go/typed"
 
 	"github.com/uber-go/atomic"
-	"golang.org/x/net/context"
 	"golang.org/x/net/ipv4"
 	"golang.org/x/net/ipv6"
 )
@@ -106,6 +107,10 @@ var (
 	// backed up
 	ErrSendBufferFull = errors.New("connection send buffer is full, cannot send frame")
 
+	// errDrainTimeout is used to fail in-flight calls on connections that
+	// are closed when the channel's drain timeout expires.
+	errDrainTimeout = NewSystemError(ErrCodeNetwork, "connection closed after channel drain timeout")
+
 	// ErrConnectionNotReady is no longer used.
 	ErrConnectionNotReady = errors.New("connection is not yet ready")
 )
@@ -123,6 +128,8 @@ func (e errConnectionUnknownState) Error() string {
 // ConnectionOptions are options that control the behavior of a Connection
 type ConnectionOptions struct {
 	// The frame pool, allowing better management of frame buffers. Defaults to using raw heap.
+	// If the pool is a SizedFramePool, such as one created using NewAdaptiveFramePool,
+	// frames are sized for their payload.
 	FramePool FramePool
 
 	// NOTE: This is deprecated and not used for anything.
@@ -131,11 +138,71 @@ type ConnectionOptions struct {
 	// The size of send channel buffers. Defaults to 512.
 	SendBufferSize int
 
-	// The type of checksum to use when sending messages.
+	// The type of checksum to use when sending messages. Defaults to ChecksumTypeCrc32.
 	ChecksumType ChecksumType
 
-	// ToS class name marked on outbound packets.
+	// DisableChecksums sends messages without a checksum, ignoring ChecksumType.
+	DisableChecksums bool
+
+	// StrictChecksums rejects inbound calls and responses that do not use
+	// the connection's checksum type, including those without a checksum.
+	// Messages whose checksum does not match their contents are always
+	// rejected.
+	StrictChecksums bool
+
+	// ToS class name marked on outbound packets. Calls can use connections
+	// marked with a different class using CallOptions.TosPriority.
 	TosPriority tos.ToS
+
+	// TCPKeepAlive is the keep-alive period of TCP connections. If this is 0,
+	// the Go default is used, and a negative value disables keep-alives.
+	TCPKeepAlive time.Duration
+
+	// DisableTCPNoDelay disables TCP_NODELAY, so that small writes are
+	// coalesced using Nagle's algorithm. By default, TCP_NODELAY is set.
+	DisableTCPNoDelay bool
+
+	// SocketSendBufferSize is the size of the socket's send buffer
+	// (SO_SNDBUF). If this is 0, the operating system default is used.
+	SocketSendBufferSize int
+
+	// SocketRecvBufferSize is the size of the socket's receive buffer
+	// (SO_RCVBUF). If this is 0, the operating system default is used.
+	SocketRecvBufferSize int
+
+	// SendBufferFullPolicy controls how calls behave when the send buffer is
+	// full. By default, they wait for space until their context is done.
+	SendBufferFullPolicy SendBufferFullPolicy
+
+	// SendBufferHighWatermark is the number of queued frames at which
+	// OnSendBufferHighWatermark is called. Zero disables the callback.
+	SendBufferHighWatermark int
+
+	// OnSendBufferHighWatermark is called when the number of frames queued in
+	// the send buffer reaches SendBufferHighWatermark. It is not called again
+	// until the buffer drains below the watermark. It must not block.
+	OnSendBufferHighWatermark func(c *Connection, queued int)
+}
+
+// EffectiveConnectionOptions are the options a connection is using once
+// defaults have been applied. It is a snapshot, and changing it has no effect
+// on the connection.
+type EffectiveConnectionOptions struct {
+	// SendBufferSize is the size of the connection's send channel buffer.
+	SendBufferSize int `json:"sendBufferSize"`
+
+	// ChecksumType is the type of checksum used when sending messages.
+	ChecksumType ChecksumType `json:"checksumType"`
+
+	// StrictChecksums is whether messages that don't use ChecksumType
+	// are rejected.
+	StrictChecksums bool `json:"strictChecksums"`
+
+	// TosPriority is the ToS class marked on outbound packets, zero if unset.
+	TosPriority tos.ToS `json:"tosPriority"`
+
+	// SendBufferFullPolicy is how calls behave when the send buffer is full.
+	SendBufferFullPolicy SendBufferFullPolicy `json:"sendBufferFullPolicy"`
 }
 
 // connectionEvents are the events that can be triggered by a connection.
@@ -171,6 +238,11 @@ type Connection struct {
 	commonStatsTags map[string]string
 	relay           *Relayer
 
+	// classConn is set if the connection was created for calls that use a
+	// ToS class other than the channel's (see CallOptions.TosPriority), and
+	// is not used for other calls.
+	classConn bool
+
 	// outboundHP is the host:port we used to create this outbound connection.
 	// It may not match remotePeerInfo.HostPort, in which case the connection is
 	// added to peers for both host:ports. For inbound connections, this is empty.
@@ -181,8 +253,33 @@ type Connection struct {
 	closeNetworkCalled atomic.Int32
 	// stoppedExchanges is atomically set when exchanges are stopped due to error.
 	stoppedExchanges atomic.Uint32
+
+	// failed is set if the connection was closed due to a connection error.
+	failed atomic.Bool
 	// pendingMethods is the number of methods running that may block closing of sendCh.
 	pendingMethods atomic.Int64
+	// remoteCompressions are the compressions that the remote peer can decompress.
+	remoteCompressions map[string]struct{}
+	// remoteCallControl are the call control frames that the remote peer handles.
+	remoteCallControl callControlSupport
+	// lastActivity is the time, in Unix nanoseconds, that an exchange was
+	// last added or removed.
+	lastActivity atomic.Int64
+	// expiresAt is when the connection exceeds the channel's MaxConnectionAge.
+	// It is zero if connections have no maximum age.
+	expiresAt time.Time
+	// replacing is set while a replacement is connecting for an expired
+	// outbound connection.
+	replacing atomic.Bool
+	// stats counts the frames and bytes sent and received.
+	stats connectionStats
+	// aboveWatermark is set once the send buffer reaches the high watermark,
+	// and cleared when it drains below it.
+	aboveWatermark atomic.Bool
+	// closeReason and closeErr are why the connection was closed, and are
+	// reported to the channel's ConnectionObserver. Protected by stateMut.
+	closeReason string
+	closeErr    error
 	// remotePeerAddress is used as a cache for remote peer address parsed into individual
 	// components that can be used to set peer tags on OpenTracing Span.
 	remotePeerAddress peerAddressComponents
@@ -228,7 +325,9 @@ func getTimeout(ctx context.Context) time.Duration {
 }
 
 func (co ConnectionOptions) withDefaults() ConnectionOptions {
-	if co.ChecksumType == ChecksumTypeNone {
+	if co.DisableChecksums {
+		co.ChecksumType = ChecksumTypeNone
+	} else if co.ChecksumType == ChecksumTypeNone || !co.ChecksumType.valid() {
 		co.ChecksumType = ChecksumTypeCrc32
 	}
 	if co.FramePool == nil {
@@ -257,8 +356,48 @@ func (ch *Channel) setConnectionTosPriority(tosPriority tos.ToS, c net.Conn) err
 	return err
 }
 
-func (ch *Channel) newConnection(conn net.Conn, initialID uint32, outboundHP string, remotePeer PeerInfo, remotePeerAddress peerAddressComponents, events connectionEvents) *Connection {
-	opts := ch.connectionOptions.withDefaults()
+// setSocketOptions applies the TCP options in opts to c.
+func setSocketOptions(opts ConnectionOptions, c net.Conn) error {
+	tcpConn, isTCP := c.(*net.TCPConn)
+	if !isTCP {
+		return nil
+	}
+
+	if opts.TCPKeepAlive > 0 {
+		if err := tcpConn.SetKeepAlive(true); err != nil {
+			return err
+		}
+		if err := tcpConn.SetKeepAlivePeriod(opts.TCPKeepAlive); err != nil {
+			return err
+		}
+	} else if opts.TCPKeepAlive < 0 {
+		if err := tcpConn.SetKeepAlive(false); err != nil {
+			return err
+		}
+	}
+	if opts.DisableTCPNoDelay {
+		if err := tcpConn.SetNoDelay(false); err != nil {
+			return err
+		}
+	}
+	if opts.SocketSendBufferSize > 0 {
+		if err := tcpConn.SetWriteBuffer(opts.SocketSendBufferSize); err != nil {
+			return err
+		}
+	}
+	if opts.SocketRecvBufferSize > 0 {
+		if err := tcpConn.SetReadBuffer(opts.SocketRecvBufferSize); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ch *Channel) newConnection(conn net.Conn, opts ConnectionOptions, initialID uint32, outboundHP, localHostPort string, remotePeer PeerInfo, remotePeerAddress peerAddressComponents, remoteCompressions map[string]struct{}, remoteCallControl callControlSupport, frameSize int, events connectionEvents) *Connection {
+	opts = opts.withDefaults()
+	if frameSize > MaxFrameSize {
+		opts.FramePool = newLargeFramePool(opts.FramePool, frameSize)
+	}
 
 	connID := _nextConnID.Inc()
 	log := ch.log.WithFields(LogFields{
@@ -278,6 +417,9 @@ func (ch *Channel) newConnection(conn net.Conn, initialID uint32, outboundHP str
 		log = log.WithFields(LogField{"connectionDirection", inbound})
 	}
 	peerInfo := ch.PeerInfo()
+	if localHostPort != "" {
+		peerInfo.HostPort = localHostPort
+	}
 
 	c := &Connection{
 		channelConnectionCommon: ch.channelConnectionCommon,
@@ -292,20 +434,29 @@ func (ch *Channel) newConnection(conn net.Conn, initialID uint32, outboundHP str
 		remotePeerInfo:    remotePeer,
 		remotePeerAddress: remotePeerAddress,
 		outboundHP:        outboundHP,
-		inbound:           newMessageExchangeSet(log, messageExchangeSetInbound),
-		outbound:          newMessageExchangeSet(log, messageExchangeSetOutbound),
-		handler:           ch.handler,
-		events:            events,
-		commonStatsTags:   ch.commonStatsTags,
+		classConn:         opts.TosPriority != ch.connectionOptions.TosPriority,
+
+		remoteCompressions: remoteCompressions,
+		remoteCallControl:  remoteCallControl,
+		inbound:            newMessageExchangeSet(log, messageExchangeSetInbound),
+		outbound:           newMessageExchangeSet(log, messageExchangeSetOutbound),
+		handler:            chainInboundInterceptors(ch.handler, ch.inboundInterceptors),
+		events:             events,
+		commonStatsTags:    ch.commonStatsTags,
 	}
 
 	if tosPriority := opts.TosPriority; tosPriority > 0 {
-		if err := ch.setConnectionTosPriority(tosPriority, conn); err != nil {
+		if err := ch.setConnectionTosPriority(tosPriority, rawConn(conn)); err != nil {
 			log.WithFields(ErrField(err)).Error("Failed to set ToS priority.")
 		}
 	}
+	if err := setSocketOptions(opts, rawConn(conn)); err != nil {
+		log.WithFields(ErrField(err)).Error("Failed to set socket options.")
+	}
 
 	c.nextMessageID.Store(initialID)
+	c.lastActivity.Store(time.Now().UnixNano())
+	c.expiresAt = ch.connectionExpiry(time.Now())
 	c.log = log
 	c.inbound.onRemoved = c.checkExchanges
 	c.outbound.onRemoved = c.checkExchanges
@@ -325,9 +476,19 @@ func (ch *Channel) newConnection(conn net.Conn, initialID uint32, outboundHP str
 }
 
 func (c *Connection) onExchangeAdded() {
+	c.lastActivity.Store(time.Now().UnixNano())
 	c.callOnExchangeChange()
 }
 
+// idleSince returns how long the connection has had no in-flight calls,
+// or 0 if there are calls in-flight.
+func (c *Connection) idleSince(now time.Time) time.Duration {
+	if c.inbound.count() > 0 || c.outbound.count() > 0 {
+		return 0
+	}
+	return now.Sub(time.Unix(0, c.lastActivity.Load()))
+}
+
 // IsActive returns whether this connection is in an active state.
 func (c *Connection) IsActive() bool {
 	return c.readState() == connectionActive
@@ -343,6 +504,7 @@ func (c *Connection) callOnActive() {
 		}...)
 	}
 	log.Info("Created new active connection.")
+	c.observe(ConnectionEvent{Type: ConnectionHandshakeCompleted})
 
 	if f := c.events.OnActive; f != nil {
 		f(c)
@@ -413,16 +575,22 @@ func (c *Connection) handlePingReq(frame *Frame) {
 	}
 
 	pingRes := &pingRes{id: frame.Header.ID}
-	if err := c.sendMessage(pingRes); err != nil {
+	if err := c.sendMessage(pingRes); err == ErrSendBufferFull {
+		// The peer's ping will time out, but the calls on the connection
+		// should not fail because the buffer is temporarily full.
+		c.log.WithFields(
+			LogField{"remotePeer", c.remotePeerInfo},
+			LogField{"id", frame.Header.ID},
+		).Warn("Dropping ping response as the send buffer is full.")
+	} else if err != nil {
 		c.connectionError("send pong", err)
 	}
 }
 
 // sendMessage sends a standalone message (typically a control message)
 func (c *Connection) sendMessage(msg message) error {
-	frame := c.opts.FramePool.Get()
-	if err := frame.write(msg); err != nil {
-		c.opts.FramePool.Release(frame)
+	frame, err := newMessageFrame(c.opts.FramePool, msg)
+	if err != nil {
 		return err
 	}
 
@@ -455,6 +623,20 @@ func (c *Connection) RemotePeerInfo() PeerInfo {
 	return c.remotePeerInfo
 }
 
+// EffectiveOptions returns the options the connection is using, after defaults
+// have been applied.
+func (c *Connection) EffectiveOptions() EffectiveConnectionOptions {
+	return EffectiveConnectionOptions{
+		SendBufferSize: c.opts.SendBufferSize,
+		ChecksumType:   c.opts.ChecksumType,
+		TosPriority:    c.opts.TosPriority,
+
+		StrictChecksums: c.opts.StrictChecksums,
+
+		SendBufferFullPolicy: c.opts.SendBufferFullPolicy,
+	}
+}
+
 // NextMessageID reserves the next available message id for this connection
 func (c *Connection) NextMessageID() uint32 {
 	return c.nextMessageID.Inc()
@@ -462,20 +644,19 @@ func (c *Connection) NextMessageID() uint32 {
 
 // SendSystemError sends an error frame for the given system error.
 func (c *Connection) SendSystemError(id uint32, span Span, err error) error {
-	frame := c.opts.FramePool.Get()
-
-	if err := frame.write(&errorMessage{
+	frame, frameErr := newMessageFrame(c.opts.FramePool, &errorMessage{
 		id:      id,
 		errCode: GetSystemErrorCode(err),
 		tracing: span,
 		message: GetSystemErrorMessage(err),
-	}); err != nil {
+	})
+	if frameErr != nil {
 
 		// This shouldn't happen - it means writing the errorMessage is broken.
 		c.log.WithFields(
 			LogField{"remotePeer", c.remotePeerInfo},
 			LogField{"id", id},
-			ErrField(err),
+			ErrField(frameErr),
 		).Warn("Couldn't create outbound frame.")
 		return fmt.Errorf("failed to create outbound error frame")
 	}
@@ -537,6 +718,8 @@ func (c *Connection) connectionError(site string, err error) error {
 		}
 	}
 	err = c.logConnectionError(site, err)
+	c.failed.Store(true)
+	c.setCloseErr(err)
 	c.close(closeLogFields...)
 
 	// On any connection error, notify the exchanges of this error.
@@ -550,6 +733,7 @@ func (c *Connection) connectionError(site string, err error) error {
 func (c *Connection) protocolError(id uint32, err error) error {
 	c.log.WithFields(ErrField(err)).Warn("Protocol error.")
 	sysErr := NewWrappedSystemError(ErrCodeProtocol, err)
+	c.setCloseErr(sysErr)
 	c.SendSystemError(id, Span{}, sysErr)
 	// Don't close the connection until the error has been sent.
 	c.close(
@@ -597,16 +781,17 @@ func (c *Connection) readState() connectionState {
 // since we cannot process new frames until the initialization is complete.
 func (c *Connection) readFrames(_ uint32) {
 	for {
-		frame := c.opts.FramePool.Get()
-		if err := frame.ReadIn(c.conn); err != nil {
+		frame, err := c.readFrame()
+		if err != nil {
 			if c.closeNetworkCalled.Load() == 0 {
 				c.connectionError("read frames", err)
 			} else {
 				c.log.Debugf("Ignoring error after connection was closed: %v", err)
 			}
-			c.opts.FramePool.Release(frame)
 			return
 		}
+		c.stats.recvd(frame)
+		c.captureFrame(frame, false /* sent */)
 
 		var releaseFrame bool
 		if c.relay == nil {
@@ -620,9 +805,62 @@ func (c *Connection) readFrames(_ uint32) {
 	}
 }
 
+// readFrame reads the next frame from the network connection. If the frame
+// pool is a SizedFramePool, the frame header is read first so the frame
+// can be sized for its payload.
+func (c *Connection) readFrame() (*Frame, error) {
+	pool, ok := c.opts.FramePool.(SizedFramePool)
+	if !ok {
+		frame := c.opts.FramePool.Get()
+		if err := frame.ReadIn(c.conn); err != nil {
+			c.opts.FramePool.Release(frame)
+			return nil, err
+		}
+		return frame, nil
+	}
+
+	var headerBuf [FrameHeaderSize]byte
+	var rbuf typed.ReadBuffer
+	rbuf.Wrap(headerBuf[:])
+	if _, err := rbuf.FillFrom(c.conn, FrameHeaderSize); err != nil {
+		return nil, err
+	}
+
+	var header FrameHeader
+	if err := header.read(&rbuf); err != nil {
+		return nil, err
+	}
+
+	payloadSize := int(header.PayloadSize())
+	if c.relay != nil && isRelayedMessageType(header.messageType) {
+		// Relayed frames may be rewritten by a RelayInterceptor, which
+		// requires a full size frame.
+		payloadSize = MaxFramePayloadSize
+	}
+
+	frame := pool.GetSized(payloadSize)
+	frame.Header = header
+	copy(frame.headerBuffer, headerBuf[:])
+	if err := frame.readPayloadIn(c.conn); err != nil {
+		pool.Release(frame)
+		return nil, err
+	}
+	return frame, nil
+}
+
+// isRelayedMessageType returns whether frames of the given type are relayed
+// by a relay connection.
+func isRelayedMessageType(t messageType) bool {
+	switch t {
+	case messageTypeCallReq, messageTypeCallReqContinue, messageTypeCallRes, messageTypeCallResContinue, messageTypeError, messageTypeCancel, messageTypeClaim:
+		return true
+	default:
+		return false
+	}
+}
+
 func (c *Connection) handleFrameRelay(frame *Frame) bool {
-	switch frame.Header.messageType {
-	case messageTypeCallReq, messageTypeCallReqContinue, messageTypeCallRes, messageTypeCallResContinue, messageTypeError:
+	if isRelayedMessageType(frame.Header.messageType) {
 		if err := c.relay.Relay(frame); err != nil {
 			c.log.WithFields(
 				ErrField(err),
@@ -631,9 +869,8 @@ func (c *Connection) handleFrameRelay(frame *Frame) bool {
 			).Error("Failed to relay frame.")
 		}
 		return false
-	default:
-		return c.handleFrameNoRelay(frame)
 	}
+	return c.handleFrameNoRelay(frame)
 }
 
 func (c *Connection) handleFrameNoRelay(frame *Frame) bool {
@@ -649,6 +886,10 @@ func (c *Connection) handleFrameNoRelay(frame *Frame) bool {
 		releaseFrame = c.handleCallRes(frame)
 	case messageTypeCallResContinue:
 		releaseFrame = c.handleCallResContinue(frame)
+	case messageTypeCancel:
+		c.handleCancel(frame)
+	case messageTypeClaim:
+		c.handleClaim(frame)
 	case messageTypePingReq:
 		c.handlePingReq(frame)
 	case messageTypePingRes:
@@ -672,11 +913,20 @@ func (c *Connection) writeFrames(_ uint32) {
 	for {
 		select {
 		case f := <-c.sendCh:
+			c.sendBufferDrained()
 			if c.log.Enabled(LogLevelDebug) {
 				c.log.Debugf("Writing frame %s", f.Header)
 			}
+			if c.faults.injectFrame(f) {
+				c.opts.FramePool.Release(f)
+				continue
+			}
 
 			err := f.WriteOut(c.conn)
+			if err == nil {
+				c.stats.sent(f)
+				c.captureFrame(f, true /* sent */)
+			}
 			c.opts.FramePool.Release(f)
 			if err != nil {
 				c.connectionError("write frames", err)
@@ -723,6 +973,7 @@ func (c *Connection) closeSendCh(connID uint32) {
 
 // checkExchanges is called whenever an exchange is removed, and when Close is called.
 func (c *Connection) checkExchanges() {
+	c.lastActivity.Store(time.Now().UnixNano())
 	c.callOnExchangeChange()
 
 	moveState := func(fromState, toState connectionState) bool {
@@ -733,7 +984,14 @@ func (c *Connection) checkExchanges() {
 			c.state = toState
 			return nil
 		})
-		return err == nil
+		if err != nil {
+			return false
+		}
+		c.observe(ConnectionEvent{Type: ConnectionStateChanged})
+		if toState == connectionClosed {
+			c.observe(ConnectionEvent{Type: ConnectionClosed})
+		}
+		return true
 	}
 
 	var updated connectionState
@@ -784,6 +1042,7 @@ func (c *Connection) close(fields ...LogField) error {
 		switch c.state {
 		case connectionActive:
 			c.state = connectionStartClose
+			c.closeReason = closeReasonField(fields)
 		default:
 			return fmt.Errorf("connection must be Active to Close")
 		}
@@ -795,6 +1054,7 @@ func (c *Connection) close(fields ...LogField) error {
 	c.log.WithFields(
 		LogField{"newState", c.readState()},
 	).Debug("Connection state updated in Close.")
+	c.observe(ConnectionEvent{Type: ConnectionStateChanged})
 	c.callOnCloseStateChange()
 
 	// Check all in-flight requests to see whether we can transition the Close state.
@@ -809,6 +1069,18 @@ func (c *Connection) Close() error {
 	return c.close(LogField{"reason", "user initiated"})
 }
 
+// abort fails any in-flight exchanges with the given error, and closes the
+// network connection without waiting for the exchanges to complete.
+func (c *Connection) abort(err error) {
+	c.log.WithFields(ErrField(err)).Info("Connection aborted.")
+	c.setCloseErr(err)
+	if c.stoppedExchanges.CAS(0, 1) {
+		c.outbound.stopExchanges(err)
+		c.inbound.stopExchanges(err)
+	}
+	c.closeNetwork()
+}
+
 // closeNetwork closes the network connection and all network-related channels.
 // This should only be done in response to a fatal connection or protocol
 // error, or after all pending frames have been sent.
@@ -816,8 +1088,11 @@ func (c *Connection) closeNetwork() {
 	// NB(mmihic): The sender goroutine will exit once the connection is
 	// closed; no need to close the send channel (and closing the send
 	// channel would be dangerous since other goroutine might be sending)
+	if c.closeNetworkCalled.Inc() > 1 {
+		// The connection was already closed by abort.
+		return
+	}
 	c.log.Debugf("Closing underlying network connection")
-	c.closeNetworkCalled.Inc()
 	if err := c.conn.Close(); err != nil {
 		c.log.WithFields(
 			LogField{"remotePeer", c.remotePeerInfo},
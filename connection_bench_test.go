@@ -21,6 +21,7 @@
 package tchannel_test
 
 import (
+	"context"
 	"runtime"
 	"sync"
 	"testing"
@@ -33,7 +34,6 @@ import (
 
 	"github.com/streadway/quantile"
 	"github.com/stretchr/testify/assert"
-	"golang.org/x/net/context"
 )
 
 const benchService = "bench-server"
@@ -0,0 +1,56 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build go1.9 && linux
+// +build go1.9,linux
+
+package tchannel_test
+
+import (
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSocketOptionsApplied(t *testing.T) {
+	withSocketOptionsConn(t, func(c *net.TCPConn) {
+		rawConn, err := c.SyscallConn()
+		require.NoError(t, err, "SyscallConn failed")
+
+		getsockopt := func(level, opt int) int {
+			var (
+				v      int
+				optErr error
+			)
+			require.NoError(t, rawConn.Control(func(fd uintptr) {
+				v, optErr = syscall.GetsockoptInt(int(fd), level, opt)
+			}), "Control failed")
+			require.NoError(t, optErr, "getsockopt failed")
+			return v
+		}
+		assert.NotEqual(t, 0, getsockopt(syscall.SOL_SOCKET, syscall.SO_KEEPALIVE), "Keep-alives should be enabled")
+		assert.Equal(t, 0, getsockopt(syscall.IPPROTO_TCP, syscall.TCP_NODELAY), "TCP_NODELAY should be disabled")
+		assert.True(t, getsockopt(syscall.SOL_SOCKET, syscall.SO_SNDBUF) >= 64*1024, "Unexpected send buffer size")
+		assert.True(t, getsockopt(syscall.SOL_SOCKET, syscall.SO_RCVBUF) >= 64*1024, "Unexpected receive buffer size")
+	})
+}
@@ -21,6 +21,7 @@
 package tchannel_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -40,7 +41,6 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"golang.org/x/net/context"
 	"golang.org/x/net/ipv4"
 	"golang.org/x/net/ipv6"
 )
@@ -751,6 +751,48 @@ func TestNetDialTimeout(t *testing.T) {
 	assert.True(t, d >= timeoutPeriod, "Timeout should take more than %v, took %v", timeoutPeriod, d)
 }
 
+func TestChannelDialTimeout(t *testing.T) {
+	// Use a listener that accepts TCP connections but never responds to the
+	// init req, so the connection attempt only fails due to a timeout.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Listen failed")
+	defer ln.Close()
+
+	go func() {
+		var accepted []net.Conn
+		defer func() {
+			for _, conn := range accepted {
+				conn.Close()
+			}
+		}()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted = append(accepted, conn)
+		}
+	}()
+
+	dialTimeout := testutils.Timeout(50 * time.Millisecond)
+	opts := testutils.NewOpts().
+		SetDialTimeout(dialTimeout).
+		AddLogFilter("Failed during connection handshake", 1)
+	client := testutils.NewClient(t, opts)
+	defer client.Close()
+
+	started := time.Now()
+	ctx, cancel := NewContext(10 * time.Second)
+	defer cancel()
+
+	err = client.Ping(ctx, ln.Addr().String())
+	d := time.Since(started)
+	assert.Equal(t, ErrTimeout, err, "Ping expected to fail with timeout")
+	assert.True(t, d >= dialTimeout, "Timeout should take more than %v, took %v", dialTimeout, d)
+	assert.True(t, d < 5*dialTimeout, "Connect should fail within the dial timeout %v, took %v", dialTimeout, d)
+	assert.NoError(t, ctx.Err(), "Call context should not have expired")
+}
+
 func TestConnectTimeout(t *testing.T) {
 	opts := testutils.NewOpts().DisableLogVerification()
 	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
@@ -869,6 +911,81 @@ func TestTosPriority(t *testing.T) {
 	})
 }
 
+func TestTosPriorityPerCall(t *testing.T) {
+	ctx, cancel := NewContext(time.Second)
+	defer cancel()
+
+	opts := testutils.NewOpts().NoRelay()
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		ts.Register(raw.Wrap(newTestHandler(t)), "echo")
+		client := ts.NewClient(nil)
+
+		call := func(tosPriority tos.ToS) *Connection {
+			outbound, err := client.BeginCall(ctx, ts.HostPort(), ts.ServiceName(), "echo", &CallOptions{
+				TosPriority: tosPriority,
+			})
+			require.NoError(t, err, "BeginCall failed")
+
+			conn, netConn := OutboundConnection(outbound)
+			marked, err := isTosPriority(netConn, tosPriority)
+			require.NoError(t, err, "Checking TOS priority failed")
+			assert.True(t, marked, "Connection not marked with ToS %v", tosPriority)
+
+			_, _, _, err = raw.WriteArgs(outbound, []byte("arg2"), []byte("arg3"))
+			require.NoError(t, err, "Call failed")
+			return conn
+		}
+
+		defaultConn := call(0)
+		lowdelayConn := call(tos.Lowdelay)
+		throughputConn := call(tos.Throughput)
+		assert.NotEqual(t, defaultConn, lowdelayConn, "ToS classes should use separate connections")
+		assert.NotEqual(t, lowdelayConn, throughputConn, "ToS classes should use separate connections")
+
+		assert.Equal(t, defaultConn, call(0), "Calls without a ToS class should reuse the default connection")
+		assert.Equal(t, lowdelayConn, call(tos.Lowdelay), "Calls should reuse the connection for their ToS class")
+		assert.Equal(t, tos.Lowdelay, lowdelayConn.EffectiveOptions().TosPriority, "Unexpected ToS in options")
+
+		_, outbound := client.Peers().GetOrAdd(ts.HostPort()).NumConnections()
+		assert.Equal(t, 3, outbound, "Unexpected number of connections")
+	})
+}
+
+func TestSocketOptions(t *testing.T) {
+	withSocketOptionsConn(t, func(c *net.TCPConn) {
+		assert.NotNil(t, c, "Outbound connection should use TCP")
+	})
+}
+
+// withSocketOptionsConn makes a call from a client with all socket options
+// set, and runs f with the outbound TCP connection before completing the call.
+func withSocketOptionsConn(t *testing.T, f func(c *net.TCPConn)) {
+	ctx, cancel := NewContext(time.Second)
+	defer cancel()
+
+	opts := testutils.NewOpts().NoRelay()
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		ts.Register(raw.Wrap(newTestHandler(t)), "echo")
+
+		copts := testutils.NewOpts()
+		copts.DefaultConnectionOptions.TCPKeepAlive = time.Minute
+		copts.DefaultConnectionOptions.DisableTCPNoDelay = true
+		copts.DefaultConnectionOptions.SocketSendBufferSize = 64 * 1024
+		copts.DefaultConnectionOptions.SocketRecvBufferSize = 64 * 1024
+		client := ts.NewClient(copts)
+
+		outbound, err := client.BeginCall(ctx, ts.HostPort(), ts.ServiceName(), "echo", nil)
+		require.NoError(t, err, "BeginCall failed")
+		_, netConn := OutboundConnection(outbound)
+		tcpConn, ok := netConn.(*net.TCPConn)
+		require.True(t, ok, "Expected a TCP connection, got %T", netConn)
+		f(tcpConn)
+
+		_, _, _, err = raw.WriteArgs(outbound, []byte("arg2"), []byte("arg3"))
+		require.NoError(t, err, "Call failed")
+	})
+}
+
 func TestPeerStatusChangeClientReduction(t *testing.T) {
 	sopts := testutils.NewOpts().NoRelay()
 	testutils.WithTestServer(t, sopts, func(ts *testutils.TestServer) {
@@ -971,6 +1088,46 @@ func TestPeerStatusChangeServer(t *testing.T) {
 	assert.Len(t, changes, 0, "unexpected peer status changes")
 }
 
+func TestPeerAvailability(t *testing.T) {
+	sopts := testutils.NewOpts().NoRelay()
+	testutils.WithTestServer(t, sopts, func(ts *testutils.TestServer) {
+		server := ts.Server()
+		testutils.RegisterEcho(server, nil)
+		events := make(chan string, 4)
+
+		copts := testutils.NewOpts().
+			SetOnPeerAvailable(func(p *Peer) {
+				assert.Equal(t, ts.HostPort(), p.HostPort(), "unexpected peer")
+				events <- "available"
+			}).
+			SetOnPeerUnavailable(func(p *Peer) {
+				assert.Equal(t, ts.HostPort(), p.HostPort(), "unexpected peer")
+				i, o := p.NumConnections()
+				assert.Equal(t, 0, i+o, "unavailable peer should have no connections")
+				events <- "unavailable"
+			})
+
+		// The first connection makes the peer available.
+		client := ts.NewClient(copts)
+		require.NoError(t, testutils.CallEcho(client, ts.HostPort(), ts.ServiceName(), nil))
+		assert.Equal(t, "available", <-events, "event for first connection")
+
+		// A second connection to an available peer does not trigger an event.
+		ctx, cancel := NewContext(testutils.Timeout(100 * time.Millisecond))
+		defer cancel()
+		_, err := client.RootPeers().GetOrAdd(ts.HostPort()).Connect(ctx)
+		require.NoError(t, err, "Connect failed")
+		assert.Len(t, events, 0, "no event for second connection")
+
+		// Losing both connections only makes the peer unavailable once.
+		server.Close()
+		assert.Equal(t, "unavailable", <-events, "event for last disconnection")
+
+		client.Close()
+		assert.Len(t, events, 0, "unexpected peer availability changes")
+	})
+}
+
 func TestContextCanceledOnTCPClose(t *testing.T) {
 	// 1. Context canceled warning is expected as part of this test
 	// add log filter to ignore this error
@@ -21,9 +21,8 @@
 package tchannel
 
 import (
+	"context"
 	"time"
-
-	"golang.org/x/net/context"
 )
 
 const defaultTimeout = time.Second
@@ -42,6 +41,7 @@ type tchannelCtxParams struct {
 	options                 *CallOptions
 	retryOptions            *RetryOptions
 	connectTimeout          time.Duration
+	baggage                 map[string]string
 }
 
 // IncomingCall exposes properties for incoming calls through the context.
@@ -94,11 +94,12 @@ func WrapContextForTest(ctx context.Context, call IncomingCall) context.Context
 	return ctx
 }
 
-// newIncomingContext creates a new context for an incoming call with the given span.
-func newIncomingContext(call IncomingCall, timeout time.Duration) (context.Context, context.CancelFunc) {
-	return NewContextBuilder(timeout).
-		setIncomingCall(call).
-		Build()
+// newIncomingContext creates a new context for an incoming call with the given
+// timeout and baggage.
+func newIncomingContext(call IncomingCall, timeout time.Duration, baggage map[string]string) (context.Context, context.CancelFunc) {
+	cb := NewContextBuilder(timeout).setIncomingCall(call)
+	cb.Baggage = baggage
+	return cb.Build()
 }
 
 // CurrentCall returns the current incoming call, or nil if this is not an incoming call context.
@@ -109,13 +110,37 @@ func CurrentCall(ctx context.Context) IncomingCall {
 	return nil
 }
 
-func currentCallOptions(ctx context.Context) *CallOptions {
+// CurrentCallOptions returns the call options set on the context using a
+// ContextBuilder, or nil if there are none. It can be used with any context
+// derived from a TChannel context.
+func CurrentCallOptions(ctx context.Context) *CallOptions {
 	if params := getTChannelParams(ctx); params != nil {
 		return params.options
 	}
 	return nil
 }
 
+// CurrentRetryOptions returns the retry options set on the context using a
+// ContextBuilder, or nil if there are none, in which case RunWithRetry uses
+// the default retry options.
+func CurrentRetryOptions(ctx context.Context) *RetryOptions {
+	if params := getTChannelParams(ctx); params != nil {
+		return params.retryOptions
+	}
+	return nil
+}
+
+// CurrentHeaders returns the application request headers set on the context,
+// or nil if there are none. Unlike ContextWithHeaders, it can be used with any
+// context derived from a TChannel context, such as a context that has been
+// wrapped by another framework.
+func CurrentHeaders(ctx context.Context) map[string]string {
+	if h, ok := ctx.Value(contextKeyHeaders).(*headersContainer); ok {
+		return h.reqHeaders
+	}
+	return nil
+}
+
 func isTracingDisabled(ctx context.Context) bool {
 	if params := getTChannelParams(ctx); params != nil {
 		return params.tracingDisabled
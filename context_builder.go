@@ -21,9 +21,8 @@
 package tchannel
 
 import (
+	"context"
 	"time"
-
-	"golang.org/x/net/context"
 )
 
 // ContextBuilder stores all TChannel-specific parameters that will
@@ -41,12 +40,19 @@ type ContextBuilder struct {
 	// headers will be merged with headers accumulated by the builder.
 	replaceParentHeaders bool
 
-	// If Timeout is zero, Build will default to defaultTimeout.
+	// If Timeout is zero and the ParentContext has a deadline, Build uses the
+	// parent's deadline.
 	Timeout time.Duration
 
 	// Headers are application headers that json/thrift will encode into arg2.
 	Headers map[string]string
 
+	// Baggage are items that are sent in transport headers with the call, and
+	// with any calls made while handling it using the handler's context.
+	// Items are merged with the baggage of the ParentContext, and an item
+	// with an empty value removes the parent's item.
+	Baggage map[string]string
+
 	// CallOptions are TChannel call options for the specific call.
 	CallOptions *CallOptions
 
@@ -60,6 +66,9 @@ type ContextBuilder struct {
 	// The new (child) context inherits a number of properties from the parent context:
 	//   - context fields, accessible via `ctx.Value(key)`
 	//   - headers if parent is a ContextWithHeaders, unless replaced via SetHeaders()
+	//   - the deadline and cancellation of the parent
+	// The parent can be any context, such as the context of a gRPC or HTTP
+	// request, so calls made while handling it are cancelled with it.
 	ParentContext context.Context
 
 	// Hidden fields: we do not want users outside of tchannel to set these.
@@ -97,6 +106,18 @@ func (cb *ContextBuilder) SetHeaders(headers map[string]string) *ContextBuilder
 	return cb
 }
 
+// SetBaggage sets a single baggage item for the Context, which is sent with
+// calls made using the Context, and propagated by servers that make calls
+// using the context of the call. An empty value removes the item.
+func (cb *ContextBuilder) SetBaggage(key, value string) *ContextBuilder {
+	if cb.Baggage == nil {
+		cb.Baggage = map[string]string{key: value}
+	} else {
+		cb.Baggage[key] = value
+	}
+	return cb
+}
+
 // SetShardKey sets the ShardKey call option ("sk" transport header).
 func (cb *ContextBuilder) SetShardKey(sk string) *ContextBuilder {
 	if cb.CallOptions == nil {
@@ -133,6 +154,15 @@ func (cb *ContextBuilder) SetRoutingDelegate(rd string) *ContextBuilder {
 	return cb
 }
 
+// SetPriority sets the Priority call option ("pr" transport header).
+func (cb *ContextBuilder) SetPriority(p CallPriority) *ContextBuilder {
+	if cb.CallOptions == nil {
+		cb.CallOptions = new(CallOptions)
+	}
+	cb.CallOptions.Priority = p
+	return cb
+}
+
 // SetConnectTimeout sets the ConnectionTimeout for this context.
 // The context timeout applies to the whole call, while the connect
 // timeout only applies to creating a new connection.
@@ -175,6 +205,15 @@ func (cb *ContextBuilder) SetTimeoutPerAttempt(timeoutPerAttempt time.Duration)
 	return cb
 }
 
+// SetHedgeDelay sets HedgeDelay in RetryOptions.
+func (cb *ContextBuilder) SetHedgeDelay(hedgeDelay time.Duration) *ContextBuilder {
+	if cb.RetryOptions == nil {
+		cb.RetryOptions = &RetryOptions{}
+	}
+	cb.RetryOptions.HedgeDelay = hedgeDelay
+	return cb
+}
+
 // SetParentContext sets the parent for the Context.
 func (cb *ContextBuilder) SetParentContext(ctx context.Context) *ContextBuilder {
 	cb.ParentContext = ctx
@@ -206,6 +245,13 @@ func (cb *ContextBuilder) getHeaders() map[string]string {
 	return mergedHeaders
 }
 
+func (cb *ContextBuilder) getBaggage() map[string]string {
+	if cb.ParentContext == nil {
+		return mergeBaggage(nil, cb.Baggage)
+	}
+	return mergeBaggage(CurrentBaggage(cb.ParentContext), cb.Baggage)
+}
+
 // Build returns a ContextWithHeaders that can be used to make calls.
 func (cb *ContextBuilder) Build() (ContextWithHeaders, context.CancelFunc) {
 	params := &tchannelCtxParams{
@@ -215,6 +261,7 @@ func (cb *ContextBuilder) Build() (ContextWithHeaders, context.CancelFunc) {
 		connectTimeout:          cb.ConnectTimeout,
 		hideListeningOnOutbound: cb.hideListeningOnOutbound,
 		tracingDisabled:         cb.TracingDisabled,
+		baggage:                 cb.getBaggage(),
 	}
 
 	parent := cb.ParentContext
@@ -224,7 +271,16 @@ func (cb *ContextBuilder) Build() (ContextWithHeaders, context.CancelFunc) {
 		// Unwrap any headerCtx, since we'll be rewrapping anyway.
 		parent = headerCtx.Context
 	}
-	ctx, cancel := context.WithTimeout(parent, cb.Timeout)
+
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if _, hasDeadline := parent.Deadline(); cb.Timeout == 0 && hasDeadline {
+		ctx, cancel = context.WithCancel(parent)
+	} else {
+		ctx, cancel = context.WithTimeout(parent, cb.Timeout)
+	}
 
 	ctx = context.WithValue(ctx, contextKeyTChannel, params)
 	return WrapWithHeaders(ctx, cb.getHeaders()), cancel
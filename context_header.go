@@ -20,7 +20,7 @@
 
 package tchannel
 
-import "golang.org/x/net/context"
+import "context"
 
 // ContextWithHeaders is a Context which contains request and response headers.
 type ContextWithHeaders interface {
@@ -20,6 +20,7 @@
 package tchannel_test
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -30,7 +31,6 @@ import (
 	"github.com/uber/tchannel-go/testutils/goroutines"
 
 	"github.com/stretchr/testify/assert"
-	"golang.org/x/net/context"
 )
 
 var cn = "hello"
@@ -52,6 +52,52 @@ func TestNewContextTimeoutZero(t *testing.T) {
 	assert.True(t, deadline.Sub(time.Now()) <= 0, "Deadline should be Now or earlier")
 }
 
+func TestNewContextTimeoutZeroWithParentDeadline(t *testing.T) {
+	type parentKey struct{}
+	parent, parentCancel := context.WithTimeout(context.Background(), time.Minute)
+	parent = context.WithValue(parent, parentKey{}, "v")
+
+	ctx, cancel := NewContextBuilder(0).SetParentContext(parent).Build()
+	defer cancel()
+
+	parentDeadline, _ := parent.Deadline()
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok, "Context missing deadline")
+	assert.Equal(t, parentDeadline, deadline, "Expected the parent's deadline")
+	assert.Equal(t, "v", ctx.Value(parentKey{}), "Expected parent values")
+
+	parentCancel()
+	select {
+	case <-ctx.Done():
+	case <-time.After(testutils.Timeout(time.Second)):
+		t.Fatal("Context was not cancelled with its parent")
+	}
+}
+
+func TestCurrentAccessors(t *testing.T) {
+	callOptions := &CallOptions{ShardKey: "shard"}
+	retryOptions := &RetryOptions{MaxAttempts: 3}
+	tctx, cancel := NewContextBuilder(time.Second).
+		AddHeader("k", "v").
+		SetShardKey("shard").
+		SetRetryOptions(retryOptions).
+		Build()
+	defer cancel()
+
+	// Accessors work on contexts derived from a TChannel context, even if
+	// they are no longer a ContextWithHeaders.
+	type otherKey struct{}
+	ctx := context.WithValue(tctx, otherKey{}, "other")
+	assert.Equal(t, map[string]string{"k": "v"}, CurrentHeaders(ctx), "Unexpected headers")
+	assert.Equal(t, callOptions, CurrentCallOptions(ctx), "Unexpected call options")
+	assert.Equal(t, retryOptions, CurrentRetryOptions(ctx), "Unexpected retry options")
+
+	background := context.Background()
+	assert.Nil(t, CurrentHeaders(background), "Expected no headers")
+	assert.Nil(t, CurrentCallOptions(background), "Expected no call options")
+	assert.Nil(t, CurrentRetryOptions(background), "Expected no retry options")
+}
+
 func TestRoutingDelegatePropagates(t *testing.T) {
 	WithVerifiedServer(t, nil, func(ch *Channel, hostPort string) {
 		peerInfo := ch.PeerInfo()
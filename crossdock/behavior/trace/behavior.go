@@ -21,6 +21,7 @@
 package trace
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"time"
@@ -33,7 +34,6 @@ import (
 	"github.com/opentracing/opentracing-go/ext"
 	"github.com/uber/jaeger-client-go"
 	"github.com/uber/jaeger-client-go/utils"
-	"golang.org/x/net/context"
 )
 
 // Different parameter keys and values used by the system
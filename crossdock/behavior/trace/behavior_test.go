@@ -21,6 +21,7 @@
 package trace
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"strings"
@@ -36,7 +37,6 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/uber/jaeger-client-go"
-	"golang.org/x/net/context"
 )
 
 func TestTraceBehavior(t *testing.T) {
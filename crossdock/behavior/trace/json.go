@@ -21,11 +21,11 @@
 package trace
 
 import (
+	"context"
+
 	"github.com/uber/tchannel-go"
 	"github.com/uber/tchannel-go/crossdock/log"
 	"github.com/uber/tchannel-go/json"
-
-	"golang.org/x/net/context"
 )
 
 const jsonEndpoint = "trace"
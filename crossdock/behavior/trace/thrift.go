@@ -21,14 +21,13 @@
 package trace
 
 import (
+	"context"
 	"encoding/json"
 
 	"github.com/uber/tchannel-go"
 	"github.com/uber/tchannel-go/crossdock/log"
 	"github.com/uber/tchannel-go/thrift"
 	gen "github.com/uber/tchannel-go/thrift/gen-go/test"
-
-	"golang.org/x/net/context"
 )
 
 func (b *Behavior) registerThrift(ch *tchannel.Channel) {
@@ -0,0 +1,87 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tchannel
+
+import (
+	"context"
+	"time"
+)
+
+// WithMethodMaxTimeout is a SubChannelOption that caps the timeout of inbound
+// calls to the given method. Calls with a longer timeout are handled as if
+// the caller had used the maximum timeout.
+func WithMethodMaxTimeout(method string, timeout time.Duration) SubChannelOption {
+	return func(s *SubChannel) {
+		s.Lock()
+		defer s.Unlock()
+		if s.methodTimeouts == nil {
+			s.methodTimeouts = make(map[string]time.Duration)
+		}
+		s.methodTimeouts[method] = timeout
+	}
+}
+
+// methodMaxTimeout returns the maximum timeout for inbound calls to method,
+// or 0 if there is no maximum.
+func (c *SubChannel) methodMaxTimeout(method string) time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+	return c.methodTimeouts[method]
+}
+
+// handlerContext returns the context for the handler of an inbound call,
+// which expires early if the method has a maximum timeout.
+func (c *Connection) handlerContext(call *InboundCall) context.Context {
+	subCh, ok := c.subChannels.get(call.ServiceName())
+	if !ok {
+		return call.mex.ctx
+	}
+
+	maxTimeout := subCh.methodMaxTimeout(call.MethodString())
+	if maxTimeout <= 0 {
+		return call.mex.ctx
+	}
+
+	ctx, cancel := context.WithTimeout(call.mex.ctx, maxTimeout)
+	callCancel := call.response.cancel
+	call.response.cancel = func() {
+		cancel()
+		callCancel()
+	}
+	return ctx
+}
+
+// expireInbound is called once the handler's context for an inbound call is
+// done, either due to its deadline, or once the response has been sent.
+func (c *Connection) expireInbound(call *InboundCall, ctxErr error) {
+	// The handler's context may expire before the exchange's, so cancel the
+	// exchange to fail any writes from the handler.
+	call.response.cancel()
+	active := call.mex.inboundExpired()
+	if !active || !c.enforceDeadlines || ctxErr != context.DeadlineExceeded {
+		return
+	}
+
+	call.statsReporter.IncCounter("inbound.calls.timeouts", call.commonStatsTags, 1)
+	if call.log.Enabled(LogLevelDebug) {
+		call.log.Debugf("Deadline expired for call to %s::%s", call.ServiceName(), call.MethodString())
+	}
+	c.SendSystemError(call.mex.msgID, *CurrentSpan(call.mex.ctx), ErrTimeout)
+}
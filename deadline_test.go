@@ -0,0 +1,100 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tchannel_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMethodMaxTimeout(t *testing.T) {
+	maxTimeout := testutils.Timeout(100 * time.Millisecond)
+
+	server := testutils.NewServer(t, nil)
+	defer server.Close()
+	sc := server.GetSubChannel("deadlines", WithMethodMaxTimeout("capped", maxTimeout))
+
+	remaining := make(chan time.Duration, 2)
+	handler := func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok, "handler context should have a deadline")
+		remaining <- deadline.Sub(time.Now())
+		return &raw.Res{}, nil
+	}
+	testutils.RegisterFunc(sc, "capped", handler)
+	testutils.RegisterFunc(sc, "uncapped", handler)
+
+	client := testutils.NewClient(t, nil)
+	defer client.Close()
+
+	ctx, cancel := NewContext(testutils.Timeout(time.Second))
+	defer cancel()
+
+	hostPort := server.PeerInfo().HostPort
+	_, _, _, err := raw.Call(ctx, client, hostPort, "deadlines", "capped", nil, nil)
+	require.NoError(t, err, "capped call failed")
+	assert.True(t, <-remaining <= maxTimeout, "capped method should use the maximum timeout")
+
+	_, _, _, err = raw.Call(ctx, client, hostPort, "deadlines", "uncapped", nil, nil)
+	require.NoError(t, err, "uncapped call failed")
+	assert.True(t, <-remaining > maxTimeout, "uncapped method should use the caller's timeout")
+}
+
+func TestEnforceDeadlines(t *testing.T) {
+	maxTimeout := testutils.Timeout(50 * time.Millisecond)
+
+	// The handler's late response fails as the call has expired.
+	opts := testutils.NewOpts().NoRelay().AddLogFilter("simpleHandler OnError", 1)
+	opts.EnforceDeadlines = true
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		sc := ts.Server().GetSubChannel("deadlines", WithMethodMaxTimeout("slow", maxTimeout))
+
+		release := make(chan struct{})
+		handlerErr := make(chan error, 1)
+		testutils.RegisterFunc(sc, "slow", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+			<-ctx.Done()
+			handlerErr <- ctx.Err()
+
+			// Respond after the caller has been sent a timeout, which
+			// should be dropped.
+			<-release
+			return &raw.Res{Arg3: []byte("late")}, nil
+		})
+
+		ctx, cancel := NewContext(testutils.Timeout(5 * time.Second))
+		defer cancel()
+
+		started := time.Now()
+		_, _, _, err := raw.Call(ctx, ts.NewClient(nil), ts.HostPort(), "deadlines", "slow", nil, nil)
+		assert.Equal(t, ErrTimeout, err, "caller should be sent a timeout error")
+		assert.True(t, time.Since(started) < testutils.Timeout(time.Second),
+			"caller should not wait for its own deadline")
+		assert.Equal(t, context.DeadlineExceeded, <-handlerErr, "handler context should expire")
+		close(release)
+	})
+}
@@ -27,7 +27,12 @@ import (
 	"net"
 )
 
-func dialContext(ctx context.Context, hostPort string) (net.Conn, error) {
+func dialContext(ctx context.Context, dialer Dialer, hostPort string) (net.Conn, error) {
+	network, address := networkAddress(hostPort)
+	if dialer != nil {
+		return dialer(ctx, network, address)
+	}
+
 	d := net.Dialer{}
-	return d.DialContext(ctx, "tcp", hostPort)
+	return d.DialContext(ctx, network, address)
 }
@@ -23,6 +23,9 @@
 package tchannel_test
 
 import (
+	"context"
+	"errors"
+	"net"
 	"strings"
 	"testing"
 	"time"
@@ -63,3 +66,37 @@ func TestNetDialCancelContext(t *testing.T) {
 	assert.Equal(t, ErrCodeCancelled, GetSystemErrorCode(err), "Ping expected to fail with context cancelled")
 	assert.True(t, d < 2*timeoutPeriod, "Timeout should take less than %v, took %v", 2*timeoutPeriod, d)
 }
+
+func TestDialer(t *testing.T) {
+	testutils.WithTestServer(t, nil, func(ts *testutils.TestServer) {
+		testutils.RegisterEcho(ts.Server(), nil)
+
+		var dialed []string
+		opts := testutils.NewOpts()
+		opts.Dialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+			dialed = append(dialed, network+" "+address)
+			var d net.Dialer
+			return d.DialContext(ctx, network, address)
+		}
+		client := ts.NewClient(opts)
+
+		testutils.AssertEcho(t, client, ts.HostPort(), ts.ServiceName())
+		assert.Equal(t, []string{"tcp " + ts.HostPort()}, dialed, "Unexpected dials")
+	})
+}
+
+func TestDialerError(t *testing.T) {
+	dialErr := errors.New("proxy unavailable")
+	opts := testutils.NewOpts()
+	opts.Dialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return nil, dialErr
+	}
+	client := testutils.NewClient(t, opts)
+	defer client.Close()
+
+	ctx, cancel := NewContext(testutils.Timeout(time.Second))
+	defer cancel()
+
+	err := client.Ping(ctx, "127.0.0.1:1")
+	assert.Equal(t, dialErr, err, "Ping should fail with the dialer's error")
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+// Drain puts the channel into maintenance mode, where new inbound calls,
+// including calls to relay, are rejected with ErrChannelDraining so that
+// callers retry them on another peer. Calls already in progress complete as
+// usual, and the channel keeps its listener and connections, and can still
+// make outbound calls. Resume returns the channel to handling new calls.
+func (ch *Channel) Drain() {
+	if ch.draining.Swap(true) {
+		return
+	}
+	ch.Logger().Info("Channel draining inbound calls.")
+}
+
+// Resume undoes Drain, so the channel handles new inbound calls again.
+func (ch *Channel) Resume() {
+	if !ch.draining.Swap(false) {
+		return
+	}
+	ch.Logger().Info("Channel resumed inbound calls.")
+}
+
+// Draining returns whether the channel is rejecting new inbound calls due to
+// Drain.
+func (ch *Channel) Draining() bool {
+	return ch.draining.Load()
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"testing"
+
+	. "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrainResume(t *testing.T) {
+	testutils.WithTestServer(t, nil, func(ts *testutils.TestServer) {
+		started := make(chan struct{}, 1)
+		unblock := registerBlockingHandler(ts.Server(), "block", started)
+		testutils.RegisterEcho(ts.Server(), nil)
+
+		client := ts.NewClient(nil)
+		blockedErr := make(chan error, 1)
+		go func() {
+			blockedErr <- callService(client, ts.HostPort(), ts.ServiceName(), "block")
+		}()
+		<-started
+
+		ts.Server().Drain()
+		assert.True(t, ts.Server().Draining(), "Expected server to be draining")
+		err := callService(client, ts.HostPort(), ts.ServiceName(), "echo")
+		assert.Equal(t, ErrChannelDraining, err, "Expected new calls to be declined while draining")
+
+		close(unblock)
+		require.NoError(t, <-blockedErr, "Call in progress should complete while draining")
+
+		ts.Server().Resume()
+		assert.False(t, ts.Server().Draining(), "Expected server to resume")
+		assert.NoError(t, callService(client, ts.HostPort(), ts.ServiceName(), "echo"),
+			"Call should succeed once the server resumes")
+	})
+}
+
+func TestDrainRelay(t *testing.T) {
+	opts := testutils.NewOpts().SetRelayOnly()
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		testutils.RegisterEcho(ts.Server(), nil)
+		client := ts.NewClient(nil)
+
+		ts.Relay().Drain()
+		err := callService(client, ts.HostPort(), ts.ServiceName(), "echo")
+		assert.Equal(t, ErrChannelDraining, err, "Expected relayed calls to be declined while draining")
+
+		ts.Relay().Resume()
+		assert.NoError(t, callService(client, ts.HostPort(), ts.ServiceName(), "echo"),
+			"Relayed call should succeed once the relay resumes")
+	})
+}
@@ -21,9 +21,8 @@
 package tchannel
 
 import (
+	"context"
 	"fmt"
-
-	"golang.org/x/net/context"
 )
 
 const (
@@ -93,8 +92,18 @@ var (
 	// ErrChannelClosed is a SystemError indicating that the channel has been closed.
 	ErrChannelClosed = NewSystemError(ErrCodeDeclined, "closed channel")
 
+	// ErrChannelDraining is a SystemError indicating that the channel is not
+	// handling new calls as it has been drained.
+	ErrChannelDraining = NewSystemError(ErrCodeDeclined, "channel draining")
+
 	// ErrMethodTooLarge is a SystemError indicating that the method is too large.
 	ErrMethodTooLarge = NewSystemError(ErrCodeProtocol, "method too large")
+
+	// ErrArg2TooLarge is a SystemError indicating that arg2 is over its size limit.
+	ErrArg2TooLarge = NewSystemError(ErrCodeBadRequest, "arg2 exceeds the size limit")
+
+	// ErrArg3TooLarge is a SystemError indicating that arg3 is over its size limit.
+	ErrArg3TooLarge = NewSystemError(ErrCodeBadRequest, "arg3 exceeds the size limit")
 )
 
 // MetricsKey is a string representation of the error code that's suitable for
@@ -181,6 +190,18 @@ func (se SystemError) Error() string {
 // Wrapped returns the wrapped error
 func (se SystemError) Wrapped() error { return se.wrapped }
 
+// Unwrap returns the wrapped error, so that errors.Is and errors.As can match
+// the error that caused the SystemError, such as a *net.OpError.
+func (se SystemError) Unwrap() error { return se.wrapped }
+
+// Is reports whether target is a SystemError with the same code and message,
+// so that errors.Is(err, ErrTimeout) matches timeouts sent by peers, and
+// SystemErrors that wrap an error.
+func (se SystemError) Is(target error) bool {
+	t, ok := target.(SystemError)
+	return ok && t.code == se.code && t.msg == se.msg
+}
+
 // Code returns the SystemError code, for sending to a peer
 func (se SystemError) Code() SystemErrCode {
 	return se.code
@@ -203,13 +224,14 @@ func GetContextError(err error) error {
 }
 
 // GetSystemErrorCode returns the code to report for the given error.  If the error is a
-// SystemError, we can get the code directly.  Otherwise treat it as an unexpected error
+// SystemError, or wraps one (e.g. a CallError), we can get the code directly.  Otherwise
+// treat it as an unexpected error
 func GetSystemErrorCode(err error) SystemErrCode {
 	if err == nil {
 		return ErrCodeInvalid
 	}
 
-	if se, ok := err.(SystemError); ok {
+	if se, ok := findSystemError(err); ok {
 		return se.Code()
 	}
 
@@ -217,15 +239,39 @@ func GetSystemErrorCode(err error) SystemErrCode {
 }
 
 // GetSystemErrorMessage returns the message to report for the given error.  If the error is a
-// SystemError, we can get the underlying message. Otherwise, use the Error() method.
+// SystemError, or wraps one, we can get the underlying message. Otherwise, use the Error() method.
 func GetSystemErrorMessage(err error) string {
-	if se, ok := err.(SystemError); ok {
+	if se, ok := findSystemError(err); ok {
 		return se.Message()
 	}
 
 	return err.Error()
 }
 
+// findSystemError returns the SystemError that err is, or wraps. Errors are
+// unwrapped using their Unwrap or Cause method.
+func findSystemError(err error) (SystemError, bool) {
+	for err != nil {
+		if se, ok := err.(SystemError); ok {
+			return se, true
+		}
+
+		switch e := err.(type) {
+		case interface {
+			Unwrap() error
+		}:
+			err = e.Unwrap()
+		case interface {
+			Cause() error
+		}:
+			err = e.Cause()
+		default:
+			return SystemError{}, false
+		}
+	}
+	return SystemError{}, false
+}
+
 type errConnNotActive struct {
 	info  string
 	state connectionState
@@ -0,0 +1,54 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build go1.13
+// +build go1.13
+
+package tchannel
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSystemErrorIsAs(t *testing.T) {
+	remote := NewSystemError(ErrCodeTimeout, "timeout")
+	wrapped := fmt.Errorf("call failed: %w", remote)
+	assert.True(t, errors.Is(wrapped, ErrTimeout), "errors.Is should match a wrapped SystemError")
+	assert.False(t, errors.Is(wrapped, ErrServerBusy), "errors.Is should not match a different SystemError")
+	assert.Equal(t, ErrCodeTimeout, GetSystemErrorCode(wrapped), "Unexpected code for wrapped SystemError")
+
+	opErr := &net.OpError{Op: "dial", Err: io.EOF}
+	netErr := NewWrappedSystemError(ErrCodeNetwork, opErr)
+	var gotOpErr *net.OpError
+	assert.True(t, errors.As(netErr, &gotOpErr), "errors.As should find the cause of a SystemError")
+	assert.Equal(t, opErr, gotOpErr, "Unexpected cause")
+	assert.True(t, errors.Is(netErr, io.EOF), "errors.Is should match the cause of a SystemError")
+
+	callErr := (&callErrorInfo{service: "svc", method: "method"}).wrap(remote, true /* remote */)
+	var gotCallErr *CallError
+	assert.True(t, errors.As(callErr, &gotCallErr), "Expected a CallError")
+	assert.True(t, errors.Is(callErr, ErrTimeout), "errors.Is should match the error in a CallError")
+}
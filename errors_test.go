@@ -22,6 +22,7 @@ package tchannel
 
 import (
 	"io"
+	"net"
 	"regexp"
 	"testing"
 
@@ -67,6 +68,50 @@ func TestSystemError(t *testing.T) {
 	assert.Equal(t, ErrCodeTimeout, code, "tchannel timeout error produces ErrCodeTimeout")
 }
 
+// causeError wraps an error using Cause, like github.com/pkg/errors.
+type causeError struct{ err error }
+
+func (e causeError) Error() string { return "cause: " + e.err.Error() }
+func (e causeError) Cause() error  { return e.err }
+
+// unwrapError wraps an error using Unwrap, like fmt.Errorf with %w.
+type unwrapError struct{ err error }
+
+func (e unwrapError) Error() string { return "unwrap: " + e.err.Error() }
+func (e unwrapError) Unwrap() error { return e.err }
+
+func TestWrappedSystemError(t *testing.T) {
+	remote := NewSystemError(ErrCodeTimeout, "timeout")
+	for _, wrapped := range []error{
+		causeError{remote},
+		unwrapError{remote},
+		causeError{unwrapError{remote}},
+	} {
+		assert.Equal(t, ErrCodeTimeout, GetSystemErrorCode(wrapped), "Unexpected code for %v", wrapped)
+		assert.Equal(t, "timeout", GetSystemErrorMessage(wrapped), "Unexpected message for %v", wrapped)
+	}
+
+	notSystemError := causeError{io.EOF}
+	assert.Equal(t, ErrCodeUnexpected, GetSystemErrorCode(notSystemError), "Unexpected code for wrapped non-SystemError")
+	assert.Equal(t, notSystemError.Error(), GetSystemErrorMessage(notSystemError), "Unexpected message for wrapped non-SystemError")
+
+	opErr := &net.OpError{Op: "dial", Err: io.EOF}
+	netErr := NewWrappedSystemError(ErrCodeNetwork, opErr)
+	assert.Equal(t, opErr, netErr.(SystemError).Unwrap(), "Unexpected cause")
+
+	callErr := (&callErrorInfo{service: "svc", method: "method", hostPort: "1.1.1.1:1"}).wrap(remote, true /* remote */)
+	assert.Equal(t, &CallError{
+		Service:  "svc",
+		Method:   "method",
+		HostPort: "1.1.1.1:1",
+		Remote:   true,
+		Err:      remote,
+	}, callErr, "Unexpected CallError")
+	assert.Equal(t, ErrCodeTimeout, GetSystemErrorCode(callErr), "Unexpected code for CallError")
+	assert.Equal(t, callErr, (&callErrorInfo{}).wrap(callErr, false), "CallErrors should not be wrapped again")
+	assert.Equal(t, remote, (*callErrorInfo)(nil).wrap(remote, true), "nil callErrorInfo should not wrap errors")
+}
+
 func TestRelayMetricsKey(t *testing.T) {
 	for i := 0; i <= 256; i++ {
 		code := SystemErrCode(i)
@@ -21,6 +21,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"net/http"
@@ -32,7 +33,6 @@ import (
 	"github.com/uber/tchannel-go/raw"
 
 	"github.com/uber-go/atomic"
-	"golang.org/x/net/context"
 )
 
 var (
@@ -96,13 +96,15 @@ func worker(ch *tchannel.Channel) {
 }
 
 func setRequest(ch *tchannel.Channel, key, value string) error {
-	ctx, _ := context.WithTimeout(context.Background(), time.Second*10)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
 	_, _, _, err := raw.Call(ctx, ch, *hostPort, "benchmark", "set", []byte(key), []byte(value))
 	return err
 }
 
 func getRequest(ch *tchannel.Channel, key string) (string, error) {
-	ctx, _ := context.WithTimeout(context.Background(), time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
 	_, arg3, _, err := raw.Call(ctx, ch, *hostPort, "benchmark", "get", []byte(key), nil)
 	return string(arg3), err
 }
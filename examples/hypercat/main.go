@@ -21,6 +21,7 @@
 package main
 
 import (
+	"context"
 	"io"
 	"log"
 	"net"
@@ -29,7 +30,6 @@ import (
 
 	"github.com/jessevdk/go-flags"
 	"github.com/uber/tchannel-go"
-	"golang.org/x/net/context"
 )
 
 var options = struct {
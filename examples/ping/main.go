@@ -21,11 +21,10 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"time"
 
-	"golang.org/x/net/context"
-
 	"github.com/uber/tchannel-go"
 	"github.com/uber/tchannel-go/json"
 )
@@ -21,12 +21,11 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 
-	"golang.org/x/net/context"
-
 	"github.com/uber/tchannel-go"
 	"github.com/uber/tchannel-go/raw"
 )
@@ -0,0 +1,173 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tchannel
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/uber/tchannel-go/trand"
+)
+
+// CallFaults are the faults injected into inbound calls to a method.
+type CallFaults struct {
+	// Latency is added before the call is handled.
+	Latency time.Duration
+
+	// ErrCode, if set, is the code of a system error returned instead of
+	// calling the handler.
+	ErrCode SystemErrCode
+
+	// ErrorRate is the fraction of calls, between 0 and 1, that fail with
+	// ErrCode. If zero, every call fails.
+	ErrorRate float64
+}
+
+// FrameFaults are the faults injected into every frame written by a channel.
+type FrameFaults struct {
+	// DropRate is the fraction of frames, between 0 and 1, that are dropped
+	// instead of being written.
+	DropRate float64
+
+	// TruncateRate is the fraction of frames, between 0 and 1, whose payload
+	// is truncated to a random length before being written.
+	TruncateRate float64
+}
+
+type faultKey struct {
+	service string
+	method  string
+}
+
+// FaultInjector injects latency, errors and corrupt frames into a channel to
+// test how services and their callers handle failures. It is set using
+// ChannelOptions.FaultInjector, and faults can be changed at any time while
+// the channel is running. It must not be used in production.
+type FaultInjector struct {
+	sync.RWMutex
+
+	rng    *rand.Rand
+	calls  map[faultKey]CallFaults
+	frames FrameFaults
+}
+
+// NewFaultInjector returns a FaultInjector that does not inject any faults
+// until they are set.
+func NewFaultInjector() *FaultInjector {
+	return &FaultInjector{
+		rng:   trand.NewSeeded(),
+		calls: make(map[faultKey]CallFaults),
+	}
+}
+
+// SetCallFaults sets the faults injected into inbound calls to the given
+// service and method. An empty method applies to every method of the service
+// that does not have its own faults, and an empty service applies to every
+// service.
+func (fi *FaultInjector) SetCallFaults(service, method string, faults CallFaults) {
+	fi.Lock()
+	fi.calls[faultKey{service, method}] = faults
+	fi.Unlock()
+}
+
+// SetFrameFaults sets the faults injected into frames.
+func (fi *FaultInjector) SetFrameFaults(faults FrameFaults) {
+	fi.Lock()
+	fi.frames = faults
+	fi.Unlock()
+}
+
+// Reset removes all faults.
+func (fi *FaultInjector) Reset() {
+	fi.Lock()
+	fi.calls = make(map[faultKey]CallFaults)
+	fi.frames = FrameFaults{}
+	fi.Unlock()
+}
+
+func (fi *FaultInjector) callFaults(service, method string) (CallFaults, bool) {
+	fi.RLock()
+	defer fi.RUnlock()
+
+	for _, k := range []faultKey{{service, method}, {service, ""}, {"", ""}} {
+		if faults, ok := fi.calls[k]; ok {
+			return faults, true
+		}
+	}
+	return CallFaults{}, false
+}
+
+// chance returns true with the given probability.
+func (fi *FaultInjector) chance(rate float64) bool {
+	return rate > 0 && fi.rng.Float64() < rate
+}
+
+// injectCall adds any latency for the call, and returns whether the call was
+// failed rather than being passed to the handler.
+func (fi *FaultInjector) injectCall(ctx context.Context, call *InboundCall) bool {
+	if fi == nil {
+		return false
+	}
+
+	faults, ok := fi.callFaults(call.ServiceName(), call.MethodString())
+	if !ok {
+		return false
+	}
+
+	if faults.Latency > 0 {
+		select {
+		case <-time.After(faults.Latency):
+		case <-ctx.Done():
+			// The call has expired, and the caller is sent an error.
+			return true
+		}
+	}
+
+	if faults.ErrCode == ErrCodeInvalid {
+		return false
+	}
+	if faults.ErrorRate > 0 && !fi.chance(faults.ErrorRate) {
+		return false
+	}
+	call.Response().SendSystemError(NewSystemError(faults.ErrCode, "injected fault"))
+	return true
+}
+
+// injectFrame returns whether the frame should be dropped, and may truncate
+// its payload.
+func (fi *FaultInjector) injectFrame(f *Frame) (drop bool) {
+	if fi == nil {
+		return false
+	}
+
+	fi.RLock()
+	faults := fi.frames
+	fi.RUnlock()
+
+	if fi.chance(faults.DropRate) {
+		return true
+	}
+	if size := f.payloadSize(); size > 0 && fi.chance(faults.TruncateRate) {
+		f.setPayloadSize(fi.rng.Intn(size))
+	}
+	return false
+}
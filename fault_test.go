@@ -0,0 +1,122 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tchannel_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFaultInjectorCallFaults(t *testing.T) {
+	faults := NewFaultInjector()
+	opts := testutils.NewOpts()
+	opts.FaultInjector = faults
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		testutils.RegisterEcho(ts.Server(), nil)
+		testutils.RegisterFunc(ts.Server(), "other", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+			return &raw.Res{}, nil
+		})
+		client := ts.NewClient(nil)
+
+		call := func(method string) (time.Duration, error) {
+			ctx, cancel := NewContextBuilder(testutils.Timeout(time.Second)).
+				SetRetryOptions(&RetryOptions{RetryOn: RetryNever}).
+				Build()
+			defer cancel()
+
+			started := time.Now()
+			_, _, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), method, nil, nil)
+			return time.Since(started), err
+		}
+
+		faults.SetCallFaults(ts.ServiceName(), "echo", CallFaults{ErrCode: ErrCodeBusy})
+		_, err := call("echo")
+		assert.Equal(t, ErrCodeBusy, GetSystemErrorCode(err), "Expected injected error")
+		_, err = call("other")
+		assert.NoError(t, err, "Faults should only apply to echo")
+
+		faults.SetCallFaults(ts.ServiceName(), "", CallFaults{Latency: 50 * time.Millisecond})
+		faults.SetCallFaults(ts.ServiceName(), "echo", CallFaults{})
+		elapsed, err := call("echo")
+		assert.NoError(t, err, "Call without faults failed")
+		assert.True(t, elapsed < 50*time.Millisecond, "Method faults should override service faults")
+		elapsed, _ = call("other")
+		assert.True(t, elapsed >= 50*time.Millisecond, "Expected injected latency, call took %v", elapsed)
+
+		faults.Reset()
+		_, err = call("echo")
+		assert.NoError(t, err, "Call after Reset failed")
+	})
+}
+
+func TestFaultInjectorFrameFaults(t *testing.T) {
+	tests := []struct {
+		msg     string
+		faults  FrameFaults
+		wantErr SystemErrCode
+	}{
+		{
+			msg:     "drop frames",
+			faults:  FrameFaults{DropRate: 1},
+			wantErr: ErrCodeTimeout,
+		},
+		{
+			msg:    "truncate frames",
+			faults: FrameFaults{TruncateRate: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		// Corrupt frames cause errors to be logged by the server.
+		opts := testutils.NewOpts().DisableLogVerification()
+		testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+			testutils.RegisterEcho(ts.Server(), nil)
+
+			faults := NewFaultInjector()
+			clientOpts := testutils.NewOpts()
+			clientOpts.FaultInjector = faults
+			client := ts.NewClient(clientOpts)
+
+			ctx, cancel := NewContextBuilder(testutils.Timeout(100 * time.Millisecond)).
+				SetRetryOptions(&RetryOptions{RetryOn: RetryNever}).
+				Build()
+			defer cancel()
+
+			// Connect before injecting faults, since the handshake is not affected.
+			_, err := client.Connect(ctx, ts.HostPort())
+			require.NoError(t, err, "%v: Connect failed", tt.msg)
+
+			faults.SetFrameFaults(tt.faults)
+			_, _, _, err = raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "echo", []byte("arg2"), []byte("arg3"))
+			require.Error(t, err, "%v: expected call to fail", tt.msg)
+			if tt.wantErr != ErrCodeInvalid {
+				assert.Equal(t, tt.wantErr, GetSystemErrorCode(err), "%v: unexpected error", tt.msg)
+			}
+		})
+	}
+}
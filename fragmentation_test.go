@@ -304,6 +304,51 @@ func TestFragmentationChecksumMismatch(t *testing.T) {
 	assert.Equal(t, errMismatchedChecksums, err)
 }
 
+func TestFragmentationChecksumTypes(t *testing.T) {
+	for _, checksumType := range []ChecksumType{ChecksumTypeNone, ChecksumTypeCrc32, ChecksumTypeFarmhash, ChecksumTypeCrc32C} {
+		ch := make(fragmentChannel, 10)
+		w := newFragmentingWriter(NullLogger, ch, checksumType.New())
+		r := newFragmentingReader(NullLogger, ch)
+
+		// Arguments are written using multiple writes, and span fragments.
+		writer, err := w.ArgWriter(false /* last */)
+		require.NoError(t, err, "%v: arg2 writer failed", checksumType)
+		for _, s := range []string{"hello", " ", "world"} {
+			_, err := writer.Write([]byte(s))
+			require.NoError(t, err, "%v: arg2 write failed", checksumType)
+		}
+		require.NoError(t, writer.Close(), "%v: arg2 close failed", checksumType)
+		require.NoError(t, NewArgWriter(w.ArgWriter(true /* last */)).Write([]byte("this spans fragments")),
+			"%v: arg3 write failed", checksumType)
+
+		var arg2, arg3 []byte
+		require.NoError(t, NewArgReader(r.ArgReader(false /* last */)).Read(&arg2), "%v: arg2 read failed", checksumType)
+		require.NoError(t, NewArgReader(r.ArgReader(true /* last */)).Read(&arg3), "%v: arg3 read failed", checksumType)
+		assert.Equal(t, "hello world", string(arg2), "%v: unexpected arg2", checksumType)
+		assert.Equal(t, "this spans fragments", string(arg3), "%v: unexpected arg3", checksumType)
+	}
+}
+
+func TestFragmentationStrictChecksums(t *testing.T) {
+	ch := make(fragmentChannel, 10)
+	w := newFragmentingWriter(NullLogger, ch, ChecksumTypeCrc32.New())
+	r := newFragmentingReader(NullLogger, ch)
+	r.strictChecksums = true
+	r.requiredChecksum = ChecksumTypeCrc32C
+
+	var rejected []ChecksumType
+	r.onChecksumError = func(err error, checksumType ChecksumType) {
+		rejected = append(rejected, checksumType)
+	}
+
+	require.NoError(t, NewArgWriter(w.ArgWriter(true /* last */)).Write([]byte("hello")))
+
+	var arg []byte
+	err := NewArgReader(r.ArgReader(true /* last */)).Read(&arg)
+	assert.Equal(t, ErrCodeBadRequest, GetSystemErrorCode(err), "expected a BadRequest error, got %v", err)
+	assert.Equal(t, []ChecksumType{ChecksumTypeCrc32}, rejected, "unexpected rejected checksums")
+}
+
 func runFragmentationErrorTest(f func(w *fragmentingWriter, r *fragmentingReader)) {
 	ch := make(fragmentChannel, 10)
 	w := newFragmentingWriter(NullLogger, ch, ChecksumTypeCrc32.New())
@@ -26,11 +26,14 @@ import (
 	"io"
 
 	"github.com/uber/tchannel-go/typed"
+
+	"github.com/uber-go/atomic"
 )
 
 var (
-	errMismatchedChecksumTypes  = errors.New("peer returned different checksum types between fragments")
-	errMismatchedChecksums      = errors.New("different checksums between peer and local")
+	errMismatchedChecksumTypes  = NewSystemError(ErrCodeBadRequest, "peer returned different checksum types between fragments")
+	errMismatchedChecksums      = NewSystemError(ErrCodeBadRequest, "different checksums between peer and local")
+	errUnsupportedChecksumType  = NewSystemError(ErrCodeBadRequest, "peer used an unsupported checksum type")
 	errChunkExceedsFragmentSize = errors.New("peer chunk size exceeds remaining data in fragment")
 	errAlreadyReadingArgument   = errors.New("already reading argument")
 	errNotReadingArgument       = errors.New("not reading argument")
@@ -46,14 +49,35 @@ type readableFragment struct {
 	checksum     []byte
 	contents     *typed.ReadBuffer
 	onDone       func()
+
+	// large is set if the fragment is from a large frame, which uses
+	// larger chunk headers.
+	large bool
+
+	// borrowed is the number of borrowed references to the fragment's contents,
+	// minus the reference held by the reader until done is called. The fragment
+	// is released once all references are released.
+	borrowed atomic.Int32
 }
 
 func (f *readableFragment) done() {
 	if f.isDone {
 		return
 	}
-	f.onDone()
 	f.isDone = true
+	f.release()
+}
+
+// borrow adds a reference to the fragment's contents, which must be released
+// by calling release. It must be called before done.
+func (f *readableFragment) borrow() {
+	f.borrowed.Inc()
+}
+
+func (f *readableFragment) release() {
+	if f.borrowed.Dec() < 0 {
+		f.onDone()
+	}
 }
 
 type fragmentReceiver interface {
@@ -90,6 +114,32 @@ type fragmentingReader struct {
 	curFragment      *readableFragment
 	checksum         Checksum
 	err              error
+
+	// limits are the argument size limits, which are checked as each
+	// fragment is parsed.
+	limits ArgSizeLimits
+
+	// parsedArg is the index of the argument of the last parsed chunk, and
+	// parsedSizes are the sizes of the parsed arguments.
+	parsedArg   int
+	parsedSizes [3]int
+
+	// onLimitExceeded is called with the error if an argument is over its
+	// size limit, if set.
+	onLimitExceeded func(error)
+
+	// errInfo describes the call, if errors sent by the peer are wrapped in
+	// a CallError.
+	errInfo *callErrorInfo
+
+	// requiredChecksum is the checksum type that fragments must use, if
+	// strictChecksums is set.
+	strictChecksums  bool
+	requiredChecksum ChecksumType
+
+	// onChecksumError is called with the error and the fragment's checksum
+	// type if a fragment's checksum is rejected, if set.
+	onChecksumError func(err error, checksumType ChecksumType)
 }
 
 func newFragmentingReader(logger Logger, receiver fragmentReceiver) *fragmentingReader {
@@ -184,6 +234,44 @@ func (r *fragmentingReader) Read(b []byte) (int, error) {
 	}
 }
 
+// borrowArg returns the remaining chunks of the current argument without copying
+// them. The fragments that the chunks reference are not released until the
+// returned ArgBuffer is released. The argument must still be closed.
+func (r *fragmentingReader) borrowArg() (*ArgBuffer, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if !r.state.isReadingArgument() {
+		r.err = errNotReadingArgument
+		return nil, r.err
+	}
+
+	buf := &ArgBuffer{}
+	for {
+		if len(r.curChunk) > 0 {
+			if n := len(buf.fragments); n == 0 || buf.fragments[n-1] != r.curFragment {
+				r.curFragment.borrow()
+				buf.fragments = append(buf.fragments, r.curFragment)
+			}
+			buf.chunks = append(buf.chunks, r.curChunk)
+			buf.size += len(r.curChunk)
+			r.curChunk = r.curChunk[len(r.curChunk):]
+		}
+
+		// The argument ends at the end of the chunk if there are more chunks
+		// in the fragment, or there are no more fragments.
+		if len(r.remainingChunks) > 0 || !r.hasMoreFragments {
+			return buf, nil
+		}
+
+		if r.err = r.recvAndParseNextFragment(false); r.err != nil {
+			buf.Release()
+			return nil, r.err
+		}
+	}
+}
+
 func (r *fragmentingReader) Close() error {
 	last := r.state == fragmentingReadInLastArgument
 	if r.err != nil {
@@ -268,26 +356,42 @@ func (r *fragmentingReader) recvAndParseNextFragment(initial bool) error {
 			// Serialized system errors are still reported (e.g. latency, trace reporting).
 			r.err = err.AsSystemError()
 			r.doneReading(r.err)
+			r.err = r.errInfo.wrap(r.err, true /* remote */)
 		}
 		return r.err
 	}
 
 	// Set checksum, or confirm new checksum is the same type as the prior checksum
+	checksumType := r.curFragment.checksumType
 	if r.checksum == nil {
-		r.checksum = r.curFragment.checksumType.New()
-	} else if r.checksum.TypeCode() != r.curFragment.checksumType {
-		return errMismatchedChecksumTypes
+		if r.strictChecksums && checksumType != r.requiredChecksum {
+			return r.checksumFailed(NewSystemError(ErrCodeBadRequest,
+				"checksum type %v is required, got %v", r.requiredChecksum, checksumType), checksumType)
+		}
+		r.checksum = checksumType.New()
+	} else if r.checksum.TypeCode() != checksumType {
+		return r.checksumFailed(errMismatchedChecksumTypes, checksumType)
 	}
 
 	// Split fragment into underlying chunks
 	r.hasMoreFragments = (r.curFragment.flags & hasMoreFragmentsFlag) == hasMoreFragmentsFlag
 	r.remainingChunks = nil
-	for r.curFragment.contents.BytesRemaining() > 0 && r.curFragment.contents.Err() == nil {
-		chunkSize := r.curFragment.contents.ReadUint16()
-		if chunkSize > uint16(r.curFragment.contents.BytesRemaining()) {
+	for i := 0; r.curFragment.contents.BytesRemaining() > 0 && r.curFragment.contents.Err() == nil; i++ {
+		var chunkSize int
+		if r.curFragment.large {
+			chunkSize = int(r.curFragment.contents.ReadUint32())
+		} else {
+			chunkSize = int(r.curFragment.contents.ReadUint16())
+		}
+		if chunkSize > r.curFragment.contents.BytesRemaining() {
 			return errChunkExceedsFragmentSize
 		}
-		chunkData := r.curFragment.contents.ReadBytes(int(chunkSize))
+		// The first chunk in a fragment continues the previous fragment's
+		// last argument, while every other chunk starts a new argument.
+		if r.err = r.addParsedChunk(i > 0, chunkSize); r.err != nil {
+			return r.err
+		}
+		chunkData := r.curFragment.contents.ReadBytes(chunkSize)
 		r.remainingChunks = append(r.remainingChunks, chunkData)
 		r.checksum.Add(chunkData)
 	}
@@ -299,8 +403,7 @@ func (r *fragmentingReader) recvAndParseNextFragment(initial bool) error {
 	// Validate checksums
 	localChecksum := r.checksum.Sum()
 	if bytes.Compare(r.curFragment.checksum, localChecksum) != 0 {
-		r.err = errMismatchedChecksums
-		return r.err
+		return r.checksumFailed(errMismatchedChecksums, checksumType)
 	}
 
 	// Pull out the first chunk to act as the current chunk
@@ -308,6 +411,46 @@ func (r *fragmentingReader) recvAndParseNextFragment(initial bool) error {
 	return nil
 }
 
+// checksumFailed fails the reader with a checksum error.
+func (r *fragmentingReader) checksumFailed(err error, checksumType ChecksumType) error {
+	r.err = err
+	if r.onChecksumError != nil {
+		r.onChecksumError(err, checksumType)
+	}
+	return r.err
+}
+
+// setLimits sets the argument size limits, returning an error if an argument
+// that has already been received is over its limit.
+func (r *fragmentingReader) setLimits(limits ArgSizeLimits) error {
+	r.limits = limits
+	for arg, size := range r.parsedSizes {
+		if err := r.limits.check(arg, size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addParsedChunk adds the size of a parsed chunk to the size of its argument,
+// returning an error if the argument is over its limit.
+func (r *fragmentingReader) addParsedChunk(newArg bool, size int) error {
+	if newArg {
+		r.parsedArg++
+	}
+	if r.parsedArg >= len(r.parsedSizes) {
+		// There are more chunks than arguments, which fails when reading.
+		return nil
+	}
+
+	r.parsedSizes[r.parsedArg] += size
+	err := r.limits.check(r.parsedArg, r.parsedSizes[r.parsedArg])
+	if err != nil && r.onLimitExceeded != nil {
+		r.onLimitExceeded(err)
+	}
+	return err
+}
+
 func (r *fragmentingReader) doneReading(err error) {
 	if r.checksum != nil {
 		r.checksum.Release()
@@ -35,6 +35,7 @@ var (
 
 const (
 	chunkHeaderSize      = 2    // each chunk is a uint16
+	largeChunkHeaderSize = 4    // each chunk in a large frame is a uint32
 	hasMoreFragmentsFlag = 0x01 // flags indicating there are more fragments coming
 )
 
@@ -47,6 +48,18 @@ type writableFragment struct {
 	checksum    Checksum
 	contents    *typed.WriteBuffer
 	frame       interface{}
+
+	// large is set if the fragment is in a large frame, which uses
+	// larger chunk headers.
+	large bool
+}
+
+// chunkHeaderLen returns the size of the header for each chunk in the fragment.
+func (f *writableFragment) chunkHeaderLen() int {
+	if f.large {
+		return largeChunkHeaderSize
+	}
+	return chunkHeaderSize
 }
 
 // finish finishes the fragment, updating the final checksum and fragment flags
@@ -62,20 +75,32 @@ func (f *writableFragment) finish(hasMoreFragments bool) {
 // A writableChunk is a chunk of data within a fragment, representing the
 // contents of an argument within that fragment
 type writableChunk struct {
-	size     uint16
-	sizeRef  typed.Uint16Ref
-	checksum Checksum
-	contents *typed.WriteBuffer
+	size         uint32
+	sizeRef      typed.Uint16Ref
+	largeSizeRef typed.Uint32Ref
+	checksum     Checksum
+	contents     *typed.WriteBuffer
+
+	// data is the chunk's data in the fragment, which is added to the
+	// checksum when the chunk is finished. Checksums such as Farmhash are
+	// chained for each chunk, so data is added once per chunk, as readers
+	// verify it.
+	data []byte
 }
 
-// newWritableChunk creates a new writable chunk around a checksum and a buffer to hold data
-func newWritableChunk(checksum Checksum, contents *typed.WriteBuffer) *writableChunk {
-	return &writableChunk{
+// newWritableChunk creates a new writable chunk around a checksum and the fragment to hold data
+func newWritableChunk(checksum Checksum, fragment *writableFragment) *writableChunk {
+	c := &writableChunk{
 		size:     0,
-		sizeRef:  contents.DeferUint16(),
 		checksum: checksum,
-		contents: contents,
+		contents: fragment.contents,
 	}
+	if fragment.large {
+		c.largeSizeRef = fragment.contents.DeferUint32()
+	} else {
+		c.sizeRef = fragment.contents.DeferUint16()
+	}
+	return c
 }
 
 // writeAsFits writes as many bytes from the given slice as fits into the chunk
@@ -84,17 +109,28 @@ func (c *writableChunk) writeAsFits(b []byte) int {
 		b = b[:c.contents.BytesRemaining()]
 	}
 
-	c.checksum.Add(b)
-	c.contents.WriteBytes(b)
+	region := c.contents.DeferBytes(len(b))
+	region.Update(b)
+	if c.data == nil {
+		c.data = region
+	} else {
+		// The chunk's data is contiguous in the fragment.
+		c.data = c.data[:len(c.data)+len(region)]
+	}
 
 	written := len(b)
-	c.size += uint16(written)
+	c.size += uint32(written)
 	return written
 }
 
-// finish finishes the chunk, updating its chunk size
+// finish finishes the chunk, updating its chunk size and the checksum
 func (c *writableChunk) finish() {
-	c.sizeRef.Update(c.size)
+	c.checksum.Add(c.data)
+	if c.largeSizeRef != nil {
+		c.largeSizeRef.Update(c.size)
+		return
+	}
+	c.sizeRef.Update(uint16(c.size))
 }
 
 // A fragmentSender allocates and sends outbound fragments to a target
@@ -135,6 +171,16 @@ type fragmentingWriter struct {
 	curChunk    *writableChunk
 	state       fragmentingWriterState
 	err         error
+
+	// limits are the argument size limits, and curArg and curArgSize are
+	// the index and size of the argument being written.
+	limits     ArgSizeLimits
+	curArg     int
+	curArgSize int
+
+	// onLimitExceeded is called with the error if an argument is over its
+	// size limit, if set.
+	onLimitExceeded func(error)
 }
 
 // newFragmentingWriter creates a new fragmenting writer
@@ -183,12 +229,16 @@ func (w *fragmentingWriter) BeginArgument(last bool) error {
 	// If there's no room in the current fragment, freak out.  This will
 	// only happen due to an implementation error in the TChannel stack
 	// itself
-	if w.curFragment.contents.BytesRemaining() <= chunkHeaderSize {
+	if w.curFragment.contents.BytesRemaining() <= w.curFragment.chunkHeaderLen() {
 		panic(fmt.Errorf("attempting to begin an argument in a fragment with only %d bytes available",
 			w.curFragment.contents.BytesRemaining()))
 	}
 
-	w.curChunk = newWritableChunk(w.checksum, w.curFragment.contents)
+	if w.state != fragmentingWriteStart {
+		w.curArg++
+	}
+	w.curArgSize = 0
+	w.curChunk = newWritableChunk(w.checksum, w.curFragment)
 	w.state = fragmentingWriteInArgument
 	if last {
 		w.state = fragmentingWriteInLastArgument
@@ -207,6 +257,15 @@ func (w *fragmentingWriter) Write(b []byte) (int, error) {
 		return 0, w.err
 	}
 
+	// Check the limit before writing, so a write over the limit is not sent.
+	w.curArgSize += len(b)
+	if w.err = w.limits.check(w.curArg, w.curArgSize); w.err != nil {
+		if w.onLimitExceeded != nil {
+			w.onLimitExceeded(w.err)
+		}
+		return 0, w.err
+	}
+
 	totalWritten := 0
 	for {
 		bytesWritten := w.curChunk.writeAsFits(b)
@@ -238,7 +297,7 @@ func (w *fragmentingWriter) Flush() error {
 		return w.err
 	}
 
-	w.curChunk = newWritableChunk(w.checksum, w.curFragment.contents)
+	w.curChunk = newWritableChunk(w.checksum, w.curFragment)
 	return nil
 }
 
@@ -274,7 +333,7 @@ func (w *fragmentingWriter) Close() error {
 	}
 
 	w.state = fragmentingWriteWaitingForArgument
-	if w.curFragment.contents.BytesRemaining() > chunkHeaderSize {
+	if w.curFragment.contents.BytesRemaining() > w.curFragment.chunkHeaderLen() {
 		// There's enough room in this fragment for the next argument's
 		// initial chunk, so we're done here
 		return nil
@@ -291,6 +350,11 @@ func (w *fragmentingWriter) Close() error {
 	}
 
 	// Write an empty chunk to indicate this argument has ended
-	w.curFragment.contents.WriteUint16(0)
+	if w.curFragment.large {
+		w.curFragment.contents.WriteUint32(0)
+	} else {
+		w.curFragment.contents.WriteUint16(0)
+	}
+	w.checksum.Add(nil)
 	return nil
 }
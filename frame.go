@@ -38,6 +38,10 @@ const (
 
 	// MaxFramePayloadSize is the maximum size of the payload for a single frame
 	MaxFramePayloadSize = MaxFrameSize - FrameHeaderSize
+
+	// MaxLargeFrameSize is the total maximum size for a frame on connections
+	// that negotiated large frames. See ChannelOptions.MaxFrameSize.
+	MaxLargeFrameSize = 1<<24 - 1
 )
 
 // FrameHeader is the header for a frame, containing the MessageType and size
@@ -48,7 +52,8 @@ type FrameHeader struct {
 	// The type of message represented by the frame
 	messageType messageType
 
-	// Left empty
+	// Left empty, except on large frames where it holds the high byte of
+	// the size.
 	reserved1 byte
 
 	// The id of the message represented by the frame
@@ -63,12 +68,14 @@ func (fh *FrameHeader) SetPayloadSize(size uint16) {
 	fh.size = size + FrameHeaderSize
 }
 
-// PayloadSize returns the size of the frame payload
+// PayloadSize returns the size of the frame payload. Large frames should use
+// the frame's payloadSize instead.
 func (fh FrameHeader) PayloadSize() uint16 {
 	return fh.size - FrameHeaderSize
 }
 
-// FrameSize returns the total size of the frame
+// FrameSize returns the total size of the frame. Large frames should use
+// the frame's frameSize instead.
 func (fh FrameHeader) FrameSize() uint16 {
 	return fh.size
 }
@@ -113,6 +120,11 @@ type Frame struct {
 
 	// The payload for the frame
 	Payload []byte
+
+	// large is set for frames used on connections that negotiated large
+	// frames. Their size includes the header's reserved1 byte, and their
+	// argument chunks use 4 byte sizes rather than 2 bytes.
+	large bool
 }
 
 // NewFrame allocates a new frame with the given payload capacity
@@ -136,9 +148,15 @@ func (f *Frame) ReadIn(r io.Reader) error {
 	if err := f.Header.read(&rbuf); err != nil {
 		return err
 	}
-	switch payloadSize := f.Header.PayloadSize(); {
-	case payloadSize > MaxFramePayloadSize:
-		return fmt.Errorf("invalid frame size %v", f.Header.size)
+	return f.readPayloadIn(r)
+}
+
+// readPayloadIn reads the payload for the frame's header from the given
+// io.Reader.
+func (f *Frame) readPayloadIn(r io.Reader) error {
+	switch payloadSize := f.payloadSize(); {
+	case payloadSize < 0 || payloadSize > len(f.Payload):
+		return fmt.Errorf("invalid frame size %v", f.frameSize())
 	case payloadSize > 0:
 		if _, err := io.ReadFull(r, f.SizedPayload()); err != nil {
 			return err
@@ -157,7 +175,7 @@ func (f *Frame) WriteOut(w io.Writer) error {
 		return err
 	}
 
-	fullFrame := f.buffer[:f.Header.FrameSize()]
+	fullFrame := f.buffer[:f.frameSize()]
 	if _, err := w.Write(fullFrame); err != nil {
 		return err
 	}
@@ -167,7 +185,7 @@ func (f *Frame) WriteOut(w io.Writer) error {
 
 // SizedPayload returns the slice of the payload actually used, as defined by the header
 func (f *Frame) SizedPayload() []byte {
-	return f.Payload[:f.Header.PayloadSize()]
+	return f.Payload[:f.payloadSize()]
 }
 
 // messageType returns the message type.
@@ -183,12 +201,36 @@ func (f *Frame) write(msg message) error {
 	}
 
 	f.Header.ID = msg.ID()
-	f.Header.reserved1 = 0
 	f.Header.messageType = msg.messageType()
-	f.Header.SetPayloadSize(uint16(wbuf.BytesWritten()))
+	f.setPayloadSize(wbuf.BytesWritten())
 	return nil
 }
 
+// frameSize returns the total size of the frame, including the size
+// extension for large frames.
+func (f *Frame) frameSize() int {
+	if !f.large {
+		return int(f.Header.size)
+	}
+	return int(f.Header.reserved1)<<16 | int(f.Header.size)
+}
+
+// payloadSize returns the size of the frame payload.
+func (f *Frame) payloadSize() int {
+	return f.frameSize() - FrameHeaderSize
+}
+
+// setPayloadSize sets the size of the frame payload, which may only exceed
+// MaxFramePayloadSize for large frames.
+func (f *Frame) setPayloadSize(size int) {
+	frameSize := size + FrameHeaderSize
+	f.Header.size = uint16(frameSize)
+	f.Header.reserved1 = 0
+	if f.large {
+		f.Header.reserved1 = byte(frameSize >> 16)
+	}
+}
+
 func (f *Frame) read(msg message) error {
 	var rbuf typed.ReadBuffer
 	rbuf.Wrap(f.SizedPayload())
@@ -20,7 +20,11 @@
 
 package tchannel
 
-import "sync"
+import (
+	"fmt"
+	"strconv"
+	"sync"
+)
 
 // A FramePool is a pool for managing and re-using frames
 type FramePool interface {
@@ -84,3 +88,108 @@ func (c channelFramePool) Release(f *Frame) {
 		// Too many frames in the channel, discard it.
 	}
 }
+
+var (
+	_largeFramePoolsMut sync.Mutex
+	_largeFramePools    = make(map[int]*sync.Pool)
+)
+
+// largeFramePool is used by connections that negotiated large frames. Large
+// frames are pooled by their payload capacity across all connections, while
+// standard frames are passed through to the connection's frame pool.
+type largeFramePool struct {
+	standard        FramePool
+	pool            *sync.Pool
+	payloadCapacity int
+}
+
+func newLargeFramePool(standard FramePool, frameSize int) *largeFramePool {
+	p := &largeFramePool{
+		standard:        standard,
+		payloadCapacity: frameSize - FrameHeaderSize,
+	}
+	if standard == DisabledFramePool {
+		return p
+	}
+
+	_largeFramePoolsMut.Lock()
+	defer _largeFramePoolsMut.Unlock()
+	if p.pool = _largeFramePools[frameSize]; p.pool == nil {
+		p.pool = &sync.Pool{New: p.newFrame}
+		_largeFramePools[frameSize] = p.pool
+	}
+	return p
+}
+
+func (p *largeFramePool) newFrame() interface{} {
+	f := NewFrame(p.payloadCapacity)
+	f.large = true
+	return f
+}
+
+func (p *largeFramePool) Get() *Frame {
+	if p.pool == nil {
+		return p.newFrame().(*Frame)
+	}
+	return p.pool.Get().(*Frame)
+}
+
+func (p *largeFramePool) Release(f *Frame) {
+	if !f.large {
+		p.standard.Release(f)
+		return
+	}
+	if p.pool != nil && len(f.Payload) == p.payloadCapacity {
+		p.pool.Put(f)
+	}
+}
+
+// maxFrameSize returns the frame size advertised for a channel with the
+// given ChannelOptions.MaxFrameSize, or 0 if it uses standard frames.
+func maxFrameSize(size int) int {
+	switch {
+	case size <= MaxFrameSize:
+		return 0
+	case size > MaxLargeFrameSize:
+		return MaxLargeFrameSize
+	}
+	return size
+}
+
+// negotiateFrameSize returns the frame size to use for a connection given the
+// frame size advertised locally, and the init params sent by the remote peer.
+// Peers that do not advertise a frame size use MaxFrameSize.
+func negotiateFrameSize(local int, p initParams) (int, error) {
+	advertised, ok := p[InitParamMaxFrameSize]
+	if !ok || local == 0 {
+		return MaxFrameSize, nil
+	}
+
+	remote, err := strconv.Atoi(advertised)
+	if err != nil || remote < MaxFrameSize {
+		return 0, fmt.Errorf("invalid %v: %q", InitParamMaxFrameSize, advertised)
+	}
+	if remote < local {
+		return remote, nil
+	}
+	return local, nil
+}
+
+// newMessageFrame returns a frame from the pool containing the given message.
+// If the pool is a SizedFramePool, a small frame is used if the message fits.
+func newMessageFrame(pool FramePool, msg message) (*Frame, error) {
+	if sized, ok := pool.(SizedFramePool); ok {
+		frame := sized.GetSized(0)
+		if err := frame.write(msg); err == nil {
+			return frame, nil
+		}
+		sized.Release(frame)
+	}
+
+	frame := pool.Get()
+	if err := frame.write(msg); err != nil {
+		pool.Release(frame)
+		return nil, err
+	}
+	return frame, nil
+}
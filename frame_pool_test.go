@@ -25,6 +25,7 @@ package tchannel_test
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"math/rand"
 	"sync"
@@ -40,7 +41,6 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"golang.org/x/net/context"
 )
 
 type swapper struct {
@@ -142,6 +142,64 @@ func TestFramesReleased(t *testing.T) {
 	}
 }
 
+func TestBorrowedArgsReleased(t *testing.T) {
+	pool := NewRecordingFramePool()
+	opts := testutils.NewOpts().SetFramePool(pool).NoRelay()
+
+	// arg3 spans multiple frames, while arg2 fits in the first frame.
+	arg2 := testutils.RandBytes(100)
+	arg3 := testutils.RandBytes(3 * MaxFramePayloadSize)
+
+	borrowed := make(chan []*ArgBuffer, 1)
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		ts.Register(HandlerFunc(func(ctx context.Context, call *InboundCall) {
+			buf2, err := NewArgReader(call.Arg2Reader()).ReadBorrowed()
+			require.NoError(t, err, "Failed to borrow arg2")
+			buf3, err := NewArgReader(call.Arg3Reader()).ReadBorrowed()
+			require.NoError(t, err, "Failed to borrow arg3")
+			assert.Len(t, buf2.Chunks(), 1, "arg2 should be a single chunk")
+			assert.True(t, len(buf3.Chunks()) > 1, "arg3 should be made of multiple chunks")
+
+			writeArg := func(getWriter func() (ArgWriter, error), buf *ArgBuffer) {
+				w, err := getWriter()
+				require.NoError(t, err, "Failed to get arg writer")
+				_, err = buf.WriteTo(w)
+				require.NoError(t, err, "Failed to write arg")
+				require.NoError(t, w.Close(), "Failed to close arg writer")
+			}
+			writeArg(call.Response().Arg2Writer, buf2)
+			writeArg(call.Response().Arg3Writer, buf3)
+			borrowed <- []*ArgBuffer{buf2, buf3}
+		}), "echo")
+
+		ctx, cancel := NewContext(testutils.Timeout(time.Second))
+		defer cancel()
+
+		client := ts.NewClient(nil)
+		res2, res3, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "echo", arg2, arg3)
+		require.NoError(t, err, "Call failed")
+		assert.Equal(t, arg2, res2, "Unexpected arg2")
+		assert.Equal(t, arg3, res3, "Unexpected arg3")
+
+		// The borrowed frames are held until they are released, even though
+		// the call has completed.
+		bufs := <-borrowed
+		if unreleased, _ := pool.CheckEmpty(); assert.True(t, unreleased > 0, "Borrowed frames were released") {
+			assert.Equal(t, arg2, bufs[0].Bytes(), "Borrowed arg2 changed after the call")
+			assert.Equal(t, arg3, bufs[1].Bytes(), "Borrowed arg3 changed after the call")
+			assert.Equal(t, len(arg3), bufs[1].Len(), "Unexpected arg3 length")
+		}
+		for _, buf := range bufs {
+			buf.Release()
+			buf.Release()
+		}
+	})
+
+	if unreleasedCount, isEmpty := pool.CheckEmpty(); isEmpty != "" || unreleasedCount > 0 {
+		t.Errorf("Frame pool has %v unreleased frames, errors:\n%v", unreleasedCount, isEmpty)
+	}
+}
+
 type dirtyFramePool struct{}
 
 func (p dirtyFramePool) Get() *Frame {
@@ -99,6 +99,64 @@ func TestPartialRead(t *testing.T) {
 	require.Equal(t, f.SizedPayload(), f2.SizedPayload(), "payload does not match")
 }
 
+func TestLargeFrameReadIn(t *testing.T) {
+	pool := newLargeFramePool(DisabledFramePool, 1024*1024)
+	f := pool.Get()
+	f.Header.messageType = messageTypeCallReq
+	f.Header.ID = 0xDEADBEED
+	f.setPayloadSize(300000)
+	for i := range f.SizedPayload() {
+		f.Payload[i] = byte(i * 37)
+	}
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, f.WriteOut(buf))
+	assert.Equal(t, 300000+FrameHeaderSize, buf.Len(), "frame size should match written bytes")
+
+	large := pool.Get()
+	require.NoError(t, large.ReadIn(bytes.NewReader(buf.Bytes())))
+	assert.Equal(t, f.Header, large.Header, "frame headers don't match")
+	assert.Equal(t, f.SizedPayload(), large.SizedPayload(), "payload does not match")
+
+	// Standard frames ignore the size extension.
+	standard := NewFrame(MaxFramePayloadSize)
+	err := standard.ReadIn(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err, "ReadIn failed")
+	assert.Equal(t, (300000+FrameHeaderSize)&math.MaxUint16, standard.frameSize(), "Unexpected frame size")
+}
+
+func TestNegotiateFrameSize(t *testing.T) {
+	tests := []struct {
+		msg     string
+		local   int
+		remote  string
+		want    int
+		wantErr bool
+	}{
+		{msg: "neither advertise", want: MaxFrameSize},
+		{msg: "only remote advertises", remote: "1048576", want: MaxFrameSize},
+		{msg: "only local advertises", local: 1 << 20, want: MaxFrameSize},
+		{msg: "remote is smaller", local: 1 << 20, remote: "131072", want: 1 << 17},
+		{msg: "local is smaller", local: 1 << 17, remote: "1048576", want: 1 << 17},
+		{msg: "remote is invalid", local: 1 << 20, remote: "foo", wantErr: true},
+		{msg: "remote is too small", local: 1 << 20, remote: "1024", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		p := initParams{}
+		if tt.remote != "" {
+			p[InitParamMaxFrameSize] = tt.remote
+		}
+		got, err := negotiateFrameSize(tt.local, p)
+		if tt.wantErr {
+			assert.Error(t, err, "%v: expected error", tt.msg)
+			continue
+		}
+		require.NoError(t, err, "%v: unexpected error", tt.msg)
+		assert.Equal(t, tt.want, got, "%v: unexpected frame size", tt.msg)
+	}
+}
+
 func TestEmptyPayload(t *testing.T) {
 	f := NewFrame(MaxFramePayloadSize)
 	m := &pingRes{id: 1}
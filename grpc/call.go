@@ -0,0 +1,95 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package grpc
+
+import (
+	"context"
+
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/typed"
+)
+
+// errApplication is returned when the handler returns an application error.
+type errApplication string
+
+func (e errApplication) Error() string {
+	return string(e)
+}
+
+// makeCall makes a protobuf call to the channel's own handlers, passing the
+// encoded message through without decoding it. Unlike pb.Client, errors
+// are returned as-is so system error codes can be mapped to gRPC codes.
+func makeCall(ctx context.Context, ch *tchannel.Channel, serviceName, method string, headers map[string]string, arg []byte) (map[string]string, []byte, error) {
+	call, err := ch.BeginCall(ctx, ch.PeerInfo().HostPort, serviceName, method, &tchannel.CallOptions{Format: tchannel.Proto})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	headers = tchannel.InjectOutboundSpan(call.Response(), headers)
+	resArg2, resArg3, res, err := raw.WriteArgs(call, encodeHeaders(headers), arg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resHeaders, err := decodeHeaders(resArg2)
+	if err != nil {
+		return nil, nil, err
+	}
+	if res.ApplicationError() {
+		return resHeaders, nil, errApplication(resArg3)
+	}
+	return resHeaders, resArg3, nil
+}
+
+// encodeHeaders encodes headers using the same format as the pb package.
+func encodeHeaders(headers map[string]string) []byte {
+	size := 2
+	for k, v := range headers {
+		size += 4 + len(k) + len(v)
+	}
+
+	buf := make([]byte, size)
+	wbuf := typed.NewWriteBuffer(buf)
+	wbuf.WriteUint16(uint16(len(headers)))
+	for k, v := range headers {
+		wbuf.WriteLen16String(k)
+		wbuf.WriteLen16String(v)
+	}
+	return buf[:wbuf.BytesWritten()]
+}
+
+// decodeHeaders decodes headers encoded using encodeHeaders.
+func decodeHeaders(buf []byte) (map[string]string, error) {
+	if len(buf) == 0 {
+		return nil, nil
+	}
+
+	rbuf := typed.NewReadBuffer(buf)
+	numHeaders := rbuf.ReadUint16()
+	headers := make(map[string]string, numHeaders)
+	for i := 0; i < int(numHeaders) && rbuf.Err() == nil; i++ {
+		k := rbuf.ReadLen16String()
+		v := rbuf.ReadLen16String()
+		headers[k] = v
+	}
+	return headers, rbuf.Err()
+}
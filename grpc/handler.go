@@ -0,0 +1,262 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package grpc serves gRPC unary calls on the same port as a TChannel
+// Channel, which allows callers to be migrated between TChannel and gRPC
+// incrementally.
+//
+// gRPC calls are received over HTTP/2 cleartext (with prior knowledge) using
+// the channel's HTTP2Handler option, and are made as protobuf calls to the
+// handlers registered on the channel using the pb package. The gRPC method
+// /<package.Service>/<Method> is mapped to the TChannel method
+// <package.Service>::<Method>, and request and response metadata are mapped
+// to application headers.
+//
+// Only unary calls without compression are supported.
+package grpc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uber/tchannel-go"
+)
+
+const defaultTimeout = 30 * time.Second
+
+// HandlerOptions are options used when creating a Handler.
+type HandlerOptions struct {
+	// ServiceName is the TChannel service that gRPC calls are made to.
+	// If this is empty, the channel's service name is used.
+	ServiceName string
+
+	// DefaultTimeout is the timeout for calls that do not specify a
+	// grpc-timeout. If this is 0, the default of 30 seconds is used.
+	DefaultTimeout time.Duration
+}
+
+// Handler is an http.Handler that serves gRPC calls using the handlers
+// registered on a Channel. It should be set as the channel's HTTP2Handler.
+type Handler struct {
+	sync.RWMutex
+
+	opts HandlerOptions
+	ch   *tchannel.Channel
+}
+
+// NewHandler returns a Handler that can be used as a channel's HTTP2Handler.
+func NewHandler(opts *HandlerOptions) *Handler {
+	h := &Handler{}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.DefaultTimeout <= 0 {
+		h.opts.DefaultTimeout = defaultTimeout
+	}
+	return h
+}
+
+// SetChannel is called by the channel on creation to set the channel that
+// serves calls.
+func (h *Handler) SetChannel(ch *tchannel.Channel) {
+	h.Lock()
+	h.ch = ch
+	h.Unlock()
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.RLock()
+	ch := h.ch
+	h.RUnlock()
+	if ch == nil {
+		writeStatus(w, codeUnavailable, "gRPC handler is not attached to a channel")
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "gRPC requests must use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "application/grpc" && !strings.HasPrefix(ct, "application/grpc+proto") {
+		http.Error(w, fmt.Sprintf("unsupported content type %q", ct), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	method, ok := toTChannelMethod(r.URL.Path)
+	if !ok {
+		writeStatus(w, codeUnimplemented, fmt.Sprintf("malformed method %q", r.URL.Path))
+		return
+	}
+
+	timeout := h.opts.DefaultTimeout
+	if t := r.Header.Get("Grpc-Timeout"); t != "" {
+		var err error
+		if timeout, err = parseTimeout(t); err != nil {
+			writeStatus(w, codeInvalidArgument, err.Error())
+			return
+		}
+	}
+
+	arg, code, err := readMessage(r.Body)
+	if err != nil {
+		writeStatus(w, code, err.Error())
+		return
+	}
+
+	serviceName := h.opts.ServiceName
+	if serviceName == "" {
+		serviceName = ch.ServiceName()
+	}
+
+	ctx, cancel := tchannel.NewContextBuilder(timeout).SetParentContext(r.Context()).Build()
+	defer cancel()
+
+	resHeaders, res, err := makeCall(ctx, ch, serviceName, method, getMetadata(r.Header), arg)
+	if err != nil {
+		code, msg := toStatus(err)
+		writeStatus(w, code, msg)
+		return
+	}
+
+	for k, v := range resHeaders {
+		w.Header().Set(k, v)
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+	w.Write(frameMessage(res))
+	w.Header().Set("Grpc-Status", strconv.Itoa(int(codeOK)))
+}
+
+// toTChannelMethod maps a gRPC path, /package.Service/Method, to the TChannel
+// method package.Service::Method.
+func toTChannelMethod(path string) (string, bool) {
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", false
+	}
+	return parts[0] + "::" + parts[1], true
+}
+
+// parseTimeout parses a grpc-timeout value, which is a positive integer
+// followed by a unit.
+func parseTimeout(s string) (time.Duration, error) {
+	units := map[byte]time.Duration{
+		'H': time.Hour,
+		'M': time.Minute,
+		'S': time.Second,
+		'm': time.Millisecond,
+		'u': time.Microsecond,
+		'n': time.Nanosecond,
+	}
+	if len(s) < 2 {
+		return 0, fmt.Errorf("invalid grpc-timeout %q", s)
+	}
+	unit, ok := units[s[len(s)-1]]
+	if !ok {
+		return 0, fmt.Errorf("invalid grpc-timeout unit in %q", s)
+	}
+	v, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("invalid grpc-timeout %q", s)
+	}
+	return time.Duration(v) * unit, nil
+}
+
+// readMessage reads the single length-prefixed message in a unary request.
+func readMessage(body io.Reader) ([]byte, code, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(body, prefix[:]); err != nil {
+		return nil, codeInvalidArgument, fmt.Errorf("failed to read message prefix: %v", err)
+	}
+	if prefix[0] != 0 {
+		return nil, codeUnimplemented, fmt.Errorf("compressed messages are not supported")
+	}
+
+	msg := make([]byte, binary.BigEndian.Uint32(prefix[1:]))
+	if _, err := io.ReadFull(body, msg); err != nil {
+		return nil, codeInvalidArgument, fmt.Errorf("failed to read message: %v", err)
+	}
+	if rest, _ := ioutil.ReadAll(body); len(rest) > 0 {
+		return nil, codeUnimplemented, fmt.Errorf("only unary calls are supported")
+	}
+	return msg, codeOK, nil
+}
+
+func frameMessage(msg []byte) []byte {
+	framed := make([]byte, 5+len(msg))
+	binary.BigEndian.PutUint32(framed[1:], uint32(len(msg)))
+	copy(framed[5:], msg)
+	return framed
+}
+
+// getMetadata returns the request metadata that should be passed as
+// application headers. Reserved and binary metadata is not passed.
+func getMetadata(h http.Header) map[string]string {
+	headers := make(map[string]string)
+	for k, vs := range h {
+		k = strings.ToLower(k)
+		if len(vs) == 0 || isReservedHeader(k) || strings.HasSuffix(k, "-bin") {
+			continue
+		}
+		headers[k] = vs[0]
+	}
+	return headers
+}
+
+func isReservedHeader(k string) bool {
+	switch k {
+	case "content-type", "te", "user-agent", "trailer":
+		return true
+	}
+	return strings.HasPrefix(k, "grpc-")
+}
+
+// writeStatus writes a gRPC response with no messages and the given status.
+func writeStatus(w http.ResponseWriter, c code, msg string) {
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Grpc-Status", strconv.Itoa(int(c)))
+	if msg != "" {
+		w.Header().Set("Grpc-Message", encodeGRPCMessage(msg))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// encodeGRPCMessage percent-encodes a status message as required by gRPC.
+func encodeGRPCMessage(msg string) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			buf.WriteByte(c)
+		} else {
+			fmt.Fprintf(&buf, "%%%02X", c)
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,242 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/pb"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+)
+
+func echo(ctx pb.Context, arg *wrappers.StringValue) (*wrappers.StringValue, error) {
+	ctx.SetResponseHeaders(map[string]string{"hdr": ctx.Headers()["hdr"] + "-resp"})
+	return &wrappers.StringValue{Value: arg.Value}, nil
+}
+
+func fail(ctx pb.Context, arg *wrappers.StringValue) (*wrappers.StringValue, error) {
+	if arg.Value == "busy" {
+		return nil, tchannel.ErrServerBusy
+	}
+	return nil, errors.New("failed: " + arg.Value)
+}
+
+func setupServer(t *testing.T) *tchannel.Channel {
+	opts := testutils.NewOpts().SetServiceName("svc")
+	opts.HTTP2Handler = NewHandler(nil)
+	server := testutils.NewServer(t, opts)
+	require.NoError(t, pb.Register(server, pb.Handlers{
+		"test.Echo::Echo": echo,
+		"test.Echo::Fail": fail,
+	}, func(ctx context.Context, err error) {
+		t.Errorf("onError: %v", err)
+	}), "Register failed")
+	return server
+}
+
+// newH2CClient returns an HTTP client that uses HTTP/2 cleartext with prior
+// knowledge, as gRPC clients do for insecure connections.
+func newH2CClient() *http.Client {
+	return &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+}
+
+type grpcResponse struct {
+	status  string
+	message string
+	headers http.Header
+	body    []byte
+}
+
+func grpcCall(t *testing.T, hostPort, path string, body []byte, headers map[string]string) grpcResponse {
+	req, err := http.NewRequest("POST", "http://"+hostPort+path, bytes.NewReader(body))
+	require.NoError(t, err, "NewRequest failed")
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("Te", "trailers")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := newH2CClient().Do(req)
+	require.NoError(t, err, "gRPC request failed")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode, "Unexpected HTTP status")
+
+	resBody, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err, "Failed to read response body")
+
+	res := grpcResponse{headers: resp.Header, body: resBody}
+	// Errors are returned in trailers-only responses, which are sent as headers.
+	res.status, res.message = resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+	if res.status == "" {
+		res.status, res.message = resp.Trailer.Get("Grpc-Status"), resp.Trailer.Get("Grpc-Message")
+	}
+	return res
+}
+
+func frameString(t *testing.T, s string) []byte {
+	msg, err := proto.Marshal(&wrappers.StringValue{Value: s})
+	require.NoError(t, err, "Failed to marshal message")
+	return frameMessage(msg)
+}
+
+func TestGRPCUnaryCall(t *testing.T) {
+	server := setupServer(t)
+	defer server.Close()
+	hostPort := server.PeerInfo().HostPort
+
+	res := grpcCall(t, hostPort, "/test.Echo/Echo", frameString(t, "hello"), map[string]string{"hdr": "val"})
+	assert.Equal(t, "0", res.status, "Unexpected status, message: %v", res.message)
+	assert.Equal(t, "val-resp", res.headers.Get("hdr"), "Unexpected response metadata")
+	require.True(t, len(res.body) > 5, "Response is missing the message")
+	var got wrappers.StringValue
+	require.NoError(t, proto.Unmarshal(res.body[5:], &got), "Failed to unmarshal response")
+	assert.Equal(t, "hello", got.Value, "Unexpected response")
+
+	// TChannel calls are still served on the same port.
+	client := testutils.NewClient(t, nil)
+	defer client.Close()
+	ctx, cancel := pb.NewContext(time.Second)
+	defer cancel()
+	var pbRes wrappers.StringValue
+	err := pb.NewClient(client, "svc", &pb.ClientOptions{HostPort: hostPort}).
+		Call(ctx, "test.Echo::Echo", &wrappers.StringValue{Value: "tchannel"}, &pbRes)
+	require.NoError(t, err, "TChannel call failed")
+	assert.Equal(t, "tchannel", pbRes.Value, "Unexpected TChannel response")
+}
+
+func TestGRPCErrors(t *testing.T) {
+	server := setupServer(t)
+	defer server.Close()
+	hostPort := server.PeerInfo().HostPort
+
+	compressed := frameString(t, "x")
+	compressed[0] = 1
+
+	tests := []struct {
+		msg         string
+		path        string
+		body        []byte
+		headers     map[string]string
+		wantStatus  string
+		wantMessage string
+	}{
+		{
+			msg:         "application error",
+			path:        "/test.Echo/Fail",
+			body:        frameString(t, "oops"),
+			wantStatus:  "2",
+			wantMessage: "failed: oops",
+		},
+		{
+			msg:        "system error",
+			path:       "/test.Echo/Fail",
+			body:       frameString(t, "busy"),
+			wantStatus: "8",
+		},
+		{
+			msg:        "malformed method",
+			path:       "/test.Echo",
+			body:       frameString(t, "x"),
+			wantStatus: "12",
+		},
+		{
+			msg:        "compressed message",
+			path:       "/test.Echo/Echo",
+			body:       compressed,
+			wantStatus: "12",
+		},
+		{
+			msg:        "invalid timeout",
+			path:       "/test.Echo/Echo",
+			body:       frameString(t, "x"),
+			headers:    map[string]string{"Grpc-Timeout": "10x"},
+			wantStatus: "3",
+		},
+		{
+			msg:        "truncated message",
+			path:       "/test.Echo/Echo",
+			body:       frameString(t, "hello")[:6],
+			wantStatus: "3",
+		},
+	}
+
+	for _, tt := range tests {
+		res := grpcCall(t, hostPort, tt.path, tt.body, tt.headers)
+		assert.Equal(t, tt.wantStatus, res.status, "%v: unexpected status, message: %v", tt.msg, res.message)
+		if tt.wantMessage != "" {
+			assert.Equal(t, tt.wantMessage, res.message, "%v: unexpected message", tt.msg)
+		}
+	}
+}
+
+func TestParseTimeout(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    time.Duration
+		wantErr bool
+	}{
+		{s: "1H", want: time.Hour},
+		{s: "2M", want: 2 * time.Minute},
+		{s: "3S", want: 3 * time.Second},
+		{s: "100m", want: 100 * time.Millisecond},
+		{s: "5u", want: 5 * time.Microsecond},
+		{s: "7n", want: 7 * time.Nanosecond},
+		{s: "m", wantErr: true},
+		{s: "10", wantErr: true},
+		{s: "-1S", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseTimeout(tt.s)
+		if tt.wantErr {
+			assert.Error(t, err, "parseTimeout(%q) should fail", tt.s)
+			continue
+		}
+		if assert.NoError(t, err, "parseTimeout(%q) failed", tt.s) {
+			assert.Equal(t, tt.want, got, "parseTimeout(%q) mismatch", tt.s)
+		}
+	}
+}
+
+func TestEncodeGRPCMessage(t *testing.T) {
+	assert.Equal(t, "plain text", encodeGRPCMessage("plain text"))
+	assert.Equal(t, "100%25%0Anext", encodeGRPCMessage("100%\nnext"))
+}
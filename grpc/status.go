@@ -0,0 +1,66 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package grpc
+
+import (
+	"github.com/uber/tchannel-go"
+)
+
+// code is a gRPC status code.
+type code int
+
+const (
+	codeOK                code = 0
+	codeCancelled         code = 1
+	codeUnknown           code = 2
+	codeInvalidArgument   code = 3
+	codeDeadlineExceeded  code = 4
+	codeResourceExhausted code = 8
+	codeUnimplemented     code = 12
+	codeInternal          code = 13
+	codeUnavailable       code = 14
+)
+
+// toStatus returns the gRPC status for an error returned by a TChannel call.
+// Application errors are returned with the Unknown code and the error
+// message returned by the handler.
+func toStatus(err error) (code, string) {
+	if appErr, ok := err.(errApplication); ok {
+		return codeUnknown, string(appErr)
+	}
+
+	msg := tchannel.GetSystemErrorMessage(err)
+	switch tchannel.GetSystemErrorCode(err) {
+	case tchannel.ErrCodeTimeout:
+		return codeDeadlineExceeded, msg
+	case tchannel.ErrCodeCancelled:
+		return codeCancelled, msg
+	case tchannel.ErrCodeBusy:
+		return codeResourceExhausted, msg
+	case tchannel.ErrCodeDeclined, tchannel.ErrCodeNetwork:
+		return codeUnavailable, msg
+	case tchannel.ErrCodeBadRequest:
+		return codeInvalidArgument, msg
+	case tchannel.ErrCodeProtocol:
+		return codeInternal, msg
+	}
+	return codeUnknown, msg
+}
@@ -21,11 +21,12 @@
 package tchannel
 
 import (
+	"bytes"
+	"context"
 	"reflect"
 	"runtime"
+	"sort"
 	"sync"
-
-	"golang.org/x/net/context"
 )
 
 // A Handler is an object that can be registered with a Channel to process
@@ -75,8 +76,25 @@ type handlerMap struct {
 	sync.RWMutex
 
 	handlers map[string]Handler
+
+	// prefixes are the handlers registered for method prefixes, ordered
+	// from the longest prefix to the shortest.
+	prefixes []prefixHandler
 }
 
+// prefixHandler is a handler for all methods starting with prefix.
+type prefixHandler struct {
+	prefix  string
+	handler Handler
+}
+
+// byPrefixLength sorts prefix handlers so the longest prefix is first.
+type byPrefixLength []prefixHandler
+
+func (p byPrefixLength) Len() int           { return len(p) }
+func (p byPrefixLength) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p byPrefixLength) Less(i, j int) bool { return len(p[i].prefix) > len(p[j].prefix) }
+
 // Registers a handler
 func (hmap *handlerMap) register(h Handler, method string) {
 	hmap.Lock()
@@ -89,14 +107,84 @@ func (hmap *handlerMap) register(h Handler, method string) {
 	hmap.handlers[method] = h
 }
 
+// registerPrefix registers a handler for all methods starting with prefix,
+// replacing any handler previously registered for the same prefix.
+func (hmap *handlerMap) registerPrefix(h Handler, prefix string) {
+	hmap.Lock()
+	defer hmap.Unlock()
+
+	prefixes := make([]prefixHandler, 0, len(hmap.prefixes)+1)
+	for _, ph := range hmap.prefixes {
+		if ph.prefix != prefix {
+			prefixes = append(prefixes, ph)
+		}
+	}
+	prefixes = append(prefixes, prefixHandler{prefix, h})
+	sort.Stable(byPrefixLength(prefixes))
+	hmap.prefixes = prefixes
+}
+
+// unregister removes the handler for the given method, and returns whether
+// there was a handler registered.
+func (hmap *handlerMap) unregister(method string) bool {
+	hmap.Lock()
+	defer hmap.Unlock()
+
+	_, ok := hmap.handlers[method]
+	delete(hmap.handlers, method)
+	return ok
+}
+
+// unregisterPrefix removes the handler for the given prefix, and returns
+// whether there was a handler registered.
+func (hmap *handlerMap) unregisterPrefix(prefix string) bool {
+	hmap.Lock()
+	defer hmap.Unlock()
+
+	for i, ph := range hmap.prefixes {
+		if ph.prefix == prefix {
+			prefixes := make([]prefixHandler, 0, len(hmap.prefixes)-1)
+			prefixes = append(prefixes, hmap.prefixes[:i]...)
+			hmap.prefixes = append(prefixes, hmap.prefixes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
 // Finds the handler matching the given service and method.  See https://github.com/golang/go/issues/3512
 // for the reason that method is []byte instead of a string
 func (hmap *handlerMap) find(method []byte) Handler {
 	hmap.RLock()
-	handler := hmap.handlers[string(method)]
-	hmap.RUnlock()
+	defer hmap.RUnlock()
 
-	return handler
+	if handler, ok := hmap.handlers[string(method)]; ok {
+		return handler
+	}
+	for _, ph := range hmap.prefixes {
+		if bytes.HasPrefix(method, []byte(ph.prefix)) {
+			return ph.handler
+		}
+	}
+	return nil
+}
+
+// methods returns the sorted methods and prefixes with registered handlers.
+func (hmap *handlerMap) methods() (methods []string, prefixes []string) {
+	hmap.RLock()
+	defer hmap.RUnlock()
+
+	methods = make([]string, 0, len(hmap.handlers))
+	for k := range hmap.handlers {
+		methods = append(methods, k)
+	}
+	sort.Strings(methods)
+
+	for _, ph := range hmap.prefixes {
+		prefixes = append(prefixes, ph.prefix)
+	}
+	sort.Strings(prefixes)
+	return methods, prefixes
 }
 
 func (hmap *handlerMap) Handle(ctx context.Context, call *InboundCall) {
@@ -123,5 +211,5 @@ func (hmap *handlerMap) Handle(ctx context.Context, call *InboundCall) {
 type channelHandler struct{ ch *Channel }
 
 func (c channelHandler) Handle(ctx context.Context, call *InboundCall) {
-	c.ch.GetSubChannel(call.ServiceName()).handler.Handle(ctx, call)
+	c.ch.GetSubChannel(call.ServiceName()).limitHandler(ctx, call)
 }
@@ -21,10 +21,10 @@
 package tchannel
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
-	"golang.org/x/net/context"
 )
 
 type dummyHandler struct{}
@@ -0,0 +1,136 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/uber-go/atomic"
+)
+
+// healthMethod is the JSON endpoint used for application health checks, which
+// extends the health endpoint of the thrift Meta service to raw and JSON
+// channels. Like the other internal endpoints, it's registered on both the
+// channel's service and the "tchannel" service.
+const healthMethod = "_gometa_health"
+
+// HealthFunc is the interface for custom health endpoints.
+// ok is whether the service health is OK, and message is optional additional
+// information for the health result.
+type HealthFunc func(ctx context.Context) (ok bool, message string)
+
+// HealthStatus is the result of a health check.
+type HealthStatus struct {
+	Ok      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+// healthHandler implements the health endpoint using the registered HealthFunc.
+type healthHandler struct {
+	sync.RWMutex
+	healthFn HealthFunc
+
+	// warmingUp is the number of warm-ups in progress.
+	warmingUp atomic.Int32
+}
+
+func defaultHealth(ctx context.Context) (bool, string) {
+	return true, ""
+}
+
+func (h *healthHandler) setHandler(f HealthFunc) {
+	h.Lock()
+	h.healthFn = f
+	h.Unlock()
+}
+
+func (h *healthHandler) health(ctx context.Context) HealthStatus {
+	if h.warmingUp.Load() > 0 {
+		return HealthStatus{Ok: false, Message: "warming up"}
+	}
+
+	h.RLock()
+	f := h.healthFn
+	h.RUnlock()
+
+	if f == nil {
+		f = defaultHealth
+	}
+	ok, message := f(ctx)
+	return HealthStatus{Ok: ok, Message: message}
+}
+
+func (h *healthHandler) Handle(ctx context.Context, call *InboundCall) {
+	var arg2, arg3 []byte
+	if err := NewArgReader(call.Arg2Reader()).Read(&arg2); err != nil {
+		return
+	}
+	if err := NewArgReader(call.Arg3Reader()).Read(&arg3); err != nil {
+		return
+	}
+	if err := NewArgWriter(call.Response().Arg2Writer()).Write(nil); err != nil {
+		return
+	}
+	NewArgWriter(call.Response().Arg3Writer()).WriteJSON(h.health(ctx))
+}
+
+// SetHealthHandler sets the function used to report the application's health
+// to health checks made using CheckHealth. By default, the channel is always
+// reported as healthy. The thrift Server's RegisterHealthHandler also sets
+// this function, so both endpoints report the same health.
+func (ch *Channel) SetHealthHandler(f HealthFunc) {
+	ch.health.setHandler(f)
+}
+
+// CheckHealth calls the health endpoint of the channel at the given host:port,
+// and returns the reported health. An error is returned if the health check
+// could not be made, in which case the peer should be treated as unhealthy.
+func (ch *Channel) CheckHealth(ctx context.Context, hostPort string) (HealthStatus, error) {
+	var status HealthStatus
+	call, err := ch.BeginCall(ctx, hostPort, "tchannel", healthMethod, &CallOptions{Format: JSON})
+	if err != nil {
+		return status, err
+	}
+
+	if err := NewArgWriter(call.Arg2Writer()).Write(nil); err != nil {
+		return status, err
+	}
+	if err := NewArgWriter(call.Arg3Writer()).Write(nil); err != nil {
+		return status, err
+	}
+
+	var resArg2, resArg3 []byte
+	if err := NewArgReader(call.Response().Arg2Reader()).Read(&resArg2); err != nil {
+		return status, err
+	}
+	if err := NewArgReader(call.Response().Arg3Reader()).Read(&resArg3); err != nil {
+		return status, err
+	}
+	if call.Response().ApplicationError() {
+		return status, fmt.Errorf("health check failed: %s", resArg3)
+	}
+
+	err = json.Unmarshal(resArg3, &status)
+	return status, err
+}
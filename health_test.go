@@ -0,0 +1,73 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/json"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckHealth(t *testing.T) {
+	opts := testutils.NewOpts().NoRelay()
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		client := ts.NewClient(nil)
+
+		ctx, cancel := NewContext(time.Second)
+		defer cancel()
+
+		status, err := client.CheckHealth(ctx, ts.HostPort())
+		require.NoError(t, err, "CheckHealth failed")
+		assert.Equal(t, HealthStatus{Ok: true}, status, "Channel should be healthy by default")
+
+		ts.Server().SetHealthHandler(func(ctx context.Context) (bool, string) {
+			return false, "draining"
+		})
+		status, err = client.CheckHealth(ctx, ts.HostPort())
+		require.NoError(t, err, "CheckHealth failed")
+		assert.Equal(t, HealthStatus{Ok: false, Message: "draining"}, status, "Unexpected health status")
+
+		// The health endpoint is also available as a JSON endpoint on the service.
+		var res map[string]interface{}
+		peer := client.Peers().GetOrAdd(ts.HostPort())
+		require.NoError(t, json.CallPeer(json.Wrap(ctx), peer, ts.ServiceName(), "_gometa_health", nil, &res),
+			"Call _gometa_health failed")
+		assert.Equal(t, map[string]interface{}{"ok": false, "message": "draining"}, res, "Unexpected JSON response")
+	})
+}
+
+func TestCheckHealthNoPeer(t *testing.T) {
+	client := testutils.NewClient(t, nil)
+	defer client.Close()
+
+	ctx, cancel := NewContext(testutils.Timeout(100 * time.Millisecond))
+	defer cancel()
+
+	_, err := client.CheckHealth(ctx, testutils.GetClosedHostPort(t))
+	assert.Error(t, err, "CheckHealth should fail if the peer is unreachable")
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tchannel
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// hedgedPeers is the set of peers selected by the concurrent attempts of a
+// hedged request, along with the attempts' calls that are in flight. A nil
+// hedgedPeers ignores all peers and calls.
+type hedgedPeers struct {
+	sync.Mutex
+	peers map[string]struct{}
+
+	// calls are the in-flight calls of all attempts.
+	calls map[*OutboundCall]struct{}
+	// claimed is set once an attempt's peer has started responding.
+	claimed bool
+}
+
+func newHedgedPeers(selected map[string]struct{}) *hedgedPeers {
+	return &hedgedPeers{
+		peers: copySet(selected),
+		calls: make(map[*OutboundCall]struct{}),
+	}
+}
+
+func (h *hedgedPeers) add(hostPort, host string) {
+	if h == nil {
+		return
+	}
+	h.Lock()
+	h.peers[hostPort] = struct{}{}
+	h.peers[host] = struct{}{}
+	h.Unlock()
+}
+
+func (h *hedgedPeers) copy() map[string]struct{} {
+	h.Lock()
+	defer h.Unlock()
+	return copySet(h.peers)
+}
+
+func copySet(set map[string]struct{}) map[string]struct{} {
+	copied := make(map[string]struct{}, len(set))
+	for k := range set {
+		copied[k] = struct{}{}
+	}
+	return copied
+}
+
+// hedgedResult is the result of one attempt of a hedged request.
+type hedgedResult struct {
+	res interface{}
+	err error
+}
+
+// runHedged runs an attempt of f, and if it has not completed after the hedge
+// delay, runs a duplicate attempt concurrently. It returns the result of the
+// first attempt to succeed, or of the last attempt if both fail. Each attempt
+// returns its own result, so the slower attempt cannot overwrite the result of
+// the successful attempt. Both attempts have completed when runHedged returns.
+func (ch *Channel) runHedged(ctx context.Context, opts *RetryOptions, rs *RequestState, f RetriableResultFunc) (interface{}, error) {
+	// Each attempt uses a separate RequestState, as they may select peers
+	// concurrently, and the peers selected by both are shared using hedged.
+	rs.hedged = newHedgedPeers(rs.SelectedPeers)
+	defer func() {
+		rs.SelectedPeers = rs.hedged.copy()
+		rs.hedged = nil
+	}()
+
+	results := make(chan hedgedResult, 2)
+	runAttemptAsync := func(ctx context.Context, rs *RequestState) {
+		res, err := runAttempt(ctx, opts, rs, f)
+		results <- hedgedResult{res, err}
+	}
+
+	primaryCtx, cancelPrimary := context.WithCancel(ctx)
+	defer cancelPrimary()
+	go runAttemptAsync(primaryCtx, rs)
+
+	timer := time.NewTimer(opts.HedgeDelay)
+	defer timer.Stop()
+	select {
+	case r := <-results:
+		return r.res, r.err
+	case <-timer.C:
+	}
+
+	if !ch.retryBudget.tryRetry(opts.IgnoreRetryBudget) {
+		ch.statsReporter.IncCounter("outbound.calls.retry-budget-exhausted", ch.StatsTags(), 1)
+		r := <-results
+		return r.res, r.err
+	}
+
+	hedgeRS := &RequestState{
+		Start:         rs.Start,
+		SelectedPeers: rs.hedged.copy(),
+		Attempt:       rs.Attempt,
+		retryOpts:     rs.retryOpts,
+		retryBudget:   rs.retryBudget,
+		hedged:        rs.hedged,
+
+		idempotencyKey: rs.idempotencyKey,
+	}
+	hedgeCtx, cancelHedge := context.WithCancel(ctx)
+	defer cancelHedge()
+	ch.statsReporter.IncCounter("outbound.calls.hedged", ch.StatsTags(), 1)
+	go runAttemptAsync(hedgeCtx, hedgeRS)
+
+	first := <-results
+	if first.err == nil {
+		// Cancel the slower attempt, and wait for it to return since it
+		// may still be using the request state. Its result is dropped.
+		cancelPrimary()
+		cancelHedge()
+		<-results
+		return first.res, nil
+	}
+	r := <-results
+	return r.res, r.err
+}
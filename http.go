@@ -0,0 +1,148 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+)
+
+var errHTTPListenerClosed = errors.New("http listener closed")
+
+// httpListener is a net.Listener that returns connections accepted by the
+// channel that start with an HTTP/1.x request, so they can be served using
+// an http.Server. It tracks the connections it returns so they can be closed
+// when the channel is closed.
+type httpListener struct {
+	addr      net.Addr
+	conns     chan net.Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	mut      sync.Mutex
+	isClosed bool
+	active   map[net.Conn]struct{}
+}
+
+func newHTTPListener(addr net.Addr) *httpListener {
+	return &httpListener{
+		addr:   addr,
+		conns:  make(chan net.Conn),
+		closed: make(chan struct{}),
+		active: make(map[net.Conn]struct{}),
+	}
+}
+
+func (l *httpListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.closed:
+		return nil, errHTTPListenerClosed
+	}
+}
+
+// Close stops the listener from returning new connections, and closes all
+// connections that have been returned but are not yet closed or hijacked.
+func (l *httpListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+
+	l.mut.Lock()
+	l.isClosed = true
+	active := l.active
+	l.active = make(map[net.Conn]struct{})
+	l.mut.Unlock()
+
+	for c := range active {
+		c.Close()
+	}
+	return nil
+}
+
+func (l *httpListener) Addr() net.Addr {
+	return l.addr
+}
+
+// serve passes the connection to the http.Server, or closes it if the
+// listener is closed.
+func (l *httpListener) serve(c net.Conn) {
+	l.mut.Lock()
+	if l.isClosed {
+		l.mut.Unlock()
+		c.Close()
+		return
+	}
+	l.active[c] = struct{}{}
+	l.mut.Unlock()
+
+	select {
+	case l.conns <- c:
+	case <-l.closed:
+		c.Close()
+	}
+}
+
+// connState is used as the http.Server's ConnState hook to stop tracking
+// connections once the server no longer owns them.
+func (l *httpListener) connState(c net.Conn, state http.ConnState) {
+	if state != http.StateClosed && state != http.StateHijacked {
+		return
+	}
+	l.mut.Lock()
+	delete(l.active, c)
+	l.mut.Unlock()
+}
+
+// serveHTTP serves an inbound HTTP/1.x connection using the channel's
+// HTTPHandler. The http.Server is started with the first HTTP connection.
+func (ch *Channel) serveHTTP(c net.Conn) {
+	ch.mutable.Lock()
+	if ch.mutable.state != ChannelListening {
+		ch.mutable.Unlock()
+		c.Close()
+		return
+	}
+	if ch.mutable.httpServer == nil {
+		l := newHTTPListener(c.LocalAddr())
+		ch.mutable.httpListener = l
+		ch.mutable.httpServer = &http.Server{
+			Handler:   ch.httpHandler,
+			ConnState: l.connState,
+		}
+		go ch.mutable.httpServer.Serve(l)
+	}
+	l := ch.mutable.httpListener
+	ch.mutable.Unlock()
+
+	l.serve(c)
+}
+
+// closeHTTPLocked stops the http.Server and closes all HTTP/1.x connections.
+// The channel must be locked.
+func (ch *Channel) closeHTTPLocked() {
+	// http.Server.Close requires Go 1.8, so close the listener, which stops
+	// Serve and closes the connections it returned.
+	if ch.mutable.httpListener != nil {
+		ch.mutable.httpListener.Close()
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/uber/tchannel-go"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+// BackendOptions are options used when creating a Backend.
+type BackendOptions struct {
+	// Client is the HTTP client used to make requests to the backend.
+	// If this is nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// Tracer is used to extract the tracing span from the application
+	// headers of JSON calls. If this is nil, opentracing.GlobalTracer() is used.
+	Tracer opentracing.Tracer
+}
+
+// Backend is a tchannel.Handler that serves TChannel calls using an HTTP
+// backend. A call to a method is sent as a POST request to <baseURL>/<method>,
+// with arg3 as the body. For JSON calls, application headers are sent as HTTP
+// headers with HeaderPrefix.
+//
+// The response body is returned as arg3. If the backend responds with a
+// status other than 2xx, the call returns an application error. Backend is
+// typically set as the handler for a SubChannel using SetHandler.
+type Backend struct {
+	baseURL string
+	client  *http.Client
+	tracer  opentracing.Tracer
+}
+
+// NewBackend returns a Backend that makes requests to the given base URL.
+func NewBackend(baseURL string, opts *BackendOptions) *Backend {
+	if opts == nil {
+		opts = &BackendOptions{}
+	}
+	b := &Backend{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  opts.Client,
+		tracer:  opts.Tracer,
+	}
+	if b.client == nil {
+		b.client = http.DefaultClient
+	}
+	return b
+}
+
+// Handle serves the call by making a request to the HTTP backend.
+func (b *Backend) Handle(ctx context.Context, call *tchannel.InboundCall) {
+	if err := b.handle(ctx, call); err != nil {
+		call.Response().SendSystemError(err)
+	}
+}
+
+func (b *Backend) handle(ctx context.Context, call *tchannel.InboundCall) error {
+	isJSON := call.Format() == tchannel.JSON
+
+	var arg2 []byte
+	if err := tchannel.NewArgReader(call.Arg2Reader()).Read(&arg2); err != nil {
+		return err
+	}
+	var headers map[string]string
+	if isJSON && len(arg2) > 0 {
+		if err := json.Unmarshal(arg2, &headers); err != nil {
+			return tchannel.NewSystemError(tchannel.ErrCodeBadRequest, "failed to decode JSON headers: %v", err)
+		}
+		ctx = tchannel.ExtractInboundSpan(ctx, call, headers, b.getTracer())
+	}
+
+	arg3Reader, err := call.Arg3Reader()
+	if err != nil {
+		return err
+	}
+	defer arg3Reader.Close()
+
+	req, err := http.NewRequest("POST", b.baseURL+"/"+call.MethodString(), arg3Reader)
+	if err != nil {
+		return tchannel.NewSystemError(tchannel.ErrCodeBadRequest, "invalid method %q: %v", call.MethodString(), err)
+	}
+	req = req.WithContext(ctx)
+	setAppHeaders(req.Header, headers)
+	req.Header.Set(CallerHeader, call.CallerName())
+	if shardKey := call.ShardKey(); shardKey != "" {
+		req.Header.Set(ShardKeyHeader, shardKey)
+	}
+	if isJSON {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return tchannel.GetContextError(ctxErr)
+		}
+		return tchannel.NewSystemError(tchannel.ErrCodeNetwork, "HTTP backend request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	response := call.Response()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if err := response.SetApplicationError(); err != nil {
+			return err
+		}
+	}
+
+	var resArg2 []byte
+	if isJSON {
+		resHeaders := getAppHeaders(resp.Header)
+		if resHeaders == nil {
+			resHeaders = make(map[string]string)
+		}
+		if resArg2, err = json.Marshal(resHeaders); err != nil {
+			return err
+		}
+	}
+	if err := tchannel.NewArgWriter(response.Arg2Writer()).Write(resArg2); err != nil {
+		return err
+	}
+
+	arg3Writer, err := response.Arg3Writer()
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(arg3Writer, resp.Body); err != nil {
+		return fmt.Errorf("failed to copy HTTP backend response: %v", err)
+	}
+	return arg3Writer.Close()
+}
+
+func (b *Backend) getTracer() opentracing.Tracer {
+	if b.tracer != nil {
+		return b.tracer
+	}
+	return opentracing.GlobalTracer()
+}
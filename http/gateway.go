@@ -0,0 +1,254 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/uber/tchannel-go"
+)
+
+// HTTP headers used by the Gateway and Backend to carry TChannel fields.
+const (
+	// HeaderPrefix is the prefix for HTTP headers that are mapped to and from
+	// TChannel application headers. E.g. "Tchannel-Header-Foo: bar" is mapped
+	// to the application header "foo" with the value "bar".
+	HeaderPrefix = "Tchannel-Header-"
+
+	// TimeoutHeader sets the timeout for the TChannel call, as a duration
+	// such as "500ms".
+	TimeoutHeader = "Tchannel-Timeout"
+
+	// ShardKeyHeader sets the shard key for the TChannel call.
+	ShardKeyHeader = "Tchannel-Shard-Key"
+
+	// RoutingKeyHeader sets the routing key for the TChannel call.
+	RoutingKeyHeader = "Tchannel-Routing-Key"
+
+	// RoutingDelegateHeader sets the routing delegate for the TChannel call.
+	RoutingDelegateHeader = "Tchannel-Routing-Delegate"
+
+	// CallerHeader is the name of the service that made the TChannel call.
+	// It is only set on requests made by the Backend.
+	CallerHeader = "Tchannel-Caller"
+
+	// ApplicationErrorHeader is set to "true" on responses for TChannel
+	// calls that returned an application error.
+	ApplicationErrorHeader = "Tchannel-Application-Error"
+)
+
+const defaultGatewayTimeout = time.Second
+
+// GatewayOptions are options used when creating a Gateway.
+type GatewayOptions struct {
+	// Format is the arg scheme used for calls. Application headers are
+	// encoded as a JSON object in arg2 for the JSON format. For other
+	// formats, arg2 is empty and application headers are not supported.
+	// If this is empty, tchannel.JSON is used.
+	Format tchannel.Format
+
+	// Timeout is the timeout for calls that do not set TimeoutHeader.
+	// If this is 0, the default of 1 second is used.
+	Timeout time.Duration
+}
+
+// Gateway is an http.Handler that makes a TChannel call for each HTTP request
+// it receives. The request path is /<service>/<method>, and the request body
+// is sent as arg3. Peers for each service are selected from the channel's
+// SubChannel for that service.
+//
+// Successful calls respond with a 200 and arg3 as the body. Calls that return
+// an application error respond with a 500 and ApplicationErrorHeader set.
+// System errors are mapped to the closest HTTP status code, with the error
+// message as the body.
+type Gateway struct {
+	ch      *tchannel.Channel
+	format  tchannel.Format
+	timeout time.Duration
+}
+
+// NewGateway returns a Gateway that makes calls using the given channel.
+func NewGateway(ch *tchannel.Channel, opts *GatewayOptions) *Gateway {
+	if opts == nil {
+		opts = &GatewayOptions{}
+	}
+	g := &Gateway{
+		ch:      ch,
+		format:  opts.Format,
+		timeout: opts.Timeout,
+	}
+	if g.format == "" {
+		g.format = tchannel.JSON
+	}
+	if g.timeout <= 0 {
+		g.timeout = defaultGatewayTimeout
+	}
+	return g
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		http.Error(w, "request path must be /<service>/<method>", http.StatusNotFound)
+		return
+	}
+	service, method := parts[0], parts[1]
+
+	timeout := g.timeout
+	if t := r.Header.Get(TimeoutHeader); t != "" {
+		var err error
+		if timeout, err = time.ParseDuration(t); err != nil || timeout <= 0 {
+			http.Error(w, fmt.Sprintf("invalid %v: %q", TimeoutHeader, t), http.StatusBadRequest)
+			return
+		}
+	}
+
+	headers := getAppHeaders(r.Header)
+	if len(headers) > 0 && g.format != tchannel.JSON {
+		http.Error(w, fmt.Sprintf("application headers are not supported for %v", g.format), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := tchannel.NewContextBuilder(timeout).
+		SetParentContext(r.Context()).
+		SetFormat(g.format).
+		SetShardKey(r.Header.Get(ShardKeyHeader)).
+		SetRoutingKey(r.Header.Get(RoutingKeyHeader)).
+		SetRoutingDelegate(r.Header.Get(RoutingDelegateHeader)).
+		Build()
+	defer cancel()
+
+	call, err := g.ch.GetSubChannel(service).BeginCall(ctx, method, &tchannel.CallOptions{Format: g.format})
+	if err != nil {
+		writeCallError(w, err)
+		return
+	}
+
+	var arg2 []byte
+	if g.format == tchannel.JSON {
+		headers = tchannel.InjectOutboundSpan(call.Response(), headers)
+		if arg2, err = json.Marshal(headers); err != nil {
+			writeCallError(w, err)
+			return
+		}
+	}
+	if err := tchannel.NewArgWriter(call.Arg2Writer()).Write(arg2); err != nil {
+		writeCallError(w, err)
+		return
+	}
+	if err := writeArg3(call, r.Body); err != nil {
+		writeCallError(w, err)
+		return
+	}
+
+	response := call.Response()
+	var resArg2 []byte
+	if err := tchannel.NewArgReader(response.Arg2Reader()).Read(&resArg2); err != nil {
+		writeCallError(w, err)
+		return
+	}
+	if g.format == tchannel.JSON && len(resArg2) > 0 {
+		var resHeaders map[string]string
+		if err := json.Unmarshal(resArg2, &resHeaders); err != nil {
+			writeCallError(w, err)
+			return
+		}
+		setAppHeaders(w.Header(), resHeaders)
+	}
+
+	arg3Reader, err := response.Arg3Reader()
+	if err != nil {
+		writeCallError(w, err)
+		return
+	}
+	defer arg3Reader.Close()
+
+	if g.format == tchannel.JSON {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	if response.ApplicationError() {
+		w.Header().Set(ApplicationErrorHeader, "true")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	// Once the response body has started, errors can no longer be reported.
+	io.Copy(w, arg3Reader)
+}
+
+func writeArg3(call *tchannel.OutboundCall, body io.Reader) error {
+	arg3Writer, err := call.Arg3Writer()
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		if _, err := io.Copy(arg3Writer, body); err != nil {
+			return err
+		}
+	}
+	return arg3Writer.Close()
+}
+
+// writeCallError writes an HTTP error response for a failed TChannel call.
+func writeCallError(w http.ResponseWriter, err error) {
+	http.Error(w, tchannel.GetSystemErrorMessage(err), httpStatusForError(err))
+}
+
+func httpStatusForError(err error) int {
+	switch tchannel.GetSystemErrorCode(err) {
+	case tchannel.ErrCodeTimeout:
+		return http.StatusGatewayTimeout
+	case tchannel.ErrCodeBusy, tchannel.ErrCodeDeclined:
+		return http.StatusServiceUnavailable
+	case tchannel.ErrCodeBadRequest:
+		return http.StatusBadRequest
+	case tchannel.ErrCodeNetwork, tchannel.ErrCodeProtocol:
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+// getAppHeaders returns the application headers from HTTP headers that have
+// HeaderPrefix. Header names are lowercased, since HTTP headers are not case
+// sensitive.
+func getAppHeaders(h http.Header) map[string]string {
+	var headers map[string]string
+	for k, vs := range h {
+		if len(vs) == 0 || !strings.HasPrefix(k, HeaderPrefix) {
+			continue
+		}
+		if headers == nil {
+			headers = make(map[string]string)
+		}
+		headers[strings.ToLower(strings.TrimPrefix(k, HeaderPrefix))] = vs[0]
+	}
+	return headers
+}
+
+// setAppHeaders sets HTTP headers for the given application headers.
+func setAppHeaders(h http.Header, headers map[string]string) {
+	for k, v := range headers {
+		h.Set(HeaderPrefix+k, v)
+	}
+}
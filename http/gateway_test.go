@@ -0,0 +1,163 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package http
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type gatewayResponse struct {
+	status  int
+	headers http.Header
+	body    string
+}
+
+func gatewayRequest(t *testing.T, gatewayURL, path, body string, headers map[string]string) gatewayResponse {
+	req, err := http.NewRequest("POST", gatewayURL+path, strings.NewReader(body))
+	require.NoError(t, err, "NewRequest failed")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err, "HTTP request to gateway failed")
+	defer resp.Body.Close()
+
+	resBody, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err, "Failed to read gateway response")
+	return gatewayResponse{resp.StatusCode, resp.Header, string(resBody)}
+}
+
+func TestGateway(t *testing.T) {
+	serverOpts := testutils.NewOpts().
+		SetServiceName("svc").
+		AddLogFilter("Couldn't find handler.", 1)
+	server := testutils.NewServer(t, serverOpts)
+	defer server.Close()
+	testutils.RegisterFunc(server, "echo", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		assert.Equal(t, tchannel.JSON, args.Format, "Unexpected format")
+		assert.Equal(t, `{"foo":"bar"}`, string(args.Arg2), "Unexpected application headers")
+		assert.Equal(t, "sk", tchannel.CurrentCall(ctx).ShardKey(), "Unexpected shard key")
+		return &raw.Res{Arg2: []byte(`{"res":"baz"}`), Arg3: args.Arg3}, nil
+	})
+	testutils.RegisterFunc(server, "appError", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		return &raw.Res{IsErr: true, Arg2: []byte(`{}`), Arg3: []byte(`{"err":"failed"}`)}, nil
+	})
+	testutils.RegisterFunc(server, "busy", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		return &raw.Res{SystemErr: tchannel.ErrServerBusy}, nil
+	})
+
+	client := testutils.NewClient(t, nil)
+	defer client.Close()
+	client.Peers().Add(server.PeerInfo().HostPort)
+
+	gateway := httptest.NewServer(NewGateway(client, nil))
+	defer gateway.Close()
+
+	res := gatewayRequest(t, gateway.URL, "/svc/echo", `{"a":1}`, map[string]string{
+		"Tchannel-Header-Foo": "bar",
+		ShardKeyHeader:        "sk",
+	})
+	assert.Equal(t, http.StatusOK, res.status, "Unexpected status for successful call")
+	assert.Equal(t, `{"a":1}`, res.body, "Unexpected body")
+	assert.Equal(t, "baz", res.headers.Get("Tchannel-Header-Res"), "Missing response header")
+	assert.Equal(t, "application/json", res.headers.Get("Content-Type"), "Unexpected content type")
+
+	res = gatewayRequest(t, gateway.URL, "/svc/appError", `{}`, nil)
+	assert.Equal(t, http.StatusInternalServerError, res.status, "Unexpected status for application error")
+	assert.Equal(t, "true", res.headers.Get(ApplicationErrorHeader), "Missing application error header")
+	assert.Equal(t, `{"err":"failed"}`, res.body, "Unexpected body for application error")
+
+	tests := []struct {
+		msg     string
+		path    string
+		headers map[string]string
+		status  int
+	}{
+		{msg: "system error", path: "/svc/busy", status: http.StatusServiceUnavailable},
+		{msg: "missing method", path: "/svc", status: http.StatusNotFound},
+		{msg: "no handler", path: "/svc/unknown", status: http.StatusBadRequest},
+		{
+			msg:     "invalid timeout",
+			path:    "/svc/echo",
+			headers: map[string]string{TimeoutHeader: "1"},
+			status:  http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		res := gatewayRequest(t, gateway.URL, tt.path, `{}`, tt.headers)
+		assert.Equal(t, tt.status, res.status, "%v: unexpected status, body: %v", tt.msg, res.body)
+	}
+}
+
+func TestGatewayToBackend(t *testing.T) {
+	backendMux := http.NewServeMux()
+	backendMux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "bar", r.Header.Get("Tchannel-Header-Foo"), "Missing application header")
+		assert.Equal(t, "gateway", r.Header.Get(CallerHeader), "Unexpected caller")
+		w.Header().Set("Tchannel-Header-Res", "baz")
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	})
+	backendMux.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "backend failed", http.StatusInternalServerError)
+	})
+	backend := httptest.NewServer(backendMux)
+	defer backend.Close()
+
+	server := testutils.NewServer(t, testutils.NewOpts().SetServiceName("svc"))
+	defer server.Close()
+	server.GetSubChannel("svc").SetHandler(NewBackend(backend.URL, nil))
+
+	client := testutils.NewClient(t, testutils.NewOpts().SetServiceName("gateway"))
+	defer client.Close()
+	client.Peers().Add(server.PeerInfo().HostPort)
+
+	gateway := httptest.NewServer(NewGateway(client, nil))
+	defer gateway.Close()
+
+	res := gatewayRequest(t, gateway.URL, "/svc/echo", `{"a":1}`, map[string]string{"Tchannel-Header-Foo": "bar"})
+	assert.Equal(t, http.StatusOK, res.status, "Unexpected status, body: %v", res.body)
+	assert.Equal(t, `{"a":1}`, res.body, "Unexpected body")
+	assert.Equal(t, "baz", res.headers.Get("Tchannel-Header-Res"), "Missing response header")
+
+	res = gatewayRequest(t, gateway.URL, "/svc/fail", `{}`, nil)
+	assert.Equal(t, http.StatusInternalServerError, res.status, "Unexpected status for backend failure")
+	assert.Equal(t, "true", res.headers.Get(ApplicationErrorHeader), "Backend failure should be an application error")
+	assert.Equal(t, "backend failed\n", res.body, "Unexpected body for backend failure")
+
+	// Once the backend is down, calls fail with a network error.
+	backend.Close()
+	res = gatewayRequest(t, gateway.URL, "/svc/echo", `{}`, nil)
+	assert.Equal(t, http.StatusBadGateway, res.status, "Unexpected status when backend is down, body: %v", res.body)
+}
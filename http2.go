@@ -0,0 +1,69 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"net"
+
+	"golang.org/x/net/http2"
+)
+
+// http2Preface is the client connection preface that every HTTP/2 connection
+// starts with.
+const http2Preface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+// channelSetter is implemented by an HTTP2Handler that needs a reference to
+// the channel it serves.
+type channelSetter interface {
+	SetChannel(ch *Channel)
+}
+
+// serveHTTP2 serves an inbound HTTP/2 connection using the channel's
+// HTTP2Handler until the connection is closed.
+func (ch *Channel) serveHTTP2(c net.Conn) {
+	ch.mutable.Lock()
+	if ch.mutable.state != ChannelListening {
+		ch.mutable.Unlock()
+		c.Close()
+		return
+	}
+	if ch.mutable.http2Conns == nil {
+		ch.mutable.http2Conns = make(map[net.Conn]struct{})
+	}
+	ch.mutable.http2Conns[c] = struct{}{}
+	ch.mutable.Unlock()
+
+	defer func() {
+		ch.mutable.Lock()
+		delete(ch.mutable.http2Conns, c)
+		ch.mutable.Unlock()
+	}()
+
+	server := &http2.Server{}
+	server.ServeConn(c, &http2.ServeConnOpts{Handler: ch.http2Handler})
+}
+
+// closeHTTP2ConnsLocked closes all HTTP/2 connections. The channel must be locked.
+func (ch *Channel) closeHTTP2ConnsLocked() {
+	for c := range ch.mutable.http2Conns {
+		c.Close()
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	opts := testutils.NewOpts().NoRelay()
+	opts.HTTPHandler = mux
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		client := &http.Client{Transport: &http.Transport{}}
+		res, err := client.Get("http://" + ts.HostPort() + "/health")
+		require.NoError(t, err, "HTTP request failed")
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		require.NoError(t, err, "Failed to read HTTP response")
+		assert.Equal(t, "ok", string(body), "Unexpected HTTP response")
+
+		// TChannel calls are served on the same port.
+		ctx, cancel := NewContext(testutils.Timeout(time.Second))
+		defer cancel()
+		require.NoError(t, ts.NewClient(nil).Ping(ctx, ts.HostPort()), "Ping failed")
+
+		res, err = client.Post("http://"+ts.HostPort()+"/unknown", "text/plain", nil)
+		require.NoError(t, err, "HTTP request failed")
+		res.Body.Close()
+		assert.Equal(t, http.StatusNotFound, res.StatusCode, "Unexpected status for unknown path")
+	})
+}
+
+func TestHTTPHandlerClosed(t *testing.T) {
+	opts := testutils.NewOpts()
+	opts.HTTPHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	server := testutils.NewServer(t, opts)
+	hostPort := server.PeerInfo().HostPort
+
+	client := &http.Client{Transport: &http.Transport{}}
+	res, err := client.Get("http://" + hostPort + "/")
+	require.NoError(t, err, "HTTP request failed")
+	res.Body.Close()
+
+	server.Close()
+	_, err = client.Get("http://" + hostPort + "/")
+	assert.Error(t, err, "HTTP requests should fail once the channel is closed")
+}
@@ -53,15 +53,52 @@ func (e ErrAdvertiseFailed) Error() string {
 	return fmt.Sprintf("advertise failed, retry: %v, cause: %v", e.WillRetry, e.Cause)
 }
 
+// defaultAdvertiseTTL is the TTL of services advertised without a TTL, and
+// is re-advertised every advertiseInterval plus up to advertiseFuzzInterval.
+const defaultAdvertiseTTL = 60 * time.Second
+
+// advertisement is a set of services that are advertised together, and
+// re-advertised periodically until the advertisement is stopped.
+type advertisement struct {
+	services []string
+	ttl      time.Duration
+	quit     chan struct{}
+}
+
+func newAdvertisement(services []string, ttl time.Duration) *advertisement {
+	if ttl <= 0 {
+		ttl = defaultAdvertiseTTL
+	}
+	return &advertisement{services: services, ttl: ttl, quit: make(chan struct{})}
+}
+
+// stopped returns whether the advertisement was stopped using stop.
+func (ad *advertisement) stopped() bool {
+	select {
+	case <-ad.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+func (ad *advertisement) stop() {
+	if !ad.stopped() {
+		close(ad.quit)
+	}
+}
+
 // fuzzInterval returns a fuzzed version of the interval based on FullJitter as described here:
 // http://www.awsarchitectureblog.com/2015/03/backoff.html
 func fuzzInterval(interval time.Duration) time.Duration {
 	return time.Duration(rand.Int63n(int64(interval)))
 }
 
-// fuzzedAdvertiseInterval returns the time to sleep between successful advertisements.
-func (c *Client) fuzzedAdvertiseInterval() time.Duration {
-	return advertiseInterval + fuzzInterval(advertiseFuzzInterval)
+// fuzzedAdvertiseInterval returns the time to sleep between successful
+// advertisements, which is 5/6 of the TTL plus a fuzz of up to 1/3 of the TTL.
+// For the default TTL, this is advertiseInterval plus up to advertiseFuzzInterval.
+func (ad *advertisement) fuzzedAdvertiseInterval() time.Duration {
+	return ad.ttl - ad.ttl/6 + fuzzInterval(ad.ttl/3)
 }
 
 // logFailedRegistrationRetry logs either a warning or info depending on the number of
@@ -75,9 +112,18 @@ func (c *Client) logFailedRegistrationRetry(errLogger tchannel.Logger, consecuti
 	logFn("Hyperbahn client registration failed, will retry.")
 }
 
-// advertiseLoop readvertises the service approximately every minute (with some fuzzing).
-func (c *Client) advertiseLoop() {
-	sleepFor := c.fuzzedAdvertiseInterval()
+// advertiseFailed notifies the Handler and the OnAdvertiseFailed callback
+// that advertising the services failed.
+func (c *Client) advertiseFailed(services []string, err ErrAdvertiseFailed) {
+	c.opts.Handler.OnError(err)
+	if f := c.opts.OnAdvertiseFailed; f != nil {
+		f(services, err)
+	}
+}
+
+// advertiseLoop readvertises the services approximately every TTL (with some fuzzing).
+func (c *Client) advertiseLoop(ad *advertisement) {
+	sleepFor := ad.fuzzedAdvertiseInterval()
 	consecutiveFailures := uint(0)
 
 	for {
@@ -86,17 +132,20 @@ func (c *Client) advertiseLoop() {
 			c.tchan.Logger().Infof("Hyperbahn client closed")
 			return
 		}
+		if ad.stopped() {
+			return
+		}
 
-		if err := c.sendAdvertise(); err != nil {
+		if err := c.sendAdvertise(ad.services); err != nil {
 			consecutiveFailures++
 			errLogger := c.tchan.Logger().WithFields(tchannel.ErrField(err))
 			if consecutiveFailures >= maxAdvertiseFailures && c.opts.FailStrategy == FailStrategyFatal {
-				c.opts.Handler.OnError(ErrAdvertiseFailed{Cause: err, WillRetry: false})
+				c.advertiseFailed(ad.services, ErrAdvertiseFailed{Cause: err, WillRetry: false})
 				errLogger.Fatal("Hyperbahn client registration failed.")
 			}
 
 			c.logFailedRegistrationRetry(errLogger, consecutiveFailures)
-			c.opts.Handler.OnError(ErrAdvertiseFailed{Cause: err, WillRetry: true})
+			c.advertiseFailed(ad.services, ErrAdvertiseFailed{Cause: err, WillRetry: true})
 
 			// Even after many failures, cap backoff.
 			if consecutiveFailures < maxAdvertiseFailures {
@@ -104,19 +153,37 @@ func (c *Client) advertiseLoop() {
 			}
 		} else {
 			c.opts.Handler.On(Readvertised)
-			sleepFor = c.fuzzedAdvertiseInterval()
+			sleepFor = ad.fuzzedAdvertiseInterval()
 			consecutiveFailures = 0
 		}
 	}
 }
 
+// readvertise advertises the channel's new host:port as soon as its listener
+// is rebound, rather than waiting for the next periodic advertisement.
+func (c *Client) readvertise(tchannel.LocalPeerInfo) {
+	if c.IsClosed() {
+		return
+	}
+
+	for _, ad := range c.advertisements() {
+		if err := c.sendAdvertise(ad.services); err != nil {
+			c.tchan.Logger().WithFields(tchannel.ErrField(err)).Warn(
+				"Hyperbahn client failed to advertise after rebind, will retry.")
+			c.advertiseFailed(ad.services, ErrAdvertiseFailed{Cause: err, WillRetry: true})
+			continue
+		}
+		c.opts.Handler.On(Readvertised)
+	}
+}
+
 // initialAdvertise will do the initial Advertise call to Hyperbahn with additional
 // retries on top of the built-in TChannel retries. It will use exponential backoff
 // between each of the call attempts.
-func (c *Client) initialAdvertise() error {
+func (c *Client) initialAdvertise(services []string) error {
 	var err error
 	for attempt := uint(0); attempt < maxAdvertiseFailures; attempt++ {
-		err = c.sendAdvertise()
+		err = c.sendAdvertise(services)
 		if err == nil || err == errEphemeralPeer {
 			break
 		}
@@ -26,6 +26,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/uber-go/atomic"
 	"github.com/uber/tchannel-go"
 	"github.com/uber/tchannel-go/json"
 	"github.com/uber/tchannel-go/testutils"
@@ -108,6 +109,42 @@ func TestNotListeningChannel(t *testing.T) {
 	})
 }
 
+func TestReadvertiseOnRebind(t *testing.T) {
+	withSetup(t, func(hypCh *tchannel.Channel, hyperbahnHostPort string) {
+		adHostPorts := make(chan string, 2)
+		adHandler := func(ctx json.Context, req *AdRequest) (*AdResponse, error) {
+			adHostPorts <- tchannel.CurrentCall(ctx).RemotePeer().HostPort
+			return &AdResponse{1}, nil
+		}
+		json.Register(hypCh, json.Handlers{"ad": adHandler}, nil)
+
+		// Block the periodic advertisements until the test ends.
+		done := make(chan struct{})
+		defer close(done)
+		clientOpts := &ClientOptions{TimeSleep: func(time.Duration) { <-done }}
+
+		ch := testutils.NewServer(t, nil)
+		defer ch.Close()
+		client, err := NewClient(ch, configFor(hyperbahnHostPort), clientOpts)
+		require.NoError(t, err, "hyperbahn NewClient failed")
+		defer client.Close()
+
+		require.NoError(t, client.Advertise(), "Advertise failed")
+		assert.Equal(t, ch.PeerInfo().HostPort, <-adHostPorts, "Advertised unexpected host:port")
+
+		prevHostPort := ch.PeerInfo().HostPort
+		require.NoError(t, ch.RebindAndServe("127.0.0.1:0"), "RebindAndServe failed")
+		require.NotEqual(t, prevHostPort, ch.PeerInfo().HostPort, "Expected a new host:port")
+
+		select {
+		case hostPort := <-adHostPorts:
+			assert.Equal(t, ch.PeerInfo().HostPort, hostPort, "Expected the new host:port to be advertised")
+		case <-time.After(testutils.Timeout(time.Second)):
+			t.Fatal("Timed out waiting for advertisement after rebind")
+		}
+	})
+}
+
 type retryTest struct {
 	// channel used to control the response to an 'ad' call.
 	respCh chan int
@@ -340,6 +377,140 @@ func TestRetryFailure(t *testing.T) {
 	})
 }
 
+func TestAdvertiseInterval(t *testing.T) {
+	for _, ttl := range []time.Duration{defaultAdvertiseTTL, 6 * time.Second, 10 * time.Minute} {
+		ad := newAdvertisement([]string{"svc"}, ttl)
+		for i := 0; i < 100; i++ {
+			interval := ad.fuzzedAdvertiseInterval()
+			assert.True(t, interval >= ttl*5/6 && interval < ttl*7/6,
+				"TTL %v got unexpected advertise interval %v", ttl, interval)
+		}
+	}
+	assert.Equal(t, defaultAdvertiseTTL, newAdvertisement(nil, 0).ttl, "Unexpected default TTL")
+	checkAdvertiseInterval(t, newAdvertisement(nil, 0).fuzzedAdvertiseInterval())
+}
+
+// blockingSleep returns client options whose re-advertisements are blocked
+// until the returned function is called.
+func blockingSleep() (*ClientOptions, func()) {
+	done := make(chan struct{})
+	return &ClientOptions{TimeSleep: func(time.Duration) { <-done }}, func() { close(done) }
+}
+
+func TestAdvertiseService(t *testing.T) {
+	withSetup(t, func(hypCh *tchannel.Channel, hyperbahnHostPort string) {
+		adRequests := make(chan *AdRequest, 3)
+		unadRequests := make(chan *AdRequest, 3)
+		json.Register(hypCh, json.Handlers{
+			"ad": func(ctx json.Context, req *AdRequest) (*AdResponse, error) {
+				adRequests <- req
+				return &AdResponse{1}, nil
+			},
+			"unad": func(ctx json.Context, req *AdRequest) (*AdResponse, error) {
+				unadRequests <- req
+				return &AdResponse{}, nil
+			},
+		}, nil)
+
+		clientOpts, unblock := blockingSleep()
+		defer unblock()
+		ch := testutils.NewServer(t, testutils.NewOpts().SetServiceName("my-client"))
+		defer ch.Close()
+		client, err := NewClient(ch, configFor(hyperbahnHostPort), clientOpts)
+		require.NoError(t, err, "hyperbahn NewClient failed")
+		defer client.Close()
+
+		require.NoError(t, client.Advertise(ch.GetSubChannel("svc-2")), "Advertise failed")
+		assert.Equal(t, &AdRequest{[]service{{Name: "my-client"}, {Name: "svc-2"}}}, <-adRequests, "Unexpected ad request")
+
+		require.NoError(t, client.AdvertiseService("svc-3", &ServiceOptions{TTL: time.Minute}), "AdvertiseService failed")
+		assert.Equal(t, &AdRequest{[]service{{Name: "svc-3"}}}, <-adRequests, "Services should be advertised independently")
+		assert.Error(t, client.AdvertiseService("svc-2", nil), "Services cannot be advertised twice")
+
+		require.NoError(t, client.UnadvertiseService("svc-2"), "UnadvertiseService failed")
+		assert.Equal(t, &AdRequest{[]service{{Name: "svc-2"}}}, <-unadRequests, "Unexpected unad request")
+		assert.Error(t, client.UnadvertiseService("svc-2"), "Service is no longer advertised")
+
+		require.NoError(t, client.AdvertiseService("svc-2", nil), "Unadvertised service should be advertised again")
+		assert.Equal(t, &AdRequest{[]service{{Name: "svc-2"}}}, <-adRequests, "Unexpected ad request")
+
+		client.Close()
+		assert.Len(t, unadRequests, 0, "Services should not be unadvertised on close by default")
+		assert.Error(t, client.AdvertiseService("svc-4", nil), "Closed client should not advertise")
+	})
+}
+
+func TestUnadvertiseOnClose(t *testing.T) {
+	withSetup(t, func(hypCh *tchannel.Channel, hyperbahnHostPort string) {
+		unadRequests := make(chan *AdRequest, 2)
+		json.Register(hypCh, json.Handlers{
+			"ad": func(ctx json.Context, req *AdRequest) (*AdResponse, error) {
+				return &AdResponse{1}, nil
+			},
+			"unad": func(ctx json.Context, req *AdRequest) (*AdResponse, error) {
+				unadRequests <- req
+				return &AdResponse{}, nil
+			},
+		}, nil)
+
+		clientOpts, unblock := blockingSleep()
+		defer unblock()
+		clientOpts.UnadvertiseOnClose = true
+		ch := testutils.NewServer(t, testutils.NewOpts().SetServiceName("my-client"))
+		client, err := NewClient(ch, configFor(hyperbahnHostPort), clientOpts)
+		require.NoError(t, err, "hyperbahn NewClient failed")
+
+		require.NoError(t, client.Advertise(), "Advertise failed")
+		require.NoError(t, client.AdvertiseService("svc-2", nil), "AdvertiseService failed")
+
+		ch.Close()
+		assert.True(t, client.IsClosed(), "Closing the channel should close the client")
+		assert.Equal(t, &AdRequest{[]service{{Name: "my-client"}, {Name: "svc-2"}}}, <-unadRequests, "Unexpected unad request")
+
+		client.Close()
+		assert.Len(t, unadRequests, 0, "Services should only be unadvertised once")
+	})
+}
+
+func TestOnAdvertiseFailed(t *testing.T) {
+	withSetup(t, func(hypCh *tchannel.Channel, hyperbahnHostPort string) {
+		var fail atomic.Bool
+		json.Register(hypCh, json.Handlers{
+			"ad": func(ctx json.Context, req *AdRequest) (*AdResponse, error) {
+				if fail.Load() {
+					return nil, tchannel.NewSystemError(tchannel.ErrCodeBadRequest, "rejected")
+				}
+				return &AdResponse{1}, nil
+			},
+		}, nil)
+
+		failed := make(chan []string, 2)
+		clientOpts, unblock := blockingSleep()
+		defer unblock()
+		clientOpts.OnAdvertiseFailed = func(services []string, err ErrAdvertiseFailed) {
+			assert.True(t, err.WillRetry, "Advertise should be retried")
+			failed <- services
+		}
+
+		opts := testutils.NewOpts().
+			SetServiceName("my-client").
+			AddLogFilter("Hyperbahn client failed to advertise after rebind, will retry.", 2)
+		ch := testutils.NewServer(t, opts)
+		defer ch.Close()
+		client, err := NewClient(ch, configFor(hyperbahnHostPort), clientOpts)
+		require.NoError(t, err, "hyperbahn NewClient failed")
+		defer client.Close()
+
+		require.NoError(t, client.Advertise(), "Advertise failed")
+		require.NoError(t, client.AdvertiseService("svc-2", nil), "AdvertiseService failed")
+
+		fail.Store(true)
+		require.NoError(t, ch.RebindAndServe("127.0.0.1:0"), "RebindAndServe failed")
+		assert.Equal(t, []string{"my-client"}, <-failed, "Unexpected failed services")
+		assert.Equal(t, []string{"svc-2"}, <-failed, "Unexpected failed services")
+	})
+}
+
 func checkAdvertiseInterval(t *testing.T, sleptFor time.Duration) {
 	assert.True(t, sleptFor >= advertiseInterval,
 		"advertise interval should be > advertiseInterval")
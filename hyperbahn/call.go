@@ -26,7 +26,10 @@ import (
 	"github.com/uber/tchannel-go"
 )
 
-var errEphemeralPeer = errors.New("cannot advertise on channel that has not called ListenAndServe")
+var (
+	errEphemeralPeer = errors.New("cannot advertise on channel that has not called ListenAndServe")
+	errClientClosed  = errors.New("cannot advertise using a closed hyperbahn client")
+)
 
 // The following parameters define the request/response for the Hyperbahn 'ad' call.
 type service struct {
@@ -44,11 +47,11 @@ type AdResponse struct {
 	ConnectionCount int `json:"connectionCount"`
 }
 
-func (c *Client) createRequest() *AdRequest {
+func createRequest(services []string) *AdRequest {
 	req := &AdRequest{
-		Services: make([]service, len(c.services)),
+		Services: make([]service, len(services)),
 	}
-	for i, s := range c.services {
+	for i, s := range services {
 		req.Services[i] = service{
 			Name: s,
 			Cost: 0,
@@ -57,12 +60,23 @@ func (c *Client) createRequest() *AdRequest {
 	return req
 }
 
-func (c *Client) sendAdvertise() error {
+func (c *Client) sendAdvertise(services []string) error {
 	// Cannot advertise from an ephemeral peer.
 	if c.tchan.PeerInfo().IsEphemeralHostPort() {
 		return errEphemeralPeer
 	}
 
+	c.opts.Handler.On(SendAdvertise)
+	return c.callHyperbahn("ad", services)
+}
+
+// sendUnadvertise removes the advertisement of the services from Hyperbahn.
+func (c *Client) sendUnadvertise(services []string) error {
+	return c.callHyperbahn("unad", services)
+}
+
+// callHyperbahn calls the given advertisement method for the services.
+func (c *Client) callHyperbahn(method string, services []string) error {
 	retryOpts := &tchannel.RetryOptions{
 		RetryOn:           tchannel.RetryIdempotent,
 		TimeoutPerAttempt: c.opts.TimeoutPerAttempt,
@@ -76,6 +90,5 @@ func (c *Client) sendAdvertise() error {
 	defer cancel()
 
 	var resp AdResponse
-	c.opts.Handler.On(SendAdvertise)
-	return c.jsonClient.Call(ctx, "ad", c.createRequest(), &resp)
+	return c.jsonClient.Call(ctx, method, createRequest(services), &resp)
 }
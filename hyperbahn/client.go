@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/uber/tchannel-go"
@@ -35,10 +36,13 @@ import (
 
 // Client manages Hyperbahn connections and registrations.
 type Client struct {
-	tchan    *tchannel.Channel
-	services []string
-	opts     ClientOptions
-	quit     chan struct{}
+	tchan *tchannel.Channel
+	opts  ClientOptions
+	quit  chan struct{}
+
+	sync.Mutex
+	ads        []*advertisement
+	hooksAdded bool
 
 	jsonClient      *tjson.Client
 	hyperbahnClient htypes.TChanHyperbahn
@@ -83,6 +87,16 @@ type ClientOptions struct {
 	Handler           Handler
 	FailStrategy      FailStrategy
 
+	// OnAdvertiseFailed, if set, is called with the services that failed to
+	// be re-advertised each time a re-advertisement fails, after the
+	// Handler's OnError.
+	OnAdvertiseFailed func(services []string, err ErrAdvertiseFailed)
+
+	// UnadvertiseOnClose removes the advertisements of all services from
+	// Hyperbahn when the Client or its channel is closed, so that Hyperbahn
+	// stops routing calls to the channel before it stops serving them.
+	UnadvertiseOnClose bool
+
 	// The following are variables for stubbing in unit tests.
 	// They are not part of the stable API and may change.
 	TimeSleep func(d time.Duration)
@@ -160,30 +174,136 @@ func addPeer(ch *tchannel.Channel, hostPort string) {
 	peers.Add(hostPort)
 }
 
-func (c *Client) getServiceNames(otherServices []tchannel.Registrar) {
-	c.services = make([]string, 0, len(otherServices)+1)
-	c.services = append(c.services, c.tchan.PeerInfo().ServiceName)
+func (c *Client) getServiceNames(otherServices []tchannel.Registrar) []string {
+	services := make([]string, 0, len(otherServices)+1)
+	services = append(services, c.tchan.PeerInfo().ServiceName)
 
 	for _, s := range otherServices {
-		c.services = append(c.services, s.ServiceName())
+		services = append(services, s.ServiceName())
 	}
+	return services
+}
+
+// ServiceOptions are the options used to advertise a service using
+// AdvertiseService.
+type ServiceOptions struct {
+	// TTL is how long Hyperbahn keeps the advertisement. The service is
+	// re-advertised after 5/6 of the TTL, plus a random jitter of up to 1/3
+	// of the TTL so that services do not re-advertise together.
+	// Defaults to 60 seconds.
+	TTL time.Duration
 }
 
 // Advertise advertises the service with Hyperbahn, and returns any errors on initial advertisement.
 // Advertise can register multiple services hosted on the same endpoint.
 // If the advertisement succeeds, a goroutine is started to re-advertise periodically.
 func (c *Client) Advertise(otherServices ...tchannel.Registrar) error {
-	c.getServiceNames(otherServices)
+	return c.advertise(newAdvertisement(c.getServiceNames(otherServices), defaultAdvertiseTTL))
+}
+
+// AdvertiseService advertises a single service hosted on the client's
+// channel, which is re-advertised independently of other services using the
+// TTL in opts. It returns any errors on the initial advertisement, and can be
+// called for each service hosted by the channel, before or after Advertise.
+func (c *Client) AdvertiseService(serviceName string, opts *ServiceOptions) error {
+	var ttl time.Duration
+	if opts != nil {
+		ttl = opts.TTL
+	}
+	return c.advertise(newAdvertisement([]string{serviceName}, ttl))
+}
 
-	if err := c.initialAdvertise(); err != nil {
+func (c *Client) advertise(ad *advertisement) error {
+	if c.IsClosed() {
+		return errClientClosed
+	}
+	for _, service := range ad.services {
+		if c.isAdvertised(service) {
+			return fmt.Errorf("hyperbahn service %q is already advertised", service)
+		}
+	}
+
+	if err := c.initialAdvertise(ad.services); err != nil {
 		return err
 	}
 
+	c.Lock()
+	c.ads = append(c.ads, ad)
+	addHooks := !c.hooksAdded
+	c.hooksAdded = true
+	c.Unlock()
+
 	c.opts.Handler.On(Advertised)
-	go c.advertiseLoop()
+	if addHooks {
+		c.tchan.OnRebind(c.readvertise)
+		if c.opts.UnadvertiseOnClose {
+			c.tchan.OnClose(c.Close)
+		}
+	}
+	go c.advertiseLoop(ad)
 	return nil
 }
 
+// UnadvertiseService stops re-advertising the given service, and removes its
+// advertisement from Hyperbahn. Other services advertised with it using
+// Advertise continue to be advertised.
+func (c *Client) UnadvertiseService(serviceName string) error {
+	c.Lock()
+	found := false
+	for i, ad := range c.ads {
+		services := without(ad.services, serviceName)
+		if len(services) == len(ad.services) {
+			continue
+		}
+
+		// The re-advertisement loop reads the services without a lock, so the
+		// advertisement is replaced rather than modified.
+		found = true
+		ad.stop()
+		if len(services) == 0 {
+			c.ads = append(c.ads[:i:i], c.ads[i+1:]...)
+		} else {
+			c.ads[i] = newAdvertisement(services, ad.ttl)
+			go c.advertiseLoop(c.ads[i])
+		}
+		break
+	}
+	c.Unlock()
+
+	if !found {
+		return fmt.Errorf("hyperbahn service %q is not advertised", serviceName)
+	}
+	return c.sendUnadvertise([]string{serviceName})
+}
+
+// without returns the services other than the given service.
+func without(services []string, service string) []string {
+	filtered := make([]string, 0, len(services))
+	for _, s := range services {
+		if s != service {
+			filtered = append(filtered, s)
+		}
+	}
+	return filtered
+}
+
+func (c *Client) isAdvertised(service string) bool {
+	for _, ad := range c.advertisements() {
+		for _, s := range ad.services {
+			if s == service {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *Client) advertisements() []*advertisement {
+	c.Lock()
+	defer c.Unlock()
+	return append([]*advertisement(nil), c.ads...)
+}
+
 // IsClosed returns whether this Client is closed.
 func (c *Client) IsClosed() bool {
 	select {
@@ -195,8 +315,28 @@ func (c *Client) IsClosed() bool {
 }
 
 // Close closes the Hyperbahn client, which stops any background re-advertisements.
+// If UnadvertiseOnClose is set, the advertisements are also removed from Hyperbahn.
 func (c *Client) Close() {
-	if !c.IsClosed() {
-		close(c.quit)
+	c.Lock()
+	if c.IsClosed() {
+		c.Unlock()
+		return
+	}
+	close(c.quit)
+	ads := c.ads
+	c.ads = nil
+	c.Unlock()
+
+	var services []string
+	for _, ad := range ads {
+		ad.stop()
+		services = append(services, ad.services...)
+	}
+	if !c.opts.UnadvertiseOnClose || len(services) == 0 {
+		return
+	}
+	if err := c.sendUnadvertise(services); err != nil {
+		c.tchan.Logger().WithFields(tchannel.ErrField(err)).Warn(
+			"Hyperbahn client failed to unadvertise on close.")
 	}
 }
@@ -0,0 +1,341 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/uber/tchannel-go/trand"
+)
+
+const (
+	defaultIdempotencyTTL         = 10 * time.Minute
+	defaultIdempotencyMaxSize     = 64 * 1024
+	defaultIdempotencyStoreLength = 10000
+)
+
+var idempotencyKeyRng = trand.NewSeeded()
+
+// ErrDuplicateInProgress is returned for calls with an idempotency key while
+// a call with the same key is still being handled.
+var ErrDuplicateInProgress = NewSystemError(ErrCodeBusy, "call with the same idempotency key is in progress")
+
+// IdempotentResponse is the response to a call with an idempotency key, which
+// is sent again for calls that reuse the key.
+type IdempotentResponse struct {
+	ApplicationError bool
+	Arg2             []byte
+	Arg3             []byte
+}
+
+// IdempotencyStore stores the responses to calls with an idempotency key.
+// Implementations must be safe for concurrent use, and may share responses
+// between processes.
+type IdempotencyStore interface {
+	// Get returns the response stored for key, if it has not expired.
+	Get(key string) (*IdempotentResponse, bool)
+
+	// Put stores the response for key until ttl has passed.
+	Put(key string, res *IdempotentResponse, ttl time.Duration)
+}
+
+// IdempotencyOptions configures the deduplication of inbound calls that are
+// sent with an idempotency key, see CallOptions.IdempotencyKey. Retried calls
+// are sent with the same key, and are answered with the stored response of
+// the first call rather than being handled again.
+type IdempotencyOptions struct {
+	// Store stores the responses. If this is nil, responses are stored in
+	// memory using NewMemoryIdempotencyStore.
+	Store IdempotencyStore
+
+	// TTL is how long responses are stored. Defaults to 10 minutes.
+	TTL time.Duration
+
+	// MaxResponseSize is the largest response, in bytes, that is stored.
+	// Larger responses are not deduplicated. Defaults to 64KB.
+	MaxResponseSize int
+}
+
+// memoryIdempotencyStore is an in-memory IdempotencyStore that evicts the
+// oldest responses once it's full.
+type memoryIdempotencyStore struct {
+	sync.Mutex
+
+	maxEntries int
+	timeNow    func() time.Time
+	entries    map[string]*list.Element
+	order      *list.List
+}
+
+type memoryIdempotencyEntry struct {
+	key       string
+	res       *IdempotentResponse
+	expiresAt time.Time
+}
+
+// NewMemoryIdempotencyStore returns an IdempotencyStore that keeps up to
+// maxEntries responses in memory. If maxEntries is 0, 10000 responses are
+// kept.
+func NewMemoryIdempotencyStore(maxEntries int) IdempotencyStore {
+	if maxEntries <= 0 {
+		maxEntries = defaultIdempotencyStoreLength
+	}
+	return &memoryIdempotencyStore{
+		maxEntries: maxEntries,
+		timeNow:    time.Now,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+func (s *memoryIdempotencyStore) Get(key string) (*IdempotentResponse, bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	elem, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*memoryIdempotencyEntry)
+	if !s.timeNow().Before(entry.expiresAt) {
+		s.order.Remove(elem)
+		delete(s.entries, key)
+		return nil, false
+	}
+	return entry.res, true
+}
+
+func (s *memoryIdempotencyStore) Put(key string, res *IdempotentResponse, ttl time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+
+	if elem, ok := s.entries[key]; ok {
+		s.order.Remove(elem)
+	}
+	s.entries[key] = s.order.PushBack(&memoryIdempotencyEntry{
+		key:       key,
+		res:       res,
+		expiresAt: s.timeNow().Add(ttl),
+	})
+	for s.order.Len() > s.maxEntries {
+		oldest := s.order.Front()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryIdempotencyEntry).key)
+	}
+}
+
+// newIdempotencyKey returns a random key for a request that may be retried.
+func newIdempotencyKey() string {
+	return fmt.Sprintf("%016x%016x", idempotencyKeyRng.Int63(), idempotencyKeyRng.Int63())
+}
+
+// IdempotencyKey returns the idempotency key sent with every attempt of the
+// request, which is set if the request may be retried.
+func (rs *RequestState) IdempotencyKey() string {
+	if rs == nil {
+		return ""
+	}
+	return rs.idempotencyKey
+}
+
+// deduplicator answers inbound calls that reuse an idempotency key with the
+// stored response. A nil *deduplicator handles every call.
+type deduplicator struct {
+	opts    IdempotencyOptions
+	timeNow func() time.Time
+
+	sync.Mutex
+	// inProgress maps the keys of calls that are being handled to the call,
+	// which is removed once it completes or its exchange is shut down.
+	inProgress map[string]*inProgressCall
+}
+
+// inProgressCall is a call with an idempotency key that is being handled.
+type inProgressCall struct {
+	key string
+	// deadline is when the call times out, after which the key can be reused.
+	deadline time.Time
+}
+
+func newDeduplicator(opts *IdempotencyOptions, timeNow func() time.Time) *deduplicator {
+	if opts == nil {
+		return nil
+	}
+
+	d := &deduplicator{
+		opts:       *opts,
+		timeNow:    timeNow,
+		inProgress: make(map[string]*inProgressCall),
+	}
+	if d.opts.Store == nil {
+		d.opts.Store = NewMemoryIdempotencyStore(0)
+	}
+	if d.opts.TTL <= 0 {
+		d.opts.TTL = defaultIdempotencyTTL
+	}
+	if d.opts.MaxResponseSize <= 0 {
+		d.opts.MaxResponseSize = defaultIdempotencyMaxSize
+	}
+	return d
+}
+
+// storeKey scopes the caller's idempotency key to the caller and endpoint.
+func storeKey(call *InboundCall, key string) string {
+	return call.CallerName() + "\x00" + call.ServiceName() + "\x00" + call.MethodString() + "\x00" + key
+}
+
+// deduplicate returns whether an inbound call was answered as a duplicate
+// of a previous call. Otherwise, the call's response is recorded if it has an
+// idempotency key.
+func (c *Connection) deduplicate(call *InboundCall) bool {
+	d := c.dedup
+	key, ok := call.headers[IdempotencyKey]
+	if d == nil || !ok || key == "" {
+		return false
+	}
+	key = storeKey(call, key)
+
+	now := d.timeNow()
+	deadline, _ := call.mex.ctx.Deadline()
+	d.Lock()
+	if existing, ok := d.inProgress[key]; ok && now.Before(existing.deadline) {
+		d.Unlock()
+		c.deduplicated(call, "in-progress")
+		call.Response().SendSystemError(ErrDuplicateInProgress)
+		return true
+	}
+	inProgress := &inProgressCall{key: key, deadline: deadline}
+	d.inProgress[key] = inProgress
+	d.Unlock()
+
+	res, ok := d.opts.Store.Get(key)
+	if !ok {
+		call.response.recorder = &responseRecorder{d: d, call: inProgress}
+		return false
+	}
+
+	d.done(inProgress)
+	c.deduplicated(call, "replayed")
+	if err := call.replay(res); err != nil {
+		call.log.WithFields(ErrField(err)).Warn("Failed to send stored response to duplicate call.")
+	}
+	return true
+}
+
+func (c *Connection) deduplicated(call *InboundCall, result string) {
+	tags := cloneTags(call.commonStatsTags)
+	tags["result"] = result
+	c.statsReporter.IncCounter("inbound.calls.deduplicated", tags, 1)
+}
+
+// done marks the call as no longer in progress, unless a later call with the
+// same key has replaced it.
+func (d *deduplicator) done(call *inProgressCall) {
+	d.Lock()
+	if d.inProgress[call.key] == call {
+		delete(d.inProgress, call.key)
+	}
+	d.Unlock()
+}
+
+// replay reads the call's arguments, and sends the stored response.
+func (call *InboundCall) replay(res *IdempotentResponse) error {
+	var discard []byte
+	if err := NewArgReader(call.Arg2Reader()).Read(&discard); err != nil {
+		return err
+	}
+	if err := NewArgReader(call.Arg3Reader()).Read(&discard); err != nil {
+		return err
+	}
+
+	response := call.Response()
+	if res.ApplicationError {
+		if err := response.SetApplicationError(); err != nil {
+			return err
+		}
+	}
+	if err := NewArgWriter(response.Arg2Writer()).Write(res.Arg2); err != nil {
+		return err
+	}
+	return NewArgWriter(response.Arg3Writer()).Write(res.Arg3)
+}
+
+// responseRecorder records the arguments of a response so it can be stored.
+type responseRecorder struct {
+	d         *deduplicator
+	call      *inProgressCall
+	arg2      bytes.Buffer
+	arg3      bytes.Buffer
+	truncated bool
+}
+
+func (r *responseRecorder) wrap(w ArgWriter, buf *bytes.Buffer) ArgWriter {
+	return recordingArgWriter{ArgWriter: w, r: r, buf: buf}
+}
+
+// finish stores the recorded response, unless the call failed with a system
+// error. The response is stored even if it could not be sent, e.g. because the
+// call timed out, since the handler has completed and a retry should not
+// handle it again.
+func (r *responseRecorder) finish(response *InboundCallResponse) {
+	defer r.d.done(r.call)
+	if r.truncated || response.systemError {
+		return
+	}
+
+	r.d.opts.Store.Put(r.call.key, &IdempotentResponse{
+		ApplicationError: response.applicationError,
+		Arg2:             r.arg2.Bytes(),
+		Arg3:             r.arg3.Bytes(),
+	}, r.d.opts.TTL)
+}
+
+// abandon marks the call as no longer in progress without storing the
+// response, which is used when the call's exchange times out or fails before
+// the response is sent, e.g. because the connection closed.
+func (r *responseRecorder) abandon() {
+	if r == nil {
+		return
+	}
+	r.d.done(r.call)
+}
+
+type recordingArgWriter struct {
+	ArgWriter
+
+	r   *responseRecorder
+	buf *bytes.Buffer
+}
+
+func (w recordingArgWriter) Write(b []byte) (int, error) {
+	n, err := w.ArgWriter.Write(b)
+	if w.r.arg2.Len()+w.r.arg3.Len()+n > w.r.d.opts.MaxResponseSize {
+		w.r.truncated = true
+	}
+	if !w.r.truncated {
+		w.buf.Write(b[:n])
+	}
+	return n, err
+}
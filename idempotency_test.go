@@ -0,0 +1,311 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/atomic"
+)
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	store := NewMemoryIdempotencyStore(2)
+	res := func(s string) *IdempotentResponse {
+		return &IdempotentResponse{Arg3: []byte(s)}
+	}
+
+	_, ok := store.Get("k1")
+	assert.False(t, ok, "Get on empty store should fail")
+
+	store.Put("k1", res("r1"), time.Minute)
+	got, ok := store.Get("k1")
+	require.True(t, ok, "Get after Put failed")
+	assert.Equal(t, res("r1"), got, "Unexpected stored response")
+
+	store.Put("expired", res("r2"), 0)
+	_, ok = store.Get("expired")
+	assert.False(t, ok, "Expired responses should not be returned")
+
+	store.Put("k2", res("r2"), time.Minute)
+	store.Put("k3", res("r3"), time.Minute)
+	_, ok = store.Get("k1")
+	assert.False(t, ok, "Oldest response should be evicted once store is full")
+	for _, key := range []string{"k2", "k3"} {
+		_, ok := store.Get(key)
+		assert.True(t, ok, "Get(%v) failed", key)
+	}
+}
+
+func TestIdempotentCalls(t *testing.T) {
+	opts := testutils.NewOpts()
+	opts.Idempotency = &IdempotencyOptions{}
+
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		var handled atomic.Int32
+		testutils.RegisterFunc(ts.Server(), "inc", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+			n := handled.Inc()
+			if string(args.Arg3) == "fail" {
+				return nil, errors.New("handler failed")
+			}
+			return &raw.Res{
+				Arg2:  []byte("headers"),
+				Arg3:  []byte(fmt.Sprint(n)),
+				IsErr: string(args.Arg3) == "app-error",
+			}, nil
+		})
+
+		call := func(client *Channel, key, arg3 string) (*raw.CRes, error) {
+			ctx, cancel := NewContext(testutils.Timeout(time.Second))
+			defer cancel()
+			return raw.CallV2(ctx, client.GetSubChannel(ts.ServiceName()), raw.CArgs{
+				Method:      "inc",
+				Arg3:        []byte(arg3),
+				CallOptions: &CallOptions{IdempotencyKey: key},
+			})
+		}
+
+		client := ts.NewClient(nil)
+		client.Peers().Add(ts.HostPort())
+		otherClient := ts.NewClient(testutils.NewOpts().SetServiceName("other-client"))
+		otherClient.Peers().Add(ts.HostPort())
+
+		tests := []struct {
+			msg      string
+			client   *Channel
+			key      string
+			arg3     string
+			want     string
+			appError bool
+		}{
+			{msg: "first call", client: client, key: "k1", want: "1"},
+			{msg: "duplicate call", client: client, key: "k1", want: "1"},
+			{msg: "different key", client: client, key: "k2", want: "2"},
+			{msg: "different caller", client: otherClient, key: "k1", want: "3"},
+			{msg: "no key", client: client, want: "4"},
+			{msg: "no key is not deduplicated", client: client, want: "5"},
+			{msg: "app error", client: client, key: "k3", arg3: "app-error", want: "6", appError: true},
+			{msg: "duplicate app error", client: client, key: "k3", arg3: "app-error", want: "6", appError: true},
+		}
+
+		for _, tt := range tests {
+			res, err := call(tt.client, tt.key, tt.arg3)
+			require.NoError(t, err, "%v: call failed", tt.msg)
+			assert.Equal(t, "headers", string(res.Arg2), "%v: unexpected arg2", tt.msg)
+			assert.Equal(t, tt.want, string(res.Arg3), "%v: unexpected arg3", tt.msg)
+			assert.Equal(t, tt.appError, res.AppError, "%v: unexpected app error", tt.msg)
+		}
+
+		// System errors are not stored, so a retry with the same key is handled again.
+		for i := 0; i < 2; i++ {
+			_, err := call(client, "k4", "fail")
+			assert.Equal(t, ErrCodeUnexpected, GetSystemErrorCode(err), "Unexpected error")
+		}
+		assert.Equal(t, int32(8), handled.Load(), "Calls that failed should be handled again")
+	})
+}
+
+func TestIdempotentCallInProgress(t *testing.T) {
+	opts := testutils.NewOpts()
+	opts.Idempotency = &IdempotencyOptions{}
+
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		started := make(chan struct{})
+		unblock := make(chan struct{})
+		testutils.RegisterFunc(ts.Server(), "block", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+			close(started)
+			<-unblock
+			return &raw.Res{Arg3: []byte("done")}, nil
+		})
+
+		client := ts.NewClient(nil)
+		client.Peers().Add(ts.HostPort())
+		sc := client.GetSubChannel(ts.ServiceName())
+		call := func() (*raw.CRes, error) {
+			ctx, cancel := NewContext(testutils.Timeout(time.Second))
+			defer cancel()
+			return raw.CallV2(ctx, sc, raw.CArgs{
+				Method:      "block",
+				CallOptions: &CallOptions{IdempotencyKey: "k"},
+			})
+		}
+
+		errC := make(chan error, 1)
+		go func() {
+			_, err := call()
+			errC <- err
+		}()
+		select {
+		case <-started:
+		case err := <-errC:
+			close(unblock)
+			require.FailNow(t, "First call completed before the handler started", "err: %v", err)
+		case <-time.After(testutils.Timeout(time.Second)):
+			close(unblock)
+			require.FailNow(t, "Timed out waiting for the handler to start")
+		}
+
+		_, err := call()
+		assert.Equal(t, GetSystemErrorMessage(ErrDuplicateInProgress), GetSystemErrorMessage(err), "Unexpected error")
+		assert.Equal(t, ErrCodeBusy, GetSystemErrorCode(err), "Duplicate call should be busy")
+
+		close(unblock)
+		select {
+		case err := <-errC:
+			require.NoError(t, err, "First call failed")
+		case <-time.After(testutils.Timeout(time.Second)):
+			require.FailNow(t, "Timed out waiting for the first call")
+		}
+
+		res, err := call()
+		require.NoError(t, err, "Call after the first call completed failed")
+		assert.Equal(t, "done", string(res.Arg3), "Unexpected response")
+	})
+}
+
+func TestIdempotentCallConnectionClosed(t *testing.T) {
+	opts := testutils.NewOpts().NoRelay().DisableLogVerification()
+	opts.Idempotency = &IdempotencyOptions{}
+
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		var handled atomic.Int32
+		started := make(chan net.Conn, 1)
+		ts.Register(HandlerFunc(func(ctx context.Context, call *InboundCall) {
+			if handled.Inc() > 1 {
+				require.NoError(t, raw.WriteResponse(call.Response(), &raw.Res{Arg3: []byte("retried")}), "WriteResponse failed")
+				return
+			}
+
+			// Send part of the response, and block until the connection
+			// is closed.
+			_, netConn := InboundConnection(call)
+			writer, err := call.Response().Arg2Writer()
+			require.NoError(t, err, "Arg2Writer failed")
+			writer.Write([]byte("partial"))
+			require.NoError(t, writer.Flush(), "Flush failed")
+			started <- netConn
+			<-ctx.Done()
+		}), "partial")
+
+		call := func(client *Channel) (*raw.CRes, error) {
+			ctx, cancel := NewContext(testutils.Timeout(time.Second))
+			defer cancel()
+			return raw.CallV2(ctx, client.GetSubChannel(ts.ServiceName()), raw.CArgs{
+				Method:      "partial",
+				CallOptions: &CallOptions{IdempotencyKey: "k"},
+			})
+		}
+
+		client := ts.NewClient(testutils.NewOpts().DisableLogVerification())
+		client.Peers().Add(ts.HostPort())
+		errC := make(chan error, 1)
+		go func() {
+			_, err := call(client)
+			errC <- err
+		}()
+
+		select {
+		case netConn := <-started:
+			assert.Equal(t, 1, ts.Server().IdempotentCallsInProgress(), "Call should be in progress")
+			netConn.Close()
+		case err := <-errC:
+			require.FailNow(t, "Call completed before the handler started", "err: %v", err)
+		case <-time.After(testutils.Timeout(time.Second)):
+			require.FailNow(t, "Timed out waiting for the handler to start")
+		}
+
+		select {
+		case err := <-errC:
+			assert.Error(t, err, "Call should fail when the connection is closed")
+		case <-time.After(testutils.Timeout(time.Second)):
+			require.FailNow(t, "Timed out waiting for the call to fail")
+		}
+		assert.True(t, testutils.WaitFor(testutils.Timeout(time.Second), func() bool {
+			return ts.Server().IdempotentCallsInProgress() == 0
+		}), "Call should no longer be in progress once its connection is closed")
+
+		// The response was not stored, so a retry is handled again.
+		retryClient := ts.NewClient(nil)
+		retryClient.Peers().Add(ts.HostPort())
+		res, err := call(retryClient)
+		require.NoError(t, err, "Retry failed")
+		assert.Equal(t, "retried", string(res.Arg3), "Unexpected response")
+	})
+}
+
+func TestRetriedCallsIdempotencyKey(t *testing.T) {
+	testutils.WithTestServer(t, nil, func(ts *testutils.TestServer) {
+		var keys []string
+		testutils.RegisterFunc(ts.Server(), "key", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+			call := CurrentCall(ctx)
+			assert.Empty(t, call.CallOptions().IdempotencyKey, "Idempotency key should not be forwarded")
+			keys = append(keys, call.(*InboundCall).IdempotencyKey())
+			if len(keys)%2 == 1 {
+				return nil, errors.New("retry me")
+			}
+			return &raw.Res{}, nil
+		})
+
+		client := ts.NewClient(nil)
+		client.Peers().Add(ts.HostPort())
+		sc := client.GetSubChannel(ts.ServiceName())
+		ctx, cancel := NewContextBuilder(testutils.Timeout(time.Second)).
+			SetRetryOptions(&RetryOptions{RetryOn: RetryUnexpected}).
+			Build()
+		defer cancel()
+
+		callWithRetry := func() string {
+			var rsKey string
+			err := client.RunWithRetry(ctx, func(ctx context.Context, rs *RequestState) error {
+				rsKey = rs.IdempotencyKey()
+				call, err := sc.BeginCall(ctx, "key", &CallOptions{RequestState: rs})
+				if err != nil {
+					return err
+				}
+				_, _, _, err = raw.WriteArgs(call, nil, nil)
+				return err
+			})
+			require.NoError(t, err, "RunWithRetry failed")
+			return rsKey
+		}
+
+		firstKey := callWithRetry()
+		require.Len(t, keys, 2, "Expected call to be retried")
+		assert.NotEmpty(t, firstKey, "Retried calls should have an idempotency key")
+		assert.Equal(t, []string{firstKey, firstKey}, keys, "Attempts should use the same idempotency key")
+
+		secondKey := callWithRetry()
+		require.Len(t, keys, 4, "Expected call to be retried")
+		assert.NotEqual(t, firstKey, secondKey, "Separate calls should use separate idempotency keys")
+		assert.Equal(t, []string{secondKey, secondKey}, keys[2:], "Attempts should use the same idempotency key")
+	})
+}
@@ -21,13 +21,13 @@
 package tchannel
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"time"
 
 	"github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
-	"golang.org/x/net/context"
 )
 
 var errInboundRequestAlreadyActive = errors.New("inbound request is already active; possible duplicate client id")
@@ -47,6 +47,11 @@ func (c *Connection) handleCallReq(frame *Frame) bool {
 		panic(fmt.Errorf("unknown connection state for call req: %v", state))
 	}
 
+	if c.draining.Load() {
+		c.SendSystemError(frame.Header.ID, callReqSpan(frame), ErrChannelDraining)
+		return true
+	}
+
 	callReq := new(callReq)
 	callReq.id = frame.Header.ID
 	initialFragment, err := parseInboundFragment(c.opts.FramePool, frame, callReq)
@@ -56,12 +61,16 @@ func (c *Connection) handleCallReq(frame *Frame) bool {
 			LogField{"header", frame.Header},
 			ErrField(err),
 		).Error("Couldn't decode initial fragment.")
+		if err == errUnsupportedChecksumType {
+			c.statsReporter.IncCounter("checksum.unsupported", c.commonStatsTags, 1)
+			c.SendSystemError(frame.Header.ID, callReqSpan(frame), err)
+		}
 		return true
 	}
 
 	call := new(InboundCall)
 	call.conn = c
-	ctx, cancel := newIncomingContext(call, callReq.TimeToLive)
+	ctx, cancel := newIncomingContext(call, callReq.TimeToLive, baggageFromHeaders(callReq.Headers))
 
 	if !c.pendingExchangeMethodAdd() {
 		// Connection is closed, no need to do anything.
@@ -84,6 +93,7 @@ func (c *Connection) handleCallReq(frame *Frame) bool {
 		mex.shutdown()
 		return true
 	}
+	mex.cancel = cancel
 
 	response := new(InboundCallResponse)
 	response.call = call
@@ -107,6 +117,9 @@ func (c *Connection) handleCallReq(frame *Frame) bool {
 			if response.applicationError {
 				callRes.ResponseCode = responseApplicationError
 			}
+			if c.loadReporter != nil {
+				response.headers[Load] = formatLoad(c.loadReporter())
+			}
 			return callRes
 		}
 
@@ -121,13 +134,30 @@ func (c *Connection) handleCallReq(frame *Frame) bool {
 	call.log = c.log.WithFields(LogField{"In-Call", callReq.ID()})
 	call.messageForFragment = func(initial bool) message { return new(callReqContinue) }
 	call.contents = newFragmentingReader(call.log, call)
+	call.contents.onLimitExceeded = call.rejectArgs
+	c.verifyChecksums(call.contents, call.rejectChecksum)
 	call.statsReporter = c.statsReporter
 	call.createStatsTags(c.commonStatsTags)
 
 	response.statsReporter = c.statsReporter
 	response.commonStatsTags = call.commonStatsTags
 
+	accessLog := c.newAccessLogCall(AccessLogEntry{
+		Time:      now,
+		Direction: AccessLogInbound,
+		Service:   call.serviceName,
+	})
+	accessLog.received(frame)
+	call.accessLog = accessLog
+	response.accessLog = accessLog
+
 	setResponseHeaders(call.headers, response.headers)
+	call.compressor, call.compressorErr = c.compressors.forHeaders(call.headers)
+	if call.compressor != nil {
+		// Responses use the same compression as the call.
+		response.compressor = call.compressor
+		response.headers[Compression] = call.compressor.Name()
+	}
 	go c.dispatchInbound(c.connID, callReq.ID(), call, frame)
 	return false
 }
@@ -154,7 +184,7 @@ func (call *InboundCall) createStatsTags(connectionTags map[string]string) {
 }
 
 // dispatchInbound ispatches an inbound call to the appropriate handler
-func (c *Connection) dispatchInbound(_ uint32, _ uint32, call *InboundCall, frame *Frame) {
+func (c *Connection) dispatchInbound(_ uint32, _ uint32, call *InboundCall, _ *Frame) {
 	if call.log.Enabled(LogLevelDebug) {
 		call.log.Debugf("Received incoming call for %s from %s", call.ServiceName(), c.remotePeerInfo)
 	}
@@ -164,7 +194,9 @@ func (c *Connection) dispatchInbound(_ uint32, _ uint32, call *InboundCall, fram
 			LogField{"remotePeer", c.remotePeerInfo},
 			ErrField(err),
 		).Error("Couldn't read method.")
-		c.opts.FramePool.Release(frame)
+		// The frame is released with the fragment, which may already have
+		// been released if an error was sent to the caller.
+		call.releasePreviousFragment()
 		return
 	}
 
@@ -174,16 +206,39 @@ func (c *Connection) dispatchInbound(_ uint32, _ uint32, call *InboundCall, fram
 		span.SetOperationName(call.methodString)
 	}
 
+	// Arguments received with the method are only checked once the method's
+	// limits are known, and later fragments are checked as they're parsed.
+	if err := call.contents.setLimits(c.inboundArgSizeLimits(call)); err != nil {
+		call.rejectArgs(err)
+		return
+	}
+
+	if !c.authorize(call.mex.ctx, call) {
+		return
+	}
+
+	if c.deduplicate(call) {
+		return
+	}
+
+	if err := c.inboundQueue.acquire(call.mex.ctx, call.Priority()); err != nil {
+		call.shed(err)
+		return
+	}
+	defer c.inboundQueue.release()
+
+	ctx := c.handlerContext(call)
+
 	// TODO(prashant): This is an expensive way to check for cancellation. Use a heap for timeouts.
 	go func() {
 		select {
-		case <-call.mex.ctx.Done():
+		case <-ctx.Done():
 			// checking if message exchange timedout or was cancelled
 			// only two possible errors at this step:
 			// context.DeadlineExceeded
 			// context.Canceled
-			if call.mex.ctx.Err() != nil {
-				call.mex.inboundExpired()
+			if err := ctx.Err(); err != nil {
+				c.expireInbound(call, err)
 			}
 		case <-call.mex.errCh.c:
 			if c.log.Enabled(LogLevelDebug) {
@@ -195,9 +250,13 @@ func (c *Connection) dispatchInbound(_ uint32, _ uint32, call *InboundCall, fram
 			call.response.cancel()
 			call.mex.inboundExpired()
 		}
+		call.response.recorder.abandon()
 	}()
 
-	c.handler.Handle(call.mex.ctx, call)
+	if c.faults.injectCall(ctx, call) {
+		return
+	}
+	c.handler.Handle(ctx, call)
 }
 
 // An InboundCall is an incoming call from a peer
@@ -212,6 +271,10 @@ type InboundCall struct {
 	headers         transportHeaders
 	statsReporter   StatsReporter
 	commonStatsTags map[string]string
+
+	// compressor is used to decompress arg3, or nil if arg3 is not compressed.
+	compressor    Compressor
+	compressorErr error
 }
 
 // ServiceName returns the name of the service being called
@@ -249,6 +312,19 @@ func (call *InboundCall) RoutingKey() string {
 	return call.headers[RoutingKey]
 }
 
+// IdempotencyKey returns the idempotency key from the IdempotencyKey transport
+// header. It is not included in CallOptions, since calls made while handling
+// this call are separate requests that need their own keys.
+func (call *InboundCall) IdempotencyKey() string {
+	return call.headers[IdempotencyKey]
+}
+
+// Compression returns the compression used for arg3 from the Compression
+// transport header. Arguments are decompressed by Arg3Reader.
+func (call *InboundCall) Compression() string {
+	return call.headers[Compression]
+}
+
 // RoutingDelegate returns the routing delegate from the RoutingDelegate transport header.
 func (call *InboundCall) RoutingDelegate() string {
 	return call.headers[RoutingDelegate]
@@ -272,6 +348,8 @@ func (call *InboundCall) CallOptions() *CallOptions {
 		ShardKey:        call.ShardKey(),
 		RoutingDelegate: call.RoutingDelegate(),
 		RoutingKey:      call.RoutingKey(),
+		Compression:     call.Compression(),
+		Priority:        call.Priority(),
 	}
 }
 
@@ -296,7 +374,14 @@ func (call *InboundCall) Arg2Reader() (ArgReader, error) {
 // Arg3Reader returns an ArgReader to read the last argument.
 // The ReadCloser must be closed once the argument has been read.
 func (call *InboundCall) Arg3Reader() (ArgReader, error) {
-	return call.arg3Reader()
+	if call.compressorErr != nil {
+		return nil, call.compressorErr
+	}
+	r, err := call.arg3Reader()
+	if err != nil {
+		return nil, err
+	}
+	return newDecompressingReader(call.compressor, r)
 }
 
 // Response provides access to the InboundCallResponse object which can be used
@@ -329,6 +414,13 @@ type InboundCallResponse struct {
 	span             opentracing.Span
 	statsReporter    StatsReporter
 	commonStatsTags  map[string]string
+
+	// compressor is used to compress arg3, or nil if arg3 is not compressed.
+	compressor Compressor
+
+	// recorder records the response so it can be sent to duplicate calls,
+	// if the call has an idempotency key.
+	recorder *responseRecorder
 }
 
 // SendSystemError returns a system error response to the peer.  The call is considered
@@ -340,6 +432,7 @@ func (response *InboundCallResponse) SendSystemError(err error) error {
 	// Fail all future attempts to read fragments
 	response.state = reqResWriterComplete
 	response.systemError = true
+	response.accessLog.setResponseCode(accessLogResponseCode(err, false))
 	response.doneSending()
 	response.call.releasePreviousFragment()
 
@@ -367,13 +460,25 @@ func (response *InboundCallResponse) Arg2Writer() (ArgWriter, error) {
 	if err := NewArgWriter(response.arg1Writer()).Write(nil); err != nil {
 		return nil, err
 	}
-	return response.arg2Writer()
+	w, err := response.arg2Writer()
+	if err != nil || response.recorder == nil {
+		return w, err
+	}
+	return response.recorder.wrap(w, &response.recorder.arg2), nil
 }
 
 // Arg3Writer returns a WriteCloser that can be used to write the last argument.
 // The returned writer must be closed once the write is complete.
 func (response *InboundCallResponse) Arg3Writer() (ArgWriter, error) {
-	return response.arg3Writer()
+	w, err := response.arg3Writer()
+	if err != nil {
+		return nil, err
+	}
+	w = newCompressingWriter(response.compressor, w)
+	if response.recorder != nil {
+		w = response.recorder.wrap(w, &response.recorder.arg3)
+	}
+	return w, nil
 }
 
 // doneSending shuts down the message exchange for this call.
@@ -401,6 +506,19 @@ func (response *InboundCallResponse) doneSending() {
 		response.statsReporter.IncCounter("inbound.calls.success", response.commonStatsTags, 1)
 	}
 
+	if accessLog := response.accessLog; accessLog != nil {
+		accessLog.entry.Caller = response.call.CallerName()
+		accessLog.entry.Method = response.call.MethodString()
+		if !response.systemError {
+			accessLog.setResponseCode(accessLogResponseCode(nil, response.applicationError))
+		}
+		accessLog.finish(now)
+	}
+
+	if response.recorder != nil {
+		response.recorder.finish(response)
+	}
+
 	// Cancel the context since the response is complete.
 	response.cancel()
 
@@ -21,6 +21,7 @@
 package tchannel_test
 
 import (
+	"context"
 	"strings"
 	"testing"
 	"time"
@@ -32,7 +33,6 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"golang.org/x/net/context"
 )
 
 func TestActiveCallReq(t *testing.T) {
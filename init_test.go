@@ -242,6 +242,8 @@ func TestHandleInitReqNewVersion(t *testing.T) {
 					InitParamTChannelLanguage:        "go",
 					InitParamTChannelLanguageVersion: strings.TrimPrefix(runtime.Version(), "go"),
 					InitParamTChannelVersion:         VersionInfo,
+					InitParamCompression:             "gzip",
+					InitParamCallControl:             "cancel,claim",
 				},
 			},
 		}, msg, "unexpected init res")
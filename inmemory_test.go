@@ -0,0 +1,65 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tchannel_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryTransport(t *testing.T) {
+	opts := testutils.NewOpts().SetInMemory().AddLogFilter("Couldn't find handler.", 1)
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		testutils.RegisterEcho(ts.Server(), nil)
+		testutils.RegisterFunc(ts.Server(), "appError", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+			return &raw.Res{IsErr: true, Arg3: []byte("failed")}, nil
+		})
+		assert.True(t, strings.HasPrefix(ts.HostPort(), "memory:"), "Expected an in-memory address, got %v", ts.HostPort())
+
+		client := ts.NewClient(nil)
+		ctx, cancel := NewContext(testutils.Timeout(time.Second))
+		defer cancel()
+
+		// Large arguments are fragmented across multiple frames.
+		arg3 := bytes.Repeat([]byte("a"), 3*MaxFramePayloadSize)
+		arg2, resArg3, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "echo", []byte("headers"), arg3)
+		require.NoError(t, err, "Call failed")
+		assert.Equal(t, "headers", string(arg2), "Unexpected arg2")
+		assert.Equal(t, arg3, resArg3, "Unexpected arg3")
+
+		_, resArg3, res, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "appError", nil, nil)
+		require.NoError(t, err, "Call failed")
+		assert.True(t, res.ApplicationError(), "Expected application error")
+		assert.Equal(t, "failed", string(resArg3), "Unexpected application error arg3")
+
+		_, _, _, err = raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "unknown", nil, nil)
+		assert.Equal(t, ErrCodeBadRequest, GetSystemErrorCode(err), "Expected bad request for unknown method")
+	})
+}
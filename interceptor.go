@@ -0,0 +1,109 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tchannel
+
+import (
+	"context"
+
+	"github.com/uber-go/atomic"
+)
+
+// InboundInterceptor wraps the handling of every inbound call on a channel,
+// regardless of the encoding used by the call. The interceptor must call
+// next.Handle to continue handling the call, or respond to the call itself.
+type InboundInterceptor func(ctx context.Context, call *InboundCall, next Handler)
+
+// OutboundCallInfo describes an outbound call that is about to be started.
+type OutboundCallInfo struct {
+	// HostPort is the peer the call will be sent to.
+	HostPort string
+
+	// ServiceName is the name of the service being called.
+	ServiceName string
+
+	// Method is the name of the method being called.
+	Method string
+
+	// Options are the call options used for the call. They may be shared
+	// with other calls, so interceptors should replace them with a copy
+	// rather than modifying them.
+	Options *CallOptions
+}
+
+// BeginCallFunc starts an outbound call.
+type BeginCallFunc func(ctx context.Context, info *OutboundCallInfo) (*OutboundCall, error)
+
+// OutboundInterceptor wraps the start of every outbound call made by a
+// channel, regardless of the encoding used by the call. The interceptor must
+// call next to start the call, and can use OutboundCall.OnDone to observe
+// when the call completes.
+type OutboundInterceptor func(ctx context.Context, info *OutboundCallInfo, next BeginCallFunc) (*OutboundCall, error)
+
+// chainInboundInterceptors returns a handler that runs the interceptors in
+// order before calling h.
+func chainInboundInterceptors(h Handler, interceptors []InboundInterceptor) Handler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], h
+		h = HandlerFunc(func(ctx context.Context, call *InboundCall) {
+			interceptor(ctx, call, next)
+		})
+	}
+	return h
+}
+
+// chainOutboundInterceptors returns a BeginCallFunc that runs the
+// interceptors in order before calling f.
+func chainOutboundInterceptors(f BeginCallFunc, interceptors []OutboundInterceptor) BeginCallFunc {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], f
+		f = func(ctx context.Context, info *OutboundCallInfo) (*OutboundCall, error) {
+			return interceptor(ctx, info, next)
+		}
+	}
+	return f
+}
+
+// OnDone registers f to be called once the call completes, either when the
+// response has been read, or when writing the call or reading the response
+// fails. err is nil if the peer returned a response, which may be an
+// application error, see OutboundCallResponse.ApplicationError.
+func (call *OutboundCall) OnDone(f func(err error)) {
+	var called atomic.Bool
+	once := func(err error) {
+		if called.Swap(true) {
+			return
+		}
+		f(err)
+	}
+	call.onFailed = chainCallback(call.onFailed, once)
+	call.response.onFailed = chainCallback(call.response.onFailed, once)
+	call.response.onDone = chainCallback(call.response.onDone, once)
+}
+
+// chainCallback returns a callback that calls prev, if set, and then f.
+func chainCallback(prev, f func(error)) func(error) {
+	if prev == nil {
+		return f
+	}
+	return func(err error) {
+		prev(err)
+		f(err)
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tchannel_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/json"
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordedCalls is a concurrency-safe list of recorded call descriptions.
+type recordedCalls struct {
+	sync.Mutex
+	calls []string
+}
+
+func (r *recordedCalls) add(s string) {
+	r.Lock()
+	r.calls = append(r.calls, s)
+	r.Unlock()
+}
+
+func (r *recordedCalls) get() []string {
+	r.Lock()
+	defer r.Unlock()
+	return append([]string(nil), r.calls...)
+}
+
+func TestInboundInterceptors(t *testing.T) {
+	var recorded recordedCalls
+	recordInterceptor := func(name string) InboundInterceptor {
+		return func(ctx context.Context, call *InboundCall, next Handler) {
+			recorded.add(name + ":" + call.MethodString())
+			next.Handle(ctx, call)
+		}
+	}
+	deny := func(ctx context.Context, call *InboundCall, next Handler) {
+		if call.MethodString() == "denied" {
+			call.Response().SendSystemError(NewSystemError(ErrCodeDeclined, "denied"))
+			return
+		}
+		next.Handle(ctx, call)
+	}
+
+	opts := testutils.NewOpts()
+	opts.InboundInterceptors = []InboundInterceptor{recordInterceptor("first"), recordInterceptor("second"), deny}
+	server := testutils.NewServer(t, opts)
+	defer server.Close()
+	testutils.RegisterEcho(server, nil)
+	testutils.RegisterFunc(server, "denied", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		t.Error("denied handler should not be called")
+		return &raw.Res{}, nil
+	})
+	require.NoError(t, json.Register(server, json.Handlers{
+		"json": func(ctx json.Context, arg map[string]string) (map[string]string, error) {
+			return arg, nil
+		},
+	}, nil))
+
+	client := testutils.NewClient(t, nil)
+	defer client.Close()
+
+	ctx, cancel := NewContext(testutils.Timeout(time.Second))
+	defer cancel()
+
+	_, _, _, err := raw.Call(ctx, client, server.PeerInfo().HostPort, server.ServiceName(), "echo", nil, []byte("arg3"))
+	require.NoError(t, err, "echo call failed")
+
+	_, _, _, err = raw.Call(ctx, client, server.PeerInfo().HostPort, server.ServiceName(), "denied", nil, nil)
+	assert.Equal(t, ErrCodeDeclined, GetSystemErrorCode(err), "denied call should be rejected by the interceptor")
+
+	var res map[string]string
+	peer := client.Peers().GetOrAdd(server.PeerInfo().HostPort)
+	require.NoError(t, json.CallPeer(json.Wrap(ctx), peer, server.ServiceName(), "json", map[string]string{"k": "v"}, &res), "json call failed")
+	assert.Equal(t, map[string]string{"k": "v"}, res, "unexpected json response")
+
+	assert.Equal(t, []string{
+		"first:echo", "second:echo",
+		"first:denied", "second:denied",
+		"first:json", "second:json",
+	}, recorded.get(), "unexpected interceptor calls")
+}
+
+func TestInboundInterceptorsCustomHandler(t *testing.T) {
+	var intercepted recordedCalls
+	opts := testutils.NewOpts().NoRelay()
+	opts.Handler = raw.Wrap(newTestHandler(t))
+	opts.InboundInterceptors = []InboundInterceptor{
+		func(ctx context.Context, call *InboundCall, next Handler) {
+			intercepted.add(call.MethodString())
+			next.Handle(ctx, call)
+		},
+	}
+
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		ctx, cancel := NewContext(testutils.Timeout(time.Second))
+		defer cancel()
+
+		_, _, _, err := raw.Call(ctx, ts.NewClient(nil), ts.HostPort(), ts.ServiceName(), "echo", nil, []byte("arg3"))
+		require.NoError(t, err, "echo call failed")
+		assert.Equal(t, []string{"echo"}, intercepted.get(), "custom handler calls should be intercepted")
+	})
+}
+
+func TestOutboundInterceptors(t *testing.T) {
+	server := testutils.NewServer(t, nil)
+	defer server.Close()
+	testutils.RegisterEcho(server, nil)
+	testutils.RegisterFunc(server, "busy", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		return &raw.Res{SystemErr: ErrServerBusy}, nil
+	})
+
+	var recorded recordedCalls
+	errRejected := errors.New("rejected by interceptor")
+	opts := testutils.NewOpts()
+	opts.OutboundInterceptors = []OutboundInterceptor{
+		func(ctx context.Context, info *OutboundCallInfo, next BeginCallFunc) (*OutboundCall, error) {
+			recorded.add("first:" + info.ServiceName + "::" + info.Method)
+			call, err := next(ctx, info)
+			if err != nil {
+				return nil, err
+			}
+			call.OnDone(func(err error) {
+				recorded.add("done:" + info.Method + ":" + GetSystemErrorCode(err).String())
+			})
+			return call, nil
+		},
+		func(ctx context.Context, info *OutboundCallInfo, next BeginCallFunc) (*OutboundCall, error) {
+			assert.Equal(t, server.PeerInfo().HostPort, info.HostPort, "unexpected peer")
+			if info.Method == "rejected" {
+				return nil, errRejected
+			}
+			return next(ctx, info)
+		},
+	}
+	client := testutils.NewClient(t, opts)
+	defer client.Close()
+
+	ctx, cancel := NewContext(testutils.Timeout(time.Second))
+	defer cancel()
+
+	hostPort, svc := server.PeerInfo().HostPort, server.ServiceName()
+	_, _, _, err := raw.Call(ctx, client, hostPort, svc, "echo", nil, []byte("arg3"))
+	require.NoError(t, err, "echo call failed")
+
+	_, _, _, err = raw.Call(ctx, client, hostPort, svc, "busy", nil, nil)
+	assert.Equal(t, ErrServerBusy, err, "busy call should fail")
+
+	_, _, _, err = raw.Call(ctx, client, hostPort, svc, "rejected", nil, nil)
+	assert.Equal(t, errRejected, err, "rejected call should fail with the interceptor error")
+
+	assert.Equal(t, []string{
+		"first:" + svc + "::echo", "done:echo:" + ErrCodeInvalid.String(),
+		"first:" + svc + "::busy", "done:busy:" + ErrCodeBusy.String(),
+		"first:" + svc + "::rejected",
+	}, recorded.get(), "unexpected interceptor calls")
+}
@@ -21,13 +21,12 @@
 package tchannel
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"runtime"
-	"sort"
 	"strconv"
 	"time"
-
-	"golang.org/x/net/context"
 )
 
 // IntrospectionOptions are the options used when introspecting the Channel.
@@ -44,6 +43,17 @@ type IntrospectionOptions struct {
 	// IncludeOtherChannels will include basic information about other channels
 	// created in the same process as this channel.
 	IncludeOtherChannels bool `json:"includeOtherChannels"`
+
+	// IncludeSlowCalls will include the most recent slow outbound calls, if
+	// they are sampled.
+	IncludeSlowCalls bool `json:"includeSlowCalls"`
+
+	// IncludeCapture will include the calls captured by StartCapture.
+	IncludeCapture bool `json:"includeCapture"`
+
+	// IncludeRelayEdges will include the summary of each relay edge and the
+	// sampled traces, if the channel has RelayEdgeStats.
+	IncludeRelayEdges bool `json:"includeRelayEdges"`
 }
 
 // RuntimeVersion includes version information about the runtime and
@@ -63,6 +73,9 @@ type RuntimeState struct {
 	// LocalPeer is the local peer information (service name, host-port, etc).
 	LocalPeer LocalPeerInfo `json:"localPeer"`
 
+	// Listeners are the listeners serving inbound connections.
+	Listeners []ListenerInfo `json:"listeners,omitempty"`
+
 	// SubChannels contains information about any subchannels.
 	SubChannels map[string]SubChannelRuntimeState `json:"subChannels"`
 
@@ -83,6 +96,39 @@ type RuntimeState struct {
 
 	// RuntimeVersion is the version information about the runtime and the library.
 	RuntimeVersion RuntimeVersion `json:"runtimeVersion"`
+
+	// FramePool is the state of the frame pool used by the channel's connections.
+	FramePool FramePoolRuntimeState `json:"framePool"`
+
+	// SlowCalls are the most recent slow outbound calls, if they are sampled
+	// and IncludeSlowCalls is set.
+	SlowCalls []SlowCall `json:"slowCalls,omitempty"`
+
+	// Capture are the calls captured by StartCapture, if IncludeCapture is
+	// set.
+	Capture []CapturedCall `json:"capture,omitempty"`
+
+	// RelayEdges are the calls relayed from each caller to each service, if
+	// the channel has RelayEdgeStats and IncludeRelayEdges is set.
+	RelayEdges *RelayEdgesRuntimeState `json:"relayEdges,omitempty"`
+}
+
+// FramePoolRuntimeState is the runtime state of a frame pool.
+type FramePoolRuntimeState struct {
+	// Type is the type of frame pool.
+	Type string `json:"type"`
+
+	// Available is the number of frames available for reuse. It is only
+	// reported for pools created using NewChannelFramePool.
+	Available int `json:"available,omitempty"`
+
+	// Capacity is the maximum number of frames kept for reuse. It is only
+	// reported for pools created using NewChannelFramePool.
+	Capacity int `json:"capacity,omitempty"`
+
+	// Stats are the stats for each size class. They are only reported for
+	// pools created using NewAdaptiveFramePool.
+	Stats *FramePoolStats `json:"stats,omitempty"`
 }
 
 // GoRuntimeStateOptions are the options used when getting Go runtime state.
@@ -118,8 +164,9 @@ type SubChannelRuntimeState struct {
 
 // HandlerRuntimeState TODO
 type HandlerRuntimeState struct {
-	Type    handlerType `json:"type"`
-	Methods []string    `json:"methods,omitempty"`
+	Type     handlerType `json:"type"`
+	Methods  []string    `json:"methods,omitempty"`
+	Prefixes []string    `json:"prefixes,omitempty"`
 }
 
 type handlerType string
@@ -139,15 +186,28 @@ type SubPeerScore struct {
 
 // ConnectionRuntimeState is the runtime state for a single connection.
 type ConnectionRuntimeState struct {
-	ID               uint32                  `json:"id"`
-	ConnectionState  string                  `json:"connectionState"`
-	LocalHostPort    string                  `json:"localHostPort"`
-	RemoteHostPort   string                  `json:"remoteHostPort"`
-	OutboundHostPort string                  `json:"outboundHostPort"`
-	RemotePeer       PeerInfo                `json:"remotePeer"`
-	InboundExchange  ExchangeSetRuntimeState `json:"inboundExchange"`
-	OutboundExchange ExchangeSetRuntimeState `json:"outboundExchange"`
-	Relayer          RelayerRuntimeState     `json:"relayer"`
+	ID               uint32                      `json:"id"`
+	ConnectionState  string                      `json:"connectionState"`
+	LocalHostPort    string                      `json:"localHostPort"`
+	RemoteHostPort   string                      `json:"remoteHostPort"`
+	OutboundHostPort string                      `json:"outboundHostPort"`
+	RemotePeer       PeerInfo                    `json:"remotePeer"`
+	InboundExchange  ExchangeSetRuntimeState     `json:"inboundExchange"`
+	OutboundExchange ExchangeSetRuntimeState     `json:"outboundExchange"`
+	Relayer          RelayerRuntimeState         `json:"relayer"`
+	EffectiveOptions EffectiveConnectionOptions  `json:"effectiveOptions"`
+	Stats            ConnectionStatsRuntimeState `json:"stats"`
+}
+
+// ConnectionStatsRuntimeState is the traffic sent and received on a connection
+// since the init handshake completed.
+type ConnectionStatsRuntimeState struct {
+	FramesSent     uint64 `json:"framesSent"`
+	FramesRecvd    uint64 `json:"framesRecvd"`
+	BytesSent      uint64 `json:"bytesSent"`
+	BytesRecvd     uint64 `json:"bytesRecvd"`
+	SendBufferUsed int    `json:"sendBufferUsed"`
+	SendBufferSize int    `json:"sendBufferSize"`
 }
 
 // RelayerRuntimeState is the runtime state for a single relayer.
@@ -156,6 +216,9 @@ type RelayerRuntimeState struct {
 	InboundItems  RelayItemSetState `json:"inboundItems"`
 	OutboundItems RelayItemSetState `json:"outboundItems"`
 	MaxTimeout    time.Duration     `json:"maxTimeout"`
+	// AdaptiveTimeout is the adaptive timeout for calls relayed to the
+	// connection, if adaptive timeouts are enabled and there are enough samples.
+	AdaptiveTimeout time.Duration `json:"adaptiveTimeout,omitempty"`
 }
 
 // ExchangeSetRuntimeState is the runtime state for a message exchange set.
@@ -211,10 +274,26 @@ func (ch *Channel) IntrospectState(opts *IntrospectionOptions) *RuntimeState {
 
 	ch.mutable.RUnlock()
 
+	var slowCalls []SlowCall
+	if opts.IncludeSlowCalls {
+		slowCalls = ch.SlowCalls()
+	}
+
+	var capture []CapturedCall
+	if opts.IncludeCapture {
+		capture = ch.CapturedCalls()
+	}
+
+	var relayEdges *RelayEdgesRuntimeState
+	if opts.IncludeRelayEdges {
+		relayEdges = ch.relayEdgeStats.IntrospectState()
+	}
+
 	return &RuntimeState{
 		ID:             ch.chID,
 		CreatedStack:   ch.createdStack,
 		LocalPeer:      ch.PeerInfo(),
+		Listeners:      ch.Listeners(),
 		SubChannels:    ch.subChannels.IntrospectState(opts),
 		RootPeers:      ch.RootPeers().IntrospectState(opts),
 		Peers:          ch.Peers().IntrospectList(opts),
@@ -222,6 +301,10 @@ func (ch *Channel) IntrospectState(opts *IntrospectionOptions) *RuntimeState {
 		Connections:    connIDs,
 		OtherChannels:  ch.IntrospectOthers(opts),
 		RuntimeVersion: introspectRuntimeVersion(),
+		FramePool:      introspectFramePool(ch.connectionOptions.FramePool),
+		SlowCalls:      slowCalls,
+		Capture:        capture,
+		RelayEdges:     relayEdges,
 	}
 }
 
@@ -290,14 +373,9 @@ func (subChMap *subChannelMap) IntrospectState(opts *IntrospectionOptions) map[s
 		if state.Isolated {
 			state.IsolatedPeers = sc.Peers().IntrospectList(opts)
 		}
-		if hmap, ok := sc.handler.(*handlerMap); ok {
+		if hmap, ok := sc.getHandler().(*handlerMap); ok {
 			state.Handler.Type = methodHandler
-			methods := make([]string, 0, len(hmap.handlers))
-			for k := range hmap.handlers {
-				methods = append(methods, k)
-			}
-			sort.Strings(methods)
-			state.Handler.Methods = methods
+			state.Handler.Methods, state.Handler.Prefixes = hmap.methods()
 		} else {
 			state.Handler.Type = overrideHandler
 		}
@@ -345,6 +423,8 @@ func (c *Connection) IntrospectState(opts *IntrospectionOptions) ConnectionRunti
 		RemotePeer:       c.remotePeerInfo,
 		InboundExchange:  c.inbound.IntrospectState(opts),
 		OutboundExchange: c.outbound.IntrospectState(opts),
+		EffectiveOptions: c.EffectiveOptions(),
+		Stats:            c.introspectStats(),
 	}
 	if c.relay != nil {
 		state.Relayer = c.relay.IntrospectState(opts)
@@ -360,6 +440,8 @@ func (r *Relayer) IntrospectState(opts *IntrospectionOptions) RelayerRuntimeStat
 		InboundItems:  r.inbound.IntrospectState(opts, "inbound"),
 		OutboundItems: r.outbound.IntrospectState(opts, "outbound"),
 		MaxTimeout:    r.maxTimeout,
+
+		AdaptiveTimeout: r.latencies.adaptiveTimeout(),
 	}
 }
 
@@ -433,6 +515,29 @@ func (ch *Channel) handleIntrospection(arg3 []byte) interface{} {
 	return ch.IntrospectState(&opts)
 }
 
+func introspectFramePool(pool FramePool) FramePoolRuntimeState {
+	switch pool := pool.(type) {
+	case *syncFramePool:
+		return FramePoolRuntimeState{Type: "sync"}
+	case disabledFramePool:
+		return FramePoolRuntimeState{Type: "disabled"}
+	case channelFramePool:
+		return FramePoolRuntimeState{
+			Type:      "channel",
+			Available: len(pool),
+			Capacity:  cap(pool),
+		}
+	case *AdaptiveFramePool:
+		stats := pool.Stats()
+		return FramePoolRuntimeState{
+			Type:      "adaptive",
+			Available: stats.Small.Pooled + stats.Full.Pooled,
+			Stats:     &stats,
+		}
+	}
+	return FramePoolRuntimeState{Type: fmt.Sprintf("%T", pool)}
+}
+
 // IntrospectList returns the list of peers (hostport, score) in this peer list.
 func (l *PeerList) IntrospectList(opts *IntrospectionOptions) []SubPeerScore {
 	var peers []SubPeerScore
@@ -460,7 +565,11 @@ func (ch *Channel) IntrospectNumConnections() int {
 func handleInternalRuntime(arg3 []byte) interface{} {
 	var opts GoRuntimeStateOptions
 	json.Unmarshal(arg3, &opts)
+	return introspectGoRuntime(&opts)
+}
 
+// introspectGoRuntime returns the GoRuntimeState for the process.
+func introspectGoRuntime(opts *GoRuntimeStateOptions) GoRuntimeState {
 	state := GoRuntimeState{
 		NumGoroutines: runtime.NumGoroutine(),
 		NumCPU:        runtime.NumCPU(),
@@ -484,6 +593,7 @@ func introspectRuntimeVersion() RuntimeVersion {
 // registerInternal registers the following internal handlers which return runtime state:
 //  _gometa_introspect: TChannel internal state.
 //  _gometa_runtime: Golang runtime stats.
+//  _gometa_health: Application health, see SetHealthHandler.
 func (ch *Channel) registerInternal() {
 	endpoints := []struct {
 		name    string
@@ -513,4 +623,7 @@ func (ch *Channel) registerInternal() {
 		ch.Register(HandlerFunc(handler), ep.name)
 		tchanSC.Register(HandlerFunc(handler), ep.name)
 	}
+
+	ch.Register(&ch.health, healthMethod)
+	tchanSC.Register(&ch.health, healthMethod)
 }
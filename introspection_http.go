@@ -0,0 +1,132 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tchannel
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// IntrospectionHandler returns an HTTP handler that serves JSON snapshots of
+// the channel's runtime state, for debugging. Like other introspection APIs,
+// the output is not stable, and may slow down the channel.
+//
+// The handler serves the channel's RuntimeState on any path, except for paths
+// ending in "/runtime", which serve the GoRuntimeState, and paths ending in
+// "/capture", which serve the captured calls in the format read by
+// ReadCapture, and paths ending in "/relay-edges", which serve the
+// RelayEdgesRuntimeState and set the percentage of relayed calls that are
+// traced to the tracePercentage query parameter, if it's given. The
+// exchanges, emptyPeers, tombstones, otherChannels, slowCalls, capture and
+// relayEdges query parameters set the corresponding IntrospectionOptions,
+// e.g. ?exchanges=true, and stacks
+// includes all goroutine stacks in the GoRuntimeState. The service and peer
+// query parameters only include the subchannels and peers with the given
+// service names and host:ports, and can be repeated.
+func (ch *Channel) IntrospectionHandler() http.Handler {
+	return http.HandlerFunc(ch.serveIntrospection)
+}
+
+func (ch *Channel) serveIntrospection(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	boolParam := func(name string) bool {
+		v, _ := strconv.ParseBool(query.Get(name))
+		return v
+	}
+
+	if strings.HasSuffix(r.URL.Path, "/capture") {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if err := ch.WriteCapture(w); err != nil {
+			ch.log.WithFields(ErrField(err)).Info("Failed to write capture response.")
+		}
+		return
+	}
+
+	var state interface{}
+	if strings.HasSuffix(r.URL.Path, "/relay-edges") {
+		if ch.relayEdgeStats == nil {
+			http.Error(w, "channel does not have relay edge stats", http.StatusNotFound)
+			return
+		}
+		if v := query.Get("tracePercentage"); v != "" {
+			percentage, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				http.Error(w, "invalid tracePercentage: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			ch.relayEdgeStats.SetTracePercentage(percentage)
+		}
+		state = ch.relayEdgeStats.IntrospectState()
+	} else if strings.HasSuffix(r.URL.Path, "/runtime") {
+		state = introspectGoRuntime(&GoRuntimeStateOptions{
+			IncludeGoStacks: boolParam("stacks"),
+		})
+	} else {
+		rs := ch.IntrospectState(&IntrospectionOptions{
+			IncludeExchanges:     boolParam("exchanges"),
+			IncludeEmptyPeers:    boolParam("emptyPeers"),
+			IncludeTombstones:    boolParam("tombstones"),
+			IncludeOtherChannels: boolParam("otherChannels"),
+			IncludeSlowCalls:     boolParam("slowCalls"),
+			IncludeCapture:       boolParam("capture"),
+			IncludeRelayEdges:    boolParam("relayEdges"),
+		})
+		filterRuntimeState(rs, query["service"], query["peer"])
+		state = rs
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(state); err != nil {
+		ch.log.WithFields(ErrField(err)).Info("Failed to write introspection response.")
+	}
+}
+
+// filterRuntimeState removes subchannels and peers that do not match the given
+// services and host:ports. An empty filter includes everything.
+func filterRuntimeState(rs *RuntimeState, services, hostPorts []string) {
+	if len(services) > 0 {
+		allowed := toStringSet(services)
+		for service := range rs.SubChannels {
+			if _, ok := allowed[service]; !ok {
+				delete(rs.SubChannels, service)
+			}
+		}
+	}
+
+	if len(hostPorts) > 0 {
+		allowed := toStringSet(hostPorts)
+		for hostPort := range rs.RootPeers {
+			if _, ok := allowed[hostPort]; !ok {
+				delete(rs.RootPeers, hostPort)
+			}
+		}
+
+		peers := rs.Peers[:0]
+		for _, p := range rs.Peers {
+			if _, ok := allowed[p.HostPort]; ok {
+				peers = append(peers, p)
+			}
+		}
+		rs.Peers = peers
+	}
+}
@@ -21,12 +21,15 @@
 package tchannel_test
 
 import (
+	encjson "encoding/json"
+	"net/http/httptest"
 	"testing"
 	"time"
 
 	. "github.com/uber/tchannel-go"
 	"github.com/uber/tchannel-go/json"
 	"github.com/uber/tchannel-go/testutils"
+	"github.com/uber/tchannel-go/tos"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -98,3 +101,92 @@ func TestIntrospectNumConnections(t *testing.T) {
 		}), "Closed connection did not get removed, num connections is %v", ts.Server().IntrospectNumConnections())
 	})
 }
+
+func TestIntrospectEffectiveOptions(t *testing.T) {
+	opts := testutils.NewOpts().NoRelay()
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		ctx, cancel := NewContext(time.Second)
+		defer cancel()
+
+		tests := []struct {
+			msg  string
+			opts *testutils.ChannelOpts
+			want EffectiveConnectionOptions
+		}{
+			{
+				msg:  "defaults",
+				opts: testutils.NewOpts(),
+				want: EffectiveConnectionOptions{
+					SendBufferSize: 512,
+					ChecksumType:   ChecksumTypeCrc32,
+				},
+			},
+			{
+				msg: "overrides",
+				opts: testutils.NewOpts().
+					SetSendBufferSize(10).
+					SetTosPriority(tos.Lowdelay),
+				want: EffectiveConnectionOptions{
+					SendBufferSize: 10,
+					ChecksumType:   ChecksumTypeCrc32,
+					TosPriority:    tos.Lowdelay,
+				},
+			},
+		}
+
+		for _, tt := range tests {
+			client := ts.NewClient(tt.opts)
+			conn, err := client.Peers().GetOrAdd(ts.HostPort()).GetConnection(ctx)
+			require.NoError(t, err, "%v: GetConnection failed", tt.msg)
+			assert.Equal(t, tt.want, conn.EffectiveOptions(), "%v: unexpected effective options", tt.msg)
+
+			state := client.IntrospectState(nil)
+			peerState, ok := state.RootPeers[ts.HostPort()]
+			require.True(t, ok, "%v: missing peer in introspected state", tt.msg)
+			require.Len(t, peerState.OutboundConnections, 1, "%v: expected single connection", tt.msg)
+			assert.Equal(t, tt.want, peerState.OutboundConnections[0].EffectiveOptions,
+				"%v: unexpected introspected options", tt.msg)
+		}
+	})
+}
+
+func TestIntrospectionHandler(t *testing.T) {
+	opts := testutils.NewOpts().NoRelay().SetFramePool(NewChannelFramePool(10))
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		testutils.RegisterEcho(ts.Server(), nil)
+		other := ts.NewServer(testutils.NewOpts().SetServiceName("other"))
+		testutils.RegisterEcho(other, nil)
+
+		client := ts.NewClient(nil)
+		testutils.AssertEcho(t, client, ts.HostPort(), ts.ServiceName())
+		testutils.AssertEcho(t, client, other.PeerInfo().HostPort, other.ServiceName())
+
+		get := func(ch *Channel, url string) map[string]interface{} {
+			rec := httptest.NewRecorder()
+			ch.IntrospectionHandler().ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"), "unexpected content type")
+
+			var result map[string]interface{}
+			require.NoError(t, encjson.Unmarshal(rec.Body.Bytes(), &result), "failed to decode %v", url)
+			return result
+		}
+
+		state := get(client, "/")
+		assert.Len(t, state["rootPeers"], 2, "expected both peers")
+		assert.Equal(t, map[string]interface{}{"type": "sync"}, state["framePool"], "unexpected client frame pool")
+
+		filtered := get(client, "/?peer="+other.PeerInfo().HostPort+"&service=other")
+		require.Len(t, filtered["rootPeers"], 1, "expected only the filtered peer")
+		assert.Contains(t, filtered["rootPeers"], other.PeerInfo().HostPort, "missing filtered peer")
+		for service := range filtered["subChannels"].(map[string]interface{}) {
+			assert.Equal(t, "other", service, "unexpected subchannel")
+		}
+
+		runtimeState := get(client, "/debug/tchannel/runtime?stacks=true")
+		assert.NotEmpty(t, runtimeState["goStacks"], "expected goroutine stacks")
+
+		framePool := get(ts.Server(), "/")["framePool"].(map[string]interface{})
+		assert.Equal(t, "channel", framePool["type"], "unexpected server frame pool")
+		assert.EqualValues(t, 10, framePool["capacity"], "unexpected server frame pool capacity")
+	})
+}
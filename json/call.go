@@ -21,11 +21,10 @@
 package json
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/uber/tchannel-go"
-
-	"golang.org/x/net/context"
 )
 
 // ErrApplication is an application error which contains the object returned from the other side.
@@ -122,11 +121,13 @@ func (c *Client) Call(ctx Context, method string, arg, resp interface{}) error {
 		}
 
 		isOK, errAt, err = makeCall(call, headers, arg, &respHeaders, resp, &respErr)
+		if err == nil && !isOK {
+			return rs.RetryAppError(respHeaders, respErr)
+		}
 		return err
 	})
 	if err != nil {
-		// TODO: Don't lose the error type here.
-		return fmt.Errorf("%s: %v", errAt, err)
+		return wrapError(errAt, err)
 	}
 	if !isOK {
 		return respErr
@@ -141,7 +142,7 @@ func wrapCall(ctx Context, call *tchannel.OutboundCall, method string, arg, resp
 	var respErr ErrApplication
 	isOK, errAt, err := makeCall(call, ctx.Headers(), arg, &respHeaders, resp, &respErr)
 	if err != nil {
-		return fmt.Errorf("%s: %v", errAt, err)
+		return wrapError(errAt, err)
 	}
 	if !isOK {
 		return respErr
@@ -21,11 +21,10 @@
 package json
 
 import (
+	"context"
 	"time"
 
 	"github.com/uber/tchannel-go"
-
-	"golang.org/x/net/context"
 )
 
 // Context is a JSON Context which contains request and response headers.
@@ -0,0 +1,94 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build go1.18
+// +build go1.18
+
+package json
+
+import (
+	"context"
+
+	"github.com/uber/tchannel-go"
+)
+
+// Func is a JSON handler for a single method, with the request and response
+// types checked at compile time.
+type Func[Req, Res any] func(ctx Context, req *Req) (*Res, error)
+
+type typedHandler[Req, Res any] struct {
+	f         Func[Req, Res]
+	registrar tchannel.Registrar
+	onError   func(context.Context, error)
+}
+
+// RegisterFunc registers a typed JSON handler for the given method. Unlike
+// Register, it does not use reflection to discover the argument types, so a
+// handler with the wrong signature does not compile. Errors reading the
+// request or writing the response are passed to onError.
+func RegisterFunc[Req, Res any](registrar tchannel.Registrar, method string, f Func[Req, Res], onError func(context.Context, error)) {
+	registrar.Register(&typedHandler[Req, Res]{
+		f:         f,
+		registrar: registrar,
+		onError:   onError,
+	}, method)
+}
+
+func (h *typedHandler[Req, Res]) Handle(tctx context.Context, call *tchannel.InboundCall) {
+	if err := h.handle(tctx, call); err != nil {
+		h.onError(tctx, err)
+	}
+}
+
+func (h *typedHandler[Req, Res]) handle(tctx context.Context, call *tchannel.InboundCall) error {
+	ctx, err := readHeaders(tctx, call, tchannel.TracerFromRegistrar(h.registrar))
+	if err != nil {
+		return err
+	}
+
+	req := new(Req)
+	if err := tchannel.NewArgReader(call.Arg3Reader()).ReadJSON(req); err != nil {
+		return wrapError("arg3 read failed", err)
+	}
+
+	res, err := h.f(ctx, req)
+	return writeResponse(ctx, call, res, err)
+}
+
+// Call makes a JSON call to the given method using the peer, and returns the
+// typed response. Application errors are returned as ErrApplication.
+func Call[Req, Res any](ctx Context, peer *tchannel.Peer, serviceName, method string, req *Req) (*Res, error) {
+	res := new(Res)
+	if err := CallPeer(ctx, peer, serviceName, method, req, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// CallSubChannel makes a JSON call to the given method using the subchannel,
+// and returns the typed response. Application errors are returned as
+// ErrApplication.
+func CallSubChannel[Req, Res any](ctx Context, sc *tchannel.SubChannel, method string, req *Req) (*Res, error) {
+	res := new(Res)
+	if err := CallSC(ctx, sc, method, req, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
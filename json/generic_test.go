@@ -0,0 +1,111 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build go1.18
+// +build go1.18
+
+package json
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type echoArgs struct {
+	Message string
+	Fail    bool
+}
+
+func TestGenericRegisterCall(t *testing.T) {
+	ctx, cancel := NewContext(time.Second)
+	defer cancel()
+
+	server := testutils.NewServer(t, nil)
+	defer server.Close()
+
+	onError := func(ctx context.Context, err error) {
+		t.Errorf("onError: %v", err)
+	}
+	RegisterFunc(server, "echo", func(ctx Context, args *echoArgs) (*Res, error) {
+		if args.Fail {
+			return nil, errors.New("echo failed")
+		}
+		ctx.SetResponseHeaders(map[string]string{"hdr": ctx.Headers()["hdr"] + "-resp"})
+		return &Res{Result: args.Message}, nil
+	}, onError)
+	RegisterFunc(server, "busy", func(ctx Context, args *struct{}) (*struct{}, error) {
+		return nil, tchannel.ErrServerBusy
+	}, onError)
+
+	client := testutils.NewClient(t, nil)
+	defer client.Close()
+	peer := client.Peers().Add(server.PeerInfo().HostPort)
+
+	hctx := WithHeaders(ctx, map[string]string{"hdr": "val"})
+	res, err := Call[echoArgs, Res](hctx, peer, server.ServiceName(), "echo", &echoArgs{Message: "hello"})
+	require.NoError(t, err, "Call failed")
+	assert.Equal(t, &Res{Result: "hello"}, res, "Unexpected response")
+	assert.Equal(t, map[string]string{"hdr": "val-resp"}, hctx.ResponseHeaders(), "Unexpected response headers")
+
+	sc := client.GetSubChannel(server.ServiceName())
+	sc.Peers().Add(server.PeerInfo().HostPort)
+	res, err = CallSubChannel[echoArgs, Res](ctx, sc, "echo", &echoArgs{Message: "subchannel"})
+	require.NoError(t, err, "CallSubChannel failed")
+	assert.Equal(t, &Res{Result: "subchannel"}, res, "Unexpected response")
+
+	_, err = Call[echoArgs, Res](ctx, peer, server.ServiceName(), "echo", &echoArgs{Fail: true})
+	assert.Equal(t, ErrApplication{"type": "error", "message": "echo failed"}, err, "Expected application error")
+
+	_, err = Call[struct{}, struct{}](ctx, peer, server.ServiceName(), "busy", &struct{}{})
+	require.Error(t, err, "Expected system error")
+	assert.Contains(t, err.Error(), "server busy", "Unexpected error")
+}
+
+func TestGenericRegisterMap(t *testing.T) {
+	ctx, cancel := NewContext(time.Second)
+	defer cancel()
+
+	server := testutils.NewServer(t, nil)
+	defer server.Close()
+
+	type argsMap map[string]interface{}
+	RegisterFunc(server, "handle", func(ctx Context, args *argsMap) (*argsMap, error) {
+		return args, nil
+	}, func(ctx context.Context, err error) {
+		t.Errorf("onError: %v", err)
+	})
+
+	client := testutils.NewClient(t, nil)
+	defer client.Close()
+	peer := client.Peers().Add(server.PeerInfo().HostPort)
+
+	arg := argsMap{"v1": "value1", "v2": 2.0}
+	res, err := Call[argsMap, argsMap](ctx, peer, server.ServiceName(), "handle", &arg)
+	require.NoError(t, err, "Call failed")
+	assert.Equal(t, arg, *res, "Unexpected response")
+}
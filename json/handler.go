@@ -21,13 +21,13 @@
 package json
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 
 	"github.com/uber/tchannel-go"
 
 	"github.com/opentracing/opentracing-go"
-	"golang.org/x/net/context"
 )
 
 var (
@@ -125,12 +125,10 @@ func Register(registrar tchannel.Registrar, funcs Handlers, onError func(context
 
 // Handle deserializes the JSON arguments and calls the underlying handler.
 func (h *handler) Handle(tctx context.Context, call *tchannel.InboundCall) error {
-	var headers map[string]string
-	if err := tchannel.NewArgReader(call.Arg2Reader()).ReadJSON(&headers); err != nil {
-		return fmt.Errorf("arg2 read failed: %v", err)
+	ctx, err := readHeaders(tctx, call, h.tracer())
+	if err != nil {
+		return err
 	}
-	tctx = tchannel.ExtractInboundSpan(tctx, call, headers, h.tracer())
-	ctx := WithHeaders(tctx, headers)
 
 	var arg3 reflect.Value
 	var callArg reflect.Value
@@ -143,14 +141,32 @@ func (h *handler) Handle(tctx context.Context, call *tchannel.InboundCall) error
 		callArg = arg3
 	}
 	if err := tchannel.NewArgReader(call.Arg3Reader()).ReadJSON(arg3.Interface()); err != nil {
-		return fmt.Errorf("arg3 read failed: %v", err)
+		return wrapError("arg3 read failed", err)
 	}
 
 	args := []reflect.Value{reflect.ValueOf(ctx), callArg}
 	results := h.handler.Call(args)
 
-	res := results[0].Interface()
-	err := results[1].Interface()
+	var resErr error
+	if err := results[1].Interface(); err != nil {
+		resErr = err.(error)
+	}
+	return writeResponse(ctx, call, results[0].Interface(), resErr)
+}
+
+// readHeaders reads the request headers from arg2, and returns the Context
+// to pass to the handler.
+func readHeaders(tctx context.Context, call *tchannel.InboundCall, tracer opentracing.Tracer) (Context, error) {
+	var headers map[string]string
+	if err := tchannel.NewArgReader(call.Arg2Reader()).ReadJSON(&headers); err != nil {
+		return nil, wrapError("arg2 read failed", err)
+	}
+	tctx = tchannel.ExtractInboundSpan(tctx, call, headers, tracer)
+	return WithHeaders(tctx, headers), nil
+}
+
+// writeResponse writes the handler's response, or the error it returned.
+func writeResponse(ctx Context, call *tchannel.InboundCall, res interface{}, err error) error {
 	// If an error was returned, we create an error arg3 to respond with.
 	if err != nil {
 		// TODO(prashantv): More consistent error handling between json/raw/thrift..
@@ -165,7 +181,7 @@ func (h *handler) Handle(tctx context.Context, call *tchannel.InboundCall) error
 			Message string `json:"message"`
 		}{
 			Type:    "error",
-			Message: err.(error).Error(),
+			Message: err.Error(),
 		}
 	}
 
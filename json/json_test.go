@@ -21,6 +21,7 @@
 package json
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -29,7 +30,6 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"golang.org/x/net/context"
 )
 
 // ForwardArgs are the arguments specifying who to forward to (and the message to forward).
@@ -274,3 +274,9 @@ func TestMapInputOutput(t *testing.T) {
 	require.NoError(t, tchannel.NewArgReader(resp.Arg3Reader()).ReadJSON(&data))
 	assert.Equal(t, arg, data.(map[string]interface{}), "result does not match arg")
 }
+
+func TestWrappedErrorKeepsSystemError(t *testing.T) {
+	err := wrapError("connect", tchannel.ErrServerBusy)
+	assert.Equal(t, "connect: "+tchannel.ErrServerBusy.Error(), err.Error(), "Unexpected error message")
+	assert.Equal(t, tchannel.ErrCodeBusy, tchannel.GetSystemErrorCode(err), "Wrapped error should keep the SystemError code")
+}
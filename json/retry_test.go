@@ -21,6 +21,7 @@
 package json
 
 import (
+	"errors"
 	"strings"
 	"testing"
 	"time"
@@ -70,3 +71,33 @@ func TestRetryJSONNoConnect(t *testing.T) {
 	require.Error(t, err, "Call should fail")
 	assert.True(t, strings.HasPrefix(err.Error(), "connect: "), "Error does not contain expected prefix: %v", err.Error())
 }
+
+func TestRetryJSONAppError(t *testing.T) {
+	ch := testutils.NewServer(t, nil)
+	ch.Peers().Add(ch.PeerInfo().HostPort)
+
+	count := 0
+	handler := func(ctx Context, req map[string]string) (map[string]string, error) {
+		count++
+		if count > 2 {
+			return req, nil
+		}
+		return nil, errors.New("try again")
+	}
+	Register(ch, Handlers{"test": handler}, nil)
+
+	retryOpts := &tchannel.RetryOptions{
+		ClassifyAppError: func(headers map[string]string, appErr interface{}) bool {
+			return appErr.(ErrApplication)["message"] == "try again"
+		},
+	}
+	ctx, cancel := tchannel.NewContextBuilder(time.Second).SetRetryOptions(retryOpts).Build()
+	defer cancel()
+
+	client := NewClient(ch, ch.ServiceName(), nil)
+
+	var res map[string]string
+	err := client.Call(Wrap(ctx), "test", nil, &res)
+	assert.NoError(t, err, "Call should succeed")
+	assert.Equal(t, 3, count, "Handler should have been invoked 3 times")
+}
@@ -1,13 +1,13 @@
 package json_test
 
 import (
+	"context"
 	"testing"
 
 	"github.com/uber/tchannel-go"
 	"github.com/uber/tchannel-go/json"
 
 	. "github.com/uber/tchannel-go/testutils/testtracing"
-	"golang.org/x/net/context"
 )
 
 // JSONHandler tests tracing over JSON encoding
@@ -31,6 +31,7 @@ import (
 	"github.com/uber/tchannel-go/raw"
 	"github.com/uber/tchannel-go/testutils"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -68,3 +69,83 @@ func TestLargeRequest(t *testing.T) {
 		}
 	})
 }
+
+func TestLargeFrames(t *testing.T) {
+	const KB = 1024
+
+	tests := []struct {
+		msg           string
+		serverMaxSize int
+		clientMaxSize int
+		wantFrames    uint64
+	}{
+		{
+			msg:           "both support 1MB frames",
+			serverMaxSize: 1024 * KB,
+			clientMaxSize: 1024 * KB,
+			wantFrames:    1,
+		},
+		{
+			msg:           "smaller advertised size is used",
+			serverMaxSize: 256 * KB,
+			clientMaxSize: 1024 * KB,
+			wantFrames:    3,
+		},
+		{
+			msg:           "server uses standard frames",
+			clientMaxSize: 1024 * KB,
+			wantFrames:    10,
+		},
+		{
+			msg:           "client uses standard frames",
+			serverMaxSize: 1024 * KB,
+			wantFrames:    10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			opts := testutils.NewOpts().NoRelay()
+			opts.MaxFrameSize = tt.serverMaxSize
+			testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+				testutils.RegisterEcho(ts.Server(), nil)
+				client := ts.NewClient(&testutils.ChannelOpts{
+					ChannelOptions: ChannelOptions{MaxFrameSize: tt.clientMaxSize},
+				})
+
+				arg2 := testutils.RandBytes(64 * KB)
+				arg3 := testutils.RandBytes(512 * KB)
+				ctx, cancel := NewContext(testutils.Timeout(time.Second))
+				defer cancel()
+
+				rArg2, rArg3, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "echo", arg2, arg3)
+				require.NoError(t, err, "Call failed")
+				assert.Equal(t, arg2, rArg2, "echo arg2 mismatch")
+				assert.Equal(t, arg3, rArg3, "echo arg3 mismatch")
+
+				stats := outboundConnStats(t, client, ts.HostPort())
+				assert.Equal(t, tt.wantFrames, stats.FramesSent, "Unexpected frames sent")
+				assert.Equal(t, tt.wantFrames, stats.FramesRecvd, "Unexpected frames received")
+			})
+		})
+	}
+}
+
+func TestLargeFramesRelayed(t *testing.T) {
+	opts := testutils.NewOpts()
+	opts.MaxFrameSize = 1024 * 1024
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		testutils.RegisterEcho(ts.Server(), nil)
+		client := ts.NewClient(&testutils.ChannelOpts{
+			ChannelOptions: ChannelOptions{MaxFrameSize: 1024 * 1024},
+		})
+
+		arg3 := testutils.RandBytes(512 * 1024)
+		ctx, cancel := NewContext(testutils.Timeout(time.Second))
+		defer cancel()
+
+		_, rArg3, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "echo", nil, arg3)
+		require.NoError(t, err, "Call failed")
+		assert.Equal(t, arg3, rArg3, "echo arg3 mismatch")
+	})
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"net"
+
+	"github.com/uber/tchannel-go/tnet"
+)
+
+// ListenerOptions configures a listener added using AddListener.
+type ListenerOptions struct {
+	// AdvertisedHostPort is the host:port sent to peers that connect using
+	// the listener. Defaults to the listener's address.
+	AdvertisedHostPort string
+}
+
+// ListenerInfo describes a listener serving inbound connections.
+type ListenerInfo struct {
+	// Addr is the address the listener is bound to.
+	Addr string `json:"addr"`
+
+	// HostPort is the host:port sent to peers that connect using the
+	// listener.
+	HostPort string `json:"hostPort"`
+
+	// Primary is set for the listener passed to Serve or Rebind, whose
+	// host:port is the channel's PeerInfo.
+	Primary bool `json:"primary,omitempty"`
+}
+
+// extraListener is a listener added using AddListener.
+type extraListener struct {
+	net.Listener
+	hostPort string
+}
+
+// AddListener serves incoming requests using l, in addition to the channel's
+// listener and any other added listeners, such as to listen on both an
+// internal and an external interface. The channel must already be listening.
+//
+// Peers that connect using l are sent its advertised host:port, and it is
+// used as the LocalPeerInfo of their connections, while the channel's PeerInfo
+// is unchanged. Added listeners are closed when the channel is closed, and
+// are not replaced by Rebind.
+func (ch *Channel) AddListener(l net.Listener, opts *ListenerOptions) error {
+	if opts == nil {
+		opts = &ListenerOptions{}
+	}
+	hostPort := opts.AdvertisedHostPort
+	if hostPort == "" {
+		hostPort = addrHostPort(l.Addr())
+	}
+
+	mutable := &ch.mutable
+	mutable.Lock()
+	if mutable.state != ChannelListening {
+		mutable.Unlock()
+		return errInvalidStateForOp
+	}
+	listener := &extraListener{tnet.Wrap(l), hostPort}
+	mutable.extraListeners = append(mutable.extraListeners, listener)
+	mutable.Unlock()
+
+	ch.log.WithFields(
+		LogField{"listenerAddr", l.Addr().String()},
+		LogField{"listenerHostPort", hostPort},
+	).Info("Channel is listening on an additional address.")
+	go ch.serve(listener, hostPort)
+	return nil
+}
+
+// AddListenAddr listens on the given address, and serves incoming requests
+// using AddListener. The port may be 0 to use an OS assigned port.
+func (ch *Channel) AddListenAddr(hostPort string, opts *ListenerOptions) error {
+	l, err := net.Listen(networkAddress(hostPort))
+	if err != nil {
+		return err
+	}
+
+	if err := ch.AddListener(l, opts); err != nil {
+		l.Close()
+		return err
+	}
+	return nil
+}
+
+// Listeners returns the listeners serving inbound connections for the
+// channel, starting with the primary listener.
+func (ch *Channel) Listeners() []ListenerInfo {
+	ch.mutable.RLock()
+	defer ch.mutable.RUnlock()
+
+	var listeners []ListenerInfo
+	if l := ch.mutable.l; l != nil {
+		listeners = append(listeners, ListenerInfo{
+			Addr:     l.Addr().String(),
+			HostPort: ch.mutable.peerInfo.HostPort,
+			Primary:  true,
+		})
+	}
+	for _, l := range ch.mutable.extraListeners {
+		listeners = append(listeners, ListenerInfo{
+			Addr:     l.Addr().String(),
+			HostPort: l.hostPort,
+		})
+	}
+	return listeners
+}
+
+// closeListenersLocked closes all of the channel's listeners. The channel's
+// mutable lock must be held.
+func (ch *Channel) closeListenersLocked() {
+	if ch.mutable.l != nil {
+		ch.mutable.l.Close()
+	}
+	for _, l := range ch.mutable.extraListeners {
+		l.Close()
+	}
+}
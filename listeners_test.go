@@ -0,0 +1,101 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddListener(t *testing.T) {
+	server := testutils.NewServer(t, nil)
+	defer server.Close()
+	testutils.RegisterFunc(server, "local", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		return &raw.Res{Arg3: []byte(CurrentCall(ctx).LocalPeer().HostPort)}, nil
+	})
+
+	extra, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Listen failed")
+	const advertised = "10.0.0.1:4040"
+	require.NoError(t, server.AddListener(extra, &ListenerOptions{AdvertisedHostPort: advertised}), "AddListener failed")
+
+	other, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Listen failed")
+	require.NoError(t, server.AddListener(other, nil), "AddListener failed")
+
+	assert.Equal(t, []ListenerInfo{
+		{Addr: server.PeerInfo().HostPort, HostPort: server.PeerInfo().HostPort, Primary: true},
+		{Addr: extra.Addr().String(), HostPort: advertised},
+		{Addr: other.Addr().String(), HostPort: other.Addr().String()},
+	}, server.Listeners(), "Unexpected listeners")
+	assert.Len(t, server.IntrospectState(nil).Listeners, 3, "Introspection should list all listeners")
+
+	client := testutils.NewClient(t, nil)
+	defer client.Close()
+
+	tests := []struct {
+		hostPort string
+		want     string
+	}{
+		{server.PeerInfo().HostPort, server.PeerInfo().HostPort},
+		{extra.Addr().String(), advertised},
+		{other.Addr().String(), other.Addr().String()},
+	}
+	for _, tt := range tests {
+		ctx, cancel := NewContext(testutils.Timeout(time.Second))
+		_, arg3, _, err := raw.Call(ctx, client, tt.hostPort, server.ServiceName(), "local", nil, nil)
+		cancel()
+		require.NoError(t, err, "Call to %v failed", tt.hostPort)
+		assert.Equal(t, tt.want, string(arg3), "Unexpected local host:port for calls to %v", tt.hostPort)
+
+		conn, err := client.RootPeers().GetOrAdd(tt.hostPort).GetConnection(context.Background())
+		require.NoError(t, err, "GetConnection failed")
+		assert.Equal(t, tt.want, conn.RemotePeerInfo().HostPort, "Unexpected remote host:port for %v", tt.hostPort)
+	}
+	assert.Equal(t, tests[0].want, server.PeerInfo().HostPort, "Channel's PeerInfo should not change")
+
+	// Added listeners are kept when the channel is rebound, and closed when
+	// the channel is closed.
+	require.NoError(t, server.RebindAndServe("127.0.0.1:0"), "RebindAndServe failed")
+	assert.Len(t, server.Listeners(), 3, "Rebind should only replace the primary listener")
+	require.NoError(t, client.Ping(context.Background(), tests[1].hostPort), "Ping after Rebind failed")
+
+	server.Close()
+	_, err = net.Dial("tcp", extra.Addr().String())
+	assert.Error(t, err, "Added listener should be closed")
+}
+
+func TestAddListenerNotListening(t *testing.T) {
+	ch := testutils.NewClient(t, nil)
+	defer ch.Close()
+
+	assert.Error(t, ch.AddListenAddr("127.0.0.1:0", nil), "AddListener should fail when not listening")
+	assert.Empty(t, ch.Listeners(), "Client should not have listeners")
+}
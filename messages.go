@@ -36,6 +36,8 @@ const (
 	messageTypeCallRes         messageType = 0x04
 	messageTypeCallReqContinue messageType = 0x13
 	messageTypeCallResContinue messageType = 0x14
+	messageTypeCancel          messageType = 0xc0
+	messageTypeClaim           messageType = 0xc1
 	messageTypePingReq         messageType = 0xd0
 	messageTypePingRes         messageType = 0xd1
 	messageTypeError           messageType = 0xFF
@@ -78,6 +80,15 @@ const (
 	InitParamTChannelLanguageVersion = "tchannel_language_version"
 	// InitParamTChannelVersion contains the library version.
 	InitParamTChannelVersion = "tchannel_version"
+	// InitParamCompression contains the comma-separated list of compressions
+	// that the peer can decompress.
+	InitParamCompression = "tchannel_compression"
+	// InitParamMaxFrameSize contains the largest frame size that the peer
+	// supports, if it is larger than MaxFrameSize.
+	InitParamMaxFrameSize = "tchannel_max_frame_size"
+	// InitParamCallControl contains the comma-separated list of call control
+	// frames, such as "cancel", that the peer handles.
+	InitParamCallControl = "tchannel_call_control"
 )
 
 // initMessage is the base for messages in the initialization handshake
@@ -173,6 +184,20 @@ const (
 	// requested service. A relay may use the routing key over the service if
 	// it knows about traffic groups.
 	RoutingKey TransportHeaderName = "rk"
+
+	// Compression header specifies the compression used for arg3.
+	Compression TransportHeaderName = "cmp"
+
+	// Load header is set on call responses to the load reported by the
+	// server, where 0 is idle and 1 is saturated.
+	Load TransportHeaderName = "ld"
+
+	// Priority header specifies the priority of the call. See CallPriority.
+	Priority TransportHeaderName = "pr"
+
+	// IdempotencyKey header identifies a request across retries, so servers
+	// can deduplicate calls that were already handled.
+	IdempotencyKey TransportHeaderName = "idk"
 )
 
 // transportHeaders are passed as part of a CallReq/CallRes
@@ -311,6 +336,53 @@ func (m errorMessage) Error() string {
 	return m.AsSystemError().Error()
 }
 
+// cancelMessage is sent by a caller to cancel a call that it no longer needs
+// the response for.
+type cancelMessage struct {
+	id         uint32
+	TimeToLive time.Duration
+	Tracing    Span
+	Why        string
+}
+
+func (m *cancelMessage) ID() uint32               { return m.id }
+func (m *cancelMessage) messageType() messageType { return messageTypeCancel }
+func (m *cancelMessage) read(r *typed.ReadBuffer) error {
+	m.TimeToLive = time.Duration(r.ReadUint32()) * time.Millisecond
+	m.Tracing.read(r)
+	m.Why = r.ReadLen16String()
+	return r.Err()
+}
+
+func (m *cancelMessage) write(w *typed.WriteBuffer) error {
+	w.WriteUint32(uint32(m.TimeToLive / time.Millisecond))
+	m.Tracing.write(w)
+	w.WriteLen16String(m.Why)
+	return w.Err()
+}
+
+// claimMessage is sent by a caller to tell a peer that another attempt of the
+// same call has been claimed, so the peer can stop processing its attempt.
+type claimMessage struct {
+	id         uint32
+	TimeToLive time.Duration
+	Tracing    Span
+}
+
+func (m *claimMessage) ID() uint32               { return m.id }
+func (m *claimMessage) messageType() messageType { return messageTypeClaim }
+func (m *claimMessage) read(r *typed.ReadBuffer) error {
+	m.TimeToLive = time.Duration(r.ReadUint32()) * time.Millisecond
+	m.Tracing.read(r)
+	return r.Err()
+}
+
+func (m *claimMessage) write(w *typed.WriteBuffer) error {
+	w.WriteUint32(uint32(m.TimeToLive / time.Millisecond))
+	m.Tracing.write(w)
+	return w.Err()
+}
+
 type pingReq struct {
 	noBodyMsg
 	id uint32
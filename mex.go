@@ -21,6 +21,7 @@
 package tchannel
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
@@ -28,7 +29,6 @@ import (
 	"github.com/uber/tchannel-go/typed"
 
 	"github.com/uber-go/atomic"
-	"golang.org/x/net/context"
 )
 
 var (
@@ -97,6 +97,10 @@ type messageExchange struct {
 	mexset    *messageExchangeSet
 	framePool FramePool
 
+	// cancel cancels ctx. It is only set for inbound calls, which can be
+	// cancelled by the peer.
+	cancel context.CancelFunc
+
 	shutdownAtomic atomic.Uint32
 	errChNotified  atomic.Uint32
 }
@@ -249,9 +253,10 @@ func (mex *messageExchange) shutdown() {
 // inboundExpired is called when an exchange is canceled or it times out,
 // but a handler may still be running in the background. Since the handler may
 // still write to the exchange, we cannot shutdown the exchange, but we should
-// remove it from the connection's exchange list.
-func (mex *messageExchange) inboundExpired() {
-	mex.mexset.expireExchange(mex.msgID)
+// remove it from the connection's exchange list. It returns whether the
+// exchange was still active, meaning no response has been sent.
+func (mex *messageExchange) inboundExpired() bool {
+	return mex.mexset.expireExchange(mex.msgID)
 }
 
 // A messageExchangeSet manages a set of active message exchanges.  It is
@@ -388,7 +393,7 @@ func (mexset *messageExchangeSet) removeExchange(msgID uint32) {
 // expireExchange is similar to removeExchange, however it does not decrement
 // the sendChRefs wait group, since there could still be a handler running that
 // will write to the send channel.
-func (mexset *messageExchangeSet) expireExchange(msgID uint32) {
+func (mexset *messageExchangeSet) expireExchange(msgID uint32) (found bool) {
 	mexset.log.Debugf(
 		"Removing %s message exchange %d due to timeout or cancelation",
 		mexset.name,
@@ -409,6 +414,19 @@ func (mexset *messageExchangeSet) expireExchange(msgID uint32) {
 	}
 
 	mexset.onRemoved()
+	return found
+}
+
+// cancelFunc returns the function to cancel the context of an active message
+// exchange, or nil if the exchange is not active or cannot be cancelled.
+func (mexset *messageExchangeSet) cancelFunc(msgID uint32) context.CancelFunc {
+	mexset.RLock()
+	defer mexset.RUnlock()
+
+	if mex := mexset.exchanges[msgID]; mex != nil {
+		return mex.cancel
+	}
+	return nil
 }
 
 // waitForSendCh waits for all goroutines with references to sendCh to complete.
@@ -447,7 +465,7 @@ func (mexset *messageExchangeSet) forwardPeerFrame(frame *Frame) error {
 	if err := mex.forwardPeerFrame(frame); err != nil {
 		mexset.log.WithFields(
 			LogField{"frameHeader", frame.Header.String()},
-			LogField{"frameSize", frame.Header.FrameSize()},
+			LogField{"frameSize", frame.frameSize()},
 			LogField{"exchange", mexset.name},
 			ErrField(err),
 		).Info("Failed to forward frame.")
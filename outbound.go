@@ -21,6 +21,7 @@
 package tchannel
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -28,7 +29,7 @@ import (
 
 	"github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
-	"golang.org/x/net/context"
+	"github.com/uber-go/atomic"
 )
 
 // maxMethodSize is the maximum size of arg1.
@@ -87,11 +88,20 @@ func (c *Connection) beginCall(ctx context.Context, serviceName, methodName stri
 		CallerName: c.localPeerInfo.ServiceName,
 	}
 	callOptions.setHeaders(headers)
-	if opts := currentCallOptions(ctx); opts != nil {
+	if opts := CurrentCallOptions(ctx); opts != nil {
 		opts.overrideHeaders(headers)
 	}
+	if dropped := c.baggage.setHeaders(headers, CurrentBaggage(ctx)); dropped > 0 {
+		c.statsReporter.IncCounter("outbound.baggage.dropped", c.commonStatsTags, int64(dropped))
+	}
+
+	compressor := c.outboundCompressor(callOptions.Compression)
+	if compressor != nil {
+		headers[Compression] = compressor.Name()
+	}
 
 	call := new(OutboundCall)
+	call.compressor = compressor
 	call.mex = mex
 	call.conn = c
 	call.callReq = callReq{
@@ -102,6 +112,9 @@ func (c *Connection) beginCall(ctx context.Context, serviceName, methodName stri
 	}
 	call.statsReporter = c.statsReporter
 	call.createStatsTags(c.commonStatsTags, callOptions, methodName)
+	if c.outboundPeerTag {
+		call.commonStatsTags["target-peer"] = c.remotePeerInfo.HostPort
+	}
 	call.log = c.log.WithFields(LogField{"Out-Call", requestID})
 
 	// TODO(mmihic): It'd be nice to do this without an fptr
@@ -114,11 +127,14 @@ func (c *Connection) beginCall(ctx context.Context, serviceName, methodName stri
 	}
 
 	call.contents = newFragmentingWriter(call.log, call, c.opts.ChecksumType.New())
+	call.contents.limits = c.outboundArgLimits
+	call.contents.onLimitExceeded = func(err error) { call.failed(err) }
 
 	response := new(OutboundCallResponse)
 	response.startedAt = now
 	response.timeNow = c.timeNow
 	response.requestState = callOptions.RequestState
+	response.compressors = c.compressors
 	response.mex = mex
 	response.log = c.log.WithFields(LogField{"Out-Response", requestID})
 	response.span = c.startOutboundSpan(ctx, serviceName, methodName, call, now)
@@ -130,11 +146,43 @@ func (c *Connection) beginCall(ctx context.Context, serviceName, methodName stri
 		return new(callResContinue)
 	}
 	response.contents = newFragmentingReader(response.log, response)
+	c.verifyChecksums(response.contents, c.checksumFailed)
+
+	if c.detailedErrors {
+		errInfo := &callErrorInfo{
+			service:      serviceName,
+			method:       methodName,
+			hostPort:     c.remotePeerInfo.HostPort,
+			requestState: callOptions.RequestState,
+		}
+		call.errInfo = errInfo
+		response.errInfo = errInfo
+		response.contents.errInfo = errInfo
+	}
 	response.statsReporter = call.statsReporter
+
+	accessLog := c.newAccessLogCall(AccessLogEntry{
+		Time:      now,
+		Direction: AccessLogOutbound,
+		Caller:    headers[CallerName],
+		Service:   serviceName,
+		Method:    methodName,
+		Attempt:   callOptions.RequestState.RetryCount() + 1,
+	})
+	accessLog.setHeaders(c.baggage.redact(headers))
+	call.accessLog = accessLog
+	response.accessLog = accessLog
 	response.commonStatsTags = call.commonStatsTags
 
 	call.response = response
 
+	cancelOnFailure := c.cancelOnFailure(call)
+	call.onFailed = cancelOnFailure
+	response.onFailed = cancelOnFailure
+	if rs := callOptions.RequestState; rs != nil {
+		rs.hedged.addCall(call)
+	}
+
 	if err := call.writeMethod([]byte(methodName)); err != nil {
 		return nil, err
 	}
@@ -170,6 +218,12 @@ type OutboundCall struct {
 	response        *OutboundCallResponse
 	statsReporter   StatsReporter
 	commonStatsTags map[string]string
+
+	// compressor is used to compress arg3, or nil if arg3 is not compressed.
+	compressor Compressor
+
+	// abandoned is set once a cancel or claim frame has been sent for the call.
+	abandoned atomic.Bool
 }
 
 // Response provides access to the call's response object, which can be used to
@@ -206,7 +260,11 @@ func (call *OutboundCall) Arg2Writer() (ArgWriter, error) {
 // Arg3Writer returns a WriteCloser that can be used to write the last argument.
 // The returned writer must be closed once the write is complete.
 func (call *OutboundCall) Arg3Writer() (ArgWriter, error) {
-	return call.arg3Writer()
+	w, err := call.arg3Writer()
+	if err != nil {
+		return nil, err
+	}
+	return newCompressingWriter(call.compressor, w), nil
 }
 
 // LocalPeer returns the local peer information for this call.
@@ -234,6 +292,18 @@ type OutboundCallResponse struct {
 	span            opentracing.Span
 	statsReporter   StatsReporter
 	commonStatsTags map[string]string
+	compressors     *compressors
+
+	// onDone is an optional callback for when the response has been read,
+	// with any system error returned by the peer.
+	onDone func(unexpected error)
+
+	// onLoad is an optional callback for the load reported by the peer in
+	// the response.
+	onLoad func(load float64)
+
+	// onStarted is an optional callback for when the peer starts responding.
+	onStarted func()
 }
 
 // ApplicationError returns true if the call resulted in an application level error
@@ -257,6 +327,9 @@ func (response *OutboundCallResponse) Arg2Reader() (ArgReader, error) {
 	if err := NewArgReader(response.arg1Reader()).Read(&method); err != nil {
 		return nil, err
 	}
+	if response.onStarted != nil {
+		response.onStarted()
+	}
 
 	return response.arg2Reader()
 }
@@ -264,7 +337,15 @@ func (response *OutboundCallResponse) Arg2Reader() (ArgReader, error) {
 // Arg3Reader returns an ArgReader to read the last argument.
 // The ReadCloser must be closed once the argument has been read.
 func (response *OutboundCallResponse) Arg3Reader() (ArgReader, error) {
-	return response.arg3Reader()
+	compressor, err := response.compressors.forHeaders(response.callRes.Headers)
+	if err != nil {
+		return nil, err
+	}
+	r, err := response.arg3Reader()
+	if err != nil {
+		return nil, err
+	}
+	return newDecompressingReader(compressor, r)
 }
 
 // handleError handles an error coming back from the peer. If the error is a
@@ -363,6 +444,18 @@ func (response *OutboundCallResponse) doneReading(unexpected error) {
 		response.statsReporter.IncCounter("outbound.calls.success", response.commonStatsTags, 1)
 	}
 
+	response.accessLog.setResponseCode(accessLogResponseCode(unexpected, response.ApplicationError()))
+	response.accessLog.finish(now)
+
+	if response.onDone != nil {
+		response.onDone(unexpected)
+	}
+	if response.onLoad != nil {
+		if load, ok := parseLoad(response.callRes.Headers); ok {
+			response.onLoad(load)
+		}
+	}
+	// Removing the exchange updates the peer's score, using any new load.
 	response.mex.shutdown()
 }
 
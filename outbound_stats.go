@@ -0,0 +1,116 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultMaxSlowCalls = 100
+
+// OutboundStatsOptions configures additional stats for outbound calls.
+type OutboundStatsOptions struct {
+	// PeerTag adds a "target-peer" tag with the host:port of the peer to the
+	// stats for outbound calls. Calls are already tagged by method.
+	PeerTag bool
+
+	// SlowCallThreshold is the latency above which outbound calls are
+	// sampled, and made available using Channel.SlowCalls and introspection.
+	// Zero disables sampling.
+	SlowCallThreshold time.Duration
+
+	// MaxSlowCalls is the number of the most recent slow calls that are
+	// kept. Defaults to 100.
+	MaxSlowCalls int
+}
+
+// SlowCall is a sample of an outbound call that took longer than
+// OutboundStatsOptions.SlowCallThreshold.
+type SlowCall struct {
+	AccessLogEntry
+
+	// Headers are the transport headers sent with the call.
+	Headers map[string]string `json:"headers"`
+}
+
+// slowCallSampler keeps the most recent slow calls. A nil *slowCallSampler
+// is valid, and ignores all calls.
+type slowCallSampler struct {
+	threshold time.Duration
+
+	sync.Mutex
+	samples []SlowCall
+	next    int
+}
+
+// newSlowCallSampler returns a sampler if sampling is enabled, and nil otherwise.
+func newSlowCallSampler(opts OutboundStatsOptions) *slowCallSampler {
+	if opts.SlowCallThreshold <= 0 {
+		return nil
+	}
+	if opts.MaxSlowCalls <= 0 {
+		opts.MaxSlowCalls = defaultMaxSlowCalls
+	}
+	return &slowCallSampler{
+		threshold: opts.SlowCallThreshold,
+		samples:   make([]SlowCall, 0, opts.MaxSlowCalls),
+	}
+}
+
+func (s *slowCallSampler) record(entry AccessLogEntry, headers transportHeaders) {
+	if s == nil || entry.Latency < s.threshold {
+		return
+	}
+
+	sample := SlowCall{AccessLogEntry: entry, Headers: make(map[string]string, len(headers))}
+	for k, v := range headers {
+		sample.Headers[string(k)] = v
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if len(s.samples) < cap(s.samples) {
+		s.samples = append(s.samples, sample)
+		return
+	}
+	s.samples[s.next] = sample
+	s.next = (s.next + 1) % len(s.samples)
+}
+
+// list returns the sampled calls, oldest first.
+func (s *slowCallSampler) list() []SlowCall {
+	if s == nil {
+		return nil
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	samples := make([]SlowCall, 0, len(s.samples))
+	samples = append(samples, s.samples[s.next:]...)
+	return append(samples, s.samples[:s.next]...)
+}
+
+// SlowCalls returns the most recent outbound calls that were slower than
+// OutboundStatsOptions.SlowCallThreshold, oldest first.
+func (ch *Channel) SlowCalls() []SlowCall {
+	return ch.slowCalls.list()
+}
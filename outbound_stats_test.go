@@ -0,0 +1,112 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sendTagsReporter records the tags of sent outbound calls.
+type sendTagsReporter struct {
+	sync.Mutex
+	StatsReporter
+
+	tags []map[string]string
+}
+
+func (r *sendTagsReporter) IncCounter(name string, tags map[string]string, value int64) {
+	if name != "outbound.calls.send" {
+		return
+	}
+	r.Lock()
+	defer r.Unlock()
+	r.tags = append(r.tags, tags)
+}
+
+func TestOutboundStatsPeerTag(t *testing.T) {
+	testutils.WithTestServer(t, nil, func(ts *testutils.TestServer) {
+		testutils.RegisterEcho(ts.Server(), nil)
+
+		reporter := &sendTagsReporter{StatsReporter: NullStatsReporter}
+		opts := testutils.NewOpts().SetStatsReporter(reporter)
+		opts.OutboundStats.PeerTag = true
+		client := ts.NewClient(opts)
+		testutils.AssertEcho(t, client, ts.HostPort(), ts.ServiceName())
+
+		reporter.Lock()
+		defer reporter.Unlock()
+		require.Len(t, reporter.tags, 1, "Expected a single call to be sent")
+		assert.Equal(t, ts.HostPort(), reporter.tags[0]["target-peer"], "Unexpected peer tag")
+		assert.Equal(t, "echo", reporter.tags[0]["target-endpoint"], "Unexpected method tag")
+	})
+}
+
+func TestSlowCalls(t *testing.T) {
+	testutils.WithTestServer(t, nil, func(ts *testutils.TestServer) {
+		testutils.RegisterFunc(ts.Server(), "fast", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+			return &raw.Res{}, nil
+		})
+		testutils.RegisterFunc(ts.Server(), "slow", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+			time.Sleep(30 * time.Millisecond)
+			return &raw.Res{Arg3: args.Arg3}, nil
+		})
+
+		opts := testutils.NewOpts()
+		opts.OutboundStats.SlowCallThreshold = 20 * time.Millisecond
+		opts.OutboundStats.MaxSlowCalls = 2
+		client := ts.NewClient(opts)
+
+		for _, arg3 := range []string{"1", "22", "333"} {
+			ctx, cancel := NewContext(testutils.Timeout(time.Second))
+			_, _, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "fast", nil, nil)
+			require.NoError(t, err, "fast call failed")
+			_, _, _, err = raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "slow", nil, []byte(arg3))
+			require.NoError(t, err, "slow call failed")
+			cancel()
+		}
+
+		// Only the last 2 slow calls are kept, oldest first.
+		calls := client.SlowCalls()
+		require.Len(t, calls, 2, "Unexpected number of slow calls")
+		for _, call := range calls {
+			assert.Equal(t, "slow", call.Method, "Unexpected method")
+			assert.Equal(t, ts.ServiceName(), call.Service, "Unexpected service")
+			assert.Equal(t, ts.HostPort(), call.RemoteHostPort, "Unexpected peer")
+			assert.Equal(t, AccessLogOK, call.ResponseCode, "Unexpected response code")
+			assert.Equal(t, 1, call.Attempt, "Unexpected attempt")
+			assert.True(t, call.Latency >= 20*time.Millisecond, "Unexpected latency %v", call.Latency)
+			assert.Equal(t, client.PeerInfo().ServiceName, call.Headers[string(CallerName)], "Missing caller header")
+		}
+		assert.Equal(t, uint64(1), calls[1].BytesReceived-calls[0].BytesReceived, "Unexpected response sizes")
+		assert.Equal(t, calls, client.IntrospectState(&IntrospectionOptions{IncludeSlowCalls: true}).SlowCalls, "Introspection should include slow calls")
+	})
+}
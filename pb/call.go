@@ -0,0 +1,186 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package pb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uber/tchannel-go"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// ErrApplication is an application error returned by the remote handler,
+// which contains the error message.
+type ErrApplication string
+
+func (e ErrApplication) Error() string {
+	return fmt.Sprintf("protobuf call failed: %v", string(e))
+}
+
+// Client is used to make protobuf calls to other services.
+type Client struct {
+	ch            *tchannel.Channel
+	targetService string
+	hostPort      string
+}
+
+// ClientOptions are options used when creating a client.
+type ClientOptions struct {
+	HostPort string
+}
+
+// NewClient returns a pb.Client used to make outbound protobuf calls.
+func NewClient(ch *tchannel.Channel, targetService string, opts *ClientOptions) *Client {
+	client := &Client{
+		ch:            ch,
+		targetService: targetService,
+	}
+	if opts != nil && opts.HostPort != "" {
+		client.hostPort = opts.HostPort
+	}
+	return client
+}
+
+func makeCall(call *tchannel.OutboundCall, headers map[string]string, arg, resp proto.Message) (map[string]string, string, error) {
+	headers = tchannel.InjectOutboundSpan(call.Response(), headers)
+	arg2, err := encodeHeaders(headers)
+	if err != nil {
+		return nil, "arg2 encode failed", err
+	}
+	if err := tchannel.NewArgWriter(call.Arg2Writer()).Write(arg2); err != nil {
+		return nil, "arg2 write failed", err
+	}
+	arg3, err := proto.Marshal(arg)
+	if err != nil {
+		return nil, "arg3 encode failed", err
+	}
+	if err := tchannel.NewArgWriter(call.Arg3Writer()).Write(arg3); err != nil {
+		return nil, "arg3 write failed", err
+	}
+
+	// Call Arg2Reader before checking application error.
+	var respArg2 []byte
+	if err := tchannel.NewArgReader(call.Response().Arg2Reader()).Read(&respArg2); err != nil {
+		return nil, "arg2 read failed", err
+	}
+	respHeaders, err := decodeHeaders(respArg2)
+	if err != nil {
+		return nil, "arg2 decode failed", err
+	}
+
+	var respArg3 []byte
+	if err := tchannel.NewArgReader(call.Response().Arg3Reader()).Read(&respArg3); err != nil {
+		return nil, "arg3 read failed", err
+	}
+
+	// If this is an error response, arg3 contains the error message.
+	if call.Response().ApplicationError() {
+		return respHeaders, "", ErrApplication(respArg3)
+	}
+
+	if err := proto.Unmarshal(respArg3, resp); err != nil {
+		return nil, "arg3 decode failed", err
+	}
+	return respHeaders, "", nil
+}
+
+func (c *Client) startCall(ctx context.Context, method string, callOptions *tchannel.CallOptions) (*tchannel.OutboundCall, error) {
+	if c.hostPort != "" {
+		return c.ch.BeginCall(ctx, c.hostPort, c.targetService, method, callOptions)
+	}
+
+	return c.ch.GetSubChannel(c.targetService).BeginCall(ctx, method, callOptions)
+}
+
+// Call makes a protobuf call, with retries.
+func (c *Client) Call(ctx Context, method string, arg, resp proto.Message) error {
+	var (
+		headers = ctx.Headers()
+
+		respHeaders map[string]string
+		appErr      error
+		errAt       string
+	)
+
+	err := c.ch.RunWithRetry(ctx, func(ctx context.Context, rs *tchannel.RequestState) error {
+		respHeaders, appErr = nil, nil
+		errAt = "connect"
+
+		call, err := c.startCall(ctx, method, &tchannel.CallOptions{
+			Format:       tchannel.Proto,
+			RequestState: rs,
+		})
+		if err != nil {
+			return err
+		}
+
+		respHeaders, errAt, err = makeCall(call, headers, arg, resp)
+		if _, ok := err.(ErrApplication); ok {
+			// Application errors are only retried if they are classified
+			// as retryable.
+			appErr = err
+			return rs.RetryAppError(respHeaders, appErr)
+		}
+		return err
+	})
+	if err != nil {
+		return wrapError(errAt, err)
+	}
+
+	ctx.SetResponseHeaders(respHeaders)
+	return appErr
+}
+
+func wrapCall(ctx Context, call *tchannel.OutboundCall, method string, arg, resp proto.Message) error {
+	respHeaders, errAt, err := makeCall(call, ctx.Headers(), arg, resp)
+	if _, ok := err.(ErrApplication); ok {
+		ctx.SetResponseHeaders(respHeaders)
+		return err
+	}
+	if err != nil {
+		return wrapError(errAt, err)
+	}
+
+	ctx.SetResponseHeaders(respHeaders)
+	return nil
+}
+
+// CallPeer makes a protobuf call using the given peer.
+func CallPeer(ctx Context, peer *tchannel.Peer, serviceName, method string, arg, resp proto.Message) error {
+	call, err := peer.BeginCall(ctx, serviceName, method, &tchannel.CallOptions{Format: tchannel.Proto})
+	if err != nil {
+		return err
+	}
+
+	return wrapCall(ctx, call, method, arg, resp)
+}
+
+// CallSC makes a protobuf call using the given subchannel.
+func CallSC(ctx Context, sc *tchannel.SubChannel, method string, arg, resp proto.Message) error {
+	call, err := sc.BeginCall(ctx, method, &tchannel.CallOptions{Format: tchannel.Proto})
+	if err != nil {
+		return err
+	}
+
+	return wrapCall(ctx, call, method, arg, resp)
+}
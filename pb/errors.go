@@ -0,0 +1,43 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package pb
+
+// wrappedError adds a message to an error, and keeps the original error
+// available through Unwrap and Cause, so that tchannel.GetSystemErrorCode can
+// find the SystemError that a call failed with.
+type wrappedError struct {
+	msg string
+	err error
+}
+
+func wrapError(msg string, err error) error {
+	return wrappedError{msg: msg, err: err}
+}
+
+func (e wrappedError) Error() string {
+	return e.msg + ": " + e.err.Error()
+}
+
+// Unwrap returns the original error.
+func (e wrappedError) Unwrap() error { return e.err }
+
+// Cause returns the original error.
+func (e wrappedError) Cause() error { return e.err }
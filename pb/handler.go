@@ -0,0 +1,178 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package pb
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/uber/tchannel-go"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/opentracing/opentracing-go"
+)
+
+var (
+	typeOfError   = reflect.TypeOf((*error)(nil)).Elem()
+	typeOfContext = reflect.TypeOf((*Context)(nil)).Elem()
+	typeOfMessage = reflect.TypeOf((*proto.Message)(nil)).Elem()
+)
+
+// Handlers is the map from method names to handlers.
+type Handlers map[string]interface{}
+
+// verifyHandler ensures that the given t is a function with the following signature:
+// func(pb.Context, *ArgType)(*ResType, error)
+// where *ArgType and *ResType are protobuf messages.
+func verifyHandler(t reflect.Type) error {
+	if t.Kind() != reflect.Func || t.NumIn() != 2 || t.NumOut() != 2 {
+		return fmt.Errorf("handler should be of format func(pb.Context, *ArgType) (*ResType, error)")
+	}
+
+	validateArgRes := func(t reflect.Type, name string) error {
+		if t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct || !t.Implements(typeOfMessage) {
+			return fmt.Errorf("%v should be a pointer to a struct that implements proto.Message", name)
+		}
+		return nil
+	}
+
+	if t.In(0) != typeOfContext {
+		return fmt.Errorf("arg0 should be of type pb.Context")
+	}
+	if err := validateArgRes(t.In(1), "second argument"); err != nil {
+		return err
+	}
+	if err := validateArgRes(t.Out(0), "first return value"); err != nil {
+		return err
+	}
+	if !t.Out(1).AssignableTo(typeOfError) {
+		return fmt.Errorf("second return value should be an error")
+	}
+
+	return nil
+}
+
+type handler struct {
+	handler reflect.Value
+	argType reflect.Type
+	tracer  func() opentracing.Tracer
+}
+
+func toHandler(f interface{}) (*handler, error) {
+	hV := reflect.ValueOf(f)
+	if err := verifyHandler(hV.Type()); err != nil {
+		return nil, err
+	}
+	return &handler{handler: hV, argType: hV.Type().In(1)}, nil
+}
+
+// Register registers the specified methods specified as a map from method name to the
+// protobuf handler function. The handler functions should have the following signature:
+// func(pb.Context, *ArgType)(*ResType, error)
+func Register(registrar tchannel.Registrar, funcs Handlers, onError func(context.Context, error)) error {
+	handlers := make(map[string]*handler)
+
+	handler := tchannel.HandlerFunc(func(ctx context.Context, call *tchannel.InboundCall) {
+		h, ok := handlers[string(call.Method())]
+		if !ok {
+			onError(ctx, fmt.Errorf("call for unregistered method: %s", call.Method()))
+			return
+		}
+
+		if err := h.Handle(ctx, call); err != nil {
+			onError(ctx, err)
+		}
+	})
+
+	for m, f := range funcs {
+		h, err := toHandler(f)
+		if err != nil {
+			return fmt.Errorf("%v cannot be used as a handler: %v", m, err)
+		}
+		h.tracer = func() opentracing.Tracer {
+			return tchannel.TracerFromRegistrar(registrar)
+		}
+		handlers[m] = h
+		registrar.Register(handler, m)
+	}
+
+	return nil
+}
+
+// Handle deserializes the protobuf arguments and calls the underlying handler.
+func (h *handler) Handle(tctx context.Context, call *tchannel.InboundCall) error {
+	var arg2 []byte
+	if err := tchannel.NewArgReader(call.Arg2Reader()).Read(&arg2); err != nil {
+		return wrapError("arg2 read failed", err)
+	}
+	headers, err := decodeHeaders(arg2)
+	if err != nil {
+		return wrapError("arg2 decode failed", err)
+	}
+	tctx = tchannel.ExtractInboundSpan(tctx, call, headers, h.tracer())
+	ctx := WithHeaders(tctx, headers)
+
+	var arg3 []byte
+	if err := tchannel.NewArgReader(call.Arg3Reader()).Read(&arg3); err != nil {
+		return wrapError("arg3 read failed", err)
+	}
+	arg := reflect.New(h.argType.Elem())
+	if err := proto.Unmarshal(arg3, arg.Interface().(proto.Message)); err != nil {
+		return call.Response().SendSystemError(tchannel.NewSystemError(
+			tchannel.ErrCodeBadRequest, "could not decode protobuf argument: %v", err))
+	}
+
+	results := h.handler.Call([]reflect.Value{reflect.ValueOf(ctx), arg})
+
+	var resBytes []byte
+	if err, _ := results[1].Interface().(error); err != nil {
+		// If an error was returned, we respond with an application error
+		// containing the error message.
+		if serr, ok := err.(tchannel.SystemError); ok {
+			return call.Response().SendSystemError(serr)
+		}
+
+		if err := call.Response().SetApplicationError(); err != nil {
+			return err
+		}
+		resBytes = []byte(err.Error())
+	} else {
+		if results[0].IsNil() {
+			return call.Response().SendSystemError(tchannel.NewSystemError(
+				tchannel.ErrCodeUnexpected, "handler for %s returned a nil response", call.Method()))
+		}
+		if resBytes, err = proto.Marshal(results[0].Interface().(proto.Message)); err != nil {
+			return call.Response().SendSystemError(tchannel.NewSystemError(
+				tchannel.ErrCodeUnexpected, "could not encode protobuf response: %v", err))
+		}
+	}
+
+	respHeaders, err := encodeHeaders(ctx.ResponseHeaders())
+	if err != nil {
+		return err
+	}
+	if err := tchannel.NewArgWriter(call.Response().Arg2Writer()).Write(respHeaders); err != nil {
+		return err
+	}
+
+	return tchannel.NewArgWriter(call.Response().Arg3Writer()).Write(resBytes)
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package pb
+
+import (
+	"fmt"
+
+	"github.com/uber/tchannel-go/typed"
+)
+
+// encodeHeaders encodes the given key-value pairs using the same encoding
+// as the thrift package: nh~2 (k~2 v~2){nh}
+func encodeHeaders(headers map[string]string) ([]byte, error) {
+	size := 2
+	for k, v := range headers {
+		size += 4 /* size of key/value lengths */
+		size += len(k) + len(v)
+	}
+
+	buf := make([]byte, size)
+	wbuf := typed.NewWriteBuffer(buf)
+	wbuf.WriteUint16(uint16(len(headers)))
+	for k, v := range headers {
+		wbuf.WriteLen16String(k)
+		wbuf.WriteLen16String(v)
+	}
+	return buf, wbuf.Err()
+}
+
+// decodeHeaders decodes key-value pairs encoded using encodeHeaders. Empty
+// arg2 is treated as no headers.
+func decodeHeaders(buf []byte) (map[string]string, error) {
+	if len(buf) == 0 {
+		return nil, nil
+	}
+
+	rbuf := typed.NewReadBuffer(buf)
+	numHeaders := rbuf.ReadUint16()
+	if numHeaders == 0 {
+		return nil, rbuf.Err()
+	}
+
+	headers := make(map[string]string, numHeaders)
+	for i := 0; i < int(numHeaders) && rbuf.Err() == nil; i++ {
+		k := rbuf.ReadLen16String()
+		v := rbuf.ReadLen16String()
+		headers[k] = v
+	}
+	if err := rbuf.Err(); err != nil {
+		return nil, err
+	}
+	if rbuf.BytesRemaining() > 0 {
+		return nil, fmt.Errorf("unexpected %v bytes after headers", rbuf.BytesRemaining())
+	}
+	return headers, nil
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package pb
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testHandler struct {
+	t *testing.T
+}
+
+func (h *testHandler) echo(ctx Context, arg *wrappers.StringValue) (*wrappers.StringValue, error) {
+	ctx.SetResponseHeaders(map[string]string{"hdr": ctx.Headers()["hdr"] + "-resp"})
+	return &wrappers.StringValue{Value: arg.Value}, nil
+}
+
+func (h *testHandler) fail(ctx Context, arg *wrappers.StringValue) (*wrappers.StringValue, error) {
+	if arg.Value == "busy" {
+		return nil, tchannel.ErrServerBusy
+	}
+	return nil, errors.New("failed: " + arg.Value)
+}
+
+func (h *testHandler) count(ctx Context, arg *wrappers.StringValue) (*wrappers.Int64Value, error) {
+	return &wrappers.Int64Value{Value: int64(len(arg.Value))}, nil
+}
+
+func (h *testHandler) onError(ctx context.Context, err error) {
+	h.t.Errorf("onError: %v", err)
+}
+
+func setupServer(t *testing.T) *tchannel.Channel {
+	server := testutils.NewServer(t, nil)
+	h := &testHandler{t}
+	require.NoError(t, Register(server, Handlers{
+		"echo":  h.echo,
+		"fail":  h.fail,
+		"count": h.count,
+	}, h.onError), "Register failed")
+	return server
+}
+
+func TestCalls(t *testing.T) {
+	server := setupServer(t)
+	defer server.Close()
+	client := testutils.NewClient(t, nil)
+	defer client.Close()
+
+	pbClient := NewClient(client, server.ServiceName(), &ClientOptions{
+		HostPort: server.PeerInfo().HostPort,
+	})
+
+	ctx, cancel := NewContext(time.Second)
+	defer cancel()
+	ctx = WithHeaders(ctx, map[string]string{"hdr": "req"})
+
+	var res wrappers.StringValue
+	require.NoError(t, pbClient.Call(ctx, "echo", &wrappers.StringValue{Value: "hello"}, &res), "echo failed")
+	assert.Equal(t, "hello", res.Value, "Unexpected echo response")
+	assert.Equal(t, map[string]string{"hdr": "req-resp"}, ctx.ResponseHeaders(), "Unexpected response headers")
+
+	var count wrappers.Int64Value
+	require.NoError(t, pbClient.Call(ctx, "count", &wrappers.StringValue{Value: "hello"}, &count), "count failed")
+	assert.EqualValues(t, 5, count.Value, "Unexpected count response")
+
+	err := pbClient.Call(ctx, "fail", &wrappers.StringValue{Value: "app"}, &res)
+	assert.Equal(t, ErrApplication("failed: app"), err, "Expected application error")
+
+	err = pbClient.Call(ctx, "fail", &wrappers.StringValue{Value: "busy"}, &res)
+	require.Error(t, err, "Expected system error")
+	assert.True(t, strings.Contains(err.Error(), tchannel.ErrServerBusy.Error()), "Unexpected error: %v", err)
+}
+
+func TestCallPeerAndSC(t *testing.T) {
+	server := setupServer(t)
+	defer server.Close()
+	client := testutils.NewClient(t, nil)
+	defer client.Close()
+
+	ctx, cancel := NewContext(time.Second)
+	defer cancel()
+
+	hostPort := server.PeerInfo().HostPort
+	var res wrappers.StringValue
+	peer := client.Peers().GetOrAdd(hostPort)
+	require.NoError(t, CallPeer(ctx, peer, server.ServiceName(), "echo", &wrappers.StringValue{Value: "peer"}, &res), "CallPeer failed")
+	assert.Equal(t, "peer", res.Value, "Unexpected CallPeer response")
+
+	sc := client.GetSubChannel(server.ServiceName())
+	sc.Peers().Add(hostPort)
+	require.NoError(t, CallSC(ctx, sc, "echo", &wrappers.StringValue{Value: "sc"}, &res), "CallSC failed")
+	assert.Equal(t, "sc", res.Value, "Unexpected CallSC response")
+	assert.Equal(t, map[string]string{"hdr": "-resp"}, ctx.ResponseHeaders(), "Unexpected response headers")
+
+	err := CallSC(ctx, sc, "fail", &wrappers.StringValue{Value: "sc"}, &res)
+	assert.Equal(t, ErrApplication("failed: sc"), err, "Expected application error")
+}
+
+func TestInvalidHandlers(t *testing.T) {
+	ch := testutils.NewClient(t, nil)
+	defer ch.Close()
+
+	tests := []struct {
+		msg string
+		f   interface{}
+	}{
+		{"not a function", "echo"},
+		{"wrong context", func(context.Context, *wrappers.StringValue) (*wrappers.StringValue, error) { return nil, nil }},
+		{"non-proto arg", func(Context, *struct{}) (*wrappers.StringValue, error) { return nil, nil }},
+		{"non-pointer res", func(Context, *wrappers.StringValue) (wrappers.StringValue, error) { return wrappers.StringValue{}, nil }},
+		{"no error", func(Context, *wrappers.StringValue) (*wrappers.StringValue, string) { return nil, "" }},
+	}
+
+	for _, tt := range tests {
+		err := Register(ch, Handlers{"m": tt.f}, nil)
+		assert.Error(t, err, "Register should fail for %v", tt.msg)
+	}
+}
+
+func TestHeadersEncoding(t *testing.T) {
+	tests := []map[string]string{
+		nil,
+		{"k": "v"},
+		{"k1": "v1", "k2": "", "": "v3"},
+	}
+
+	for _, headers := range tests {
+		bs, err := encodeHeaders(headers)
+		require.NoError(t, err, "encodeHeaders failed")
+
+		got, err := decodeHeaders(bs)
+		require.NoError(t, err, "decodeHeaders failed")
+		if len(headers) == 0 {
+			assert.Empty(t, got, "Expected no headers")
+			continue
+		}
+		assert.Equal(t, headers, got, "Headers mismatch")
+	}
+
+	_, err := decodeHeaders([]byte{0, 1, 0})
+	assert.Error(t, err, "decodeHeaders should fail for truncated headers")
+}
+
+func TestWrappedErrorKeepsSystemError(t *testing.T) {
+	err := wrapError("connect", tchannel.ErrServerBusy)
+	assert.Equal(t, "connect: "+tchannel.ErrServerBusy.Error(), err.Error(), "Unexpected error message")
+	assert.Equal(t, tchannel.ErrCodeBusy, tchannel.GetSystemErrorCode(err), "Wrapped error should keep the SystemError code")
+}
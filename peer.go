@@ -22,15 +22,16 @@ package tchannel
 
 import (
 	"container/heap"
+	"context"
 	"errors"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/uber/tchannel-go/tos"
 	"github.com/uber/tchannel-go/trand"
 
 	"github.com/uber-go/atomic"
-	"golang.org/x/net/context"
 )
 
 var (
@@ -48,6 +49,10 @@ var (
 	// ErrNoNewPeers indicates that no previously unselected peer is available.
 	ErrNoNewPeers = errors.New("no new peer available")
 
+	// ErrCircuitOpen indicates that no peer could be selected as the
+	// circuit breakers for all peers are open.
+	ErrCircuitOpen = errors.New("no peers available: all peer circuits are open")
+
 	peerRng = trand.NewSeeded()
 )
 
@@ -148,6 +153,11 @@ func (l *PeerList) Get(prevSelected map[string]struct{}) (*Peer, error) {
 		l.Lock()
 		peer = l.choosePeer(nil, false /* avoidHost */)
 		l.Unlock()
+		if peer == nil {
+			// The peer list is not empty, so all peers were skipped by
+			// their circuit breakers or while reconnecting.
+			return nil, ErrCircuitOpen
+		}
 	} else if err != nil {
 		return nil, err
 	}
@@ -194,7 +204,9 @@ func (l *PeerList) choosePeer(prevSelected map[string]struct{}, avoidHost bool)
 	for i := 0; i < size; i++ {
 		popped := l.peerHeap.popPeer()
 
-		if canChoosePeer(popped.HostPort()) {
+		// The circuit breaker is checked last, as allowing a half-open peer
+		// to be selected starts a probe.
+		if canChoosePeer(popped.HostPort()) && popped.reconnect.allowSelection() && popped.circuit.allowSelection() {
 			ps = popped
 			break
 		}
@@ -314,15 +326,64 @@ func newPeerScore(p *Peer, score uint64) *peerScore {
 	}
 }
 
+// peerStatusEvents are the notifications a peer sends as its connections change.
+type peerStatusEvents struct {
+	// OnStatusChanged is called whenever a connection is added or removed.
+	OnStatusChanged func(*Peer)
+
+	// OnAvailable is called when the peer gains its first active connection.
+	OnAvailable func(*Peer)
+
+	// OnUnavailable is called when the peer loses its last active connection.
+	OnUnavailable func(*Peer)
+}
+
+func (e peerStatusEvents) withDefaults() peerStatusEvents {
+	if e.OnStatusChanged == nil {
+		e.OnStatusChanged = noopOnStatusChanged
+	}
+	if e.OnAvailable == nil {
+		e.OnAvailable = noopOnStatusChanged
+	}
+	if e.OnUnavailable == nil {
+		e.OnUnavailable = noopOnStatusChanged
+	}
+	return e
+}
+
 // Peer represents a single autobahn service or client with a unique host:port.
 type Peer struct {
 	sync.RWMutex
 
 	channel             Connectable
 	hostPort            string
-	onStatusChanged     func(*Peer)
+	events              peerStatusEvents
 	onClosedConnRemoved func(*Peer)
 
+	// circuit is the peer's circuit breaker, or nil if it's disabled.
+	circuit *circuitBreaker
+
+	// detailedErrors is whether errors returned by BeginCall are wrapped in a
+	// CallError.
+	detailedErrors bool
+
+	// reconnect reconnects the peer after connection failures, or is nil if
+	// it's disabled.
+	reconnect *reconnector
+
+	// rateLimiter limits outbound calls to the peer, or nil if it's disabled.
+	rateLimiter *rateLimiter
+
+	// pool manages the connections to the peer, or nil if it's disabled.
+	pool *connPool
+
+	// load tracks the load the peer reports in call responses.
+	load peerLoad
+
+	// interceptedBeginCall runs the channel's outbound interceptors before
+	// starting a call, or is nil if there are no interceptors.
+	interceptedBeginCall BeginCallFunc
+
 	// scCount is the number of subchannels that this peer is added to.
 	scCount uint32
 
@@ -336,17 +397,14 @@ type Peer struct {
 	onUpdate func(*Peer)
 }
 
-func newPeer(channel Connectable, hostPort string, onStatusChanged func(*Peer), onClosedConnRemoved func(*Peer)) *Peer {
+func newPeer(channel Connectable, hostPort string, events peerStatusEvents, onClosedConnRemoved func(*Peer)) *Peer {
 	if hostPort == "" {
 		panic("Cannot create peer with blank hostPort")
 	}
-	if onStatusChanged == nil {
-		onStatusChanged = noopOnStatusChanged
-	}
 	return &Peer{
 		channel:             channel,
 		hostPort:            hostPort,
-		onStatusChanged:     onStatusChanged,
+		events:              events.withDefaults(),
 		onClosedConnRemoved: onClosedConnRemoved,
 	}
 }
@@ -376,9 +434,12 @@ func (p *Peer) getActiveConnLocked() (*Connection, bool) {
 	// We cycle through the connection list, starting at a random point
 	// to avoid always choosing the same connection.
 	startOffset := peerRng.Intn(allConns)
+	if p.pool != nil {
+		return p.getLeastLoadedConnLocked(startOffset)
+	}
 	for i := 0; i < allConns; i++ {
 		connIndex := (i + startOffset) % allConns
-		if conn := p.getConn(connIndex); conn.IsActive() {
+		if conn := p.getConn(connIndex); conn.IsActive() && !conn.classConn {
 			return conn, true
 		}
 	}
@@ -386,9 +447,72 @@ func (p *Peer) getActiveConnLocked() (*Connection, bool) {
 	return nil, false
 }
 
-// getActiveConn will randomly select an active connection.
+// getClassConnLocked returns an active connection marked with the given ToS
+// class. The peer must be read-locked.
+func (p *Peer) getClassConnLocked(tosPriority tos.ToS) (*Connection, bool) {
+	for _, conn := range p.outboundConnections {
+		if conn.IsActive() && conn.classConn && conn.opts.TosPriority == tosPriority {
+			return conn, true
+		}
+	}
+	return nil, false
+}
+
+func (p *Peer) getClassConn(tosPriority tos.ToS) (*Connection, bool) {
+	p.RLock()
+	conn, ok := p.getClassConnLocked(tosPriority)
+	p.RUnlock()
+
+	return conn, ok
+}
+
+// getConnectionForClass returns an active connection marked with the given
+// ToS class, creating a new outbound connection if there are none.
+func (p *Peer) getConnectionForClass(ctx context.Context, tosPriority tos.ToS) (*Connection, error) {
+	ch, ok := p.channel.(*Channel)
+	if !ok || tosPriority == 0 || tosPriority == ch.connectionOptions.TosPriority {
+		return p.GetConnection(ctx)
+	}
+
+	if conn, ok := p.getClassConn(tosPriority); ok {
+		return conn, nil
+	}
+
+	p.newConnLock.Lock()
+	defer p.newConnLock.Unlock()
+
+	if conn, ok := p.getClassConn(tosPriority); ok {
+		return conn, nil
+	}
+
+	opts := ch.connectionOptions
+	opts.TosPriority = tosPriority
+	return ch.connect(ctx, p.hostPort, opts)
+}
+
+// getLeastLoadedConnLocked returns the active connection with the fewest
+// outbound calls in-flight. The peer must be read-locked.
+func (p *Peer) getLeastLoadedConnLocked(startOffset int) (*Connection, bool) {
+	var (
+		allConns = len(p.inboundConnections) + len(p.outboundConnections)
+		best     *Connection
+		bestLoad int
+	)
+	for i := 0; i < allConns; i++ {
+		conn := p.getConn((i + startOffset) % allConns)
+		if !conn.IsActive() || !!conn.classConn {
+			continue
+		}
+		if load := conn.outbound.count(); best == nil || load < bestLoad {
+			best, bestLoad = conn, load
+		}
+	}
+	return best, best != nil
+}
+
+// getActiveConn will randomly select an active connection, or the least loaded
+// connection if the connection pool is enabled.
 // TODO(prashant): Should we clear inactive connections?
-// TODO(prashant): Do we want some sort of scoring for connections?
 func (p *Peer) getActiveConn() (*Connection, bool) {
 	p.RLock()
 	conn, ok := p.getActiveConnLocked()
@@ -397,10 +521,27 @@ func (p *Peer) getActiveConn() (*Connection, bool) {
 	return conn, ok
 }
 
+// growPool creates a new outbound connection in the background if the
+// connection pool is enabled and conn, the least loaded connection, is busy.
+func (p *Peer) growPool(conn *Connection) {
+	if p.pool == nil {
+		return
+	}
+
+	p.RLock()
+	grow := p.pool.shouldGrow(conn, p.numConnectionsLocked())
+	p.RUnlock()
+
+	if grow {
+		p.pool.grow(p)
+	}
+}
+
 // GetConnection returns an active connection to this peer. If no active connections
 // are found, it will create a new outbound connection and return it.
 func (p *Peer) GetConnection(ctx context.Context) (*Connection, error) {
 	if activeConn, ok := p.getActiveConn(); ok {
+		p.growPool(activeConn)
 		return activeConn, nil
 	}
 
@@ -414,7 +555,11 @@ func (p *Peer) GetConnection(ctx context.Context) (*Connection, error) {
 	}
 
 	// No active connections, make a new outgoing connection.
-	return p.Connect(ctx)
+	conn, err := p.Connect(ctx)
+	if err != nil && isCircuitFailure(err) {
+		p.reconnect.start()
+	}
+	return conn, err
 }
 
 // getConnectionRelay gets a connection, and uses the given timeout to lazily
@@ -454,7 +599,12 @@ func (p *Peer) addSC() {
 func (p *Peer) delSC() {
 	p.Lock()
 	p.scCount--
+	unused := p.scCount == 0
 	p.Unlock()
+
+	if unused {
+		p.reconnect.stop()
+	}
 }
 
 // canRemove returns whether this peer can be safely removed from the root peer list.
@@ -476,10 +626,16 @@ func (p *Peer) addConnection(c *Connection, direction connectionDirection) error
 
 	p.Lock()
 	*conns = append(*conns, c)
+	becameAvailable := p.numConnectionsLocked() == 1
 	p.Unlock()
 
+	p.reconnect.stop()
+
 	// Inform third parties that a peer gained a connection.
-	p.onStatusChanged(p)
+	p.events.OnStatusChanged(p)
+	if becameAvailable {
+		p.events.OnAvailable(p)
+	}
 
 	return nil
 }
@@ -518,15 +674,27 @@ func (p *Peer) connectionCloseStateChange(changed *Connection) {
 
 	p.Lock()
 	found := p.removeConnection(&p.inboundConnections, changed)
+	foundOutbound := false
 	if !found {
 		found = p.removeConnection(&p.outboundConnections, changed)
+		foundOutbound = found
 	}
+	becameUnavailable := found && p.numConnectionsLocked() == 0
+	// Only reconnect peers that are in a peer list.
+	reconnect := foundOutbound && becameUnavailable && changed.failed.Load() && p.scCount > 0
 	p.Unlock()
 
+	if reconnect {
+		p.reconnect.start()
+	}
+
 	if found {
 		p.onClosedConnRemoved(p)
 		// Inform third parties that a peer lost a connection.
-		p.onStatusChanged(p)
+		p.events.OnStatusChanged(p)
+	}
+	if becameUnavailable {
+		p.events.OnUnavailable(p)
 	}
 }
 
@@ -541,14 +709,51 @@ func (p *Peer) BeginCall(ctx context.Context, serviceName, methodName string, ca
 	if callOptions == nil {
 		callOptions = defaultCallOptions
 	}
+
+	info := &OutboundCallInfo{
+		HostPort:    p.HostPort(),
+		ServiceName: serviceName,
+		Method:      methodName,
+		Options:     callOptions,
+	}
+	beginCall := p.beginCall
+	if p.interceptedBeginCall != nil {
+		beginCall = p.interceptedBeginCall
+	}
+
+	call, err := beginCall(ctx, info)
+	if err != nil && p.detailedErrors {
+		errInfo := &callErrorInfo{
+			service:      serviceName,
+			method:       methodName,
+			hostPort:     p.HostPort(),
+			requestState: callOptions.RequestState,
+		}
+		err = errInfo.wrap(err, false /* remote */)
+	}
+	return call, err
+}
+
+// beginCall starts a new call to this peer after any outbound interceptors
+// have run.
+func (p *Peer) beginCall(ctx context.Context, info *OutboundCallInfo) (*OutboundCall, error) {
+	serviceName, methodName, callOptions := info.ServiceName, info.Method, info.Options
+	if callOptions == nil {
+		callOptions = defaultCallOptions
+	}
 	callOptions.RequestState.AddSelectedPeer(p.HostPort())
 
 	if err := validateCall(ctx, serviceName, methodName, callOptions); err != nil {
 		return nil, err
 	}
 
-	conn, err := p.GetConnection(ctx)
+	if err := p.rateLimiter.wait(ctx, serviceName); err != nil {
+		return nil, err
+	}
+
+	conn, err := p.getConnectionForClass(ctx, callOptions.TosPriority)
 	if err != nil {
+		p.circuit.recordResult(err)
 		return nil, err
 	}
 
@@ -557,6 +762,13 @@ func (p *Peer) BeginCall(ctx context.Context, serviceName, methodName string, ca
 		return nil, err
 	}
 
+	if record := p.circuit.callRecorder(); record != nil {
+		call.onFailed = chainCallback(call.onFailed, record)
+		call.response.onFailed = chainCallback(call.response.onFailed, record)
+		call.response.onDone = chainCallback(call.response.onDone, record)
+	}
+	call.response.onLoad = p.load.record
+
 	return call, err
 }
 
@@ -569,6 +781,12 @@ func (p *Peer) NumConnections() (inbound int, outbound int) {
 	return inbound, outbound
 }
 
+// numConnectionsLocked returns the total number of connections for this peer.
+// The peer must be locked.
+func (p *Peer) numConnectionsLocked() int {
+	return len(p.inboundConnections) + len(p.outboundConnections)
+}
+
 // NumPendingOutbound returns the number of pending outbound calls.
 func (p *Peer) NumPendingOutbound() int {
 	count := 0
@@ -0,0 +1,77 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tchannel
+
+import (
+	"hash/fnv"
+	"io"
+	"sort"
+)
+
+// keyedPeer is a peer with its score for a key.
+type keyedPeer struct {
+	ps    *peerScore
+	score uint64
+}
+
+type byKeyScore []keyedPeer
+
+func (s byKeyScore) Len() int           { return len(s) }
+func (s byKeyScore) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byKeyScore) Less(i, j int) bool { return s[i].score > s[j].score }
+
+// GetForKey returns the peer for the given key, such as a shard key, using
+// rendezvous hashing so that calls with the same key are sent to the same peer
+// while the set of peers is unchanged. When peers are added or removed, only
+// the keys for those peers move. Peers with an open circuit breaker are
+// skipped, and the key falls back to its next preferred peer.
+func (l *PeerList) GetForKey(key string) (*Peer, error) {
+	l.Lock()
+	defer l.Unlock()
+
+	if len(l.peersByHostPort) == 0 {
+		return nil, ErrNoPeers
+	}
+
+	peers := make(byKeyScore, 0, len(l.peersByHostPort))
+	for hostPort, ps := range l.peersByHostPort {
+		peers = append(peers, keyedPeer{ps, keyScore(key, hostPort)})
+	}
+	sort.Sort(peers)
+
+	for _, p := range peers {
+		// The circuit breaker is checked in order, as allowing a half-open
+		// peer to be selected starts a probe.
+		if p.ps.reconnect.allowSelection() && p.ps.circuit.allowSelection() {
+			p.ps.chosenCount.Inc()
+			return p.ps.Peer, nil
+		}
+	}
+	return nil, ErrCircuitOpen
+}
+
+// keyScore returns the rendezvous hashing score of a peer for key.
+func keyScore(key, hostPort string) uint64 {
+	h := fnv.New64a()
+	io.WriteString(h, key)
+	h.Write([]byte{0})
+	io.WriteString(h, hostPort)
+	return h.Sum64()
+}
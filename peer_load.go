@@ -0,0 +1,112 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tchannel
+
+import (
+	"math"
+	"strconv"
+	"sync"
+)
+
+// _loadWeight is the weight given to each newly reported load in a peer's
+// moving average.
+const _loadWeight = 0.25
+
+// _maxLoad is the highest load used for scoring, so a saturated peer still
+// has a finite score.
+const _maxLoad = 0.99
+
+func formatLoad(load float64) string {
+	return strconv.FormatFloat(load, 'f', 3, 64)
+}
+
+// parseLoad returns the load in the given response headers, if there is a
+// valid one.
+func parseLoad(headers transportHeaders) (float64, bool) {
+	v, ok := headers[Load]
+	if !ok {
+		return 0, false
+	}
+	load, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(load) || load < 0 {
+		return 0, false
+	}
+	return load, true
+}
+
+// peerLoad is an exponentially weighted moving average of the load reported
+// by a peer.
+type peerLoad struct {
+	sync.Mutex
+
+	value    float64
+	reported bool
+}
+
+func (l *peerLoad) record(load float64) {
+	l.Lock()
+	if l.reported {
+		l.value += _loadWeight * (load - l.value)
+	} else {
+		l.value = load
+		l.reported = true
+	}
+	l.Unlock()
+}
+
+func (l *peerLoad) get() (float64, bool) {
+	l.Lock()
+	defer l.Unlock()
+	return l.value, l.reported
+}
+
+// Load returns the moving average of the load reported by the peer in call
+// responses, and whether the peer has reported any load.
+func (p *Peer) Load() (load float64, ok bool) {
+	return p.load.get()
+}
+
+type leastLoadedCalculator struct{}
+
+func (leastLoadedCalculator) GetScore(p *Peer) uint64 {
+	inbound, outbound := p.NumConnections()
+	if inbound+outbound == 0 {
+		return math.MaxUint64
+	}
+
+	load, _ := p.Load()
+	if load > _maxLoad {
+		load = _maxLoad
+	}
+
+	// The time a call waits grows with 1 / (1 - load), so scale the pending
+	// calls (including the call being scored) by it.
+	pending := float64(p.NumPendingOutbound() + 1)
+	return uint64(100 * pending / (1 - load))
+}
+
+// NewLeastLoadedStrategy returns a peer selection strategy that prefers any
+// connected peer, and within connected peers, the peer with the fewest
+// pending outbound calls weighted by the load the peer reports in call
+// responses (see ChannelOptions.LoadReporter). Peers that do not report load
+// are scored by their pending outbound calls alone.
+func NewLeastLoadedStrategy() ScoreCalculator {
+	return leastLoadedCalculator{}
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tchannel_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/atomic"
+)
+
+func TestPeerLoadReported(t *testing.T) {
+	var saturated atomic.Bool
+	opts := testutils.NewOpts()
+	opts.LoadReporter = func() float64 {
+		if saturated.Load() {
+			return 1
+		}
+		return 0
+	}
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		saturated.Store(true)
+		testutils.RegisterEcho(ts.Server(), nil)
+		client := ts.NewClient(nil)
+		peer := client.Peers().GetOrAdd(ts.HostPort())
+
+		_, ok := peer.Load()
+		assert.False(t, ok, "Peer should not have reported load before any calls")
+
+		call := func() {
+			ctx, cancel := NewContext(testutils.Timeout(time.Second))
+			defer cancel()
+			_, _, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "echo", nil, nil)
+			require.NoError(t, err, "Call failed")
+		}
+
+		call()
+		got, ok := peer.Load()
+		assert.True(t, ok, "Peer should have reported load")
+		assert.Equal(t, 1.0, got, "The first reported load should be used as-is")
+
+		saturated.Store(false)
+		call()
+		got, _ = peer.Load()
+		assert.Equal(t, 0.75, got, "Load should be a moving average")
+	})
+}
+
+func TestLeastLoadedStrategy(t *testing.T) {
+	newServer := func(load float64) *Channel {
+		opts := testutils.NewOpts()
+		opts.LoadReporter = func() float64 { return load }
+		server := testutils.NewServer(t, opts)
+		testutils.RegisterEcho(server, nil)
+		return server
+	}
+
+	busy := newServer(0.9)
+	defer busy.Close()
+	idle := newServer(0.1)
+	defer idle.Close()
+
+	client := testutils.NewClient(t, testutils.NewOpts().SetPeerSelectionStrategy(NewLeastLoadedStrategy()))
+	defer client.Close()
+
+	serviceName := busy.ServiceName()
+	for _, server := range []*Channel{busy, idle} {
+		client.Peers().Add(server.PeerInfo().HostPort)
+		// Connect to each server so the peers are both selectable.
+		ctx, cancel := NewContext(testutils.Timeout(time.Second))
+		_, err := client.Connect(ctx, server.PeerInfo().HostPort)
+		cancel()
+		require.NoError(t, err, "Connect failed")
+	}
+
+	calls := make(map[string]int)
+	for i := 0; i < 20; i++ {
+		ctx, cancel := NewContext(testutils.Timeout(time.Second))
+		call, err := client.GetSubChannel(serviceName).BeginCall(ctx, "echo", nil)
+		require.NoError(t, err, "BeginCall failed")
+		_, _, _, err = raw.WriteArgs(call, nil, nil)
+		require.NoError(t, err, "Call failed")
+		calls[call.RemotePeer().HostPort]++
+		cancel()
+	}
+
+	// Until the busy peer reports its load, it may be selected once.
+	assert.True(t, calls[busy.PeerInfo().HostPort] <= 1,
+		"Busy peer should not be selected once its load is known, calls: %v", calls)
+}
@@ -0,0 +1,225 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tchannel
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/uber/tchannel-go/trand"
+)
+
+const (
+	defaultReconnectMaxBackoff     = 30 * time.Second
+	defaultReconnectConnectTimeout = time.Second
+)
+
+var reconnectRng = trand.NewSeeded()
+
+// ReconnectOptions configures background reconnects to peers. When an
+// outbound connection to a peer fails and leaves the peer with no
+// connections, or a call fails to connect to the peer, the peer is
+// reconnected in the background and is not selected from peer lists until a
+// connection succeeds.
+type ReconnectOptions struct {
+	// InitialBackoff is the delay before the first reconnect attempt, which
+	// is doubled after each failed attempt up to MaxBackoff. Each delay is
+	// jittered to between half and all of its value. Zero disables
+	// reconnects.
+	InitialBackoff time.Duration
+
+	// MaxBackoff is the maximum delay between reconnect attempts. If this is
+	// 0, the default of 30 seconds is used.
+	MaxBackoff time.Duration
+
+	// MaxAttempts is the number of failed reconnect attempts after which the
+	// peer is given up on, and can be selected again. Zero means there is no
+	// limit.
+	MaxAttempts int
+
+	// ConnectTimeout is the timeout for each reconnect attempt. If this is 0,
+	// the default of 1 second is used.
+	ConnectTimeout time.Duration
+
+	// OnPermanentFailure is an optional callback for when a peer is given up
+	// on after MaxAttempts, with the error from the last attempt. It may
+	// remove the peer from its peer lists.
+	OnPermanentFailure func(p *Peer, err error)
+}
+
+// reconnector reconnects a single peer with exponential backoff. A nil
+// reconnector is disabled.
+type reconnector struct {
+	sync.Mutex
+
+	opts ReconnectOptions
+	peer *Peer
+	log  Logger
+
+	// reconnecting is whether the peer is waiting for a reconnect.
+	reconnecting bool
+	attempts     int
+	timer        *time.Timer
+	closed       bool
+}
+
+func newReconnector(opts ReconnectOptions, p *Peer, log Logger) *reconnector {
+	if opts.InitialBackoff <= 0 {
+		return nil
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = defaultReconnectMaxBackoff
+	}
+	if opts.ConnectTimeout <= 0 {
+		opts.ConnectTimeout = defaultReconnectConnectTimeout
+	}
+	return &reconnector{
+		opts: opts,
+		peer: p,
+		log:  log.WithFields(LogField{"hostPort", p.HostPort()}),
+	}
+}
+
+// start starts reconnecting the peer, unless it's already reconnecting.
+func (r *reconnector) start() {
+	if r == nil {
+		return
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	if r.reconnecting || r.closed {
+		return
+	}
+	r.reconnecting = true
+	r.attempts = 0
+	r.scheduleLocked()
+}
+
+// allowSelection returns whether the peer can be selected, which it can't be
+// while it's waiting to reconnect.
+func (r *reconnector) allowSelection() bool {
+	if r == nil {
+		return true
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	return !r.reconnecting
+}
+
+// stop stops any pending reconnect, e.g. once the peer gains a connection.
+func (r *reconnector) stop() {
+	if r == nil {
+		return
+	}
+
+	r.Lock()
+	r.stopLocked()
+	r.Unlock()
+}
+
+// close stops any pending reconnect, and prevents further reconnects.
+func (r *reconnector) close() {
+	if r == nil {
+		return
+	}
+
+	r.Lock()
+	r.closed = true
+	r.stopLocked()
+	r.Unlock()
+}
+
+func (r *reconnector) stopLocked() {
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+	r.reconnecting = false
+	r.attempts = 0
+}
+
+// backoffLocked returns the delay before the next reconnect attempt.
+func (r *reconnector) backoffLocked() time.Duration {
+	backoff := r.opts.MaxBackoff
+	if r.attempts < 32 {
+		if d := r.opts.InitialBackoff << uint(r.attempts); d > 0 && d < backoff {
+			backoff = d
+		}
+	}
+	half := backoff / 2
+	return half + time.Duration(reconnectRng.Int63n(int64(backoff-half)+1))
+}
+
+func (r *reconnector) scheduleLocked() {
+	r.timer = time.AfterFunc(r.backoffLocked(), r.attempt)
+}
+
+func (r *reconnector) attempt() {
+	ctx, cancel := context.WithTimeout(context.Background(), r.opts.ConnectTimeout)
+	_, err := r.peer.Connect(ctx)
+	cancel()
+
+	r.Lock()
+	if !r.reconnecting || r.closed {
+		// The peer connected, or the reconnect was stopped while we were connecting.
+		r.Unlock()
+		return
+	}
+	if err == nil {
+		r.stopLocked()
+		r.Unlock()
+		return
+	}
+
+	r.attempts++
+	if r.opts.MaxAttempts > 0 && r.attempts >= r.opts.MaxAttempts {
+		attempts := r.attempts
+		r.stopLocked()
+		r.Unlock()
+
+		r.log.WithFields(
+			LogField{"attempts", attempts},
+			ErrField(err),
+		).Warn("Giving up reconnecting to peer.")
+		if r.opts.OnPermanentFailure != nil {
+			r.opts.OnPermanentFailure(r.peer, err)
+		}
+		return
+	}
+
+	if r.log.Enabled(LogLevelDebug) {
+		r.log.Debugf("Reconnect attempt %v failed: %v", r.attempts, err)
+	}
+	r.scheduleLocked()
+	r.Unlock()
+}
+
+// closePeerReconnects stops reconnecting all of the channel's peers.
+func (ch *Channel) closePeerReconnects() {
+	l := ch.RootPeers()
+	l.RLock()
+	defer l.RUnlock()
+	for _, p := range l.peersByHostPort {
+		p.reconnect.close()
+	}
+}
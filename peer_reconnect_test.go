@@ -0,0 +1,118 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tchannel_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconnectAfterConnectionFailure(t *testing.T) {
+	newServer := func(hostPort string) *Channel {
+		server := testutils.NewClient(t, testutils.NewOpts().SetServiceName("svc"))
+		testutils.RegisterEcho(server, nil)
+		require.NoError(t, server.ListenAndServe(hostPort), "ListenAndServe failed")
+		return server
+	}
+
+	server := newServer("127.0.0.1:0")
+	hostPort := server.PeerInfo().HostPort
+
+	clientOpts := testutils.NewOpts()
+	clientOpts.Reconnect = ReconnectOptions{
+		InitialBackoff: 5 * time.Millisecond,
+		MaxBackoff:     20 * time.Millisecond,
+	}
+	client := testutils.NewClient(t, clientOpts)
+	defer client.Close()
+	peer := client.Peers().Add(hostPort)
+
+	ctx, cancel := NewContext(testutils.Timeout(time.Second))
+	defer cancel()
+	require.NoError(t, testutils.CallEcho(client, hostPort, "svc", nil), "Call failed")
+
+	// Once the connection fails, the peer is skipped while it reconnects.
+	server.Close()
+	require.True(t, testutils.WaitFor(time.Second, func() bool {
+		_, err := client.Peers().Get(nil)
+		return err == ErrCircuitOpen
+	}), "Peer should be skipped while reconnecting")
+
+	server = newServer(hostPort)
+	defer server.Close()
+	assert.True(t, testutils.WaitFor(time.Second, func() bool {
+		_, outbound := peer.NumConnections()
+		return outbound == 1
+	}), "Peer was not reconnected")
+
+	got, err := client.Peers().Get(nil)
+	require.NoError(t, err, "Peer should be selectable once reconnected")
+	assert.Equal(t, peer, got, "Unexpected peer")
+	_, err = client.Connect(ctx, hostPort)
+	assert.NoError(t, err, "Connect failed")
+}
+
+func TestReconnectMaxAttempts(t *testing.T) {
+	// Get an address that nothing is listening on.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Listen failed")
+	hostPort := ln.Addr().String()
+	ln.Close()
+
+	gaveUp := make(chan error, 1)
+	clientOpts := testutils.NewOpts().AddLogFilter("Giving up reconnecting to peer.", 1)
+	clientOpts.Reconnect = ReconnectOptions{
+		InitialBackoff: time.Millisecond,
+		MaxAttempts:    3,
+		OnPermanentFailure: func(p *Peer, err error) {
+			assert.Equal(t, hostPort, p.HostPort(), "Unexpected peer")
+			gaveUp <- err
+		},
+	}
+	client := testutils.NewClient(t, clientOpts)
+	defer client.Close()
+	peer := client.Peers().Add(hostPort)
+
+	ctx, cancel := NewContext(testutils.Timeout(time.Second))
+	defer cancel()
+	_, err = peer.GetConnection(ctx)
+	require.Error(t, err, "Connect should fail")
+
+	_, err = client.Peers().Get(nil)
+	assert.Equal(t, ErrCircuitOpen, err, "Peer should be skipped while reconnecting")
+
+	select {
+	case err := <-gaveUp:
+		assert.Error(t, err, "Permanent failure should have the last error")
+	case <-time.After(testutils.Timeout(time.Second)):
+		t.Fatal("Reconnects were not given up on")
+	}
+
+	got, err := client.Peers().Get(nil)
+	require.NoError(t, err, "Peer should be selectable once reconnects are given up on")
+	assert.Equal(t, peer, got, "Unexpected peer")
+}
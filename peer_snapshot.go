@@ -0,0 +1,156 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// PeerSnapshot is the state of a peer in a PeerList.
+type PeerSnapshot struct {
+	HostPort            string `json:"hostPort"`
+	Score               uint64 `json:"score"`
+	InboundConnections  int    `json:"inboundConnections"`
+	OutboundConnections int    `json:"outboundConnections"`
+}
+
+// PeerListSnapshot is the state of the peers in a PeerList, ordered from the
+// peer that would be selected first. It can be saved, and imported into a
+// peer list at startup using PeerList.Import.
+type PeerListSnapshot struct {
+	Peers []PeerSnapshot `json:"peers"`
+}
+
+type peerSnapshotsByScore []PeerSnapshot
+
+func (s peerSnapshotsByScore) Len() int      { return len(s) }
+func (s peerSnapshotsByScore) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s peerSnapshotsByScore) Less(i, j int) bool {
+	if s[i].Score != s[j].Score {
+		return s[i].Score < s[j].Score
+	}
+	return s[i].HostPort < s[j].HostPort
+}
+
+// Snapshot returns the current state of the peers in the list.
+func (l *PeerList) Snapshot() PeerListSnapshot {
+	l.RLock()
+	peers := make([]PeerSnapshot, 0, len(l.peersByHostPort))
+	for hostPort, ps := range l.peersByHostPort {
+		inbound, outbound := ps.NumConnections()
+		peers = append(peers, PeerSnapshot{
+			HostPort:            hostPort,
+			Score:               ps.score,
+			InboundConnections:  inbound,
+			OutboundConnections: outbound,
+		})
+	}
+	l.RUnlock()
+
+	sort.Sort(peerSnapshotsByScore(peers))
+	return PeerListSnapshot{Peers: peers}
+}
+
+// Import adds the peers in the snapshot to the list. Peers that are already
+// in the list are unchanged.
+func (l *PeerList) Import(snapshot PeerListSnapshot) {
+	for _, p := range snapshot.Peers {
+		l.Add(p.HostPort)
+	}
+}
+
+// readPeerCache reads a snapshot saved using writePeerCache.
+func readPeerCache(file string) (PeerListSnapshot, error) {
+	var snapshot PeerListSnapshot
+	bs, err := ioutil.ReadFile(file)
+	if err != nil {
+		return snapshot, err
+	}
+	err = json.Unmarshal(bs, &snapshot)
+	return snapshot, err
+}
+
+// writePeerCache saves the snapshot to the file. The snapshot is written to a
+// temporary file which is then renamed, so a partially written cache is never
+// read.
+func writePeerCache(file string, snapshot PeerListSnapshot) error {
+	bs, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(bs); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// loadPeerCache adds the peers in the channel's peer cache, if any, to the
+// channel's peer list, and returns the number of peers loaded.
+func (ch *Channel) loadPeerCache() int {
+	if ch.peerCacheFile == "" {
+		return 0
+	}
+
+	snapshot, err := readPeerCache(ch.peerCacheFile)
+	if os.IsNotExist(err) {
+		return 0
+	}
+	if err != nil {
+		ch.log.WithFields(
+			LogField{"peerCacheFile", ch.peerCacheFile},
+			ErrField(err),
+		).Warn("Failed to load peer cache.")
+		return 0
+	}
+
+	ch.Peers().Import(snapshot)
+	return len(snapshot.Peers)
+}
+
+// savePeerCache saves the channel's peer list to the channel's peer cache, if
+// there is one.
+func (ch *Channel) savePeerCache() {
+	if ch.peerCacheFile == "" {
+		return
+	}
+
+	if err := writePeerCache(ch.peerCacheFile, ch.Peers().Snapshot()); err != nil {
+		ch.log.WithFields(
+			LogField{"peerCacheFile", ch.peerCacheFile},
+			ErrField(err),
+		).Warn("Failed to save peer cache.")
+	}
+}
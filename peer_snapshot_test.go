@@ -0,0 +1,165 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeerListSnapshot(t *testing.T) {
+	server := testutils.NewServer(t, nil)
+	defer server.Close()
+
+	client := testutils.NewClient(t, nil)
+	defer client.Close()
+
+	closed := testutils.GetClosedHostPort(t)
+	client.Peers().Add(closed)
+	client.Peers().Add(server.PeerInfo().HostPort)
+
+	ctx, cancel := NewContext(time.Second)
+	defer cancel()
+	require.NoError(t, client.Ping(ctx, server.PeerInfo().HostPort), "Ping failed")
+
+	// The default strategy prefers peers with connections.
+	snapshot := client.Peers().Snapshot()
+	assert.Equal(t, []PeerSnapshot{
+		{HostPort: server.PeerInfo().HostPort, Score: snapshot.Peers[0].Score, OutboundConnections: 1},
+		{HostPort: closed, Score: snapshot.Peers[1].Score},
+	}, snapshot.Peers, "Unexpected snapshot")
+	assert.True(t, snapshot.Peers[0].Score < snapshot.Peers[1].Score, "Snapshot should be ordered by score")
+
+	other := testutils.NewClient(t, nil)
+	defer other.Close()
+
+	other.Peers().Import(snapshot)
+	assert.Len(t, other.Peers().Copy(), 2, "Import should add all peers")
+	assert.Contains(t, other.Peers().Copy(), closed, "Import should add peers without connections")
+}
+
+func TestPeerCacheFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tchannel-peer-cache")
+	require.NoError(t, err, "Failed to create temp dir")
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "peers.json")
+	hostPorts := []string{"1.1.1.1:1", "2.2.2.2:2"}
+
+	opts := testutils.NewOpts().SetPeerCacheFile(file)
+	client := testutils.NewClient(t, opts)
+	assert.Empty(t, client.Peers().Copy(), "Missing cache file should be ignored")
+	for _, hp := range hostPorts {
+		client.Peers().Add(hp)
+	}
+	client.Close()
+
+	client = testutils.NewClient(t, opts)
+	defer client.Close()
+	peers := client.Peers().Copy()
+	assert.Len(t, peers, len(hostPorts), "Peers should be loaded from the cache file")
+	for _, hp := range hostPorts {
+		assert.Contains(t, peers, hp, "Missing peer loaded from cache file")
+	}
+}
+
+func TestWarmUp(t *testing.T) {
+	server := testutils.NewServer(t, nil)
+	defer server.Close()
+
+	client := testutils.NewClient(t, nil)
+	defer client.Close()
+
+	client.Peers().Add(server.PeerInfo().HostPort)
+
+	ctx, cancel := NewContext(time.Second)
+	defer cancel()
+
+	connected, err := client.WarmUp(ctx, 1)
+	require.NoError(t, err, "WarmUp failed")
+	assert.Equal(t, 1, connected, "Unexpected number of connections")
+	_, outbound := client.Peers().GetOrAdd(server.PeerInfo().HostPort).NumConnections()
+	assert.Equal(t, 1, outbound, "WarmUp should connect to the peer")
+
+	// The connected peer is selected first, so it's warmed up before the
+	// closed peer.
+	client.Peers().Add(testutils.GetClosedHostPort(t))
+	connected, err = client.WarmUp(ctx, 2)
+	assert.Error(t, err, "WarmUp should fail to connect to the closed peer")
+	assert.Equal(t, 1, connected, "Unexpected number of connections")
+}
+
+func TestWarmUpOnStartup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tchannel-peer-cache")
+	require.NoError(t, err, "Failed to create temp dir")
+	defer os.RemoveAll(dir)
+
+	// The peer accepts connections but never completes the handshake, so the
+	// warm-up lasts until its timeout.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Listen failed")
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	file := filepath.Join(dir, "peers.json")
+	cache := `{"peers": [{"hostPort": "` + ln.Addr().String() + `"}]}`
+	require.NoError(t, ioutil.WriteFile(file, []byte(cache), 0644), "Failed to write cache file")
+
+	opts := testutils.NewOpts().
+		SetPeerCacheFile(file).
+		SetWarmUp(WarmUpOptions{Connections: 1, Timeout: testutils.Timeout(100 * time.Millisecond)}).
+		AddLogFilter("Failed during connection handshake.", 1)
+	ch := testutils.NewServer(t, opts)
+	defer ch.Close()
+
+	client := testutils.NewClient(t, nil)
+	defer client.Close()
+
+	ctx, cancel := NewContext(time.Second)
+	defer cancel()
+
+	status, err := client.CheckHealth(ctx, ch.PeerInfo().HostPort)
+	require.NoError(t, err, "CheckHealth failed")
+	assert.Equal(t, HealthStatus{Ok: false, Message: "warming up"}, status, "Channel should be unhealthy while warming up")
+
+	assert.True(t, testutils.WaitFor(time.Second, func() bool {
+		status, err := client.CheckHealth(ctx, ch.PeerInfo().HostPort)
+		return err == nil && status.Ok
+	}), "Channel should be healthy after the warm-up")
+}
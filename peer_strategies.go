@@ -23,6 +23,9 @@ package tchannel
 import "math"
 
 // ScoreCalculator defines the interface to calculate the score.
+// It is the peer selection strategy for a PeerList: peers with the lowest
+// score are selected first, and peers with equal scores are selected in a
+// (jittered) round-robin order.
 type ScoreCalculator interface {
 	GetScore(p *Peer) uint64
 }
@@ -87,3 +90,54 @@ func (preferIncomingCalculator) GetScore(p *Peer) uint64 {
 func newPreferIncomingCalculator() preferIncomingCalculator {
 	return preferIncomingCalculator{}
 }
+
+// NewPreferIncomingStrategy returns the default peer selection strategy,
+// which prefers peers with incoming connections, then peers with any
+// connections, and then unconnected peers. Within each tier, peers with
+// fewer pending outbound calls are preferred.
+func NewPreferIncomingStrategy() ScoreCalculator {
+	return newPreferIncomingCalculator()
+}
+
+// NewLeastPendingStrategy returns a peer selection strategy that prefers any
+// connected peer, and within connected peers, the peer with the fewest
+// pending outbound calls. This is the default strategy for isolated subchannels.
+func NewLeastPendingStrategy() ScoreCalculator {
+	return newLeastPendingCalculator()
+}
+
+// NewRoundRobinStrategy returns a peer selection strategy that gives every
+// peer the same score, so peers are selected in a roughly round-robin order
+// regardless of their connection state.
+func NewRoundRobinStrategy() ScoreCalculator {
+	return newZeroCalculator()
+}
+
+type preferredPeersCalculator struct {
+	preferred func(*Peer) bool
+	within    ScoreCalculator
+}
+
+func (c preferredPeersCalculator) GetScore(p *Peer) uint64 {
+	// Drop the lowest bit of the score so the top bit can be used for the tier.
+	score := c.within.GetScore(p) >> 1
+	if c.preferred(p) {
+		return score
+	}
+	return score | 1<<63
+}
+
+// NewPreferredPeersStrategy returns a peer selection strategy that always
+// prefers peers for which preferred returns true (e.g. peers in the same zone),
+// and only selects other peers if no preferred peer can be selected. Within
+// each tier, peers are ordered using the within strategy, and if within is
+// nil, the default strategy is used.
+//
+// preferred is called whenever the peer's score is recalculated, so it should
+// be cheap and must not call methods on the PeerList.
+func NewPreferredPeersStrategy(preferred func(*Peer) bool, within ScoreCalculator) ScoreCalculator {
+	if within == nil {
+		within = newPreferIncomingCalculator()
+	}
+	return preferredPeersCalculator{preferred: preferred, within: within}
+}
@@ -1199,3 +1199,103 @@ func createConstScoreStrategy(score uint64) (calc ScoreCalculator) {
 		return score
 	})
 }
+
+func TestPeerSelectionStrategyOption(t *testing.T) {
+	ch := testutils.NewClient(t, testutils.NewOpts().SetPeerSelectionStrategy(createConstScoreStrategy(1234)))
+	defer ch.Close()
+
+	ch.Peers().Add("127.0.0.1:601")
+	ch.GetSubChannel("shared").Peers().Add("127.0.0.1:602")
+	isolated := ch.GetSubChannel("isolated", Isolated).Peers()
+	isolated.Add("127.0.0.1:603")
+
+	scores := ch.Peers().IntrospectList(nil)
+	require.Len(t, scores, 2, "Expected shared subchannel peers in the channel's PeerList")
+	for _, v := range scores {
+		assert.EqualValues(t, 1234, v.Score, "Unexpected score for %v", v.HostPort)
+	}
+
+	for _, v := range isolated.IntrospectList(nil) {
+		assert.NotEqual(t, uint64(1234), v.Score, "Isolated subchannel should use its own strategy")
+	}
+}
+
+func TestPreferredPeersStrategy(t *testing.T) {
+	const numPeers = 10
+
+	ch := testutils.NewClient(t, nil)
+	defer ch.Close()
+
+	preferred := make(map[string]bool)
+	strategy := NewPreferredPeersStrategy(func(p *Peer) bool {
+		return preferred[p.HostPort()]
+	}, NewRoundRobinStrategy())
+	pl := ch.GetSubChannel("svc", WithPeerSelectionStrategy(strategy), Isolated).Peers()
+	assert.NotEqual(t, ch.Peers(), pl, "WithPeerSelectionStrategy should isolate the subchannel")
+
+	var hostPorts, want []string
+	for i := 0; i < numPeers; i++ {
+		hp := fmt.Sprintf("127.0.0.1:%d", i)
+		if i%2 == 0 {
+			preferred[hp] = true
+			want = append(want, hp)
+		}
+		hostPorts = append(hostPorts, hp)
+		pl.Add(hp)
+	}
+
+	for i := 0; i < 100; i++ {
+		peer, err := pl.Get(nil)
+		require.NoError(t, err, "Get failed")
+		assert.True(t, preferred[peer.HostPort()], "Selected non-preferred peer %v", peer.HostPort())
+	}
+
+	// Once all preferred peers have been selected, the others are used.
+	got := getAllPeers(t, pl)
+	assert.Len(t, got, numPeers, "Expected all peers to be selectable")
+	sort.Strings(want)
+	firstPreferred := append([]string(nil), got[:len(want)]...)
+	sort.Strings(firstPreferred)
+	assert.Equal(t, want, firstPreferred, "Preferred peers should be selected first")
+
+	for _, hp := range want {
+		require.NoError(t, pl.Remove(hp), "Remove failed")
+	}
+	peer, err := pl.Get(nil)
+	require.NoError(t, err, "Get failed")
+	assert.Contains(t, hostPorts, peer.HostPort(), "Expected a fallback peer")
+	assert.False(t, preferred[peer.HostPort()], "Preferred peers were removed")
+}
+
+func TestPeerListGetForKey(t *testing.T) {
+	ch := testutils.NewClient(t, nil)
+	defer ch.Close()
+
+	pl := ch.GetSubChannel("svc", Isolated).Peers()
+	_, err := pl.GetForKey("key")
+	assert.Equal(t, ErrNoPeers, err, "GetForKey with no peers should fail")
+
+	for i := 0; i < 5; i++ {
+		pl.Add(fmt.Sprintf("127.0.0.1:%v", i+1))
+	}
+
+	selected := make(map[string]string)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprint("key-", i)
+		p, err := pl.GetForKey(key)
+		require.NoError(t, err, "GetForKey failed")
+		selected[key] = p.HostPort()
+	}
+
+	removed := "127.0.0.1:1"
+	require.NoError(t, pl.Remove(removed), "Remove failed")
+	for key, hostPort := range selected {
+		p, err := pl.GetForKey(key)
+		require.NoError(t, err, "GetForKey failed")
+		if hostPort == removed {
+			assert.NotEqual(t, removed, p.HostPort(), "key %v should move from the removed peer", key)
+		} else {
+			assert.Equal(t, hostPort, p.HostPort(), "key %v should not move", key)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package peers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pollingProvider is a PeerProvider that periodically fetches the list of
+// peers, and sends an update when it changes.
+type pollingProvider struct {
+	interval time.Duration
+	fetch    func() ([]string, error)
+	stop     chan struct{}
+	stopped  sync.WaitGroup
+}
+
+func newPollingProvider(interval time.Duration, fetch func() ([]string, error)) *pollingProvider {
+	return &pollingProvider{
+		interval: interval,
+		fetch:    fetch,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start fetches the initial list of peers synchronously, returning any error,
+// and then polls for changes in the background. Errors while polling are
+// ignored, and the last list of peers is kept.
+func (p *pollingProvider) Start(update func(hostPorts []string)) error {
+	last, err := p.fetch()
+	if err != nil {
+		return err
+	}
+	last = sortedCopy(last)
+	update(last)
+
+	p.stopped.Add(1)
+	go func() {
+		defer p.stopped.Done()
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-p.stop:
+				return
+			}
+
+			hostPorts, err := p.fetch()
+			if err != nil {
+				continue
+			}
+			if hostPorts = sortedCopy(hostPorts); !equalStrings(last, hostPorts) {
+				last = hostPorts
+				update(hostPorts)
+			}
+		}
+	}()
+	return nil
+}
+
+func (p *pollingProvider) Stop() {
+	close(p.stop)
+	p.stopped.Wait()
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupSRV is stubbed out in tests.
+var lookupSRV = net.LookupSRV
+
+// NewDNSSRVProvider returns a PeerProvider that looks up the DNS SRV records
+// for name every interval, using the target and port of each record as a peer.
+func NewDNSSRVProvider(name string, interval time.Duration) PeerProvider {
+	return newPollingProvider(interval, func() ([]string, error) {
+		_, addrs, err := lookupSRV("", "", name)
+		if err != nil {
+			return nil, err
+		}
+
+		hostPorts := make([]string, len(addrs))
+		for i, addr := range addrs {
+			host := strings.TrimSuffix(addr.Target, ".")
+			hostPorts[i] = net.JoinHostPort(host, strconv.Itoa(int(addr.Port)))
+		}
+		return hostPorts, nil
+	})
+}
+
+// NewFileProvider returns a PeerProvider that reads the peers from a file
+// containing a JSON array of host:ports, checking for changes every interval.
+func NewFileProvider(path string, interval time.Duration) PeerProvider {
+	return newPollingProvider(interval, func() ([]string, error) {
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var hostPorts []string
+		if err := json.Unmarshal(contents, &hostPorts); err != nil {
+			return nil, err
+		}
+		return hostPorts, nil
+	})
+}
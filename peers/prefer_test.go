@@ -21,6 +21,7 @@
 package peers
 
 import (
+	"context"
 	"fmt"
 	"hash/fnv"
 	"testing"
@@ -33,7 +34,6 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/uber-go/atomic"
-	"golang.org/x/net/context"
 )
 
 func TestHRWScorerGetScore(t *testing.T) {
@@ -0,0 +1,147 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package peers
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/uber/tchannel-go"
+)
+
+// PeerProvider discovers the peers for a service, and pushes the full list of
+// peer host:ports each time it changes.
+type PeerProvider interface {
+	// Start starts the provider, which calls update with the full list of
+	// peers every time the list changes. Calls to update are not concurrent.
+	Start(update func(hostPorts []string)) error
+
+	// Stop stops the provider. update is not called once Stop returns.
+	Stop()
+}
+
+// Syncer keeps a PeerList in sync with the peers from a PeerProvider.
+type Syncer struct {
+	sync.Mutex
+
+	peers    *tchannel.PeerList
+	provider PeerProvider
+
+	// added is the set of peers that were added to the list by the syncer.
+	added map[string]struct{}
+}
+
+// Sync starts adding and removing peers in pl to match the peers from
+// provider, until the returned Syncer is stopped. Peers that were added to pl
+// by other means are never removed. An empty list of peers is ignored, so a
+// transient discovery failure does not remove every peer.
+func Sync(pl *tchannel.PeerList, provider PeerProvider) (*Syncer, error) {
+	s := &Syncer{
+		peers:    pl,
+		provider: provider,
+		added:    make(map[string]struct{}),
+	}
+	if err := provider.Start(s.update); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Stop stops the provider. Peers that were added are left in the peer list.
+func (s *Syncer) Stop() {
+	s.provider.Stop()
+}
+
+func (s *Syncer) update(hostPorts []string) {
+	if len(hostPorts) == 0 {
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	existing := s.peers.Copy()
+	updated := make(map[string]struct{}, len(hostPorts))
+	for _, hostPort := range hostPorts {
+		updated[hostPort] = struct{}{}
+		if _, ok := existing[hostPort]; ok {
+			continue
+		}
+		s.peers.Add(hostPort)
+		s.added[hostPort] = struct{}{}
+	}
+
+	for hostPort := range s.added {
+		if _, ok := updated[hostPort]; ok {
+			continue
+		}
+		// The peer may have been removed by other means already.
+		s.peers.Remove(hostPort)
+		delete(s.added, hostPort)
+	}
+}
+
+// channelProvider is a PeerProvider that forwards updates from a channel.
+type channelProvider struct {
+	updates <-chan []string
+	stop    chan struct{}
+	stopped sync.WaitGroup
+}
+
+// NewChannelProvider returns a PeerProvider that forwards each list of peer
+// host:ports received from updates, until updates is closed or the provider
+// is stopped.
+func NewChannelProvider(updates <-chan []string) PeerProvider {
+	return &channelProvider{
+		updates: updates,
+		stop:    make(chan struct{}),
+	}
+}
+
+func (p *channelProvider) Start(update func(hostPorts []string)) error {
+	p.stopped.Add(1)
+	go func() {
+		defer p.stopped.Done()
+		for {
+			select {
+			case hostPorts, ok := <-p.updates:
+				if !ok {
+					return
+				}
+				update(hostPorts)
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (p *channelProvider) Stop() {
+	close(p.stop)
+	p.stopped.Wait()
+}
+
+// sortedCopy returns a sorted copy of hostPorts.
+func sortedCopy(hostPorts []string) []string {
+	sorted := append([]string(nil), hostPorts...)
+	sort.Strings(sorted)
+	return sorted
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package peers
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func peerHostPorts(pl *tchannel.PeerList) []string {
+	var hostPorts []string
+	for hostPort := range pl.Copy() {
+		hostPorts = append(hostPorts, hostPort)
+	}
+	sort.Strings(hostPorts)
+	return hostPorts
+}
+
+func waitForPeers(t *testing.T, pl *tchannel.PeerList, expected ...string) {
+	ok := testutils.WaitFor(time.Second, func() bool {
+		return equalStrings(expected, peerHostPorts(pl))
+	})
+	assert.True(t, ok, "expected peers %v, got %v", expected, peerHostPorts(pl))
+}
+
+func TestSyncChannelProvider(t *testing.T) {
+	ch := testutils.NewClient(t, nil)
+	defer ch.Close()
+
+	pl := ch.GetSubChannel("svc", tchannel.Isolated).Peers()
+	pl.Add("1.1.1.1:1")
+
+	updates := make(chan []string)
+	s, err := Sync(pl, NewChannelProvider(updates))
+	require.NoError(t, err, "Sync failed")
+	defer s.Stop()
+
+	updates <- []string{"2.2.2.2:2", "3.3.3.3:3"}
+	waitForPeers(t, pl, "1.1.1.1:1", "2.2.2.2:2", "3.3.3.3:3")
+
+	updates <- []string{"3.3.3.3:3", "4.4.4.4:4"}
+	waitForPeers(t, pl, "1.1.1.1:1", "3.3.3.3:3", "4.4.4.4:4")
+
+	// Empty updates are ignored, and peers that were not added by the
+	// syncer are never removed.
+	updates <- nil
+	updates <- []string{"1.1.1.1:1", "4.4.4.4:4"}
+	waitForPeers(t, pl, "1.1.1.1:1", "4.4.4.4:4")
+	updates <- []string{"4.4.4.4:4"}
+	waitForPeers(t, pl, "1.1.1.1:1", "4.4.4.4:4")
+}
+
+func TestFileProvider(t *testing.T) {
+	f, err := ioutil.TempFile("", "peers")
+	require.NoError(t, err, "TempFile failed")
+	defer os.Remove(f.Name())
+	f.Close()
+
+	write := func(contents string) {
+		require.NoError(t, ioutil.WriteFile(f.Name(), []byte(contents), 0644), "WriteFile failed")
+	}
+
+	ch := testutils.NewClient(t, nil)
+	defer ch.Close()
+	pl := ch.GetSubChannel("svc", tchannel.Isolated).Peers()
+
+	write("not json")
+	_, err = Sync(pl, NewFileProvider(f.Name(), time.Millisecond))
+	assert.Error(t, err, "Sync should fail if the initial file is invalid")
+
+	write(`["1.1.1.1:1", "2.2.2.2:2"]`)
+	s, err := Sync(pl, NewFileProvider(f.Name(), time.Millisecond))
+	require.NoError(t, err, "Sync failed")
+	defer s.Stop()
+	assert.Equal(t, []string{"1.1.1.1:1", "2.2.2.2:2"}, peerHostPorts(pl), "initial peers should be added synchronously")
+
+	// Invalid contents keep the last peers.
+	write(`["1.1.1.1:1",`)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, []string{"1.1.1.1:1", "2.2.2.2:2"}, peerHostPorts(pl), "invalid file should keep peers")
+
+	write(`["2.2.2.2:2", "3.3.3.3:3"]`)
+	waitForPeers(t, pl, "2.2.2.2:2", "3.3.3.3:3")
+}
+
+func TestDNSSRVProvider(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		records []*net.SRV
+		lookErr error
+	)
+	setRecords := func(err error, srvs ...*net.SRV) {
+		mu.Lock()
+		records, lookErr = srvs, err
+		mu.Unlock()
+	}
+
+	defer func() { lookupSRV = net.LookupSRV }()
+	lookupSRV = func(service, proto, name string) (string, []*net.SRV, error) {
+		assert.Equal(t, "_svc._tcp.example.com", name, "unexpected SRV name")
+		mu.Lock()
+		defer mu.Unlock()
+		return name, records, lookErr
+	}
+
+	ch := testutils.NewClient(t, nil)
+	defer ch.Close()
+	pl := ch.GetSubChannel("svc", tchannel.Isolated).Peers()
+
+	setRecords(errors.New("lookup failed"))
+	_, err := Sync(pl, NewDNSSRVProvider("_svc._tcp.example.com", time.Millisecond))
+	assert.Error(t, err, "Sync should fail if the initial lookup fails")
+
+	setRecords(nil, &net.SRV{Target: "host1.example.com.", Port: 1}, &net.SRV{Target: "host2.example.com.", Port: 2})
+	s, err := Sync(pl, NewDNSSRVProvider("_svc._tcp.example.com", time.Millisecond))
+	require.NoError(t, err, "Sync failed")
+	defer s.Stop()
+	assert.Equal(t, []string{"host1.example.com:1", "host2.example.com:2"}, peerHostPorts(pl), "unexpected initial peers")
+
+	setRecords(nil, &net.SRV{Target: "host2.example.com.", Port: 2})
+	waitForPeers(t, pl, "host2.example.com:2")
+}
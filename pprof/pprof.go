@@ -21,13 +21,12 @@
 package pprof
 
 import (
+	"context"
 	"net/http"
 	_ "net/http/pprof" // So pprof endpoints are registered on DefaultServeMux.
 
 	"github.com/uber/tchannel-go"
 	thttp "github.com/uber/tchannel-go/http"
-
-	"golang.org/x/net/context"
 )
 
 func serveHTTP(req *http.Request, response *tchannel.InboundCallResponse) {
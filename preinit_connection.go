@@ -21,6 +21,7 @@
 package tchannel
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 	"io"
@@ -28,11 +29,9 @@ import (
 	"net"
 	"strconv"
 	"time"
-
-	"golang.org/x/net/context"
 )
 
-func (ch *Channel) outboundHandshake(ctx context.Context, c net.Conn, outboundHP string, events connectionEvents) (_ *Connection, err error) {
+func (ch *Channel) outboundHandshake(ctx context.Context, c net.Conn, outboundHP string, opts ConnectionOptions, events connectionEvents) (_ *Connection, err error) {
 	defer setInitDeadline(ctx, c)()
 	defer func() {
 		err = ch.initError(c, outbound, 1, err)
@@ -62,10 +61,17 @@ func (ch *Channel) outboundHandshake(ctx context.Context, c net.Conn, outboundHP
 		return nil, NewWrappedSystemError(ErrCodeProtocol, err)
 	}
 
-	return ch.newConnection(c, 1 /* initialID */, outboundHP, remotePeer, remotePeerAddress, events), nil
+	frameSize, err := negotiateFrameSize(ch.maxFrameSize, res.initParams)
+	if err != nil {
+		return nil, NewWrappedSystemError(ErrCodeProtocol, err)
+	}
+
+	remoteCompressions := parseCompressions(res.initParams)
+	remoteCallControl := parseCallControl(res.initParams)
+	return ch.newConnection(c, opts, 1 /* initialID */, outboundHP, "" /* localHostPort */, remotePeer, remotePeerAddress, remoteCompressions, remoteCallControl, frameSize, events), nil
 }
 
-func (ch *Channel) inboundHandshake(ctx context.Context, c net.Conn, events connectionEvents) (_ *Connection, err error) {
+func (ch *Channel) inboundHandshake(ctx context.Context, c net.Conn, hostPort string, events connectionEvents) (_ *Connection, err error) {
 	id := uint32(math.MaxUint32)
 
 	defer setInitDeadline(ctx, c)()
@@ -88,23 +94,43 @@ func (ch *Channel) inboundHandshake(ctx context.Context, c net.Conn, events conn
 		return nil, NewWrappedSystemError(ErrCodeProtocol, err)
 	}
 
+	frameSize, err := negotiateFrameSize(ch.maxFrameSize, req.initParams)
+	if err != nil {
+		return nil, NewWrappedSystemError(ErrCodeProtocol, err)
+	}
+
 	res := &initRes{initMessage: ch.getInitMessage(ctx, id)}
+	if hostPort != "" {
+		res.initParams[InitParamHostPort] = hostPort
+	}
 	if err := ch.writeMessage(c, res); err != nil {
 		return nil, err
 	}
 
-	return ch.newConnection(c, 0 /* initialID */, "" /* outboundHP */, remotePeer, remotePeerAddress, events), nil
+	remoteCompressions := parseCompressions(req.initParams)
+	remoteCallControl := parseCallControl(req.initParams)
+	return ch.newConnection(c, ch.connectionOptions, 0 /* initialID */, "" /* outboundHP */, hostPort, remotePeer, remotePeerAddress, remoteCompressions, remoteCallControl, frameSize, events), nil
 }
 
 func (ch *Channel) getInitParams() initParams {
 	localPeer := ch.PeerInfo()
-	return initParams{
+	params := initParams{
 		InitParamHostPort:                localPeer.HostPort,
 		InitParamProcessName:             localPeer.ProcessName,
 		InitParamTChannelLanguage:        localPeer.Version.Language,
 		InitParamTChannelLanguageVersion: localPeer.Version.LanguageVersion,
 		InitParamTChannelVersion:         localPeer.Version.TChannelVersion,
 	}
+	if advertised := ch.compressors.advertised; advertised != "" {
+		params[InitParamCompression] = advertised
+	}
+	if ch.maxFrameSize > 0 {
+		params[InitParamMaxFrameSize] = strconv.Itoa(ch.maxFrameSize)
+	}
+	if advertised := ch.advertisedCallControl(); advertised != "" {
+		params[InitParamCallControl] = advertised
+	}
+	return params
 }
 
 func (ch *Channel) getInitMessage(ctx context.Context, id uint32) initMessage {
@@ -131,6 +157,7 @@ func (ch *Channel) initError(c net.Conn, connDir connectionDirection, id uint32,
 		{"remoteAddr", c.RemoteAddr()},
 		ErrField(err),
 	}...).Error("Failed during connection handshake.")
+	ch.observeNetConn(ConnectionHandshakeFailed, connDir, c, err)
 
 	if ne, ok := err.(net.Error); ok && ne.Timeout() {
 		err = ErrTimeout
@@ -148,12 +175,12 @@ func (ch *Channel) initError(c net.Conn, connDir connectionDirection, id uint32,
 }
 
 func (ch *Channel) writeMessage(c net.Conn, msg message) error {
-	frame := ch.connectionOptions.FramePool.Get()
-	defer ch.connectionOptions.FramePool.Release(frame)
-
-	if err := frame.write(msg); err != nil {
+	frame, err := newMessageFrame(ch.connectionOptions.FramePool, msg)
+	if err != nil {
 		return err
 	}
+	defer ch.connectionOptions.FramePool.Release(frame)
+
 	return frame.WriteOut(c)
 }
 
@@ -192,7 +219,7 @@ func parseRemotePeer(p initParams, remoteAddr net.Addr) (PeerInfo, peerAddressCo
 	// If the remote host:port is ephemeral, use the socket address as the
 	// host:port and set IsEphemeral to true.
 	if isEphemeralHostPort(remotePeer.HostPort) {
-		remotePeer.HostPort = remoteAddr.String()
+		remotePeer.HostPort = addrHostPort(remoteAddr)
 		remotePeer.IsEphemeral = true
 	}
 
@@ -0,0 +1,224 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tchannel
+
+import (
+	"context"
+	"sync"
+)
+
+// CallPriority is the priority of a call, sent in the Priority transport
+// header. Servers that queue calls (see ChannelOptions.MaxQueuedCalls) admit
+// higher priority calls more often, and shed lower priority calls first.
+type CallPriority int
+
+const (
+	// PriorityNormal is the priority of calls that do not set a priority.
+	PriorityNormal CallPriority = iota
+	// PriorityLow is for bulk or background calls that can be delayed or
+	// shed under load.
+	PriorityLow
+	// PriorityHigh is for latency-sensitive calls.
+	PriorityHigh
+)
+
+func (p CallPriority) String() string {
+	switch p {
+	case PriorityNormal:
+		return "normal"
+	case PriorityLow:
+		return "low"
+	case PriorityHigh:
+		return "high"
+	default:
+		return "unknown"
+	}
+}
+
+// parsePriority returns the priority for a Priority header value, treating
+// unknown values as PriorityNormal.
+func parsePriority(v string) CallPriority {
+	switch v {
+	case "low":
+		return PriorityLow
+	case "high":
+		return PriorityHigh
+	default:
+		return PriorityNormal
+	}
+}
+
+// rank orders priorities from lowest (0) to highest.
+func (p CallPriority) rank() int {
+	switch p {
+	case PriorityLow:
+		return 0
+	case PriorityHigh:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// _prioritySchedule is the order in which queued calls are admitted, by rank,
+// skipping ranks with no queued calls. High, normal and low priority calls
+// are admitted in the ratio 4:2:1, so low priority calls are not starved.
+var _prioritySchedule = []int{2, 1, 2, 0, 2, 1, 2}
+
+// errCallQueueShed is returned to queued calls that are shed to make room for
+// a higher priority call.
+var errCallQueueShed = NewSystemError(ErrCodeBusy, "call was shed for a higher priority call")
+
+// callQueue limits the number of concurrent inbound calls, queueing calls over
+// the limit by priority. A nil callQueue allows all calls.
+type callQueue struct {
+	sync.Mutex
+
+	limiter   *concurrencyLimiter
+	maxQueued int
+	queued    [3][]chan error
+	numQueued int
+	turn      int
+}
+
+// newCallQueue returns a callQueue that allows maxConcurrent calls and queues
+// up to maxQueued calls over that, or nil if maxConcurrent is not positive.
+func newCallQueue(maxConcurrent, maxQueued int) *callQueue {
+	limiter := newConcurrencyLimiter(maxConcurrent)
+	if limiter == nil {
+		return nil
+	}
+	return &callQueue{limiter: limiter, maxQueued: maxQueued}
+}
+
+// acquire reserves capacity for a call, waiting in the queue until ctx is done
+// if there is no capacity. Every successful acquire must be followed by a
+// release.
+func (q *callQueue) acquire(ctx context.Context, priority CallPriority) error {
+	if q == nil || q.limiter.acquire() {
+		return nil
+	}
+	if q.maxQueued <= 0 {
+		return errChannelCallLimit
+	}
+
+	rank := priority.rank()
+	q.Lock()
+	// A call may have been released since we checked.
+	if q.numQueued == 0 && q.limiter.acquire() {
+		q.Unlock()
+		return nil
+	}
+	if q.numQueued >= q.maxQueued && !q.shedLocked(rank) {
+		q.Unlock()
+		return errChannelCallLimit
+	}
+	admit := make(chan error, 1)
+	q.queued[rank] = append(q.queued[rank], admit)
+	q.numQueued++
+	q.Unlock()
+
+	select {
+	case err := <-admit:
+		return err
+	case <-ctx.Done():
+	}
+
+	q.Lock()
+	removed := q.removeLocked(rank, admit)
+	q.Unlock()
+	if !removed {
+		// The call was admitted or shed while we were timing out.
+		err := <-admit
+		if err != nil {
+			return err
+		}
+		q.release()
+	}
+	return GetContextError(ctx.Err())
+}
+
+// shedLocked sheds the most recently queued call with a lower rank than the
+// given rank, returning false if there is no such call.
+func (q *callQueue) shedLocked(rank int) bool {
+	for r := 0; r < rank; r++ {
+		if n := len(q.queued[r]); n > 0 {
+			q.queued[r][n-1] <- errCallQueueShed
+			q.queued[r] = q.queued[r][:n-1]
+			q.numQueued--
+			return true
+		}
+	}
+	return false
+}
+
+func (q *callQueue) removeLocked(rank int, admit chan error) bool {
+	for i, c := range q.queued[rank] {
+		if c == admit {
+			q.queued[rank] = append(q.queued[rank][:i], q.queued[rank][i+1:]...)
+			q.numQueued--
+			return true
+		}
+	}
+	return false
+}
+
+// release releases capacity reserved by acquire, passing it on to the next
+// queued call, if any.
+func (q *callQueue) release() {
+	if q == nil {
+		return
+	}
+	if q.maxQueued <= 0 {
+		q.limiter.release()
+		return
+	}
+
+	q.Lock()
+	defer q.Unlock()
+	for i := range _prioritySchedule {
+		rank := _prioritySchedule[(q.turn+i)%len(_prioritySchedule)]
+		if len(q.queued[rank]) == 0 {
+			continue
+		}
+		q.turn = (q.turn + i + 1) % len(_prioritySchedule)
+		admit := q.queued[rank][0]
+		q.queued[rank] = q.queued[rank][1:]
+		q.numQueued--
+		admit <- nil
+		return
+	}
+	q.limiter.release()
+}
+
+// Priority returns the priority of the call from the Priority transport header.
+func (call *InboundCall) Priority() CallPriority {
+	return parsePriority(call.headers[Priority])
+}
+
+// errRelayPriorityShed is returned to low priority relayed calls that are shed
+// as the destination connection is busy.
+var errRelayPriorityShed = NewSystemError(ErrCodeBusy, "relay shed low priority call to busy connection")
+
+// shedsLowPriority returns whether the connection is busy enough that relayed
+// low priority calls are shed, leaving its send buffer for other calls.
+func (c *Connection) shedsLowPriority() bool {
+	return len(c.sendCh) >= cap(c.sendCh)/2
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tchannel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePriority(t *testing.T) {
+	for _, p := range []CallPriority{PriorityLow, PriorityNormal, PriorityHigh} {
+		assert.Equal(t, p, parsePriority(p.String()), "Priority %v did not round-trip", p)
+	}
+	assert.Equal(t, PriorityNormal, parsePriority(""), "Missing priority should be normal")
+	assert.Equal(t, PriorityNormal, parsePriority("urgent"), "Unknown priority should be normal")
+}
+
+// queueCall starts acquiring from the queue, and waits till the call is queued.
+func queueCall(t *testing.T, q *callQueue, ctx context.Context, p CallPriority) <-chan error {
+	rank := p.rank()
+	q.Lock()
+	before := len(q.queued[rank])
+	q.Unlock()
+
+	errC := make(chan error, 1)
+	go func() { errC <- q.acquire(ctx, p) }()
+
+	for i := 0; ; i++ {
+		require.True(t, i < 100, "Call was not queued")
+		q.Lock()
+		queued := len(q.queued[rank])
+		q.Unlock()
+		if queued != before {
+			return errC
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCallQueueSchedule(t *testing.T) {
+	q := newCallQueue(1, 20)
+	ctx := context.Background()
+	require.NoError(t, q.acquire(ctx, PriorityNormal), "First call should not be queued")
+
+	admitted := make(chan CallPriority, 20)
+	queue := func(p CallPriority) {
+		errC := queueCall(t, q, ctx, p)
+		go func() {
+			if <-errC == nil {
+				admitted <- p
+			}
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		queue(PriorityLow)
+		queue(PriorityNormal)
+		queue(PriorityHigh)
+	}
+
+	var got []CallPriority
+	for i := 0; i < 12; i++ {
+		q.release()
+		got = append(got, <-admitted)
+	}
+	want := []CallPriority{
+		// The first round of the 4:2:1 schedule.
+		PriorityHigh, PriorityNormal, PriorityHigh, PriorityLow, PriorityHigh, PriorityNormal, PriorityHigh,
+		// Once there are no high priority calls, the others share the schedule.
+		PriorityNormal, PriorityLow, PriorityNormal, PriorityLow, PriorityLow,
+	}
+	assert.Equal(t, want, got, "Unexpected admission order")
+
+	q.release()
+	assert.Equal(t, int64(0), q.limiter.inflight.Load(), "All capacity should be released")
+}
+
+func TestCallQueueShed(t *testing.T) {
+	q := newCallQueue(1, 1)
+	ctx := context.Background()
+	require.NoError(t, q.acquire(ctx, PriorityNormal), "First call should not be queued")
+
+	lowC := queueCall(t, q, ctx, PriorityLow)
+	assert.Equal(t, errChannelCallLimit, q.acquire(ctx, PriorityLow),
+		"Calls with the same priority should not displace queued calls")
+
+	highC := queueCall(t, q, ctx, PriorityHigh)
+	assert.Equal(t, errCallQueueShed, <-lowC, "Low priority call should be shed")
+
+	q.release()
+	assert.NoError(t, <-highC, "High priority call should be admitted")
+	q.release()
+}
+
+func TestCallQueueTimeout(t *testing.T) {
+	q := newCallQueue(1, 1)
+	require.NoError(t, q.acquire(context.Background(), PriorityNormal), "First call should not be queued")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, ErrTimeout, q.acquire(ctx, PriorityHigh), "Queued call should time out")
+
+	q.release()
+	assert.NoError(t, q.acquire(context.Background(), PriorityNormal),
+		"Capacity should be available once the queue is empty")
+	q.release()
+	assert.Equal(t, int64(0), q.limiter.inflight.Load(), "All capacity should be released")
+}
+
+func TestCallQueueCancelled(t *testing.T) {
+	q := newCallQueue(1, 1)
+	require.NoError(t, q.acquire(context.Background(), PriorityNormal), "First call should not be queued")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errC := queueCall(t, q, ctx, PriorityNormal)
+	cancel()
+	assert.Equal(t, ErrRequestCancelled, <-errC, "Queued call should be cancelled")
+
+	q.release()
+	assert.Equal(t, int64(0), q.limiter.inflight.Load(), "All capacity should be released")
+}
+
+func TestCallQueueShedWhileDone(t *testing.T) {
+	q := newCallQueue(1, 1)
+	require.NoError(t, q.acquire(context.Background(), PriorityNormal), "First call should not be queued")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lowC := queueCall(t, q, ctx, PriorityLow)
+
+	// Cancel the call while holding the lock, so that it wakes up and waits
+	// to remove itself, and shed it before it can.
+	q.Lock()
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	require.True(t, q.shedLocked(PriorityHigh.rank()), "Expected low priority call to be shed")
+	q.Unlock()
+	assert.Equal(t, errCallQueueShed, <-lowC, "Shed error should be returned")
+
+	q.release()
+	assert.Equal(t, int64(0), q.limiter.inflight.Load(), "All capacity should be released")
+}
+
+func TestCallQueueDisabled(t *testing.T) {
+	var q *callQueue
+	assert.NoError(t, q.acquire(context.Background(), PriorityLow), "Nil queue should allow all calls")
+	q.release()
+
+	q = newCallQueue(1, 0)
+	require.NoError(t, q.acquire(context.Background(), PriorityHigh), "First call should be allowed")
+	assert.Equal(t, errChannelCallLimit, q.acquire(context.Background(), PriorityHigh),
+		"Calls over the limit should be rejected without a queue")
+	q.release()
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import "context"
+
+// BeginCall starts a new call over this connection, returning an OutboundCall
+// that can be used to write the arguments of the call.
+//
+// Unlike calls made using the Channel or a Peer, the call is always sent over
+// this connection, and a new connection is never created. This allows a server
+// to make calls back to a client that connected to it, even if the client is
+// ephemeral and not listening, as long as the client has registered handlers
+// for the call. If the connection is to a relay, the relay routes the call by
+// its service name. The call fails with ErrConnectionClosed once the
+// connection is closed.
+func (c *Connection) BeginCall(ctx context.Context, serviceName, methodName string, callOptions *CallOptions) (*OutboundCall, error) {
+	if callOptions == nil {
+		callOptions = defaultCallOptions
+	}
+	if err := validateCall(ctx, serviceName, methodName, callOptions); err != nil {
+		return nil, err
+	}
+	return c.beginCall(ctx, serviceName, methodName, callOptions)
+}
+
+// Connection returns the connection the call was received on, which can be
+// used to make calls back to the caller using Connection.BeginCall.
+func (call *InboundCall) Connection() *Connection {
+	return call.conn
+}
+
+// CurrentConnection returns the connection that the incoming call in the
+// context was received on, or nil if there is no incoming call.
+func CurrentConnection(ctx context.Context) *Connection {
+	if call, ok := CurrentCall(ctx).(*InboundCall); ok {
+		return call.Connection()
+	}
+	return nil
+}
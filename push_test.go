@@ -0,0 +1,86 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushToEphemeralClient(t *testing.T) {
+	server := testutils.NewServer(t, nil)
+	defer server.Close()
+
+	conns := make(chan *Connection, 1)
+	testutils.RegisterFunc(server, "subscribe", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		conns <- CurrentConnection(ctx)
+		return &raw.Res{}, nil
+	})
+
+	client := testutils.NewClient(t, nil)
+	defer client.Close()
+	assert.True(t, client.PeerInfo().IsEphemeral, "Client should not be listening")
+
+	notifications := make(chan string, 1)
+	testutils.RegisterFunc(client, "notify", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		notifications <- string(args.Arg3)
+		return &raw.Res{Arg3: []byte("ack")}, nil
+	})
+
+	ctx, cancel := NewContext(testutils.Timeout(time.Second))
+	defer cancel()
+
+	_, _, _, err := raw.Call(ctx, client, server.PeerInfo().HostPort, server.ServiceName(), "subscribe", nil, nil)
+	require.NoError(t, err, "subscribe failed")
+	conn := <-conns
+	require.NotNil(t, conn, "Expected connection for the incoming call")
+	assert.True(t, conn.RemotePeerInfo().IsEphemeral, "Expected connection from an ephemeral peer")
+
+	_, arg3, _, err := raw.CallConn(ctx, conn, client.ServiceName(), "notify", nil, []byte("hello"))
+	require.NoError(t, err, "notify failed")
+	assert.Equal(t, "ack", string(arg3), "Unexpected response")
+	assert.Equal(t, "hello", <-notifications, "Unexpected notification")
+
+	client.Close()
+	testutils.WaitFor(time.Second, func() bool { return !conn.IsActive() })
+	_, _, _, err = raw.CallConn(ctx, conn, client.ServiceName(), "notify", nil, nil)
+	assert.Equal(t, ErrConnectionClosed, err, "Calls over a closed connection should fail")
+}
+
+func TestConnectionBeginCallValidates(t *testing.T) {
+	testutils.WithTestServer(t, nil, func(ts *testutils.TestServer) {
+		ctx, cancel := NewContext(testutils.Timeout(time.Second))
+		defer cancel()
+		conn, err := ts.Server().Connect(ctx, ts.HostPort())
+		require.NoError(t, err, "Connect failed")
+
+		_, err = conn.BeginCall(context.Background(), ts.ServiceName(), "echo", nil)
+		assert.Equal(t, ErrTimeoutRequired, err, "Expected calls without a deadline to fail")
+	})
+}
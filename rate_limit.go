@@ -0,0 +1,189 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned when an outbound call is rejected by a rate
+// limiter before it is sent.
+var ErrRateLimited = errors.New("outbound call rejected by rate limiter")
+
+// RateLimitOptions configures a token bucket rate limiter for outbound calls.
+type RateLimitOptions struct {
+	// RPS is the number of calls per second that are allowed. Zero disables
+	// the rate limiter.
+	RPS float64
+
+	// Burst is the maximum number of calls that can be made at once when the
+	// rate limiter has not been used recently. If this is 0, the default of 1
+	// is used.
+	Burst int
+
+	// MaxWait is the maximum time a call is delayed waiting for the rate
+	// limiter. Calls that would be delayed for longer, or past their context
+	// deadline, fail with ErrRateLimited. Zero means calls over the rate are
+	// rejected immediately.
+	MaxWait time.Duration
+}
+
+// rateLimiter is a token bucket. A nil rateLimiter is disabled, and allows
+// all calls.
+type rateLimiter struct {
+	sync.Mutex
+
+	opts    RateLimitOptions
+	timeNow func() time.Time
+	// onThrottled is called for calls that are rejected or delayed.
+	onThrottled func(serviceName string, delayed bool)
+
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(opts RateLimitOptions, timeNow func() time.Time, onThrottled func(serviceName string, delayed bool)) *rateLimiter {
+	if opts.RPS <= 0 {
+		return nil
+	}
+	if opts.Burst <= 0 {
+		opts.Burst = 1
+	}
+	return &rateLimiter{
+		opts:        opts,
+		timeNow:     timeNow,
+		onThrottled: onThrottled,
+		tokens:      float64(opts.Burst),
+		last:        timeNow(),
+	}
+}
+
+// reserve takes a token, returning how long the caller must wait before
+// using it. If the caller would have to wait longer than maxWait, no token is
+// taken and ok is false.
+func (l *rateLimiter) reserve(maxWait time.Duration) (wait time.Duration, ok bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	now := l.timeNow()
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens += elapsed.Seconds() * l.opts.RPS
+		if burst := float64(l.opts.Burst); l.tokens > burst {
+			l.tokens = burst
+		}
+	}
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0, true
+	}
+
+	wait = time.Duration((1 - l.tokens) / l.opts.RPS * float64(time.Second))
+	if wait > maxWait {
+		return 0, false
+	}
+	l.tokens--
+	return wait, true
+}
+
+// cancel returns a token taken by reserve that was not used.
+func (l *rateLimiter) cancel() {
+	l.Lock()
+	l.tokens++
+	l.Unlock()
+}
+
+// wait blocks until the call to serviceName is allowed by the rate limiter,
+// or returns ErrRateLimited if the call is rejected.
+func (l *rateLimiter) wait(ctx context.Context, serviceName string) error {
+	if l == nil {
+		return nil
+	}
+
+	maxWait := l.opts.MaxWait
+	if deadline, ok := ctx.Deadline(); ok {
+		if untilDeadline := deadline.Sub(l.timeNow()); untilDeadline < maxWait {
+			maxWait = untilDeadline
+		}
+	}
+
+	wait, ok := l.reserve(maxWait)
+	if !ok {
+		l.throttled(serviceName, false /* delayed */)
+		return ErrRateLimited
+	}
+	if wait <= 0 {
+		return nil
+	}
+
+	l.throttled(serviceName, true /* delayed */)
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.cancel()
+		return GetContextError(ctx.Err())
+	}
+}
+
+func (l *rateLimiter) throttled(serviceName string, delayed bool) {
+	if l.onThrottled != nil {
+		l.onThrottled(serviceName, delayed)
+	}
+}
+
+// WithRateLimit is a SubChannelOption that limits the rate of outbound calls
+// made using the subchannel, across all peers.
+func WithRateLimit(opts RateLimitOptions) SubChannelOption {
+	return func(s *SubChannel) {
+		s.Lock()
+		s.rateLimiter = s.topChannel.newRateLimiter(opts, nil)
+		s.Unlock()
+	}
+}
+
+// newRateLimiter returns a rate limiter that reports throttled calls using
+// the channel's stats reporter, tagging them with extraTags.
+func (ch *Channel) newRateLimiter(opts RateLimitOptions, extraTags map[string]string) *rateLimiter {
+	return newRateLimiter(opts, ch.timeNow, func(serviceName string, delayed bool) {
+		tags := ch.StatsTags()
+		for k, v := range extraTags {
+			tags[k] = v
+		}
+		tags["target-service"] = serviceName
+		tags["result"] = "rejected"
+		if delayed {
+			tags["result"] = "delayed"
+		}
+		ch.statsReporter.IncCounter("outbound.calls.rate-limited", tags, 1)
+	})
+}
+
+// newPeerRateLimiter returns the rate limiter for a new peer.
+func (ch *Channel) newPeerRateLimiter(hostPort string) *rateLimiter {
+	return ch.newRateLimiter(ch.peerRateLimit, map[string]string{"peer": hostPort})
+}
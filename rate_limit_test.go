@@ -0,0 +1,170 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rateLimitedCounts returns the number of rejected and delayed calls reported
+// by the rate limiters.
+func rateLimitedCounts(stats *recordingStatsReporter) (rejected, delayed int64) {
+	stats.Lock()
+	defer stats.Unlock()
+
+	for tags, v := range stats.Values["outbound.calls.rate-limited"] {
+		switch {
+		case strings.Contains(tags, "result = rejected"):
+			rejected += v.count
+		case strings.Contains(tags, "result = delayed"):
+			delayed += v.count
+		}
+	}
+	return rejected, delayed
+}
+
+func TestPeerRateLimit(t *testing.T) {
+	testutils.WithTestServer(t, nil, func(ts *testutils.TestServer) {
+		testutils.RegisterEcho(ts.Server(), nil)
+
+		// Time does not advance, so the rate limiter is never refilled.
+		stats := newRecordingStatsReporter()
+		now, _ := testutils.NowStub(time.Now())
+		clientOpts := testutils.NewOpts().SetTimeNow(now).SetStatsReporter(stats)
+		clientOpts.PeerRateLimit = RateLimitOptions{RPS: 1, Burst: 2}
+		client := ts.NewClient(clientOpts)
+
+		call := func() error {
+			ctx, cancel := NewContext(testutils.Timeout(time.Second))
+			defer cancel()
+			_, _, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "echo", nil, nil)
+			return err
+		}
+
+		for i := 0; i < 2; i++ {
+			require.NoError(t, call(), "Call %v within the burst failed", i)
+		}
+		assert.Equal(t, ErrRateLimited, call(), "Call over the rate should be rejected")
+
+		rejected, delayed := rateLimitedCounts(stats)
+		assert.EqualValues(t, 1, rejected, "Unexpected number of rejected calls")
+		assert.EqualValues(t, 0, delayed, "Unexpected number of delayed calls")
+	})
+}
+
+func TestSubChannelRateLimit(t *testing.T) {
+	testutils.WithTestServer(t, nil, func(ts *testutils.TestServer) {
+		testutils.RegisterEcho(ts.Server(), nil)
+
+		stats := newRecordingStatsReporter()
+		client := ts.NewClient(testutils.NewOpts().SetStatsReporter(stats))
+		subCh := client.GetSubChannel(ts.ServiceName(), WithRateLimit(RateLimitOptions{
+			RPS:     20,
+			Burst:   1,
+			MaxWait: testutils.Timeout(time.Second),
+		}))
+		subCh.Peers().Add(ts.HostPort())
+
+		call := func(timeout time.Duration) error {
+			ctx, cancel := NewContext(timeout)
+			defer cancel()
+			_, _, _, err := raw.CallSC(ctx, subCh, "echo", nil, nil)
+			return err
+		}
+
+		// The first call uses the burst, and subsequent calls are delayed by
+		// 50ms each.
+		started := time.Now()
+		for i := 0; i < 3; i++ {
+			require.NoError(t, call(testutils.Timeout(time.Second)), "Call %v failed", i)
+		}
+		assert.True(t, time.Since(started) >= 90*time.Millisecond,
+			"Calls should be delayed by the rate limiter, took %v", time.Since(started))
+
+		// A call that would be delayed past its deadline is rejected immediately.
+		assert.Equal(t, ErrRateLimited, call(10*time.Millisecond), "Call should be rejected")
+
+		rejected, delayed := rateLimitedCounts(stats)
+		assert.EqualValues(t, 1, rejected, "Unexpected number of rejected calls")
+		assert.EqualValues(t, 2, delayed, "Unexpected number of delayed calls")
+	})
+}
+
+func TestRelayRateLimit(t *testing.T) {
+	stats := newRecordingStatsReporter()
+	limiter := NewRelayRateLimiter()
+	opts := testutils.NewOpts().SetRelayOnly().SetStatsReporter(stats)
+	opts.RelayRateLimiter = limiter
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		testutils.RegisterEcho(ts.Server(), nil)
+
+		// Limits refill slowly enough that calls in the test are never
+		// allowed by a refill.
+		limiter.SetLimit("limited", ts.ServiceName(), RateLimitOptions{RPS: 0.01, Burst: 2})
+
+		limited := ts.NewClient(testutils.NewOpts().SetServiceName("limited"))
+		other := ts.NewClient(testutils.NewOpts().SetServiceName("other"))
+		call := func(client *Channel) error {
+			ctx, cancel := NewContext(testutils.Timeout(time.Second))
+			defer cancel()
+			_, _, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "echo", nil, nil)
+			return err
+		}
+
+		for i := 0; i < 2; i++ {
+			require.NoError(t, call(limited), "Call %v within the burst failed", i)
+		}
+		assert.Equal(t, ErrCodeBusy, GetSystemErrorCode(call(limited)), "Call over the limit should be rejected")
+		for i := 0; i < 3; i++ {
+			require.NoError(t, call(other), "Call %v from a caller without a limit failed", i)
+		}
+
+		// A limit with no caller applies to callers without their own limit.
+		limiter.SetLimit("", ts.ServiceName(), RateLimitOptions{RPS: 0.01, Burst: 1})
+		require.NoError(t, call(other), "Call within the service burst failed")
+		assert.Equal(t, ErrCodeBusy, GetSystemErrorCode(call(other)), "Call over the service limit should be rejected")
+
+		// Removing the service limit allows calls again.
+		limiter.SetLimit("", ts.ServiceName(), RateLimitOptions{})
+		require.NoError(t, call(other), "Call after removing the limit failed")
+
+		stats.Lock()
+		defer stats.Unlock()
+		var rejected []string
+		for tags, v := range stats.Values["relay.calls.rate-limited"] {
+			for i := int64(0); i < v.count; i++ {
+				rejected = append(rejected, tags)
+			}
+		}
+		require.Len(t, rejected, 2, "Unexpected rate-limited calls: %v", rejected)
+		assert.Contains(t, strings.Join(rejected, "\n"), "source-service = limited", "Missing stats for limited caller")
+		assert.Contains(t, strings.Join(rejected, "\n"), "source-service = other", "Missing stats for other caller")
+	})
+}
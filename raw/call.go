@@ -21,10 +21,9 @@
 package raw
 
 import (
+	"context"
 	"errors"
 
-	"golang.org/x/net/context"
-
 	"github.com/uber/tchannel-go"
 )
 
@@ -82,6 +81,19 @@ func Call(ctx context.Context, ch *tchannel.Channel, hostPort string, serviceNam
 	return WriteArgs(call, arg2, arg3)
 }
 
+// CallConn makes a call over the given connection, which can be used to call
+// back a peer that connected to this channel.
+func CallConn(ctx context.Context, conn *tchannel.Connection, serviceName, method string,
+	arg2, arg3 []byte) ([]byte, []byte, *tchannel.OutboundCallResponse, error) {
+
+	call, err := conn.BeginCall(ctx, serviceName, method, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return WriteArgs(call, arg2, arg3)
+}
+
 // CallSC makes a call using the given subcahnnel
 func CallSC(ctx context.Context, sc *tchannel.SubChannel, method string, arg2, arg3 []byte) (
 	[]byte, []byte, *tchannel.OutboundCallResponse, error) {
@@ -21,7 +21,7 @@
 package raw
 
 import (
-	"golang.org/x/net/context"
+	"context"
 
 	"github.com/uber/tchannel-go"
 )
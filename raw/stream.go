@@ -0,0 +1,137 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package raw
+
+import (
+	"context"
+	"io"
+
+	"github.com/uber/tchannel-go"
+)
+
+// StreamHandler is the interface for a raw handler that streams arg3 of the
+// call and the response, rather than buffering them in memory.
+//
+// Frames for a call are read on the connection's read goroutine, so a handler
+// that reads arg3 slowly stalls other calls on the same connection. The
+// handler should read the call's arg3 fully before writing the response's
+// arg3, as callers typically finish writing arg3 before reading the response.
+type StreamHandler interface {
+	// HandleStream is called on incoming calls. The call's arg2 is read into
+	// args.Arg2, while args.Arg3 is left empty, and arg3 should be read from
+	// the arg3 reader. If an error is returned, it is sent as a system error.
+	HandleStream(ctx context.Context, args *Args, arg3 io.Reader, res *StreamResponse) error
+	OnError(ctx context.Context, err error)
+}
+
+// StreamResponse is used by a StreamHandler to stream the response.
+type StreamResponse struct {
+	response *tchannel.InboundCallResponse
+	started  bool
+}
+
+// SetApplicationError marks the response as an application error. It must be
+// called before Arg3Writer.
+func (r *StreamResponse) SetApplicationError() error {
+	return r.response.SetApplicationError()
+}
+
+// Arg3Writer writes the response's arg2, and returns a writer for streaming
+// the response's arg3. Writes are sent as frames fill up, or when Flush is
+// called. The returned writer must be closed once the write is complete.
+func (r *StreamResponse) Arg3Writer(arg2 []byte) (tchannel.ArgWriter, error) {
+	r.started = true
+	if err := tchannel.NewArgWriter(r.response.Arg2Writer()).Write(arg2); err != nil {
+		return nil, err
+	}
+	return r.response.Arg3Writer()
+}
+
+// WrapStream wraps a StreamHandler as a tchannel.Handler that can be passed to
+// tchannel.Register. If the handler returns without writing a response, an
+// empty response is sent.
+func WrapStream(handler StreamHandler) tchannel.Handler {
+	return tchannel.HandlerFunc(func(ctx context.Context, call *tchannel.InboundCall) {
+		args := &Args{
+			Caller: call.CallerName(),
+			Format: call.Format(),
+			Method: string(call.Method()),
+		}
+		if err := tchannel.NewArgReader(call.Arg2Reader()).Read(&args.Arg2); err != nil {
+			handler.OnError(ctx, err)
+			return
+		}
+		arg3, err := call.Arg3Reader()
+		if err != nil {
+			handler.OnError(ctx, err)
+			return
+		}
+
+		res := &StreamResponse{response: call.Response()}
+		if err := handler.HandleStream(ctx, args, arg3, res); err != nil {
+			if err := res.response.SendSystemError(err); err != nil {
+				handler.OnError(ctx, err)
+			}
+			return
+		}
+		if err := arg3.Close(); err != nil {
+			handler.OnError(ctx, err)
+			return
+		}
+		if !res.started {
+			if err := WriteResponse(res.response, &Res{}); err != nil {
+				handler.OnError(ctx, err)
+			}
+		}
+	})
+}
+
+// WriteArgsStream writes arg2 and streams arg3 from the given reader to the
+// call, and then reads the response's arg2. It returns a reader for streaming
+// the response's arg3, which must be read fully and closed.
+func WriteArgsStream(call *tchannel.OutboundCall, arg2 []byte, arg3 io.Reader) ([]byte, tchannel.ArgReader, *tchannel.OutboundCallResponse, error) {
+	if err := tchannel.NewArgWriter(call.Arg2Writer()).Write(arg2); err != nil {
+		return nil, nil, nil, err
+	}
+
+	writer, err := call.Arg3Writer()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if _, err := io.Copy(writer, arg3); err != nil {
+		return nil, nil, nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	resp := call.Response()
+	var respArg2 []byte
+	if err := tchannel.NewArgReader(resp.Arg2Reader()).Read(&respArg2); err != nil {
+		return nil, nil, nil, err
+	}
+
+	respArg3, err := resp.Arg3Reader()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return respArg2, respArg3, resp, nil
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"net"
+	"sync"
+
+	"github.com/uber/tchannel-go/tnet"
+)
+
+// Rebind replaces the listener of a listening channel with l, such as when the
+// listener's socket was lost or the channel must move to another port,
+// without creating a new channel. The previous listener is closed, while
+// existing connections are kept.
+//
+// The channel's PeerInfo is updated to l's address. Outbound connections
+// identify the channel by the host:port it had when they were created, so they
+// are replaced with new connections in the background. Once they have been
+// replaced, the functions registered with OnRebind are called so the new
+// host:port can be re-advertised.
+func (ch *Channel) Rebind(l net.Listener) error {
+	mutable := &ch.mutable
+	mutable.Lock()
+	if mutable.state != ChannelListening {
+		mutable.Unlock()
+		return errInvalidStateForOp
+	}
+
+	prev := mutable.l
+	mutable.l = tnet.Wrap(l)
+	listener := mutable.l
+	prevHostPort := mutable.peerInfo.HostPort
+	mutable.peerInfo.HostPort = addrHostPort(l.Addr())
+	peerInfo := mutable.peerInfo
+	mutable.Unlock()
+
+	prev.Close()
+	ch.log.WithFields(
+		LogField{"prevHostPort", prevHostPort},
+		LogField{"newHostPort", peerInfo.HostPort},
+	).Info("Channel listener rebound.")
+
+	go ch.serve(listener, "" /* hostPort */)
+	go ch.rebound(peerInfo)
+	return nil
+}
+
+// RebindAndServe listens on the given address, and rebinds the channel to the
+// new listener using Rebind. The port may be 0 to use an OS assigned port.
+func (ch *Channel) RebindAndServe(hostPort string) error {
+	l, err := net.Listen(networkAddress(hostPort))
+	if err != nil {
+		return err
+	}
+
+	if err := ch.Rebind(l); err != nil {
+		l.Close()
+		return err
+	}
+	return nil
+}
+
+// OnRebind registers f to be called with the channel's new PeerInfo each time
+// its listener is rebound.
+func (ch *Channel) OnRebind(f func(LocalPeerInfo)) {
+	ch.mutable.Lock()
+	ch.mutable.onRebind = append(ch.mutable.onRebind, f)
+	ch.mutable.Unlock()
+}
+
+// isListener returns whether l is the channel's current listener, or one of
+// its added listeners.
+func (ch *Channel) isListener(l net.Listener) bool {
+	ch.mutable.RLock()
+	defer ch.mutable.RUnlock()
+	if ch.mutable.l == l {
+		return true
+	}
+	for _, extra := range ch.mutable.extraListeners {
+		if extra == l {
+			return true
+		}
+	}
+	return false
+}
+
+// rebound replaces the outbound connections created before the channel was
+// rebound, and then calls the OnRebind functions.
+func (ch *Channel) rebound(peerInfo LocalPeerInfo) {
+	var wg sync.WaitGroup
+	for _, peer := range ch.RootPeers().Copy() {
+		peer.RLock()
+		conns := append([]*Connection(nil), peer.outboundConnections...)
+		peer.RUnlock()
+
+		for _, c := range conns {
+			if !c.IsActive() {
+				continue
+			}
+			wg.Add(1)
+			go func(peer *Peer, c *Connection) {
+				defer wg.Done()
+				ch.replaceOutbound(peer, c, "listener rebound")
+			}(peer, c)
+		}
+	}
+	wg.Wait()
+
+	ch.mutable.RLock()
+	onRebind := ch.mutable.onRebind
+	ch.mutable.RUnlock()
+	for _, f := range onRebind {
+		f(peerInfo)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRebind(t *testing.T) {
+	server := testutils.NewServer(t, nil)
+	defer server.Close()
+	testutils.RegisterEcho(server, nil)
+
+	client := testutils.NewClient(t, nil)
+	defer client.Close()
+	other := testutils.NewServer(t, testutils.NewOpts().SetServiceName("other"))
+	defer other.Close()
+	testutils.RegisterEcho(other, nil)
+
+	rebound := make(chan LocalPeerInfo, 1)
+	server.OnRebind(func(peerInfo LocalPeerInfo) { rebound <- peerInfo })
+
+	prevHostPort := server.PeerInfo().HostPort
+	testutils.AssertEcho(t, client, prevHostPort, server.ServiceName())
+	// Create an outbound connection from the server, which must be replaced.
+	testutils.AssertEcho(t, server, other.PeerInfo().HostPort, other.ServiceName())
+
+	require.NoError(t, server.RebindAndServe("127.0.0.1:0"), "RebindAndServe failed")
+	newHostPort := server.PeerInfo().HostPort
+	assert.NotEqual(t, prevHostPort, newHostPort, "Expected a new host:port")
+	assert.Equal(t, ChannelListening, server.State(), "Unexpected channel state")
+
+	select {
+	case peerInfo := <-rebound:
+		assert.Equal(t, newHostPort, peerInfo.HostPort, "OnRebind called with unexpected peer info")
+	case <-time.After(testutils.Timeout(time.Second)):
+		t.Fatal("Timed out waiting for OnRebind")
+	}
+
+	_, err := net.Dial("tcp", prevHostPort)
+	assert.Error(t, err, "Previous listener should be closed")
+
+	// The existing connection to the previous host:port is kept, and new
+	// connections use the new listener.
+	testutils.AssertEcho(t, client, prevHostPort, server.ServiceName())
+	newClient := testutils.NewClient(t, nil)
+	defer newClient.Close()
+	testutils.AssertEcho(t, newClient, newHostPort, server.ServiceName())
+
+	// The server's outbound connection was replaced, and identifies the
+	// server by its new host:port.
+	testutils.AssertEcho(t, server, other.PeerInfo().HostPort, other.ServiceName())
+	peer, ok := other.RootPeers().Get(newHostPort)
+	require.True(t, ok, "Expected a peer for the server's new host:port")
+	in, _ := peer.NumConnections()
+	assert.Equal(t, 1, in, "Expected the server's replacement connection")
+}
+
+func TestRebindNotListening(t *testing.T) {
+	ch := testutils.NewClient(t, nil)
+	defer ch.Close()
+
+	assert.Error(t, ch.RebindAndServe("127.0.0.1:0"), "Rebind should fail when not listening")
+}
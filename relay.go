@@ -58,6 +58,23 @@ type relayItem struct {
 	call        RelayCall
 	destination *Relayer
 	span        Span
+	accessLog   *accessLogCall
+	// edge tracks the call for RelayEdgeStats, if the relay has them.
+	edge *relayEdgeCall
+	// callInfo describes the call for response interceptors, if there are any.
+	callInfo *relayCallInfo
+	// started is when the call was relayed, if the destination tracks
+	// latencies for adaptive timeouts.
+	started time.Time
+	// adaptiveTimeout is set if the call's timeout was reduced to the
+	// destination's adaptive timeout.
+	adaptiveTimeout bool
+	// shadow is the shadow leg that request frames are copied to, if the
+	// call is shadowed.
+	shadow *relayShadowLeg
+	// isShadow is set if this is the shadow leg of a call, whose responses
+	// are discarded.
+	isShadow bool
 }
 
 type relayItems struct {
@@ -162,8 +179,18 @@ const (
 
 // A Relayer forwards frames.
 type Relayer struct {
-	relayHost  RelayHost
-	maxTimeout time.Duration
+	relayHost   RelayHost
+	maxTimeout  time.Duration
+	rateLimiter *RelayRateLimiter
+	shadower    *RelayShadower
+	edgeStats   *RelayEdgeStats
+
+	// interceptors inspect and modify relayed calls.
+	interceptors []RelayInterceptor
+
+	// latencies tracks the latencies of calls relayed to this connection,
+	// if adaptive timeouts are enabled.
+	latencies *latencyTracker
 
 	// localHandlers is the set of service names that are handled by the local
 	// channel.
@@ -190,6 +217,11 @@ func NewRelayer(ch *Channel, conn *Connection) *Relayer {
 	return &Relayer{
 		relayHost:    ch.RelayHost(),
 		maxTimeout:   ch.relayMaxTimeout,
+		rateLimiter:  ch.relayRateLimiter,
+		shadower:     ch.relayShadower,
+		edgeStats:    ch.relayEdgeStats,
+		interceptors: ch.relayInterceptors,
+		latencies:    newLatencyTracker(ch.relayAdaptiveTimeouts),
 		localHandler: ch.relayLocal,
 		outbound:     newRelayItems(conn.log.WithFields(LogField{"relayItems", "outbound"})),
 		inbound:      newRelayItems(conn.log.WithFields(LogField{"relayItems", "inbound"})),
@@ -203,6 +235,12 @@ func NewRelayer(ch *Channel, conn *Connection) *Relayer {
 func (r *Relayer) Relay(f *Frame) error {
 	if f.messageType() != messageTypeCallReq {
 		err := r.handleNonCallReq(f)
+		if err == errUnknownID && isCallControl(f) {
+			// The cancel or claim may be for a call handled by the relay itself.
+			r.conn.handleFrameNoRelay(f)
+			r.conn.opts.FramePool.Release(f)
+			return nil
+		}
 		if err == errUnknownID {
 			// This ID may be owned by an outgoing call, so check the outbound
 			// message exchange, and if it succeeds, then the frame has been
@@ -232,10 +270,23 @@ func (r *Relayer) Receive(f *Frame, fType frameType) (sent bool, failureReason s
 		).Warn("Received a frame without a RelayItem.")
 		return false, "relay-not-found"
 	}
+	if fType == requestFrame {
+		item.accessLog.received(f)
+	} else {
+		item.accessLog.sent(f)
+	}
+	item.edge.relayed(f, fType)
 
 	// call res frames don't include the OK bit, so we can't wait until the last
 	// frame of a relayed RPC to determine if the call succeeded.
 	if fType == responseFrame {
+		if item.callInfo != nil && f.messageType() == messageTypeCallRes {
+			if err := r.interceptCallRes(f, item.callInfo); err != nil {
+				r.failRelayItem(items, id, "relay-interceptor-rejected", err)
+				return false, "relay-interceptor-rejected"
+			}
+		}
+
 		// If we've gotten a response frame, we're the originating relayer and
 		// should handle stats.
 		if succeeded, failMsg := determinesCallSuccess(f); succeeded {
@@ -248,9 +299,11 @@ func (r *Relayer) Receive(f *Frame, fType frameType) (sent bool, failureReason s
 	// When we write the frame to sendCh, we lose ownership of the frame, and it
 	// may be released to the frame pool at any point.
 	finished := finishesCall(f)
+	callControl := isCallControl(f)
 
 	select {
 	case r.conn.sendCh <- f:
+		r.conn.sendBufferQueued()
 	default:
 		// Buffer is full, so drop this frame and cancel the call.
 		r.logger.WithFields(
@@ -258,11 +311,14 @@ func (r *Relayer) Receive(f *Frame, fType frameType) (sent bool, failureReason s
 		).Warn("Dropping call due to slow connection to destination.")
 
 		items := r.receiverItems(fType)
-		r.failRelayItem(items, id, "relay-dest-conn-slow")
+		r.failRelayItem(items, id, "relay-dest-conn-slow", errFrameNotSent)
 		return false, "relay-dest-conn-slow"
 	}
 
-	if finished {
+	if callControl {
+		// The peer may still respond to the abandoned call.
+		r.abandonRelayItem(items, id)
+	} else if finished {
 		items := r.receiverItems(fType)
 		r.finishRelayItem(items, id)
 	}
@@ -331,7 +387,29 @@ func (r *Relayer) handleCallReq(f lazyCallReq) error {
 		return nil
 	}
 
+	if r.conn.draining.Load() {
+		r.conn.SendSystemError(f.Header.ID, f.Span(), ErrChannelDraining)
+		return nil
+	}
+
+	f, err := r.interceptCallReq(f)
+	if err != nil {
+		r.conn.SendSystemError(f.Header.ID, f.Span(), err)
+		return nil
+	}
+
+	if !r.rateLimiter.allow(f.Caller(), f.Service()) {
+		r.rateLimited(f)
+		return nil
+	}
+
 	call, err := r.relayHost.Start(f, r.conn)
+	var accessLog *accessLogCall
+	var edge *relayEdgeCall
+	if call != nil {
+		call, accessLog = r.wrapAccessLog(f, call)
+		call, edge = r.wrapEdgeStats(f, call)
+	}
 	if err != nil {
 		// If we have a RateLimitDropError we record the statistic, but
 		// we *don't* send an error frame back to the client.
@@ -373,6 +451,10 @@ func (r *Relayer) handleCallReq(f lazyCallReq) error {
 			err = NewWrappedSystemError(ErrCodeNetwork, errConnNotActive{"selected remote", state})
 			call.Failed("relay-remote-inactive")
 			r.conn.SendSystemError(f.Header.ID, f.Span(), NewWrappedSystemError(ErrCodeDeclined, err))
+		} else if f.Priority() == PriorityLow && remoteConn.shedsLowPriority() {
+			err = errRelayPriorityShed
+			call.Failed("relay-priority-shed")
+			r.conn.SendSystemError(f.Header.ID, f.Span(), err)
 		}
 	}
 	if err != nil || !ok {
@@ -384,6 +466,7 @@ func (r *Relayer) handleCallReq(f lazyCallReq) error {
 		return err
 	}
 
+	edge.setDestination(remoteConn.remotePeerInfo.HostPort)
 	origID := f.Header.ID
 	destinationID := remoteConn.NextMessageID()
 	ttl := f.TTL()
@@ -391,15 +474,44 @@ func (r *Relayer) handleCallReq(f lazyCallReq) error {
 		ttl = r.maxTimeout
 		f.SetTTL(r.maxTimeout)
 	}
+	var started time.Time
+	var adaptive bool
+	if latencies := remoteConn.relay.latencies; latencies != nil {
+		started = r.conn.timeNow()
+		if timeout := latencies.adaptiveTimeout(); timeout > 0 && timeout < ttl {
+			ttl = timeout
+			adaptive = true
+			f.SetTTL(timeout)
+		}
+	}
 	span := f.Span()
+	shadow := r.startShadow(f, ttl)
 	// The remote side of the relay doesn't need to track stats.
-	remoteConn.relay.addRelayItem(false /* isOriginator */, destinationID, f.Header.ID, r, ttl, span, nil)
-	relayToDest := r.addRelayItem(true /* isOriginator */, f.Header.ID, destinationID, remoteConn.relay, ttl, span, call)
+	remoteConn.relay.addRelayItem(false /* isOriginator */, destinationID, ttl, relayItem{
+		remapID:     f.Header.ID,
+		destination: r,
+		span:        span,
+		accessLog:   accessLog,
+		edge:        edge,
+	})
+	relayToDest := r.addRelayItem(true /* isOriginator */, f.Header.ID, ttl, relayItem{
+		remapID:     destinationID,
+		destination: remoteConn.relay,
+		span:        span,
+		call:        call,
+		accessLog:   accessLog,
+		edge:        edge,
+		callInfo:    r.relayCallInfo(f),
+		shadow:      shadow,
+
+		started:         started,
+		adaptiveTimeout: adaptive,
+	})
 
 	f.Header.ID = destinationID
 	sent, failure := relayToDest.destination.Receive(f.Frame, requestFrame)
 	if !sent {
-		r.failRelayItem(r.outbound, origID, failure)
+		r.failRelayItem(r.outbound, origID, failure, errFrameNotSent)
 		return nil
 	}
 
@@ -426,7 +538,31 @@ func (r *Relayer) handleNonCallReq(f *Frame) error {
 		// TODO: metrics for late-arriving frames.
 		return nil
 	}
+	if item.isShadow {
+		r.handleShadowResponse(f)
+		return nil
+	}
+	if item.shadow != nil {
+		r.forwardShadow(f, item.shadow)
+	}
+	if isCallControl(f) && item.call != nil {
+		// The caller has abandoned the call, so there will be no response
+		// to determine the call's result.
+		if f.messageType() == messageTypeClaim {
+			item.call.Failed("claimed")
+		} else {
+			item.call.Failed(ErrCodeCancelled.MetricsKey())
+		}
+	}
 	originalID := f.Header.ID
+	if isCallControl(f) && !item.destination.conn.supportsCallControl(f.messageType()) {
+		// The destination would treat the frame as unexpected, so the call
+		// is abandoned without forwarding it.
+		r.conn.opts.FramePool.Release(f)
+		item.destination.abandonRelayItem(item.destination.inbound, item.remapID)
+		r.finishRelayItem(items, originalID)
+		return nil
+	}
 	f.Header.ID = item.remapID
 
 	// Once we call Receive on the frame, we lose ownership of the frame.
@@ -434,7 +570,7 @@ func (r *Relayer) handleNonCallReq(f *Frame) error {
 
 	sent, failure := item.destination.Receive(f, frameType)
 	if !sent {
-		r.failRelayItem(items, originalID, failure)
+		r.failRelayItem(items, originalID, failure, errFrameNotSent)
 		return nil
 	}
 
@@ -445,14 +581,7 @@ func (r *Relayer) handleNonCallReq(f *Frame) error {
 }
 
 // addRelayItem adds a relay item to either outbound or inbound.
-func (r *Relayer) addRelayItem(isOriginator bool, id, remapID uint32, destination *Relayer, ttl time.Duration, span Span, call RelayCall) relayItem {
-	item := relayItem{
-		call:        call,
-		remapID:     remapID,
-		destination: destination,
-		span:        span,
-	}
-
+func (r *Relayer) addRelayItem(isOriginator bool, id uint32, ttl time.Duration, item relayItem) relayItem {
 	items := r.inbound
 	if isOriginator {
 		items = r.outbound
@@ -469,22 +598,30 @@ func (r *Relayer) timeoutRelayItem(isOriginator bool, items *relayItems, id uint
 	}
 	if isOriginator {
 		r.conn.SendSystemError(id, item.span, ErrTimeout)
-		item.call.Failed("timeout")
+		if item.adaptiveTimeout {
+			item.call.Failed("relay-adaptive-timeout")
+		} else {
+			item.call.Failed("timeout")
+		}
 		item.call.End()
 	}
 
 	r.decrementPending()
 }
 
-func (r *Relayer) failRelayItem(items *relayItems, id uint32, failure string) {
+// failRelayItem fails the call, and if this is the originating relayer, sends
+// err to the caller.
+func (r *Relayer) failRelayItem(items *relayItems, id uint32, failure string, err error) {
 	// Entomb it so that we don't get unknown exchange errors on further frames
 	// for this call.
 	item, ok := items.Entomb(id, _relayTombTTL)
 	if !ok {
 		return
 	}
+	// The call is over, so its timeout shouldn't try to entomb it again.
+	item.Stop()
 	if item.call != nil {
-		r.conn.SendSystemError(id, item.span, errFrameNotSent)
+		r.conn.SendSystemError(id, item.span, err)
 		item.call.Failed(failure)
 		item.call.End()
 	}
@@ -498,11 +635,27 @@ func (r *Relayer) finishRelayItem(items *relayItems, id uint32) {
 		return
 	}
 	if item.call != nil {
+		if !item.started.IsZero() {
+			item.destination.latencies.record(r.conn.timeNow().Sub(item.started))
+		}
 		item.call.End()
 	}
 	r.decrementPending()
 }
 
+// abandonRelayItem entombs the destination's item for a call that the caller
+// has cancelled or claimed, so that frames the destination still sends for the
+// call are dropped rather than treated as frames for an unknown call.
+func (r *Relayer) abandonRelayItem(items *relayItems, id uint32) {
+	item, ok := items.Entomb(id, _relayTombTTL)
+	if !ok {
+		return
+	}
+	// The call is over, so its timeout shouldn't try to entomb it again.
+	item.Stop()
+	r.decrementPending()
+}
+
 func (r *Relayer) decrementPending() {
 	r.pending.Dec()
 	r.conn.checkExchanges()
@@ -558,13 +711,20 @@ func frameTypeFor(f *Frame) frameType {
 	switch t := f.Header.messageType; t {
 	case messageTypeCallRes, messageTypeCallResContinue, messageTypeError, messageTypePingRes:
 		return responseFrame
-	case messageTypeCallReq, messageTypeCallReqContinue, messageTypePingReq:
+	case messageTypeCallReq, messageTypeCallReqContinue, messageTypeCancel, messageTypeClaim, messageTypePingReq:
 		return requestFrame
 	default:
 		panic(fmt.Sprintf("unsupported frame type: %v", t))
 	}
 }
 
+// isCallControl returns whether the frame is a cancel or claim, which the
+// caller sends to abandon a call.
+func isCallControl(f *Frame) bool {
+	t := f.messageType()
+	return t == messageTypeCancel || t == messageTypeClaim
+}
+
 func determinesCallSuccess(f *Frame) (succeeded bool, failMsg string) {
 	switch f.messageType() {
 	case messageTypeError:
@@ -37,6 +37,9 @@ type CallFrame interface {
 	// RoutingKey may refer to an alternate traffic group instead of the
 	// traffic group identified by the service name.
 	RoutingKey() []byte
+	// ShardKey is the shard key of the call, if any, which can be used to
+	// consistently route calls with the same key to the same peer.
+	ShardKey() []byte
 }
 
 // RateLimitDropError is the error that should be returned from
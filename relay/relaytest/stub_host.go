@@ -34,6 +34,7 @@ var _ tchannel.RelayHost = (*StubRelayHost)(nil)
 
 // StubRelayHost is a stub RelayHost for tests that backs peer selection to an
 // underlying channel using isolated subchannels and the default peer selection.
+// Calls with a shard key are routed consistently using GetForKey.
 type StubRelayHost struct {
 	ch    *tchannel.Channel
 	stats *MockStats
@@ -59,7 +60,16 @@ func (rh *StubRelayHost) SetChannel(ch *tchannel.Channel) {
 // Start starts a new RelayCall for the given call on a specific connection.
 func (rh *StubRelayHost) Start(cf relay.CallFrame, _ *tchannel.Connection) (tchannel.RelayCall, error) {
 	// Get a peer from the subchannel.
-	peer, err := rh.ch.GetSubChannel(string(cf.Service())).Peers().Get(nil)
+	peers := rh.ch.GetSubChannel(string(cf.Service())).Peers()
+	var (
+		peer *tchannel.Peer
+		err  error
+	)
+	if shardKey := cf.ShardKey(); len(shardKey) > 0 {
+		peer, err = peers.GetForKey(string(shardKey))
+	} else {
+		peer, err = peers.Get(nil)
+	}
 	return &stubCall{rh.stats.Begin(cf), peer}, err
 }
 
@@ -0,0 +1,128 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package routing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"github.com/uber/tchannel-go"
+)
+
+// ParseRoutes reads routes from a JSON object that maps each service name to
+// a list of host:ports, such as:
+//
+//	{"svc": ["10.0.0.1:4040", "10.0.0.2:4040"]}
+func ParseRoutes(r io.Reader) (Routes, error) {
+	var routes Routes
+	if err := json.NewDecoder(r).Decode(&routes); err != nil {
+		return nil, fmt.Errorf("failed to decode routes: %v", err)
+	}
+	for service, hostPorts := range routes {
+		for _, hostPort := range hostPorts {
+			if _, _, err := net.SplitHostPort(hostPort); err != nil {
+				return nil, fmt.Errorf("invalid host:port %q for service %q: %v", hostPort, service, err)
+			}
+		}
+	}
+	return routes, nil
+}
+
+// LoadFile reads routes from the JSON file at path. See ParseRoutes for the
+// file format.
+func LoadFile(path string) (Routes, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseRoutes(bytes.NewReader(contents))
+}
+
+// WatchFile loads routes from the JSON file at path, and then checks the file
+// every interval, replacing the routes whenever its contents change. Errors
+// reading or parsing the file after the initial load are logged, and the
+// current routes are kept. The returned function stops watching the file.
+func (t *Table) WatchFile(path string, interval time.Duration) (stop func(), err error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	routes, err := ParseRoutes(bytes.NewReader(contents))
+	if err != nil {
+		return nil, err
+	}
+	t.SetRoutes(routes)
+
+	stopCh := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var lastErr string
+
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+			}
+
+			newContents, err := ioutil.ReadFile(path)
+			if err == nil && bytes.Equal(newContents, contents) {
+				continue
+			}
+			if err == nil {
+				// Only attempt to parse each version of the file once.
+				contents = newContents
+				routes, err = ParseRoutes(bytes.NewReader(newContents))
+			}
+			if err != nil {
+				if errMsg := err.Error(); errMsg != lastErr {
+					lastErr = errMsg
+					t.logger().WithFields(
+						tchannel.LogField{Key: "path", Value: path},
+						tchannel.ErrField(err),
+					).Warn("Failed to reload routes, keeping current routes.")
+				}
+				continue
+			}
+
+			lastErr = ""
+			t.SetRoutes(routes)
+		}
+	}()
+
+	return func() { close(stopCh) }, nil
+}
+
+func (t *Table) logger() tchannel.Logger {
+	t.RLock()
+	defer t.RUnlock()
+	if t.ch == nil {
+		return tchannel.NullLogger
+	}
+	return t.ch.Logger()
+}
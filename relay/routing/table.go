@@ -0,0 +1,185 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package routing provides a RelayHost that routes calls using a routing
+// table that maps service names to host:ports. The routing table can be
+// replaced at runtime without restarting the relay or interrupting in-flight
+// calls.
+package routing
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/relay"
+)
+
+// Ensure that Table implements tchannel.RelayHost.
+var _ tchannel.RelayHost = (*Table)(nil)
+
+// Routes maps a service name to the host:ports that serve it.
+type Routes map[string][]string
+
+// Table is a RelayHost that selects a peer for each relayed call from the
+// host:ports in the current routes for the call's service.
+//
+// Each service uses the peer list of an isolated subchannel on the relay
+// channel. When the routes change, only the peers that were added or removed
+// are updated, so in-flight calls and existing connections are not affected.
+type Table struct {
+	sync.RWMutex
+
+	ch       *tchannel.Channel
+	routes   Routes
+	services map[string]*tchannel.PeerList
+}
+
+type call struct {
+	peer *tchannel.Peer
+}
+
+// NewTable returns a Table with the given initial routes.
+func NewTable(routes Routes) *Table {
+	return &Table{
+		routes:   copyRoutes(routes),
+		services: make(map[string]*tchannel.PeerList),
+	}
+}
+
+// SetChannel is called by the relay channel on creation, and applies the
+// initial routes.
+func (t *Table) SetChannel(ch *tchannel.Channel) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.ch = ch
+	t.apply(nil, t.routes)
+}
+
+// Start selects a peer for the call from the routes for the call's service.
+func (t *Table) Start(cf relay.CallFrame, _ *tchannel.Connection) (tchannel.RelayCall, error) {
+	service := string(cf.Service())
+
+	t.RLock()
+	peers, ok := t.services[service]
+	t.RUnlock()
+	if !ok {
+		return nil, tchannel.NewSystemError(tchannel.ErrCodeDeclined, "no routes for service %q", service)
+	}
+
+	peer, err := peers.Get(nil)
+	if err != nil {
+		return nil, err
+	}
+	return call{peer}, nil
+}
+
+// Routes returns a copy of the current routes.
+func (t *Table) Routes() Routes {
+	t.RLock()
+	defer t.RUnlock()
+	return copyRoutes(t.routes)
+}
+
+// SetRoutes replaces the current routes. Services that are not in routes are
+// no longer relayed, and new calls for them are declined.
+func (t *Table) SetRoutes(routes Routes) {
+	routes = copyRoutes(routes)
+
+	t.Lock()
+	defer t.Unlock()
+
+	if t.ch != nil {
+		t.apply(t.routes, routes)
+	}
+	t.routes = routes
+}
+
+// Watch applies each set of routes received on updates until updates is
+// closed. It blocks, so it is typically run in its own goroutine.
+func (t *Table) Watch(updates <-chan Routes) {
+	for routes := range updates {
+		t.SetRoutes(routes)
+	}
+}
+
+// apply updates the peer lists for all services from old to new routes.
+// It must be called with the lock held.
+func (t *Table) apply(old, new Routes) {
+	for service, hostPorts := range new {
+		peers, ok := t.services[service]
+		if !ok {
+			peers = t.ch.GetSubChannel(service, tchannel.Isolated).Peers()
+			t.services[service] = peers
+		}
+
+		newSet := toSet(hostPorts)
+		for hostPort := range newSet {
+			peers.Add(hostPort)
+		}
+		for _, hostPort := range old[service] {
+			if _, ok := newSet[hostPort]; !ok {
+				peers.Remove(hostPort)
+			}
+		}
+	}
+
+	for service, hostPorts := range old {
+		if _, ok := new[service]; ok {
+			continue
+		}
+		if peers, ok := t.services[service]; ok {
+			for _, hostPort := range hostPorts {
+				peers.Remove(hostPort)
+			}
+		}
+		delete(t.services, service)
+	}
+}
+
+func (c call) Destination() (*tchannel.Peer, bool) {
+	return c.peer, c.peer != nil
+}
+
+func (c call) Succeeded()      {}
+func (c call) Failed(_ string) {}
+func (c call) End()            {}
+
+func copyRoutes(routes Routes) Routes {
+	copied := make(Routes, len(routes))
+	for service, hostPorts := range routes {
+		hps := make([]string, 0, len(hostPorts))
+		for hostPort := range toSet(hostPorts) {
+			hps = append(hps, hostPort)
+		}
+		sort.Strings(hps)
+		copied[service] = hps
+	}
+	return copied
+}
+
+func toSet(ss []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(ss))
+	for _, s := range ss {
+		set[s] = struct{}{}
+	}
+	return set
+}
@@ -0,0 +1,210 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package routing
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newServers returns n servers for service svc that respond with their
+// host:port.
+func newServers(t *testing.T, n int) []*tchannel.Channel {
+	var servers []*tchannel.Channel
+	for i := 0; i < n; i++ {
+		server := testutils.NewServer(t, testutils.NewOpts().SetServiceName("svc"))
+		testutils.RegisterFunc(server, "hostport", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+			return &raw.Res{Arg3: []byte(server.PeerInfo().HostPort)}, nil
+		})
+		servers = append(servers, server)
+	}
+	return servers
+}
+
+func closeAll(chs ...*tchannel.Channel) {
+	for _, ch := range chs {
+		ch.Close()
+	}
+}
+
+// callRelay makes a call to svc through the relay, returning the host:port
+// of the server that handled the call.
+func callRelay(t *testing.T, client *tchannel.Channel, relayHostPort string) (string, error) {
+	ctx, cancel := tchannel.NewContext(testutils.Timeout(time.Second))
+	defer cancel()
+
+	_, arg3, _, err := raw.Call(ctx, client, relayHostPort, "svc", "hostport", nil, nil)
+	return string(arg3), err
+}
+
+func TestTableSetRoutes(t *testing.T) {
+	servers := newServers(t, 2)
+	defer closeAll(servers...)
+	hp1, hp2 := servers[0].PeerInfo().HostPort, servers[1].PeerInfo().HostPort
+
+	table := NewTable(Routes{"svc": {hp1}})
+	relay := testutils.NewServer(t, testutils.NewOpts().SetServiceName("relay").SetRelayHost(table))
+	defer relay.Close()
+	client := testutils.NewClient(t, nil)
+	defer client.Close()
+
+	for i := 0; i < 5; i++ {
+		got, err := callRelay(t, client, relay.PeerInfo().HostPort)
+		require.NoError(t, err, "Relayed call failed")
+		assert.Equal(t, hp1, got, "Call should be routed to the initial route")
+	}
+
+	table.SetRoutes(Routes{"svc": {hp2}})
+	assert.Equal(t, Routes{"svc": {hp2}}, table.Routes(), "Unexpected routes")
+	for i := 0; i < 5; i++ {
+		got, err := callRelay(t, client, relay.PeerInfo().HostPort)
+		require.NoError(t, err, "Relayed call failed")
+		assert.Equal(t, hp2, got, "Call should be routed to the updated route")
+	}
+
+	table.SetRoutes(Routes{"other": {hp1}})
+	_, err := callRelay(t, client, relay.PeerInfo().HostPort)
+	assert.Equal(t, tchannel.ErrCodeDeclined, tchannel.GetSystemErrorCode(err), "Expected calls to removed service to be declined, got %v", err)
+}
+
+func TestTableWatch(t *testing.T) {
+	servers := newServers(t, 2)
+	defer closeAll(servers...)
+	hp1, hp2 := servers[0].PeerInfo().HostPort, servers[1].PeerInfo().HostPort
+
+	table := NewTable(nil)
+	relay := testutils.NewServer(t, testutils.NewOpts().SetServiceName("relay").SetRelayHost(table))
+	defer relay.Close()
+	client := testutils.NewClient(t, nil)
+	defer client.Close()
+
+	updates := make(chan Routes)
+	done := make(chan struct{})
+	go func() {
+		table.Watch(updates)
+		close(done)
+	}()
+
+	for _, hp := range []string{hp1, hp2} {
+		updates <- Routes{"svc": {hp}}
+		require.True(t, testutils.WaitFor(time.Second, func() bool {
+			got, err := callRelay(t, client, relay.PeerInfo().HostPort)
+			return err == nil && got == hp
+		}), "Calls were not routed to %v", hp)
+	}
+
+	close(updates)
+	<-done
+}
+
+func TestTableWatchFile(t *testing.T) {
+	servers := newServers(t, 2)
+	defer closeAll(servers...)
+	hp1, hp2 := servers[0].PeerInfo().HostPort, servers[1].PeerInfo().HostPort
+
+	dir, err := ioutil.TempDir("", "routes")
+	require.NoError(t, err, "Failed to create temp dir")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "routes.json")
+	writeRoutes := func(contents string) {
+		require.NoError(t, ioutil.WriteFile(path, []byte(contents), 0644), "Failed to write routes")
+	}
+	writeRoutes(`{"svc": ["` + hp1 + `"]}`)
+
+	table := NewTable(nil)
+	stop, err := table.WatchFile(path, testutils.Timeout(10*time.Millisecond))
+	require.NoError(t, err, "WatchFile failed")
+	defer stop()
+	assert.Equal(t, Routes{"svc": {hp1}}, table.Routes(), "Routes should be loaded from the file")
+
+	opts := testutils.NewOpts().
+		SetServiceName("relay").
+		SetRelayHost(table).
+		AddLogFilter("Failed to reload routes, keeping current routes.", 1)
+	relay := testutils.NewServer(t, opts)
+	defer relay.Close()
+	client := testutils.NewClient(t, nil)
+	defer client.Close()
+
+	got, err := callRelay(t, client, relay.PeerInfo().HostPort)
+	require.NoError(t, err, "Relayed call failed")
+	assert.Equal(t, hp1, got, "Call should be routed using the file's routes")
+
+	// Invalid contents are ignored and the current routes are kept.
+	writeRoutes(`{"svc": "not a list"}`)
+	time.Sleep(testutils.Timeout(50 * time.Millisecond))
+	assert.Equal(t, Routes{"svc": {hp1}}, table.Routes(), "Invalid routes should be ignored")
+
+	writeRoutes(`{"svc": ["` + hp2 + `"]}`)
+	require.True(t, testutils.WaitFor(time.Second, func() bool {
+		got, err := callRelay(t, client, relay.PeerInfo().HostPort)
+		return err == nil && got == hp2
+	}), "Calls were not routed using the updated file")
+}
+
+func TestParseRoutes(t *testing.T) {
+	tests := []struct {
+		msg     string
+		json    string
+		want    Routes
+		wantErr string
+	}{
+		{
+			msg:  "valid routes",
+			json: `{"a": ["1.1.1.1:1", "2.2.2.2:2"], "b": []}`,
+			want: Routes{"a": {"1.1.1.1:1", "2.2.2.2:2"}, "b": {}},
+		},
+		{
+			msg:     "invalid JSON",
+			json:    `{"a": `,
+			wantErr: "failed to decode routes",
+		},
+		{
+			msg:     "invalid host:port",
+			json:    `{"a": ["1.1.1.1"]}`,
+			wantErr: `invalid host:port "1.1.1.1" for service "a"`,
+		},
+	}
+
+	for _, tt := range tests {
+		routes, err := ParseRoutes(strings.NewReader(tt.json))
+		if tt.wantErr != "" {
+			if assert.Error(t, err, "%v: expected error", tt.msg) {
+				assert.Contains(t, err.Error(), tt.wantErr, "%v: unexpected error", tt.msg)
+			}
+			continue
+		}
+		require.NoError(t, err, "%v: ParseRoutes failed", tt.msg)
+		assert.Equal(t, tt.want, routes, "%v: unexpected routes", tt.msg)
+	}
+}
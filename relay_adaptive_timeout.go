@@ -0,0 +1,157 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/uber-go/atomic"
+)
+
+const (
+	// _relayLatencySamples is the number of recent latencies kept for each
+	// destination connection.
+	_relayLatencySamples = 512
+	// _relayLatencyRecompute is the number of new latencies after which the
+	// adaptive timeout is recomputed.
+	_relayLatencyRecompute = 32
+
+	_defaultAdaptiveTimeoutPercentile = 0.99
+	_defaultAdaptiveTimeoutMin        = 100 * time.Millisecond
+	_defaultAdaptiveTimeoutMinSamples = 100
+)
+
+// RelayAdaptiveTimeoutOptions configures adaptive timeouts for relayed calls,
+// which are based on the recent response latencies of each destination. A
+// call's timeout is reduced to the adaptive timeout if it is shorter than the
+// caller's TTL, so calls to a destination that stops responding time out
+// early rather than holding relay items for the caller's full TTL.
+type RelayAdaptiveTimeoutOptions struct {
+	// Factor multiplies the latency percentile to get the adaptive timeout.
+	// Zero disables adaptive timeouts.
+	Factor float64
+
+	// Percentile is the percentile of latencies to use, between 0 and 1. If
+	// this is 0, the default of 0.99 is used.
+	Percentile float64
+
+	// MinTimeout is the smallest adaptive timeout. If this is 0, the default
+	// of 100ms is used.
+	MinTimeout time.Duration
+
+	// MinSamples is the number of calls a destination must complete before
+	// an adaptive timeout is applied. If this is 0, the default of 100 is used.
+	MinSamples int
+}
+
+func (o RelayAdaptiveTimeoutOptions) withDefaults() RelayAdaptiveTimeoutOptions {
+	if o.Percentile <= 0 || o.Percentile > 1 {
+		o.Percentile = _defaultAdaptiveTimeoutPercentile
+	}
+	if o.MinTimeout <= 0 {
+		o.MinTimeout = _defaultAdaptiveTimeoutMin
+	}
+	if o.MinSamples <= 0 {
+		o.MinSamples = _defaultAdaptiveTimeoutMinSamples
+	}
+	if o.MinSamples > _relayLatencySamples {
+		o.MinSamples = _relayLatencySamples
+	}
+	return o
+}
+
+// latencyTracker tracks the recent latencies of calls relayed to a
+// destination connection. A nil tracker has no adaptive timeout.
+type latencyTracker struct {
+	opts RelayAdaptiveTimeoutOptions
+
+	sync.Mutex
+	samples []time.Duration
+	next    int
+	count   int
+
+	// timeout is the current adaptive timeout, or 0 if there is none yet.
+	timeout atomic.Int64
+}
+
+// newLatencyTracker returns a tracker, or nil if adaptive timeouts are disabled.
+func newLatencyTracker(opts RelayAdaptiveTimeoutOptions) *latencyTracker {
+	if opts.Factor <= 0 {
+		return nil
+	}
+	return &latencyTracker{
+		opts:    opts.withDefaults(),
+		samples: make([]time.Duration, 0, _relayLatencySamples),
+	}
+}
+
+// record adds the latency of a completed call.
+func (t *latencyTracker) record(d time.Duration) {
+	if t == nil {
+		return
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	if len(t.samples) < _relayLatencySamples {
+		t.samples = append(t.samples, d)
+	} else {
+		t.samples[t.next] = d
+	}
+	t.next = (t.next + 1) % _relayLatencySamples
+	t.count++
+
+	if t.count == t.opts.MinSamples || t.count > t.opts.MinSamples && t.count%_relayLatencyRecompute == 0 {
+		t.timeout.Store(int64(t.compute()))
+	}
+}
+
+// compute returns the adaptive timeout for the current samples. It must be
+// called with the lock held.
+func (t *latencyTracker) compute() time.Duration {
+	sorted := append(durations(nil), t.samples...)
+	sort.Sort(sorted)
+
+	latency := sorted[int(t.opts.Percentile*float64(len(sorted)-1))]
+	timeout := time.Duration(float64(latency) * t.opts.Factor)
+	if timeout < t.opts.MinTimeout {
+		timeout = t.opts.MinTimeout
+	}
+	return timeout
+}
+
+// adaptiveTimeout returns the adaptive timeout for calls to the destination,
+// or 0 if there is none.
+func (t *latencyTracker) adaptiveTimeout() time.Duration {
+	if t == nil {
+		return 0
+	}
+	return time.Duration(t.timeout.Load())
+}
+
+type durations []time.Duration
+
+func (d durations) Len() int           { return len(d) }
+func (d durations) Less(i, j int) bool { return d[i] < d[j] }
+func (d durations) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
@@ -0,0 +1,76 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencyTrackerDisabled(t *testing.T) {
+	tracker := newLatencyTracker(RelayAdaptiveTimeoutOptions{})
+	assert.Nil(t, tracker, "Expected no tracker when Factor is 0")
+
+	// A nil tracker should be safe to use.
+	tracker.record(time.Second)
+	assert.Equal(t, time.Duration(0), tracker.adaptiveTimeout(), "Nil tracker should have no timeout")
+}
+
+func TestLatencyTrackerDefaults(t *testing.T) {
+	tracker := newLatencyTracker(RelayAdaptiveTimeoutOptions{Factor: 2})
+	assert.Equal(t, RelayAdaptiveTimeoutOptions{
+		Factor:     2,
+		Percentile: 0.99,
+		MinTimeout: 100 * time.Millisecond,
+		MinSamples: 100,
+	}, tracker.opts, "Unexpected defaults")
+
+	tracker = newLatencyTracker(RelayAdaptiveTimeoutOptions{Factor: 2, MinSamples: 10000})
+	assert.Equal(t, _relayLatencySamples, tracker.opts.MinSamples, "MinSamples should be capped")
+}
+
+func TestLatencyTrackerTimeout(t *testing.T) {
+	tracker := newLatencyTracker(RelayAdaptiveTimeoutOptions{
+		Factor:     3,
+		Percentile: 0.5,
+		MinTimeout: time.Millisecond,
+		MinSamples: 5,
+	})
+
+	for i := 1; i < 5; i++ {
+		tracker.record(time.Duration(i) * time.Millisecond)
+		assert.Equal(t, time.Duration(0), tracker.adaptiveTimeout(), "No timeout before MinSamples")
+	}
+
+	tracker.record(5 * time.Millisecond)
+	assert.Equal(t, 9*time.Millisecond, tracker.adaptiveTimeout(), "Expected median * factor")
+
+	// The timeout is only recomputed periodically after MinSamples.
+	for i := 6; i < _relayLatencyRecompute; i++ {
+		tracker.record(time.Microsecond)
+	}
+	assert.Equal(t, 9*time.Millisecond, tracker.adaptiveTimeout(), "Timeout should not be recomputed yet")
+
+	tracker.record(time.Microsecond)
+	assert.Equal(t, time.Millisecond, tracker.adaptiveTimeout(), "Timeout should be capped at MinTimeout")
+}
@@ -0,0 +1,350 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/uber/tchannel-go/trand"
+
+	"github.com/uber-go/atomic"
+)
+
+const defaultRelayEdgeMaxTraces = 100
+
+// RelayEdgeStatsOptions configures RelayEdgeStats.
+type RelayEdgeStatsOptions struct {
+	// TracePercentage is the percentage of relayed calls, between 0 and 100,
+	// that are recorded as RelayCallTraces. It can be changed while the
+	// channel is running using SetTracePercentage.
+	TracePercentage float64
+
+	// MaxTraces is the number of most recent traces that are kept. Defaults
+	// to 100.
+	MaxTraces int
+}
+
+// RelayEdgeSummary is the traffic relayed from a caller to a service.
+type RelayEdgeSummary struct {
+	Caller  string `json:"caller"`
+	Service string `json:"service"`
+
+	// Calls is the number of completed calls, and Failures is the number of
+	// calls that did not succeed, by response code.
+	Calls    uint64            `json:"calls"`
+	Failures map[string]uint64 `json:"failures,omitempty"`
+
+	// TotalLatency and MaxLatency are the sum and maximum of the latencies of
+	// completed calls, in nanoseconds when encoded as JSON.
+	TotalLatency time.Duration `json:"totalLatency"`
+	MaxLatency   time.Duration `json:"maxLatency"`
+
+	// RequestFrames, ResponseFrames, RequestBytes and ResponseBytes are the
+	// frames and frame payload bytes relayed in each direction.
+	RequestFrames  uint64 `json:"requestFrames"`
+	ResponseFrames uint64 `json:"responseFrames"`
+	RequestBytes   uint64 `json:"requestBytes"`
+	ResponseBytes  uint64 `json:"responseBytes"`
+}
+
+// RelayCallTrace describes a single sampled relayed call. The
+// RemoteHostPort is the caller's host:port, BytesReceived is the request
+// size, and BytesSent is the response size.
+type RelayCallTrace struct {
+	AccessLogEntry
+
+	// Destination is the host:port the call was relayed to, if a destination
+	// was selected.
+	Destination string `json:"destination,omitempty"`
+
+	RequestFrames  uint64 `json:"requestFrames"`
+	ResponseFrames uint64 `json:"responseFrames"`
+}
+
+// RelayEdgesRuntimeState is the runtime state of RelayEdgeStats.
+type RelayEdgesRuntimeState struct {
+	TracePercentage float64            `json:"tracePercentage"`
+	Edges           []RelayEdgeSummary `json:"edges"`
+	Traces          []RelayCallTrace   `json:"traces,omitempty"`
+}
+
+// RelayEdgeStats tracks the calls relayed from each caller to each service.
+// For each edge, it reports the number of calls by response code, their
+// latency, and the frames and bytes relayed, using the channel's
+// StatsReporter, and keeps a summary that can be introspected. A percentage
+// of calls are also recorded as structured traces. A nil RelayEdgeStats does
+// not track any calls.
+type RelayEdgeStats struct {
+	sync.Mutex
+
+	tracePercentage float64
+	rng             *rand.Rand
+	edges           map[relayEdge]*RelayEdgeSummary
+	traces          []RelayCallTrace
+	next            int
+}
+
+// NewRelayEdgeStats returns a RelayEdgeStats with the given options.
+func NewRelayEdgeStats(opts RelayEdgeStatsOptions) *RelayEdgeStats {
+	if opts.MaxTraces <= 0 {
+		opts.MaxTraces = defaultRelayEdgeMaxTraces
+	}
+	return &RelayEdgeStats{
+		tracePercentage: opts.TracePercentage,
+		rng:             trand.NewSeeded(),
+		edges:           make(map[relayEdge]*RelayEdgeSummary),
+		traces:          make([]RelayCallTrace, 0, opts.MaxTraces),
+	}
+}
+
+// SetTracePercentage sets the percentage of calls, between 0 and 100, that
+// are recorded as traces.
+func (s *RelayEdgeStats) SetTracePercentage(percentage float64) {
+	s.Lock()
+	s.tracePercentage = percentage
+	s.Unlock()
+}
+
+// Edges returns the summary of each edge, sorted by caller and service.
+func (s *RelayEdgeStats) Edges() []RelayEdgeSummary {
+	if s == nil {
+		return nil
+	}
+
+	s.Lock()
+	edges := make([]RelayEdgeSummary, 0, len(s.edges))
+	for _, summary := range s.edges {
+		edge := *summary
+		edge.Failures = make(map[string]uint64, len(summary.Failures))
+		for code, n := range summary.Failures {
+			edge.Failures[code] = n
+		}
+		edges = append(edges, edge)
+	}
+	s.Unlock()
+
+	sort.Sort(byRelayEdge(edges))
+	return edges
+}
+
+// Traces returns the most recent sampled calls, oldest first.
+func (s *RelayEdgeStats) Traces() []RelayCallTrace {
+	if s == nil {
+		return nil
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	traces := make([]RelayCallTrace, 0, len(s.traces))
+	traces = append(traces, s.traces[s.next:]...)
+	return append(traces, s.traces[:s.next]...)
+}
+
+// IntrospectState returns the runtime state of the edges and traces.
+func (s *RelayEdgeStats) IntrospectState() *RelayEdgesRuntimeState {
+	if s == nil {
+		return nil
+	}
+
+	s.Lock()
+	percentage := s.tracePercentage
+	s.Unlock()
+	return &RelayEdgesRuntimeState{
+		TracePercentage: percentage,
+		Edges:           s.Edges(),
+		Traces:          s.Traces(),
+	}
+}
+
+// sampled returns whether a new call should be traced.
+func (s *RelayEdgeStats) sampled() bool {
+	s.Lock()
+	defer s.Unlock()
+	return s.tracePercentage > 0 && s.rng.Float64()*100 < s.tracePercentage
+}
+
+// record adds a completed call to its edge's summary, and to the traces if
+// the call was sampled.
+func (s *RelayEdgeStats) record(edge relayEdge, trace RelayCallTrace, sampled bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	summary, ok := s.edges[edge]
+	if !ok {
+		summary = &RelayEdgeSummary{
+			Caller:   edge.caller,
+			Service:  edge.service,
+			Failures: make(map[string]uint64),
+		}
+		s.edges[edge] = summary
+	}
+	summary.Calls++
+	if trace.ResponseCode != AccessLogOK {
+		summary.Failures[trace.ResponseCode]++
+	}
+	summary.TotalLatency += trace.Latency
+	if trace.Latency > summary.MaxLatency {
+		summary.MaxLatency = trace.Latency
+	}
+	summary.RequestFrames += trace.RequestFrames
+	summary.ResponseFrames += trace.ResponseFrames
+	summary.RequestBytes += trace.BytesReceived
+	summary.ResponseBytes += trace.BytesSent
+
+	if !sampled {
+		return
+	}
+	if len(s.traces) < cap(s.traces) {
+		s.traces = append(s.traces, trace)
+		return
+	}
+	s.traces[s.next] = trace
+	s.next = (s.next + 1) % len(s.traces)
+}
+
+type byRelayEdge []RelayEdgeSummary
+
+func (e byRelayEdge) Len() int      { return len(e) }
+func (e byRelayEdge) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e byRelayEdge) Less(i, j int) bool {
+	if e[i].Caller != e[j].Caller {
+		return e[i].Caller < e[j].Caller
+	}
+	return e[i].Service < e[j].Service
+}
+
+// relayEdgeCall tracks a single relayed call for RelayEdgeStats. A nil
+// *relayEdgeCall is valid, and ignores all updates.
+type relayEdgeCall struct {
+	stats    *RelayEdgeStats
+	reporter StatsReporter
+	tags     map[string]string
+	edge     relayEdge
+	sampled  bool
+	trace    RelayCallTrace
+
+	requestFrames  atomic.Uint64
+	responseFrames atomic.Uint64
+	requestBytes   atomic.Uint64
+	responseBytes  atomic.Uint64
+	responseCode   atomic.String
+}
+
+// wrapEdgeStats wraps a relayed call to track it in the relay's
+// RelayEdgeStats, if it has one.
+func (r *Relayer) wrapEdgeStats(f lazyCallReq, call RelayCall) (RelayCall, *relayEdgeCall) {
+	if r.edgeStats == nil {
+		return call, nil
+	}
+
+	caller, service := string(f.Caller()), string(f.Service())
+	tags := cloneTags(r.conn.commonStatsTags)
+	tags["source-service"] = caller
+	tags["target-service"] = service
+
+	edgeCall := &relayEdgeCall{
+		stats:    r.edgeStats,
+		reporter: r.conn.statsReporter,
+		tags:     tags,
+		edge:     relayEdge{caller, service},
+		sampled:  r.edgeStats.sampled(),
+		trace: RelayCallTrace{
+			AccessLogEntry: AccessLogEntry{
+				Time:           r.conn.timeNow(),
+				Direction:      AccessLogRelay,
+				Caller:         caller,
+				Service:        service,
+				Method:         string(f.Method()),
+				RemoteHostPort: r.conn.remotePeerInfo.HostPort,
+			},
+		},
+	}
+	return relayEdgeRelayCall{call, edgeCall, r.conn.timeNow}, edgeCall
+}
+
+// setDestination sets the host:port that the call is relayed to. It must be
+// called before any frames for the call are relayed.
+func (c *relayEdgeCall) setDestination(hostPort string) {
+	if c != nil {
+		c.trace.Destination = hostPort
+	}
+}
+
+func (c *relayEdgeCall) relayed(f *Frame, fType frameType) {
+	if c == nil {
+		return
+	}
+	if fType == requestFrame {
+		c.requestFrames.Inc()
+		c.requestBytes.Add(uint64(f.payloadSize()))
+	} else {
+		c.responseFrames.Inc()
+		c.responseBytes.Add(uint64(f.payloadSize()))
+	}
+}
+
+// finish reports the call, which completed at now.
+func (c *relayEdgeCall) finish(now time.Time) {
+	trace := c.trace
+	trace.Latency = now.Sub(trace.Time)
+	trace.ResponseCode = c.responseCode.Load()
+	trace.RequestFrames = c.requestFrames.Load()
+	trace.ResponseFrames = c.responseFrames.Load()
+	trace.BytesReceived = c.requestBytes.Load()
+	trace.BytesSent = c.responseBytes.Load()
+
+	callTags := cloneTags(c.tags)
+	callTags["response-code"] = trace.ResponseCode
+	c.reporter.IncCounter("relay.edge.calls", callTags, 1)
+	c.reporter.RecordTimer("relay.edge.latency", c.tags, trace.Latency)
+	c.reporter.IncCounter("relay.edge.request.frames", c.tags, int64(trace.RequestFrames))
+	c.reporter.IncCounter("relay.edge.response.frames", c.tags, int64(trace.ResponseFrames))
+	c.reporter.IncCounter("relay.edge.request.bytes", c.tags, int64(trace.BytesReceived))
+	c.reporter.IncCounter("relay.edge.response.bytes", c.tags, int64(trace.BytesSent))
+
+	c.stats.record(c.edge, trace, c.sampled)
+}
+
+// relayEdgeRelayCall records the outcome of a relayed call for RelayEdgeStats.
+type relayEdgeRelayCall struct {
+	RelayCall
+
+	edgeCall *relayEdgeCall
+	timeNow  func() time.Time
+}
+
+func (c relayEdgeRelayCall) Succeeded() {
+	c.edgeCall.responseCode.Store(AccessLogOK)
+	c.RelayCall.Succeeded()
+}
+
+func (c relayEdgeRelayCall) Failed(reason string) {
+	c.edgeCall.responseCode.Store(reason)
+	c.RelayCall.Failed(reason)
+}
+
+func (c relayEdgeRelayCall) End() {
+	c.edgeCall.finish(c.timeNow())
+	c.RelayCall.End()
+}
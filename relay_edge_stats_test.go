@@ -0,0 +1,148 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayEdgeStats(t *testing.T) {
+	stats := newRecordingStatsReporter()
+	edgeStats := NewRelayEdgeStats(RelayEdgeStatsOptions{})
+	opts := testutils.NewOpts().SetRelayOnly()
+	opts.StatsReporter = stats
+	opts.RelayEdgeStats = edgeStats
+
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		testutils.RegisterEcho(ts.Server(), nil)
+		testutils.RegisterFunc(ts.Server(), "app-error", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+			return &raw.Res{Arg3: []byte("failed"), IsErr: true}, nil
+		})
+
+		c1 := ts.NewClient(testutils.NewOpts().SetServiceName("c1"))
+		c2 := ts.NewClient(testutils.NewOpts().SetServiceName("c2"))
+		call := func(client *Channel, method string) {
+			ctx, cancel := NewContext(testutils.Timeout(time.Second))
+			defer cancel()
+			_, _, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), method, nil, []byte("hello"))
+			require.NoError(t, err, "%v call failed", method)
+		}
+
+		// Calls are recorded once the relay has forwarded the last response
+		// frame, which may be after the client receives it.
+		waitForCalls := func(want uint64) []RelayEdgeSummary {
+			var edges []RelayEdgeSummary
+			testutils.WaitFor(time.Second, func() bool {
+				edges = edgeStats.Edges()
+				var calls uint64
+				for _, e := range edges {
+					calls += e.Calls
+				}
+				return calls == want
+			})
+			return edges
+		}
+
+		for i := 0; i < 3; i++ {
+			call(c1, "echo")
+		}
+		call(c2, "app-error")
+
+		edges := waitForCalls(4)
+		require.Len(t, edges, 2, "Expected an edge for each caller")
+		assert.Equal(t, "c1", edges[0].Caller, "Edges should be sorted by caller")
+		assert.Equal(t, ts.ServiceName(), edges[0].Service, "Unexpected service")
+		assert.Equal(t, uint64(3), edges[0].Calls, "Unexpected calls from c1")
+		assert.Empty(t, edges[0].Failures, "Unexpected failures from c1")
+		assert.Equal(t, uint64(3), edges[0].RequestFrames, "Unexpected request frames from c1")
+		assert.Equal(t, uint64(3), edges[0].ResponseFrames, "Unexpected response frames to c1")
+		assert.True(t, edges[0].RequestBytes > 0, "Expected request bytes from c1")
+		assert.True(t, edges[0].ResponseBytes > 0, "Expected response bytes to c1")
+		assert.True(t, edges[0].MaxLatency > 0, "Expected latency for c1")
+		assert.Equal(t, "c2", edges[1].Caller, "Edges should be sorted by caller")
+		assert.Equal(t, map[string]uint64{"application-error": 1}, edges[1].Failures, "Unexpected failures from c2")
+		assert.Empty(t, edgeStats.Traces(), "Calls should not be traced by default")
+
+		// Enable tracing using the introspection endpoint.
+		req := httptest.NewRequest("GET", "/debug/tchannel/relay-edges?tracePercentage=100", nil)
+		rec := httptest.NewRecorder()
+		ts.Relay().IntrospectionHandler().ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, "Unexpected status: %s", rec.Body)
+		var state RelayEdgesRuntimeState
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &state), "Failed to unmarshal state")
+		assert.Equal(t, float64(100), state.TracePercentage, "Trace percentage not updated")
+		assert.Len(t, state.Edges, 2, "Unexpected edges")
+
+		call(c1, "echo")
+		waitForCalls(5)
+		traces := edgeStats.Traces()
+		require.Len(t, traces, 1, "Expected traced call")
+		assert.Equal(t, "c1", traces[0].Caller, "Unexpected caller")
+		assert.Equal(t, "echo", traces[0].Method, "Unexpected method")
+		assert.Equal(t, AccessLogRelay, traces[0].Direction, "Unexpected direction")
+		assert.Equal(t, AccessLogOK, traces[0].ResponseCode, "Unexpected response code")
+		assert.Equal(t, ts.Server().PeerInfo().HostPort, traces[0].Destination, "Unexpected destination")
+		assert.Equal(t, uint64(1), traces[0].RequestFrames, "Unexpected request frames")
+		assert.Equal(t, uint64(1), traces[0].ResponseFrames, "Unexpected response frames")
+
+		state = *ts.Relay().IntrospectState(&IntrospectionOptions{IncludeRelayEdges: true}).RelayEdges
+		assert.Len(t, state.Traces, 1, "Expected trace in introspected state")
+
+		stats.Lock()
+		defer stats.Unlock()
+		calls := make(map[string]int64)
+		for tags, v := range stats.Values["relay.edge.calls"] {
+			for _, tag := range strings.Split(tags, ", ") {
+				if strings.HasPrefix(tag, "source-service = ") {
+					calls[strings.TrimPrefix(tag, "source-service = ")] += v.count
+				}
+			}
+			assert.Contains(t, tags, "response-code = ", "Missing response code tag")
+		}
+		assert.Equal(t, map[string]int64{"c1": 4, "c2": 1}, calls, "Unexpected relay.edge.calls")
+		assert.Len(t, stats.Values["relay.edge.latency"], 2, "Expected latency for each edge")
+	})
+}
+
+func TestRelayEdgeStatsIntrospectionDisabled(t *testing.T) {
+	opts := testutils.NewOpts().SetRelayOnly()
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		rec := httptest.NewRecorder()
+		ts.Relay().IntrospectionHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/relay-edges", nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code, "Expected not found without RelayEdgeStats")
+		assert.Nil(t, ts.Relay().IntrospectState(&IntrospectionOptions{IncludeRelayEdges: true}).RelayEdges,
+			"Unexpected relay edges without RelayEdgeStats")
+	})
+}
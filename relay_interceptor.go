@@ -0,0 +1,441 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tchannel
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var errRelayMethodChecksum = errors.New("cannot change the method of a fragmented call with a checksum")
+
+// RelayInterceptor inspects and modifies calls as they are relayed, such as
+// to route calls based on their headers, or to enforce authentication at the
+// relay. Either function may be nil.
+type RelayInterceptor struct {
+	// CallReq is called with the first frame of each relayed call before the
+	// RelayHost selects a destination, so changes to the service or headers
+	// affect routing. If it returns an error, the call is failed with the
+	// error instead of being relayed. Errors that are not a SystemError are
+	// sent as ErrCodeDeclined.
+	CallReq func(req *RelayCallReq) error
+
+	// CallRes is called with the first frame of the response to a relayed
+	// call before it is forwarded to the caller. If it returns an error, the
+	// caller is sent the error instead of the response.
+	CallRes func(res *RelayCallRes) error
+}
+
+type relayHeader struct {
+	key, value string
+}
+
+// relayHeaders are the transport headers of a relayed frame, which are
+// kept in order so the frame can be rewritten.
+type relayHeaders struct {
+	headers  []relayHeader
+	modified bool
+}
+
+func (h *relayHeaders) get(key string) (string, bool) {
+	for _, kv := range h.headers {
+		if kv.key == key {
+			return kv.value, true
+		}
+	}
+	return "", false
+}
+
+func (h *relayHeaders) toMap() map[string]string {
+	m := make(map[string]string, len(h.headers))
+	for _, kv := range h.headers {
+		m[kv.key] = kv.value
+	}
+	return m
+}
+
+func (h *relayHeaders) set(key, value string) {
+	h.modified = true
+	for i, kv := range h.headers {
+		if kv.key == key {
+			h.headers[i].value = value
+			return
+		}
+	}
+	h.headers = append(h.headers, relayHeader{key, value})
+}
+
+func (h *relayHeaders) delete(key string) {
+	for i, kv := range h.headers {
+		if kv.key == key {
+			h.modified = true
+			h.headers = append(h.headers[:i], h.headers[i+1:]...)
+			return
+		}
+	}
+}
+
+// readRelayHeaders reads the transport headers starting at offset in the
+// payload, and returns the offset of the first byte after them.
+func readRelayHeaders(payload []byte, offset int) ([]relayHeader, int) {
+	numHeaders := int(payload[offset])
+	cur := offset + 1
+	headers := make([]relayHeader, 0, numHeaders)
+	for i := 0; i < numHeaders; i++ {
+		keyLen := int(payload[cur])
+		cur++
+		key := string(payload[cur : cur+keyLen])
+		cur += keyLen
+
+		valLen := int(payload[cur])
+		cur++
+		val := string(payload[cur : cur+valLen])
+		cur += valLen
+
+		headers = append(headers, relayHeader{key, val})
+	}
+	return headers, cur
+}
+
+// appendRelayHeaders appends the headers to buf in the wire format.
+func appendRelayHeaders(buf []byte, headers []relayHeader) ([]byte, error) {
+	if len(headers) > 255 {
+		return nil, fmt.Errorf("too many transport headers: %v", len(headers))
+	}
+	buf = append(buf, byte(len(headers)))
+	for _, kv := range headers {
+		var err error
+		if buf, err = appendRelayString(buf, kv.key); err != nil {
+			return nil, err
+		}
+		if buf, err = appendRelayString(buf, kv.value); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+func appendRelayString(buf []byte, s string) ([]byte, error) {
+	if len(s) > 255 {
+		return nil, fmt.Errorf("transport header string is too long: %q", s)
+	}
+	buf = append(buf, byte(len(s)))
+	return append(buf, s...), nil
+}
+
+// setRelayPayload replaces the frame's payload.
+func setRelayPayload(f *Frame, payload []byte) error {
+	if len(payload) > len(f.Payload) {
+		return fmt.Errorf("rewritten frame payload is too large: %v bytes", len(payload))
+	}
+	copy(f.Payload, payload)
+	f.setPayloadSize(len(payload))
+	return nil
+}
+
+// RelayCallReq is the first frame of a relayed call. It is only valid
+// during the call to RelayInterceptor.CallReq.
+type RelayCallReq struct {
+	f          lazyCallReq
+	headers    relayHeaders
+	headersEnd int
+
+	service, method string
+	serviceModified bool
+	methodModified  bool
+}
+
+func newRelayCallReq(f lazyCallReq) *RelayCallReq {
+	headersStart := _serviceNameIndex + int(f.Payload[_serviceLenIndex])
+	headers, headersEnd := readRelayHeaders(f.Payload, headersStart)
+	return &RelayCallReq{
+		f:          f,
+		headers:    relayHeaders{headers: headers},
+		headersEnd: headersEnd,
+		service:    string(f.Service()),
+		method:     string(f.Method()),
+	}
+}
+
+// Caller returns the name of the calling service.
+func (r *RelayCallReq) Caller() string {
+	caller, _ := r.headers.get(string(CallerName))
+	return caller
+}
+
+// Service returns the name of the service being called.
+func (r *RelayCallReq) Service() string {
+	return r.service
+}
+
+// Method returns the name of the method being called.
+func (r *RelayCallReq) Method() string {
+	return r.method
+}
+
+// Header returns the value of the given transport header.
+func (r *RelayCallReq) Header(key string) (string, bool) {
+	return r.headers.get(key)
+}
+
+// Headers returns a copy of the transport headers.
+func (r *RelayCallReq) Headers() map[string]string {
+	return r.headers.toMap()
+}
+
+// SetHeader sets a transport header.
+func (r *RelayCallReq) SetHeader(key, value string) {
+	r.headers.set(key, value)
+}
+
+// DeleteHeader removes a transport header.
+func (r *RelayCallReq) DeleteHeader(key string) {
+	r.headers.delete(key)
+}
+
+// SetService changes the service that the call is relayed to.
+func (r *RelayCallReq) SetService(service string) {
+	r.service = service
+	r.serviceModified = true
+}
+
+// SetMethod changes the method being called. The method of a call that is
+// fragmented can only be changed if it does not use a checksum, otherwise
+// the call is failed with ErrCodeBadRequest.
+func (r *RelayCallReq) SetMethod(method string) {
+	r.method = method
+	r.methodModified = true
+}
+
+func (r *RelayCallReq) modified() bool {
+	return r.headers.modified || r.serviceModified || r.methodModified
+}
+
+// rewrite writes any changes back to the frame.
+func (r *RelayCallReq) rewrite() error {
+	payload := r.f.SizedPayload()
+	tail := payload[r.headersEnd:]
+	if r.methodModified {
+		var err error
+		if tail, err = r.rewriteMethod(tail); err != nil {
+			return err
+		}
+	}
+
+	buf := make([]byte, 0, len(payload)+len(r.service)+len(r.method))
+	buf = append(buf, payload[:_serviceLenIndex]...)
+	buf, err := appendRelayString(buf, r.service)
+	if err != nil {
+		return err
+	}
+	if buf, err = appendRelayHeaders(buf, r.headers.headers); err != nil {
+		return err
+	}
+	return setRelayPayload(r.f.Frame, append(buf, tail...))
+}
+
+// rewriteMethod returns the checksum and arguments with arg1 replaced by the
+// new method, updating the checksum if the call is not fragmented.
+func (r *RelayCallReq) rewriteMethod(tail []byte) ([]byte, error) {
+	// csumtype:1 (csum:4){0,1} arg1~2 arg2~2 arg3~2
+	checksumType := ChecksumType(tail[0])
+	if checksumType != ChecksumTypeNone && r.f.HasMoreFragments() {
+		return nil, NewWrappedSystemError(ErrCodeBadRequest, errRelayMethodChecksum)
+	}
+	if len(r.method) > maxMethodSize {
+		return nil, NewWrappedSystemError(ErrCodeBadRequest, ErrMethodTooLarge)
+	}
+
+	argsStart := 1 + checksumType.ChecksumSize()
+	arg1Len := int(binary.BigEndian.Uint16(tail[argsStart:]))
+	rest := tail[argsStart+2+arg1Len:]
+
+	buf := make([]byte, argsStart, len(tail)+len(r.method))
+	buf[0] = byte(checksumType)
+	buf = append(buf, 0, 0)
+	binary.BigEndian.PutUint16(buf[argsStart:], uint16(len(r.method)))
+	buf = append(buf, r.method...)
+	buf = append(buf, rest...)
+
+	if checksumType != ChecksumTypeNone {
+		checksum := checksumType.New()
+		checksum.Add([]byte(r.method))
+		for args := rest; len(args) >= 2; {
+			argLen := int(binary.BigEndian.Uint16(args))
+			checksum.Add(args[2 : 2+argLen])
+			args = args[2+argLen:]
+		}
+		copy(buf[1:argsStart], checksum.Sum())
+		checksum.Release()
+	}
+	return buf, nil
+}
+
+// RelayCallRes is the first frame of the response to a relayed call. It is
+// only valid during the call to RelayInterceptor.CallRes.
+type RelayCallRes struct {
+	f       *Frame
+	call    *relayCallInfo
+	headers relayHeaders
+
+	headersEnd int
+}
+
+// relayCallInfo describes a relayed call for response interceptors.
+type relayCallInfo struct {
+	caller, service, method string
+}
+
+// _resHeadersIndex is the offset of the transport headers in a call res.
+const _resHeadersIndex = _resCodeIndex + 1 + _spanLength
+
+func newRelayCallRes(f *Frame, call *relayCallInfo) *RelayCallRes {
+	headers, headersEnd := readRelayHeaders(f.Payload, _resHeadersIndex)
+	return &RelayCallRes{
+		f:          f,
+		call:       call,
+		headers:    relayHeaders{headers: headers},
+		headersEnd: headersEnd,
+	}
+}
+
+// Caller returns the name of the service that made the call.
+func (r *RelayCallRes) Caller() string {
+	return r.call.caller
+}
+
+// Service returns the name of the service that was called.
+func (r *RelayCallRes) Service() string {
+	return r.call.service
+}
+
+// Method returns the name of the method that was called.
+func (r *RelayCallRes) Method() string {
+	return r.call.method
+}
+
+// OK returns whether the response is a success, rather than an application
+// error.
+func (r *RelayCallRes) OK() bool {
+	return newLazyCallRes(r.f).OK()
+}
+
+// Header returns the value of the given transport header.
+func (r *RelayCallRes) Header(key string) (string, bool) {
+	return r.headers.get(key)
+}
+
+// Headers returns a copy of the transport headers.
+func (r *RelayCallRes) Headers() map[string]string {
+	return r.headers.toMap()
+}
+
+// SetHeader sets a transport header.
+func (r *RelayCallRes) SetHeader(key, value string) {
+	r.headers.set(key, value)
+}
+
+// DeleteHeader removes a transport header.
+func (r *RelayCallRes) DeleteHeader(key string) {
+	r.headers.delete(key)
+}
+
+// rewrite writes any changes back to the frame.
+func (r *RelayCallRes) rewrite() error {
+	payload := r.f.SizedPayload()
+	buf := make([]byte, 0, len(payload))
+	buf = append(buf, payload[:_resHeadersIndex]...)
+	buf, err := appendRelayHeaders(buf, r.headers.headers)
+	if err != nil {
+		return err
+	}
+	return setRelayPayload(r.f, append(buf, payload[r.headersEnd:]...))
+}
+
+// relayInterceptorError converts an error returned by an interceptor to the
+// error sent to the caller.
+func relayInterceptorError(err error) error {
+	if _, ok := err.(SystemError); ok {
+		return err
+	}
+	return NewSystemError(ErrCodeDeclined, err.Error())
+}
+
+// interceptCallReq runs the request interceptors for a call, and rewrites
+// the frame if they modified it.
+func (r *Relayer) interceptCallReq(f lazyCallReq) (lazyCallReq, error) {
+	if len(r.interceptors) == 0 {
+		return f, nil
+	}
+
+	req := newRelayCallReq(f)
+	for _, interceptor := range r.interceptors {
+		if interceptor.CallReq == nil {
+			continue
+		}
+		if err := interceptor.CallReq(req); err != nil {
+			return f, relayInterceptorError(err)
+		}
+	}
+	if !req.modified() {
+		return f, nil
+	}
+	if err := req.rewrite(); err != nil {
+		return f, relayInterceptorError(err)
+	}
+	return newLazyCallReq(f.Frame), nil
+}
+
+// interceptCallRes runs the response interceptors for the response frame of
+// a call, and rewrites the frame if they modified it.
+func (r *Relayer) interceptCallRes(f *Frame, call *relayCallInfo) error {
+	res := newRelayCallRes(f, call)
+	for _, interceptor := range r.interceptors {
+		if interceptor.CallRes == nil {
+			continue
+		}
+		if err := interceptor.CallRes(res); err != nil {
+			return relayInterceptorError(err)
+		}
+	}
+	if !res.headers.modified {
+		return nil
+	}
+	if err := res.rewrite(); err != nil {
+		return relayInterceptorError(err)
+	}
+	return nil
+}
+
+// relayCallInfo returns the call info needed by response interceptors, or
+// nil if there are none.
+func (r *Relayer) relayCallInfo(f lazyCallReq) *relayCallInfo {
+	for _, interceptor := range r.interceptors {
+		if interceptor.CallRes != nil {
+			return &relayCallInfo{
+				caller:  string(f.Caller()),
+				service: string(f.Service()),
+				method:  string(f.Method()),
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tchannel_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayInterceptorCallReq(t *testing.T) {
+	opts := testutils.NewOpts().SetRelayOnly()
+	opts.RelayInterceptors = []RelayInterceptor{
+		{
+			// Authenticate calls using the routing key.
+			CallReq: func(req *RelayCallReq) error {
+				if token, _ := req.Header(string(RoutingKey)); token != "token" {
+					return errors.New("unauthorized")
+				}
+				req.DeleteHeader(string(RoutingKey))
+				return nil
+			},
+		},
+		{
+			// Route calls for the canary alias to the server, and rename methods.
+			CallReq: func(req *RelayCallReq) error {
+				if req.Service() == "canary" {
+					req.SetService(testutils.DefaultServerName)
+					req.SetHeader(string(ShardKey), "canary")
+				}
+				if req.Method() == "renamed" {
+					req.SetMethod("echo")
+				}
+				return nil
+			},
+		},
+	}
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		testutils.RegisterFunc(ts.Server(), "echo", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+			call := CurrentCall(ctx)
+			assert.Empty(t, call.RoutingKey(), "Routing key should be removed by the relay")
+			return &raw.Res{Arg2: []byte(call.ShardKey()), Arg3: args.Arg3}, nil
+		})
+		client := ts.NewClient(nil)
+
+		call := func(service, method, token string, arg3 []byte) ([]byte, []byte, error) {
+			ctx, cancel := NewContextBuilder(testutils.Timeout(time.Second)).
+				SetRoutingKey(token).
+				SetRetryOptions(&RetryOptions{RetryOn: RetryNever}).
+				Build()
+			defer cancel()
+			arg2, arg3, _, err := raw.Call(ctx, client, ts.HostPort(), service, method, nil, arg3)
+			return arg2, arg3, err
+		}
+
+		_, _, err := call(ts.ServiceName(), "echo", "wrong", nil)
+		assert.Equal(t, ErrCodeDeclined, GetSystemErrorCode(err), "Expected unauthorized call to be declined")
+		assert.Contains(t, err.Error(), "unauthorized", "Unexpected error")
+
+		arg2, arg3, err := call("canary", "echo", "token", []byte("arg3"))
+		require.NoError(t, err, "Call to rewritten service failed")
+		assert.Equal(t, "canary", string(arg2), "Expected header added by the relay")
+		assert.Equal(t, "arg3", string(arg3), "Unexpected arg3")
+
+		// The checksum is updated when the method is changed.
+		_, arg3, err = call(ts.ServiceName(), "renamed", "token", []byte("arg3"))
+		require.NoError(t, err, "Call to rewritten method failed")
+		assert.Equal(t, "arg3", string(arg3), "Unexpected arg3")
+	})
+}
+
+func TestRelayInterceptorRenameFragmented(t *testing.T) {
+	// The fragments after the rejected call frame are not relayed.
+	opts := testutils.NewOpts().SetRelayOnly().AddLogFilter("Failed to relay frame.", 10)
+	opts.RelayInterceptors = []RelayInterceptor{{
+		CallReq: func(req *RelayCallReq) error {
+			req.SetMethod("echo")
+			return nil
+		},
+	}}
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		testutils.RegisterEcho(ts.Server(), nil)
+
+		ctx, cancel := NewContext(testutils.Timeout(time.Second))
+		defer cancel()
+		arg3 := bytes.Repeat([]byte("a"), 3*MaxFramePayloadSize)
+		_, _, _, err := raw.Call(ctx, ts.NewClient(nil), ts.HostPort(), ts.ServiceName(), "renamed", nil, arg3)
+		assert.Equal(t, ErrCodeBadRequest, GetSystemErrorCode(err), "Renaming a fragmented call with a checksum should fail")
+	})
+}
+
+func TestRelayInterceptorCallRes(t *testing.T) {
+	opts := testutils.NewOpts().SetRelayOnly()
+	opts.RelayInterceptors = []RelayInterceptor{{
+		CallRes: func(res *RelayCallRes) error {
+			if res.Method() == "blocked" {
+				return NewSystemError(ErrCodeUnexpected, "response blocked by relay")
+			}
+			if !res.OK() {
+				res.SetHeader("relay-app-error", "true")
+			}
+			return nil
+		},
+	}}
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		testutils.RegisterEcho(ts.Server(), nil)
+		testutils.RegisterFunc(ts.Server(), "blocked", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+			return &raw.Res{}, nil
+		})
+		testutils.RegisterFunc(ts.Server(), "appError", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+			return &raw.Res{IsErr: true, Arg3: []byte("failed")}, nil
+		})
+		client := ts.NewClient(nil)
+
+		ctx, cancel := NewContextBuilder(testutils.Timeout(time.Second)).
+			SetRetryOptions(&RetryOptions{RetryOn: RetryNever}).
+			Build()
+		defer cancel()
+
+		_, _, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "blocked", nil, nil)
+		assert.Equal(t, ErrCodeUnexpected, GetSystemErrorCode(err), "Expected response to be replaced by an error")
+
+		// Responses with rewritten headers are still valid.
+		_, arg3, res, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "appError", nil, nil)
+		require.NoError(t, err, "Call failed")
+		assert.True(t, res.ApplicationError(), "Expected application error")
+		assert.Equal(t, "failed", string(arg3), "Unexpected arg3")
+
+		_, arg3, _, err = raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "echo", nil, []byte("arg3"))
+		require.NoError(t, err, "Call failed")
+		assert.Equal(t, "arg3", string(arg3), "Unexpected arg3")
+	})
+}
@@ -31,6 +31,8 @@ var (
 	_callerNameKeyBytes      = []byte(CallerName)
 	_routingDelegateKeyBytes = []byte(RoutingDelegate)
 	_routingKeyKeyBytes      = []byte(RoutingKey)
+	_shardKeyKeyBytes        = []byte(ShardKey)
+	_priorityKeyBytes        = []byte(Priority)
 )
 
 const (
@@ -87,7 +89,7 @@ func (cr lazyCallRes) OK() bool {
 type lazyCallReq struct {
 	*Frame
 
-	caller, method, delegate, key []byte
+	caller, method, delegate, key, shardKey, priority []byte
 }
 
 // TODO: Consider pooling lazyCallReq and using pointers to the struct.
@@ -121,6 +123,10 @@ func newLazyCallReq(f *Frame) lazyCallReq {
 			cr.delegate = val
 		} else if bytes.Equal(key, _routingKeyKeyBytes) {
 			cr.key = val
+		} else if bytes.Equal(key, _shardKeyKeyBytes) {
+			cr.shardKey = val
+		} else if bytes.Equal(key, _priorityKeyBytes) {
+			cr.priority = val
 		}
 	}
 
@@ -161,6 +167,16 @@ func (f lazyCallReq) RoutingKey() []byte {
 	return f.key
 }
 
+// ShardKey returns the shard key for this call req, if any.
+func (f lazyCallReq) ShardKey() []byte {
+	return f.shardKey
+}
+
+// Priority returns the priority of this callReq.
+func (f lazyCallReq) Priority() CallPriority {
+	return parsePriority(string(f.priority))
+}
+
 // TTL returns the time to live for this callReq.
 func (f lazyCallReq) TTL() time.Duration {
 	ttl := binary.BigEndian.Uint32(f.Payload[_ttlIndex : _ttlIndex+_ttlLen])
@@ -187,7 +203,7 @@ func (f lazyCallReq) HasMoreFragments() bool {
 // this RPC req-res.
 func finishesCall(f *Frame) bool {
 	switch f.messageType() {
-	case messageTypeError:
+	case messageTypeError, messageTypeCancel, messageTypeClaim:
 		return true
 	case messageTypeCallRes, messageTypeCallResContinue:
 		flags := f.Payload[_flagsIndex]
@@ -36,6 +36,7 @@ const (
 	reqHasCaller
 	reqHasDelegate
 	reqHasRoutingKey
+	reqHasShardKey
 	reqHasChecksum
 	reqTotalCombinations
 	reqHasAll testCallReq = reqTotalCombinations - 1
@@ -69,6 +70,9 @@ func (cr testCallReq) req() lazyCallReq {
 	if cr&reqHasRoutingKey != 0 {
 		headers["rk"] = "fake-routingkey"
 	}
+	if cr&reqHasShardKey != 0 {
+		headers["sk"] = "fake-shardkey"
+	}
 	writeHeaders(payload, headers)
 
 	if cr&reqHasChecksum == 0 {
@@ -250,6 +254,17 @@ func TestLazyCallReqRoutingKey(t *testing.T) {
 	})
 }
 
+func TestLazyCallReqShardKey(t *testing.T) {
+	withLazyCallReqCombinations(func(crt testCallReq) {
+		cr := crt.req()
+		if crt&reqHasShardKey == 0 {
+			assert.Equal(t, []byte(nil), cr.ShardKey(), "Unexpected shard key.")
+		} else {
+			assert.Equal(t, "fake-shardkey", string(cr.ShardKey()), "Shard key mismatch.")
+		}
+	})
+}
+
 func TestLazyCallReqMethod(t *testing.T) {
 	withLazyCallReqCombinations(func(crt testCallReq) {
 		cr := crt.req()
@@ -0,0 +1,97 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tchannel
+
+import (
+	"sync"
+	"time"
+)
+
+// relayEdge is a pair of caller and callee services.
+type relayEdge struct {
+	caller  string
+	service string
+}
+
+// RelayRateLimiter limits the rate of relayed calls for each pair of caller
+// and callee services. A nil RelayRateLimiter allows all calls.
+type RelayRateLimiter struct {
+	sync.RWMutex
+
+	limits map[relayEdge]*rateLimiter
+}
+
+// NewRelayRateLimiter returns a RelayRateLimiter with no limits.
+func NewRelayRateLimiter() *RelayRateLimiter {
+	return &RelayRateLimiter{limits: make(map[relayEdge]*rateLimiter)}
+}
+
+// SetLimit sets the rate limit for relayed calls from caller to service,
+// replacing any existing limit. If caller is empty, the limit is shared by
+// calls to service from all callers that do not have their own limit.
+// Relayed calls are never delayed, so MaxWait is ignored, and an RPS of zero
+// removes the limit.
+func (l *RelayRateLimiter) SetLimit(caller, service string, opts RateLimitOptions) {
+	opts.MaxWait = 0
+	limiter := newRateLimiter(opts, time.Now, nil)
+
+	l.Lock()
+	defer l.Unlock()
+
+	edge := relayEdge{caller, service}
+	if limiter == nil {
+		delete(l.limits, edge)
+		return
+	}
+	l.limits[edge] = limiter
+}
+
+// allow returns whether a call from caller to service is allowed.
+func (l *RelayRateLimiter) allow(caller, service []byte) bool {
+	if l == nil {
+		return true
+	}
+
+	l.RLock()
+	limiter, ok := l.limits[relayEdge{string(caller), string(service)}]
+	if !ok {
+		limiter = l.limits[relayEdge{"", string(service)}]
+	}
+	l.RUnlock()
+
+	if limiter == nil {
+		return true
+	}
+	_, ok = limiter.reserve(0 /* maxWait */)
+	return ok
+}
+
+// rateLimited rejects a call that was over its relay rate limit.
+func (r *Relayer) rateLimited(f lazyCallReq) {
+	caller, service := string(f.Caller()), string(f.Service())
+
+	tags := cloneTags(r.conn.commonStatsTags)
+	tags["source-service"] = caller
+	tags["target-service"] = service
+	r.conn.statsReporter.IncCounter("relay.calls.rate-limited", tags, 1)
+
+	err := NewSystemError(ErrCodeBusy, "relay rate limit exceeded for calls from %q to %q", caller, service)
+	r.conn.SendSystemError(f.Header.ID, f.Span(), err)
+}
@@ -0,0 +1,190 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/uber/tchannel-go/trand"
+
+	"github.com/uber-go/atomic"
+)
+
+// RelayShadowOptions configures the shadowing of relayed calls to a service.
+type RelayShadowOptions struct {
+	// HostPort is the shadow destination that copies of calls are sent to.
+	HostPort string
+
+	// Percentage is the percentage of calls, between 0 and 100, that are
+	// copied to the shadow destination.
+	Percentage float64
+}
+
+// relayShadow is the shadow destination for a service.
+type relayShadow struct {
+	RelayShadowOptions
+
+	// connecting is set while a connection to the shadow destination is
+	// being created.
+	connecting atomic.Bool
+}
+
+// RelayShadower copies a percentage of the relayed calls to each service to a
+// shadow destination, such as a new version of the service that is being
+// dark launched. Shadowed calls are relayed to the original destination as
+// usual, and responses from the shadow destination are discarded. A nil
+// RelayShadower does not shadow any calls.
+type RelayShadower struct {
+	sync.RWMutex
+
+	shadows map[string]*relayShadow
+
+	rngMut sync.Mutex
+	rng    *rand.Rand
+}
+
+// NewRelayShadower returns a RelayShadower that does not shadow any calls
+// until shadows are set.
+func NewRelayShadower() *RelayShadower {
+	return &RelayShadower{
+		shadows: make(map[string]*relayShadow),
+		rng:     trand.NewSeeded(),
+	}
+}
+
+// SetShadow sets the shadow destination for calls to service, replacing any
+// existing shadow. An empty HostPort or a Percentage of zero removes the
+// shadow.
+func (s *RelayShadower) SetShadow(service string, opts RelayShadowOptions) {
+	s.Lock()
+	defer s.Unlock()
+
+	if opts.HostPort == "" || opts.Percentage <= 0 {
+		delete(s.shadows, service)
+		return
+	}
+	s.shadows[service] = &relayShadow{RelayShadowOptions: opts}
+}
+
+// shadowFor returns the shadow destination for a call to service, if the call
+// should be shadowed.
+func (s *RelayShadower) shadowFor(service []byte) (*relayShadow, bool) {
+	if s == nil {
+		return nil, false
+	}
+
+	s.RLock()
+	shadow, ok := s.shadows[string(service)]
+	s.RUnlock()
+	if !ok {
+		return nil, false
+	}
+
+	s.rngMut.Lock()
+	sampled := s.rng.Float64()*100 < shadow.Percentage
+	s.rngMut.Unlock()
+	return shadow, sampled
+}
+
+// relayShadowLeg is the copy of a relayed call that is sent to a shadow
+// destination.
+type relayShadowLeg struct {
+	destination *Relayer
+	remapID     uint32
+}
+
+// startShadow sends a copy of the call to its shadow destination, if the call
+// should be shadowed, and returns the shadow leg that later frames for the call
+// are copied to. Calls are only shadowed while there is an active connection
+// to the shadow destination, so that shadowing never delays the call.
+func (r *Relayer) startShadow(f lazyCallReq, ttl time.Duration) *relayShadowLeg {
+	shadow, ok := r.shadower.shadowFor(f.Service())
+	if !ok {
+		return nil
+	}
+
+	tags := cloneTags(r.conn.commonStatsTags)
+	tags["source-service"] = string(f.Caller())
+	tags["target-service"] = string(f.Service())
+
+	peer := r.peers.GetOrAdd(shadow.HostPort)
+	conn, ok := peer.getActiveConn()
+	if !ok {
+		if !shadow.connecting.Swap(true) {
+			go func() {
+				defer shadow.connecting.Store(false)
+				if _, err := peer.getConnectionRelay(ttl); err != nil {
+					r.logger.WithFields(
+						ErrField(err),
+						LogField{"dest", string(f.Service())},
+						LogField{"shadowHostPort", shadow.HostPort},
+					).Info("Failed to connect to relay shadow destination.")
+				}
+			}()
+		}
+		r.conn.statsReporter.IncCounter("relay.shadow.skipped", tags, 1)
+		return nil
+	}
+	if canHandle, _ := conn.relay.canHandleNewCall(); !canHandle {
+		r.conn.statsReporter.IncCounter("relay.shadow.skipped", tags, 1)
+		return nil
+	}
+
+	leg := &relayShadowLeg{
+		destination: conn.relay,
+		remapID:     conn.NextMessageID(),
+	}
+	conn.relay.addRelayItem(false /* isOriginator */, leg.remapID, ttl, relayItem{
+		remapID:     f.Header.ID,
+		destination: r,
+		span:        f.Span(),
+		isShadow:    true,
+	})
+	r.forwardShadow(f.Frame, leg)
+	r.conn.statsReporter.IncCounter("relay.shadow.calls", tags, 1)
+	return leg
+}
+
+// forwardShadow sends a copy of a request frame to the shadow leg of its call,
+// unless the shadow leg has already finished or failed.
+func (r *Relayer) forwardShadow(f *Frame, leg *relayShadowLeg) {
+	if item, ok := leg.destination.inbound.Get(leg.remapID); !ok || item.tomb {
+		return
+	}
+
+	shadowFrame := r.conn.opts.FramePool.Get()
+	shadowFrame.Header = f.Header
+	shadowFrame.Header.ID = leg.remapID
+	copy(shadowFrame.Payload, f.SizedPayload())
+	leg.destination.Receive(shadowFrame, requestFrame)
+}
+
+// handleShadowResponse discards a response frame from a shadow destination.
+func (r *Relayer) handleShadowResponse(f *Frame) {
+	finished := finishesCall(f)
+	id := f.Header.ID
+	r.conn.opts.FramePool.Release(f)
+	if finished {
+		r.finishRelayItem(r.inbound, id)
+	}
+}
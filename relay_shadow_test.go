@@ -0,0 +1,92 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayShadow(t *testing.T) {
+	shadower := NewRelayShadower()
+	opts := testutils.NewOpts().SetRelayOnly()
+	opts.RelayShadower = shadower
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		testutils.RegisterEcho(ts.Server(), nil)
+
+		shadowCalls := make(chan []byte, 100)
+		shadow := testutils.NewServer(t, testutils.NewOpts().SetServiceName(ts.ServiceName()))
+		defer shadow.Close()
+		testutils.RegisterFunc(shadow, "echo", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+			shadowCalls <- args.Arg3
+			return nil, errors.New("shadow responses should be ignored")
+		})
+
+		shadower.SetShadow(ts.ServiceName(), RelayShadowOptions{
+			HostPort:   shadow.PeerInfo().HostPort,
+			Percentage: 100,
+		})
+
+		client := ts.NewClient(nil)
+		call := func(arg3 []byte) {
+			ctx, cancel := NewContext(testutils.Timeout(time.Second))
+			defer cancel()
+			_, res, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "echo", nil, arg3)
+			require.NoError(t, err, "call failed")
+			assert.Equal(t, arg3, res, "unexpected response")
+		}
+
+		// Calls are only shadowed once the relay is connected to the shadow.
+		require.True(t, testutils.WaitFor(time.Second, func() bool {
+			call([]byte("connect"))
+			return len(shadowCalls) > 0
+		}), "expected calls to be shadowed")
+		for len(shadowCalls) > 0 {
+			<-shadowCalls
+		}
+
+		// Fragmented calls are copied to the shadow in full.
+		for _, arg3 := range [][]byte{[]byte("small"), testutils.RandBytes(3 * MaxFramePayloadSize)} {
+			call(arg3)
+			select {
+			case got := <-shadowCalls:
+				assert.Equal(t, arg3, got, "unexpected shadowed arg3")
+			case <-time.After(testutils.Timeout(time.Second)):
+				t.Fatalf("timed out waiting for shadowed call")
+			}
+		}
+
+		// Removing the shadow stops shadowing.
+		shadower.SetShadow(ts.ServiceName(), RelayShadowOptions{})
+		call([]byte("not shadowed"))
+		assert.Empty(t, shadowCalls, "unexpected shadowed call")
+	})
+}
@@ -21,7 +21,9 @@
 package tchannel_test
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"runtime"
 	"strings"
@@ -41,7 +43,6 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/uber-go/atomic"
-	"golang.org/x/net/context"
 )
 
 type relayTest struct {
@@ -375,6 +376,58 @@ func TestLargeTimeoutsAreClamped(t *testing.T) {
 	})
 }
 
+func TestRelayAdaptiveTimeouts(t *testing.T) {
+	const minSamples = 10
+
+	opts := serviceNameOpts("echo-service").
+		SetRelayOnly().
+		SetRelayAdaptiveTimeouts(RelayAdaptiveTimeoutOptions{
+			Factor:     2,
+			MinTimeout: 10 * time.Millisecond,
+			MinSamples: minSamples,
+		}).
+		DisableLogVerification() // handler returns after deadline
+
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		srv := ts.Server()
+		client := ts.NewClient(nil)
+
+		var slow atomic.Bool
+		unblock := make(chan struct{})
+		defer close(unblock) // let server shut down cleanly
+		testutils.RegisterFunc(srv, "echo", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+			if slow.Load() {
+				<-unblock
+			}
+			return &raw.Res{Arg2: args.Arg2, Arg3: args.Arg3}, nil
+		})
+
+		for i := 0; i < minSamples; i++ {
+			ctx, cancel := NewContext(testutils.Timeout(time.Second))
+			_, _, _, err := raw.Call(ctx, client, ts.HostPort(), "echo-service", "echo", nil, nil)
+			cancel()
+			require.NoError(t, err, "Call %v failed", i)
+		}
+
+		slow.Store(true)
+		started := time.Now()
+		ctx, cancel := NewContext(time.Minute)
+		defer cancel()
+		_, _, _, err := raw.Call(ctx, client, ts.HostPort(), "echo-service", "echo", nil, nil)
+		assert.Equal(t, ErrTimeout, err, "Expected call to time out")
+		assert.True(t, time.Since(started) < testutils.Timeout(time.Second),
+			"Expected adaptive timeout to end the call early")
+
+		calls := relaytest.NewMockStats()
+		for i := 0; i < minSamples; i++ {
+			calls.Add(client.PeerInfo().ServiceName, "echo-service", "echo").Succeeded().End()
+		}
+		calls.Add(client.PeerInfo().ServiceName, "echo-service", "echo").
+			Failed("relay-adaptive-timeout").End()
+		ts.AssertRelayStats(calls)
+	})
+}
+
 // TestRelayConcurrentCalls makes many concurrent calls and ensures that
 // we don't try to reuse any frames once they've been released.
 func TestRelayConcurrentCalls(t *testing.T) {
@@ -791,3 +844,37 @@ func TestRelayConcurrentNewConnectionAttempts(t *testing.T) {
 		assert.Equal(t, 1, inboundConns, "Expected a single inbound connection to the server")
 	})
 }
+
+func TestRelayShardKeyAffinity(t *testing.T) {
+	opts := testutils.NewOpts().SetRelayOnly()
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		for i := 0; i < 3; i++ {
+			server := ts.NewServer(testutils.NewOpts().SetServiceName("svc"))
+			hostPort := server.PeerInfo().HostPort
+			testutils.RegisterFunc(server, "hostPort", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+				assert.Equal(t, string(args.Arg3), CurrentCall(ctx).ShardKey(), "shard key should be propagated")
+				return &raw.Res{Arg3: []byte(hostPort)}, nil
+			})
+		}
+		client := ts.NewClient(nil)
+
+		callWithKey := func(shardKey string) string {
+			ctx, cancel := NewContextBuilder(testutils.Timeout(time.Second)).SetShardKey(shardKey).Build()
+			defer cancel()
+			_, arg3, _, err := raw.Call(ctx, client, ts.HostPort(), "svc", "hostPort", nil, []byte(shardKey))
+			require.NoError(t, err, "call with shard key %q failed", shardKey)
+			return string(arg3)
+		}
+
+		selected := make(map[string]struct{})
+		for i := 0; i < 20; i++ {
+			shardKey := fmt.Sprint("key-", i)
+			hostPort := callWithKey(shardKey)
+			for j := 0; j < 3; j++ {
+				assert.Equal(t, hostPort, callWithKey(shardKey), "calls with the same shard key should use the same peer")
+			}
+			selected[hostPort] = struct{}{}
+		}
+		assert.True(t, len(selected) > 1, "shard keys should be spread across peers")
+	})
+}
@@ -73,6 +73,16 @@ type reqResWriter struct {
 	messageForFragment messageForFragment
 	log                Logger
 	err                error
+
+	// accessLog counts the bytes written, if the channel has an access log.
+	accessLog *accessLogCall
+
+	// onFailed is an optional callback for when the writer fails.
+	onFailed func(error)
+
+	// errInfo describes the call, if the writer's errors are wrapped in a
+	// CallError.
+	errInfo *callErrorInfo
 }
 
 //go:generate stringer -type=reqResReaderState
@@ -124,6 +134,7 @@ func (w *reqResWriter) newFragment(initial bool, checksum Checksum) (*writableFr
 	wbuf := typed.NewWriteBuffer(frame.Payload[:])
 	fragment := new(writableFragment)
 	fragment.frame = frame
+	fragment.large = frame.large
 	fragment.flagsRef = wbuf.DeferByte()
 	if err := message.write(wbuf); err != nil {
 		return nil, err
@@ -142,17 +153,28 @@ func (w *reqResWriter) flushFragment(fragment *writableFragment) error {
 	}
 
 	frame := fragment.frame.(*Frame)
-	frame.Header.SetPayloadSize(uint16(fragment.contents.BytesWritten()))
+	frame.setPayloadSize(fragment.contents.BytesWritten())
 
 	if err := w.mex.checkError(); err != nil {
 		return w.failed(err)
 	}
+	w.accessLog.sent(frame)
+	if w.conn.opts.SendBufferFullPolicy == SendBufferFailFast {
+		select {
+		case w.conn.sendCh <- frame:
+			w.conn.sendBufferQueued()
+			return nil
+		default:
+			return w.failed(ErrSendBufferBusy)
+		}
+	}
 	select {
 	case <-w.mex.ctx.Done():
 		return w.failed(GetContextError(w.mex.ctx.Err()))
 	case <-w.mex.errCh.c:
 		return w.failed(w.mex.errCh.err)
 	case w.conn.sendCh <- frame:
+		w.conn.sendBufferQueued()
 		return nil
 	}
 }
@@ -165,7 +187,10 @@ func (w *reqResWriter) failed(err error) error {
 	}
 
 	w.mex.shutdown()
-	w.err = err
+	w.err = w.errInfo.wrap(err, false /* remote */)
+	if w.onFailed != nil {
+		w.onFailed(err)
+	}
 	return w.err
 }
 
@@ -189,6 +214,16 @@ type reqResReader struct {
 	previousFragment   *readableFragment
 	log                Logger
 	err                error
+
+	// accessLog counts the bytes read, if the channel has an access log.
+	accessLog *accessLogCall
+
+	// onFailed is an optional callback for when the reader fails.
+	onFailed func(error)
+
+	// errInfo describes the call, if the reader's errors are wrapped in a
+	// CallError.
+	errInfo *callErrorInfo
 }
 
 // arg1Reader returns an ArgReader to read arg1.
@@ -238,13 +273,15 @@ func (r *reqResReader) recvNextFragment(initial bool) (*readableFragment, error)
 		if err, ok := err.(errorMessage); ok {
 			// If we received a serialized error from the other side, then we should go through
 			// the normal doneReading path so stats get updated with this error.
-			r.err = err.AsSystemError()
+			r.err = r.errInfo.wrap(err.AsSystemError(), true /* remote */)
 			return nil, err
 		}
 
 		return nil, r.failed(err)
 	}
 
+	r.accessLog.received(frame)
+
 	// Parse the message and setup the fragment
 	fragment, err := parseInboundFragment(r.mex.framePool, frame, message)
 	if err != nil {
@@ -273,7 +310,10 @@ func (r *reqResReader) failed(err error) error {
 	}
 
 	r.mex.shutdown()
-	r.err = err
+	r.err = r.errInfo.wrap(err, false /* remote */)
+	if r.onFailed != nil {
+		r.onFailed(err)
+	}
 	return r.err
 }
 
@@ -287,8 +327,12 @@ func parseInboundFragment(framePool FramePool, frame *Frame, message message) (*
 	}
 
 	fragment.checksumType = ChecksumType(rbuf.ReadSingleByte())
+	if !fragment.checksumType.valid() {
+		return nil, errUnsupportedChecksumType
+	}
 	fragment.checksum = rbuf.ReadBytes(fragment.checksumType.ChecksumSize())
 	fragment.contents = rbuf
+	fragment.large = frame.large
 	fragment.onDone = func() {
 		framePool.Release(frame)
 	}
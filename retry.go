@@ -21,11 +21,11 @@
 package tchannel
 
 import (
+	"context"
+	"errors"
 	"net"
 	"sync"
 	"time"
-
-	"golang.org/x/net/context"
 )
 
 // RetryOn represents the types of errors to retry on.
@@ -63,13 +63,26 @@ type RequestState struct {
 	// SelectedPeers is a set of host:ports that have been selected previously.
 	SelectedPeers map[string]struct{}
 	// Attempt is 1 for the first attempt, and so on.
-	Attempt   int
-	retryOpts *RetryOptions
+	Attempt     int
+	retryOpts   *RetryOptions
+	retryBudget *retryBudget
+
+	// hedged tracks the peers selected by all concurrent attempts of a
+	// hedged request, or is nil if the attempt is not hedged.
+	hedged *hedgedPeers
+
+	// idempotencyKey is sent with every attempt if the request may be
+	// retried.
+	idempotencyKey string
 }
 
 // RetriableFunc is the type of function that can be passed to RunWithRetry.
 type RetriableFunc func(context.Context, *RequestState) error
 
+// RetriableResultFunc is the type of function that can be passed to
+// RunWithRetryResult. Each attempt returns its own result.
+type RetriableResultFunc func(context.Context, *RequestState) (interface{}, error)
+
 func isNetError(err error) bool {
 	// TODO(prashantv): Should TChannel internally these to ErrCodeNetwork before returning
 	// them to the user?
@@ -116,6 +129,21 @@ func (r RetryOn) CanRetry(err error) bool {
 	return false
 }
 
+// RetryPredicate decides whether a failed attempt can be retried. It is passed
+// the error, the error's system error code, and the attempt that failed,
+// starting from 1.
+type RetryPredicate func(err error, code SystemErrCode, attempt int) bool
+
+// AppErrorClassifier decides whether an application error can be retried. It
+// is passed the response's application headers and the application error as
+// decoded by the format's client, which is the result struct for Thrift, and
+// the ErrApplication for JSON and Proto.
+type AppErrorClassifier func(headers map[string]string, appErr interface{}) bool
+
+// errRetryableAppError is returned by a RetriableFunc for an application error
+// that should be retried, see RequestState.RetryAppError.
+var errRetryableAppError = errors.New("retryable application error")
+
 // RetryOptions are the retry options used to configure RunWithRetry.
 type RetryOptions struct {
 	// MaxAttempts is the maximum number of calls and retries that will be made.
@@ -125,9 +153,34 @@ type RetryOptions struct {
 	// RetryOn is the types of errors to retry on.
 	RetryOn RetryOn
 
+	// ShouldRetry, if set, is used instead of RetryOn to decide whether an
+	// error can be retried.
+	ShouldRetry RetryPredicate
+
+	// ClassifyAppError, if set, decides whether application errors can be
+	// retried. Application errors are never retried if this is not set.
+	ClassifyAppError AppErrorClassifier
+
 	// TimeoutPerAttempt is the per-retry timeout to use.
 	// If this is zero, then the original timeout is used.
 	TimeoutPerAttempt time.Duration
+
+	// IgnoreRetryBudget allows the request to retry even if the channel's
+	// retry budget (see ChannelOptions.RetryBudget) is exhausted. Retries
+	// are still counted against the budget.
+	IgnoreRetryBudget bool
+
+	// HedgeDelay enables hedging if it is set. When an attempt has not
+	// completed after HedgeDelay, a duplicate attempt is started, preferring
+	// a peer that has not been selected, and the first successful attempt is
+	// used while the other is cancelled. Hedged attempts are counted against
+	// the retry budget, and should only be used for idempotent calls.
+	//
+	// With hedging, attempts run concurrently, so a RetriableFunc passed to
+	// RunWithRetry must be safe for concurrent use and must not write state
+	// shared by attempts, such as variables captured for the response. Use
+	// RunWithRetryResult to return the result of the successful attempt.
+	HedgeDelay time.Duration
 }
 
 var defaultRetryOptions = &RetryOptions{
@@ -139,12 +192,7 @@ var requestStatePool = sync.Pool{
 }
 
 func getRetryOptions(ctx context.Context) *RetryOptions {
-	params := getTChannelParams(ctx)
-	if params == nil {
-		return defaultRetryOptions
-	}
-
-	opts := params.retryOptions
+	opts := CurrentRetryOptions(ctx)
 	if opts == nil {
 		return defaultRetryOptions
 	}
@@ -155,13 +203,40 @@ func getRetryOptions(ctx context.Context) *RetryOptions {
 	return opts
 }
 
+// canRetry returns whether an error from the given attempt can be retried.
+func (o *RetryOptions) canRetry(err error, attempt int) bool {
+	if err == errRetryableAppError {
+		// The application error has already been classified as retryable.
+		return true
+	}
+	if o.ShouldRetry != nil {
+		return o.ShouldRetry(err, getErrCode(err), attempt)
+	}
+	return o.RetryOn.CanRetry(err)
+}
+
 // HasRetries will return true if there are more retries left.
 func (rs *RequestState) HasRetries(err error) bool {
 	if rs == nil {
 		return false
 	}
 	rOpts := rs.retryOpts
-	return rs.Attempt < rOpts.MaxAttempts && rOpts.RetryOn.CanRetry(err)
+	return rs.Attempt < rOpts.MaxAttempts && rOpts.canRetry(err, rs.Attempt) &&
+		(rOpts.IgnoreRetryBudget || rs.retryBudget.hasBudget())
+}
+
+// RetryAppError returns an error that makes RunWithRetry retry the request if
+// RetryOptions.ClassifyAppError classifies the application error as retryable
+// and there are retries left. Otherwise, it returns nil, and the application
+// error should be returned to the caller as usual.
+func (rs *RequestState) RetryAppError(headers map[string]string, appErr interface{}) error {
+	if rs == nil || rs.retryOpts.ClassifyAppError == nil {
+		return nil
+	}
+	if !rs.retryOpts.ClassifyAppError(headers, appErr) || !rs.HasRetries(errRetryableAppError) {
+		return nil
+	}
+	return errRetryableAppError
 }
 
 // SinceStart returns the time since the start of the request. If there is no request state,
@@ -197,6 +272,7 @@ func (rs *RequestState) AddSelectedPeer(hostPort string) {
 		rs.SelectedPeers[hostPort] = struct{}{}
 		rs.SelectedPeers[host] = struct{}{}
 	}
+	rs.hedged.add(hostPort, host)
 }
 
 // RetryCount returns the retry attempt this is. Essentially, Attempt - 1.
@@ -210,31 +286,50 @@ func (rs *RequestState) RetryCount() int {
 // RunWithRetry will take a function that makes the TChannel call, and will
 // rerun it as specifed in the RetryOptions in the Context.
 func (ch *Channel) RunWithRetry(runCtx context.Context, f RetriableFunc) error {
-	var err error
+	_, err := ch.RunWithRetryResult(runCtx, func(ctx context.Context, rs *RequestState) (interface{}, error) {
+		return nil, f(ctx, rs)
+	})
+	return err
+}
 
+// RunWithRetryResult is like RunWithRetry, but returns the result of the
+// last attempt, which is the successful attempt if there is one. Since each
+// attempt returns its own result, f does not need to capture the response,
+// which makes it safe to use with hedging (see RetryOptions.HedgeDelay).
+func (ch *Channel) RunWithRetryResult(runCtx context.Context, f RetriableResultFunc) (interface{}, error) {
+	var (
+		res interface{}
+		err error
+	)
 	opts := getRetryOptions(runCtx)
 	rs := ch.getRequestState(opts)
 	defer requestStatePool.Put(rs)
+	ch.retryBudget.recordRequest()
 
 	for i := 0; i < opts.MaxAttempts; i++ {
 		rs.Attempt++
 
-		if opts.TimeoutPerAttempt == 0 {
-			err = f(runCtx, rs)
+		if opts.HedgeDelay > 0 {
+			res, err = ch.runHedged(runCtx, opts, rs, f)
 		} else {
-			attemptCtx, cancel := context.WithTimeout(runCtx, opts.TimeoutPerAttempt)
-			err = f(attemptCtx, rs)
-			cancel()
+			res, err = runAttempt(runCtx, opts, rs, f)
 		}
 
 		if err == nil {
-			return nil
+			return res, nil
 		}
-		if !opts.RetryOn.CanRetry(err) {
+		if !opts.canRetry(err, rs.Attempt) {
 			if ch.log.Enabled(LogLevelInfo) {
 				ch.log.WithFields(ErrField(err)).Info("Failed after non-retriable error.")
 			}
-			return err
+			return res, err
+		}
+		if rs.Attempt < opts.MaxAttempts && !ch.retryBudget.tryRetry(opts.IgnoreRetryBudget) {
+			ch.statsReporter.IncCounter("outbound.calls.retry-budget-exhausted", ch.StatsTags(), 1)
+			if ch.log.Enabled(LogLevelInfo) {
+				ch.log.WithFields(ErrField(err)).Info("Failed after retryable error as the retry budget is exhausted.")
+			}
+			return res, lastAttemptErr(err)
 		}
 
 		ch.log.WithFields(
@@ -245,14 +340,39 @@ func (ch *Channel) RunWithRetry(runCtx context.Context, f RetriableFunc) error {
 	}
 
 	// Too many retries, return the last error
+	return res, lastAttemptErr(err)
+}
+
+// lastAttemptErr returns the error to return from RunWithRetry when err is from
+// the last attempt. A retryable application error is not returned, since the
+// RetriableFunc handles the application error of the last attempt.
+func lastAttemptErr(err error) error {
+	if err == errRetryableAppError {
+		return nil
+	}
 	return err
 }
 
+// runAttempt runs a single attempt of f, using the per-attempt timeout if set.
+func runAttempt(ctx context.Context, opts *RetryOptions, rs *RequestState, f RetriableResultFunc) (interface{}, error) {
+	if opts.TimeoutPerAttempt == 0 {
+		return f(ctx, rs)
+	}
+
+	attemptCtx, cancel := context.WithTimeout(ctx, opts.TimeoutPerAttempt)
+	defer cancel()
+	return f(attemptCtx, rs)
+}
+
 func (ch *Channel) getRequestState(retryOpts *RetryOptions) *RequestState {
 	rs := requestStatePool.Get().(*RequestState)
 	*rs = RequestState{
-		Start:     ch.timeNow(),
-		retryOpts: retryOpts,
+		Start:       ch.timeNow(),
+		retryOpts:   retryOpts,
+		retryBudget: ch.retryBudget,
+	}
+	if retryOpts.MaxAttempts > 1 && (retryOpts.RetryOn != RetryNever || retryOpts.ShouldRetry != nil) {
+		rs.idempotencyKey = newIdempotencyKey()
 	}
 	return rs
 }
@@ -0,0 +1,151 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultRetryBudgetWindow = 10 * time.Second
+
+	// retryBudgetBuckets is the number of buckets the window is split into.
+	// Requests and retries expire from the window one bucket at a time.
+	retryBudgetBuckets = 10
+)
+
+// RetryBudgetOptions configures a retry budget shared by all calls made using
+// RunWithRetry on a Channel. Once the budget is exhausted, RunWithRetry
+// returns errors without retrying, which avoids amplifying load on peers
+// during an outage.
+type RetryBudgetOptions struct {
+	// Ratio is the maximum number of retries as a fraction of the number of
+	// requests made over the window. E.g. 0.2 allows 1 retry for every
+	// 5 requests. Zero disables the retry budget.
+	Ratio float64
+
+	// MinRetriesPerSecond is the number of retries that are always allowed,
+	// regardless of the request volume, so that retries are possible when
+	// few requests are made.
+	MinRetriesPerSecond float64
+
+	// Window is the period over which requests and retries are counted.
+	// If this is 0, the default of 10 seconds is used.
+	Window time.Duration
+}
+
+type retryBudgetBucket struct {
+	// id identifies the period of time that the counts are for.
+	id       int64
+	requests int
+	retries  int
+}
+
+// retryBudget tracks requests and retries over a sliding window. A nil
+// retryBudget is disabled, and allows all retries.
+type retryBudget struct {
+	sync.Mutex
+
+	opts        RetryBudgetOptions
+	timeNow     func() time.Time
+	bucketWidth time.Duration
+	minRetries  float64
+	buckets     [retryBudgetBuckets]retryBudgetBucket
+}
+
+func newRetryBudget(opts RetryBudgetOptions, timeNow func() time.Time) *retryBudget {
+	if opts.Ratio <= 0 {
+		return nil
+	}
+	if opts.Window <= 0 {
+		opts.Window = defaultRetryBudgetWindow
+	}
+	return &retryBudget{
+		opts:        opts,
+		timeNow:     timeNow,
+		bucketWidth: opts.Window / retryBudgetBuckets,
+		minRetries:  opts.MinRetriesPerSecond * opts.Window.Seconds(),
+	}
+}
+
+// currentBucketLocked returns the bucket for the current time, resetting it
+// if it holds counts from a previous window.
+func (b *retryBudget) currentBucketLocked() *retryBudgetBucket {
+	id := b.timeNow().UnixNano() / int64(b.bucketWidth)
+	bucket := &b.buckets[id%retryBudgetBuckets]
+	if bucket.id != id {
+		*bucket = retryBudgetBucket{id: id}
+	}
+	return bucket
+}
+
+// hasBudgetLocked returns whether another retry is allowed in the current window.
+func (b *retryBudget) hasBudgetLocked() bool {
+	current := b.currentBucketLocked()
+
+	var requests, retries int
+	for _, bucket := range b.buckets {
+		if current.id-bucket.id < retryBudgetBuckets {
+			requests += bucket.requests
+			retries += bucket.retries
+		}
+	}
+	return float64(retries+1) <= b.opts.Ratio*float64(requests)+b.minRetries
+}
+
+// recordRequest records a new request, which increases the retry budget.
+func (b *retryBudget) recordRequest() {
+	if b == nil {
+		return
+	}
+
+	b.Lock()
+	b.currentBucketLocked().requests++
+	b.Unlock()
+}
+
+// hasBudget returns whether a retry would be allowed, without using the budget.
+func (b *retryBudget) hasBudget() bool {
+	if b == nil {
+		return true
+	}
+
+	b.Lock()
+	defer b.Unlock()
+	return b.hasBudgetLocked()
+}
+
+// tryRetry returns whether a retry is allowed, and if so, records the retry.
+// If force is set, the retry is always allowed and recorded.
+func (b *retryBudget) tryRetry(force bool) bool {
+	if b == nil {
+		return true
+	}
+
+	b.Lock()
+	defer b.Unlock()
+	if !force && !b.hasBudgetLocked() {
+		return false
+	}
+	b.currentBucketLocked().retries++
+	return true
+}
@@ -21,6 +21,7 @@
 package tchannel_test
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -30,7 +31,6 @@ import (
 	"github.com/uber/tchannel-go/testutils"
 
 	"github.com/stretchr/testify/assert"
-	"golang.org/x/net/context"
 )
 
 func TestRequestStateRetry(t *testing.T) {
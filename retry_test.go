@@ -21,17 +21,19 @@
 package tchannel_test
 
 import (
+	"context"
 	"net"
+	"sync"
 	"testing"
 	"time"
 
 	. "github.com/uber/tchannel-go"
 
+	"github.com/uber/tchannel-go/raw"
 	"github.com/uber/tchannel-go/testutils"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"golang.org/x/net/context"
 )
 
 func createFuncToRetry(t *testing.T, errors ...error) (RetriableFunc, *int) {
@@ -164,6 +166,159 @@ func TestRetryTillMaxAttempts(t *testing.T) {
 	}
 }
 
+func TestRetryShouldRetry(t *testing.T) {
+	ch := testutils.NewClient(t, nil)
+	defer ch.Close()
+
+	type predicateCall struct {
+		code    SystemErrCode
+		attempt int
+	}
+	var calls []predicateCall
+	retryOpts := &RetryOptions{
+		MaxAttempts: 5,
+		// Retry bad requests, which RetryOn never retries, but only twice.
+		ShouldRetry: func(err error, code SystemErrCode, attempt int) bool {
+			calls = append(calls, predicateCall{code, attempt})
+			return code == ErrCodeBadRequest && attempt < 3
+		},
+	}
+	ctx, cancel := NewContextBuilder(time.Second).SetRetryOptions(retryOpts).Build()
+	defer cancel()
+
+	badRequest := NewSystemError(ErrCodeBadRequest, "bad request")
+	f, counter := createFuncToRetry(t, badRequest, badRequest, badRequest, nil)
+	err := ch.RunWithRetry(ctx, f)
+	assert.Equal(t, badRequest, err, "Expected error from the last attempt")
+	assert.Equal(t, 3, *counter, "Unexpected number of attempts")
+	assert.Equal(t, []predicateCall{
+		{ErrCodeBadRequest, 1},
+		{ErrCodeBadRequest, 2},
+		{ErrCodeBadRequest, 3},
+	}, calls, "Unexpected predicate calls")
+}
+
+func TestRetryAppError(t *testing.T) {
+	ch := testutils.NewClient(t, nil)
+	defer ch.Close()
+
+	tests := []struct {
+		msg          string
+		classify     AppErrorClassifier
+		wantAttempts int
+	}{
+		{
+			msg:          "no classifier",
+			wantAttempts: 1,
+		},
+		{
+			msg:          "not retryable",
+			classify:     func(map[string]string, interface{}) bool { return false },
+			wantAttempts: 1,
+		},
+		{
+			msg: "retryable",
+			classify: func(headers map[string]string, appErr interface{}) bool {
+				return headers["retryable"] == "true" && appErr == "overloaded"
+			},
+			wantAttempts: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		retryOpts := &RetryOptions{MaxAttempts: 3, ClassifyAppError: tt.classify}
+		ctx, cancel := NewContextBuilder(time.Second).SetRetryOptions(retryOpts).Build()
+
+		attempts := 0
+		err := ch.RunWithRetry(ctx, func(_ context.Context, rs *RequestState) error {
+			attempts++
+			return rs.RetryAppError(map[string]string{"retryable": "true"}, "overloaded")
+		})
+		cancel()
+
+		assert.NoError(t, err, "%v: the last attempt's application error should not be an error", tt.msg)
+		assert.Equal(t, tt.wantAttempts, attempts, "%v: unexpected number of attempts", tt.msg)
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	var (
+		nowMu sync.Mutex
+		now   = time.Now()
+	)
+	timeNow := func() time.Time {
+		nowMu.Lock()
+		defer nowMu.Unlock()
+		return now
+	}
+
+	stats := newRecordingStatsReporter()
+	opts := testutils.NewOpts().SetTimeNow(timeNow).SetStatsReporter(stats)
+	opts.RetryBudget = RetryBudgetOptions{Ratio: 0.5, Window: time.Second}
+	ch := testutils.NewClient(t, opts)
+	defer ch.Close()
+
+	run := func(retryOpts *RetryOptions, err error) int {
+		ctx, cancel := NewContextBuilder(time.Second).SetRetryOptions(retryOpts).Build()
+		defer cancel()
+
+		attempts := 0
+		ch.RunWithRetry(ctx, func(context.Context, *RequestState) error {
+			attempts++
+			return err
+		})
+		return attempts
+	}
+	retryOpts := &RetryOptions{MaxAttempts: 5}
+
+	assert.Equal(t, 1, run(retryOpts, ErrServerBusy), "Retries should not exceed half of 1 request")
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, 1, run(retryOpts, nil), "Successful requests should not be retried")
+	}
+
+	// There have been 6 requests, so 3 retries are allowed.
+	assert.Equal(t, 4, run(retryOpts, ErrServerBusy), "Expected retries to use the budget")
+	assert.Equal(t, 1, run(retryOpts, ErrServerBusy), "Expected no retries once the budget is exhausted")
+	assert.Equal(t, 5, run(&RetryOptions{MaxAttempts: 5, IgnoreRetryBudget: true}, ErrServerBusy),
+		"IgnoreRetryBudget should retry even if the budget is exhausted")
+
+	// Once the window has passed, requests and retries are no longer counted.
+	nowMu.Lock()
+	now = now.Add(time.Second)
+	nowMu.Unlock()
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, 1, run(retryOpts, nil), "Successful requests should not be retried")
+	}
+	assert.Equal(t, 2, run(retryOpts, ErrServerBusy), "Expected retry budget to be reset")
+
+	stats.Lock()
+	var exhausted int64
+	for _, v := range stats.Values["outbound.calls.retry-budget-exhausted"] {
+		exhausted += v.count
+	}
+	stats.Unlock()
+	assert.EqualValues(t, 4, exhausted, "Unexpected number of requests that exhausted the retry budget")
+}
+
+func TestRetryBudgetMinRetries(t *testing.T) {
+	now, _ := testutils.NowStub(time.Now())
+	opts := testutils.NewOpts().SetTimeNow(now)
+	opts.RetryBudget = RetryBudgetOptions{Ratio: 0.1, MinRetriesPerSecond: 2, Window: time.Second}
+	ch := testutils.NewClient(t, opts)
+	defer ch.Close()
+
+	ctx, cancel := NewContextBuilder(time.Second).SetRetryOptions(&RetryOptions{MaxAttempts: 5}).Build()
+	defer cancel()
+
+	attempts := 0
+	err := ch.RunWithRetry(ctx, func(context.Context, *RequestState) error {
+		attempts++
+		return ErrServerBusy
+	})
+	assert.Equal(t, ErrServerBusy, err, "Unexpected error")
+	assert.Equal(t, 3, attempts, "Expected MinRetriesPerSecond retries with few requests")
+}
+
 func TestRetrySubContextNoTimeoutPerAttempt(t *testing.T) {
 	e := getTestErrors()
 	ctx, cancel := NewContext(time.Second)
@@ -268,3 +423,278 @@ func TestRequestStateSince(t *testing.T) {
 			tt.requestState, tt.now, tt.fallback, tt.expected, got)
 	}
 }
+
+func TestRetryHedging(t *testing.T) {
+	release := make(chan struct{})
+	slow := testutils.NewServer(t, testutils.NewOpts().
+		SetServiceName("svc").
+		DisableLogVerification()) // handler responds after it's claimed
+	defer slow.Close()
+	testutils.RegisterFunc(slow, "echo", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		<-release
+		return &raw.Res{Arg3: []byte("slow")}, nil
+	})
+
+	fast := testutils.NewServer(t, testutils.NewOpts().SetServiceName("svc"))
+	defer fast.Close()
+	testutils.RegisterFunc(fast, "echo", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		return &raw.Res{Arg3: []byte("fast")}, nil
+	})
+
+	stats := newRecordingStatsReporter()
+	client := testutils.NewClient(t, testutils.NewOpts().SetStatsReporter(stats))
+	defer client.Close()
+	sc := client.GetSubChannel("svc", Isolated)
+	sc.Peers().Add(fast.PeerInfo().HostPort)
+
+	ctx, cancel := NewContextBuilder(testutils.Timeout(5 * time.Second)).
+		SetHedgeDelay(testutils.Timeout(20 * time.Millisecond)).
+		Build()
+	defer cancel()
+
+	var (
+		mu      sync.Mutex
+		arg3    []byte
+		callers []string
+	)
+	started := time.Now()
+	err := client.RunWithRetry(ctx, func(ctx context.Context, rs *RequestState) error {
+		// The first attempt is sent to the slow peer, and the hedged attempt
+		// should use the subchannel's peer list, which avoids it.
+		var call *OutboundCall
+		var err error
+		callOpts := &CallOptions{Format: Raw, RequestState: rs}
+		if _, ok := rs.PrevSelectedPeers()[slow.PeerInfo().HostPort]; !ok {
+			call, err = client.Peers().GetOrAdd(slow.PeerInfo().HostPort).BeginCall(ctx, "svc", "echo", callOpts)
+		} else {
+			call, err = sc.BeginCall(ctx, "echo", callOpts)
+		}
+		if err != nil {
+			return err
+		}
+
+		_, res, _, err := raw.WriteArgs(call, nil, nil)
+		if err == nil {
+			mu.Lock()
+			arg3 = res
+			callers = append(callers, call.RemotePeer().HostPort)
+			mu.Unlock()
+		}
+		return err
+	})
+	require.NoError(t, err, "hedged call failed")
+	assert.True(t, time.Since(started) < testutils.Timeout(time.Second), "hedged call should not wait for the slow peer")
+	assert.Equal(t, []byte("fast"), arg3, "unexpected response")
+	assert.Equal(t, []string{fast.PeerInfo().HostPort}, callers, "only the fast peer should respond")
+
+	// The slow attempt is cancelled, so its response is dropped.
+	close(release)
+
+	stats.Lock()
+	var hedged int64
+	for _, v := range stats.Values["outbound.calls.hedged"] {
+		hedged += v.count
+	}
+	stats.Unlock()
+	assert.EqualValues(t, 1, hedged, "unexpected number of hedged calls")
+}
+
+func TestRetryHedgingClaimsSlowAttempt(t *testing.T) {
+	slowStats := newRecordingStatsReporter()
+	slow := testutils.NewServer(t, testutils.NewOpts().
+		SetServiceName("svc").
+		SetStatsReporter(slowStats).
+		DisableLogVerification()) // handler responds after it's claimed
+	defer slow.Close()
+	slowErr := make(chan error, 1)
+	testutils.RegisterFunc(slow, "echo", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		<-ctx.Done()
+		slowErr <- ctx.Err()
+		return &raw.Res{Arg3: []byte("slow")}, nil
+	})
+
+	fast := testutils.NewServer(t, testutils.NewOpts().SetServiceName("svc"))
+	defer fast.Close()
+	testutils.RegisterFunc(fast, "echo", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		return &raw.Res{Arg3: []byte("fast")}, nil
+	})
+
+	stats := newRecordingStatsReporter()
+	client := testutils.NewClient(t, testutils.NewOpts().SetStatsReporter(stats))
+	defer client.Close()
+
+	ctx, cancel := NewContextBuilder(testutils.Timeout(5 * time.Second)).
+		SetHedgeDelay(testutils.Timeout(20 * time.Millisecond)).
+		Build()
+	defer cancel()
+
+	err := client.RunWithRetry(ctx, func(ctx context.Context, rs *RequestState) error {
+		hostPort := fast.PeerInfo().HostPort
+		if _, ok := rs.PrevSelectedPeers()[slow.PeerInfo().HostPort]; !ok {
+			hostPort = slow.PeerInfo().HostPort
+		}
+		call, err := client.Peers().GetOrAdd(hostPort).BeginCall(ctx, "svc", "echo", &CallOptions{Format: Raw, RequestState: rs})
+		if err != nil {
+			return err
+		}
+
+		_, _, _, err = raw.WriteArgs(call, nil, nil)
+		return err
+	})
+	require.NoError(t, err, "hedged call failed")
+
+	select {
+	case err := <-slowErr:
+		assert.Equal(t, context.Canceled, err, "Expected slow handler to be claimed")
+	case <-time.After(testutils.Timeout(time.Second)):
+		t.Fatal("Slow handler was not claimed")
+	}
+
+	countStat := func(r *recordingStatsReporter, name string) int64 {
+		r.Lock()
+		defer r.Unlock()
+		var count int64
+		for _, v := range r.Values[name] {
+			count += v.count
+		}
+		return count
+	}
+	assert.EqualValues(t, 1, countStat(stats, "outbound.calls.claims"), "Expected slow attempt to be claimed")
+	assert.EqualValues(t, 0, countStat(stats, "outbound.calls.cancels"), "Claimed attempt should not also be cancelled")
+	assert.EqualValues(t, 1, countStat(slowStats, "inbound.calls.claimed"), "Expected slow peer to honor the claim")
+}
+
+func TestRetryHedgingResult(t *testing.T) {
+	slow := testutils.NewServer(t, testutils.NewOpts().
+		SetServiceName("svc").
+		DisableLogVerification()) // handler is claimed or cancelled
+	defer slow.Close()
+	testutils.RegisterFunc(slow, "echo", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	fast := testutils.NewServer(t, testutils.NewOpts().SetServiceName("svc"))
+	defer fast.Close()
+	testutils.RegisterFunc(fast, "echo", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		return &raw.Res{Arg3: []byte("fast")}, nil
+	})
+
+	stats := newRecordingStatsReporter()
+	client := testutils.NewClient(t, testutils.NewOpts().SetStatsReporter(stats))
+	defer client.Close()
+
+	ctx, cancel := NewContextBuilder(testutils.Timeout(5 * time.Second)).
+		SetHedgeDelay(testutils.Timeout(20 * time.Millisecond)).
+		Build()
+	defer cancel()
+
+	// Each attempt captures its response in its own variables, and only the
+	// result of the successful attempt is returned, even though the slower
+	// attempt fails after it.
+	res, err := client.RunWithRetryResult(ctx, func(ctx context.Context, rs *RequestState) (interface{}, error) {
+		hostPort := fast.PeerInfo().HostPort
+		if _, ok := rs.PrevSelectedPeers()[slow.PeerInfo().HostPort]; !ok {
+			hostPort = slow.PeerInfo().HostPort
+		}
+		call, err := client.Peers().GetOrAdd(hostPort).BeginCall(ctx, "svc", "echo", &CallOptions{Format: Raw, RequestState: rs})
+		if err != nil {
+			return nil, err
+		}
+
+		var arg3 []byte
+		_, arg3, _, err = raw.WriteArgs(call, nil, nil)
+		return arg3, err
+	})
+	require.NoError(t, err, "hedged call failed")
+	assert.Equal(t, []byte("fast"), res, "unexpected response")
+
+	stats.Lock()
+	var hedged int64
+	for _, v := range stats.Values["outbound.calls.hedged"] {
+		hedged += v.count
+	}
+	stats.Unlock()
+	assert.EqualValues(t, 1, hedged, "unexpected number of hedged calls")
+}
+
+func TestRetryHedgingNoClaimsForOlderPeers(t *testing.T) {
+	slow := testutils.NewServer(t, testutils.NewOpts().
+		SetServiceName("svc").
+		DisableLogVerification()) // handler responds after the call completes
+	defer slow.Close()
+	// The slow peer acts as an older peer that does not handle claim or
+	// cancel frames, so they should not be sent to it.
+	slow.DisableCallControl()
+	release := make(chan struct{})
+	slowErr := make(chan error, 1)
+	testutils.RegisterFunc(slow, "echo", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		select {
+		case <-ctx.Done():
+			slowErr <- ctx.Err()
+		case <-release:
+			slowErr <- nil
+		}
+		return &raw.Res{Arg3: []byte("slow")}, nil
+	})
+
+	fast := testutils.NewServer(t, testutils.NewOpts().SetServiceName("svc"))
+	defer fast.Close()
+	testutils.RegisterFunc(fast, "echo", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		return &raw.Res{Arg3: []byte("fast")}, nil
+	})
+
+	stats := newRecordingStatsReporter()
+	client := testutils.NewClient(t, testutils.NewOpts().
+		SetStatsReporter(stats).
+		DisableLogVerification()) // slow response arrives after the call completes
+	defer client.Close()
+
+	ctx, cancel := NewContextBuilder(testutils.Timeout(5 * time.Second)).
+		SetHedgeDelay(testutils.Timeout(20 * time.Millisecond)).
+		Build()
+	defer cancel()
+
+	err := client.RunWithRetry(ctx, func(ctx context.Context, rs *RequestState) error {
+		hostPort := fast.PeerInfo().HostPort
+		if _, ok := rs.PrevSelectedPeers()[slow.PeerInfo().HostPort]; !ok {
+			hostPort = slow.PeerInfo().HostPort
+		}
+		call, err := client.Peers().GetOrAdd(hostPort).BeginCall(ctx, "svc", "echo", &CallOptions{Format: Raw, RequestState: rs})
+		if err != nil {
+			return err
+		}
+
+		_, _, _, err = raw.WriteArgs(call, nil, nil)
+		return err
+	})
+	require.NoError(t, err, "hedged call failed")
+
+	select {
+	case err := <-slowErr:
+		t.Fatalf("Slow handler should not be claimed or cancelled, got %v", err)
+	case <-time.After(testutils.Timeout(50 * time.Millisecond)):
+	}
+	close(release)
+	assert.NoError(t, <-slowErr, "Slow handler should complete normally")
+
+	stats.Lock()
+	defer stats.Unlock()
+	assert.Empty(t, stats.Values["outbound.calls.claims"], "Claims should not be sent to older peers")
+	assert.Empty(t, stats.Values["outbound.calls.cancels"], "Cancels should not be sent to older peers")
+}
+
+func TestRetryHedgingFastAttempt(t *testing.T) {
+	ch := testutils.NewClient(t, nil)
+	defer ch.Close()
+
+	ctx, cancel := NewContextBuilder(time.Second).SetHedgeDelay(time.Second).Build()
+	defer cancel()
+
+	var attempts int
+	require.NoError(t, ch.RunWithRetry(ctx, func(context.Context, *RequestState) error {
+		attempts++
+		return nil
+	}), "RunWithRetry failed")
+	assert.Equal(t, 1, attempts, "attempts that complete before the hedge delay should not be hedged")
+}
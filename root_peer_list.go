@@ -27,16 +27,18 @@ import "sync"
 type RootPeerList struct {
 	sync.RWMutex
 
-	channel             Connectable
-	onPeerStatusChanged func(*Peer)
-	peersByHostPort     map[string]*Peer
+	channel          Connectable
+	peerStatusEvents peerStatusEvents
+	initPeer         func(*Peer)
+	peersByHostPort  map[string]*Peer
 }
 
-func newRootPeerList(ch Connectable, onPeerStatusChanged func(*Peer)) *RootPeerList {
+func newRootPeerList(ch Connectable, events peerStatusEvents, initPeer func(*Peer)) *RootPeerList {
 	return &RootPeerList{
-		channel:             ch,
-		onPeerStatusChanged: onPeerStatusChanged,
-		peersByHostPort:     make(map[string]*Peer),
+		channel:          ch,
+		peerStatusEvents: events,
+		initPeer:         initPeer,
+		peersByHostPort:  make(map[string]*Peer),
 	}
 }
 
@@ -67,7 +69,8 @@ func (l *RootPeerList) Add(hostPort string) *Peer {
 	var p *Peer
 	// To avoid duplicate connections, only the root list should create new
 	// peers. All other lists should keep refs to the root list's peers.
-	p = newPeer(l.channel, hostPort, l.onPeerStatusChanged, l.onClosedConnRemoved)
+	p = newPeer(l.channel, hostPort, l.peerStatusEvents, l.onClosedConnRemoved)
+	l.initPeer(p)
 	l.peersByHostPort[hostPort] = p
 	return p
 }
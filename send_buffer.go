@@ -0,0 +1,72 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+// SendBufferFullPolicy controls how calls behave when a connection's send
+// buffer is full.
+type SendBufferFullPolicy int
+
+const (
+	// SendBufferBlock makes calls wait for space in the send buffer until
+	// the call's context is done.
+	SendBufferBlock SendBufferFullPolicy = iota
+
+	// SendBufferFailFast fails calls with ErrSendBufferBusy as soon as a
+	// frame does not fit in the send buffer.
+	SendBufferFailFast
+)
+
+// ErrSendBufferBusy is returned by calls using SendBufferFailFast when the
+// connection's send buffer is full. It is a Busy error, so the call can be
+// retried on another peer.
+var ErrSendBufferBusy = NewSystemError(ErrCodeBusy, "connection send buffer is full")
+
+func (p SendBufferFullPolicy) String() string {
+	switch p {
+	case SendBufferBlock:
+		return "block"
+	case SendBufferFailFast:
+		return "failFast"
+	default:
+		return "unknown"
+	}
+}
+
+// sendBufferQueued is called after a frame is added to the send buffer, and
+// calls the high watermark callback if the buffer has reached the watermark.
+func (c *Connection) sendBufferQueued() {
+	hw := c.opts.SendBufferHighWatermark
+	if hw <= 0 || c.opts.OnSendBufferHighWatermark == nil {
+		return
+	}
+
+	if queued := len(c.sendCh); queued >= hw && !c.aboveWatermark.Swap(true) {
+		c.opts.OnSendBufferHighWatermark(c, queued)
+	}
+}
+
+// sendBufferDrained is called after a frame is removed from the send buffer,
+// and re-arms the high watermark callback once the buffer is below it.
+func (c *Connection) sendBufferDrained() {
+	if c.aboveWatermark.Load() && len(c.sendCh) < c.opts.SendBufferHighWatermark {
+		c.aboveWatermark.Store(false)
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/testutils"
+	"github.com/uber/tchannel-go/testutils/testreader"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/atomic"
+)
+
+// stallingProxyBufferSize is the socket buffer size used by the stalling
+// proxy and its clients, so a stalled connection backs up quickly.
+const stallingProxyBufferSize = 4096
+
+// stallingProxy forwards connections to a destination until it is stalled,
+// after which it stops reading from the client so that writes back up.
+type stallingProxy struct {
+	ln      net.Listener
+	dest    string
+	stalled atomic.Bool
+
+	sync.Mutex
+	conns []net.Conn
+}
+
+func newStallingProxy(t *testing.T, dest string) *stallingProxy {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Listen failed")
+
+	p := &stallingProxy{ln: ln, dest: dest}
+	go p.accept()
+	return p
+}
+
+func (p *stallingProxy) accept() {
+	for {
+		src, err := p.ln.Accept()
+		if err != nil {
+			return
+		}
+		// Limit how much the proxy's side of the connection buffers once
+		// it stops reading.
+		src.(*net.TCPConn).SetReadBuffer(stallingProxyBufferSize)
+		dst, err := net.Dial("tcp", p.dest)
+		if err != nil {
+			src.Close()
+			return
+		}
+		p.Lock()
+		p.conns = append(p.conns, src, dst)
+		p.Unlock()
+
+		go io.Copy(src, dst)
+		go func() {
+			buf := make([]byte, 1024)
+			for !p.stalled.Load() {
+				n, err := src.Read(buf)
+				if err != nil {
+					return
+				}
+				dst.Write(buf[:n])
+			}
+		}()
+	}
+}
+
+func (p *stallingProxy) Close() {
+	p.ln.Close()
+	p.Lock()
+	defer p.Unlock()
+	for _, c := range p.conns {
+		c.Close()
+	}
+}
+
+// fillSendBuffer streams arg2 to a call through a stalled proxy until the
+// write fails, and returns the error.
+func fillSendBuffer(t *testing.T, client *Channel, proxy *stallingProxy, serviceName string) error {
+	ctx, cancel := NewContext(testutils.Timeout(500 * time.Millisecond))
+	defer cancel()
+
+	_, err := client.Connect(ctx, proxy.ln.Addr().String())
+	require.NoError(t, err, "Connect failed")
+	proxy.stalled.Store(true)
+
+	call, err := client.BeginCall(ctx, proxy.ln.Addr().String(), serviceName, "stream", nil)
+	require.NoError(t, err, "BeginCall failed")
+	writer, err := call.Arg2Writer()
+	require.NoError(t, err, "Arg2Writer failed")
+	_, err = io.Copy(writer, testreader.Looper([]byte("payload")))
+	return err
+}
+
+func TestSendBufferFullPolicy(t *testing.T) {
+	tests := []struct {
+		msg     string
+		policy  SendBufferFullPolicy
+		wantErr error
+	}{
+		{
+			msg:     "block waits until the context is done",
+			policy:  SendBufferBlock,
+			wantErr: ErrTimeout,
+		},
+		{
+			msg:     "fail fast returns a busy error",
+			policy:  SendBufferFailFast,
+			wantErr: ErrSendBufferBusy,
+		},
+	}
+
+	for _, tt := range tests {
+		opts := testutils.NewOpts().NoRelay().DisableLogVerification()
+		testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+			ts.Register(HandlerFunc(func(ctx context.Context, call *InboundCall) {}), "stream")
+
+			proxy := newStallingProxy(t, ts.HostPort())
+			defer proxy.Close()
+
+			var watermark atomic.Int32
+			clientOpts := testutils.NewOpts().SetSendBufferSize(10)
+			clientOpts.DefaultConnectionOptions.SocketSendBufferSize = stallingProxyBufferSize
+			clientOpts.DefaultConnectionOptions.SendBufferFullPolicy = tt.policy
+			clientOpts.DefaultConnectionOptions.SendBufferHighWatermark = 8
+			clientOpts.DefaultConnectionOptions.OnSendBufferHighWatermark = func(c *Connection, queued int) {
+				assert.True(t, queued >= 8, "%v: callback called below the watermark", tt.msg)
+				watermark.Inc()
+			}
+			client := ts.NewClient(clientOpts)
+
+			err := fillSendBuffer(t, client, proxy, ts.ServiceName())
+			assert.Equal(t, tt.wantErr, err, "%v: unexpected error", tt.msg)
+			assert.True(t, watermark.Load() > 0, "%v: expected the high watermark callback", tt.msg)
+
+			// The connection is kept open.
+			conns := client.IntrospectState(nil).RootPeers[proxy.ln.Addr().String()].OutboundConnections
+			if assert.Len(t, conns, 1, "%v: expected the connection to remain", tt.msg) {
+				assert.Equal(t, tt.policy, conns[0].EffectiveOptions.SendBufferFullPolicy, "%v: unexpected effective policy", tt.msg)
+			}
+
+			proxy.Close()
+		})
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+)
+
+// connProtocol is the protocol an inbound connection was detected to use.
+type connProtocol int
+
+const (
+	protocolTChannel connProtocol = iota
+	protocolHTTP
+	protocolHTTP2
+)
+
+// httpMethods are the methods that an HTTP/1.x request line can start with.
+var httpMethods = []string{
+	"GET", "HEAD", "POST", "PUT", "DELETE", "CONNECT", "OPTIONS", "TRACE", "PATCH",
+}
+
+// httpSniffLen is the number of bytes read to detect an HTTP/1.x request, the
+// length of the longest method and the following space. A request line is
+// always longer than this.
+const httpSniffLen = len("OPTIONS ")
+
+// sniffedConn is a connection that replays bytes that have been peeked from
+// the connection before returning further reads.
+type sniffedConn struct {
+	net.Conn
+
+	r *bufio.Reader
+}
+
+func (c sniffedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// sniffProtocol detects whether the connection starts with an HTTP/1.x
+// request line, the HTTP/2 client preface, or neither, in which case it's
+// treated as a TChannel connection. It returns a connection that replays any
+// bytes read while detecting the protocol.
+//
+// A TChannel connection starts with the 2 byte size of the init request
+// frame, and init requests are never large enough for the first byte to be
+// an upper case letter, as HTTP methods and the preface start with. So only
+// HTTP clients send more than a single byte before the check completes.
+func sniffProtocol(c net.Conn) (net.Conn, connProtocol) {
+	r := bufio.NewReaderSize(c, len(http2Preface))
+	sniffed := sniffedConn{c, r}
+
+	first, err := r.Peek(1)
+	if err != nil || first[0] < 'A' || first[0] > 'Z' {
+		return sniffed, protocolTChannel
+	}
+
+	start, err := r.Peek(httpSniffLen)
+	if err != nil {
+		return sniffed, protocolTChannel
+	}
+	if bytes.HasPrefix([]byte(http2Preface), start) {
+		preface, err := r.Peek(len(http2Preface))
+		if err == nil && bytes.Equal(preface, []byte(http2Preface)) {
+			return sniffed, protocolHTTP2
+		}
+		return sniffed, protocolTChannel
+	}
+	for _, method := range httpMethods {
+		if bytes.HasPrefix(start, []byte(method+" ")) {
+			return sniffed, protocolHTTP
+		}
+	}
+	return sniffed, protocolTChannel
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniffProtocol(t *testing.T) {
+	tests := []struct {
+		msg   string
+		bytes string
+		want  connProtocol
+	}{
+		{"tchannel init", "\x00\x50\x01\x00\x00\x00\x00\x01", protocolTChannel},
+		{"http get", "GET / HTTP/1.1\r\n\r\n", protocolHTTP},
+		{"http options", "OPTIONS * HTTP/1.1\r\n\r\n", protocolHTTP},
+		{"http post", "POST /metrics HTTP/1.1\r\n\r\n", protocolHTTP},
+		{"http2 preface", http2Preface, protocolHTTP2},
+		{"unknown method", "GETS / HTTP/1.1\r\n\r\n", protocolTChannel},
+		{"truncated preface", "PRI * HTTP/2.0\r\n\r\nXX\r\n\r\n", protocolTChannel},
+	}
+
+	for _, tt := range tests {
+		client, server := net.Pipe()
+		go func() {
+			client.Write([]byte(tt.bytes))
+			client.Close()
+		}()
+
+		conn, got := sniffProtocol(server)
+		assert.Equal(t, tt.want, got, "%v: unexpected protocol", tt.msg)
+
+		replayed, err := ioutil.ReadAll(conn)
+		require.NoError(t, err, "%v: read failed", tt.msg)
+		assert.Equal(t, tt.bytes, string(replayed), "%v: sniffed bytes should be replayed", tt.msg)
+		server.Close()
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package stats
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/uber/tchannel-go"
+)
+
+// PrometheusOptions are used to configure a Prometheus StatsReporter.
+type PrometheusOptions struct {
+	// Registerer is where the reporter registers its collectors.
+	// If nil, prometheus.DefaultRegisterer is used.
+	Registerer prometheus.Registerer
+
+	// Namespace is prepended to all metric names. Defaults to "tchannel".
+	Namespace string
+
+	// LatencyBuckets are the histogram buckets (in seconds) used for timers
+	// that do not have an entry in Buckets. Defaults to prometheus.DefBuckets.
+	LatencyBuckets []float64
+
+	// Buckets overrides the histogram buckets for specific stats, keyed by the
+	// tchannel stat name (e.g. "outbound.calls.latency"). Gauges listed here are
+	// recorded as histograms rather than gauges, which allows values such as
+	// payload sizes or frame pool usage to keep their distribution.
+	Buckets map[string][]float64
+}
+
+type promMetric struct {
+	labels    []string
+	counter   *prometheus.CounterVec
+	gauge     *prometheus.GaugeVec
+	histogram *prometheus.HistogramVec
+}
+
+type prometheusReporter struct {
+	opts PrometheusOptions
+
+	sync.RWMutex
+	metrics map[string]*promMetric
+}
+
+// NewPrometheusReporter returns a StatsReporter that exports stats as
+// Prometheus counters, gauges and histograms. Collectors are created on first
+// use, and the label names for a stat are fixed by the tags of the first value
+// reported: later tags that are not part of that set are dropped, and missing
+// tags are reported with an empty value.
+func NewPrometheusReporter(opts PrometheusOptions) tchannel.StatsReporter {
+	if opts.Registerer == nil {
+		opts.Registerer = prometheus.DefaultRegisterer
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = "tchannel"
+	}
+	if opts.LatencyBuckets == nil {
+		opts.LatencyBuckets = prometheus.DefBuckets
+	}
+	return &prometheusReporter{
+		opts:    opts,
+		metrics: make(map[string]*promMetric),
+	}
+}
+
+func (r *prometheusReporter) IncCounter(name string, tags map[string]string, value int64) {
+	if m := r.getMetric(name, tags, r.newCounter); m != nil {
+		m.counter.WithLabelValues(m.values(tags)...).Add(float64(value))
+	}
+}
+
+func (r *prometheusReporter) UpdateGauge(name string, tags map[string]string, value int64) {
+	if buckets, ok := r.opts.Buckets[name]; ok {
+		if m := r.getMetric(name, tags, r.newHistogram("", buckets)); m != nil {
+			m.histogram.WithLabelValues(m.values(tags)...).Observe(float64(value))
+		}
+		return
+	}
+
+	if m := r.getMetric(name, tags, r.newGauge); m != nil {
+		m.gauge.WithLabelValues(m.values(tags)...).Set(float64(value))
+	}
+}
+
+func (r *prometheusReporter) RecordTimer(name string, tags map[string]string, d time.Duration) {
+	buckets, ok := r.opts.Buckets[name]
+	if !ok {
+		buckets = r.opts.LatencyBuckets
+	}
+	if m := r.getMetric(name, tags, r.newHistogram("_seconds", buckets)); m != nil {
+		m.histogram.WithLabelValues(m.values(tags)...).Observe(d.Seconds())
+	}
+}
+
+// getMetric returns the metric for the given stat name, creating and
+// registering it if required. It returns nil if the collector could not be
+// registered, in which case the value is dropped.
+func (r *prometheusReporter) getMetric(name string, tags map[string]string, newF func(name string, labels []string) *promMetric) *promMetric {
+	r.RLock()
+	m, ok := r.metrics[name]
+	r.RUnlock()
+	if ok {
+		return m
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	if m, ok := r.metrics[name]; ok {
+		return m
+	}
+
+	labels := make([]string, 0, len(tags))
+	for k := range tags {
+		labels = append(labels, k)
+	}
+	sort.Strings(labels)
+
+	promLabels := make([]string, len(labels))
+	for i, l := range labels {
+		promLabels[i] = promName(l)
+	}
+
+	m = newF(promName(name), promLabels)
+	m.labels = labels
+	if err := r.opts.Registerer.Register(m.collector()); err != nil {
+		are, ok := err.(prometheus.AlreadyRegisteredError)
+		if !ok || !m.setCollector(are.ExistingCollector) {
+			m = nil
+		}
+	}
+	r.metrics[name] = m
+	return m
+}
+
+func (r *prometheusReporter) newCounter(name string, labels []string) *promMetric {
+	return &promMetric{counter: prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: r.opts.Namespace,
+		Name:      name,
+		Help:      "TChannel counter " + name,
+	}, labels)}
+}
+
+func (r *prometheusReporter) newGauge(name string, labels []string) *promMetric {
+	return &promMetric{gauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: r.opts.Namespace,
+		Name:      name,
+		Help:      "TChannel gauge " + name,
+	}, labels)}
+}
+
+func (r *prometheusReporter) newHistogram(suffix string, buckets []float64) func(string, []string) *promMetric {
+	return func(name string, labels []string) *promMetric {
+		return &promMetric{histogram: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: r.opts.Namespace,
+			Name:      name + suffix,
+			Help:      "TChannel histogram " + name,
+			Buckets:   buckets,
+		}, labels)}
+	}
+}
+
+func (m *promMetric) collector() prometheus.Collector {
+	switch {
+	case m.counter != nil:
+		return m.counter
+	case m.gauge != nil:
+		return m.gauge
+	default:
+		return m.histogram
+	}
+}
+
+// setCollector replaces the metric's collector with an existing collector
+// of the same type, and returns whether it was able to do so.
+func (m *promMetric) setCollector(c prometheus.Collector) bool {
+	var ok bool
+	switch {
+	case m.counter != nil:
+		m.counter, ok = c.(*prometheus.CounterVec)
+	case m.gauge != nil:
+		m.gauge, ok = c.(*prometheus.GaugeVec)
+	default:
+		m.histogram, ok = c.(*prometheus.HistogramVec)
+	}
+	return ok
+}
+
+// values returns the label values for the given tags, in label order.
+func (m *promMetric) values(tags map[string]string) []string {
+	values := make([]string, len(m.labels))
+	for i, l := range m.labels {
+		values[i] = tags[l]
+	}
+	return values
+}
+
+// promName converts a tchannel stat or tag name into a valid Prometheus name.
+func promName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gatherFamilies(t *testing.T, registry *prometheus.Registry) map[string]*dto.MetricFamily {
+	families, err := registry.Gather()
+	require.NoError(t, err, "Gather failed")
+
+	byName := make(map[string]*dto.MetricFamily)
+	for _, f := range families {
+		byName[f.GetName()] = f
+	}
+	return byName
+}
+
+func labelsOf(m *dto.Metric) map[string]string {
+	labels := make(map[string]string)
+	for _, l := range m.GetLabel() {
+		labels[l.GetName()] = l.GetValue()
+	}
+	return labels
+}
+
+func TestPrometheusReporter(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	r := NewPrometheusReporter(PrometheusOptions{
+		Registerer:     registry,
+		LatencyBuckets: []float64{0.01, 0.1, 1},
+		Buckets: map[string][]float64{
+			"outbound.calls.payload-size": {100, 1000},
+		},
+	})
+
+	tags := map[string]string{"service": "svc", "target-endpoint": "echo"}
+	r.IncCounter("outbound.calls.send", tags, 1)
+	r.IncCounter("outbound.calls.send", tags, 2)
+	r.UpdateGauge("connections.active", nil, 5)
+	r.RecordTimer("outbound.calls.latency", tags, 50*time.Millisecond)
+	r.RecordTimer("outbound.calls.latency", tags, 2*time.Second)
+	r.UpdateGauge("outbound.calls.payload-size", tags, 500)
+
+	// Unknown tags are dropped, and missing tags are reported as empty.
+	r.IncCounter("outbound.calls.send", map[string]string{"service": "other", "extra": "x"}, 1)
+
+	families := gatherFamilies(t, registry)
+
+	counter := families["tchannel_outbound_calls_send"]
+	require.NotNil(t, counter, "missing counter")
+	assert.Equal(t, dto.MetricType_COUNTER, counter.GetType())
+	require.Len(t, counter.GetMetric(), 2)
+	for _, m := range counter.GetMetric() {
+		switch labelsOf(m)["service"] {
+		case "svc":
+			assert.Equal(t, map[string]string{"service": "svc", "target_endpoint": "echo"}, labelsOf(m))
+			assert.Equal(t, 3.0, m.GetCounter().GetValue())
+		case "other":
+			assert.Equal(t, map[string]string{"service": "other", "target_endpoint": ""}, labelsOf(m))
+			assert.Equal(t, 1.0, m.GetCounter().GetValue())
+		default:
+			t.Errorf("unexpected labels: %v", labelsOf(m))
+		}
+	}
+
+	gauge := families["tchannel_connections_active"]
+	require.NotNil(t, gauge, "missing gauge")
+	assert.Equal(t, 5.0, gauge.GetMetric()[0].GetGauge().GetValue())
+
+	latency := families["tchannel_outbound_calls_latency_seconds"]
+	require.NotNil(t, latency, "missing latency histogram")
+	hist := latency.GetMetric()[0].GetHistogram()
+	assert.Equal(t, uint64(2), hist.GetSampleCount())
+	assert.InDelta(t, 2.05, hist.GetSampleSum(), 0.0001)
+	var bounds []float64
+	var counts []uint64
+	for _, b := range hist.GetBucket() {
+		bounds = append(bounds, b.GetUpperBound())
+		counts = append(counts, b.GetCumulativeCount())
+	}
+	assert.Equal(t, []float64{0.01, 0.1, 1}, bounds, "unexpected latency buckets")
+	assert.Equal(t, []uint64{0, 1, 1}, counts, "unexpected latency bucket counts")
+
+	size := families["tchannel_outbound_calls_payload_size"]
+	require.NotNil(t, size, "missing payload size histogram")
+	assert.Equal(t, dto.MetricType_HISTOGRAM, size.GetType())
+	sizeHist := size.GetMetric()[0].GetHistogram()
+	require.Len(t, sizeHist.GetBucket(), 2)
+	assert.Equal(t, uint64(0), sizeHist.GetBucket()[0].GetCumulativeCount())
+	assert.Equal(t, uint64(1), sizeHist.GetBucket()[1].GetCumulativeCount())
+}
+
+func TestPrometheusReporterSharedRegistry(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	opts := PrometheusOptions{Registerer: registry}
+	r1 := NewPrometheusReporter(opts)
+	r2 := NewPrometheusReporter(opts)
+
+	r1.IncCounter("inbound.calls.recvd", nil, 1)
+	r2.IncCounter("inbound.calls.recvd", nil, 2)
+
+	families := gatherFamilies(t, registry)
+	counter := families["tchannel_inbound_calls_recvd"]
+	require.NotNil(t, counter, "missing counter")
+	assert.Equal(t, 3.0, counter.GetMetric()[0].GetCounter().GetValue(),
+		"reporters sharing a registry should share collectors")
+
+	// A stat that conflicts with an existing collector is dropped.
+	assert.NotPanics(t, func() {
+		r2.IncCounter("inbound.calls.recvd", map[string]string{"service": "svc"}, 1)
+		NewPrometheusReporter(opts).UpdateGauge("inbound.calls.recvd", nil, 1)
+	})
+}
@@ -21,6 +21,7 @@
 package tchannel_test
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"testing"
@@ -33,7 +34,6 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"golang.org/x/net/context"
 )
 
 func tagsForOutboundCall(serverCh *Channel, clientCh *Channel, method string) map[string]string {
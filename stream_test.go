@@ -21,8 +21,10 @@
 package tchannel_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"io/ioutil"
 	"strings"
@@ -31,11 +33,11 @@ import (
 
 	. "github.com/uber/tchannel-go"
 
+	"github.com/uber/tchannel-go/raw"
 	"github.com/uber/tchannel-go/testutils"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"golang.org/x/net/context"
 )
 
 const (
@@ -338,3 +340,114 @@ func TestResponseClosedBeforeRequest(t *testing.T) {
 		<-writerDone
 	})
 }
+
+// patternReader is an infinite reader that returns the bytes 0 to 250 repeatedly.
+type patternReader struct{ n int }
+
+func (r *patternReader) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = byte(r.n % 251)
+		r.n++
+	}
+	return len(b), nil
+}
+
+type rawStreamHandler struct {
+	t        *testing.T
+	respSize int64
+}
+
+func (h rawStreamHandler) HandleStream(ctx context.Context, args *raw.Args, arg3 io.Reader, res *raw.StreamResponse) error {
+	hash := crc32.NewIEEE()
+	n, err := io.Copy(hash, arg3)
+	if err != nil {
+		return err
+	}
+	if string(args.Arg2) == "app-error" {
+		if err := res.SetApplicationError(); err != nil {
+			return err
+		}
+	}
+
+	w, err := res.Arg3Writer([]byte(fmt.Sprintf("%v:%v", n, hash.Sum32())))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, io.LimitReader(&patternReader{}, h.respSize)); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func (h rawStreamHandler) OnError(ctx context.Context, err error) {
+	h.t.Errorf("rawStreamHandler OnError: %v", err)
+}
+
+func TestRawStreamLargePayload(t *testing.T) {
+	const size = 4 * 1024 * 1024
+
+	testutils.WithTestServer(t, nil, func(ts *testutils.TestServer) {
+		ts.Register(raw.WrapStream(rawStreamHandler{t, size}), "stream")
+
+		ctx, cancel := NewContext(testutils.Timeout(5 * time.Second))
+		defer cancel()
+
+		for _, arg2 := range []string{"", "app-error"} {
+			call, err := ts.NewClient(nil).BeginCall(ctx, ts.HostPort(), ts.ServiceName(), "stream", nil)
+			require.NoError(t, err, "BeginCall failed")
+
+			wantHash := crc32.NewIEEE()
+			arg3 := io.TeeReader(io.LimitReader(&patternReader{}, size), wantHash)
+			respArg2, respArg3, resp, err := raw.WriteArgsStream(call, []byte(arg2), arg3)
+			require.NoError(t, err, "WriteArgsStream failed")
+			assert.Equal(t, arg2 == "app-error", resp.ApplicationError(), "Unexpected application error")
+			assert.Equal(t, fmt.Sprintf("%v:%v", size, wantHash.Sum32()), string(respArg2), "Server received unexpected arg3")
+
+			gotHash := crc32.NewIEEE()
+			n, err := io.Copy(gotHash, respArg3)
+			require.NoError(t, err, "Failed to read response arg3")
+			assert.EqualValues(t, size, n, "Unexpected response size")
+			assert.Equal(t, wantHash.Sum32(), gotHash.Sum32(), "Unexpected response arg3")
+			assert.NoError(t, respArg3.Close(), "Failed to close response arg3")
+		}
+	})
+}
+
+type rawStreamErrorHandler struct{}
+
+func (rawStreamErrorHandler) HandleStream(ctx context.Context, args *raw.Args, arg3 io.Reader, res *raw.StreamResponse) error {
+	if _, err := io.Copy(ioutil.Discard, arg3); err != nil {
+		return err
+	}
+	if string(args.Arg2) == "empty" {
+		return nil
+	}
+	return ErrServerBusy
+}
+
+func (rawStreamErrorHandler) OnError(ctx context.Context, err error) {}
+
+func TestRawStreamResponses(t *testing.T) {
+	testutils.WithTestServer(t, nil, func(ts *testutils.TestServer) {
+		ts.Register(raw.WrapStream(rawStreamErrorHandler{}), "stream")
+
+		ctx, cancel := NewContext(testutils.Timeout(time.Second))
+		defer cancel()
+
+		client := ts.NewClient(nil)
+		call, err := client.BeginCall(ctx, ts.HostPort(), ts.ServiceName(), "stream", nil)
+		require.NoError(t, err, "BeginCall failed")
+		_, _, _, err = raw.WriteArgsStream(call, nil, strings.NewReader("hello"))
+		assert.Equal(t, ErrServerBusy, err, "Expected handler error to be sent as a system error")
+
+		call, err = client.BeginCall(ctx, ts.HostPort(), ts.ServiceName(), "stream", nil)
+		require.NoError(t, err, "BeginCall failed")
+		respArg2, respArg3, _, err := raw.WriteArgsStream(call, []byte("empty"), strings.NewReader("hello"))
+		require.NoError(t, err, "WriteArgsStream failed")
+		assert.Empty(t, respArg2, "Expected empty response arg2")
+		got, err := ioutil.ReadAll(respArg3)
+		require.NoError(t, err, "Failed to read response arg3")
+		assert.Empty(t, got, "Expected empty response arg3")
+		assert.NoError(t, respArg3.Close(), "Failed to close response arg3")
+	})
+}
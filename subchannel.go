@@ -21,11 +21,12 @@
 package tchannel
 
 import (
+	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/opentracing/opentracing-go"
-	"golang.org/x/net/context"
 )
 
 // SubChannelOption are used to set options for subchannels.
@@ -34,9 +35,22 @@ type SubChannelOption func(*SubChannel)
 // Isolated is a SubChannelOption that creates an isolated subchannel.
 func Isolated(s *SubChannel) {
 	s.Lock()
+	defer s.Unlock()
+	if s.peers != s.topChannel.peers {
+		// Already isolated, keep the existing peer list and strategy.
+		return
+	}
 	s.peers = s.topChannel.peers.newSibling()
 	s.peers.SetStrategy(newLeastPendingCalculator())
-	s.Unlock()
+}
+
+// WithPeerSelectionStrategy is a SubChannelOption that creates an isolated
+// subchannel (see Isolated) which selects peers using the given strategy.
+func WithPeerSelectionStrategy(sc ScoreCalculator) SubChannelOption {
+	return func(s *SubChannel) {
+		Isolated(s)
+		s.peers.SetStrategy(sc)
+	}
 }
 
 // SubChannel allows calling a specific service on a channel.
@@ -51,6 +65,12 @@ type SubChannel struct {
 	handler            Handler
 	logger             Logger
 	statsReporter      StatsReporter
+	inboundLimiter     *concurrencyLimiter
+	methodLimiters     map[string]*concurrencyLimiter
+	methodTimeouts     map[string]time.Duration
+	methodArgLimits    map[string]ArgSizeLimits
+	rateLimiter        *rateLimiter
+	timeoutPolicy      *TimeoutPolicy
 }
 
 // Map of subchannel and the corresponding service
@@ -83,6 +103,30 @@ func (c *SubChannel) BeginCall(ctx context.Context, methodName string, callOptio
 		callOptions = defaultCallOptions
 	}
 
+	c.RLock()
+	rateLimiter := c.rateLimiter
+	timeoutPolicy := c.timeoutPolicy
+	c.RUnlock()
+	if timeoutPolicy == nil {
+		return c.beginCall(ctx, methodName, callOptions, rateLimiter)
+	}
+
+	ctx, cancel := timeoutPolicy.withDefaultTimeout(ctx, methodName)
+	call, err := c.beginCall(ctx, methodName, callOptions, rateLimiter)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	timeoutPolicy.observe(call, methodName, cancel)
+	return call, nil
+}
+
+// beginCall starts a call using the context after any default timeout is applied.
+func (c *SubChannel) beginCall(ctx context.Context, methodName string, callOptions *CallOptions, rateLimiter *rateLimiter) (*OutboundCall, error) {
+	if err := rateLimiter.wait(ctx, c.serviceName); err != nil {
+		return nil, err
+	}
+
 	peer, err := c.peers.Get(callOptions.RequestState.PrevSelectedPeers())
 	if err != nil {
 		return nil, err
@@ -104,32 +148,53 @@ func (c *SubChannel) Isolated() bool {
 }
 
 // Register registers a handler on the subchannel for the given method.
+// Handlers can be registered while the channel is serving calls, and replace
+// any handler previously registered for the method.
 //
 // This function panics if the Handler for the SubChannel was overwritten with
 // SetHandler.
 func (c *SubChannel) Register(h Handler, methodName string) {
-	handlers, ok := c.handler.(*handlerMap)
-	if !ok {
-		panic(fmt.Sprintf(
-			"handler for SubChannel(%v) was changed to disallow method registration",
-			c.ServiceName(),
-		))
-	}
-	handlers.register(h, methodName)
+	c.handlerMap().register(h, methodName)
+}
+
+// RegisterPrefix registers a handler on the subchannel for all methods that
+// start with the given prefix, which can be used by routing-style services.
+// Calls are dispatched to the handler registered for the method if any, and
+// otherwise to the handler registered for the longest matching prefix.
+//
+// This function panics if the Handler for the SubChannel was overwritten with
+// SetHandler.
+func (c *SubChannel) RegisterPrefix(h Handler, prefix string) {
+	c.handlerMap().registerPrefix(h, prefix)
+}
+
+// Unregister removes the handler registered on the subchannel for the given
+// method, and returns whether there was a handler registered. Calls already
+// dispatched to the handler are not affected.
+//
+// This function panics if the Handler for the SubChannel was overwritten with
+// SetHandler.
+func (c *SubChannel) Unregister(methodName string) bool {
+	return c.handlerMap().unregister(methodName)
+}
+
+// UnregisterPrefix removes the handler registered on the subchannel for the
+// given prefix using RegisterPrefix, and returns whether there was a handler
+// registered.
+//
+// This function panics if the Handler for the SubChannel was overwritten with
+// SetHandler.
+func (c *SubChannel) UnregisterPrefix(prefix string) bool {
+	return c.handlerMap().unregisterPrefix(prefix)
 }
 
 // GetHandlers returns all handlers registered on this subchannel by method name.
+// Handlers registered using RegisterPrefix are not included.
 //
 // This function panics if the Handler for the SubChannel was overwritten with
 // SetHandler.
 func (c *SubChannel) GetHandlers() map[string]Handler {
-	handlers, ok := c.handler.(*handlerMap)
-	if !ok {
-		panic(fmt.Sprintf(
-			"handler for SubChannel(%v) was changed to disallow method registration",
-			c.ServiceName(),
-		))
-	}
+	handlers := c.handlerMap()
 
 	handlers.RLock()
 	handlersMap := make(map[string]Handler, len(handlers.handlers))
@@ -140,6 +205,19 @@ func (c *SubChannel) GetHandlers() map[string]Handler {
 	return handlersMap
 }
 
+// handlerMap returns the subchannel's handlerMap, and panics if the Handler
+// was overwritten with SetHandler.
+func (c *SubChannel) handlerMap() *handlerMap {
+	handlers, ok := c.getHandler().(*handlerMap)
+	if !ok {
+		panic(fmt.Sprintf(
+			"handler for SubChannel(%v) was changed to disallow method registration",
+			c.ServiceName(),
+		))
+	}
+	return handlers
+}
+
 // SetHandler changes the SubChannel's underlying handler. This may be used to
 // set up a catch-all Handler for all requests received by this SubChannel.
 //
@@ -147,7 +225,15 @@ func (c *SubChannel) GetHandlers() map[string]Handler {
 // SetHandler() will be forgotten. Further calls to Register() on this
 // SubChannel after SetHandler() is called will cause panics.
 func (c *SubChannel) SetHandler(h Handler) {
+	c.Lock()
 	c.handler = h
+	c.Unlock()
+}
+
+func (c *SubChannel) getHandler() Handler {
+	c.RLock()
+	defer c.RUnlock()
+	return c.handler
 }
 
 // Logger returns the logger for this subchannel.
@@ -21,6 +21,7 @@
 package tchannel_test
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -30,7 +31,6 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"golang.org/x/net/context"
 )
 
 type chanSet struct {
@@ -196,7 +196,7 @@ func TestGetHandlers(t *testing.T) {
 		{
 			serviceName: ch.ServiceName(),
 			// Default service name comes with extra introspection methods.
-			wantMethods: []string{"_gometa_introspect", "_gometa_runtime", "method1", "method2"},
+			wantMethods: []string{"_gometa_health", "_gometa_introspect", "_gometa_runtime", "method1", "method2"},
 		},
 		{
 			serviceName: "foo",
@@ -268,3 +268,102 @@ func TestHandlerWithoutSubChannel(t *testing.T) {
 		})
 	})
 }
+
+func TestRegisterPrefix(t *testing.T) {
+	testutils.WithTestServer(t, nil, func(ts *testutils.TestServer) {
+		sc := ts.Server().GetSubChannel(ts.ServiceName())
+		respondWith := func(name string) Handler {
+			return HandlerFunc(func(ctx context.Context, call *InboundCall) {
+				_, err := raw.ReadArgs(call)
+				require.NoError(t, err, "Failed to read args")
+				require.NoError(t, raw.WriteResponse(call.Response(), &raw.Res{
+					Arg3: []byte(name + ":" + call.MethodString()),
+				}), "Failed to write response")
+			})
+		}
+		sc.Register(respondWith("exact"), "users/get")
+		sc.RegisterPrefix(respondWith("users"), "users/")
+		sc.RegisterPrefix(respondWith("admin"), "users/admin/")
+
+		client := ts.NewClient(nil)
+		call := func(method string) (string, error) {
+			ctx, cancel := NewContext(testutils.Timeout(time.Second))
+			defer cancel()
+			_, arg3, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), method, nil, nil)
+			return string(arg3), err
+		}
+
+		tests := []struct {
+			method string
+			want   string
+		}{
+			{"users/get", "exact:users/get"},
+			{"users/list", "users:users/list"},
+			{"users/admin/delete", "admin:users/admin/delete"},
+		}
+		for _, tt := range tests {
+			got, err := call(tt.method)
+			if assert.NoError(t, err, "Call to %v failed", tt.method) {
+				assert.Equal(t, tt.want, got, "Call to %v used unexpected handler", tt.method)
+			}
+		}
+
+		state := ts.Server().IntrospectState(nil).SubChannels[ts.ServiceName()]
+		assert.Equal(t, []string{"users/", "users/admin/"}, state.Handler.Prefixes, "Unexpected introspected prefixes")
+		assert.Contains(t, state.Handler.Methods, "users/get", "Missing introspected method")
+
+		assert.True(t, sc.UnregisterPrefix("users/admin/"), "Prefix should be unregistered")
+		assert.False(t, sc.UnregisterPrefix("users/admin/"), "Prefix is no longer registered")
+		got, err := call("users/admin/delete")
+		require.NoError(t, err, "Call after unregistering prefix failed")
+		assert.Equal(t, "users:users/admin/delete", got, "Call should use the shorter prefix")
+
+		assert.True(t, sc.Unregister("users/get"), "Method should be unregistered")
+		assert.False(t, sc.Unregister("users/get"), "Method is no longer registered")
+		got, err = call("users/get")
+		require.NoError(t, err, "Call after unregistering method failed")
+		assert.Equal(t, "users:users/get", got, "Call should use the prefix handler")
+	})
+}
+
+func TestDynamicRegistration(t *testing.T) {
+	opts := testutils.NewOpts().AddLogFilter("Couldn't find handler.", 1000)
+	testutils.WithTestServer(t, opts, func(ts *testutils.TestServer) {
+		client := ts.NewClient(nil)
+		stop := make(chan struct{})
+		done := make(chan struct{})
+
+		// Register and unregister the method while calls are being made.
+		go func() {
+			defer close(done)
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if i%2 == 0 {
+					testutils.RegisterEcho(ts.Server(), nil)
+				} else {
+					ts.Server().Unregister("echo")
+				}
+				ts.Server().IntrospectState(nil)
+			}
+		}()
+
+		for i := 0; i < 20; i++ {
+			ctx, cancel := NewContext(testutils.Timeout(time.Second))
+			_, _, _, err := raw.Call(ctx, client, ts.HostPort(), ts.ServiceName(), "echo", nil, nil)
+			cancel()
+			if err != nil {
+				assert.Equal(t, ErrCodeBadRequest, GetSystemErrorCode(err), "Unexpected error: %v", err)
+			}
+		}
+		close(stop)
+		<-done
+
+		ts.Server().Unregister("echo")
+		_, ok := ts.Server().GetSubChannel(ts.ServiceName()).GetHandlers()["echo"]
+		assert.False(t, ok, "Unregistered method should not be in handlers")
+	})
+}
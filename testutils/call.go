@@ -93,7 +93,7 @@ func NewIncomingCall(callerName string) tchannel.IncomingCall {
 
 // FakeCallFrame is a stub implementation of the CallFrame interface.
 type FakeCallFrame struct {
-	ServiceF, MethodF, CallerF, RoutingKeyF, RoutingDelegateF string
+	ServiceF, MethodF, CallerF, RoutingKeyF, RoutingDelegateF, ShardKeyF string
 }
 
 var _ relay.CallFrame = FakeCallFrame{}
@@ -122,3 +122,8 @@ func (f FakeCallFrame) RoutingKey() []byte {
 func (f FakeCallFrame) RoutingDelegate() []byte {
 	return []byte(f.RoutingDelegateF)
 }
+
+// ShardKey returns the shard key field.
+func (f FakeCallFrame) ShardKey() []byte {
+	return []byte(f.ShardKeyF)
+}
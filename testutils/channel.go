@@ -21,14 +21,15 @@
 package testutils
 
 import (
+	"context"
 	"fmt"
 	"net"
 
 	"github.com/uber/tchannel-go"
 	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/tnet"
 
 	"github.com/uber-go/atomic"
-	"golang.org/x/net/context"
 )
 
 // NewServerChannel creates a TChannel that is listening and returns the channel.
@@ -36,7 +37,7 @@ import (
 func NewServerChannel(opts *ChannelOpts) (*tchannel.Channel, error) {
 	opts = opts.Copy()
 
-	l, err := net.Listen("tcp", "127.0.0.1:0")
+	l, err := listen(opts.InMemory)
 	if err != nil {
 		return nil, fmt.Errorf("failed to listen: %v", err)
 	}
@@ -48,6 +49,7 @@ func NewServerChannel(opts *ChannelOpts) (*tchannel.Channel, error) {
 	serviceName := defaultString(opts.ServiceName, DefaultServerName)
 	opts.ProcessName = defaultString(opts.ProcessName, serviceName+"-"+port)
 	updateOptsLogger(opts)
+	updateOptsDialer(opts)
 	ch, err := tchannel.NewChannel(serviceName, &opts.ChannelOptions)
 	if err != nil {
 		return nil, fmt.Errorf("NewChannel failed: %v", err)
@@ -60,6 +62,31 @@ func NewServerChannel(opts *ChannelOpts) (*tchannel.Channel, error) {
 	return ch, nil
 }
 
+func listen(inMemory bool) (net.Listener, error) {
+	if inMemory {
+		return tnet.ListenMemory("memory:0")
+	}
+	return net.Listen("tcp", "127.0.0.1:0")
+}
+
+// updateOptsDialer lets channels connect to in-memory listeners, unless the
+// test uses its own Dialer.
+func updateOptsDialer(opts *ChannelOpts) {
+	if opts.Dialer == nil {
+		opts.Dialer = dialMemory
+	}
+}
+
+// dialMemory connects to in-memory listeners without the network, and to
+// any other address using the network.
+func dialMemory(ctx context.Context, network, address string) (net.Conn, error) {
+	if conn, err := tnet.DialMemory(ctx, address); err != tnet.ErrNoMemoryListener {
+		return conn, err
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, network, address)
+}
+
 var totalClients atomic.Uint32
 
 // NewClientChannel creates a TChannel that is not listening.
@@ -71,6 +98,7 @@ func NewClientChannel(opts *ChannelOpts) (*tchannel.Channel, error) {
 	serviceName := defaultString(opts.ServiceName, DefaultClientName)
 	opts.ProcessName = defaultString(opts.ProcessName, serviceName+"-"+fmt.Sprint(clientNum))
 	updateOptsLogger(opts)
+	updateOptsDialer(opts)
 	return tchannel.NewChannel(serviceName, &opts.ChannelOptions)
 }
 
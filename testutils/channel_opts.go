@@ -56,6 +56,10 @@ type ChannelOpts struct {
 	// OnlyRelay instructs TestServer the test must only be run with a relay.
 	OnlyRelay bool
 
+	// InMemory serves the channel using an in-memory listener instead of a
+	// TCP port, so clients in the same process connect without the network.
+	InMemory bool
+
 	// RunCount is the number of times the test should be run. Zero or
 	// negative values are treated as a single run.
 	RunCount int
@@ -131,6 +135,12 @@ func (o *ChannelOpts) SetTosPriority(tosPriority tos.ToS) *ChannelOpts {
 	return o
 }
 
+// SetDialTimeout sets DialTimeout in ChannelOptions.
+func (o *ChannelOpts) SetDialTimeout(d time.Duration) *ChannelOpts {
+	o.DialTimeout = d
+	return o
+}
+
 // SetTimeNow sets TimeNow in ChannelOptions.
 func (o *ChannelOpts) SetTimeNow(timeNow func() time.Time) *ChannelOpts {
 	o.TimeNow = timeNow
@@ -155,6 +165,12 @@ func (o *ChannelOpts) SetRelayOnly() *ChannelOpts {
 	return o
 }
 
+// SetInMemory serves the channel using an in-memory listener.
+func (o *ChannelOpts) SetInMemory() *ChannelOpts {
+	o.InMemory = true
+	return o
+}
+
 // SetRunCount sets the number of times run the test.
 func (o *ChannelOpts) SetRunCount(n int) *ChannelOpts {
 	o.RunCount = n
@@ -200,6 +216,30 @@ func (o *ChannelOpts) SetRelayMaxTimeout(d time.Duration) *ChannelOpts {
 	return o
 }
 
+// SetRelayAdaptiveTimeouts sets the adaptive timeouts for relayed calls.
+func (o *ChannelOpts) SetRelayAdaptiveTimeouts(opts tchannel.RelayAdaptiveTimeoutOptions) *ChannelOpts {
+	o.ChannelOptions.RelayAdaptiveTimeouts = opts
+	return o
+}
+
+// SetDetailedErrors wraps errors returned by outbound calls in a CallError.
+func (o *ChannelOpts) SetDetailedErrors() *ChannelOpts {
+	o.ChannelOptions.DetailedErrors = true
+	return o
+}
+
+// SetPeerCacheFile sets the file used to persist the channel's peer list.
+func (o *ChannelOpts) SetPeerCacheFile(file string) *ChannelOpts {
+	o.ChannelOptions.PeerCacheFile = file
+	return o
+}
+
+// SetWarmUp sets the options for connecting to cached peers on startup.
+func (o *ChannelOpts) SetWarmUp(opts tchannel.WarmUpOptions) *ChannelOpts {
+	o.ChannelOptions.WarmUp = opts
+	return o
+}
+
 // SetOnPeerStatusChanged sets the callback for channel status change
 // noficiations.
 func (o *ChannelOpts) SetOnPeerStatusChanged(f func(*tchannel.Peer)) *ChannelOpts {
@@ -207,6 +247,33 @@ func (o *ChannelOpts) SetOnPeerStatusChanged(f func(*tchannel.Peer)) *ChannelOpt
 	return o
 }
 
+// SetOnPeerAvailable sets the callback for when a peer gains its first
+// active connection.
+func (o *ChannelOpts) SetOnPeerAvailable(f func(*tchannel.Peer)) *ChannelOpts {
+	o.ChannelOptions.OnPeerAvailable = f
+	return o
+}
+
+// SetOnPeerUnavailable sets the callback for when a peer loses its last
+// active connection.
+func (o *ChannelOpts) SetOnPeerUnavailable(f func(*tchannel.Peer)) *ChannelOpts {
+	o.ChannelOptions.OnPeerUnavailable = f
+	return o
+}
+
+// SetPeerSelectionStrategy sets the strategy used to select peers from the
+// channel's PeerList.
+func (o *ChannelOpts) SetPeerSelectionStrategy(sc tchannel.ScoreCalculator) *ChannelOpts {
+	o.ChannelOptions.PeerSelectionStrategy = sc
+	return o
+}
+
+// SetDrainTimeout sets the maximum time Close waits for in-flight calls.
+func (o *ChannelOpts) SetDrainTimeout(d time.Duration) *ChannelOpts {
+	o.ChannelOptions.DrainTimeout = d
+	return o
+}
+
 func defaultString(v string, defaultValue string) string {
 	if v == "" {
 		return defaultValue
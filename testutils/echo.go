@@ -21,6 +21,7 @@
 package testutils
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -28,7 +29,6 @@ import (
 	"github.com/uber/tchannel-go/raw"
 
 	"github.com/stretchr/testify/assert"
-	"golang.org/x/net/context"
 )
 
 // CallEcho calls the "echo" endpoint from the given src to target.
@@ -28,11 +28,12 @@ import (
 	"time"
 )
 
-// filterStacks will filter any stacks excluded by the given VerifyOpts.
-func filterStacks(stacks []Stack, skipID int, opts *VerifyOpts) []Stack {
+// filterStacks will filter any stacks excluded by the given VerifyOpts, and
+// any stacks with an ID in skipIDs.
+func filterStacks(stacks []Stack, skipIDs map[int]struct{}, opts *VerifyOpts) []Stack {
 	filtered := stacks[:0]
 	for _, stack := range stacks {
-		if stack.ID() == skipID || isTestStack(stack) {
+		if _, ok := skipIDs[stack.ID()]; ok || isTestStack(stack) {
 			continue
 		}
 		if opts.ShouldSkip(stack) {
@@ -57,13 +58,26 @@ func isTestStack(s Stack) bool {
 // IdentifyLeaks looks for extra goroutines, and returns a descriptive error if
 // it finds any.
 func IdentifyLeaks(opts *VerifyOpts) error {
-	cur := GetCurrentStack().id
+	return identifyLeaks(nil, opts)
+}
+
+// IdentifyNewLeaks is like IdentifyLeaks, but ignores goroutines that are in
+// the given snapshot, which is typically taken using GetAll when a test starts.
+func IdentifyNewLeaks(snapshot []Stack, opts *VerifyOpts) error {
+	return identifyLeaks(snapshot, opts)
+}
+
+func identifyLeaks(snapshot []Stack, opts *VerifyOpts) error {
+	skipIDs := map[int]struct{}{GetCurrentStack().id: {}}
+	for _, s := range snapshot {
+		skipIDs[s.id] = struct{}{}
+	}
 
 	const maxAttempts = 50
 	var stacks []Stack
 	for i := 0; i < maxAttempts; i++ {
 		stacks = GetAll()
-		stacks = filterStacks(stacks, cur, opts)
+		stacks = filterStacks(stacks, skipIDs, opts)
 
 		if len(stacks) == 0 {
 			return nil
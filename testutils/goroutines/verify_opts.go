@@ -26,16 +26,27 @@ import "bytes"
 type VerifyOpts struct {
 	// Excludes is a list of strings that will exclude a stack from being considered a leak.
 	Excludes []string
+
+	// Includes is a list of strings that a stack must contain to be considered
+	// a leak, e.g. a package path. If empty, all stacks are considered.
+	Includes []string
 }
 
 // ShouldSkip returns whether the given stack should be skipped when doing verification.
 func (opts *VerifyOpts) ShouldSkip(s Stack) bool {
-	if opts == nil || len(opts.Excludes) == 0 {
+	if opts == nil {
 		return false
 	}
 
-	for _, exclude := range opts.Excludes {
-		if bytes.Contains(s.Full(), []byte(exclude)) {
+	if len(opts.Includes) > 0 && !containsAny(s.Full(), opts.Includes) {
+		return true
+	}
+	return containsAny(s.Full(), opts.Excludes)
+}
+
+func containsAny(stack []byte, strs []string) bool {
+	for _, s := range strs {
+		if bytes.Contains(stack, []byte(s)) {
 			return true
 		}
 	}
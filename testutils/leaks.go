@@ -0,0 +1,162 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package testutils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/testutils/goroutines"
+)
+
+// tchannelPackage is the import path prefix that identifies goroutines owned
+// by tchannel.
+const tchannelPackage = "github.com/uber/tchannel-go"
+
+// LeakDetector verifies that a test does not leak goroutines started by
+// tchannel, or message exchanges and relay items on its channels.
+//
+// Goroutines that are already running when the LeakDetector is created are not
+// reported, so it can be used in tests that run in parallel with other tests
+// or that use long-lived channels.
+type LeakDetector struct {
+	sync.Mutex
+
+	t        testing.TB
+	snapshot []goroutines.Stack
+	opts     goroutines.VerifyOpts
+	channels []*tchannel.Channel
+}
+
+// NewLeakDetector snapshots the running goroutines and returns a
+// LeakDetector. Channels created by the test should be added using Track.
+func NewLeakDetector(t testing.TB) *LeakDetector {
+	return &LeakDetector{
+		t:        t,
+		snapshot: goroutines.GetAll(),
+		opts: goroutines.VerifyOpts{
+			Includes: []string{tchannelPackage},
+		},
+	}
+}
+
+// Track adds channels that should have no message exchanges or relay items
+// left once they're closed.
+func (d *LeakDetector) Track(chs ...*tchannel.Channel) {
+	d.Lock()
+	d.channels = append(d.channels, chs...)
+	d.Unlock()
+}
+
+// Exclude ignores goroutines whose stack contains any of the given strings.
+func (d *LeakDetector) Exclude(strs ...string) {
+	d.Lock()
+	d.opts.Excludes = append(d.opts.Excludes, strs...)
+	d.Unlock()
+}
+
+// IdentifyLeaks waits for the tracked channels to close, and returns an error
+// that describes any channels that did not close, message exchanges and relay
+// items left on the channels, and new goroutines that are still running.
+// It should be called after the channels have been closed.
+func (d *LeakDetector) IdentifyLeaks() error {
+	d.Lock()
+	channels := append([]*tchannel.Channel(nil), d.channels...)
+	opts := d.opts
+	opts.Excludes = append([]string(nil), d.opts.Excludes...)
+	d.Unlock()
+
+	var leaks []string
+	for _, ch := range channels {
+		if err := waitForChannelClose(ch); err != nil {
+			leaks = append(leaks, err.Error())
+		}
+
+		state := ch.IntrospectState(&tchannel.IntrospectionOptions{
+			IncludeExchanges:  true,
+			IncludeTombstones: true,
+		})
+		if exchanges := describeLeakedExchanges(state); exchanges != "" {
+			leaks = append(leaks, fmt.Sprintf("channel %v (%v) has leftover exchanges:\n%v",
+				state.LocalPeer.ServiceName, state.LocalPeer.HostPort, exchanges))
+		}
+		if items := describeLeakedRelayItems(state); items != "" {
+			leaks = append(leaks, fmt.Sprintf("channel %v (%v) has leftover relay items:\n%v",
+				state.LocalPeer.ServiceName, state.LocalPeer.HostPort, items))
+		}
+	}
+
+	if err := goroutines.IdentifyNewLeaks(d.snapshot, &opts); err != nil {
+		leaks = append(leaks, err.Error())
+	}
+
+	if len(leaks) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(leaks, "\n"))
+}
+
+// VerifyNoLeaks calls IdentifyLeaks and fails the test if it finds any leaks.
+func (d *LeakDetector) VerifyNoLeaks() {
+	if err := d.IdentifyLeaks(); err != nil {
+		d.t.Errorf("Found leaks after closing channels:\n%v", err)
+	}
+}
+
+func describeLeakedRelayItems(rs *tchannel.RuntimeState) string {
+	var leaks []string
+	for _, peer := range rs.RootPeers {
+		var connections []tchannel.ConnectionRuntimeState
+		connections = append(connections, peer.InboundConnections...)
+		connections = append(connections, peer.OutboundConnections...)
+		for _, c := range connections {
+			if c.Relayer.Count == 0 {
+				continue
+			}
+			items := describeRelayItems(c.Relayer.InboundItems)
+			items = append(items, describeRelayItems(c.Relayer.OutboundItems)...)
+			leaks = append(leaks, fmt.Sprintf("Connection %d (%v -> %v) has %v leftover relay items:\n\t%v",
+				c.ID, c.LocalHostPort, c.RemoteHostPort, c.Relayer.Count, strings.Join(items, "\n\t")))
+		}
+	}
+	return strings.Join(leaks, "\n")
+}
+
+func describeRelayItems(items tchannel.RelayItemSetState) []string {
+	var ids []string
+	for id := range items.Items {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var described []string
+	for _, id := range ids {
+		item := items.Items[id]
+		described = append(described, fmt.Sprintf(" %v item %v remapped to %v on connection %v (tombstone: %v)",
+			items.Name, item.ID, item.RemapID, item.DestinationConnectionID, item.Tomb))
+	}
+	return described
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package testutils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeakDetector(t *testing.T) {
+	d := NewLeakDetector(t)
+	server := NewServer(t, nil)
+	client := NewClient(t, nil)
+	d.Track(server, client)
+
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	RegisterFunc(server, "block", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		close(started)
+		<-unblock
+		return &raw.Res{}, nil
+	})
+
+	errC := make(chan error, 1)
+	go func() {
+		ctx, cancel := tchannel.NewContext(Timeout(10 * time.Second))
+		defer cancel()
+		_, _, _, err := raw.Call(ctx, client, server.PeerInfo().HostPort, server.ServiceName(), "block", nil, nil)
+		errC <- err
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(Timeout(time.Second)):
+		close(unblock)
+		require.FailNow(t, "Timed out waiting for the handler to start")
+	}
+
+	err := d.IdentifyLeaks()
+	if assert.Error(t, err, "Expected leaks while a call is in progress") {
+		assert.Contains(t, err.Error(), "did not close", "Expected open channels to be reported")
+		assert.Contains(t, err.Error(), "leftover exchanges", "Expected exchanges to be reported")
+		assert.Contains(t, err.Error(), "found unexpected goroutines", "Expected goroutines to be reported")
+	}
+
+	close(unblock)
+	require.NoError(t, <-errC, "Call failed")
+	server.Close()
+	client.Close()
+	assert.NoError(t, d.IdentifyLeaks(), "Expected no leaks after channels are closed")
+}
+
+func TestLeakDetectorIgnoresExistingGoroutines(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() { <-stop }()
+
+	d := NewLeakDetector(t)
+	assert.NoError(t, d.IdentifyLeaks(), "Goroutines started before the detector should be ignored")
+
+	go func() { <-stop }()
+	err := d.IdentifyLeaks()
+	if assert.Error(t, err, "Expected new goroutines to be reported") {
+		assert.Contains(t, err.Error(), "TestLeakDetectorIgnoresExistingGoroutines", "Expected stack of leaked goroutine")
+	}
+
+	d.Exclude("TestLeakDetectorIgnoresExistingGoroutines")
+	assert.NoError(t, d.IdentifyLeaks(), "Excluded goroutines should be ignored")
+}
@@ -40,6 +40,7 @@ type Mock struct {
 	ch              *tchannel.Channel
 	respCh          chan int
 	advertised      []string
+	unadvertised    []string
 	discoverResults map[string][]string
 }
 
@@ -58,7 +59,7 @@ func New() (*Mock, error) {
 		respCh:          make(chan int),
 		discoverResults: make(map[string][]string),
 	}
-	if err := json.Register(ch, json.Handlers{"ad": mh.adHandler}, nil); err != nil {
+	if err := json.Register(ch, json.Handlers{"ad": mh.adHandler, "unad": mh.unadHandler}, nil); err != nil {
 		return nil, err
 	}
 
@@ -132,6 +133,25 @@ func (h *Mock) adHandler(ctx json.Context, req *hyperbahn.AdRequest) (*hyperbahn
 	}
 }
 
+func (h *Mock) unadHandler(ctx json.Context, req *hyperbahn.AdRequest) (*hyperbahn.AdResponse, error) {
+	callerHostPort := tchannel.CurrentCall(ctx).RemotePeer().HostPort
+	h.Lock()
+	defer h.Unlock()
+	for _, s := range req.Services {
+		h.unadvertised = append(h.unadvertised, s.Name)
+		h.ch.GetSubChannel(s.Name, tchannel.Isolated).Peers().Remove(callerHostPort)
+	}
+	return &hyperbahn.AdResponse{}, nil
+}
+
+// GetUnadvertised returns the list of services whose advertisements were removed.
+func (h *Mock) GetUnadvertised() []string {
+	h.RLock()
+	defer h.RUnlock()
+
+	return h.unadvertised
+}
+
 // GetAdvertised returns the list of services registered.
 func (h *Mock) GetAdvertised() []string {
 	h.RLock()
@@ -21,6 +21,7 @@
 package testutils
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"runtime"
@@ -37,7 +38,6 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/uber-go/atomic"
-	"golang.org/x/net/context"
 )
 
 // Has a previous test already leaked a goroutine?
@@ -293,26 +293,14 @@ func (ts *TestServer) waitForChannelClose(ch *tchannel.Channel) {
 	if ts.Failed() {
 		return
 	}
-	started := time.Now()
-
-	var state tchannel.ChannelState
-	for i := 0; i < 60; i++ {
-		if state = ch.State(); state == tchannel.ChannelClosed {
-			return
-		}
 
-		runtime.Gosched()
-		if i < 5 {
-			continue
-		}
-
-		sleepFor := time.Duration(i) * 100 * time.Microsecond
-		time.Sleep(Timeout(sleepFor))
+	err := waitForChannelClose(ch)
+	if err == nil {
+		return
 	}
 
 	// Channel is not closing, fail the test.
-	sinceStart := time.Since(started)
-	ts.Errorf("Channel %p did not close after %v, last state: %v", ch, sinceStart, state)
+	ts.Error(err.Error())
 
 	// The introspected state might help debug why the channel isn't closing.
 	introspected := ch.IntrospectState(&tchannel.IntrospectionOptions{IncludeExchanges: true, IncludeTombstones: true})
@@ -387,10 +375,37 @@ func (ts *TestServer) verifyNoGoroutinesLeaked() {
 	ts.Error(err.Error())
 }
 
+// waitForChannelClose waits for ch to be closed, and returns an error if it
+// does not close.
+func waitForChannelClose(ch *tchannel.Channel) error {
+	started := time.Now()
+
+	var state tchannel.ChannelState
+	for i := 0; i < 60; i++ {
+		if state = ch.State(); state == tchannel.ChannelClosed {
+			return nil
+		}
+
+		runtime.Gosched()
+		if i < 5 {
+			continue
+		}
+
+		sleepFor := time.Duration(i) * 100 * time.Microsecond
+		time.Sleep(Timeout(sleepFor))
+	}
+
+	sinceStart := time.Since(started)
+	return fmt.Errorf("channel %p did not close after %v, last state: %v", ch, sinceStart, state)
+}
+
 func comparableState(ch *tchannel.Channel, opts *tchannel.IntrospectionOptions) *tchannel.RuntimeState {
 	s := ch.IntrospectState(opts)
 	s.SubChannels = nil
 	s.Peers = nil
+	// The number of frames available in the pool changes as calls are made.
+	s.FramePool.Available = 0
+	s.FramePool.Stats = nil
 	return s
 }
 
@@ -433,7 +448,8 @@ func describeLeakedExchangesSingleConn(cs *tchannel.ConnectionRuntimeState) stri
 		return ""
 	}
 
-	return fmt.Sprintf("Connection %d has leftover exchanges:\n\t%v", cs.ID, strings.Join(exchanges, "\n\t"))
+	return fmt.Sprintf("Connection %d (%v -> %v) has leftover exchanges:\n\t%v",
+		cs.ID, cs.LocalHostPort, cs.RemoteHostPort, strings.Join(exchanges, "\n\t"))
 }
 
 func withServer(t testing.TB, chanOpts *ChannelOpts, f func(*TestServer)) {
@@ -21,13 +21,12 @@
 package testtracing
 
 import (
+	"context"
 	json_encoding "encoding/json"
 	"testing"
 
 	"github.com/uber/tchannel-go"
 	"github.com/uber/tchannel-go/raw"
-
-	"golang.org/x/net/context"
 )
 
 func requestFromRaw(args *raw.Args) *TracingRequest {
@@ -21,11 +21,12 @@
 package thrift
 
 import (
+	"context"
+
 	"github.com/uber/tchannel-go"
 	"github.com/uber/tchannel-go/internal/argreader"
 
 	"github.com/apache/thrift/lib/go/thrift"
-	"golang.org/x/net/context"
 )
 
 // client implements TChanClient and makes outgoing Thrift calls.
@@ -149,6 +150,9 @@ func (c *client) Call(ctx Context, thriftService, methodName string, req, resp t
 		}
 
 		respHeaders, isOK, err = readResponse(call.Response(), resp)
+		if err == nil && !isOK {
+			return rs.RetryAppError(respHeaders, resp)
+		}
 		return err
 	})
 	if err != nil {
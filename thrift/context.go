@@ -21,10 +21,10 @@
 package thrift
 
 import (
+	"context"
 	"time"
 
 	"github.com/uber/tchannel-go"
-	"golang.org/x/net/context"
 )
 
 // Context is a Thrift Context which contains request and response headers.
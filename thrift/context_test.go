@@ -21,6 +21,7 @@
 package thrift_test
 
 import (
+	"context"
 	"errors"
 	"testing"
 	"time"
@@ -33,7 +34,6 @@ import (
 	gen "github.com/uber/tchannel-go/thrift/gen-go/test"
 
 	"github.com/stretchr/testify/assert"
-	"golang.org/x/net/context"
 )
 
 func TestWrapContext(t *testing.T) {
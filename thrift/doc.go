@@ -39,6 +39,14 @@ To use a Thrift client use the generated TChan client:
 This client can be used similar to a standard Thrift client, except a Context
 is passed with options (such as timeout).
 
+Services generated by ThriftRW can be registered on the same server using
+NewThriftRWServer, with a ThriftRWHandler for each method. Exceptions declared
+by a method are returned as application errors, with the exception in arg3.
+
+Endpoints that return large lists can be paginated by setting the token for
+the next page in handlers using SetNextPageToken (or PageRange), and fetching
+the pages in clients using a PageIterator.
+
 TODO(prashant): Add and document header support.
 */
 package thrift
@@ -96,6 +96,22 @@ func TestCustomHealthNoEmpty(t *testing.T) {
 	})
 }
 
+func TestCustomHealthChannel(t *testing.T) {
+	tchan, server := setupMetaServer(t)
+	defer tchan.Close()
+	server.RegisterHealthHandler(customHealthNoEmpty)
+
+	client := testutils.NewClient(t, nil)
+	defer client.Close()
+
+	ctx, cancel := tchannel.NewContext(time.Second)
+	defer cancel()
+	status, err := client.CheckHealth(ctx, tchan.PeerInfo().HostPort)
+	if assert.NoError(t, err, "CheckHealth failed") {
+		assert.Equal(t, tchannel.HealthStatus{Ok: false, Message: "from me"}, status, "Channel health should match the thrift health")
+	}
+}
+
 func withMetaSetup(t *testing.T, f func(ctx Context, c tchanMeta, server *Server)) {
 	ctx, cancel := NewContext(time.Second * 10)
 	defer cancel()
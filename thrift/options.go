@@ -21,8 +21,9 @@
 package thrift
 
 import (
+	"context"
+
 	"github.com/apache/thrift/lib/go/thrift"
-	"golang.org/x/net/context"
 )
 
 // RegisterOption is the interface for options to Register.
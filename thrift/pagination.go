@@ -0,0 +1,137 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package thrift
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	// pageTokenHeader is the request header containing the token for the
+	// page the client is requesting. It's not set for the first page.
+	pageTokenHeader = "$page$token"
+
+	// nextPageTokenHeader is the response header containing the token for the
+	// next page. It's not set for the last page.
+	nextPageTokenHeader = "$page$next"
+)
+
+// PageToken returns the token for the page requested by a call made using a
+// PageIterator. It returns an empty string for the first page.
+func PageToken(ctx Context) string {
+	return ctx.Headers()[pageTokenHeader]
+}
+
+// SetNextPageToken sets the token that a PageIterator passes to the next call
+// to fetch the following page. If the token is empty, the current page is the
+// last page. It must be called after any calls to ctx.SetResponseHeaders.
+func SetNextPageToken(ctx Context, token string) {
+	headers := make(map[string]string, len(ctx.ResponseHeaders())+1)
+	for k, v := range ctx.ResponseHeaders() {
+		headers[k] = v
+	}
+	if token == "" {
+		delete(headers, nextPageTokenHeader)
+	} else {
+		headers[nextPageTokenHeader] = token
+	}
+	ctx.SetResponseHeaders(headers)
+}
+
+// PageRange is a helper for handlers that return pages of a list of n items.
+// It returns the range [start, end) of the items in the requested page of at
+// most pageSize items, and sets the token for the next page.
+func PageRange(ctx Context, n, pageSize int) (start, end int, err error) {
+	if pageSize <= 0 {
+		return 0, 0, fmt.Errorf("invalid page size %v", pageSize)
+	}
+
+	if token := PageToken(ctx); token != "" {
+		start, err = strconv.Atoi(token)
+		if err != nil || start < 0 || start > n {
+			return 0, 0, fmt.Errorf("invalid page token %q", token)
+		}
+	}
+
+	end = start + pageSize
+	if end >= n {
+		end = n
+		SetNextPageToken(ctx, "")
+	} else {
+		SetNextPageToken(ctx, strconv.Itoa(end))
+	}
+	return start, end, nil
+}
+
+// PageFunc makes the call for a single page using the given Context, which
+// contains the token for the page. The call's response should be handled
+// before returning.
+type PageFunc func(ctx Context) error
+
+// PageIterator fetches the pages of a paginated endpoint by making successive
+// calls, passing the token returned by each call to the next call. Each call
+// to Next fetches a page, until the last page has been fetched.
+type PageIterator struct {
+	ctx   Context
+	fetch PageFunc
+	token string
+	done  bool
+	err   error
+}
+
+// NewPageIterator returns a PageIterator that fetches pages using fetch. The
+// request headers of ctx are passed to every call.
+func NewPageIterator(ctx Context, fetch PageFunc) *PageIterator {
+	return &PageIterator{ctx: ctx, fetch: fetch}
+}
+
+// Next fetches the next page. It returns false once all pages have been
+// fetched, or if fetching a page failed, in which case Err returns the error.
+func (it *PageIterator) Next() bool {
+	if it.done {
+		return false
+	}
+
+	headers := make(map[string]string, len(it.ctx.Headers())+1)
+	for k, v := range it.ctx.Headers() {
+		headers[k] = v
+	}
+	if it.token != "" {
+		headers[pageTokenHeader] = it.token
+	}
+
+	ctx := WithHeaders(it.ctx, headers)
+	if err := it.fetch(ctx); err != nil {
+		it.err = err
+		it.done = true
+		return false
+	}
+
+	it.token = ctx.ResponseHeaders()[nextPageTokenHeader]
+	it.done = it.token == ""
+	return true
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *PageIterator) Err() error {
+	return it.err
+}
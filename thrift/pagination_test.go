@@ -0,0 +1,125 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package thrift_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go/thrift"
+
+	"github.com/uber/tchannel-go/testutils"
+	gen "github.com/uber/tchannel-go/thrift/gen-go/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pagedHandler returns the letters of arg.S2 as pages of arg.I3 letters.
+type pagedHandler struct {
+	t       *testing.T
+	headers map[string]string
+}
+
+func (h pagedHandler) Call(ctx Context, arg *gen.Data) (*gen.Data, error) {
+	assert.Equal(h.t, h.headers["h"], ctx.Headers()["h"], "Request headers should be passed to every page")
+	ctx.SetResponseHeaders(map[string]string{"resp": "v"})
+
+	start, end, err := PageRange(ctx, len(arg.S2), int(arg.I3))
+	if err != nil {
+		return nil, err
+	}
+	return &gen.Data{S2: arg.S2[start:end]}, nil
+}
+
+func (pagedHandler) Simple(ctx Context) error       { return nil }
+func (pagedHandler) SimpleFuture(ctx Context) error { return nil }
+
+func TestPageIterator(t *testing.T) {
+	headers := map[string]string{"h": "v"}
+
+	server := testutils.NewServer(t, nil)
+	defer server.Close()
+	NewServer(server).Register(gen.NewTChanSimpleServiceServer(pagedHandler{t, headers}))
+
+	client := testutils.NewClient(t, nil)
+	defer client.Close()
+	client.Peers().Add(server.PeerInfo().HostPort)
+	simpleClient := gen.NewTChanSimpleServiceClient(NewClient(client, server.ServiceName(), nil))
+
+	tests := []struct {
+		s        string
+		pageSize int32
+		want     []string
+	}{
+		{s: "", pageSize: 2, want: []string{""}},
+		{s: "ab", pageSize: 2, want: []string{"ab"}},
+		{s: "abcde", pageSize: 2, want: []string{"ab", "cd", "e"}},
+		{s: "abcdef", pageSize: 3, want: []string{"abc", "def"}},
+	}
+
+	for _, tt := range tests {
+		ctx, cancel := NewContext(time.Second)
+		defer cancel()
+
+		var pages []string
+		it := NewPageIterator(WithHeaders(ctx, headers), func(ctx Context) error {
+			res, err := simpleClient.Call(ctx, &gen.Data{S2: tt.s, I3: tt.pageSize})
+			if err == nil {
+				pages = append(pages, res.S2)
+				assert.Equal(t, "v", ctx.ResponseHeaders()["resp"], "Response headers should be preserved")
+			}
+			return err
+		})
+		for it.Next() {
+		}
+		require.NoError(t, it.Err(), "Unexpected error paging %q", tt.s)
+		assert.Equal(t, tt.want, pages, "Unexpected pages for %q", tt.s)
+		assert.False(t, it.Next(), "Next should return false after the last page")
+	}
+}
+
+func TestPageIteratorError(t *testing.T) {
+	server := testutils.NewServer(t, nil)
+	defer server.Close()
+	NewServer(server).Register(gen.NewTChanSimpleServiceServer(pagedHandler{t, nil}))
+
+	client := testutils.NewClient(t, nil)
+	defer client.Close()
+	client.Peers().Add(server.PeerInfo().HostPort)
+	simpleClient := gen.NewTChanSimpleServiceClient(NewClient(client, server.ServiceName(), nil))
+
+	ctx, cancel := NewContext(time.Second)
+	defer cancel()
+
+	var calls int
+	it := NewPageIterator(ctx, func(ctx Context) error {
+		calls++
+		_, err := simpleClient.Call(ctx, &gen.Data{S2: "abc", I3: 0})
+		return err
+	})
+	assert.False(t, it.Next(), "Next should fail for an invalid page size")
+	require.Error(t, it.Err(), "Expected error for invalid page size")
+	assert.True(t, strings.Contains(it.Err().Error(), "invalid page size"), "Unexpected error: %v", it.Err())
+	assert.False(t, it.Next(), "Next should not retry after an error")
+	assert.Equal(t, 1, calls, "Unexpected number of calls")
+}
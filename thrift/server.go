@@ -21,6 +21,7 @@
 package thrift
 
 import (
+	"context"
 	"log"
 	"strings"
 	"sync"
@@ -29,7 +30,6 @@ import (
 	"github.com/uber/tchannel-go/internal/argreader"
 
 	"github.com/apache/thrift/lib/go/thrift"
-	"golang.org/x/net/context"
 )
 
 type handler struct {
@@ -84,8 +84,15 @@ func (s *Server) Register(svr TChanServer, opts ...RegisterOption) {
 }
 
 // RegisterHealthHandler uses the user-specified function f for the Health endpoint.
+// If the server was created using a Channel, f is also used for the channel's
+// JSON health endpoint, see tchannel.Channel.SetHealthHandler.
 func (s *Server) RegisterHealthHandler(f HealthFunc) {
 	s.metaHandler.setHandler(f)
+	if ch, ok := s.ch.(*tchannel.Channel); ok {
+		ch.SetHealthHandler(func(ctx context.Context) (bool, string) {
+			return f(Wrap(ctx))
+		})
+	}
 }
 
 // SetContextFn sets the function used to convert a context.Context to a thrift.Context.
@@ -0,0 +1,150 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package thrift
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	athrift "github.com/apache/thrift/lib/go/thrift"
+	rwprotocol "go.uber.org/thriftrw/protocol"
+	"go.uber.org/thriftrw/wire"
+)
+
+var errThriftRWReadUnsupported = errors.New("thriftrw responses cannot be read")
+
+// ThriftRWValue is implemented by types generated by ThriftRW, such as the
+// Args and Result structs of a service method.
+type ThriftRWValue interface {
+	ToWire() (wire.Value, error)
+}
+
+// ThriftRWHandler handles a call to a single method of a ThriftRW-generated
+// service. The request is the method's Args struct, which can be decoded using
+// its generated FromWire method.
+type ThriftRWHandler func(ctx Context, req wire.Value) (ThriftRWResponse, error)
+
+// ThriftRWResponse is the response to a call to a ThriftRW method.
+type ThriftRWResponse struct {
+	// Body is the method's Result struct, typically created using the
+	// generated helper's WrapResponse.
+	Body ThriftRWValue
+
+	// IsApplicationError should be set if Body contains one of the method's
+	// exceptions, so that the call is marked as an application error.
+	IsApplicationError bool
+}
+
+// NewThriftRWResponse returns the response for a method given the error
+// returned by the method's implementation, and the result and error returned by
+// passing it to the generated helper's WrapResponse. Exceptions declared by
+// the method are wrapped into the result by WrapResponse, and are sent as
+// application errors with the exception in arg3. Any other error fails the call.
+func NewThriftRWResponse(methodErr error, result ThriftRWValue, wrapErr error) (ThriftRWResponse, error) {
+	if wrapErr != nil {
+		return ThriftRWResponse{}, wrapErr
+	}
+	return ThriftRWResponse{Body: result, IsApplicationError: methodErr != nil}, nil
+}
+
+type thriftRWServer struct {
+	service  string
+	handlers map[string]ThriftRWHandler
+}
+
+// NewThriftRWServer returns a TChanServer for a ThriftRW-generated service,
+// which can be registered on a Server alongside services generated by
+// thrift-gen. The handlers are keyed by the method name.
+func NewThriftRWServer(service string, handlers map[string]ThriftRWHandler) TChanServer {
+	return &thriftRWServer{
+		service:  service,
+		handlers: handlers,
+	}
+}
+
+func (s *thriftRWServer) Service() string {
+	return s.service
+}
+
+func (s *thriftRWServer) Methods() []string {
+	methods := make([]string, 0, len(s.handlers))
+	for m := range s.handlers {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+func (s *thriftRWServer) Handle(ctx Context, methodName string, protocol athrift.TProtocol) (bool, athrift.TStruct, error) {
+	handler, ok := s.handlers[methodName]
+	if !ok {
+		return false, nil, fmt.Errorf("method %v not found in service %v", methodName, s.Service())
+	}
+
+	req, err := readThriftRWValue(protocol)
+	if err != nil {
+		return false, nil, err
+	}
+
+	res, err := handler(ctx, req)
+	if err != nil {
+		return false, nil, err
+	}
+	if res.Body == nil {
+		return false, nil, fmt.Errorf("method %v of service %v returned no response", methodName, s.Service())
+	}
+
+	body, err := res.Body.ToWire()
+	if err != nil {
+		return false, nil, err
+	}
+	return !res.IsApplicationError, thriftRWStruct{body}, nil
+}
+
+// readThriftRWValue reads the remaining arg3 bytes from the protocol as a struct.
+func readThriftRWValue(p athrift.TProtocol) (wire.Value, error) {
+	bs, err := ioutil.ReadAll(p.Transport())
+	if err != nil {
+		return wire.Value{}, err
+	}
+
+	v, err := rwprotocol.Binary.Decode(bytes.NewReader(bs), wire.TStruct)
+	if err != nil {
+		return wire.Value{}, athrift.NewTProtocolException(err)
+	}
+	return v, nil
+}
+
+// thriftRWStruct adapts a ThriftRW value to be written as an Apache Thrift struct.
+type thriftRWStruct struct {
+	v wire.Value
+}
+
+func (s thriftRWStruct) Write(p athrift.TProtocol) error {
+	return rwprotocol.Binary.Encode(s.v, p.Transport())
+}
+
+func (s thriftRWStruct) Read(p athrift.TProtocol) error {
+	return errThriftRWReadUnsupported
+}
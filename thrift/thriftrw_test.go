@@ -0,0 +1,136 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package thrift_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	// Test is in a separate package to avoid circular dependencies.
+	. "github.com/uber/tchannel-go/thrift"
+
+	tchannel "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/testutils"
+	gen "github.com/uber/tchannel-go/thrift/gen-go/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/thriftrw/wire"
+)
+
+// wireResult is a Result struct in the style of ThriftRW generated code.
+type wireResult struct {
+	fields []wire.Field
+}
+
+func (r wireResult) ToWire() (wire.Value, error) {
+	return wire.NewValueStruct(wire.Struct{Fields: r.fields}), nil
+}
+
+func newThriftRWSimpleService() TChanServer {
+	return NewThriftRWServer("SimpleService", map[string]ThriftRWHandler{
+		"Call": func(ctx Context, req wire.Value) (ThriftRWResponse, error) {
+			// Echo the Data argument back as the success field.
+			for _, f := range req.GetStruct().Fields {
+				if f.ID == 1 {
+					return ThriftRWResponse{Body: wireResult{[]wire.Field{{ID: 0, Value: f.Value}}}}, nil
+				}
+			}
+			return ThriftRWResponse{}, errors.New("missing arg")
+		},
+		"Simple": func(ctx Context, req wire.Value) (ThriftRWResponse, error) {
+			simpleErr := wire.NewValueStruct(wire.Struct{Fields: []wire.Field{
+				{ID: 1, Value: wire.NewValueString("simple failed")},
+			}})
+			result := wireResult{[]wire.Field{{ID: 1, Value: simpleErr}}}
+			return NewThriftRWResponse(errors.New("simple failed"), result, nil)
+		},
+	})
+}
+
+func TestThriftRWServer(t *testing.T) {
+	ctx, cancel := NewContext(testutils.Timeout(time.Second))
+	defer cancel()
+
+	serverCh := testutils.NewServer(t, nil)
+	defer serverCh.Close()
+	NewServer(serverCh).Register(newThriftRWSimpleService())
+
+	clientCh, client, _ := getClients(t, serverCh.PeerInfo(), serverCh.ServiceName(), nil)
+	defer clientCh.Close()
+
+	data := &gen.Data{B1: true, S2: "thriftrw", I3: 3}
+	res, err := client.Call(ctx, data)
+	require.NoError(t, err, "Call failed")
+	assert.Equal(t, data, res, "Unexpected response")
+
+	err = client.Simple(ctx)
+	assert.Equal(t, &gen.SimpleErr{Message: "simple failed"}, err, "Expected typed exception")
+
+	// Exceptions are marked as application errors, with the exception in arg3.
+	call, err := clientCh.BeginCall(ctx, serverCh.PeerInfo().HostPort, serverCh.ServiceName(), "SimpleService::Simple", nil)
+	require.NoError(t, err, "BeginCall failed")
+	withWriter(t, call.Arg2Writer, func(w tchannel.ArgWriter) error {
+		return WriteHeaders(w, nil)
+	})
+	withWriter(t, call.Arg3Writer, func(w tchannel.ArgWriter) error {
+		return WriteStruct(w, &gen.SimpleServiceSimpleArgs{})
+	})
+
+	response := call.Response()
+	withReader(t, response.Arg2Reader, func(r tchannel.ArgReader) error {
+		_, err := ReadHeaders(r)
+		return err
+	})
+	var result gen.SimpleServiceSimpleResult
+	withReader(t, response.Arg3Reader, func(r tchannel.ArgReader) error {
+		return ReadStruct(r, &result)
+	})
+	assert.True(t, response.ApplicationError(), "Expected application error")
+	assert.Equal(t, &gen.SimpleErr{Message: "simple failed"}, result.SimpleErr, "Unexpected exception in arg3")
+}
+
+func TestThriftRWServerBadRequest(t *testing.T) {
+	ctx, cancel := NewContext(testutils.Timeout(time.Second))
+	defer cancel()
+
+	serverCh := testutils.NewServer(t, nil)
+	defer serverCh.Close()
+	NewServer(serverCh).Register(newThriftRWSimpleService())
+
+	clientCh := testutils.NewClient(t, nil)
+	defer clientCh.Close()
+
+	call, err := clientCh.BeginCall(ctx, serverCh.PeerInfo().HostPort, serverCh.ServiceName(), "SimpleService::Call", nil)
+	require.NoError(t, err, "BeginCall failed")
+	withWriter(t, call.Arg2Writer, func(w tchannel.ArgWriter) error {
+		return WriteHeaders(w, nil)
+	})
+	withWriter(t, call.Arg3Writer, func(w tchannel.ArgWriter) error {
+		// An unterminated struct cannot be decoded.
+		_, err := w.Write([]byte{0x0c, 0x00})
+		return err
+	})
+
+	_, err = call.Response().Arg2Reader()
+	assert.Equal(t, tchannel.ErrCodeBadRequest, tchannel.GetSystemErrorCode(err), "Expected bad request, got %v", err)
+}
@@ -1,6 +1,7 @@
 package thrift_test
 
 import (
+	"context"
 	json_encoding "encoding/json"
 	"testing"
 
@@ -8,8 +9,6 @@ import (
 	. "github.com/uber/tchannel-go/testutils/testtracing"
 	"github.com/uber/tchannel-go/thrift"
 	gen "github.com/uber/tchannel-go/thrift/gen-go/test"
-
-	"golang.org/x/net/context"
 )
 
 // ThriftHandler tests tracing over Thrift encoding
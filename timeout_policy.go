@@ -0,0 +1,190 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TimeoutPolicyOptions configures a TimeoutPolicy.
+type TimeoutPolicyOptions struct {
+	// Percentile is the percentile of recent latencies used as the default
+	// timeout, between 0 and 1. If this is 0, the default of 0.99 is used.
+	Percentile float64
+
+	// Margin is added to the latency percentile to get the default timeout.
+	Margin time.Duration
+
+	// MinTimeout and MaxTimeout clamp the derived timeout. If MinTimeout is
+	// 0, the default of 10ms is used, and if MaxTimeout is 0, the default of
+	// 10s is used.
+	MinTimeout time.Duration
+	MaxTimeout time.Duration
+
+	// InitialTimeout is used for methods that do not have enough recent
+	// latencies to derive a timeout. If this is 0, MaxTimeout is used.
+	InitialTimeout time.Duration
+
+	// WindowSize is the number of recent latencies recorded for each method.
+	// If this is 0, the default of 200 is used.
+	WindowSize int
+
+	// MinSamples is the number of latencies that must be recorded for a
+	// method before its timeout is derived from them. If this is 0, the
+	// default of 20 is used.
+	MinSamples int
+}
+
+func (o TimeoutPolicyOptions) withDefaults() TimeoutPolicyOptions {
+	if o.Percentile <= 0 || o.Percentile > 1 {
+		o.Percentile = 0.99
+	}
+	if o.MinTimeout <= 0 {
+		o.MinTimeout = 10 * time.Millisecond
+	}
+	if o.MaxTimeout <= 0 {
+		o.MaxTimeout = 10 * time.Second
+	}
+	if o.MaxTimeout < o.MinTimeout {
+		o.MaxTimeout = o.MinTimeout
+	}
+	if o.InitialTimeout <= 0 {
+		o.InitialTimeout = o.MaxTimeout
+	}
+	if o.WindowSize <= 0 {
+		o.WindowSize = 200
+	}
+	if o.MinSamples <= 0 {
+		o.MinSamples = 20
+	}
+	if o.MinSamples > o.WindowSize {
+		o.MinSamples = o.WindowSize
+	}
+	return o
+}
+
+// TimeoutPolicy records the latency of recent outbound calls for each method,
+// and derives the timeout for calls that are made without a deadline.
+type TimeoutPolicy struct {
+	sync.Mutex
+
+	opts    TimeoutPolicyOptions
+	methods map[string]*latencyWindow
+}
+
+// latencyWindow is a ring buffer of recent latencies.
+type latencyWindow struct {
+	latencies []time.Duration
+	next      int
+}
+
+// NewTimeoutPolicy returns a TimeoutPolicy using the given options. It is
+// used by passing it to WithTimeoutPolicy when creating a SubChannel.
+func NewTimeoutPolicy(opts TimeoutPolicyOptions) *TimeoutPolicy {
+	return &TimeoutPolicy{
+		opts:    opts.withDefaults(),
+		methods: make(map[string]*latencyWindow),
+	}
+}
+
+// Record records the latency of a successful call to method.
+func (p *TimeoutPolicy) Record(method string, latency time.Duration) {
+	p.Lock()
+	defer p.Unlock()
+
+	w, ok := p.methods[method]
+	if !ok {
+		w = &latencyWindow{latencies: make([]time.Duration, 0, p.opts.WindowSize)}
+		p.methods[method] = w
+	}
+	if len(w.latencies) < p.opts.WindowSize {
+		w.latencies = append(w.latencies, latency)
+		return
+	}
+	w.latencies[w.next] = latency
+	w.next = (w.next + 1) % len(w.latencies)
+}
+
+// Timeout returns the timeout for calls to method that do not have a
+// deadline.
+func (p *TimeoutPolicy) Timeout(method string) time.Duration {
+	p.Lock()
+	w, ok := p.methods[method]
+	if !ok || len(w.latencies) < p.opts.MinSamples {
+		p.Unlock()
+		return p.opts.InitialTimeout
+	}
+	latencies := append([]time.Duration(nil), w.latencies...)
+	p.Unlock()
+
+	sort.Sort(durations(latencies))
+	idx := int(math.Ceil(p.opts.Percentile*float64(len(latencies)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+
+	timeout := latencies[idx] + p.opts.Margin
+	if timeout < p.opts.MinTimeout {
+		return p.opts.MinTimeout
+	}
+	if timeout > p.opts.MaxTimeout {
+		return p.opts.MaxTimeout
+	}
+	return timeout
+}
+
+// WithTimeoutPolicy is a SubChannelOption that records the latency of
+// outbound calls made using the subchannel in the given policy, and uses it
+// to set the timeout for calls made with a context that has no deadline.
+// A policy should only be used by a single subchannel.
+func WithTimeoutPolicy(p *TimeoutPolicy) SubChannelOption {
+	return func(s *SubChannel) {
+		s.Lock()
+		s.timeoutPolicy = p
+		s.Unlock()
+	}
+}
+
+// withDefaultTimeout returns a context with the timeout from the policy if
+// ctx has no deadline. The returned cancel function is never nil.
+func (p *TimeoutPolicy) withDefaultTimeout(ctx context.Context, method string) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, p.Timeout(method))
+}
+
+// observe records the latency of the call once its response has been read
+// successfully, and calls cancel once the response is done.
+func (p *TimeoutPolicy) observe(call *OutboundCall, method string, cancel context.CancelFunc) {
+	response := call.response
+	response.onFailed = chainCallback(response.onFailed, func(error) { cancel() })
+	response.onDone = chainCallback(response.onDone, func(err error) {
+		if err == nil {
+			p.Record(method, response.timeNow().Sub(response.startedAt))
+		}
+		cancel()
+	})
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeoutPolicy(t *testing.T) {
+	policy := NewTimeoutPolicy(TimeoutPolicyOptions{
+		Margin:         5 * time.Millisecond,
+		MinTimeout:     20 * time.Millisecond,
+		MaxTimeout:     time.Second,
+		InitialTimeout: 500 * time.Millisecond,
+		WindowSize:     100,
+		MinSamples:     10,
+	})
+
+	assert.Equal(t, 500*time.Millisecond, policy.Timeout("m"), "Unknown method should use the initial timeout")
+	for i := 1; i < 10; i++ {
+		policy.Record("m", time.Duration(i)*time.Millisecond)
+	}
+	assert.Equal(t, 500*time.Millisecond, policy.Timeout("m"), "Method without enough samples should use the initial timeout")
+
+	for i := 10; i <= 100; i++ {
+		policy.Record("m", time.Duration(i)*time.Millisecond)
+	}
+	assert.Equal(t, 104*time.Millisecond, policy.Timeout("m"), "Timeout should be p99 + margin")
+	assert.Equal(t, 500*time.Millisecond, policy.Timeout("other"), "Methods should be tracked separately")
+
+	// Recording more latencies replaces the oldest ones.
+	for i := 0; i < 100; i++ {
+		policy.Record("m", time.Millisecond)
+	}
+	assert.Equal(t, 20*time.Millisecond, policy.Timeout("m"), "Timeout should be clamped to the minimum")
+
+	for i := 0; i < 100; i++ {
+		policy.Record("m", 5*time.Second)
+	}
+	assert.Equal(t, time.Second, policy.Timeout("m"), "Timeout should be clamped to the maximum")
+}
+
+func TestTimeoutPolicyDefaults(t *testing.T) {
+	policy := NewTimeoutPolicy(TimeoutPolicyOptions{})
+	assert.Equal(t, 10*time.Second, policy.Timeout("m"), "Initial timeout should default to the max timeout")
+
+	for i := 0; i < 20; i++ {
+		policy.Record("m", time.Millisecond)
+	}
+	assert.Equal(t, 10*time.Millisecond, policy.Timeout("m"), "Unexpected default min timeout")
+}
+
+func TestSubChannelTimeoutPolicy(t *testing.T) {
+	testutils.WithTestServer(t, nil, func(ts *testutils.TestServer) {
+		ttls := make(chan time.Duration, 1)
+		testutils.RegisterFunc(ts.Server(), "ttl", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+			deadline, _ := ctx.Deadline()
+			ttls <- deadline.Sub(time.Now())
+			return &raw.Res{}, nil
+		})
+
+		policy := NewTimeoutPolicy(TimeoutPolicyOptions{
+			MinTimeout:     testutils.Timeout(300 * time.Millisecond),
+			InitialTimeout: 5 * time.Second,
+			MinSamples:     5,
+		})
+		client := ts.NewClient(nil)
+		sc := client.GetSubChannel(ts.ServiceName(), WithTimeoutPolicy(policy))
+		client.Peers().Add(ts.HostPort())
+
+		call := func(ctx context.Context) time.Duration {
+			_, _, _, err := raw.CallSC(ctx, sc, "ttl", nil, nil)
+			require.NoError(t, err, "Call failed")
+			return <-ttls
+		}
+
+		for i := 0; i < 5; i++ {
+			ttl := call(context.Background())
+			assert.True(t, ttl > 4*time.Second, "Call %v should use the initial timeout, got %v", i, ttl)
+		}
+
+		ttl := call(context.Background())
+		assert.True(t, ttl > 0 && ttl <= testutils.Timeout(300*time.Millisecond),
+			"Call should use the timeout derived from recent latencies, got %v", ttl)
+
+		ctx, cancel := NewContext(3 * time.Second)
+		defer cancel()
+		ttl = call(ctx)
+		assert.True(t, ttl > 2*time.Second && ttl <= 3*time.Second, "Explicit timeout should not be changed, got %v", ttl)
+	})
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"crypto/tls"
+	"net"
+)
+
+// tlsConn is a TLS connection that keeps a reference to the underlying
+// network connection, which is required to set socket options such as ToS.
+type tlsConn struct {
+	*tls.Conn
+
+	raw net.Conn
+}
+
+// tlsServer wraps an accepted connection with TLS if the channel is
+// configured to use TLS.
+func (ch *Channel) tlsServer(c net.Conn) net.Conn {
+	if ch.tlsConfig == nil {
+		return c
+	}
+	return tlsConn{Conn: tls.Server(c, ch.tlsConfig), raw: c}
+}
+
+// tlsClient wraps an outbound connection to hostPort with TLS if the channel
+// is configured to use TLS for that peer.
+func (ch *Channel) tlsClient(c net.Conn, hostPort string) net.Conn {
+	config := ch.tlsConfig
+	if ch.outboundTLSConfig != nil {
+		if peerConfig := ch.outboundTLSConfig(hostPort); peerConfig != nil {
+			config = peerConfig
+		}
+	}
+	if config == nil {
+		return c
+	}
+	if config.ServerName == "" {
+		// Verify the peer's certificate against the host being dialed, as
+		// tls.Dial does, rather than failing the handshake.
+		if host, _, err := net.SplitHostPort(hostPort); err == nil {
+			config = cloneTLSConfig(config)
+			config.ServerName = host
+		}
+	}
+	return tlsConn{Conn: tls.Client(c, config), raw: c}
+}
+
+// rawConn returns the network connection underlying any TLS connection.
+func rawConn(c net.Conn) net.Conn {
+	if tc, ok := c.(tlsConn); ok {
+		return tc.raw
+	}
+	return c
+}
+
+// TLSConnectionState returns the TLS state of the connection, including any
+// certificates presented by the remote peer. The second return value is false
+// if the connection does not use TLS.
+func (c *Connection) TLSConnectionState() (tls.ConnectionState, bool) {
+	tc, ok := c.conn.(tlsConn)
+	if !ok {
+		return tls.ConnectionState{}, false
+	}
+	return tc.ConnectionState(), true
+}
@@ -1,4 +1,4 @@
-// Copyright (c) 2015 Uber Technologies, Inc.
+// Copyright (c) 2017 Uber Technologies, Inc.
 
 // Permission is hereby granted, free of charge, to any person obtaining a copy
 // of this software and associated documentation files (the "Software"), to deal
@@ -18,17 +18,13 @@
 // OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
 // THE SOFTWARE.
 
-// +build !go1.7
+//go:build go1.8
+// +build go1.8
 
 package tchannel
 
-import (
-	"net"
+import "crypto/tls"
 
-	"golang.org/x/net/context"
-)
-
-func dialContext(ctx context.Context, hostPort string) (net.Conn, error) {
-	timeout := getTimeout(ctx)
-	return net.DialTimeout("tcp", hostPort, timeout)
+func cloneTLSConfig(c *tls.Config) *tls.Config {
+	return c.Clone()
 }
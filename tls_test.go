@@ -0,0 +1,187 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestCert creates a self-signed certificate valid for 127.0.0.1 that can
+// be used both as a server and a client certificate.
+func newTestCert(t *testing.T, commonName string) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "Failed to generate key")
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err, "Failed to create certificate")
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err, "Failed to parse certificate")
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}, pool
+}
+
+func TestTLSRoundTrip(t *testing.T) {
+	serverCert, serverPool := newTestCert(t, "server")
+	clientCert, clientPool := newTestCert(t, "client")
+
+	sopts := testutils.NewOpts()
+	sopts.TLSConfig = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientPool,
+	}
+	server := testutils.NewServer(t, sopts)
+	defer server.Close()
+
+	testutils.RegisterFunc(server, "echo", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		conn, _ := InboundConnection(CurrentCall(ctx))
+		state, ok := conn.TLSConnectionState()
+		if assert.True(t, ok, "Inbound connection should use TLS") &&
+			assert.Len(t, state.PeerCertificates, 1, "Expected client certificate") {
+			assert.Equal(t, "client", state.PeerCertificates[0].Subject.CommonName, "Unexpected client identity")
+		}
+		return &raw.Res{Arg2: args.Arg2, Arg3: args.Arg3}, nil
+	})
+
+	copts := testutils.NewOpts()
+	copts.TLSConfig = &tls.Config{
+		Certificates: []tls.Certificate{clientCert},
+		RootCAs:      serverPool,
+		ServerName:   "127.0.0.1",
+	}
+	client := testutils.NewClient(t, copts)
+	defer client.Close()
+
+	ctx, cancel := NewContext(testutils.Timeout(time.Second))
+	defer cancel()
+
+	hostPort := server.PeerInfo().HostPort
+	_, arg3, _, err := raw.Call(ctx, client, hostPort, server.ServiceName(), "echo", nil, []byte("hello"))
+	require.NoError(t, err, "Call over TLS failed")
+	assert.Equal(t, "hello", string(arg3), "Unexpected response")
+
+	conn, err := client.Peers().GetOrAdd(hostPort).GetConnection(ctx)
+	require.NoError(t, err, "GetConnection failed")
+	state, ok := conn.TLSConnectionState()
+	require.True(t, ok, "Outbound connection should use TLS")
+	assert.True(t, state.HandshakeComplete, "TLS handshake should be complete")
+	if assert.Len(t, state.PeerCertificates, 1, "Expected server certificate") {
+		assert.Equal(t, "server", state.PeerCertificates[0].Subject.CommonName, "Unexpected server identity")
+	}
+}
+
+func TestTLSOutboundConfigPerPeer(t *testing.T) {
+	serverCert, serverPool := newTestCert(t, "server")
+
+	sopts := testutils.NewOpts()
+	sopts.TLSConfig = &tls.Config{Certificates: []tls.Certificate{serverCert}}
+	server := testutils.NewServer(t, sopts)
+	defer server.Close()
+	hostPort := server.PeerInfo().HostPort
+
+	var configuredFor []string
+	copts := testutils.NewOpts()
+	copts.OutboundTLSConfig = func(peerHostPort string) *tls.Config {
+		configuredFor = append(configuredFor, peerHostPort)
+		host, _, err := net.SplitHostPort(peerHostPort)
+		require.NoError(t, err, "Failed to split host:port")
+		return &tls.Config{RootCAs: serverPool, ServerName: host}
+	}
+	client := testutils.NewClient(t, copts)
+	defer client.Close()
+
+	ctx, cancel := NewContext(testutils.Timeout(time.Second))
+	defer cancel()
+
+	require.NoError(t, client.Ping(ctx, hostPort), "Ping over TLS failed")
+	assert.Equal(t, []string{hostPort}, configuredFor, "OutboundTLSConfig should be called for the new connection")
+}
+
+func TestTLSDefaultServerName(t *testing.T) {
+	serverCert, serverPool := newTestCert(t, "server")
+
+	sopts := testutils.NewOpts()
+	sopts.TLSConfig = &tls.Config{Certificates: []tls.Certificate{serverCert}}
+	server := testutils.NewServer(t, sopts)
+	defer server.Close()
+
+	// With no ServerName, the server's certificate should be verified
+	// against the host being dialed. The config should not be modified.
+	clientConfig := &tls.Config{RootCAs: serverPool}
+	copts := testutils.NewOpts()
+	copts.TLSConfig = clientConfig
+	client := testutils.NewClient(t, copts)
+	defer client.Close()
+
+	ctx, cancel := NewContext(testutils.Timeout(time.Second))
+	defer cancel()
+
+	require.NoError(t, client.Ping(ctx, server.PeerInfo().HostPort), "Ping over TLS failed")
+	assert.Empty(t, clientConfig.ServerName, "TLS config should not be modified")
+}
+
+func TestTLSPlaintextClientRejected(t *testing.T) {
+	serverCert, _ := newTestCert(t, "server")
+
+	sopts := testutils.NewOpts().AddLogFilter("Failed during connection handshake", 1)
+	sopts.TLSConfig = &tls.Config{Certificates: []tls.Certificate{serverCert}}
+	server := testutils.NewServer(t, sopts)
+	defer server.Close()
+
+	client := testutils.NewClient(t, testutils.NewOpts().AddLogFilter("Failed during connection handshake", 1))
+	defer client.Close()
+
+	ctx, cancel := NewContext(testutils.Timeout(time.Second))
+	defer cancel()
+
+	assert.Error(t, client.Ping(ctx, server.PeerInfo().HostPort), "Plaintext ping to a TLS server should fail")
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// MemoryNetwork is the network name of in-memory addresses.
+const MemoryNetwork = "memory"
+
+var (
+	// ErrNoMemoryListener is returned by DialMemory if there is no in-memory
+	// listener at the address.
+	ErrNoMemoryListener = errors.New("no in-memory listener at address")
+
+	errMemoryListenerClosed = errors.New("in-memory listener is closed")
+)
+
+var memoryListeners = struct {
+	sync.Mutex
+	byAddr     map[string]*memoryListener
+	lastPort   int
+	lastClient int
+}{byAddr: make(map[string]*memoryListener)}
+
+type memoryAddr string
+
+func (a memoryAddr) Network() string { return MemoryNetwork }
+func (a memoryAddr) String() string  { return string(a) }
+
+// memoryConn is one end of an in-memory connection.
+type memoryConn struct {
+	net.Conn
+
+	local, remote net.Addr
+}
+
+func (c memoryConn) LocalAddr() net.Addr  { return c.local }
+func (c memoryConn) RemoteAddr() net.Addr { return c.remote }
+
+type memoryListener struct {
+	addr      memoryAddr
+	conns     chan net.Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// ListenMemory returns a Listener for connections from the same process,
+// which are made using DialMemory without using the network. The host:port
+// must not be used by another in-memory listener, and if the port is 0, an
+// unused port is chosen. The address does not need to be a valid IP address.
+func ListenMemory(hostPort string) (net.Listener, error) {
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return nil, err
+	}
+
+	memoryListeners.Lock()
+	defer memoryListeners.Unlock()
+
+	if port == "0" {
+		memoryListeners.lastPort++
+		port = strconv.Itoa(memoryListeners.lastPort)
+	}
+	addr := net.JoinHostPort(host, port)
+	if _, ok := memoryListeners.byAddr[addr]; ok {
+		return nil, fmt.Errorf("in-memory address %v is already in use", addr)
+	}
+
+	l := &memoryListener{
+		addr:   memoryAddr(addr),
+		conns:  make(chan net.Conn),
+		closed: make(chan struct{}),
+	}
+	memoryListeners.byAddr[addr] = l
+	return l, nil
+}
+
+// DialMemory connects to the in-memory listener at the given host:port,
+// blocking until the connection is accepted. It returns ErrNoMemoryListener
+// if there is no listener at the address.
+func DialMemory(ctx context.Context, hostPort string) (net.Conn, error) {
+	memoryListeners.Lock()
+	l, ok := memoryListeners.byAddr[hostPort]
+	memoryListeners.lastClient++
+	clientAddr := memoryAddr("memory-client:" + strconv.Itoa(memoryListeners.lastClient))
+	memoryListeners.Unlock()
+
+	if !ok {
+		return nil, ErrNoMemoryListener
+	}
+
+	client, server := net.Pipe()
+	select {
+	case l.conns <- memoryConn{server, l.addr, clientAddr}:
+		return memoryConn{client, clientAddr, l.addr}, nil
+	case <-l.closed:
+		return nil, errMemoryListenerClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *memoryListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.closed:
+		return nil, errMemoryListenerClosed
+	}
+}
+
+// Close stops accepting connections and frees the address. Connections that
+// were already accepted are not closed.
+func (l *memoryListener) Close() error {
+	l.closeOnce.Do(func() {
+		memoryListeners.Lock()
+		delete(memoryListeners.byAddr, string(l.addr))
+		memoryListeners.Unlock()
+		close(l.closed)
+	})
+	return nil
+}
+
+func (l *memoryListener) Addr() net.Addr {
+	return l.addr
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tnet
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryListener(t *testing.T) {
+	ln, err := ListenMemory("memory:0")
+	require.NoError(t, err, "ListenMemory failed")
+	addr := ln.Addr().String()
+	assert.Equal(t, MemoryNetwork, ln.Addr().Network(), "Unexpected network")
+	assert.NotEqual(t, "memory:0", addr, "Expected a port to be chosen")
+
+	_, err = ListenMemory(addr)
+	assert.Error(t, err, "Listening on an address in use should fail")
+
+	accepted := make(chan struct{})
+	go func() {
+		defer close(accepted)
+		conn, err := ln.Accept()
+		if !assert.NoError(t, err, "Accept failed") {
+			return
+		}
+		defer conn.Close()
+		assert.Equal(t, addr, conn.LocalAddr().String(), "Unexpected local address")
+		_, err = io.Copy(conn, conn)
+		assert.NoError(t, err, "Echo failed")
+	}()
+
+	conn, err := DialMemory(context.Background(), addr)
+	require.NoError(t, err, "DialMemory failed")
+	assert.Equal(t, addr, conn.RemoteAddr().String(), "Unexpected remote address")
+
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err, "Write failed")
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err, "Read failed")
+	assert.Equal(t, "hello", string(buf), "Unexpected echo")
+	require.NoError(t, conn.Close(), "Close failed")
+	<-accepted
+
+	require.NoError(t, ln.Close(), "Close listener failed")
+	_, err = ln.Accept()
+	assert.Error(t, err, "Accept after Close should fail")
+	_, err = DialMemory(context.Background(), addr)
+	assert.Equal(t, ErrNoMemoryListener, err, "Dial after Close should fail")
+
+	// The address can be reused once the listener is closed.
+	ln, err = ListenMemory(addr)
+	require.NoError(t, err, "ListenMemory on a freed address failed")
+	defer ln.Close()
+}
+
+func TestDialMemoryTimeout(t *testing.T) {
+	ln, err := ListenMemory("memory:0")
+	require.NoError(t, err, "ListenMemory failed")
+	defer ln.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = DialMemory(ctx, ln.Addr().String())
+	assert.Equal(t, context.DeadlineExceeded, err, "Dial without Accept should time out")
+}
@@ -21,6 +21,7 @@
 package tchannel
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -29,7 +30,6 @@ import (
 
 	"github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
-	"golang.org/x/net/context"
 )
 
 // zipkinSpanFormat defines a name for OpenTracing carrier format that tracer may support.
@@ -21,6 +21,7 @@
 package tchannel
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"testing"
@@ -32,7 +33,6 @@ import (
 	"github.com/opentracing/opentracing-go/mocktracer"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"golang.org/x/net/context"
 )
 
 func TestTracingSpanEncoding(t *testing.T) {
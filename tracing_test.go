@@ -21,6 +21,7 @@
 package tchannel_test
 
 import (
+	"context"
 	"sync"
 	"testing"
 	"time"
@@ -35,7 +36,6 @@ import (
 	"github.com/opentracing/opentracing-go/mocktracer"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"golang.org/x/net/context"
 )
 
 // JSONHandler tests tracing over JSON encoding
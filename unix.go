@@ -0,0 +1,66 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/uber-go/atomic"
+)
+
+// unixScheme is the prefix for host:ports that are unix domain sockets, such
+// as unix:///var/run/svc.sock, or unix://@svc for abstract sockets on Linux.
+const unixScheme = "unix://"
+
+// _lastUnixClient is used to give each unnamed unix socket client a unique
+// host:port, as there is no address to identify it by.
+var _lastUnixClient atomic.Uint64
+
+// splitUnixHostPort returns the socket path if the host:port is a unix domain
+// socket.
+func splitUnixHostPort(hostPort string) (string, bool) {
+	if !strings.HasPrefix(hostPort, unixScheme) {
+		return "", false
+	}
+	return strings.TrimPrefix(hostPort, unixScheme), true
+}
+
+// networkAddress returns the network and address to dial or listen on for a
+// host:port.
+func networkAddress(hostPort string) (network, address string) {
+	if path, ok := splitUnixHostPort(hostPort); ok {
+		return "unix", path
+	}
+	return "tcp", hostPort
+}
+
+// addrHostPort returns the host:port to report for a socket address.
+func addrHostPort(addr net.Addr) string {
+	if addr.Network() != "unix" {
+		return addr.String()
+	}
+	if name := addr.String(); name != "" && name != "@" {
+		return unixScheme + name
+	}
+	return "unix-client:" + strconv.FormatUint(_lastUnixClient.Inc(), 10)
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/uber/tchannel-go/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tchannel-unix")
+	require.NoError(t, err, "Failed to create temp dir")
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		msg      string
+		hostPort string
+		linux    bool
+	}{
+		{msg: "path", hostPort: "unix://" + filepath.Join(dir, "svc.sock")},
+		{msg: "abstract", hostPort: "unix://@tchannel-unix-test", linux: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			if tt.linux && runtime.GOOS != "linux" {
+				t.Skip("Abstract sockets are only supported on Linux")
+			}
+
+			server := testutils.NewClient(t, testutils.NewOpts().SetServiceName("svc"))
+			defer server.Close()
+			require.NoError(t, server.ListenAndServe(tt.hostPort), "ListenAndServe failed")
+			assert.Equal(t, tt.hostPort, server.PeerInfo().HostPort, "Unexpected host:port")
+			testutils.RegisterEcho(server, nil)
+
+			client := testutils.NewClient(t, nil)
+			defer client.Close()
+			testutils.AssertEcho(t, client, tt.hostPort, "svc")
+
+			// The client has no socket address, so it's given a unique host:port.
+			peers := server.IntrospectState(nil).RootPeers
+			require.Len(t, peers, 1, "Expected a single peer on the server")
+			for hostPort, peer := range peers {
+				assert.True(t, strings.HasPrefix(hostPort, "unix-client:"), "Unexpected client host:port %v", hostPort)
+				assert.Len(t, peer.InboundConnections, 1, "Expected a single inbound connection")
+			}
+		})
+	}
+}
@@ -24,10 +24,9 @@ package tchannel
 // Since it has a _test.go suffix, it is only compiled with tests in this package.
 
 import (
+	"context"
 	"net"
 	"time"
-
-	"golang.org/x/net/context"
 )
 
 // MexChannelBufferSize is the size of the message exchange channel buffer.
@@ -82,3 +81,21 @@ func InboundConnection(call IncomingCall) (*Connection, net.Conn) {
 	conn := inboundCall.conn
 	return conn, conn.conn
 }
+
+// IdempotentCallsInProgress returns the number of inbound calls with an
+// idempotency key that are marked as in progress.
+func (ch *Channel) IdempotentCallsInProgress() int {
+	d := ch.dedup
+	if d == nil {
+		return 0
+	}
+	d.Lock()
+	defer d.Unlock()
+	return len(d.inProgress)
+}
+
+// DisableCallControl stops the channel from advertising support for call
+// control frames to new connections, as older versions of TChannel do.
+func (ch *Channel) DisableCallControl() {
+	ch.callControlDisabled.Store(true)
+}
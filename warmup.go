@@ -0,0 +1,112 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const _defaultWarmUpTimeout = 5 * time.Second
+
+// WarmUpOptions configures connecting to the channel's peers when it is
+// created, so that the first calls to them don't pay the cost of connecting.
+type WarmUpOptions struct {
+	// Connections is the number of peers from the peer cache (see
+	// ChannelOptions.PeerCacheFile) to connect to when the channel is
+	// created. Zero disables the warm-up.
+	Connections int
+
+	// Timeout is the maximum time the warm-up can take. If this is 0, the
+	// default of 5 seconds is used.
+	Timeout time.Duration
+}
+
+func (o WarmUpOptions) withDefaults() WarmUpOptions {
+	if o.Timeout <= 0 {
+		o.Timeout = _defaultWarmUpTimeout
+	}
+	return o
+}
+
+// WarmUp connects to up to n of the channel's peers in the order they would be
+// selected, and returns the number of peers that it connected to, and the
+// first connection error, if any. While a warm-up is in progress, health
+// checks made using CheckHealth report the channel as unhealthy.
+func (ch *Channel) WarmUp(ctx context.Context, n int) (int, error) {
+	ch.health.warmingUp.Inc()
+	defer ch.health.warmingUp.Dec()
+
+	peers := ch.Peers().Snapshot().Peers
+	if len(peers) > n {
+		peers = peers[:n]
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		connected int
+		firstErr  error
+	)
+	for _, p := range peers {
+		peer := ch.RootPeers().GetOrAdd(p.HostPort)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := peer.GetConnection(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				connected++
+			} else if firstErr == nil {
+				firstErr = err
+			}
+		}()
+	}
+	wg.Wait()
+
+	ch.statsReporter.IncCounter("warm-up.connections", ch.StatsTags(), int64(connected))
+	return connected, firstErr
+}
+
+// startWarmUp runs the configured warm-up in the background.
+func (ch *Channel) startWarmUp() {
+	opts := ch.warmUp.withDefaults()
+
+	// Mark the channel as warming up before returning, so it's reported as
+	// unhealthy until the warm-up completes.
+	ch.health.warmingUp.Inc()
+	go func() {
+		defer ch.health.warmingUp.Dec()
+
+		ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+		defer cancel()
+		connected, err := ch.WarmUp(ctx, opts.Connections)
+		if err != nil {
+			ch.log.WithFields(
+				LogField{"connected", connected},
+				ErrField(err),
+			).Info("Failed to connect to some peers during warm-up.")
+		}
+	}()
+}